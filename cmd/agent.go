@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	agentCoordinator  string
+	agentAuthToken    string
+	agentName         string
+	agentNetworks     string
+	agentScanTypes    string
+	agentConcurrency  int
+	agentPollInterval time.Duration
+)
+
+// agentCmd 分布式扫描代理
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "以代理模式运行，从协调器拉取扫描任务",
+	Long: `以分布式扫描代理模式运行：向协调器(api命令启动并设置--agent-token)注册，
+上报本机能力(是否可发送原始报文、可达网段)，持续拉取按主机和端口分片的工作单元，
+在本地网络中执行扫描并将结果回报给协调器，由协调器合并为一份结果。
+例如：
+  go-port-rocket agent --coordinator http://10.0.0.1:8080 --token secret
+  go-port-rocket agent --coordinator http://10.0.0.1:8080 --token secret --networks 192.168.10.0/24,10.20.0.0/16 --concurrency 4`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agent, err := api.NewAgent(&api.AgentConfig{
+			CoordinatorURL: agentCoordinator,
+			Token:          agentAuthToken,
+			Name:           agentName,
+			Networks:       splitList(agentNetworks),
+			ScanTypes:      splitList(agentScanTypes),
+			Concurrency:    agentConcurrency,
+			PollInterval:   agentPollInterval,
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// 设置信号处理
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigChan
+			fmt.Println("\n正在停止代理...")
+			cancel()
+		}()
+
+		fmt.Printf("代理正在连接协调器: %s\n", agentCoordinator)
+		return agent.Run(ctx)
+	},
+}
+
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func init() {
+	// 添加命令行参数
+	agentCmd.Flags().StringVar(&agentCoordinator, "coordinator", "", "协调器地址，如 http://10.0.0.1:8080")
+	agentCmd.Flags().StringVar(&agentAuthToken, "token", "", "代理认证令牌，与协调器的--agent-token一致")
+	agentCmd.Flags().StringVar(&agentName, "name", "", "代理名称，默认使用主机名")
+	agentCmd.Flags().StringVar(&agentNetworks, "networks", "", "本代理可达的网段(CIDR)，逗号分隔，为空表示不限制")
+	agentCmd.Flags().StringVar(&agentScanTypes, "scan-types", "", "本代理接受的扫描类型，逗号分隔，为空表示不限制")
+	agentCmd.Flags().IntVar(&agentConcurrency, "concurrency", 1, "同时执行的工作单元数")
+	agentCmd.Flags().DurationVar(&agentPollInterval, "poll-interval", 0, "没有任务时的轮询间隔，0表示使用协调器建议的间隔")
+
+	agentCmd.MarkFlagRequired("coordinator")
+
+	// 绑定到viper配置
+	viper.BindPFlag("agent.coordinator", agentCmd.Flags().Lookup("coordinator"))
+	viper.BindPFlag("agent.token", agentCmd.Flags().Lookup("token"))
+	viper.BindPFlag("agent.networks", agentCmd.Flags().Lookup("networks"))
+
+	// 添加到根命令
+	RootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	analyzePcapFile      string
+	analyzeTarget        string
+	analyzePorts         string
+	analyzeEnableService bool
+	analyzeEnableOS      bool
+	analyzeFormat        string
+	analyzeOutputFile    string
+	analyzeHoneypotLimit int
+)
+
+// analyzeCmd 离线分析抓包文件
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "离线分析抓包文件",
+	Long: `从pcap/pcapng抓包中重建端口状态、banner和操作系统探测应答，并执行指纹识别，不访问网络。
+例如：
+  go-port-rocket analyze --pcap scan.pcapng
+  go-port-rocket analyze --pcap scan.pcapng -t 192.168.1.1 -p 1-1000 --service-detection -O
+  go-port-rocket analyze --pcap scan.pcapng --format json -o result.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if analyzeFormat != "text" && analyzeFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", analyzeFormat)
+		}
+
+		replay, err := scanner.LoadPacketReplay(analyzePcapFile)
+		if err != nil {
+			return err
+		}
+
+		var hosts []scanner.ReplayHost
+		if analyzeTarget != "" {
+			// 指定目标时走扫描流程，由抓包数据代替网络应答
+			if analyzePorts == "" {
+				var ports []string
+				for _, r := range replay.Results(analyzeTarget) {
+					ports = append(ports, strconv.Itoa(r.Port))
+				}
+				if len(ports) == 0 {
+					return fmt.Errorf("抓包中没有目标 %s 的报文", analyzeTarget)
+				}
+				analyzePorts = strings.Join(ports, ",")
+			}
+			results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+				Target:        analyzeTarget,
+				Ports:         analyzePorts,
+				ScanType:      scanner.ScanTypeSYN,
+				Timeout:       time.Second,
+				Workers:       1,
+				EnableService: analyzeEnableService,
+				EnableOS:      analyzeEnableOS,
+				Replay:        replay,
+			})
+			if err != nil {
+				return fmt.Errorf("分析失败: %v", err)
+			}
+			host := scanner.ReplayHost{Target: analyzeTarget, Results: results, Honeypot: scanner.ScoreHoneypot(results)}
+			for _, r := range results {
+				if r.OS != nil {
+					host.OS = r.OS
+					break
+				}
+			}
+			hosts = append(hosts, host)
+		} else {
+			hosts, err = replay.Analyze(analyzeEnableService, analyzeEnableOS)
+			if err != nil {
+				return fmt.Errorf("分析失败: %v", err)
+			}
+		}
+
+		// 过滤疑似蜜罐的主机，避免污染资产清单
+		var excluded []scanner.ReplayHost
+		if analyzeHoneypotLimit > 0 {
+			kept := hosts[:0]
+			for _, host := range hosts {
+				if host.Honeypot.Exceeds(analyzeHoneypotLimit) {
+					excluded = append(excluded, host)
+					continue
+				}
+				kept = append(kept, host)
+			}
+			hosts = kept
+		}
+
+		if analyzeFormat == "json" || analyzeOutputFile != "" {
+			data, err := json.MarshalIndent(hosts, "", "  ")
+			if err != nil {
+				return fmt.Errorf("序列化结果失败: %v", err)
+			}
+			if analyzeOutputFile != "" {
+				if err := os.WriteFile(analyzeOutputFile, data, 0644); err != nil {
+					return fmt.Errorf("写入输出文件失败: %v", err)
+				}
+				fmt.Printf("分析结果已保存到: %s\n", analyzeOutputFile)
+			}
+			if analyzeFormat == "json" {
+				fmt.Println(string(data))
+				return nil
+			}
+		}
+
+		fmt.Printf("读取 %d 个报文，忽略 %d 个，共 %d 个目标\n", replay.Packets, replay.Skipped, len(hosts))
+		for _, host := range excluded {
+			fmt.Printf("已排除疑似蜜罐主机 %s (评分 %s)\n", host.Target, host.Honeypot)
+		}
+		for _, host := range hosts {
+			fmt.Printf("\n目标: %s\n", host.Target)
+			scanner.PrintResults(host.Results)
+		}
+		return nil
+	},
+}
+
+func init() {
+	// 添加命令行参数
+	analyzeCmd.Flags().StringVar(&analyzePcapFile, "pcap", "", "要分析的pcap/pcapng文件")
+	analyzeCmd.Flags().StringVarP(&analyzeTarget, "target", "t", "", "只分析指定目标")
+	analyzeCmd.Flags().StringVarP(&analyzePorts, "ports", "p", "", "指定目标时分析的端口范围 (默认为抓包中出现的端口)")
+	analyzeCmd.Flags().BoolVar(&analyzeEnableService, "service-detection", false, "根据抓包中的banner识别服务")
+	analyzeCmd.Flags().BoolVarP(&analyzeEnableOS, "os-detection", "O", false, "根据抓包中的应答报文识别操作系统")
+	analyzeCmd.Flags().StringVar(&analyzeFormat, "format", "text", "输出格式 (text, json)")
+	analyzeCmd.Flags().StringVarP(&analyzeOutputFile, "output", "o", "", "将JSON结果保存到文件")
+	analyzeCmd.Flags().IntVar(&analyzeHoneypotLimit, "honeypot-threshold", 0, "排除蜜罐评分达到该值的主机 (0表示不过滤，建议50)")
+
+	// 绑定到viper配置
+	viper.BindPFlag("analyze.pcap", analyzeCmd.Flags().Lookup("pcap"))
+	viper.BindPFlag("analyze.format", analyzeCmd.Flags().Lookup("format"))
+
+	// 设置必填参数
+	analyzeCmd.MarkFlagRequired("pcap")
+
+	// 添加到根命令
+	RootCmd.AddCommand(analyzeCmd)
+}
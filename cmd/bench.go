@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	benchEngines     string
+	benchWorkers     string
+	benchRateLimits  string
+	benchOpenPorts   int
+	benchClosedPorts int
+	benchDelayed     int
+	benchDelay       time.Duration
+	benchTimeout     time.Duration
+	benchProfile     string
+	benchNoSave      bool
+	benchFormat      string
+	benchOutputFile  string
+)
+
+// benchCmd 扫描器基准测试
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "测量本机扫描吞吐并生成机器画像",
+	Long: `在本机启动开放、关闭和延迟应答的TCP/UDP监听端口，以不同并发数和速率限制运行各扫描引擎，
+报告吞吐量(端口/秒)、CPU、内存、文件描述符占用和准确率，并保存机器画像供扫描建议使用。
+需要root权限的引擎在无权限时跳过。
+例如：
+  go-port-rocket bench
+  go-port-rocket bench --engines tcp --workers 20,50,100,500
+  go-port-rocket bench --rates 0,500,2000 --no-save --format json -o bench.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if benchFormat != "text" && benchFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", benchFormat)
+		}
+
+		opts := scanner.DefaultBenchOptions()
+		opts.Engines = nil
+		for _, name := range strings.Split(benchEngines, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Engines = append(opts.Engines, scanner.ScanType(name))
+			}
+		}
+		var err error
+		if opts.Workers, err = parseIntList(benchWorkers); err != nil {
+			return fmt.Errorf("无效的并发数: %v", err)
+		}
+		if opts.RateLimits, err = parseIntList(benchRateLimits); err != nil {
+			return fmt.Errorf("无效的速率限制: %v", err)
+		}
+		opts.OpenPorts = benchOpenPorts
+		opts.ClosedPorts = benchClosedPorts
+		opts.DelayedPorts = benchDelayed
+		opts.Delay = benchDelay
+		opts.Timeout = benchTimeout
+
+		progress := os.Stdout
+		if benchFormat == "json" {
+			progress = os.Stderr
+		}
+		report, err := scanner.RunBenchmark(opts, progress)
+		if err != nil {
+			return fmt.Errorf("基准测试失败: %v", err)
+		}
+
+		if benchFormat == "json" || benchOutputFile != "" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("序列化结果失败: %v", err)
+			}
+			if benchOutputFile != "" {
+				if err := os.WriteFile(benchOutputFile, data, 0644); err != nil {
+					return fmt.Errorf("写入输出文件失败: %v", err)
+				}
+				fmt.Fprintf(progress, "测试结果已保存到: %s\n", benchOutputFile)
+			}
+			if benchFormat == "json" {
+				fmt.Println(string(data))
+			}
+		}
+		if benchFormat == "text" {
+			scanner.PrintBenchReport(report)
+		}
+
+		// 保存机器画像
+		if !benchNoSave && len(report.Results) > 0 {
+			if err := scanner.SaveMachineProfile(benchProfile, report.Profile()); err != nil {
+				return err
+			}
+			fmt.Fprintf(progress, "机器画像已保存到: %s\n", benchProfile)
+		}
+		return nil
+	},
+}
+
+// parseIntList 解析逗号分隔的整数列表
+func parseIntList(value string) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q 不是非负整数", item)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func init() {
+	defaults := scanner.DefaultBenchOptions()
+
+	// 添加命令行参数
+	benchCmd.Flags().StringVar(&benchEngines, "engines", "tcp,udp,syn", "测试的扫描引擎，逗号分隔："+scanner.ScanTypeUsage())
+	benchCmd.Flags().StringVar(&benchWorkers, "workers", "10,50,100,200", "测试的并发数，逗号分隔")
+	benchCmd.Flags().StringVar(&benchRateLimits, "rates", "0,1000", "测试的速率限制(每秒探测数)，逗号分隔，0表示不限速")
+	benchCmd.Flags().IntVar(&benchOpenPorts, "open", defaults.OpenPorts, "每种协议的开放端口数")
+	benchCmd.Flags().IntVar(&benchClosedPorts, "closed", defaults.ClosedPorts, "每种协议的关闭端口数")
+	benchCmd.Flags().IntVar(&benchDelayed, "delayed", defaults.DelayedPorts, "每种协议延迟应答的端口数")
+	benchCmd.Flags().DurationVar(&benchDelay, "delay", defaults.Delay, "延迟端口的应答延迟")
+	benchCmd.Flags().DurationVarP(&benchTimeout, "timeout", "T", defaults.Timeout, "单端口超时时间")
+	benchCmd.Flags().StringVar(&benchProfile, "profile", scanner.DefaultMachineProfilePath(), "机器画像保存路径")
+	benchCmd.Flags().BoolVar(&benchNoSave, "no-save", false, "不保存机器画像")
+	benchCmd.Flags().StringVar(&benchFormat, "format", "text", "输出格式 (text, json)")
+	benchCmd.Flags().StringVarP(&benchOutputFile, "output", "o", "", "将JSON结果保存到文件")
+
+	// 绑定到viper配置
+	viper.BindPFlag("bench.engines", benchCmd.Flags().Lookup("engines"))
+	viper.BindPFlag("bench.workers", benchCmd.Flags().Lookup("workers"))
+	viper.BindPFlag("bench.rates", benchCmd.Flags().Lookup("rates"))
+
+	// 添加到根命令
+	RootCmd.AddCommand(benchCmd)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/cyberspacesec/go-port-rocket/pkg/api"
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
 	"github.com/cyberspacesec/go-port-rocket/pkg/output"
 	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 	"github.com/spf13/cobra"
@@ -40,6 +41,10 @@ var (
 	queueSize     int
 	enableAuth    bool
 	allowInMemory bool
+	agentToken    string
+	leaseTimeout  time.Duration
+	apiHistoryDB  string
+	rulesReload   time.Duration
 
 	// MCP扫描和API参数本地变量
 	mcpConfigData string
@@ -124,6 +129,10 @@ func init() {
 	apiCmd.Flags().IntVar(&queueSize, "queue-size", 100, "任务队列大小")
 	apiCmd.Flags().BoolVar(&enableAuth, "enable-auth", true, "启用认证")
 	apiCmd.Flags().BoolVar(&allowInMemory, "allow-inmemory", false, "允许在Redis连接失败时降级使用内存存储")
+	apiCmd.Flags().StringVar(&agentToken, "agent-token", "", "分布式扫描代理的认证令牌，为空时不接受代理接入")
+	apiCmd.Flags().DurationVar(&leaseTimeout, "lease-timeout", 2*time.Minute, "工作单元租约时长，代理在此期间无心跳则重新分配")
+	apiCmd.Flags().StringVar(&apiHistoryDB, "history-db", history.DefaultPath(), "扫描历史库文件路径，为空时不记录扫描历史")
+	apiCmd.Flags().DurationVar(&rulesReload, "rules-reload", 10*time.Second, "检查用户服务规则文件变化的间隔，0表示不自动重新加载")
 
 	// 添加命令
 	RootCmd.AddCommand(apiCmd)
@@ -145,12 +154,8 @@ func runScan(cmd *cobra.Command, args []string) {
 		LimitOSScan:      limitOSScan,
 	}
 
-	// 创建扫描器
-	scanner, err := scanner.NewScanner(scanOpts)
-	if err != nil {
-		fmt.Printf("创建扫描器失败: %v\n", err)
-		os.Exit(1)
-	}
+	// 机器可读的输出格式不混入扫描建议
+	scanOpts.Quiet = outputFormat != "text"
 
 	// 创建输出选项
 	var writer = os.Stdout
@@ -182,7 +187,7 @@ func runScan(cmd *cobra.Command, args []string) {
 
 	// 执行扫描
 	fmt.Printf("开始扫描目标: %s\n", target)
-	results, err := scanner.Scan(cmd.Context())
+	host, err := scanner.ExecuteHostScan(scanOpts)
 	if err != nil {
 		fmt.Printf("扫描失败: %v\n", err)
 		os.Exit(1)
@@ -193,7 +198,7 @@ func runScan(cmd *cobra.Command, args []string) {
 	outputOpts.Duration = outputOpts.EndTime.Sub(outputOpts.StartTime)
 
 	// 写入结果
-	if err := outputHandler.Write(results); err != nil {
+	if err := outputHandler.WriteHosts([]*scanner.HostResult{host}); err != nil {
 		fmt.Printf("写入结果失败: %v\n", err)
 		os.Exit(1)
 	}
@@ -227,6 +232,12 @@ func runAPIServer(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("3. 查看任务状态:\n   curl -s http://%s:%d/api/v1/scan/tasks/{task_id}\n\n", apiHost, apiPort)
 	fmt.Printf("4. 查看系统状态:\n   curl -s http://%s:%d/api/v1/system/status\n\n", apiHost, apiPort)
+	if agentToken != "" {
+		fmt.Printf("5. 分布式扫描: 在各网络中运行代理后创建任务:\n   go-port-rocket agent --coordinator http://%s:%d --token YOUR_AGENT_TOKEN\n   curl -s -X POST -H \"Content-Type: application/json\" -d '{\"target\":\"10.0.0.0/24\",\"ports\":\"1-1024\"}' http://%s:%d/api/v1/jobs\n\n", apiHost, apiPort, apiHost, apiPort)
+	}
+	if apiHistoryDB != "" {
+		fmt.Printf("扫描历史: 完成的任务记录到 %s\n   curl -s \"http://%s:%d/api/v1/history?latest=true&state=open\"\n\n", apiHistoryDB, apiHost, apiPort)
+	}
 	fmt.Println("📚 完整API文档请访问: https://cyberspacesec.github.io/go-port-rocket/docs/http-api.html")
 	fmt.Println()
 	fmt.Println("按 Ctrl+C 停止服务")
@@ -244,6 +255,10 @@ func runAPIServer(cmd *cobra.Command, args []string) {
 		QueueSize:      queueSize,
 		EnableAuth:     enableAuth,
 		AllowInMemory:  allowInMemory,
+		AgentToken:     agentToken,
+		LeaseTimeout:   leaseTimeout,
+		HistoryPath:    apiHistoryDB,
+		RulesReload:    rulesReload,
 	}
 
 	// 创建API服务
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/output"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffFormat     string
+	diffOutputFile string
+	diffFailOn     string
+)
+
+// diffCmd 比较两个扫描结果文件
+var diffCmd = &cobra.Command{
+	Use:   "diff <旧结果> <新结果>",
+	Short: "比较两次扫描结果，报告主机、端口状态和服务版本的变化",
+	Long: `比较两个扫描结果文件，报告新增和消失的主机、端口状态变化、服务版本变化以及操作系统变化。
+支持scan命令输出的JSON/XML结果和API使用的扫描报告JSON/XML，格式根据文件内容自动识别。
+
+差异类型: ` + diffKindNames() + `
+
+退出码可直接用作CI检查：
+  0  没有--fail-on指定类型的差异
+  1  存在--fail-on指定类型的差异
+  2  读取或解析结果文件失败
+例如：
+  go-port-rocket diff yesterday.json today.json
+  go-port-rocket diff old.xml new.json --format html -o diff.html
+  go-port-rocket diff baseline.json current.json --fail-on port-opened,service-changed`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(runDiff(args[0], args[1]))
+	},
+}
+
+// runDiff 比较两个结果文件并输出，返回命令的退出码
+func runDiff(oldPath, newPath string) int {
+	failOn, err := output.ParseDiffKinds(diffFailOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 2
+	}
+	diff, err := output.DiffFiles(oldPath, newPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if diffOutputFile != "" {
+		file, err := os.Create(diffOutputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 创建输出文件失败: %v\n", err)
+			return 2
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := output.WriteDiff(w, diff, diffFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 2
+	}
+	if diffOutputFile != "" {
+		fmt.Printf("对比结果已保存到: %s (%d 项差异)\n", diffOutputFile, diff.Count())
+	}
+
+	if diff.Count(failOn...) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// diffKindNames 返回所有差异类型名称
+func diffKindNames() string {
+	names := make([]string, 0, len(output.DiffKinds))
+	for _, kind := range output.DiffKinds {
+		names = append(names, string(kind))
+	}
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "输出格式 (text, json, html)")
+	diffCmd.Flags().StringVarP(&diffOutputFile, "output", "o", "", "输出文件路径，默认输出到标准输出")
+	diffCmd.Flags().StringVar(&diffFailOn, "fail-on", "any", "存在这些类型的差异时以退出码1退出，逗号分隔，any表示任意差异")
+
+	RootCmd.AddCommand(diffCmd)
+}
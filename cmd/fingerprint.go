@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fingerprintImportDest string
+	fingerprintFormat     string
+	fingerprintPort       int
+	fingerprintProtocol   string
+	fingerprintProbe      string
+	fingerprintLearnName  string
+	fingerprintService    string
+	fingerprintProduct    string
+	fingerprintLearnOut   string
+)
+
+// fingerprintCmd 管理nmap指纹库
+var fingerprintCmd = &cobra.Command{
+	Use:   "fingerprint",
+	Short: "管理服务和操作系统指纹库",
+	Long: `导入、检查和查询服务识别与操作系统识别使用的nmap指纹库。
+导入的指纹库保存在 ~/.go-port-rocket/fingerprints，之后的扫描自动使用，
+--fingerprint-db指定的目录优先；都没有时使用内置的指纹数据。
+数据目录下rules子目录中的YAML/JSON文件定义用户服务规则，扫描时优先于nmap探测使用。
+例如：
+  go-port-rocket fingerprint import /usr/share/nmap     # 导入nmap自带的最新指纹库
+  go-port-rocket fingerprint validate ./my-probes        # 逐行检查指纹文件
+  go-port-rocket fingerprint stats                       # 当前指纹库的版本和条目数量
+  go-port-rocket fingerprint lookup 'SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n' --port 22
+  go-port-rocket fingerprint learn result.json --service acme-rpc  # 根据未识别服务指纹生成规则草稿`,
+}
+
+// fingerprintImportCmd 导入nmap指纹文件
+var fingerprintImportCmd = &cobra.Command{
+	Use:   "import <目录>",
+	Short: "从目录导入nmap-service-probes、nmap-os-db和nmap-services",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := fingerprint.ImportDB(args[0], fingerprintImportDest)
+		if err != nil {
+			return fmt.Errorf("导入指纹库失败: %v", err)
+		}
+		dest := fingerprintImportDest
+		if dest == "" {
+			dest = fingerprint.UserDataDir()
+		}
+		for _, e := range db.ParseErrors {
+			fmt.Fprintf(os.Stderr, "警告: %s\n", e.Error())
+		}
+		stats := db.Stats()
+		fmt.Printf("已导入指纹库 %s 到 %s\n", stats.Version, dest)
+		fmt.Printf("探测 %d 个，match规则 %d 条，softmatch规则 %d 条，操作系统指纹 %d 个，服务端口 %d 条，跳过 %d 行\n",
+			stats.Probes, stats.Matches, stats.SoftMatches, stats.OSFingerprints, stats.Services, stats.ParseErrors)
+		return nil
+	},
+}
+
+// fingerprintValidateCmd 检查指纹文件
+var fingerprintValidateCmd = &cobra.Command{
+	Use:   "validate [目录]",
+	Short: "检查指纹文件并逐行报告解析错误",
+	Long: `解析目录中的指纹文件和rules子目录中的用户服务规则，报告每个无法解析的行和规则；
+不指定目录时检查当前使用的指纹库和规则目录。存在错误时命令以非零状态退出。`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, rulesDir := fingerprint.ActiveDataDir(), fingerprint.RulesDir()
+		if len(args) > 0 {
+			dir = args[0]
+			rulesDir = filepath.Join(dir, "rules")
+		}
+		db, err := fingerprint.ValidateDB(dir)
+		if err != nil {
+			return err
+		}
+		rules, err := fingerprint.LoadRules(rulesDir)
+		if err != nil {
+			return err
+		}
+		for _, e := range db.ParseErrors {
+			fmt.Printf("%s:%d: %v\n", e.File, e.Line, e.Err)
+			fmt.Printf("\t%s\n", e.Text)
+		}
+		for _, e := range rules.Errors {
+			fmt.Printf("%s:%d: %v\n", filepath.Join(rulesDir, e.File), e.Line, e.Err)
+		}
+		if count := len(db.ParseErrors) + len(rules.Errors); count > 0 {
+			return fmt.Errorf("%s 中有 %d 处错误", fingerprintDirName(dir), count)
+		}
+		fmt.Printf("%s 检查通过，用户规则 %d 条\n", fingerprintDirName(dir), len(rules.Rules))
+		return nil
+	},
+}
+
+// fingerprintStatsCmd 统计当前指纹库
+var fingerprintStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "显示当前指纹库的版本和条目数量",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if fingerprintFormat != "text" && fingerprintFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", fingerprintFormat)
+		}
+		dir := fingerprint.ActiveDataDir()
+		db, err := fingerprint.ActiveDB()
+		if err != nil {
+			return err
+		}
+		rules, err := fingerprint.Rules()
+		if err != nil {
+			return err
+		}
+		stats := db.Stats()
+		if fingerprintFormat == "json" {
+			return printHistoryJSON(struct {
+				Dir      string `json:"dir"`
+				RulesDir string `json:"rules_dir"`
+				Rules    int    `json:"rules"`
+				nmap.Stats
+			}{fingerprintDirName(dir), rules.Dir, len(rules.Rules), stats})
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "目录\t%s\n", fingerprintDirName(dir))
+		fmt.Fprintf(w, "版本\t%s\n", stats.Version)
+		fmt.Fprintf(w, "探测\t%d\n", stats.Probes)
+		fmt.Fprintf(w, "match规则\t%d\n", stats.Matches)
+		fmt.Fprintf(w, "softmatch规则\t%d\n", stats.SoftMatches)
+		fmt.Fprintf(w, "操作系统指纹\t%d\n", stats.OSFingerprints)
+		fmt.Fprintf(w, "服务端口\t%d\n", stats.Services)
+		fmt.Fprintf(w, "解析错误\t%d\n", stats.ParseErrors)
+		fmt.Fprintf(w, "用户规则\t%d (%s)\n", len(rules.Rules), rules.Dir)
+		return w.Flush()
+	},
+}
+
+// fingerprintLookupCmd 用指纹库匹配横幅
+var fingerprintLookupCmd = &cobra.Command{
+	Use:   "lookup <横幅>",
+	Short: "用当前指纹库匹配一段服务横幅",
+	Long: `按扫描时的顺序用探测规则匹配横幅，显示命中的规则和识别结果。
+横幅支持\r、\n、\0、\xHH等转义；指定--probe时只使用该探测的规则。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if fingerprintFormat != "text" && fingerprintFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", fingerprintFormat)
+		}
+		banner, err := nmap.UnescapeString(args[0])
+		if err != nil {
+			return fmt.Errorf("无效的横幅: %v", err)
+		}
+		db, err := fingerprint.ActiveDB()
+		if err != nil {
+			return err
+		}
+
+		var match *nmap.ServiceMatch
+		if fingerprintProbe != "" {
+			if match, err = db.MatchProbeResponse(fingerprintProbe, banner); err != nil {
+				return err
+			}
+		} else {
+			match = db.MatchResponse(fingerprintProtocol, fingerprintPort, banner)
+		}
+		portService := db.ServiceName(fingerprintProtocol, fingerprintPort)
+
+		if fingerprintFormat == "json" {
+			return printHistoryJSON(struct {
+				Match       *nmap.ServiceMatch `json:"match"`
+				PortService string             `json:"port_service,omitempty"`
+				DBVersion   string             `json:"fingerprint_db"`
+			}{match, portService, db.Version})
+		}
+
+		if match == nil {
+			fmt.Println("没有匹配的规则")
+			if portService != "" {
+				fmt.Printf("端口 %d/%s 的常见服务: %s\n", fingerprintPort, strings.ToLower(fingerprintProtocol), portService)
+			}
+			return nil
+		}
+		kind := "match"
+		if match.Soft {
+			kind = "softmatch"
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "服务\t%s\n", match.Service)
+		fmt.Fprintf(w, "产品\t%s\n", orDash(match.Product))
+		fmt.Fprintf(w, "版本\t%s\n", orDash(match.Version))
+		fmt.Fprintf(w, "附加信息\t%s\n", orDash(match.Info))
+		fmt.Fprintf(w, "操作系统\t%s\n", orDash(match.OS))
+		fmt.Fprintf(w, "设备类型\t%s\n", orDash(match.DeviceType))
+		fmt.Fprintf(w, "CPE\t%s\n", orDash(strings.Join(match.CPE, " ")))
+		fmt.Fprintf(w, "规则\t%s 探测 %s 第%d行\n", kind, match.Probe, match.Line)
+		fmt.Fprintf(w, "指纹库\t%s\n", db.Version)
+		return w.Flush()
+	},
+}
+
+// fingerprintLearnCmd 根据未识别服务指纹生成用户规则草稿
+var fingerprintLearnCmd = &cobra.Command{
+	Use:   "learn <文件>",
+	Short: "根据未识别服务的指纹记录生成用户规则草稿",
+	Long: `从文件中读取扫描时输出的SF-Port指纹记录，为每条记录生成一条用户服务规则草稿。
+文件可以是单独保存的记录、文本格式的扫描输出或JSON格式的扫描结果。
+生成的规则已确认能匹配记录中的响应，修改服务名称和产品信息后放入规则目录即可生效。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("读取指纹记录失败: %v", err)
+		}
+		records, err := fingerprint.ExtractServiceRecords(data)
+		if err != nil {
+			return fmt.Errorf("解析指纹记录失败: %v", err)
+		}
+		if len(records) == 0 {
+			return fmt.Errorf("%s 中没有SF-Port指纹记录", args[0])
+		}
+		db, err := fingerprint.ActiveDB()
+		if err != nil {
+			return err
+		}
+
+		rules := make([]*fingerprint.ServiceRule, 0, len(records))
+		for i, record := range records {
+			rule, err := fingerprint.DraftRule(record, db)
+			if err != nil {
+				return fmt.Errorf("端口 %d/%s 的记录: %v", record.Port, record.Protocol, err)
+			}
+			if fingerprintLearnName != "" {
+				rule.Name = fingerprintLearnName
+				if len(records) > 1 {
+					rule.Name = fmt.Sprintf("%s-%d", fingerprintLearnName, i+1)
+				}
+			}
+			if fingerprintService != "" {
+				rule.Service = fingerprintService
+			}
+			rule.Product = fingerprintProduct
+			if err := fingerprint.VerifyRule(rule, record.Responses[0].Response); err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+
+		out, err := fingerprint.MarshalRules(rules)
+		if err != nil {
+			return fmt.Errorf("生成规则失败: %v", err)
+		}
+		if fingerprintLearnOut == "" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		if err := os.WriteFile(fingerprintLearnOut, out, 0644); err != nil {
+			return fmt.Errorf("写入规则文件失败: %v", err)
+		}
+		fmt.Printf("已生成 %d 条规则草稿: %s\n", len(rules), fingerprintLearnOut)
+		return nil
+	},
+}
+
+// fingerprintDirName 数据目录的显示名称
+func fingerprintDirName(dir string) string {
+	if dir == "" {
+		return "内置指纹库"
+	}
+	return dir
+}
+
+func init() {
+	fingerprintImportCmd.Flags().StringVar(&fingerprintImportDest, "dest", "", "导入的目标目录，默认为 ~/.go-port-rocket/fingerprints")
+	fingerprintStatsCmd.Flags().StringVar(&fingerprintFormat, "format", "text", "输出格式 (text, json)")
+	fingerprintLookupCmd.Flags().StringVar(&fingerprintFormat, "format", "text", "输出格式 (text, json)")
+	fingerprintLookupCmd.Flags().IntVar(&fingerprintPort, "port", 0, "横幅所在的端口，用于选择探测规则")
+	fingerprintLookupCmd.Flags().StringVar(&fingerprintProtocol, "protocol", "tcp", "横幅所在端口的协议 (tcp, udp)")
+	fingerprintLookupCmd.Flags().StringVar(&fingerprintProbe, "probe", "", "只使用该探测的规则，如GetRequest")
+	fingerprintLearnCmd.Flags().StringVar(&fingerprintLearnName, "name", "", "规则名称，默认为learned-<协议>-<端口>")
+	fingerprintLearnCmd.Flags().StringVar(&fingerprintService, "service", "", "服务名称，默认为unknown")
+	fingerprintLearnCmd.Flags().StringVar(&fingerprintProduct, "product", "", "产品名称")
+	fingerprintLearnCmd.Flags().StringVarP(&fingerprintLearnOut, "out", "o", "", "写入的规则文件，默认输出到标准输出")
+
+	fingerprintCmd.AddCommand(fingerprintImportCmd)
+	fingerprintCmd.AddCommand(fingerprintValidateCmd)
+	fingerprintCmd.AddCommand(fingerprintStatsCmd)
+	fingerprintCmd.AddCommand(fingerprintLookupCmd)
+	fingerprintCmd.AddCommand(fingerprintLearnCmd)
+
+	// 添加到根命令
+	RootCmd.AddCommand(fingerprintCmd)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	firewallTarget     string
+	firewallPorts      string
+	firewallUDPPorts   string
+	firewallProbes     string
+	firewallTimeout    time.Duration
+	firewallWorkers    int
+	firewallFormat     string
+	firewallOutputFile string
+)
+
+// firewallCmd 防火墙/IDS行为检测
+var firewallCmd = &cobra.Command{
+	Use:   "firewall",
+	Short: "检测防火墙与IDS/IPS行为",
+	Long: `使用TCP connect、SYN、ACK、FIN和UDP等多种探测扫描目标，比较各探测的应答、
+RST的TTL、ICMP管理禁止代码和限速特征，判断端口处于有状态过滤、无状态过滤还是IPS之后，
+每个结论都附带证据。原始套接字探测需要root权限，无权限时跳过。
+例如：
+  go-port-rocket firewall -t 192.168.1.1
+  go-port-rocket firewall -t 192.168.1.1 -p 22,80,443 --udp-ports 53,161
+  go-port-rocket firewall -t 192.168.1.1 --probes syn,ack,xmas --format json -o firewall.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if firewallFormat != "text" && firewallFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", firewallFormat)
+		}
+
+		var probes []scanner.ScanType
+		for _, name := range strings.Split(firewallProbes, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			probe := scanner.ScanType(name)
+			if err := scanner.ValidateScanType(probe); err != nil {
+				return err
+			}
+			probes = append(probes, probe)
+		}
+
+		report, err := scanner.DetectFirewall(&scanner.ScanOptions{
+			Target:  firewallTarget,
+			Ports:   firewallPorts,
+			Timeout: firewallTimeout,
+			Workers: firewallWorkers,
+		}, probes, firewallUDPPorts)
+		if err != nil {
+			return fmt.Errorf("防火墙检测失败: %v", err)
+		}
+
+		if firewallFormat == "json" || firewallOutputFile != "" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("序列化结果失败: %v", err)
+			}
+			if firewallOutputFile != "" {
+				if err := os.WriteFile(firewallOutputFile, data, 0644); err != nil {
+					return fmt.Errorf("写入输出文件失败: %v", err)
+				}
+				fmt.Printf("检测结果已保存到: %s\n", firewallOutputFile)
+			}
+			if firewallFormat == "json" {
+				fmt.Println(string(data))
+				return nil
+			}
+		}
+
+		scanner.PrintFirewallReport(report)
+		return nil
+	},
+}
+
+func init() {
+	// 添加命令行参数
+	firewallCmd.Flags().StringVarP(&firewallTarget, "target", "t", "", "目标IP地址或域名")
+	firewallCmd.Flags().StringVarP(&firewallPorts, "ports", "p", "21-25,53,80,110,135,139,443,445,3306,3389,8080", "TCP探测的端口范围")
+	firewallCmd.Flags().StringVar(&firewallUDPPorts, "udp-ports", "53,123,161", "UDP探测的端口范围")
+	firewallCmd.Flags().StringVar(&firewallProbes, "probes", "tcp,syn,ack,fin,udp", "使用的探测类型，逗号分隔："+scanner.ScanTypeUsage())
+	firewallCmd.Flags().DurationVarP(&firewallTimeout, "timeout", "T", 3*time.Second, "单次探测超时时间")
+	firewallCmd.Flags().IntVarP(&firewallWorkers, "workers", "w", 10, "并发工作线程数")
+	firewallCmd.Flags().StringVar(&firewallFormat, "format", "text", "输出格式 (text, json)")
+	firewallCmd.Flags().StringVarP(&firewallOutputFile, "output", "o", "", "将JSON结果保存到文件")
+
+	// 绑定到viper配置
+	viper.BindPFlag("firewall.ports", firewallCmd.Flags().Lookup("ports"))
+	viper.BindPFlag("firewall.udp_ports", firewallCmd.Flags().Lookup("udp-ports"))
+	viper.BindPFlag("firewall.probes", firewallCmd.Flags().Lookup("probes"))
+
+	// 设置必填参数
+	firewallCmd.MarkFlagRequired("target")
+
+	// 添加到根命令
+	RootCmd.AddCommand(firewallCmd)
+}
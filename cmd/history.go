@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	historyDB      string
+	historyHost    string
+	historyPort    int
+	historyService string
+	historyState   string
+	historySince   string
+	historyUntil   string
+	historyLatest  bool
+	historyScans   bool
+	historyLimit   int
+	historyFormat  string
+	historyPrune   string
+)
+
+// historyCmd 查询本地扫描历史
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "查询本地扫描历史和资产清单",
+	Long: `查询scan、monitor命令和API服务器记录的扫描历史。
+每次扫描的主机、端口、服务和操作系统结果都保存在本地历史库中，不需要数据库等外部服务。
+--since和--until支持RFC3339时间、2006-01-02日期以及24h、7d等相对时长。
+例如：
+  go-port-rocket history --latest                      # 当前资产清单：每个主机端口的最新状态
+  go-port-rocket history --host 192.168.1.0/24 --port 22
+  go-port-rocket history --service nginx --since 7d --format json
+  go-port-rocket history --scans --limit 20            # 最近的扫描记录
+  go-port-rocket history --prune 90d                   # 删除90天前的记录`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if historyFormat != "text" && historyFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", historyFormat)
+		}
+		store, err := history.Open(historyDB)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if historyPrune != "" {
+			before, err := history.ParseTime(historyPrune, now)
+			if err != nil {
+				return err
+			}
+			removed, err := store.Prune(before)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("已删除 %s 之前的 %d 条扫描记录\n", before.Format("2006-01-02 15:04:05"), removed)
+			return nil
+		}
+
+		query := history.Query{
+			Host:    historyHost,
+			Port:    historyPort,
+			Service: historyService,
+			State:   scanner.PortState(historyState),
+			Latest:  historyLatest,
+			Limit:   historyLimit,
+		}
+		if historyState == "all" {
+			query.State = ""
+		}
+		if historySince != "" {
+			if query.Since, err = history.ParseTime(historySince, now); err != nil {
+				return err
+			}
+		}
+		if historyUntil != "" {
+			if query.Until, err = history.ParseTime(historyUntil, now); err != nil {
+				return err
+			}
+		}
+
+		if historyScans {
+			scans, err := store.Scans(query)
+			if err != nil {
+				return err
+			}
+			if historyFormat == "json" {
+				return printHistoryJSON(scans)
+			}
+			printHistoryScans(scans)
+			return nil
+		}
+
+		observations, err := store.Query(query)
+		if err != nil {
+			return err
+		}
+		if historyFormat == "json" {
+			return printHistoryJSON(observations)
+		}
+		printHistoryObservations(observations)
+		return nil
+	},
+}
+
+// printHistoryJSON 以JSON格式输出查询结果
+func printHistoryJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printHistoryObservations 以表格输出端口观察记录
+func printHistoryObservations(observations []history.Observation) {
+	if len(observations) == 0 {
+		fmt.Println("没有匹配的记录")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "时间\t主机\t端口\t状态\t服务\t版本\t操作系统")
+	for _, obs := range observations {
+		host := obs.Host
+		if len(obs.Hostnames) > 0 && obs.Hostnames[0] != obs.Host {
+			host = fmt.Sprintf("%s (%s)", obs.Host, obs.Hostnames[0])
+		}
+		version := strings.TrimSpace(obs.Product + " " + obs.Version)
+		fmt.Fprintf(w, "%s\t%s\t%d/%s\t%s\t%s\t%s\t%s\n",
+			obs.Time.Local().Format("2006-01-02 15:04"), host, obs.Port, obs.Protocol, obs.State,
+			orDash(obs.Service), orDash(version), orDash(obs.OS))
+	}
+	w.Flush()
+	fmt.Printf("\n共 %d 条记录\n", len(observations))
+}
+
+// printHistoryScans 以表格输出扫描记录
+func printHistoryScans(scans []*history.Scan) {
+	if len(scans) == 0 {
+		fmt.Println("没有匹配的扫描记录")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "时间\t来源\t目标\t类型\t主机数\t开放端口\tID")
+	for _, scan := range scans {
+		open := 0
+		for _, host := range scan.Hosts {
+			open += host.CountPorts(scanner.PortStateOpen)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			scan.End.Local().Format("2006-01-02 15:04"), scan.Source, scan.Target, scan.ScanType, len(scan.Hosts), open, scan.ID)
+	}
+	w.Flush()
+}
+
+// orDash 空值显示为"-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// recordHistory 将扫描结果写入本地历史库，写入失败只提示不中断
+func recordHistory(path string, scan *history.Scan) {
+	if path == "" || len(scan.Hosts) == 0 {
+		return
+	}
+	store, err := history.Open(path)
+	if err == nil {
+		err = store.Record(scan)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "记录扫描历史失败: %v\n", err)
+	}
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyDB, "history-db", history.DefaultPath(), "扫描历史库文件路径")
+	historyCmd.Flags().StringVar(&historyHost, "host", "", "按主机过滤，支持IP、主机名和CIDR")
+	historyCmd.Flags().IntVar(&historyPort, "port", 0, "按端口过滤")
+	historyCmd.Flags().StringVar(&historyService, "service", "", "按服务或产品名称过滤(子串匹配)")
+	historyCmd.Flags().StringVar(&historyState, "state", "open", "按端口状态过滤 (open, closed, filtered, all)")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "起始时间，如2024-03-01或7d")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "结束时间，如2024-03-31或24h")
+	historyCmd.Flags().BoolVar(&historyLatest, "latest", false, "每个主机端口只显示最近一次扫描的结果")
+	historyCmd.Flags().BoolVar(&historyScans, "scans", false, "列出扫描记录而不是端口")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 0, "最多显示的条数，取最新的记录 (0表示不限制)")
+	historyCmd.Flags().StringVar(&historyFormat, "format", "text", "输出格式 (text, json)")
+	historyCmd.Flags().StringVar(&historyPrune, "prune", "", "删除该时间之前的记录，如90d或2024-01-01")
+
+	// 绑定到viper配置
+	viper.BindPFlag("history.db", historyCmd.Flags().Lookup("history-db"))
+
+	// 添加到根命令
+	RootCmd.AddCommand(historyCmd)
+}
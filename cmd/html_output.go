@@ -2,15 +2,21 @@ package cmd
 
 import (
 	"fmt"
+	"html"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
 	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 )
 
-// ConvertScannerResultToOutput 将scanner包的扫描结果转换为HTML
-func ConvertScannerResultToOutput(results []scanner.ScanResult, outputFile string, target string, scanType string, startTime, endTime time.Time) error {
+// ConvertScannerResultToOutput 将scanner包的主机结果转换为HTML
+func ConvertScannerResultToOutput(host *scanner.HostResult, outputFile string, scanType string) error {
+	results := host.ScanResults()
+	target := host.Name()
+	startTime, endTime := host.Timing.Start, host.Timing.End
+
 	// 创建HTML输出文件
 	file, err := os.Create(outputFile)
 	if err != nil {
@@ -1012,6 +1018,16 @@ func ConvertScannerResultToOutput(results []scanner.ScanResult, outputFile strin
                     </td>
                 </tr>`)
 		}
+
+		// 如果有TLS检查结果，添加TLS行
+		if result.Service != nil && result.Service.TLS != nil {
+			htmlTemplate += fmt.Sprintf(`
+                <tr class="port-row banner-row" data-state="%s" data-port="%d">
+                    <td colspan="5">
+                        <div class="banner">%s</div>
+                    </td>
+                </tr>`, stateAttr, result.Port, tlsInfoHTML(result.Service.TLS))
+		}
 	}
 
 	// 添加页脚
@@ -2157,3 +2173,38 @@ func isHexString(s string) bool {
 	}
 	return true
 }
+
+// tlsInfoHTML 将TLS检查结果格式化为转义后的多行文本
+func tlsInfoHTML(info *fingerprint.TLSInfo) string {
+	var b strings.Builder
+	for _, version := range info.Versions {
+		fmt.Fprintf(&b, "%s: %s\n", version.Name, strings.Join(version.Ciphers, ", "))
+	}
+	for i, cert := range info.Certificates {
+		label := "证书"
+		if i > 0 {
+			label = "证书链"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", label, cert.Subject)
+		if len(cert.SANs) > 0 {
+			fmt.Fprintf(&b, "  SAN: %s\n", strings.Join(cert.SANs, ", "))
+		}
+		fmt.Fprintf(&b, "  颁发者: %s\n", cert.Issuer)
+		fmt.Fprintf(&b, "  密钥: %s %d  签名: %s\n", cert.KeyType, cert.KeyBits, cert.SignatureAlgorithm)
+		fmt.Fprintf(&b, "  有效期: %s 至 %s", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		if cert.Expired {
+			b.WriteString(" (已过期)")
+		}
+		b.WriteString("\n")
+	}
+	if len(info.Certificates) > 0 {
+		if info.Trusted {
+			b.WriteString("证书验证: 受信任\n")
+		} else {
+			fmt.Fprintf(&b, "证书验证: %s\n", info.VerifyError)
+		}
+	}
+	fmt.Fprintf(&b, "JARM: %s", info.JARM)
+	// 证书字段来自远端，转义后再插入报告
+	return "<strong>TLS 信息</strong>\n" + html.EscapeString(b.String())
+}
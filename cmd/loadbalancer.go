@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	lbTarget     string
+	lbPort       int
+	lbSamples    int
+	lbTLS        bool
+	lbTimeout    time.Duration
+	lbFormat     string
+	lbOutputFile string
+)
+
+// loadBalancerCmd 负载均衡检测
+var loadBalancerCmd = &cobra.Command{
+	Use:   "loadbalancer",
+	Short: "检测Web目标是否位于负载均衡之后",
+	Long: `向同一端口重复发送HTTP请求与SYN探测，比较Server/Date头、TLS证书、TTL和IP ID序列，
+判断目标是否位于负载均衡之后并估计后端数量。SYN探测需要root权限，无权限时只比较HTTP特征。
+端口为443时默认使用TLS。
+例如：
+  go-port-rocket loadbalancer -t www.example.com
+  go-port-rocket loadbalancer -t www.example.com -p 443 -n 20
+  go-port-rocket loadbalancer -t 192.168.1.10 -p 8443 --tls --format json -o lb.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if lbFormat != "text" && lbFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", lbFormat)
+		}
+		useTLS := lbTLS || (lbPort == 443 && !cmd.Flags().Changed("tls"))
+
+		report, err := scanner.DetectLoadBalancer(&scanner.ScanOptions{
+			Target:  lbTarget,
+			Timeout: lbTimeout,
+		}, lbPort, lbSamples, useTLS)
+		if err != nil {
+			return fmt.Errorf("负载均衡检测失败: %v", err)
+		}
+
+		if lbFormat == "json" || lbOutputFile != "" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("序列化结果失败: %v", err)
+			}
+			if lbOutputFile != "" {
+				if err := os.WriteFile(lbOutputFile, data, 0644); err != nil {
+					return fmt.Errorf("写入输出文件失败: %v", err)
+				}
+				fmt.Printf("检测结果已保存到: %s\n", lbOutputFile)
+			}
+			if lbFormat == "json" {
+				fmt.Println(string(data))
+				return nil
+			}
+		}
+
+		scanner.PrintLoadBalancerReport(report)
+		return nil
+	},
+}
+
+func init() {
+	// 添加命令行参数
+	loadBalancerCmd.Flags().StringVarP(&lbTarget, "target", "t", "", "目标IP地址或域名")
+	loadBalancerCmd.Flags().IntVarP(&lbPort, "port", "p", 80, "探测的Web端口")
+	loadBalancerCmd.Flags().IntVarP(&lbSamples, "samples", "n", scanner.DefaultLoadBalancerSamples, "重复探测次数")
+	loadBalancerCmd.Flags().BoolVar(&lbTLS, "tls", false, "使用TLS连接并比较证书 (端口443默认启用)")
+	loadBalancerCmd.Flags().DurationVarP(&lbTimeout, "timeout", "T", 3*time.Second, "单次探测超时时间")
+	loadBalancerCmd.Flags().StringVar(&lbFormat, "format", "text", "输出格式 (text, json)")
+	loadBalancerCmd.Flags().StringVarP(&lbOutputFile, "output", "o", "", "将JSON结果保存到文件")
+
+	// 绑定到viper配置
+	viper.BindPFlag("loadbalancer.samples", loadBalancerCmd.Flags().Lookup("samples"))
+
+	// 设置必填参数
+	loadBalancerCmd.MarkFlagRequired("target")
+
+	// 添加到根命令
+	RootCmd.AddCommand(loadBalancerCmd)
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	monitorTarget        string
+	monitorPorts         string
+	monitorScanType      string
+	monitorTimeout       time.Duration
+	monitorWorkers       int
+	monitorService       bool
+	monitorOS            bool
+	monitorInterval      time.Duration
+	monitorCount         int
+	monitorState         string
+	monitorWebhook       string
+	monitorFormat        string
+	monitorResetBaseline bool
+	monitorHistoryDB     string
+	monitorNoHistory     bool
+)
+
+// monitorCmd 持续监控命令
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "持续监控目标，报告端口、服务和操作系统的变化",
+	Long: `按固定间隔重复扫描目标，每次结果与上一次的基线比较，只报告有意义的变化：
+新开放的端口(port-opened)、关闭的端口(port-closed)、服务版本或TLS证书变化(service-changed)
+以及操作系统变化(os-changed)。基线保存在状态文件中，重启后继续比较。
+设置--webhook后仅在发现变化时发送通知。
+例如：
+  go-port-rocket monitor -t 192.168.1.10 -p 1-1024 --interval 1h
+  go-port-rocket monitor -t 192.168.1.0/28 -p 22,80,443 --service-detection --webhook https://hooks.example.com/scan
+  go-port-rocket monitor -t example.com -p 443 --count 1 --format json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if monitorTarget == "" || monitorPorts == "" {
+			return fmt.Errorf("必须指定目标和端口")
+		}
+		if monitorFormat != "text" && monitorFormat != "json" {
+			return fmt.Errorf("不支持的输出格式: %s", monitorFormat)
+		}
+		if err := scanner.ValidateScanType(scanner.ScanType(monitorScanType)); err != nil {
+			return err
+		}
+		if monitorInterval <= 0 {
+			return fmt.Errorf("监控间隔必须大于0")
+		}
+		targets, err := scanner.ExpandTargets(monitorTarget)
+		if err != nil {
+			return err
+		}
+
+		monitor := scanner.NewMonitor()
+		if !monitorResetBaseline {
+			if monitor, err = scanner.LoadMonitor(monitorState); err != nil {
+				return err
+			}
+		}
+		var notifier *scanner.WebhookNotifier
+		if monitorWebhook != "" {
+			notifier = scanner.NewWebhookNotifier(monitorWebhook)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigChan
+			cancel()
+		}()
+
+		for round := 1; ; round++ {
+			var changes []scanner.ChangeEvent
+			for _, target := range targets {
+				changes = append(changes, monitorTargetOnce(monitor, target)...)
+			}
+			if err := scanner.SaveMonitor(monitorState, monitor); err != nil {
+				return err
+			}
+			if err := printMonitorChanges(round, changes); err != nil {
+				return err
+			}
+			if notifier != nil && len(changes) > 0 {
+				if err := notifier.Notify(ctx, &scanner.ChangeNotification{
+					Source:  "monitor",
+					Target:  monitorTarget,
+					Time:    time.Now(),
+					Changes: changes,
+				}); err != nil {
+					fmt.Fprintf(os.Stderr, "发送变化通知失败: %v\n", err)
+				}
+			}
+
+			if monitorCount > 0 && round >= monitorCount {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(monitorInterval):
+			}
+		}
+	},
+}
+
+// monitorTargetOnce 扫描单个目标并与基线比较
+func monitorTargetOnce(monitor *scanner.Monitor, target string) []scanner.ChangeEvent {
+	host, err := scanner.ExecuteHostScan(&scanner.ScanOptions{
+		Target:        target,
+		Ports:         monitorPorts,
+		ScanType:      scanner.ScanType(monitorScanType),
+		Timeout:       monitorTimeout,
+		Workers:       monitorWorkers,
+		EnableService: monitorService,
+		EnableOS:      monitorOS,
+		Quiet:         true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "扫描 %s 失败: %v\n", target, err)
+		return nil
+	}
+	// 被放弃的主机结果不完整，不用于比较
+	if host.GiveUp != nil {
+		fmt.Fprintf(os.Stderr, "主机 %s 被放弃，跳过比较\n", target)
+		return nil
+	}
+
+	if !monitorNoHistory {
+		recordHistory(monitorHistoryDB, &history.Scan{
+			Source:   history.SourceMonitor,
+			Target:   target,
+			Ports:    monitorPorts,
+			ScanType: monitorScanType,
+			Start:    host.Timing.Start,
+			End:      host.Timing.End,
+			Hosts:    []*scanner.HostResult{host},
+		})
+	}
+
+	key := target + "/" + monitorScanType
+	if _, ok := monitor.Baseline(key); !ok {
+		fmt.Fprintf(os.Stderr, "已为 %s 建立基线 (开放端口 %d 个)\n", target, host.CountPorts(scanner.PortStateOpen))
+	}
+	return monitor.Observe(key, host)
+}
+
+// printMonitorChanges 输出一轮监控发现的变化
+func printMonitorChanges(round int, changes []scanner.ChangeEvent) error {
+	if monitorFormat == "json" {
+		if changes == nil {
+			changes = []scanner.ChangeEvent{}
+		}
+		data, err := json.Marshal(map[string]interface{}{
+			"round":   round,
+			"time":    time.Now(),
+			"changes": changes,
+		})
+		if err != nil {
+			return fmt.Errorf("序列化变化失败: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	if len(changes) == 0 {
+		fmt.Printf("[%s] 第 %d 轮: 未发现变化\n", timestamp, round)
+		return nil
+	}
+	fmt.Printf("[%s] 第 %d 轮: 发现 %d 项变化\n", timestamp, round, len(changes))
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+	}
+	return nil
+}
+
+func init() {
+	// 添加命令行参数
+	monitorCmd.Flags().StringVarP(&monitorTarget, "target", "t", "", "监控目标，支持IP、域名、CIDR和末段范围")
+	monitorCmd.Flags().StringVarP(&monitorPorts, "ports", "p", "", "端口范围，例如：22,80,443,8000-8100")
+	monitorCmd.Flags().StringVarP(&monitorScanType, "scan", "s", "tcp", "扫描类型："+scanner.ScanTypeUsage())
+	monitorCmd.Flags().DurationVarP(&monitorTimeout, "timeout", "T", 2*time.Second, "超时时间")
+	monitorCmd.Flags().IntVarP(&monitorWorkers, "workers", "w", 100, "并发工作线程数")
+	monitorCmd.Flags().BoolVar(&monitorService, "service-detection", false, "启用服务检测，用于发现服务版本变化")
+	monitorCmd.Flags().BoolVarP(&monitorOS, "os-detection", "O", false, "启用操作系统检测")
+	monitorCmd.Flags().DurationVar(&monitorInterval, "interval", time.Hour, "两次扫描的间隔")
+	monitorCmd.Flags().IntVar(&monitorCount, "count", 0, "扫描轮数，0表示持续运行")
+	monitorCmd.Flags().StringVar(&monitorState, "state", scanner.DefaultMonitorStatePath(), "基线状态文件路径")
+	monitorCmd.Flags().StringVar(&monitorWebhook, "webhook", "", "发现变化时通知的Webhook地址")
+	monitorCmd.Flags().StringVar(&monitorFormat, "format", "text", "输出格式 (text, json)")
+	monitorCmd.Flags().BoolVar(&monitorResetBaseline, "reset-baseline", false, "忽略已保存的基线，以本次扫描重新建立")
+	monitorCmd.Flags().StringVar(&monitorHistoryDB, "history-db", history.DefaultPath(), "扫描历史库文件路径")
+	monitorCmd.Flags().BoolVar(&monitorNoHistory, "no-history", false, "不将扫描结果记录到扫描历史")
+
+	// 绑定到viper配置
+	viper.BindPFlag("monitor.interval", monitorCmd.Flags().Lookup("interval"))
+	viper.BindPFlag("monitor.state", monitorCmd.Flags().Lookup("state"))
+	viper.BindPFlag("monitor.webhook", monitorCmd.Flags().Lookup("webhook"))
+
+	// 添加到根命令
+	RootCmd.AddCommand(monitorCmd)
+}
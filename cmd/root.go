@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // 版本号，在构建时通过ldflags注入
@@ -76,4 +78,14 @@ func init() {
 
 	// 添加全局标志
 	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "输出详细信息")
+	RootCmd.PersistentFlags().String("fingerprint-db", "", "nmap指纹数据目录(包含nmap-service-probes和nmap-os-db)，默认使用fingerprint import导入的指纹库或内置数据")
+	viper.BindPFlag("fingerprint.data_dir", RootCmd.PersistentFlags().Lookup("fingerprint-db"))
+	RootCmd.PersistentFlags().String("fingerprint-rules", "", "用户服务规则目录(YAML/JSON)，默认为指纹数据目录下的rules")
+	viper.BindPFlag("fingerprint.rules_dir", RootCmd.PersistentFlags().Lookup("fingerprint-rules"))
+
+	// 命令执行前应用指纹数据目录和规则目录，配置文件中的fingerprint.data_dir和fingerprint.rules_dir同样生效
+	cobra.OnInitialize(func() {
+		fingerprint.SetDataDir(viper.GetString("fingerprint.data_dir"))
+		fingerprint.SetRulesDir(viper.GetString("fingerprint.rules_dir"))
+	})
 }
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
 	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 
 	"github.com/spf13/cobra"
@@ -23,9 +26,19 @@ var (
 	scanServiceProbe     bool
 	scanBannerProbe      bool
 	scanVersionIntensity int
+	scanTLSInspect       bool
 	scanEnableOS         bool
 	scanGuessOS          bool
 	scanLimitOSScan      bool
+	scanHostTimeout      time.Duration
+	scanMaxRetries       int
+	scanDetectTarpit     bool
+	scanPcapFile         string
+	scanPacketTrace      bool
+	scanDryRun           bool
+	scanHoneypotLimit    int
+	scanHistoryDB        string
+	scanNoHistory        bool
 )
 
 func init() {
@@ -37,7 +50,8 @@ func init() {
 例如：
   go-port-rocket scan -t 192.168.1.1 -p 1-1000 -s tcp
   go-port-rocket scan -t example.com -p 80,443,8080-8090 -s syn
-  go-port-rocket scan -t example.com -p 53,161,162 -s udp`,
+  go-port-rocket scan -t example.com -p 53,161,162 -s udp
+  go-port-rocket scan -t 10.0.0.0/24 -p 1-65535 -s syn --dry-run`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// 验证必要参数
 			if scanTarget == "" {
@@ -54,6 +68,7 @@ func init() {
 					EnableVersionDetection: scanEnableService,
 					VersionIntensity:       scanVersionIntensity,
 					BannerGrab:             scanBannerProbe,
+					TLSInspection:          scanTLSInspect,
 					Timeout:                scanTimeout,
 					EnableOSDetection:      scanEnableOS,
 				}
@@ -75,50 +90,82 @@ func init() {
 				EnableOS:         scanEnableOS,
 				GuessOS:          scanGuessOS,
 				LimitOSScan:      scanLimitOSScan,
+				HostTimeout:      scanHostTimeout,
+				MaxRetries:       scanMaxRetries,
+				DetectTarpit:     scanDetectTarpit,
 			}
 
-			// 记录开始时间
-			startTime := time.Now()
+			// 只输出扫描计划，不访问网络
+			if scanDryRun {
+				plan, err := scanner.PlanScan(opts)
+				if err != nil {
+					return fmt.Errorf("生成扫描计划失败: %v", err)
+				}
+				plan.Print(os.Stdout)
+				return nil
+			}
+
+			// 详细模式下在控制台显示扫描进度
+			if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+				opts.Verbose = true
+				opts.Observers = append(opts.Observers, scanner.NewProgressObserver())
+			}
+
+			// 记录扫描收发的报文
+			if scanPcapFile != "" || scanPacketTrace {
+				var trace io.Writer
+				if scanPacketTrace {
+					trace = os.Stdout
+				}
+				recorder, err := scanner.CreatePacketRecorder(scanPcapFile, trace)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if err := recorder.Close(); err != nil {
+						fmt.Fprintf(os.Stderr, "写入pcap文件失败: %v\n", err)
+					} else if scanPcapFile != "" {
+						fmt.Printf("已记录 %d 个数据包到 %s\n", recorder.Count(), scanPcapFile)
+					}
+				}()
+				opts.Capture = recorder
+			}
 
 			// 执行扫描
-			results, err := scanner.ExecuteScan(opts)
+			host, err := scanner.ExecuteHostScan(opts)
 			if err != nil {
 				return fmt.Errorf("扫描失败: %v", err)
 			}
 
-			// 记录结束时间
-			endTime := time.Now()
+			// 疑似蜜罐的主机不输出端口结果，避免污染资产清单
+			if score := host.Honeypot; score.Exceeds(scanHoneypotLimit) {
+				fmt.Printf("目标 %s 疑似蜜罐 (评分 %s)，已按 --honeypot-threshold=%d 排除结果\n", scanTarget, score, scanHoneypotLimit)
+				for _, reason := range score.Reasons {
+					fmt.Printf("   - %s\n", reason)
+				}
+				return nil
+			}
 
 			// 打印结果到控制台
-			scanner.PrintResults(results)
+			scanner.PrintHostResult(host)
+
+			// 记录到本地扫描历史，被放弃的主机结果不完整，不计入资产清单
+			if !scanNoHistory && host.GiveUp == nil {
+				recordHistory(scanHistoryDB, &history.Scan{
+					Source:   history.SourceCLI,
+					Target:   scanTarget,
+					Ports:    scanPorts,
+					ScanType: scanTypeOption,
+					Start:    host.Timing.Start,
+					End:      host.Timing.End,
+					Hosts:    []*scanner.HostResult{host},
+				})
+			}
 
 			// 如果指定了输出文件，则保存结果到文件
 			if scanOutputFile != "" {
-				// 将扫描结果转换为适合输出的格式
-				var udpResults []scanner.UDPScanResult // 由于我们没有UDP扫描结果，创建一个空切片
-				var serviceInfo map[int]*scanner.ServiceInfo = make(map[int]*scanner.ServiceInfo)
-				var hostStatus []scanner.HostStatus // 没有主机状态，创建一个空切片
-
-				// 提取服务信息
-				for _, result := range results {
-					if result.State == scanner.PortStateOpen && result.ServiceName != "" {
-						serviceInfo[result.Port] = &scanner.ServiceInfo{
-							Name: result.ServiceName,
-							Port: result.Port,
-						}
-					}
-				}
-
 				// 创建输出数据
-				output := scanner.CreateScanOutputFromResults(
-					scanTarget,
-					results,
-					udpResults,
-					serviceInfo,
-					hostStatus,
-					startTime,
-					endTime,
-				)
+				output := scanner.NewPortScanOutput(host)
 
 				// 确定输出格式（基于文件扩展名或默认为JSON）
 				format := scanner.OutputFormatJSON
@@ -142,7 +189,7 @@ func init() {
 					fmt.Printf("正在保存扫描结果到HTML文件: %s\n", scanOutputFile)
 
 					// 使用ConvertScannerResultToOutput函数生成HTML
-					if err := ConvertScannerResultToOutput(results, scanOutputFile, scanTarget, string(scanTypeOption), startTime, endTime); err != nil {
+					if err := ConvertScannerResultToOutput(host, scanOutputFile, string(scanTypeOption)); err != nil {
 						fmt.Printf("保存扫描结果到HTML文件 %s 失败: %v\n", scanOutputFile, err)
 					} else {
 						fmt.Printf("扫描结果已保存到: %s\n", scanOutputFile)
@@ -172,7 +219,7 @@ func init() {
 	// 添加命令行参数
 	scanCmd.Flags().StringVarP(&scanTarget, "target", "t", "", "目标IP地址或域名")
 	scanCmd.Flags().StringVarP(&scanPorts, "ports", "p", "", "端口范围，例如：80,443,8080-8090")
-	scanCmd.Flags().StringVarP(&scanTypeOption, "scan", "s", "tcp", "扫描类型：tcp, syn, fin, null, xmas, ack, udp")
+	scanCmd.Flags().StringVarP(&scanTypeOption, "scan", "s", "tcp", "扫描类型："+scanner.ScanTypeUsage())
 	scanCmd.Flags().DurationVarP(&scanTimeout, "timeout", "T", 2*time.Second, "超时时间")
 	scanCmd.Flags().IntVarP(&scanWorkers, "workers", "w", 100, "并发工作线程数")
 	scanCmd.Flags().StringVarP(&scanOutputFile, "output", "o", "", "输出文件路径")
@@ -182,12 +229,30 @@ func init() {
 	scanCmd.Flags().BoolVar(&scanServiceProbe, "service-probe", false, "启用服务探测")
 	scanCmd.Flags().BoolVar(&scanBannerProbe, "banner-grab", false, "获取服务banner")
 	scanCmd.Flags().IntVar(&scanVersionIntensity, "version-intensity", 7, "版本检测强度 (0-9)")
+	scanCmd.Flags().BoolVar(&scanTLSInspect, "tls-inspect", true, "对识别为ssl或未识别的端口检查证书、协议版本、密码套件和JARM指纹")
 
 	// 添加操作系统检测相关参数
 	scanCmd.Flags().BoolVarP(&scanEnableOS, "os-detection", "O", false, "启用操作系统检测")
 	scanCmd.Flags().BoolVar(&scanGuessOS, "guess-os", false, "根据TTL猜测操作系统")
 	scanCmd.Flags().BoolVar(&scanLimitOSScan, "limit-os-scan", false, "限制对开放端口的主机进行OS扫描")
 
+	// 添加主机放弃策略相关参数
+	scanCmd.Flags().DurationVar(&scanHostTimeout, "host-timeout", 0, "单主机扫描总超时，超过后放弃该主机 (0表示不限制)")
+	scanCmd.Flags().IntVar(&scanMaxRetries, "max-retries", 0, "端口超时后的最大重试次数")
+	scanCmd.Flags().BoolVar(&scanDetectTarpit, "detect-tarpit", false, "检测所有端口均开放的tarpit主机并放弃扫描，可能误判开放端口较多的正常主机")
+	scanCmd.Flags().IntVar(&scanHoneypotLimit, "honeypot-threshold", 0, "蜜罐评分达到该值时不输出结果 (0表示不过滤，建议50)")
+
+	// 添加报文记录相关参数
+	scanCmd.Flags().StringVar(&scanPcapFile, "pcap", "", "将扫描收发的报文写入pcapng文件")
+	scanCmd.Flags().BoolVar(&scanPacketTrace, "packet-trace", false, "在控制台实时打印扫描收发的报文")
+
+	// 添加扫描计划参数
+	scanCmd.Flags().BoolVar(&scanDryRun, "dry-run", false, "只显示扫描计划(探测数、预估耗时、所需权限)，不发送报文")
+
+	// 添加扫描历史相关参数
+	scanCmd.Flags().StringVar(&scanHistoryDB, "history-db", history.DefaultPath(), "扫描历史库文件路径")
+	scanCmd.Flags().BoolVar(&scanNoHistory, "no-history", false, "不将本次结果记录到扫描历史")
+
 	// 绑定到viper配置
 	viper.BindPFlag("scan.target", scanCmd.Flags().Lookup("target"))
 	viper.BindPFlag("scan.ports", scanCmd.Flags().Lookup("ports"))
@@ -199,9 +264,11 @@ func init() {
 	viper.BindPFlag("scan.service_probe", scanCmd.Flags().Lookup("service-probe"))
 	viper.BindPFlag("scan.banner_grab", scanCmd.Flags().Lookup("banner-grab"))
 	viper.BindPFlag("scan.version_intensity", scanCmd.Flags().Lookup("version-intensity"))
+	viper.BindPFlag("scan.tls_inspect", scanCmd.Flags().Lookup("tls-inspect"))
 	viper.BindPFlag("scan.os_detection", scanCmd.Flags().Lookup("os-detection"))
 	viper.BindPFlag("scan.guess_os", scanCmd.Flags().Lookup("guess-os"))
 	viper.BindPFlag("scan.limit_os_scan", scanCmd.Flags().Lookup("limit-os-scan"))
+	viper.BindPFlag("history.db", scanCmd.Flags().Lookup("history-db"))
 
 	// 设置必填参数
 	scanCmd.MarkFlagRequired("target")
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+)
+
+// AgentConfig 分布式扫描代理配置
+type AgentConfig struct {
+	CoordinatorURL string         // 协调器地址，如 http://10.0.0.1:8080
+	Token          string         // 代理认证令牌
+	Name           string         // 代理名称，默认使用主机名
+	Networks       []string       // 可达网段(CIDR)，为空表示不限制
+	ScanTypes      []string       // 支持的扫描类型，为空表示不限制
+	Concurrency    int            // 同时执行的工作单元数
+	PollInterval   time.Duration  // 没有工作单元时的轮询间隔，0表示使用协调器建议的间隔
+	HTTPClient     *http.Client   // 访问协调器使用的HTTP客户端
+	Dialer         scanner.Dialer // 扫描使用的拨号器，为nil时使用系统网络
+}
+
+// Agent 分布式扫描代理
+// 向协调器注册后持续拉取工作单元，在本地网络中执行扫描并逐个回报结果。
+type Agent struct {
+	config       *AgentConfig
+	capabilities AgentCapabilities
+
+	mu         sync.Mutex
+	id         string
+	registerMu sync.Mutex // 串行化重新注册，避免多个工作协程各自注册
+}
+
+// NewAgent 创建分布式扫描代理
+func NewAgent(config *AgentConfig) (*Agent, error) {
+	if config.CoordinatorURL == "" {
+		return nil, fmt.Errorf("协调器地址不能为空")
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("代理令牌不能为空")
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if config.Name == "" {
+		config.Name, _ = os.Hostname()
+	}
+	config.CoordinatorURL = strings.TrimRight(config.CoordinatorURL, "/")
+
+	capabilities := DetectAgentCapabilities()
+	capabilities.Networks = config.Networks
+	capabilities.ScanTypes = config.ScanTypes
+	return &Agent{config: config, capabilities: capabilities}, nil
+}
+
+// DetectAgentCapabilities 检测本机的扫描能力
+func DetectAgentCapabilities() AgentCapabilities {
+	return AgentCapabilities{RawSockets: os.Geteuid() == 0}
+}
+
+// ID 返回协调器分配的代理ID，未注册时为空
+func (a *Agent) ID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.id
+}
+
+// Run 注册到协调器并持续执行工作单元，直到ctx取消
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+	logger.Infof("代理 %s 已注册到 %s (ID: %s, 原始套接字: %v)", a.config.Name, a.config.CoordinatorURL, a.ID(), a.capabilities.RawSockets)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.heartbeatLoop(ctx)
+	}()
+	for i := 0; i < a.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.workLoop(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// workLoop 拉取并执行工作单元
+func (a *Agent) workLoop(ctx context.Context) {
+	for ctx.Err() == nil {
+		id := a.ID()
+		unit, err := a.fetchWork(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warnf("拉取工作单元失败: %v", err)
+			a.reregister(ctx, id, err)
+			a.wait(ctx)
+			continue
+		}
+		if unit == nil {
+			a.wait(ctx)
+			continue
+		}
+
+		result := a.execute(unit)
+		if ctx.Err() != nil {
+			return
+		}
+		if err := a.post(ctx, "/results", result, nil); err != nil {
+			logger.Warnf("回报工作单元 %s 结果失败: %v", unit.ID, err)
+		}
+	}
+}
+
+// heartbeatLoop 定期发送心跳，续期正在执行的工作单元
+func (a *Agent) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(a.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			id := a.ID()
+			if err := a.post(ctx, "/heartbeat", nil, nil); err != nil && ctx.Err() == nil {
+				logger.Warnf("发送心跳失败: %v", err)
+				a.reregister(ctx, id, err)
+			}
+		}
+	}
+}
+
+// execute 在本地执行工作单元
+func (a *Agent) execute(unit *WorkUnit) *WorkResult {
+	logger.Debugf("执行工作单元 %s: %s [%s]", unit.ID, unit.Target, unit.Ports)
+
+	opts := scanOptionsFromRequest(&unit.Request)
+	opts.Dialer = a.config.Dialer
+	// 代理在后台执行，不向标准输出打印扫描建议
+	opts.Quiet = true
+	host, err := scanner.ExecuteHostScan(opts)
+	if err != nil {
+		return &WorkResult{UnitID: unit.ID, Error: err.Error()}
+	}
+	return &WorkResult{UnitID: unit.ID, Host: host}
+}
+
+// register 向协调器注册
+func (a *Agent) register(ctx context.Context) error {
+	var resp RegisterAgentResponse
+	req := RegisterAgentRequest{Name: a.config.Name, Capabilities: a.capabilities}
+	if _, err := a.do(ctx, http.MethodPost, "/api/v1/agent/register", req, &resp); err != nil {
+		return fmt.Errorf("注册代理失败: %v", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.id = resp.AgentID
+	if a.config.PollInterval <= 0 {
+		a.config.PollInterval = resp.PollInterval
+	}
+	return nil
+}
+
+// reregister 协调器不认识本代理时(如协调器重启)重新注册，其他协程已重新注册时不再重复
+func (a *Agent) reregister(ctx context.Context, failedID string, cause error) {
+	if !strings.Contains(cause.Error(), ErrUnknownAgent.Error()) {
+		return
+	}
+	a.registerMu.Lock()
+	defer a.registerMu.Unlock()
+	if a.ID() != failedID {
+		return
+	}
+	if err := a.register(ctx); err != nil {
+		logger.Warnf("%v", err)
+	}
+}
+
+// fetchWork 拉取一个工作单元，没有可分配的单元时返回nil
+func (a *Agent) fetchWork(ctx context.Context) (*WorkUnit, error) {
+	var unit WorkUnit
+	status, err := a.do(ctx, http.MethodGet, "/api/v1/agent/"+a.ID()+"/work", nil, &unit)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNoContent {
+		return nil, nil
+	}
+	return &unit, nil
+}
+
+// post 向本代理的接口发送请求
+func (a *Agent) post(ctx context.Context, path string, body, out interface{}) error {
+	_, err := a.do(ctx, http.MethodPost, "/api/v1/agent/"+a.ID()+path, body, out)
+	return err
+}
+
+// do 发送带代理令牌的JSON请求，非2xx响应返回协调器给出的错误信息
+func (a *Agent) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("序列化请求失败: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.config.CoordinatorURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set(AgentTokenHeader, a.config.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.config.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return resp.StatusCode, fmt.Errorf("协调器返回错误 (%d): %s", resp.StatusCode, apiErr.Error)
+		}
+		return resp.StatusCode, fmt.Errorf("协调器返回错误 (%d)", resp.StatusCode)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// pollInterval 返回轮询间隔
+func (a *Agent) pollInterval() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.config.PollInterval > 0 {
+		return a.config.PollInterval
+	}
+	return defaultAgentPoll
+}
+
+// wait 等待一个轮询间隔或ctx取消
+func (a *Agent) wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(a.pollInterval()):
+	}
+}
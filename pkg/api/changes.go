@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/google/uuid"
+)
+
+const (
+	changeKeyPrefix    = "change:"           // 变化事件在存储中的键前缀
+	baselineKeyPrefix  = "baseline:"         // 监控基线在存储中的键前缀
+	changeRetention    = 30 * 24 * time.Hour // 变化事件保留时长
+	maxChangeRecords   = 10000               // 内存中保留的最大变化事件数
+	defaultChangeLimit = 100                 // 变化事件查询默认返回数量
+)
+
+// ChangeRecord 监控计划发现的变化事件
+type ChangeRecord struct {
+	ID         string `json:"id"`          // 事件ID
+	ScheduleID string `json:"schedule_id"` // 监控计划ID
+	TaskID     string `json:"task_id"`     // 发现变化的扫描任务ID
+	scanner.ChangeEvent
+}
+
+// ChangeQuery 变化事件查询条件
+type ChangeQuery struct {
+	ScheduleID string             // 监控计划ID
+	Host       string             // 主机地址
+	Type       scanner.ChangeType // 变化类型
+	Since      time.Time          // 只返回该时间之后的事件
+	Limit      int                // 最多返回的事件数，取最新的事件
+}
+
+// ChangeFeed 变化事件流，保存监控基线和各次扫描发现的变化
+type ChangeFeed struct {
+	mu      sync.Mutex
+	records []*ChangeRecord // 按时间排序
+	store   StorageInterface
+	monitor *scanner.Monitor
+}
+
+// NewChangeFeed 创建变化事件流，事件和基线保存在store中
+func NewChangeFeed(store StorageInterface) *ChangeFeed {
+	return &ChangeFeed{
+		store:   store,
+		monitor: scanner.NewMonitor(),
+	}
+}
+
+// Load 从存储中加载基线和变化事件
+func (f *ChangeFeed) Load(ctx context.Context) error {
+	keys, err := f.store.ScanKeys(ctx, baselineKeyPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("读取监控基线失败: %v", err)
+	}
+	for _, key := range keys {
+		data, err := f.store.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		var host scanner.HostResult
+		if err := json.Unmarshal([]byte(data), &host); err != nil {
+			logger.Warnf("解析监控基线 %s 失败: %v", key, err)
+			continue
+		}
+		f.monitor.SetBaseline(strings.TrimPrefix(key, baselineKeyPrefix), &host)
+	}
+
+	keys, err = f.store.ScanKeys(ctx, changeKeyPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("读取变化事件失败: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		data, err := f.store.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		var record ChangeRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			continue
+		}
+		f.records = append(f.records, &record)
+	}
+	sort.SliceStable(f.records, func(i, j int) bool { return f.records[i].Time.Before(f.records[j].Time) })
+	f.trim()
+	return nil
+}
+
+// Record 将监控计划任务的扫描结果与基线比较，保存并返回发现的变化
+func (f *ChangeFeed) Record(scheduleID, taskID string, host *scanner.HostResult) []*ChangeRecord {
+	key := scheduleID + "/" + host.Address()
+	changes := f.monitor.Observe(key, host)
+	if baseline, ok := f.monitor.Baseline(key); ok {
+		f.save(baselineKeyPrefix+key, baseline, 0)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records := make([]*ChangeRecord, 0, len(changes))
+	for _, change := range changes {
+		record := &ChangeRecord{
+			ID:          uuid.New().String(),
+			ScheduleID:  scheduleID,
+			TaskID:      taskID,
+			ChangeEvent: change,
+		}
+		f.save(changeKeyPrefix+record.ID, record, changeRetention)
+		f.records = append(f.records, record)
+		records = append(records, record)
+	}
+	f.trim()
+	return records
+}
+
+// Query 按条件查询变化事件，按时间升序返回
+func (f *ChangeFeed) Query(q ChangeQuery) []*ChangeRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultChangeLimit
+	}
+	var matched []*ChangeRecord
+	for i := len(f.records) - 1; i >= 0 && len(matched) < limit; i-- {
+		record := f.records[i]
+		if q.ScheduleID != "" && record.ScheduleID != q.ScheduleID ||
+			q.Host != "" && record.Host != q.Host ||
+			q.Type != "" && record.Type != q.Type ||
+			!q.Since.IsZero() && !record.Time.After(q.Since) {
+			continue
+		}
+		matched = append(matched, record)
+	}
+	// 倒序收集最新的事件，返回前恢复为时间升序
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+	return matched
+}
+
+// trim 丢弃超出数量上限的旧事件
+func (f *ChangeFeed) trim() {
+	if len(f.records) > maxChangeRecords {
+		f.records = append([]*ChangeRecord(nil), f.records[len(f.records)-maxChangeRecords:]...)
+	}
+}
+
+// save 将对象写入存储
+func (f *ChangeFeed) save(key string, value interface{}, expiration time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if err := f.store.Set(context.Background(), key, string(data), expiration); err != nil {
+		logger.Warnf("保存 %s 失败: %v", key, err)
+	}
+}
+
+// recordChanges 监控计划的任务完成后与基线比较，记录变化并在有变化时发送通知
+func (s *Server) recordChanges(task *Task) {
+	if task.ScheduleID == "" || task.Result == nil || task.Result.Host == nil {
+		return
+	}
+	schedule, ok := s.scheduler.Get(task.ScheduleID)
+	if !ok || !schedule.Monitor {
+		return
+	}
+
+	records := s.changes.Record(schedule.ID, task.ID, task.Result.Host)
+	for _, record := range records {
+		logger.Infof("监控计划 %s 发现变化: %s", schedule.Name, record.ChangeEvent)
+	}
+	if len(records) == 0 || schedule.NotifyURL == "" {
+		return
+	}
+
+	notification := &scanner.ChangeNotification{
+		Source: schedule.Name,
+		Target: schedule.Request.Target,
+		Time:   time.Now(),
+	}
+	for _, record := range records {
+		notification.Changes = append(notification.Changes, record.ChangeEvent)
+	}
+	if err := scanner.NewWebhookNotifier(schedule.NotifyURL).Notify(s.ctx, notification); err != nil {
+		logger.Warnf("监控计划 %s 发送变化通知失败: %v", schedule.Name, err)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// monitorTask 构造监控计划完成的任务，openPorts为本次发现的开放端口
+func monitorTask(scheduleID, taskID string, openPorts ...int) *Task {
+	host := scanner.NewHostResult("192.0.2.10")
+	results := []scanner.ScanResult{{Port: 22, State: scanner.PortStateClosed}, {Port: 80, State: scanner.PortStateClosed}, {Port: 443, State: scanner.PortStateClosed}}
+	for i := range results {
+		for _, port := range openPorts {
+			if results[i].Port == port {
+				results[i].State = scanner.PortStateOpen
+			}
+		}
+	}
+	host.AddResults(scanner.ScanTypeTCP, results)
+	return &Task{ID: taskID, ScheduleID: scheduleID, Result: &ScanResult{Host: host}}
+}
+
+func TestChangeFeed(t *testing.T) {
+	var mu sync.Mutex
+	var notifications []scanner.ChangeNotification
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n scanner.ChangeNotification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		mu.Lock()
+		notifications = append(notifications, n)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	server := setupTestServer()
+	_, err := server.scheduler.Create(&ScheduleRequest{Cron: "@hourly", NotifyURL: webhook.URL, Request: ScanRequest{Target: "192.0.2.10", Ports: "22,80,443"}})
+	assert.Error(t, err, "通知地址只能用于监控计划")
+
+	schedule, err := server.scheduler.Create(&ScheduleRequest{Cron: "@hourly", Monitor: true, NotifyURL: webhook.URL, Request: ScanRequest{Target: "192.0.2.10", Ports: "22,80,443"}})
+	require.NoError(t, err)
+	plain, err := server.scheduler.Create(&ScheduleRequest{Cron: "@hourly", Request: ScanRequest{Target: "192.0.2.10", Ports: "22,80,443"}})
+	require.NoError(t, err)
+
+	// 首次扫描建立基线，结果相同的扫描不产生变化也不发送通知
+	server.recordChanges(monitorTask(schedule.ID, "task-1", 22, 80))
+	server.recordChanges(monitorTask(schedule.ID, "task-2", 22, 80))
+	assert.Empty(t, server.changes.Query(ChangeQuery{}))
+	assert.Empty(t, notifications)
+
+	server.recordChanges(monitorTask(schedule.ID, "task-3", 22, 443))
+	// 非监控计划的任务不做比较
+	server.recordChanges(monitorTask(plain.ID, "task-4", 22))
+	server.recordChanges(monitorTask(plain.ID, "task-5", 443))
+
+	changes := server.changes.Query(ChangeQuery{ScheduleID: schedule.ID})
+	require.Len(t, changes, 2)
+	for _, change := range changes {
+		assert.Equal(t, "task-3", change.TaskID)
+	}
+	require.Len(t, notifications, 1)
+	assert.Len(t, notifications[0].Changes, 2)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/changes?type=port-opened&host=192.0.2.10", nil)
+	server.engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Total   int             `json:"total"`
+		Changes []*ChangeRecord `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 1, resp.Total)
+	assert.Equal(t, 443, resp.Changes[0].Port)
+	assert.Equal(t, scanner.ChangePortOpened, resp.Changes[0].Type)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/changes?since=yesterday", nil)
+	server.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 基线和事件保存在存储中，重启后继续比较
+	restored := NewChangeFeed(server.changes.store)
+	require.NoError(t, restored.Load(context.Background()))
+	assert.Len(t, restored.Query(ChangeQuery{}), 2)
+	assert.Empty(t, restored.Record(schedule.ID, "task-6", monitorTask(schedule.ID, "task-6", 22, 443).Result.Host))
+}
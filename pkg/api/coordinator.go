@@ -0,0 +1,521 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/google/uuid"
+)
+
+// AgentTokenHeader 代理认证令牌所在的请求头
+const AgentTokenHeader = "X-Agent-Token"
+
+// 分布式扫描的默认参数
+const (
+	defaultShardPorts   = 1000             // 每个工作单元的端口数
+	defaultLeaseTimeout = 2 * time.Minute  // 工作单元租约时长
+	defaultAgentPoll    = 5 * time.Second  // 代理轮询和心跳间隔
+	maxUnitAttempts     = 3                // 工作单元最多分配次数
+	agentOfflineAfter   = 30 * time.Second // 超过该时长没有心跳的代理视为离线
+)
+
+// ErrUnknownAgent 代理未注册或协调器重启后注册信息丢失
+var ErrUnknownAgent = fmt.Errorf("代理未注册")
+
+// AgentCapabilities 代理上报的扫描能力
+type AgentCapabilities struct {
+	RawSockets bool     `json:"raw_sockets"`          // 是否可以发送原始报文
+	Networks   []string `json:"networks,omitempty"`   // 可达网段(CIDR)，为空表示不限制
+	ScanTypes  []string `json:"scan_types,omitempty"` // 支持的扫描类型，为空表示不限制
+}
+
+// AgentInfo 已注册的代理
+type AgentInfo struct {
+	ID           string            `json:"id"`            // 代理ID
+	Name         string            `json:"name"`          // 代理名称
+	Capabilities AgentCapabilities `json:"capabilities"`  // 扫描能力
+	RegisteredAt time.Time         `json:"registered_at"` // 注册时间
+	LastSeen     time.Time         `json:"last_seen"`     // 最近一次心跳或请求的时间
+	Online       bool              `json:"online"`        // 是否在线
+	ActiveUnits  int               `json:"active_units"`  // 正在执行的工作单元数
+	Completed    int               `json:"completed"`     // 已完成的工作单元数
+
+	networks []*net.IPNet // 解析后的可达网段
+}
+
+// RegisterAgentRequest 代理注册请求
+type RegisterAgentRequest struct {
+	Name         string            `json:"name"`         // 代理名称
+	Capabilities AgentCapabilities `json:"capabilities"` // 扫描能力
+}
+
+// RegisterAgentResponse 代理注册响应
+type RegisterAgentResponse struct {
+	AgentID      string        `json:"agent_id"`      // 分配的代理ID
+	PollInterval time.Duration `json:"poll_interval"` // 建议的轮询和心跳间隔
+}
+
+// WorkUnit 分配给代理的工作单元，对应一个主机的一个端口分片
+type WorkUnit struct {
+	ID       string      `json:"id"`       // 工作单元ID
+	JobID    string      `json:"job_id"`   // 所属任务ID
+	Target   string      `json:"target"`   // 目标主机
+	Ports    string      `json:"ports"`    // 端口分片
+	Request  ScanRequest `json:"request"`  // 扫描参数，目标和端口已替换为本单元的值
+	Attempts int         `json:"attempts"` // 已分配次数
+
+	queuedAt time.Time // 最近一次进入等待队列的时间
+}
+
+// WorkResult 代理回报的工作单元结果
+type WorkResult struct {
+	UnitID string              `json:"unit_id"`         // 工作单元ID
+	Host   *scanner.HostResult `json:"host,omitempty"`  // 扫描结果
+	Error  string              `json:"error,omitempty"` // 扫描失败时的错误信息
+}
+
+// JobRequest 分布式扫描任务请求
+type JobRequest struct {
+	ScanRequest
+	ShardPorts int `json:"shard_ports"` // 每个工作单元的端口数，0表示使用默认值
+}
+
+// Job 分布式扫描任务，各工作单元的结果按主机合并
+type Job struct {
+	ID         string                `json:"id"`               // 任务ID
+	Status     string                `json:"status"`           // 任务状态
+	CreateTime time.Time             `json:"create_time"`      // 创建时间
+	EndTime    *time.Time            `json:"end_time"`         // 结束时间
+	Request    *ScanRequest          `json:"request"`          // 扫描请求
+	TotalUnits int                   `json:"total_units"`      // 工作单元总数
+	Completed  int                   `json:"completed_units"`  // 已完成的工作单元数
+	Failed     int                   `json:"failed_units"`     // 失败的工作单元数
+	Hosts      []*scanner.HostResult `json:"hosts"`            // 合并后的主机结果
+	Errors     []string              `json:"errors,omitempty"` // 失败工作单元的错误信息
+
+	hosts map[string]*scanner.HostResult // 按目标索引的主机结果
+}
+
+// unitLease 已分配给代理的工作单元
+type unitLease struct {
+	unit     *WorkUnit
+	agentID  string
+	deadline time.Time
+}
+
+// Coordinator 分布式扫描协调器
+// 将任务按主机和端口分片拆成工作单元，按代理能力分配，租约超时的单元重新排队，并合并各代理回报的结果。
+// 等待超过租约时长且没有在线代理可以执行的单元记为失败，避免任务一直处于pending状态。
+type Coordinator struct {
+	mu           sync.Mutex
+	agents       map[string]*AgentInfo
+	jobs         map[string]*Job
+	pending      []*WorkUnit
+	leases       map[string]*unitLease
+	leaseTimeout time.Duration
+	now          func() time.Time
+	onFinish     func(job *Job) // 任务结束时以快照调用，不持有锁
+}
+
+// NewCoordinator 创建协调器，leaseTimeout为0时使用默认租约时长
+func NewCoordinator(leaseTimeout time.Duration) *Coordinator {
+	if leaseTimeout <= 0 {
+		leaseTimeout = defaultLeaseTimeout
+	}
+	return &Coordinator{
+		agents:       make(map[string]*AgentInfo),
+		jobs:         make(map[string]*Job),
+		leases:       make(map[string]*unitLease),
+		leaseTimeout: leaseTimeout,
+		now:          time.Now,
+	}
+}
+
+// RegisterAgent 注册代理
+func (c *Coordinator) RegisterAgent(req *RegisterAgentRequest) (*AgentInfo, error) {
+	agent := &AgentInfo{
+		ID:           uuid.New().String(),
+		Name:         req.Name,
+		Capabilities: req.Capabilities,
+	}
+	for _, cidr := range req.Capabilities.Networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的网段: %s", cidr)
+		}
+		agent.networks = append(agent.networks, network)
+	}
+	for _, scanType := range req.Capabilities.ScanTypes {
+		if err := scanner.ValidateScanType(scanner.ScanType(scanType)); err != nil {
+			return nil, err
+		}
+	}
+	if agent.Name == "" {
+		agent.Name = agent.ID[:8]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	agent.RegisteredAt = c.now()
+	agent.LastSeen = agent.RegisteredAt
+	c.agents[agent.ID] = agent
+	return agent, nil
+}
+
+// Heartbeat 记录代理心跳，并续期代理正在执行的工作单元的租约
+func (c *Coordinator) Heartbeat(agentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[agentID]
+	if !ok {
+		return ErrUnknownAgent
+	}
+	agent.LastSeen = c.now()
+	for _, lease := range c.leases {
+		if lease.agentID == agentID {
+			lease.deadline = agent.LastSeen.Add(c.leaseTimeout)
+		}
+	}
+	return nil
+}
+
+// SubmitJob 创建分布式扫描任务，按目标主机和端口分片生成工作单元
+// 请求应已通过validateScanRequest校验。
+func (c *Coordinator) SubmitJob(req *JobRequest) (*Job, error) {
+	targets, err := scanner.ExpandTargets(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	shardSize := req.ShardPorts
+	if shardSize <= 0 {
+		shardSize = defaultShardPorts
+	}
+	shards, err := scanner.ShardPorts(req.Ports, shardSize)
+	if err != nil {
+		return nil, err
+	}
+
+	scanReq := req.ScanRequest
+	job := &Job{
+		ID:         uuid.New().String(),
+		Status:     "pending",
+		Request:    &scanReq,
+		TotalUnits: len(targets) * len(shards),
+		hosts:      make(map[string]*scanner.HostResult),
+	}
+
+	units := make([]*WorkUnit, 0, job.TotalUnits)
+	for _, target := range targets {
+		for _, ports := range shards {
+			unitReq := scanReq
+			unitReq.Target = target
+			unitReq.Ports = ports
+			units = append(units, &WorkUnit{
+				ID:      uuid.New().String(),
+				JobID:   job.ID,
+				Target:  target,
+				Ports:   ports,
+				Request: unitReq,
+			})
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job.CreateTime = c.now()
+	c.jobs[job.ID] = job
+	c.enqueue(units...)
+	return c.snapshot(job), nil
+}
+
+// NextUnit 为代理分配一个其能力可以执行的工作单元，没有可分配的单元时返回nil
+func (c *Coordinator) NextUnit(agentID string) (*WorkUnit, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[agentID]
+	if !ok {
+		return nil, ErrUnknownAgent
+	}
+	now := c.now()
+	agent.LastSeen = now
+	c.expireLeases(now)
+	c.expirePending(now)
+
+	for i, unit := range c.pending {
+		if !agentCanRun(agent, unit) {
+			continue
+		}
+		c.pending = append(c.pending[:i], c.pending[i+1:]...)
+		unit.Attempts++
+		c.leases[unit.ID] = &unitLease{unit: unit, agentID: agentID, deadline: now.Add(c.leaseTimeout)}
+		agent.ActiveUnits++
+		if job := c.jobs[unit.JobID]; job != nil && job.Status == "pending" {
+			job.Status = "running"
+		}
+		copied := *unit
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+// SubmitResult 接收代理回报的工作单元结果
+// 失败的单元在未超过最大分配次数时重新排队，成功的结果按主机合并到任务中。
+func (c *Coordinator) SubmitResult(agentID string, result *WorkResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[agentID]
+	if !ok {
+		return ErrUnknownAgent
+	}
+	agent.LastSeen = c.now()
+
+	lease, ok := c.leases[result.UnitID]
+	if !ok || lease.agentID != agentID {
+		return fmt.Errorf("工作单元 %s 未分配给该代理或租约已过期", result.UnitID)
+	}
+	delete(c.leases, result.UnitID)
+	agent.ActiveUnits--
+
+	job := c.jobs[lease.unit.JobID]
+	if job == nil {
+		return nil
+	}
+	if result.Error != "" {
+		c.retry(lease.unit, fmt.Sprintf("%s [%s] (代理 %s): %s", lease.unit.Target, lease.unit.Ports, agent.Name, result.Error))
+		return nil
+	}
+
+	agent.Completed++
+	if result.Host != nil {
+		// 合并到副本上，已返回的快照不受影响
+		merged := result.Host
+		if host, ok := job.hosts[lease.unit.Target]; ok {
+			merged = host.Clone()
+			merged.Merge(result.Host)
+		}
+		job.hosts[lease.unit.Target] = merged
+	}
+	job.Completed++
+	c.finish(job)
+	return nil
+}
+
+// Job 返回任务的快照
+func (c *Coordinator) Job(id string) (*Job, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expireLeases(c.now())
+	c.expirePending(c.now())
+	job, ok := c.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return c.snapshot(job), true
+}
+
+// Jobs 按创建时间返回所有任务的快照
+func (c *Coordinator) Jobs() []*Job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expireLeases(c.now())
+	c.expirePending(c.now())
+	jobs := make([]*Job, 0, len(c.jobs))
+	for _, job := range c.jobs {
+		jobs = append(jobs, c.snapshot(job))
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreateTime.Before(jobs[j].CreateTime) })
+	return jobs
+}
+
+// Agents 按注册时间返回所有代理
+func (c *Coordinator) Agents() []AgentInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	agents := make([]AgentInfo, 0, len(c.agents))
+	for _, agent := range c.agents {
+		info := *agent
+		info.Online = now.Sub(agent.LastSeen) < agentOfflineAfter
+		agents = append(agents, info)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].RegisteredAt.Before(agents[j].RegisteredAt) })
+	return agents
+}
+
+// expireLeases 将租约超时的工作单元重新排队
+func (c *Coordinator) expireLeases(now time.Time) {
+	for id, lease := range c.leases {
+		if now.Before(lease.deadline) {
+			continue
+		}
+		delete(c.leases, id)
+		name := lease.agentID
+		if agent, ok := c.agents[lease.agentID]; ok {
+			agent.ActiveUnits--
+			name = agent.Name
+		}
+		c.retry(lease.unit, fmt.Sprintf("%s [%s] (代理 %s): 租约超时", lease.unit.Target, lease.unit.Ports, name))
+	}
+}
+
+// expirePending 将等待超过租约时长且没有在线代理可以执行的工作单元记为失败
+func (c *Coordinator) expirePending(now time.Time) {
+	kept := c.pending[:0]
+	for _, unit := range c.pending {
+		if now.Sub(unit.queuedAt) < c.leaseTimeout || c.runnable(unit, now) {
+			kept = append(kept, unit)
+			continue
+		}
+		c.fail(unit, fmt.Sprintf("%s [%s]: 没有在线代理可以执行 %s 扫描", unit.Target, unit.Ports, unit.Request.ScanType))
+	}
+	for i := len(kept); i < len(c.pending); i++ {
+		c.pending[i] = nil
+	}
+	c.pending = kept
+}
+
+// runnable 判断是否有在线代理可以执行工作单元
+func (c *Coordinator) runnable(unit *WorkUnit, now time.Time) bool {
+	for _, agent := range c.agents {
+		if now.Sub(agent.LastSeen) < agentOfflineAfter && agentCanRun(agent, unit) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue 将工作单元加入等待队列
+func (c *Coordinator) enqueue(units ...*WorkUnit) {
+	now := c.now()
+	for _, unit := range units {
+		unit.queuedAt = now
+	}
+	c.pending = append(c.pending, units...)
+}
+
+// fail 将工作单元记为失败
+func (c *Coordinator) fail(unit *WorkUnit, reason string) {
+	job := c.jobs[unit.JobID]
+	if job == nil {
+		return
+	}
+	job.Failed++
+	job.Errors = append(job.Errors, reason)
+	c.finish(job)
+}
+
+// retry 重新排队工作单元，超过最大分配次数时记为失败
+func (c *Coordinator) retry(unit *WorkUnit, reason string) {
+	job := c.jobs[unit.JobID]
+	if job == nil {
+		return
+	}
+	if unit.Attempts < maxUnitAttempts {
+		c.enqueue(unit)
+		return
+	}
+	c.fail(unit, reason)
+}
+
+// finish 所有工作单元结束后更新任务状态
+func (c *Coordinator) finish(job *Job) {
+	if job.Completed+job.Failed < job.TotalUnits {
+		return
+	}
+	now := c.now()
+	job.EndTime = &now
+	if job.Completed == 0 {
+		job.Status = "failed"
+	} else {
+		job.Status = "completed"
+	}
+	if c.onFinish != nil {
+		go c.onFinish(c.snapshot(job))
+	}
+}
+
+// snapshot 复制任务，主机结果按地址排序
+func (c *Coordinator) snapshot(job *Job) *Job {
+	copied := *job
+	copied.Errors = append([]string(nil), job.Errors...)
+	copied.Hosts = make([]*scanner.HostResult, 0, len(job.hosts))
+	for _, host := range job.hosts {
+		copied.Hosts = append(copied.Hosts, host)
+	}
+	sort.Slice(copied.Hosts, func(i, j int) bool {
+		return compareAddress(copied.Hosts[i].Address(), copied.Hosts[j].Address()) < 0
+	})
+	copied.hosts = nil
+	return &copied
+}
+
+// agentCanRun 判断代理的能力是否满足工作单元的要求
+// 需要root权限的扫描类型要求代理可以发送原始报文；声明了可达网段的代理只接收网段内的IP目标。
+func agentCanRun(agent *AgentInfo, unit *WorkUnit) bool {
+	scanType := scanner.ScanType(unit.Request.ScanType)
+	if reg, ok := scanner.LookupScanner(scanType); ok && reg.Capabilities.RequiresRoot && !agent.Capabilities.RawSockets {
+		return false
+	}
+	if len(agent.Capabilities.ScanTypes) > 0 && !containsScanType(agent.Capabilities.ScanTypes, unit.Request.ScanType) {
+		return false
+	}
+	if len(agent.networks) == 0 {
+		return true
+	}
+	ip := net.ParseIP(unit.Target)
+	if ip == nil {
+		return false
+	}
+	for _, network := range agent.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsScanType 判断扫描类型列表中是否包含指定类型
+func containsScanType(list []string, scanType string) bool {
+	for _, item := range list {
+		if item == scanType {
+			return true
+		}
+	}
+	return false
+}
+
+// compareAddress 比较两个地址，IP按数值排序并排在主机名之前
+func compareAddress(a, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipA != nil && ipB != nil:
+		ipA, ipB = ipA.To16(), ipB.To16()
+		for i := range ipA {
+			if ipA[i] != ipB[i] {
+				return int(ipA[i]) - int(ipB[i])
+			}
+		}
+		return 0
+	case ipA != nil:
+		return -1
+	case ipB != nil:
+		return 1
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner/scannertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registerTestAgent(t *testing.T, c *Coordinator, caps AgentCapabilities) string {
+	agent, err := c.RegisterAgent(&RegisterAgentRequest{Capabilities: caps})
+	require.NoError(t, err)
+	return agent.ID
+}
+
+func TestCoordinatorShardsAndMatchesCapabilities(t *testing.T) {
+	c := NewCoordinator(time.Minute)
+	job, err := c.SubmitJob(&JobRequest{
+		ScanRequest: ScanRequest{Target: "192.0.2.1-2", Ports: "1-10", ScanType: "tcp"},
+		ShardPorts:  4,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 6, job.TotalUnits)
+	assert.Equal(t, "pending", job.Status)
+
+	// 网段不匹配的代理拿不到工作单元
+	other := registerTestAgent(t, c, AgentCapabilities{Networks: []string{"198.51.100.0/24"}})
+	unit, err := c.NextUnit(other)
+	require.NoError(t, err)
+	assert.Nil(t, unit)
+
+	local := registerTestAgent(t, c, AgentCapabilities{Networks: []string{"192.0.2.0/24"}})
+	unit, err = c.NextUnit(local)
+	require.NoError(t, err)
+	require.NotNil(t, unit)
+	assert.Equal(t, "192.0.2.1", unit.Request.Target)
+	assert.Equal(t, "1-4", unit.Request.Ports)
+	assert.Equal(t, 1, unit.Attempts)
+
+	// 需要root权限的扫描只分配给可发送原始报文的代理
+	_, err = c.SubmitJob(&JobRequest{ScanRequest: ScanRequest{Target: "198.51.100.5", Ports: "80", ScanType: "syn"}})
+	require.NoError(t, err)
+	unit, err = c.NextUnit(other)
+	require.NoError(t, err)
+	assert.Nil(t, unit)
+
+	raw := registerTestAgent(t, c, AgentCapabilities{RawSockets: true, Networks: []string{"198.51.100.0/24"}})
+	unit, err = c.NextUnit(raw)
+	require.NoError(t, err)
+	require.NotNil(t, unit)
+	assert.Equal(t, "syn", unit.Request.ScanType)
+
+	_, err = c.NextUnit("unknown")
+	assert.Equal(t, ErrUnknownAgent, err)
+	_, err = c.RegisterAgent(&RegisterAgentRequest{Capabilities: AgentCapabilities{Networks: []string{"bad"}}})
+	assert.Error(t, err)
+}
+
+func TestCoordinatorLeaseExpiry(t *testing.T) {
+	now := time.Now()
+	c := NewCoordinator(time.Minute)
+	c.now = func() time.Time { return now }
+
+	job, err := c.SubmitJob(&JobRequest{ScanRequest: ScanRequest{Target: "192.0.2.1", Ports: "80", ScanType: "tcp"}})
+	require.NoError(t, err)
+	first := registerTestAgent(t, c, AgentCapabilities{})
+	second := registerTestAgent(t, c, AgentCapabilities{})
+
+	unit, err := c.NextUnit(first)
+	require.NoError(t, err)
+	require.NotNil(t, unit)
+
+	// 心跳续期租约
+	now = now.Add(50 * time.Second)
+	require.NoError(t, c.Heartbeat(first))
+	now = now.Add(50 * time.Second)
+	next, err := c.NextUnit(second)
+	require.NoError(t, err)
+	assert.Nil(t, next)
+
+	// 租约超时后重新分配给其他代理，原代理的结果不再接受
+	now = now.Add(time.Minute)
+	next, err = c.NextUnit(second)
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.Equal(t, unit.ID, next.ID)
+	assert.Equal(t, 2, next.Attempts)
+	assert.Error(t, c.SubmitResult(first, &WorkResult{UnitID: unit.ID}))
+
+	// 超过最大分配次数后记为失败
+	require.NoError(t, c.SubmitResult(second, &WorkResult{UnitID: next.ID, Error: "boom"}))
+	next, err = c.NextUnit(first)
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	require.NoError(t, c.SubmitResult(first, &WorkResult{UnitID: next.ID, Error: "boom"}))
+
+	snapshot, ok := c.Job(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, "failed", snapshot.Status)
+	assert.Equal(t, 1, snapshot.Failed)
+	require.Len(t, snapshot.Errors, 1)
+	assert.Contains(t, snapshot.Errors[0], "boom")
+	assert.NotNil(t, snapshot.EndTime)
+}
+
+func TestCoordinatorFailsUnassignableUnits(t *testing.T) {
+	now := time.Now()
+	c := NewCoordinator(time.Minute)
+	c.now = func() time.Time { return now }
+
+	job, err := c.SubmitJob(&JobRequest{ScanRequest: ScanRequest{Target: "192.0.2.1-2", Ports: "80", ScanType: "syn"}})
+	require.NoError(t, err)
+	plain := registerTestAgent(t, c, AgentCapabilities{})
+
+	// 租约时长内等待有能力的代理上线
+	now = now.Add(30 * time.Second)
+	raw := registerTestAgent(t, c, AgentCapabilities{RawSockets: true, Networks: []string{"192.0.2.2/32"}})
+	unit, err := c.NextUnit(plain)
+	require.NoError(t, err)
+	assert.Nil(t, unit)
+	snapshot, _ := c.Job(job.ID)
+	assert.Equal(t, "pending", snapshot.Status)
+
+	// 超过租约时长后没有在线代理可以执行的单元记为失败，其余单元仍可分配
+	now = now.Add(40 * time.Second)
+	require.NoError(t, c.Heartbeat(raw))
+	unit, err = c.NextUnit(plain)
+	require.NoError(t, err)
+	assert.Nil(t, unit)
+	snapshot, _ = c.Job(job.ID)
+	assert.Equal(t, 1, snapshot.Failed)
+	require.Len(t, snapshot.Errors, 1)
+	assert.Contains(t, snapshot.Errors[0], "192.0.2.1")
+	assert.Contains(t, snapshot.Errors[0], "没有在线代理")
+
+	unit, err = c.NextUnit(raw)
+	require.NoError(t, err)
+	require.NotNil(t, unit)
+	assert.Equal(t, "192.0.2.2", unit.Target)
+	require.NoError(t, c.SubmitResult(raw, &WorkResult{UnitID: unit.ID, Host: scanner.NewHostResult(unit.Target)}))
+	snapshot, _ = c.Job(job.ID)
+	assert.Equal(t, "completed", snapshot.Status)
+	assert.Equal(t, 1, snapshot.Completed)
+
+	// 代理离线后等待中的单元同样记为失败
+	job, err = c.SubmitJob(&JobRequest{ScanRequest: ScanRequest{Target: "192.0.2.2", Ports: "80", ScanType: "syn"}})
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	snapshot, _ = c.Job(job.ID)
+	assert.Equal(t, "failed", snapshot.Status)
+}
+
+func TestCoordinatorMergesResults(t *testing.T) {
+	c := NewCoordinator(time.Minute)
+	job, err := c.SubmitJob(&JobRequest{
+		ScanRequest: ScanRequest{Target: "192.0.2.20,192.0.2.3", Ports: "22,80", ScanType: "tcp"},
+		ShardPorts:  1,
+	})
+	require.NoError(t, err)
+	agent := registerTestAgent(t, c, AgentCapabilities{})
+
+	for i := 0; i < job.TotalUnits; i++ {
+		unit, err := c.NextUnit(agent)
+		require.NoError(t, err)
+		require.NotNil(t, unit)
+
+		port, err := scanner.ParsePorts(unit.Ports)
+		require.NoError(t, err)
+		host := scanner.NewHostResult(unit.Target)
+		host.AddResults(scanner.ScanTypeTCP, []scanner.ScanResult{{Port: port[0], State: scanner.PortStateOpen}})
+		require.NoError(t, c.SubmitResult(agent, &WorkResult{UnitID: unit.ID, Host: host}))
+	}
+
+	snapshot, ok := c.Job(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, "completed", snapshot.Status)
+	require.Len(t, snapshot.Hosts, 2)
+	assert.Equal(t, "192.0.2.3", snapshot.Hosts[0].Address())
+	for _, host := range snapshot.Hosts {
+		assert.Equal(t, scanner.HostStateUp, host.Status)
+		require.Len(t, host.TCP, 2)
+		assert.Equal(t, 22, host.TCP[0].Port)
+		assert.Equal(t, 80, host.TCP[1].Port)
+	}
+
+	agents := c.Agents()
+	require.Len(t, agents, 1)
+	assert.Equal(t, 4, agents[0].Completed)
+	assert.Equal(t, 0, agents[0].ActiveUnits)
+	assert.True(t, agents[0].Online)
+}
+
+func TestDistributedScanWithAgents(t *testing.T) {
+	server := setupTestServer()
+	server.config.AgentToken = "agent-secret"
+	ts := httptest.NewServer(server.engine)
+	defer ts.Close()
+
+	// 两台主机分属两个网段，各由所在网段的代理扫描
+	network := scannertest.NewNetwork(1)
+	for _, ip := range []string{"192.0.2.10", "192.0.2.200"} {
+		network.AddHost(&scannertest.Host{
+			IP:  net.ParseIP(ip),
+			TCP: map[int]scannertest.PortState{22: scannertest.PortOpen, 80: scannertest.PortOpen},
+		})
+	}
+
+	// 令牌错误时拒绝接入
+	bad, err := NewAgent(&AgentConfig{CoordinatorURL: ts.URL, Token: "wrong"})
+	require.NoError(t, err)
+	assert.Error(t, bad.Run(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, cidr := range []string{"192.0.2.0/25", "192.0.2.128/25"} {
+		agent, err := NewAgent(&AgentConfig{
+			CoordinatorURL: ts.URL,
+			Token:          "agent-secret",
+			Name:           cidr,
+			Networks:       []string{cidr},
+			Concurrency:    2,
+			PollInterval:   10 * time.Millisecond,
+			Dialer:         network,
+		})
+		require.NoError(t, err)
+		go agent.Run(ctx)
+	}
+
+	body, _ := json.Marshal(JobRequest{
+		ScanRequest: ScanRequest{Target: "192.0.2.10,192.0.2.200", Ports: "20-25,80", Timeout: time.Second},
+		ShardPorts:  3,
+	})
+	resp, err := http.Post(ts.URL+"/api/v1/jobs", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	var created struct {
+		JobID      string `json:"job_id"`
+		TotalUnits int    `json:"total_units"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	resp.Body.Close()
+	assert.Equal(t, 6, created.TotalUnits)
+
+	var job Job
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(ts.URL + "/api/v1/jobs/" + created.JobID)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&job) == nil && job.Status == "completed"
+	}, 10*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, 6, job.Completed)
+	require.Len(t, job.Hosts, 2)
+	for _, host := range job.Hosts {
+		assert.Equal(t, scanner.HostStateUp, host.Status)
+		assert.Len(t, host.TCP, 7)
+		open := host.OpenPorts()
+		require.Len(t, open, 2)
+		assert.Equal(t, 22, open[0].Port)
+		assert.Equal(t, 80, open[1].Port)
+	}
+
+	// 每个代理只扫描了自己网段内的主机
+	agents := server.coordinator.Agents()
+	require.Len(t, agents, 2)
+	for _, agent := range agents {
+		assert.Equal(t, 3, agent.Completed, agent.Name)
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros 预定义的cron表达式
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField cron表达式中一个字段的取值范围
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "分钟", min: 0, max: 59}
+	cronHour   = cronField{name: "小时", min: 0, max: 23}
+	cronDay    = cronField{name: "日", min: 1, max: 31}
+	cronMonth  = cronField{name: "月", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronWeekday = cronField{name: "星期", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// CronSchedule 解析后的cron表达式，精度为分钟
+type CronSchedule struct {
+	minute, hour, day, month, weekday uint64
+	dayStar, weekdayStar              bool
+	loc                               *time.Location
+}
+
+// ParseCron 解析标准的5字段cron表达式(分 时 日 月 星期)
+// 支持 *、逗号列表、范围、步长、月份和星期的英文缩写，以及@daily、@weekly等预定义表达式。
+// loc为计算执行时间使用的时区，为nil时使用本地时区。
+func ParseCron(expr string, loc *time.Location) (*CronSchedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("无效的cron表达式 %q: 需要5个字段(分 时 日 月 星期)", expr)
+	}
+
+	schedule := &CronSchedule{
+		dayStar:     fields[2] == "*" || fields[2] == "?",
+		weekdayStar: fields[4] == "*" || fields[4] == "?",
+		loc:         loc,
+	}
+	targets := []*uint64{&schedule.minute, &schedule.hour, &schedule.day, &schedule.month, &schedule.weekday}
+	for i, field := range []cronField{cronMinute, cronHour, cronDay, cronMonth, cronWeekday} {
+		bits, err := field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("无效的cron表达式 %q: %v", expr, err)
+		}
+		*targets[i] = bits
+	}
+	// 星期中的7与0同为周日
+	if schedule.weekday&(1<<7) != 0 {
+		schedule.weekday |= 1
+	}
+	return schedule, nil
+}
+
+// parse 将字段解析为位图
+func (f cronField) parse(spec string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段的步长无效: %s", f.name, part)
+			}
+			rangeSpec, step = part[:idx], n
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case rangeSpec == "*" || rangeSpec == "?":
+		case strings.Contains(rangeSpec, "-"):
+			idx := strings.Index(rangeSpec, "-")
+			var err error
+			if start, err = f.value(rangeSpec[:idx]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(rangeSpec[idx+1:]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("%s字段的范围无效: %s", f.name, rangeSpec)
+			}
+		default:
+			value, err := f.value(rangeSpec)
+			if err != nil {
+				return 0, err
+			}
+			start = value
+			// 单个值带步长时表示从该值开始到最大值
+			if step == 1 {
+				end = value
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析字段中的单个值
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s字段的取值无效: %s (范围 %d-%d)", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next 返回晚于after的下一个执行时间，5年内没有匹配的时间时返回零值
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	t := after.In(c.loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, c.loc)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 判断日期是否匹配
+// 与标准cron一致：日和星期都有限制时满足其一即可，否则两者都须满足。
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	day := c.day&(1<<uint(t.Day())) != 0
+	weekday := c.weekday&(1<<uint(t.Weekday())) != 0
+	if c.dayStar || c.weekdayStar {
+		return day && weekday
+	}
+	return day || weekday
+}
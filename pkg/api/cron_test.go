@@ -0,0 +1,65 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronNext(t *testing.T) {
+	// 2024-03-06 是周三
+	base := time.Date(2024, 3, 6, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 6, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 6, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2024, 3, 7, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"30 1 * * sun", time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2024, 3, 6, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 日和星期都有限制时满足其一即可
+		{"0 0 15 * fri", time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr, time.UTC)
+			require.NoError(t, err)
+			assert.Equal(t, tt.next, schedule.Next(base))
+		})
+	}
+
+	// 闰年的2月29日在2024年之后下一次是2028年
+	leap, err := ParseCron("0 0 29 2 *", time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), leap.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	// 不存在的日期
+	never, err := ParseCron("0 0 31 2 *", time.UTC)
+	require.NoError(t, err)
+	assert.True(t, never.Next(base).IsZero())
+}
+
+func TestParseCronTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	schedule, err := ParseCron("0 3 * * *", loc)
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 3, 6, 19, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "* * * * funday"} {
+		_, err := ParseCron(expr, time.UTC)
+		assert.Error(t, err, expr)
+	}
+}
@@ -2,7 +2,6 @@ package api
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"time"
 
@@ -10,10 +9,9 @@ import (
 	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 )
 
-// executeScan 执行扫描任务
-func (s *Server) executeScan(req *ScanRequest) (*ScanResult, error) {
-	// 创建扫描选项
-	opts := &scanner.ScanOptions{
+// scanOptionsFromRequest 将扫描请求转换为扫描选项
+func scanOptionsFromRequest(req *ScanRequest) *scanner.ScanOptions {
+	return &scanner.ScanOptions{
 		Target:           req.Target,
 		Ports:            req.Ports,
 		ScanType:         scanner.ScanType(req.ScanType),
@@ -24,13 +22,18 @@ func (s *Server) executeScan(req *ScanRequest) (*ScanResult, error) {
 		VersionIntensity: req.VersionIntensity,
 		GuessOS:          req.GuessOS,
 		LimitOSScan:      req.LimitOSScan,
+		HostTimeout:      req.HostTimeout,
+		MaxRetries:       req.MaxRetries,
+		DetectTarpit:     req.DetectTarpit,
 	}
+}
 
-	// 创建扫描器
-	scanner, err := scanner.NewScanner(opts)
-	if err != nil {
-		return nil, fmt.Errorf("创建扫描器失败: %v", err)
-	}
+// executeScan 执行扫描任务
+func (s *Server) executeScan(req *ScanRequest) (*ScanResult, error) {
+	// 创建扫描选项
+	opts := scanOptionsFromRequest(req)
+	// 服务端执行不向标准输出打印建议
+	opts.Quiet = true
 
 	// 创建输出缓冲区
 	var buf bytes.Buffer
@@ -51,9 +54,8 @@ func (s *Server) executeScan(req *ScanRequest) (*ScanResult, error) {
 		return nil, fmt.Errorf("创建输出处理器失败: %v", err)
 	}
 
-	// 执行扫描
-	ctx := context.Background()
-	results, err := scanner.Scan(ctx)
+	// 通过扫描器注册表执行扫描
+	host, err := scanner.ExecuteHostScan(opts)
 	if err != nil {
 		return nil, fmt.Errorf("扫描执行失败: %v", err)
 	}
@@ -62,8 +64,15 @@ func (s *Server) executeScan(req *ScanRequest) (*ScanResult, error) {
 	outputOpts.EndTime = time.Now()
 	outputOpts.Duration = outputOpts.EndTime.Sub(outputOpts.StartTime)
 
+	// 疑似蜜罐的主机不输出端口结果，评分随任务结果返回
+	honeypot := host.Honeypot
+	hosts := []*scanner.HostResult{host}
+	if honeypot.Exceeds(req.HoneypotLimit) {
+		host, hosts = nil, nil
+	}
+
 	// 写入扫描结果
-	if err := outputHandler.Write(results); err != nil {
+	if err := outputHandler.WriteHosts(hosts); err != nil {
 		return nil, fmt.Errorf("写入扫描结果失败: %v", err)
 	}
 
@@ -74,6 +83,8 @@ func (s *Server) executeScan(req *ScanRequest) (*ScanResult, error) {
 		StartTime: outputOpts.StartTime,
 		EndTime:   outputOpts.EndTime,
 		Result:    buf.String(),
+		Host:      host,
+		Honeypot:  honeypot,
 	}
 
 	return scanResult, nil
@@ -93,6 +104,11 @@ func (s *Server) validateScanRequest(req *ScanRequest) error {
 		req.ScanType = "tcp" // 默认使用TCP扫描
 	}
 
+	// 扫描类型以扫描器注册表为准
+	if err := scanner.ValidateScanType(scanner.ScanType(req.ScanType)); err != nil {
+		return err
+	}
+
 	if req.Timeout == 0 {
 		req.Timeout = 5 * time.Second // 默认超时时间5秒
 	}
@@ -105,6 +121,14 @@ func (s *Server) validateScanRequest(req *ScanRequest) error {
 		req.OutputFormat = "json" // 默认使用JSON输出格式
 	}
 
+	if req.MaxRetries < 0 {
+		return fmt.Errorf("最大重试次数不能为负数")
+	}
+
+	if req.HoneypotLimit < 0 || req.HoneypotLimit > 100 {
+		return fmt.Errorf("蜜罐评分阈值必须在0-100之间")
+	}
+
 	if req.VersionIntensity < 0 || req.VersionIntensity > 9 {
 		req.VersionIntensity = 7 // 默认版本检测强度为7
 	}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
@@ -104,6 +107,12 @@ func (s *Server) handleCreateScanTask(c *gin.Context) {
 		return
 	}
 
+	// 验证请求参数并填充默认值
+	if err := s.validateScanRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 创建新任务
 	task := &Task{
 		ID:         uuid.New().String(),
@@ -113,17 +122,37 @@ func (s *Server) handleCreateScanTask(c *gin.Context) {
 	}
 
 	// 将任务加入队列
-	select {
-	case s.taskQueue <- task:
-		// 更新任务状态
-		s.updateTask(task)
-		c.JSON(http.StatusOK, gin.H{
-			"task_id": task.ID,
-			"status":  task.Status,
-		})
-	default:
+	if !s.enqueueTask(task) {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "任务队列已满"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task_id": task.ID,
+		"status":  task.Status,
+	})
+}
+
+// handlePlanScan 处理扫描计划请求，只估算探测数与耗时，不执行扫描
+func (s *Server) handlePlanScan(c *gin.Context) {
+	var req ScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	// 验证请求参数并填充默认值
+	if err := s.validateScanRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := scanner.PlanScan(scanOptionsFromRequest(&req))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+
+	c.JSON(http.StatusOK, plan)
 }
 
 // handleListTasks 处理获取任务列表请求
@@ -157,6 +186,17 @@ func (s *Server) handleListTasks(c *gin.Context) {
 		}
 	}
 
+	// 按定时计划过滤
+	if scheduleID := c.Query("schedule_id"); scheduleID != "" {
+		filtered := make([]*Task, 0)
+		for _, task := range tasks {
+			if task.ScheduleID == scheduleID {
+				filtered = append(filtered, task)
+			}
+		}
+		tasks = filtered
+	}
+
 	c.JSON(http.StatusOK, gin.H{"tasks": tasks})
 }
 
@@ -390,3 +430,345 @@ func (s *Server) validateUser(username, password string) bool {
 	// TODO: 实现用户验证逻辑
 	return true
 }
+
+// handleRegisterAgent 处理代理注册请求
+func (s *Server) handleRegisterAgent(c *gin.Context) {
+	var req RegisterAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	agent, err := s.coordinator.RegisterAgent(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, RegisterAgentResponse{
+		AgentID:      agent.ID,
+		PollInterval: defaultAgentPoll,
+	})
+}
+
+// handleAgentHeartbeat 处理代理心跳
+func (s *Server) handleAgentHeartbeat(c *gin.Context) {
+	if err := s.coordinator.Heartbeat(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleAgentWork 处理代理拉取工作单元的请求，没有可分配的单元时返回204
+func (s *Server) handleAgentWork(c *gin.Context) {
+	unit, err := s.coordinator.NextUnit(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if unit == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, unit)
+}
+
+// handleAgentResult 处理代理回报的工作单元结果
+func (s *Server) handleAgentResult(c *gin.Context) {
+	var result WorkResult
+	if err := c.ShouldBindJSON(&result); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if err := s.coordinator.SubmitResult(c.Param("id"), &result); err != nil {
+		status := http.StatusConflict
+		if err == ErrUnknownAgent {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleListAgents 处理获取代理列表请求
+func (s *Server) handleListAgents(c *gin.Context) {
+	agents := s.coordinator.Agents()
+	c.JSON(http.StatusOK, gin.H{
+		"total":  len(agents),
+		"agents": agents,
+	})
+}
+
+// handleCreateJob 处理创建分布式扫描任务请求
+func (s *Server) handleCreateJob(c *gin.Context) {
+	var req JobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	// 验证请求参数并填充默认值
+	if err := s.validateScanRequest(&req.ScanRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := s.coordinator.SubmitJob(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job_id":      job.ID,
+		"status":      job.Status,
+		"total_units": job.TotalUnits,
+	})
+}
+
+// handleListJobs 处理获取分布式扫描任务列表请求
+func (s *Server) handleListJobs(c *gin.Context) {
+	jobs := s.coordinator.Jobs()
+	summaries := make([]gin.H, 0, len(jobs))
+	for _, job := range jobs {
+		summaries = append(summaries, gin.H{
+			"id":              job.ID,
+			"status":          job.Status,
+			"create_time":     job.CreateTime,
+			"end_time":        job.EndTime,
+			"target":          job.Request.Target,
+			"total_units":     job.TotalUnits,
+			"completed_units": job.Completed,
+			"failed_units":    job.Failed,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(summaries),
+		"jobs":  summaries,
+	})
+}
+
+// handleGetJob 处理获取分布式扫描任务请求，返回合并后的主机结果
+func (s *Server) handleGetJob(c *gin.Context) {
+	job, ok := s.coordinator.Job(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// bindScheduleRequest 解析并校验计划请求
+func (s *Server) bindScheduleRequest(c *gin.Context) (*ScheduleRequest, bool) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return nil, false
+	}
+
+	// 验证扫描参数并填充默认值
+	if err := s.validateScanRequest(&req.Request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+// handleCreateSchedule 处理创建定时扫描计划请求
+func (s *Server) handleCreateSchedule(c *gin.Context) {
+	req, ok := s.bindScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := s.scheduler.Create(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// handleListSchedules 处理获取定时扫描计划列表请求
+func (s *Server) handleListSchedules(c *gin.Context) {
+	schedules := s.scheduler.List()
+	c.JSON(http.StatusOK, gin.H{
+		"total":     len(schedules),
+		"schedules": schedules,
+	})
+}
+
+// handleGetSchedule 处理获取定时扫描计划请求
+func (s *Server) handleGetSchedule(c *gin.Context) {
+	schedule, ok := s.scheduler.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "计划不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// handleUpdateSchedule 处理更新定时扫描计划请求
+func (s *Server) handleUpdateSchedule(c *gin.Context) {
+	if _, ok := s.scheduler.Get(c.Param("id")); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "计划不存在"})
+		return
+	}
+	req, ok := s.bindScheduleRequest(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := s.scheduler.Update(c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// handleDeleteSchedule 处理删除定时扫描计划请求
+func (s *Server) handleDeleteSchedule(c *gin.Context) {
+	if err := s.scheduler.Delete(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "计划已删除"})
+}
+
+// handleRunSchedule 处理立即执行定时扫描计划请求
+func (s *Server) handleRunSchedule(c *gin.Context) {
+	if _, ok := s.scheduler.Get(c.Param("id")); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "计划不存在"})
+		return
+	}
+
+	taskID, err := s.scheduler.RunNow(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task_id": taskID,
+		"status":  "pending",
+	})
+}
+
+// handleListChanges 处理获取变化事件请求
+// 支持按 schedule_id、host、type、since(RFC3339) 过滤，limit 限制返回最新的事件数
+func (s *Server) handleListChanges(c *gin.Context) {
+	query := ChangeQuery{
+		ScheduleID: c.Query("schedule_id"),
+		Host:       c.Query("host"),
+		Type:       scanner.ChangeType(c.Query("type")),
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的since参数，应为RFC3339格式"})
+			return
+		}
+		query.Since = t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit参数"})
+			return
+		}
+		query.Limit = n
+	}
+
+	changes := s.changes.Query(query)
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(changes),
+		"changes": changes,
+	})
+}
+
+// handleQueryHistory 按条件查询扫描历史中的端口记录
+func (s *Server) handleQueryHistory(c *gin.Context) {
+	if s.history == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用扫描历史"})
+		return
+	}
+	query, ok := bindHistoryQuery(c)
+	if !ok {
+		return
+	}
+	observations, err := s.history.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total":        len(observations),
+		"observations": observations,
+	})
+}
+
+// handleListHistoryScans 列出扫描历史中的扫描记录
+func (s *Server) handleListHistoryScans(c *gin.Context) {
+	if s.history == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用扫描历史"})
+		return
+	}
+	query, ok := bindHistoryQuery(c)
+	if !ok {
+		return
+	}
+	scans, err := s.history.Scans(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(scans),
+		"scans": scans,
+	})
+}
+
+// bindHistoryQuery 解析扫描历史的查询参数，参数无效时返回错误响应
+func bindHistoryQuery(c *gin.Context) (history.Query, bool) {
+	query := history.Query{
+		Host:    c.Query("host"),
+		Service: c.Query("service"),
+		State:   scanner.PortState(c.Query("state")),
+		Latest:  c.Query("latest") == "true",
+	}
+	if port := c.Query("port"); port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的port参数"})
+			return query, false
+		}
+		query.Port = n
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit参数"})
+			return query, false
+		}
+		query.Limit = n
+	}
+
+	now := time.Now()
+	var err error
+	if since := c.Query("since"); since != "" {
+		if query.Since, err = history.ParseTime(since, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return query, false
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if query.Until, err = history.ParseTime(until, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return query, false
+		}
+	}
+	return query, true
+}
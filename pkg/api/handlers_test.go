@@ -156,6 +156,42 @@ func TestHandleCreateScanTask(t *testing.T) {
 	}
 }
 
+// TestHandlePlanScan 测试扫描计划
+func TestHandlePlanScan(t *testing.T) {
+	server := setupTestServer()
+	router := gin.New()
+	router.POST("/api/v1/scan/plan", server.handlePlanScan)
+
+	plan := func(req ScanRequest) *httptest.ResponseRecorder {
+		reqBody, err := json.Marshal(req)
+		require.NoError(t, err)
+		httpReq, _ := http.NewRequest(http.MethodPost, "/api/v1/scan/plan", bytes.NewBuffer(reqBody))
+		httpReq.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	w := plan(ScanRequest{
+		Target:        "10.0.0.0/30",
+		Ports:         "1-100",
+		ScanType:      "tcp",
+		EnableService: true,
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(4), resp["target_count"])
+	assert.Equal(t, float64(100), resp["port_count"])
+	assert.Equal(t, float64(800), resp["total_probes"])
+	assert.Len(t, resp["stages"], 2)
+
+	w = plan(ScanRequest{Ports: "1-100"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "目标地址不能为空")
+}
+
 // TestHandleListTasks 测试任务列表
 func TestHandleListTasks(t *testing.T) {
 	server := setupTestServer()
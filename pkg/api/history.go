@@ -0,0 +1,52 @@
+package api
+
+import (
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+)
+
+// recordTaskHistory 将完成的扫描任务写入扫描历史
+func (s *Server) recordTaskHistory(task *Task) {
+	if s.history == nil || task.Result == nil || task.Result.Host == nil || task.Result.Host.GiveUp != nil {
+		return
+	}
+	scan := &history.Scan{
+		Source:   history.SourceAPI,
+		SourceID: task.ID,
+		Target:   task.Request.Target,
+		Ports:    task.Request.Ports,
+		ScanType: task.Request.ScanType,
+		Start:    task.Result.Host.Timing.Start,
+		End:      task.Result.Host.Timing.End,
+		Hosts:    []*scanner.HostResult{task.Result.Host},
+	}
+	if task.ScheduleID != "" {
+		scan.Source, scan.SourceID = history.SourceSchedule, task.ScheduleID
+	}
+	if err := s.history.Record(scan); err != nil {
+		logger.Warnf("记录任务 %s 的扫描历史失败: %v", task.ID, err)
+	}
+}
+
+// recordJobHistory 将结束的分布式扫描任务写入扫描历史
+func (s *Server) recordJobHistory(job *Job) {
+	if s.history == nil || len(job.Hosts) == 0 {
+		return
+	}
+	scan := &history.Scan{
+		Source:   history.SourceJob,
+		SourceID: job.ID,
+		Target:   job.Request.Target,
+		Ports:    job.Request.Ports,
+		ScanType: job.Request.ScanType,
+		Start:    job.CreateTime,
+		Hosts:    job.Hosts,
+	}
+	if job.EndTime != nil {
+		scan.End = *job.EndTime
+	}
+	if err := s.history.Record(scan); err != nil {
+		logger.Warnf("记录分布式任务 %s 的扫描历史失败: %v", job.ID, err)
+	}
+}
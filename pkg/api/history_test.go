@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer(&ServerConfig{
+		TaskTimeout:    10 * time.Second,
+		MaxConcurrency: 1,
+		QueueSize:      10,
+		AllowInMemory:  true,
+		HistoryPath:    filepath.Join(t.TempDir(), "history.jsonl"),
+	})
+	defer server.Stop()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		server.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// 计划任务和分布式任务都记录到历史中
+	task := monitorTask("schedule-1", "task-1", 22, 443)
+	task.Request = &ScanRequest{Target: "192.0.2.10", Ports: "22,80,443", ScanType: "tcp"}
+	server.recordTaskHistory(task)
+	end := time.Now()
+	job := &Job{ID: "job-1", Request: &ScanRequest{Target: "192.0.2.0/30", Ports: "80", ScanType: "tcp"}, CreateTime: end, EndTime: &end,
+		Hosts: []*scanner.HostResult{monitorTask("", "", 80).Result.Host}}
+	job.Hosts[0].Addresses = []string{"192.0.2.1"}
+	server.recordJobHistory(job)
+
+	w := get("/api/v1/history?state=open&host=192.0.2.10")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Total        int                   `json:"total"`
+		Observations []history.Observation `json:"observations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 2, resp.Total)
+	assert.Equal(t, 22, resp.Observations[0].Port)
+	assert.Equal(t, 443, resp.Observations[1].Port)
+
+	w = get("/api/v1/history/scans?host=192.0.2.0/24")
+	require.Equal(t, http.StatusOK, w.Code)
+	var scans struct {
+		Total int             `json:"total"`
+		Scans []*history.Scan `json:"scans"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &scans))
+	require.Equal(t, 2, scans.Total)
+	assert.Equal(t, history.SourceSchedule, scans.Scans[0].Source)
+	assert.Equal(t, "schedule-1", scans.Scans[0].SourceID)
+	assert.Equal(t, history.SourceJob, scans.Scans[1].Source)
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/history?port=abc").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/history?since=yesterday").Code)
+
+	// 未配置历史库时接口不可用
+	disabled := setupTestServer()
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/history", nil)
+	disabled.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
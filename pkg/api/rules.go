@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// watchRules 定期检查用户服务规则目录，规则文件变化后新的扫描任务使用新规则
+func (s *Server) watchRules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reloadRules()
+		}
+	}
+}
+
+// reloadRules 重新加载用户服务规则并记录结果
+func (s *Server) reloadRules() (*fingerprint.RuleSet, bool, error) {
+	set, changed, err := fingerprint.ReloadRules()
+	if err != nil {
+		logger.Warnf("重新加载用户服务规则失败: %v", err)
+		return nil, false, err
+	}
+	if changed {
+		logger.Infof("已从 %s 加载 %d 条用户服务规则", set.Dir, len(set.Rules))
+		for _, e := range set.Errors {
+			logger.Warnf("用户服务规则 %s", e.Error())
+		}
+	}
+	return set, changed, nil
+}
+
+// rulesResponse 用户服务规则接口的响应
+func rulesResponse(set *fingerprint.RuleSet, changed bool) gin.H {
+	errors := make([]string, 0, len(set.Errors))
+	for _, e := range set.Errors {
+		errors = append(errors, e.Error())
+	}
+	return gin.H{
+		"dir":     set.Dir,
+		"version": set.Version,
+		"total":   len(set.Rules),
+		"rules":   set.Rules,
+		"errors":  errors,
+		"changed": changed,
+	}
+}
+
+// handleListRules 列出当前生效的用户服务规则
+func (s *Server) handleListRules(c *gin.Context) {
+	set, err := fingerprint.Rules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rulesResponse(set, false))
+}
+
+// handleReloadRules 立即重新加载用户服务规则
+func (s *Server) handleReloadRules(c *gin.Context) {
+	set, changed, err := s.reloadRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rulesResponse(set, changed))
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRule = `rules:
+  - name: acme
+    service: acme
+    ports: 9000
+    match:
+      - regex: '^ACME'
+`
+
+func TestRulesHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	fingerprint.SetRulesDir(dir)
+	defer fingerprint.SetRulesDir("")
+
+	server := NewServer(&ServerConfig{
+		TaskTimeout:    10 * time.Second,
+		MaxConcurrency: 1,
+		QueueSize:      10,
+		AllowInMemory:  true,
+		RulesReload:    20 * time.Millisecond,
+	})
+	defer server.Stop()
+
+	request := func(method, path string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		server.engine.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := request(http.MethodGet, "/api/v1/fingerprint/rules")
+	assert.Equal(t, dir, resp["dir"])
+	assert.Equal(t, 0.0, resp["total"])
+
+	// 后台定期检查规则目录，新增的规则无需重启即可生效
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "acme.yaml"), []byte(testRule), 0644))
+	assert.Eventually(t, func() bool {
+		set, err := fingerprint.Rules()
+		return err == nil && len(set.Rules) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	resp = request(http.MethodGet, "/api/v1/fingerprint/rules")
+	assert.Equal(t, 1.0, resp["total"])
+
+	// 手动重新加载，规则错误随响应返回
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("rules:\n  - name: broken\n"), 0644))
+	resp = request(http.MethodPost, "/api/v1/fingerprint/rules/reload")
+	assert.Equal(t, 1.0, resp["total"])
+	assert.Len(t, resp["errors"], 1)
+}
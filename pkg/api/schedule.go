@@ -0,0 +1,474 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// 错过执行时间(服务停机、任务队列已满或不在维护窗口内)时的处理策略
+const (
+	MissedRunSkip    = "skip"     // 跳过错过的执行，等待下一个执行时间
+	MissedRunCatchUp = "catch-up" // 尽快补执行一次，多次错过只补一次
+)
+
+const (
+	scheduleKeyPrefix  = "schedule:"    // 计划在存储中的键前缀
+	scheduleTick       = time.Second    // 调度器检查间隔
+	missedRunGrace     = time.Minute    // 超过执行时间该时长仍未执行视为错过
+	maxScheduleHistory = 20             // 计划保留的最近任务数
+	maxScheduleJitter  = 24 * time.Hour // 随机延迟上限
+)
+
+// MaintenanceWindow 维护窗口，计划任务只在窗口内执行
+type MaintenanceWindow struct {
+	Days  []string `json:"days,omitempty"` // 允许的星期(sun,mon,...,sat)，为空表示每天；跨午夜的窗口按开始日计算
+	Start string   `json:"start"`          // 开始时间 HH:MM
+	End   string   `json:"end"`            // 结束时间 HH:MM，早于开始时间表示跨越午夜，与开始时间相同表示全天
+}
+
+// ScheduleRequest 创建或更新计划的请求
+type ScheduleRequest struct {
+	Name       string             `json:"name"`        // 计划名称
+	Cron       string             `json:"cron"`        // cron表达式
+	Timezone   string             `json:"timezone"`    // 时区，如 Asia/Shanghai，为空使用服务器时区
+	Request    ScanRequest        `json:"request"`     // 每次执行的扫描请求
+	Enabled    *bool              `json:"enabled"`     // 是否启用，默认启用
+	Jitter     time.Duration      `json:"jitter"`      // 随机延迟上限，用于错开同一时刻的多个计划
+	Window     *MaintenanceWindow `json:"window"`      // 维护窗口，为空表示不限制
+	MissedRuns string             `json:"missed_runs"` // 错过执行时的处理策略: skip, catch-up
+	Monitor    bool               `json:"monitor"`     // 监控模式，每次结果与上一次的基线比较并记录变化
+	NotifyURL  string             `json:"notify_url"`  // 监控发现变化时通知的Webhook地址
+}
+
+// Schedule 定时扫描计划，每次执行创建一个关联到计划的普通扫描任务
+type Schedule struct {
+	ID          string             `json:"id"`                     // 计划ID
+	Name        string             `json:"name"`                   // 计划名称
+	Cron        string             `json:"cron"`                   // cron表达式
+	Timezone    string             `json:"timezone,omitempty"`     // 时区
+	Request     ScanRequest        `json:"request"`                // 扫描请求
+	Enabled     bool               `json:"enabled"`                // 是否启用
+	Jitter      time.Duration      `json:"jitter"`                 // 随机延迟上限
+	Window      *MaintenanceWindow `json:"window,omitempty"`       // 维护窗口
+	MissedRuns  string             `json:"missed_runs"`            // 错过执行时的处理策略
+	Monitor     bool               `json:"monitor"`                // 是否为监控计划
+	NotifyURL   string             `json:"notify_url,omitempty"`   // 变化通知的Webhook地址
+	CreateTime  time.Time          `json:"create_time"`            // 创建时间
+	UpdateTime  time.Time          `json:"update_time"`            // 更新时间
+	NextRun     *time.Time         `json:"next_run"`               // 下一次执行时间(含随机延迟)
+	LastRun     *time.Time         `json:"last_run"`               // 最近一次执行时间
+	Runs        int                `json:"runs"`                   // 已执行次数
+	Skipped     int                `json:"skipped"`                // 跳过的次数
+	RecentTasks []string           `json:"recent_tasks,omitempty"` // 最近创建的任务ID
+	LastError   string             `json:"last_error,omitempty"`   // 最近一次创建任务失败的原因
+
+	cron *CronSchedule
+	loc  *time.Location
+}
+
+// Scheduler 定时扫描调度器
+type Scheduler struct {
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+	store     StorageInterface
+	submit    func(schedule *Schedule) (string, error) // 为计划创建扫描任务，返回任务ID
+	now       func() time.Time
+	rand      *rand.Rand
+}
+
+// NewScheduler 创建调度器，计划保存在store中，submit负责为到期的计划创建扫描任务
+func NewScheduler(store StorageInterface, submit func(schedule *Schedule) (string, error)) *Scheduler {
+	return &Scheduler{
+		schedules: make(map[string]*Schedule),
+		store:     store,
+		submit:    submit,
+		now:       time.Now,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Load 从存储中加载计划，停机期间错过的执行在下一次检查时按策略处理
+func (s *Scheduler) Load(ctx context.Context) error {
+	keys, err := s.store.ScanKeys(ctx, scheduleKeyPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("读取计划失败: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		data, err := s.store.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		var schedule Schedule
+		if err := json.Unmarshal([]byte(data), &schedule); err != nil {
+			logger.Warnf("解析计划 %s 失败: %v", key, err)
+			continue
+		}
+		if err := schedule.compile(); err != nil {
+			logger.Warnf("加载计划 %s 失败: %v", schedule.ID, err)
+			continue
+		}
+		if schedule.Enabled && schedule.NextRun == nil {
+			s.plan(&schedule, s.now())
+		}
+		s.schedules[schedule.ID] = &schedule
+	}
+	return nil
+}
+
+// Run 定期检查到期的计划，直到ctx取消
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tick(s.now())
+		}
+	}
+}
+
+// Create 创建计划，请求中的扫描参数应已通过校验
+func (s *Scheduler) Create(req *ScheduleRequest) (*Schedule, error) {
+	now := s.now()
+	schedule := &Schedule{
+		ID:         uuid.New().String(),
+		CreateTime: now,
+	}
+	if err := schedule.apply(req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plan(schedule, now)
+	s.schedules[schedule.ID] = schedule
+	s.save(schedule)
+	return schedule.snapshot(), nil
+}
+
+// Update 更新计划的配置，执行统计保持不变
+func (s *Scheduler) Update(id string, req *ScheduleRequest) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("计划不存在")
+	}
+	updated := *schedule
+	if err := updated.apply(req); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	updated.UpdateTime = now
+	s.plan(&updated, now)
+	s.schedules[id] = &updated
+	s.save(&updated)
+	return updated.snapshot(), nil
+}
+
+// Delete 删除计划，已创建的任务不受影响
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return fmt.Errorf("计划不存在")
+	}
+	delete(s.schedules, id)
+	return s.store.Delete(context.Background(), scheduleKeyPrefix+id)
+}
+
+// Get 返回计划的快照
+func (s *Scheduler) Get(id string) (*Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, false
+	}
+	return schedule.snapshot(), true
+}
+
+// List 按创建时间返回所有计划的快照
+func (s *Scheduler) List() []*Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		list = append(list, schedule.snapshot())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreateTime.Before(list[j].CreateTime) })
+	return list
+}
+
+// RunNow 立即执行一次计划，不受维护窗口限制，也不改变下一次执行时间
+func (s *Scheduler) RunNow(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return "", fmt.Errorf("计划不存在")
+	}
+	taskID, err := s.submit(schedule)
+	if err != nil {
+		return "", err
+	}
+	s.recordRun(schedule, taskID, s.now())
+	s.save(schedule)
+	return taskID, nil
+}
+
+// tick 执行到期的计划
+func (s *Scheduler) tick(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, schedule := range s.schedules {
+		if !schedule.Enabled || schedule.NextRun == nil || now.Before(*schedule.NextRun) {
+			continue
+		}
+
+		// 不在维护窗口内：补执行策略推迟到窗口打开，否则跳过本次
+		if schedule.Window != nil && !schedule.Window.Contains(now.In(schedule.loc)) {
+			if schedule.MissedRuns == MissedRunCatchUp {
+				open := schedule.Window.NextOpen(now.In(schedule.loc))
+				schedule.NextRun = &open
+				logger.Infof("计划 %s 不在维护窗口内，推迟到 %s 执行", schedule.Name, open.Format(time.RFC3339))
+			} else {
+				s.skip(schedule, now, "不在维护窗口内")
+			}
+			s.save(schedule)
+			continue
+		}
+
+		if now.Sub(*schedule.NextRun) > missedRunGrace && schedule.MissedRuns == MissedRunSkip {
+			s.skip(schedule, now, "错过执行时间")
+			s.save(schedule)
+			continue
+		}
+
+		taskID, err := s.submit(schedule)
+		if err != nil {
+			schedule.LastError = err.Error()
+			logger.Warnf("计划 %s 创建任务失败: %v", schedule.Name, err)
+			// 补执行策略在下一次检查时重试
+			if schedule.MissedRuns == MissedRunSkip {
+				s.skip(schedule, now, err.Error())
+			}
+			s.save(schedule)
+			continue
+		}
+		s.recordRun(schedule, taskID, now)
+		s.plan(schedule, now)
+		s.save(schedule)
+	}
+}
+
+// recordRun 记录一次执行
+func (s *Scheduler) recordRun(schedule *Schedule, taskID string, now time.Time) {
+	schedule.Runs++
+	schedule.LastRun = &now
+	schedule.LastError = ""
+	schedule.RecentTasks = append(schedule.RecentTasks, taskID)
+	if len(schedule.RecentTasks) > maxScheduleHistory {
+		schedule.RecentTasks = schedule.RecentTasks[len(schedule.RecentTasks)-maxScheduleHistory:]
+	}
+}
+
+// skip 跳过本次执行并计划下一次
+func (s *Scheduler) skip(schedule *Schedule, now time.Time, reason string) {
+	schedule.Skipped++
+	logger.Infof("计划 %s 跳过 %s 的执行: %s", schedule.Name, schedule.NextRun.Format(time.RFC3339), reason)
+	s.plan(schedule, now)
+}
+
+// plan 计算晚于after的下一次执行时间，并加上随机延迟
+func (s *Scheduler) plan(schedule *Schedule, after time.Time) {
+	schedule.NextRun = nil
+	if !schedule.Enabled {
+		return
+	}
+	next := schedule.cron.Next(after)
+	if next.IsZero() {
+		return
+	}
+	if schedule.Jitter > 0 {
+		next = next.Add(time.Duration(s.rand.Int63n(int64(schedule.Jitter) + 1)))
+	}
+	schedule.NextRun = &next
+}
+
+// save 将计划写入存储
+func (s *Scheduler) save(schedule *Schedule) {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return
+	}
+	if err := s.store.Set(context.Background(), scheduleKeyPrefix+schedule.ID, string(data), 0); err != nil {
+		logger.Warnf("保存计划 %s 失败: %v", schedule.ID, err)
+	}
+}
+
+// apply 校验请求并更新计划的配置
+func (sc *Schedule) apply(req *ScheduleRequest) error {
+	updated := *sc
+	updated.Name = req.Name
+	updated.Cron = req.Cron
+	updated.Timezone = req.Timezone
+	updated.Request = req.Request
+	updated.Jitter = req.Jitter
+	updated.Window = req.Window
+	updated.MissedRuns = req.MissedRuns
+	updated.Enabled = req.Enabled == nil || *req.Enabled
+	updated.Monitor = req.Monitor
+	updated.NotifyURL = req.NotifyURL
+
+	if updated.MissedRuns == "" {
+		updated.MissedRuns = MissedRunSkip
+	}
+	if updated.MissedRuns != MissedRunSkip && updated.MissedRuns != MissedRunCatchUp {
+		return fmt.Errorf("不支持的错过执行策略: %s (可用: %s, %s)", updated.MissedRuns, MissedRunSkip, MissedRunCatchUp)
+	}
+	if updated.Jitter < 0 || updated.Jitter > maxScheduleJitter {
+		return fmt.Errorf("随机延迟必须在0到%s之间", maxScheduleJitter)
+	}
+	if updated.NotifyURL != "" {
+		if !updated.Monitor {
+			return fmt.Errorf("只有监控计划可以设置变化通知地址")
+		}
+		if u, err := url.Parse(updated.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("无效的通知地址: %s", updated.NotifyURL)
+		}
+	}
+	if updated.Window != nil {
+		if err := updated.Window.validate(); err != nil {
+			return err
+		}
+	}
+	if err := updated.compile(); err != nil {
+		return err
+	}
+	if updated.Name == "" {
+		updated.Name = updated.Request.Target
+	}
+
+	*sc = updated
+	return nil
+}
+
+// compile 解析时区和cron表达式
+func (sc *Schedule) compile() error {
+	sc.loc = time.Local
+	if sc.Timezone != "" {
+		loc, err := time.LoadLocation(sc.Timezone)
+		if err != nil {
+			return fmt.Errorf("无效的时区: %s", sc.Timezone)
+		}
+		sc.loc = loc
+	}
+	cron, err := ParseCron(sc.Cron, sc.loc)
+	if err != nil {
+		return err
+	}
+	sc.cron = cron
+	return nil
+}
+
+// snapshot 复制计划
+func (sc *Schedule) snapshot() *Schedule {
+	copied := *sc
+	copied.RecentTasks = append([]string(nil), sc.RecentTasks...)
+	return &copied
+}
+
+// validate 校验维护窗口
+func (w *MaintenanceWindow) validate() error {
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	for _, day := range w.Days {
+		if _, ok := cronWeekday.names[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("无效的星期: %s", day)
+		}
+	}
+	return nil
+}
+
+// Contains 判断时间是否在维护窗口内，t应已转换到计划的时区
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+	minute := t.Hour()*60 + t.Minute()
+
+	switch {
+	case start == end:
+		return w.allowsDay(t.Weekday())
+	case start < end:
+		return w.allowsDay(t.Weekday()) && minute >= start && minute < end
+	default:
+		// 跨越午夜的窗口，凌晨部分属于前一天开始的窗口
+		if minute >= start {
+			return w.allowsDay(t.Weekday())
+		}
+		return minute < end && w.allowsDay((t.Weekday()+6)%7)
+	}
+}
+
+// NextOpen 返回不早于t的最近一次窗口打开时间
+func (w *MaintenanceWindow) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	start, _ := parseClock(w.Start)
+	for i := 0; i <= 7; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, start/60, start%60, 0, 0, t.Location())
+		if day.After(t) && w.allowsDay(day.Weekday()) {
+			return day
+		}
+	}
+	return t
+}
+
+// allowsDay 判断窗口是否允许在该星期开始
+func (w *MaintenanceWindow) allowsDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if cronWeekday.names[strings.ToLower(name)] == int(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock 解析HH:MM格式的时间，返回当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间 %q，应为HH:MM格式", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestScheduler 创建使用固定时间和内存存储的调度器，返回每次创建任务时的计划ID
+func newTestScheduler(now *time.Time, store StorageInterface) (*Scheduler, *[]string) {
+	var submitted []string
+	scheduler := NewScheduler(store, func(schedule *Schedule) (string, error) {
+		submitted = append(submitted, schedule.ID)
+		return fmt.Sprintf("task-%d", len(submitted)), nil
+	})
+	scheduler.now = func() time.Time { return *now }
+	return scheduler, &submitted
+}
+
+func TestSchedulerRunsDueSchedules(t *testing.T) {
+	now := time.Date(2024, 3, 6, 1, 59, 0, 0, time.UTC)
+	scheduler, submitted := newTestScheduler(&now, NewMemoryStorage())
+
+	schedule, err := scheduler.Create(&ScheduleRequest{
+		Cron:     "0 2 * * *",
+		Timezone: "UTC",
+		Request:  ScanRequest{Target: "192.0.2.0/24", Ports: "1-1024"},
+	})
+	require.NoError(t, err)
+	assert.True(t, schedule.Enabled)
+	assert.Equal(t, MissedRunSkip, schedule.MissedRuns)
+	assert.Equal(t, "192.0.2.0/24", schedule.Name)
+	require.NotNil(t, schedule.NextRun)
+	assert.Equal(t, time.Date(2024, 3, 6, 2, 0, 0, 0, time.UTC), *schedule.NextRun)
+
+	scheduler.tick(now)
+	assert.Empty(t, *submitted)
+
+	now = now.Add(time.Minute)
+	scheduler.tick(now)
+	assert.Equal(t, []string{schedule.ID}, *submitted)
+
+	schedule, _ = scheduler.Get(schedule.ID)
+	assert.Equal(t, 1, schedule.Runs)
+	assert.Equal(t, []string{"task-1"}, schedule.RecentTasks)
+	assert.Equal(t, time.Date(2024, 3, 7, 2, 0, 0, 0, time.UTC), *schedule.NextRun)
+
+	// 停用的计划不执行
+	disabled := false
+	_, err = scheduler.Update(schedule.ID, &ScheduleRequest{Cron: "* * * * *", Request: schedule.Request, Enabled: &disabled})
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	scheduler.tick(now)
+	assert.Len(t, *submitted, 1)
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	now := time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)
+	scheduler, _ := newTestScheduler(&now, NewMemoryStorage())
+
+	for i := 0; i < 20; i++ {
+		schedule, err := scheduler.Create(&ScheduleRequest{
+			Cron:    "@hourly",
+			Jitter:  10 * time.Minute,
+			Request: ScanRequest{Target: "192.0.2.1", Ports: "80"},
+		})
+		require.NoError(t, err)
+		delay := schedule.NextRun.Sub(time.Date(2024, 3, 6, 1, 0, 0, 0, time.UTC))
+		assert.True(t, delay >= 0 && delay <= 10*time.Minute, delay)
+	}
+}
+
+func TestSchedulerMissedRuns(t *testing.T) {
+	store := NewMemoryStorage()
+	now := time.Date(2024, 3, 6, 1, 0, 0, 0, time.UTC)
+	scheduler, _ := newTestScheduler(&now, store)
+
+	skip, err := scheduler.Create(&ScheduleRequest{Name: "skip", Cron: "0 2 * * *", Timezone: "UTC", Request: ScanRequest{Target: "192.0.2.1", Ports: "80"}})
+	require.NoError(t, err)
+	catchUp, err := scheduler.Create(&ScheduleRequest{Name: "catch-up", Cron: "0 2 * * *", Timezone: "UTC", MissedRuns: MissedRunCatchUp, Request: ScanRequest{Target: "192.0.2.2", Ports: "80"}})
+	require.NoError(t, err)
+
+	// 服务重启后从存储加载计划，停机期间错过了两次执行
+	now = time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC)
+	restarted, submitted := newTestScheduler(&now, store)
+	require.NoError(t, restarted.Load(context.Background()))
+	require.Len(t, restarted.List(), 2)
+
+	restarted.tick(now)
+	assert.Equal(t, []string{catchUp.ID}, *submitted, "多次错过只补执行一次")
+
+	skipped, _ := restarted.Get(skip.ID)
+	assert.Equal(t, 1, skipped.Skipped)
+	assert.Equal(t, 0, skipped.Runs)
+	assert.Equal(t, time.Date(2024, 3, 9, 2, 0, 0, 0, time.UTC), skipped.NextRun.UTC())
+
+	caughtUp, _ := restarted.Get(catchUp.ID)
+	assert.Equal(t, 1, caughtUp.Runs)
+	assert.Equal(t, time.Date(2024, 3, 9, 2, 0, 0, 0, time.UTC), caughtUp.NextRun.UTC())
+}
+
+func TestSchedulerMaintenanceWindow(t *testing.T) {
+	now := time.Date(2024, 3, 6, 11, 0, 0, 0, time.UTC) // 周三
+	scheduler, submitted := newTestScheduler(&now, NewMemoryStorage())
+
+	window := &MaintenanceWindow{Days: []string{"sat", "sun"}, Start: "22:00", End: "04:00"}
+	deferred, err := scheduler.Create(&ScheduleRequest{Cron: "0 12 * * *", Timezone: "UTC", Window: window, MissedRuns: MissedRunCatchUp, Request: ScanRequest{Target: "192.0.2.1", Ports: "80"}})
+	require.NoError(t, err)
+	skipped, err := scheduler.Create(&ScheduleRequest{Cron: "0 12 * * *", Timezone: "UTC", Window: window, Request: ScanRequest{Target: "192.0.2.2", Ports: "80"}})
+	require.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	scheduler.tick(now)
+	assert.Empty(t, *submitted)
+
+	// 补执行策略推迟到周六22:00窗口打开，跳过策略等待下一次执行时间
+	deferredNow, _ := scheduler.Get(deferred.ID)
+	assert.Equal(t, time.Date(2024, 3, 9, 22, 0, 0, 0, time.UTC), *deferredNow.NextRun)
+	skippedNow, _ := scheduler.Get(skipped.ID)
+	assert.Equal(t, 1, skippedNow.Skipped)
+	assert.Equal(t, time.Date(2024, 3, 7, 12, 0, 0, 0, time.UTC), *skippedNow.NextRun)
+
+	now = time.Date(2024, 3, 9, 22, 0, 0, 0, time.UTC)
+	scheduler.tick(now)
+	assert.Equal(t, []string{deferred.ID}, *submitted)
+
+	// 跨午夜窗口的凌晨部分属于前一天
+	assert.True(t, window.Contains(time.Date(2024, 3, 10, 3, 59, 0, 0, time.UTC)))
+	assert.True(t, window.Contains(time.Date(2024, 3, 11, 3, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2024, 3, 9, 3, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2024, 3, 10, 4, 0, 0, 0, time.UTC)))
+
+	_, err = scheduler.Create(&ScheduleRequest{Cron: "@daily", Window: &MaintenanceWindow{Start: "25:00", End: "01:00"}, Request: ScanRequest{Target: "192.0.2.1", Ports: "80"}})
+	assert.Error(t, err)
+	_, err = scheduler.Create(&ScheduleRequest{Cron: "@daily", MissedRuns: "later", Request: ScanRequest{Target: "192.0.2.1", Ports: "80"}})
+	assert.Error(t, err)
+}
+
+func TestScheduleHandlers(t *testing.T) {
+	server := setupTestServer()
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/schedules", ScheduleRequest{Name: "nightly", Cron: "bad", Request: ScanRequest{Target: "127.0.0.1", Ports: "80"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPost, "/api/v1/schedules", ScheduleRequest{Name: "nightly", Cron: "@daily"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/api/v1/schedules", ScheduleRequest{Name: "nightly", Cron: "@daily", Request: ScanRequest{Target: "127.0.0.1", Ports: "80"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	var schedule Schedule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedule))
+	assert.Equal(t, "tcp", schedule.Request.ScanType, "扫描参数填充默认值")
+
+	w = do(http.MethodPut, "/api/v1/schedules/"+schedule.ID, ScheduleRequest{Name: "weekly", Cron: "@weekly", Request: ScanRequest{Target: "127.0.0.1", Ports: "80"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"weekly"`)
+
+	// 立即执行创建关联到计划的普通任务
+	w = do(http.MethodPost, "/api/v1/schedules/"+schedule.ID+"/run", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var run struct {
+		TaskID string `json:"task_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	task, ok := server.tasks.Load(run.TaskID)
+	require.True(t, ok)
+	assert.Equal(t, schedule.ID, task.(*Task).ScheduleID)
+
+	w = do(http.MethodGet, "/api/v1/scan/tasks?schedule_id="+schedule.ID, nil)
+	assert.Contains(t, w.Body.String(), run.TaskID)
+	w = do(http.MethodGet, "/api/v1/scan/tasks?schedule_id=other", nil)
+	assert.NotContains(t, w.Body.String(), run.TaskID)
+
+	w = do(http.MethodGet, "/api/v1/schedules", nil)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+
+	w = do(http.MethodDelete, "/api/v1/schedules/"+schedule.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(http.MethodGet, "/api/v1/schedules/"+schedule.ID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -2,15 +2,20 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/history"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -27,6 +32,10 @@ type ServerConfig struct {
 	QueueSize      int           // 任务队列大小
 	EnableAuth     bool          // 是否启用认证
 	AllowInMemory  bool          // 是否允许在Redis连接失败时降级到内存存储
+	AgentToken     string        // 分布式扫描代理的认证令牌，为空时拒绝代理接入
+	LeaseTimeout   time.Duration // 工作单元租约时长，代理在此期间未回报则重新分配
+	HistoryPath    string        // 扫描历史库文件路径，为空时不记录扫描历史
+	RulesReload    time.Duration // 检查用户服务规则变化的间隔，0表示不自动重新加载
 }
 
 // Server API服务器
@@ -40,44 +49,56 @@ type Server struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	inmemory  bool // 是否使用内存存储
+
+	coordinator *Coordinator   // 分布式扫描协调器
+	scheduler   *Scheduler     // 定时扫描调度器
+	changes     *ChangeFeed    // 监控计划的变化事件流
+	history     *history.Store // 扫描历史库
 }
 
 // Task 扫描任务
 type Task struct {
-	ID         string       `json:"id"`          // 任务ID
-	Status     string       `json:"status"`      // 任务状态
-	CreateTime time.Time    `json:"create_time"` // 创建时间
-	StartTime  *time.Time   `json:"start_time"`  // 开始时间
-	EndTime    *time.Time   `json:"end_time"`    // 结束时间
-	Request    *ScanRequest `json:"request"`     // 扫描请求
-	Result     *ScanResult  `json:"result"`      // 扫描结果
-	Error      string       `json:"error"`       // 错误信息
+	ID         string       `json:"id"`                    // 任务ID
+	Status     string       `json:"status"`                // 任务状态
+	CreateTime time.Time    `json:"create_time"`           // 创建时间
+	StartTime  *time.Time   `json:"start_time"`            // 开始时间
+	EndTime    *time.Time   `json:"end_time"`              // 结束时间
+	Request    *ScanRequest `json:"request"`               // 扫描请求
+	Result     *ScanResult  `json:"result"`                // 扫描结果
+	Error      string       `json:"error"`                 // 错误信息
+	ScheduleID string       `json:"schedule_id,omitempty"` // 创建任务的定时计划ID
 }
 
 // ScanRequest 扫描请求
 type ScanRequest struct {
-	Target           string        `json:"target"`            // 目标
-	Ports            string        `json:"ports"`             // 端口
-	ScanType         string        `json:"scan_type"`         // 扫描类型
-	Timeout          time.Duration `json:"timeout"`           // 超时时间
-	Workers          int           `json:"workers"`           // 工作线程数
-	OutputFormat     string        `json:"output_format"`     // 输出格式
-	PrettyOutput     bool          `json:"pretty_output"`     // 美化输出
-	EnableOS         bool          `json:"enable_os"`         // 启用操作系统检测
-	EnableService    bool          `json:"enable_service"`    // 启用服务检测
-	VersionIntensity int           `json:"version_intensity"` // 版本检测强度
-	GuessOS          bool          `json:"guess_os"`          // 推测操作系统
-	LimitOSScan      bool          `json:"limit_os_scan"`     // 限制操作系统扫描
+	Target           string        `json:"target"`             // 目标
+	Ports            string        `json:"ports"`              // 端口
+	ScanType         string        `json:"scan_type"`          // 扫描类型
+	Timeout          time.Duration `json:"timeout"`            // 超时时间
+	Workers          int           `json:"workers"`            // 工作线程数
+	OutputFormat     string        `json:"output_format"`      // 输出格式
+	PrettyOutput     bool          `json:"pretty_output"`      // 美化输出
+	EnableOS         bool          `json:"enable_os"`          // 启用操作系统检测
+	EnableService    bool          `json:"enable_service"`     // 启用服务检测
+	VersionIntensity int           `json:"version_intensity"`  // 版本检测强度
+	GuessOS          bool          `json:"guess_os"`           // 推测操作系统
+	LimitOSScan      bool          `json:"limit_os_scan"`      // 限制操作系统扫描
+	HostTimeout      time.Duration `json:"host_timeout"`       // 单主机扫描总超时
+	MaxRetries       int           `json:"max_retries"`        // 端口超时后的最大重试次数
+	DetectTarpit     bool          `json:"detect_tarpit"`      // 启用tarpit检测，几乎所有端口开放时放弃该主机
+	HoneypotLimit    int           `json:"honeypot_threshold"` // 蜜罐评分达到该值时排除端口结果，0表示不过滤
 }
 
 // ScanResult 扫描结果
 type ScanResult struct {
-	TaskID    string    `json:"task_id"`    // 任务ID
-	Status    string    `json:"status"`     // 状态
-	Progress  float64   `json:"progress"`   // 进度
-	StartTime time.Time `json:"start_time"` // 开始时间
-	EndTime   time.Time `json:"end_time"`   // 结束时间
-	Result    string    `json:"result"`     // 结果数据
+	TaskID    string                 `json:"task_id"`            // 任务ID
+	Status    string                 `json:"status"`             // 状态
+	Progress  float64                `json:"progress"`           // 进度
+	StartTime time.Time              `json:"start_time"`         // 开始时间
+	EndTime   time.Time              `json:"end_time"`           // 结束时间
+	Result    string                 `json:"result"`             // 结果数据
+	Host      *scanner.HostResult    `json:"host,omitempty"`     // 以主机为中心的结构化结果
+	Honeypot  *scanner.HoneypotScore `json:"honeypot,omitempty"` // 蜜罐/tarpit可能性评分
 }
 
 // NewServer 创建新的API服务器
@@ -92,6 +113,8 @@ func NewServer(config *ServerConfig) *Server {
 		ctx:       ctx,
 		cancel:    cancel,
 		inmemory:  config.RedisAddr == "", // 如果Redis地址为空，则使用内存存储
+
+		coordinator: NewCoordinator(config.LeaseTimeout),
 	}
 
 	// 初始化Redis客户端
@@ -123,6 +146,31 @@ func NewServer(config *ServerConfig) *Server {
 		}
 	}
 
+	// 初始化定时扫描调度器，计划与任务使用相同的存储
+	var store StorageInterface = NewMemoryStorage()
+	if !server.inmemory {
+		store = &redisAdapter{client: server.redis}
+	}
+	server.scheduler = NewScheduler(store, server.submitScheduledTask)
+	if err := server.scheduler.Load(ctx); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+	server.changes = NewChangeFeed(store)
+	if err := server.changes.Load(ctx); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+
+	// 打开扫描历史库，完成的任务和分布式任务都记录到历史中
+	if config.HistoryPath != "" {
+		store, err := history.Open(config.HistoryPath)
+		if err != nil {
+			fmt.Printf("警告: %v, 将不记录扫描历史\n", err)
+		} else {
+			server.history = store
+			server.coordinator.onFinish = server.recordJobHistory
+		}
+	}
+
 	// 设置中间件
 	server.setupMiddlewares()
 
@@ -131,6 +179,10 @@ func NewServer(config *ServerConfig) *Server {
 
 	// 启动工作线程
 	go server.processTaskQueue()
+	go server.scheduler.Run(ctx)
+	if config.RulesReload > 0 {
+		go server.watchRules(ctx, config.RulesReload)
+	}
 
 	return server
 }
@@ -183,6 +235,7 @@ func (s *Server) setupRoutes() {
 		scan := v1.Group("/scan")
 		{
 			scan.POST("/", s.handleCreateScanTask)
+			scan.POST("/plan", s.handlePlanScan)
 			scan.GET("/tasks", s.handleListTasks)
 			scan.GET("/tasks/:id", s.handleGetTask)
 			scan.DELETE("/tasks/:id", s.handleCancelTask)
@@ -195,6 +248,66 @@ func (s *Server) setupRoutes() {
 			system.GET("/status", s.handleSystemStatus)
 			system.GET("/metrics", s.handleSystemMetrics)
 		}
+
+		// 定时扫描计划
+		schedules := v1.Group("/schedules")
+		{
+			schedules.POST("", s.handleCreateSchedule)
+			schedules.GET("", s.handleListSchedules)
+			schedules.GET("/:id", s.handleGetSchedule)
+			schedules.PUT("/:id", s.handleUpdateSchedule)
+			schedules.DELETE("/:id", s.handleDeleteSchedule)
+			schedules.POST("/:id/run", s.handleRunSchedule)
+		}
+
+		// 监控计划发现的变化
+		v1.GET("/changes", s.handleListChanges)
+
+		// 扫描历史
+		historyGroup := v1.Group("/history")
+		{
+			historyGroup.GET("", s.handleQueryHistory)
+			historyGroup.GET("/scans", s.handleListHistoryScans)
+		}
+
+		// 用户服务规则
+		rules := v1.Group("/fingerprint/rules")
+		{
+			rules.GET("", s.handleListRules)
+			rules.POST("/reload", s.handleReloadRules)
+		}
+
+		// 分布式扫描：代理接口使用代理令牌认证
+		agent := v1.Group("/agent", s.agentAuthMiddleware())
+		{
+			agent.POST("/register", s.handleRegisterAgent)
+			agent.POST("/:id/heartbeat", s.handleAgentHeartbeat)
+			agent.GET("/:id/work", s.handleAgentWork)
+			agent.POST("/:id/results", s.handleAgentResult)
+		}
+
+		// 分布式扫描：任务管理
+		v1.GET("/agents", s.handleListAgents)
+		jobs := v1.Group("/jobs")
+		{
+			jobs.POST("", s.handleCreateJob)
+			jobs.GET("", s.handleListJobs)
+			jobs.GET("/:id", s.handleGetJob)
+		}
+	}
+}
+
+// agentAuthMiddleware 代理认证中间件，校验X-Agent-Token请求头
+func (s *Server) agentAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(AgentTokenHeader)
+		if s.config.AgentToken == "" || token == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AgentToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的代理令牌"})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
 }
 
@@ -217,9 +330,10 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 // authMiddleware JWT认证中间件
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 跳过登录和刷新token接口的认证
+		// 跳过登录和刷新token接口的认证，代理接口使用单独的令牌认证
 		if c.Request.URL.Path == "/api/v1/auth/login" ||
-			c.Request.URL.Path == "/api/v1/auth/refresh" {
+			c.Request.URL.Path == "/api/v1/auth/refresh" ||
+			strings.HasPrefix(c.Request.URL.Path, "/api/v1/agent/") {
 			c.Next()
 			return
 		}
@@ -265,7 +379,12 @@ func (s *Server) processTaskQueue() {
 		select {
 		case <-s.ctx.Done():
 			return
-		case task := <-s.taskQueue:
+		case task, ok := <-s.taskQueue:
+			// Stop关闭队列后不再取任务
+			if !ok {
+				return
+			}
+
 			// 获取工作线程令牌
 			s.workers <- struct{}{}
 
@@ -294,11 +413,42 @@ func (s *Server) processTaskQueue() {
 					task.Result = result
 				}
 				s.updateTask(task)
+
+				// 记录扫描历史，监控计划与基线比较
+				s.recordTaskHistory(task)
+				s.recordChanges(task)
 			}(task)
 		}
 	}
 }
 
+// enqueueTask 将任务加入队列，队列已满时返回false
+func (s *Server) enqueueTask(task *Task) bool {
+	select {
+	case s.taskQueue <- task:
+		s.updateTask(task)
+		return true
+	default:
+		return false
+	}
+}
+
+// submitScheduledTask 为到期的计划创建普通扫描任务
+func (s *Server) submitScheduledTask(schedule *Schedule) (string, error) {
+	req := schedule.Request
+	task := &Task{
+		ID:         uuid.New().String(),
+		Status:     "pending",
+		CreateTime: time.Now(),
+		Request:    &req,
+		ScheduleID: schedule.ID,
+	}
+	if !s.enqueueTask(task) {
+		return "", fmt.Errorf("任务队列已满")
+	}
+	return task.ID, nil
+}
+
 // updateTask 更新任务状态
 func (s *Server) updateTask(task *Task) {
 	// 更新内存中的任务状态
@@ -347,6 +497,11 @@ func (r *redisAdapter) ScanKeys(ctx context.Context, pattern string) ([]string,
 	return keys, nil
 }
 
+// Delete 删除键
+func (r *redisAdapter) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
 // Close 关闭
 func (r *redisAdapter) Close() error {
 	return r.client.Close()
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"testing"
 	"time"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -159,3 +162,48 @@ func (r *httpRecorder) WriteHeader(code int) {
 func (r *httpRecorder) Flush() {
 	r.Flushed = true
 }
+
+func TestScanOptionsFromRequestTarpitDefault(t *testing.T) {
+	var req ScanRequest
+	assert.NoError(t, json.Unmarshal([]byte(`{"target":"192.0.2.1","ports":"80"}`), &req))
+	// 与命令行的--detect-tarpit一致，未设置时不启用，避免放弃开放端口多的正常主机
+	assert.False(t, scanOptionsFromRequest(&req).DetectTarpit)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"detect_tarpit":true}`), &req))
+	assert.True(t, scanOptionsFromRequest(&req).DetectTarpit)
+}
+
+// registryScanner 注册到扫描器注册表的测试扫描器
+type registryScanner struct{}
+
+func (r *registryScanner) Scan(ctx context.Context, opts *scanner.ScanOptions) ([]scanner.ScanResult, error) {
+	return []scanner.ScanResult{{Port: 4242, State: scanner.PortStateOpen, Type: opts.ScanType}}, nil
+}
+
+func (r *registryScanner) ValidateOptions(opts *scanner.ScanOptions) error { return nil }
+
+func (r *registryScanner) RequiresRoot() bool { return false }
+
+func TestExecuteScanUsesRegistry(t *testing.T) {
+	scanType := scanner.ScanType("api-registry-test")
+	assert.NoError(t, scanner.RegisterScanner(scanType, func() scanner.BaseScanner { return &registryScanner{} }, scanner.ScannerCapabilities{
+		Protocol:    "tcp",
+		Description: "测试扫描器",
+	}))
+
+	// 扫描任务经注册表分派到对应的扫描器
+	server := &Server{}
+	req := &ScanRequest{Target: "127.0.0.1", Ports: "4242", ScanType: string(scanType)}
+	assert.NoError(t, server.validateScanRequest(req))
+	result, err := server.executeScan(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", result.Status)
+	if assert.NotNil(t, result.Host) {
+		ports := result.Host.Ports()
+		if assert.Len(t, ports, 1) {
+			assert.Equal(t, 4242, ports[0].Port)
+			assert.Equal(t, scanner.PortStateOpen, ports[0].State)
+		}
+	}
+	assert.Contains(t, result.Result, "4242")
+}
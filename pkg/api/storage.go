@@ -14,6 +14,7 @@ type StorageInterface interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
 	ScanKeys(ctx context.Context, pattern string) ([]string, error)
+	Delete(ctx context.Context, key string) error
 	Close() error
 }
 
@@ -96,6 +97,15 @@ func (m *MemoryStorage) ScanKeys(ctx context.Context, pattern string) ([]string,
 	return matchedKeys, nil
 }
 
+// Delete 删除键
+func (m *MemoryStorage) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.data, key)
+	return nil
+}
+
 // Close 关闭存储
 func (m *MemoryStorage) Close() error {
 	m.mutex.Lock()
@@ -36,6 +36,11 @@ type Config struct {
 	} `mapstructure:"output"`
 
 	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	Fingerprint struct {
+		DataDir  string `mapstructure:"data_dir"`  // nmap指纹数据目录，为空时使用内置数据
+		RulesDir string `mapstructure:"rules_dir"` // 用户服务规则目录，为空时使用数据目录下的rules
+	} `mapstructure:"fingerprint"`
 }
 
 // MetricsConfig 指标配置结构体
@@ -121,6 +126,10 @@ func setDefaults() {
 	viper.SetDefault("metrics.enabled", false)
 	viper.SetDefault("metrics.address", ":9090")
 	viper.SetDefault("metrics.path", "/metrics")
+
+	// 指纹库配置默认值
+	viper.SetDefault("fingerprint.data_dir", "")
+	viper.SetDefault("fingerprint.rules_dir", "")
 }
 
 // EnsureConfigDir 确保配置目录存在
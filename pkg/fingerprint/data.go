@@ -2,93 +2,163 @@ package fingerprint
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
+	"sync"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
 )
 
 //go:embed data/nmap-service-probes data/nmap-os-db data/nmap-services
 var embeddedData embed.FS
 
-// 记录已经提取的临时目录，以便程序退出时清理
-var extractedTempDirs []string
+// loadedDB 一个数据目录对应的指纹库，只加载一次
+type loadedDB struct {
+	once sync.Once
+	db   *nmap.NmapDB
+	err  error
+}
 
-// ExtractEmbeddedData 提取嵌入的Nmap指纹数据到临时目录
-func ExtractEmbeddedData() (string, error) {
-	// 创建临时目录
-	tempDir, err := os.MkdirTemp("", "go-port-rocket-fingerprints")
+var (
+	dbMu    sync.Mutex
+	dbCache = make(map[string]*loadedDB) // 数据目录 -> 指纹库，空字符串表示嵌入的数据
+	dataDir string                       // 默认数据目录，为空时使用嵌入的数据
+)
+
+// EmbeddedData 返回嵌入的nmap数据文件，文件位于根目录下
+func EmbeddedData() fs.FS {
+	sub, err := fs.Sub(embeddedData, "data")
 	if err != nil {
-		return "", fmt.Errorf("创建临时目录失败: %v", err)
+		// data目录由go:embed保证存在
+		panic(err)
 	}
+	return sub
+}
 
-	// 添加到临时目录列表中，以便退出时清理
-	extractedTempDirs = append(extractedTempDirs, tempDir)
+// LoadDB 返回dir目录下的指纹库，dir为空时使用嵌入的数据
+// 每个目录在进程内只解析一次，返回的指纹库只读，由所有goroutine共享
+func LoadDB(dir string) (*nmap.NmapDB, error) {
+	dir = cacheKey(dir)
 
-	// 提取所有嵌入的文件
-	err = fs.WalkDir(embeddedData, "data", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	dbMu.Lock()
+	entry, ok := dbCache[dir]
+	if !ok {
+		entry = &loadedDB{}
+		dbCache[dir] = entry
+	}
+	dbMu.Unlock()
 
-		// 跳过根目录
-		if path == "data" {
-			return nil
+	entry.once.Do(func() {
+		if dir == "" {
+			entry.db, entry.err = loadEmbeddedDB()
+		} else {
+			entry.db, entry.err = loadDirDB(dir)
 		}
+	})
+	return entry.db, entry.err
+}
 
-		// 获取目标路径
-		relPath, err := filepath.Rel("data", path)
-		if err != nil {
-			return err
-		}
-		destPath := filepath.Join(tempDir, relPath)
+// loadEmbeddedDB 解析嵌入的数据，版本号带有embedded-前缀以便与导入的指纹库区分
+func loadEmbeddedDB() (*nmap.NmapDB, error) {
+	db, err := nmap.LoadNmapDBFS(EmbeddedData(), ".")
+	if err != nil {
+		return nil, err
+	}
+	db.Version = "embedded-" + db.Version
+	return db, nil
+}
+
+// loadDirDB 解析dir目录下的指纹库，目录中缺少的文件使用嵌入的数据补齐
+func loadDirDB(dir string) (*nmap.NmapDB, error) {
+	fsys, err := dataFS(dir)
+	if err != nil {
+		return nil, err
+	}
+	return nmap.LoadNmapDBFS(fsys, ".")
+}
 
-		// 如果是目录，创建它
-		if d.IsDir() {
-			return os.MkdirAll(destPath, 0755)
+// overlayFS 优先读取目录中的文件，不存在时读取嵌入的数据
+type overlayFS struct {
+	dir      fs.FS
+	fallback fs.FS
+}
+
+// Open 实现fs.FS接口
+func (o overlayFS) Open(name string) (fs.File, error) {
+	file, err := o.dir.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.fallback.Open(name)
+	}
+	return file, err
+}
+
+// dataFS 返回dir目录的指纹数据，目录中至少要有探测规则或操作系统指纹之一
+func dataFS(dir string) (fs.FS, error) {
+	for _, name := range []string{nmap.ServiceProbesFile, nmap.OSDBFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return overlayFS{dir: os.DirFS(dir), fallback: EmbeddedData()}, nil
 		}
+	}
+	return nil, fmt.Errorf("%s中没有%s或%s", dir, nmap.ServiceProbesFile, nmap.OSDBFile)
+}
 
-		// 读取文件内容
-		data, err := embeddedData.ReadFile(path)
-		if err != nil {
-			return err
+// cacheKey 返回数据目录在缓存中的键
+func cacheKey(dir string) string {
+	if dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			return abs
 		}
+	}
+	return dir
+}
 
-		// 写入文件
-		return os.WriteFile(destPath, data, 0644)
-	})
+// forgetDB 丢弃数据目录已加载的指纹库，目录内容更新后下次LoadDB重新解析
+func forgetDB(dir string) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	delete(dbCache, cacheKey(dir))
+}
 
-	if err != nil {
-		os.RemoveAll(tempDir) // 清理临时目录
-		return "", err
-	}
+// SetDataDir 设置默认的指纹数据目录，NewFingerprinter("")将使用该目录，为空时恢复为嵌入的数据
+func SetDataDir(dir string) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	dataDir = dir
+}
 
-	return tempDir, nil
+// DataDir 返回默认的指纹数据目录，为空表示使用嵌入的数据
+func DataDir() string {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	return dataDir
 }
 
-// GetEmbeddedFingerprintPath 获取嵌入指纹数据的路径
-func GetEmbeddedFingerprintPath() (string, error) {
-	return ExtractEmbeddedData()
+// UserDataDir 返回fingerprint import导入指纹库的默认目录
+func UserDataDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".go-port-rocket", "fingerprints")
 }
 
-// CleanupTempDirs 清理所有创建的临时目录
-func CleanupTempDirs() {
-	for _, dir := range extractedTempDirs {
-		os.RemoveAll(dir)
+// ActiveDB 返回扫描使用的指纹库：ActiveDataDir下的nmap数据加上RulesDir中的用户规则
+func ActiveDB() (*nmap.NmapDB, error) {
+	db, err := LoadDB(ActiveDataDir())
+	if err != nil {
+		return nil, err
 	}
-	extractedTempDirs = nil
+	return withUserRules(db), nil
 }
 
-// init 注册程序退出时的清理函数
-func init() {
-	// 确保在程序退出时清理临时目录
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		CleanupTempDirs()
-		os.Exit(0)
-	}()
+// ActiveDataDir 返回NewFingerprinter("")实际使用的数据目录
+// 依次为SetDataDir设置的目录、已导入指纹库的用户目录，都没有时返回空字符串表示嵌入的数据
+func ActiveDataDir() string {
+	if dir := DataDir(); dir != "" {
+		return dir
+	}
+	dir := UserDataDir()
+	if _, err := os.Stat(filepath.Join(dir, nmap.ServiceProbesFile)); err == nil {
+		return dir
+	}
+	return ""
 }
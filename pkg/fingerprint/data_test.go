@@ -0,0 +1,68 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDBShared(t *testing.T) {
+	// 并发获取嵌入的指纹库得到同一个实例
+	dbs := make([]*nmap.NmapDB, 8)
+	var wg sync.WaitGroup
+	for i := range dbs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := LoadDB("")
+			assert.NoError(t, err)
+			dbs[i] = db
+		}(i)
+	}
+	wg.Wait()
+	for _, db := range dbs {
+		assert.Same(t, dbs[0], db)
+	}
+
+	a, err := NewFingerprinter("")
+	require.NoError(t, err)
+	b, err := NewFingerprinter("")
+	require.NoError(t, err)
+	assert.Same(t, a.db, b.db)
+	assert.NotEmpty(t, a.db.ServiceProbes)
+	assert.NotNil(t, a.db.OS)
+}
+
+func TestLoadDBDataDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nmap-service-probes"),
+		[]byte("Probe TCP NULL q||\nmatch custom m|^CUSTOM| p/Custom/\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nmap-os-db"),
+		[]byte("MatchPoints\nT1(R=100)\n\nFingerprint Custom OS\nT1(R=Y)\n"), 0644))
+
+	SetDataDir(dir)
+	defer SetDataDir("")
+
+	// 配置的数据目录替代嵌入的数据，同一目录只加载一次
+	f, err := NewFingerprinter("")
+	require.NoError(t, err)
+	require.Len(t, f.db.ServiceProbes, 1)
+	require.Len(t, f.db.OS.Fingerprints, 1)
+	assert.Equal(t, "Custom OS", f.db.OS.Fingerprints[0].Name)
+
+	same, err := LoadDB(dir)
+	require.NoError(t, err)
+	assert.Same(t, f.db, same)
+
+	// 目录无法加载时回退到嵌入的数据
+	embedded, err := LoadDB("")
+	require.NoError(t, err)
+	f, err = NewFingerprinter(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Same(t, embedded, f.db)
+}
@@ -2,6 +2,8 @@ package fingerprint
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"time"
 
 	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
@@ -9,47 +11,41 @@ import (
 
 // Fingerprinter 指纹识别器
 type Fingerprinter struct {
-	opts *FingerprintOptions
-	db   *nmap.NmapDB
+	opts     *FingerprintOptions
+	db       *nmap.NmapDB
+	observer FingerprintObserver
+}
+
+// FingerprintObserver 指纹识别结果观察者
+type FingerprintObserver interface {
+	// OnServiceFingerprint 服务识别成功时调用
+	OnServiceFingerprint(target string, port int, fp *ServiceFingerprint)
+	// OnOSFingerprint 操作系统识别成功时调用
+	OnOSFingerprint(target string, fp *OSFingerprint)
 }
 
 // NewFingerprinter 创建新的指纹识别器
+// nmapSharePath为空时使用ActiveDataDir：SetDataDir设置的目录、已导入的用户指纹库或嵌入的指纹数据；
+// 指纹库在进程内只加载一次，多个识别器共享同一份只读数据；RulesDir中的用户规则在nmap探测之前使用
 func NewFingerprinter(nmapSharePath string) (*Fingerprinter, error) {
-	var db *nmap.NmapDB
-	var err error
-
-	// 如果用户没有提供Nmap共享目录路径，则使用嵌入的指纹数据
 	if nmapSharePath == "" {
-		tempDir, err := GetEmbeddedFingerprintPath()
-		if err != nil {
-			return nil, fmt.Errorf("提取嵌入的指纹数据失败: %v", err)
-		}
+		nmapSharePath = ActiveDataDir()
+	}
 
-		// 使用临时目录中的嵌入指纹数据
-		db, err = nmap.LoadNmapDB(tempDir)
-		if err != nil {
-			return nil, fmt.Errorf("加载嵌入的指纹数据库失败: %v", err)
-		}
-	} else {
-		// 用户提供了Nmap路径，尝试加载
-		db, err = nmap.LoadNmapDB(nmapSharePath)
-		if err != nil {
-			// 如果加载失败，回退到嵌入的指纹数据
-			tempDir, tempErr := GetEmbeddedFingerprintPath()
-			if tempErr != nil {
-				return nil, fmt.Errorf("加载Nmap指纹数据库失败: %v, 同时无法提取嵌入的指纹数据: %v", err, tempErr)
-			}
-
-			db, err = nmap.LoadNmapDB(tempDir)
-			if err != nil {
-				return nil, fmt.Errorf("加载Nmap指纹数据库失败: %v", err)
-			}
+	db, err := LoadDB(nmapSharePath)
+	if err != nil && nmapSharePath != "" {
+		// 用户提供的目录加载失败时回退到嵌入的指纹数据
+		var embeddedErr error
+		if db, embeddedErr = LoadDB(""); embeddedErr != nil {
+			return nil, fmt.Errorf("加载Nmap指纹数据库失败: %v, 同时无法加载嵌入的指纹数据: %v", err, embeddedErr)
 		}
+	} else if err != nil {
+		return nil, fmt.Errorf("加载嵌入的指纹数据库失败: %v", err)
 	}
 
 	return &Fingerprinter{
 		opts: DefaultFingerprintOptions(),
-		db:   db,
+		db:   withUserRules(db),
 	}, nil
 }
 
@@ -58,6 +54,11 @@ func (f *Fingerprinter) SetOptions(opts *FingerprintOptions) {
 	f.opts = opts
 }
 
+// SetObserver 设置指纹识别结果观察者
+func (f *Fingerprinter) SetObserver(observer FingerprintObserver) {
+	f.observer = observer
+}
+
 // GetOptions 获取指纹识别选项
 func (f *Fingerprinter) GetOptions() *FingerprintOptions {
 	return f.opts
@@ -69,16 +70,28 @@ func (f *Fingerprinter) FingerprintOS(target string, ports []int) (*OSFingerprin
 		return nil, fmt.Errorf("操作系统检测已禁用")
 	}
 
-	// 1. 收集探测结果
-	probes, err := f.probeOS(target, ports)
+	// 1. 收集探测结果：有原始报文传输时发送nmap第二代探测，否则使用连接探测
+	var probes []ProbeResult
+	var err error
+	if f.opts.Transport != nil {
+		probes, err = f.probeOSRaw(target, ports)
+	} else {
+		probes, err = f.probeOS(target, ports)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("操作系统探测失败: %v", err)
 	}
 
+	return f.MatchOSProbes(target, probes)
+}
+
+// MatchOSProbes 使用已有的探测结果匹配操作系统指纹，不发起网络探测
+func (f *Fingerprinter) MatchOSProbes(target string, probes []ProbeResult) (*OSFingerprint, error) {
 	// 2. 生成指纹
 	fp := &OSFingerprint{
 		Features:    make(map[string]string),
 		Probes:      probes,
+		DBVersion:   f.db.Version,
 		LastUpdated: time.Now(),
 	}
 
@@ -91,52 +104,123 @@ func (f *Fingerprinter) FingerprintOS(target string, ports []int) (*OSFingerprin
 		return nil, fmt.Errorf("指纹匹配失败: %v", err)
 	}
 
-	// 5. 选择最佳匹配
-	if len(matches) > 0 {
-		bestMatch := matches[0]
-		fp.Name = bestMatch.Name
-		fp.Version = bestMatch.Features["version"]
-		fp.Confidence = 0.9 // TODO: 根据匹配规则计算置信度
+	// 5. 按匹配度生成候选结果，最佳匹配作为识别结果
+	for i, m := range matches {
+		if i == maxOSGuesses {
+			break
+		}
+		fp.Guesses = append(fp.Guesses, osGuessFromMatch(m))
+	}
+	if len(fp.Guesses) > 0 {
+		best := fp.Guesses[0]
+		fp.Name = best.Name
+		fp.Version = best.Generation
+		fp.Family = best.Family
+		fp.CPE = best.CPE
+		fp.Confidence = best.Accuracy
+		if f.observer != nil {
+			f.observer.OnOSFingerprint(target, fp)
+		}
 	}
 
 	return fp, nil
 }
 
+// maxOSGuesses 最多保留的操作系统候选数
+const maxOSGuesses = 10
+
+// osGuessFromMatch 将参考指纹的匹配结果转换为候选结果，分类取第一个Class
+func osGuessFromMatch(m *nmap.OSMatch) OSGuess {
+	guess := OSGuess{
+		Name:     m.Reference.Name,
+		Accuracy: math.Round(m.Accuracy*1000) / 10,
+		CPE:      m.Reference.CPE(),
+	}
+	if len(m.Reference.Classes) > 0 {
+		class := m.Reference.Classes[0]
+		guess.Vendor = class.Vendor
+		guess.Family = class.Family
+		guess.Generation = class.Generation
+		guess.DeviceType = class.DeviceType
+	}
+	return guess
+}
+
 // FingerprintService 执行服务指纹识别
 func (f *Fingerprinter) FingerprintService(target string, port int) (*ServiceFingerprint, error) {
+	return f.DetectVersion(target, port, "tcp")
+}
+
+// DetectVersion 使用指纹库中的探测规则识别端口上的服务版本
+func (f *Fingerprinter) DetectVersion(target string, port int, protocol string) (*ServiceFingerprint, error) {
 	if !f.opts.EnableServiceDetection {
 		return nil, fmt.Errorf("服务检测已禁用")
 	}
 
-	// 1. 收集探测结果
-	probes, err := f.probeService(target, port)
+	// 1. 按探测顺序发送探测并匹配响应
+	engine := &versionEngine{db: f.db, opts: f.opts}
+	result, err := engine.run(target, port, protocol)
 	if err != nil {
 		return nil, fmt.Errorf("服务探测失败: %v", err)
 	}
 
+	// 2. 生成指纹
+	fp := &ServiceFingerprint{
+		Features:    make(map[string]string),
+		Probes:      result.probes,
+		DBVersion:   f.db.Version,
+		LastUpdated: time.Now(),
+	}
+	f.extractFeatures(fp)
+
+	// 3. 填充匹配结果
+	if result.match != nil {
+		applyServiceMatch(fp, result.match)
+		fp.Tunnel = result.tunnel
+		if f.observer != nil {
+			f.observer.OnServiceFingerprint(target, port, fp)
+		}
+	}
+
+	// 4. 没有硬匹配时记录各探测的响应，供提交新指纹或生成用户规则
+	if result.match == nil || result.match.Soft {
+		fp.Record = newServiceRecord(port, protocol, result.probes, f.db.Version, f.opts.VersionIntensity)
+	}
+
+	return fp, nil
+}
+
+// MatchServiceProbes 使用已有的探测结果匹配服务指纹，不发起网络探测
+func (f *Fingerprinter) MatchServiceProbes(target string, port int, probes []ProbeResult) (*ServiceFingerprint, error) {
 	// 2. 生成指纹
 	fp := &ServiceFingerprint{
 		Features:    make(map[string]string),
 		Probes:      probes,
+		DBVersion:   f.db.Version,
 		LastUpdated: time.Now(),
 	}
 
 	// 3. 提取特征
 	f.extractFeatures(fp)
 
-	// 4. 匹配指纹
-	matches, err := f.db.MatchService(fp.Features)
-	if err != nil {
-		return nil, fmt.Errorf("指纹匹配失败: %v", err)
+	// 4. 使用探测规则匹配各个响应，硬匹配优先于软匹配
+	var best *nmap.ServiceMatch
+	for _, probe := range probes {
+		m := f.db.MatchResponse(probe.Protocol, port, probe.Response)
+		if m != nil && (best == nil || (best.Soft && !m.Soft)) {
+			best = m
+		}
+		if best != nil && !best.Soft {
+			break
+		}
 	}
 
-	// 5. 选择最佳匹配
-	if len(matches) > 0 {
-		bestMatch := matches[0]
-		fp.Name = bestMatch.Name
-		fp.Version = bestMatch.Features["version"]
-		fp.Product = bestMatch.Features["product"]
-		fp.Confidence = 0.9 // TODO: 根据匹配规则计算置信度
+	// 5. 填充版本信息
+	if best != nil {
+		applyServiceMatch(fp, best)
+		if f.observer != nil {
+			f.observer.OnServiceFingerprint(target, port, fp)
+		}
 	}
 
 	return fp, nil
@@ -184,81 +268,39 @@ func (f *Fingerprinter) probeOS(target string, ports []int) ([]ProbeResult, erro
 	return results, nil
 }
 
-// probeService 探测服务
-func (f *Fingerprinter) probeService(target string, port int) ([]ProbeResult, error) {
-	results := make([]ProbeResult, 0)
+// probeOSRaw 通过原始报文发送nmap第二代操作系统探测
+// 需要一个开放的TCP端口，关闭端口未指定时随机选择不在开放端口中的高端口
+func (f *Fingerprinter) probeOSRaw(target string, ports []int) ([]ProbeResult, error) {
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("需要开放的端口才能进行操作系统探测")
+	}
+	closedTCP := f.opts.ClosedTCPPort
+	if closedTCP == 0 {
+		closedTCP = randomClosedPort(ports)
+	}
+	closedUDP := f.opts.ClosedUDPPort
+	if closedUDP == 0 {
+		closedUDP = randomClosedPort(ports)
+	}
 
-	// 根据端口选择探测方法
-	switch port {
-	case 21:
-		probes, err := f.probeFTP(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 22:
-		probes, err := f.probeSSH(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 23:
-		probes, err := f.probeTelnet(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 25:
-		probes, err := f.probeSMTP(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 80, 443, 8080:
-		probes, err := f.probeHTTP(target, port)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 3306:
-		probes, err := f.probeMySQL(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 5432:
-		probes, err := f.probePostgreSQL(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 6379:
-		probes, err := f.probeRedis(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 27017:
-		probes, err := f.probeMongoDB(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	case 9200:
-		probes, err := f.probeElasticsearch(target)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
-	default:
-		probes, err := f.probeGeneric(target, port)
-		if err != nil {
-			return nil, err
-		}
-		results = append(results, probes...)
+	engine, err := newOSEngine(f.opts.Transport, target, ports[0], closedTCP, closedUDP, f.opts.Timeout)
+	if err != nil {
+		return nil, err
 	}
+	return engine.run()
+}
 
-	return results, nil
+// randomClosedPort 随机选择一个不在ports中的高端口
+func randomClosedPort(ports []int) int {
+	used := make(map[int]bool, len(ports))
+	for _, port := range ports {
+		used[port] = true
+	}
+	for {
+		if port := 30000 + rand.Intn(30000); !used[port] {
+			return port
+		}
+	}
 }
 
 // extractFeatures 从探测结果中提取特征
@@ -272,7 +314,15 @@ func (f *Fingerprinter) extractFeatures(fp interface{}) {
 }
 
 // extractOSFeatures 提取操作系统特征
+// nmap第二代探测的结果计算为测试属性，以测试名为键、属性字符串为值
 func (f *Fingerprinter) extractOSFeatures(fp *OSFingerprint) {
+	if tests := computeOSTests(fp.Probes); len(tests) > 0 {
+		for name, attrs := range tests.Strings() {
+			fp.Features[name] = attrs
+		}
+		return
+	}
+
 	for _, probe := range fp.Probes {
 		// 提取TCP序列号特征
 		if probe.Type == "SEQ" {
@@ -301,31 +351,11 @@ func (f *Fingerprinter) extractOSFeatures(fp *OSFingerprint) {
 	}
 }
 
-// extractServiceFeatures 提取服务特征
+// extractServiceFeatures 提取服务特征，每个探测的响应以探测类型为键
 func (f *Fingerprinter) extractServiceFeatures(fp *ServiceFingerprint) {
 	for _, probe := range fp.Probes {
-		// 提取服务特征
-		switch probe.Type {
-		case "FTP":
-			fp.Features["ftp"] = probe.Features["ftp"]
-		case "SSH":
-			fp.Features["ssh"] = probe.Features["ssh"]
-		case "Telnet":
-			fp.Features["telnet"] = probe.Features["telnet"]
-		case "SMTP":
-			fp.Features["smtp"] = probe.Features["smtp"]
-		case "HTTP":
-			fp.Features["http"] = probe.Features["http"]
-		case "MySQL":
-			fp.Features["mysql"] = probe.Features["mysql"]
-		case "PostgreSQL":
-			fp.Features["postgresql"] = probe.Features["postgresql"]
-		case "Redis":
-			fp.Features["redis"] = probe.Features["redis"]
-		case "MongoDB":
-			fp.Features["mongodb"] = probe.Features["mongodb"]
-		case "Elasticsearch":
-			fp.Features["elasticsearch"] = probe.Features["elasticsearch"]
+		for key, value := range probe.Features {
+			fp.Features[key] = value
 		}
 	}
 }
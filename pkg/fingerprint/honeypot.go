@@ -0,0 +1,63 @@
+package fingerprint
+
+import (
+	"bytes"
+	_ "embed"
+	"sync"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+)
+
+// honeypotData 已知蜜罐的banner签名，与服务指纹库分开存放，不参与服务识别
+//
+//go:embed data/honeypot-signatures
+var honeypotData []byte
+
+// BannerSignature 蜜罐签名列表中的一条match规则
+type BannerSignature struct {
+	Service string          // 服务名称
+	Product string          // 产品名称(p//)
+	Info    string          // 附加信息(i//)
+	Rule    *nmap.MatchRule // 匹配规则
+}
+
+var (
+	honeypotOnce       sync.Once
+	honeypotSignatures []BannerSignature
+)
+
+// HoneypotSignatures 返回内置的蜜罐banner签名
+// 签名文件使用nmap-service-probes格式，无法转换为Go正则的规则会被跳过
+func HoneypotSignatures() []BannerSignature {
+	honeypotOnce.Do(func() {
+		sp, err := nmap.ParseServiceProbes(bytes.NewReader(honeypotData))
+		if err != nil {
+			return
+		}
+		for _, probe := range sp.Probes {
+			for _, rule := range probe.Matches {
+				honeypotSignatures = append(honeypotSignatures, BannerSignature{
+					Service: rule.Service,
+					Product: rule.Template.Product,
+					Info:    rule.Template.Info,
+					Rule:    rule,
+				})
+			}
+		}
+	})
+	return honeypotSignatures
+}
+
+// MatchHoneypotBanner 使用蜜罐签名匹配banner
+func MatchHoneypotBanner(banner string) (*BannerSignature, bool) {
+	if banner == "" {
+		return nil, false
+	}
+	signatures := HoneypotSignatures()
+	for i := range signatures {
+		if signatures[i].Rule.Match([]byte(banner)) != nil {
+			return &signatures[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,47 @@
+package fingerprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoneypotSignatures(t *testing.T) {
+	signatures := HoneypotSignatures()
+	require.Len(t, signatures, 4)
+	for _, sig := range signatures {
+		assert.Contains(t, sig.Product, "honeypot")
+	}
+
+	sig, ok := MatchHoneypotBanner("SSH-2.0-OpenSSH_6.0p1 Debian-4+deb7u2\r\n")
+	require.True(t, ok)
+	assert.Equal(t, "Cowrie SSH honeypot", sig.Product)
+	assert.Equal(t, "ssh", sig.Service)
+
+	// Conpot的页面内容只出现在HTTP响应中
+	sig, ok = MatchHoneypotBanner("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<title>Technodrome</title>")
+	require.True(t, ok)
+	assert.Equal(t, "Conpot ICS honeypot", sig.Product)
+	_, ok = MatchHoneypotBanner("Technodrome")
+	assert.False(t, ok)
+
+	_, ok = MatchHoneypotBanner("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n")
+	assert.False(t, ok)
+	_, ok = MatchHoneypotBanner("")
+	assert.False(t, ok)
+}
+
+func TestHoneypotSignaturesNotInServiceDB(t *testing.T) {
+	db, err := LoadDB("")
+	require.NoError(t, err)
+	for _, probe := range db.ServiceProbes {
+		for _, rule := range probe.Matches {
+			assert.NotContains(t, rule.Template.Product, "honeypot", rule.Text)
+		}
+	}
+	m := db.MatchResponse("tcp", 21, []byte("220 DiskStation FTP server ready.\r\n"))
+	if m != nil {
+		assert.NotContains(t, m.Product, "honeypot")
+	}
+}
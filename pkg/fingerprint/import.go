@@ -0,0 +1,100 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+)
+
+// dbFiles 数据目录中的指纹文件
+var dbFiles = []string{nmap.ServiceProbesFile, nmap.OSDBFile, nmap.ServicesFile}
+
+// ValidateDB 重新解析dir目录下的指纹库而不使用缓存，dir为空时检查嵌入的数据
+// 文件无法读取时返回错误，逐行的解析错误记录在返回指纹库的ParseErrors中
+func ValidateDB(dir string) (*nmap.NmapDB, error) {
+	if dir == "" {
+		return loadEmbeddedDB()
+	}
+	return loadDirDB(dir)
+}
+
+// ImportDB 将src目录中的nmap-service-probes、nmap-os-db和nmap-services导入到dst目录
+// dst为空时导入到UserDataDir；src中至少要有探测规则或操作系统指纹之一，缺少的文件使用嵌入的数据补齐。
+// 导入的文件先写入临时目录并完整解析，成功后才替换dst，返回导入后的指纹库
+func ImportDB(src, dst string) (*nmap.NmapDB, error) {
+	if dst == "" {
+		dst = UserDataDir()
+	}
+
+	contents := make(map[string][]byte, len(dbFiles))
+	for _, name := range dbFiles {
+		data, err := os.ReadFile(filepath.Join(src, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取%s失败: %v", name, err)
+		}
+		contents[name] = data
+	}
+	if contents[nmap.ServiceProbesFile] == nil && contents[nmap.OSDBFile] == nil {
+		return nil, fmt.Errorf("%s中没有%s或%s", src, nmap.ServiceProbesFile, nmap.OSDBFile)
+	}
+	for _, name := range dbFiles {
+		if contents[name] != nil {
+			continue
+		}
+		data, err := fs.ReadFile(EmbeddedData(), name)
+		if err != nil {
+			return nil, fmt.Errorf("读取嵌入的%s失败: %v", name, err)
+		}
+		contents[name] = data
+	}
+	contents[nmap.VersionFile] = []byte("imported-" +
+		nmap.ContentVersion(contents[nmap.ServiceProbesFile], contents[nmap.OSDBFile]) + "\n")
+
+	parent := filepath.Dir(dst)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %v", err)
+	}
+	tmp, err := os.MkdirTemp(parent, ".fingerprints-")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for name, data := range contents {
+		if err := os.WriteFile(filepath.Join(tmp, name), data, 0644); err != nil {
+			return nil, fmt.Errorf("写入%s失败: %v", name, err)
+		}
+	}
+	db, err := nmap.LoadNmapDB(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先移走旧的指纹库，替换失败时恢复
+	old := ""
+	if _, err := os.Stat(dst); err == nil {
+		old = tmp + ".old"
+		if err := os.Rename(dst, old); err != nil {
+			return nil, fmt.Errorf("替换%s失败: %v", dst, err)
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		if old != "" {
+			os.Rename(old, dst)
+		}
+		return nil, fmt.Errorf("替换%s失败: %v", dst, err)
+	}
+	if old != "" {
+		os.RemoveAll(old)
+	}
+
+	forgetDB(dst)
+	return db, nil
+}
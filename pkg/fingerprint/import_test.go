@@ -0,0 +1,77 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testImportProbes = "Probe TCP NULL q||\nmatch custom m|^CUSTOM ([\\d.]+)| p/Custom/ v/$1/\nmatch broken m|[|\n"
+
+func TestImportDB(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "nmap-service-probes"), []byte(testImportProbes), 0644))
+
+	// 没有导入时使用嵌入的数据
+	assert.Equal(t, "", ActiveDataDir())
+	embedded, err := LoadDB("")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(embedded.Version, "embedded-"))
+
+	// 只提供探测规则，其余文件使用嵌入的数据补齐；解析错误不影响导入
+	db, err := ImportDB(src, "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(db.Version, "imported-"))
+	require.Len(t, db.ParseErrors, 1)
+	assert.Equal(t, nmap.ServiceProbesFile, db.ParseErrors[0].File)
+	assert.Equal(t, 3, db.ParseErrors[0].Line)
+	assert.Equal(t, embedded.Stats().OSFingerprints, db.Stats().OSFingerprints)
+	assert.Equal(t, embedded.Stats().Services, db.Stats().Services)
+
+	// 导入后扫描自动使用用户目录中的指纹库
+	assert.Equal(t, UserDataDir(), ActiveDataDir())
+	f, err := NewFingerprinter("")
+	require.NoError(t, err)
+	assert.Equal(t, db.Version, f.db.Version)
+	fp, err := f.MatchServiceProbes("192.0.2.1", 9999, []ProbeResult{{Type: "NULL", Protocol: "tcp", Response: []byte("CUSTOM 1.2\r\n")}})
+	require.NoError(t, err)
+	assert.Equal(t, "Custom", fp.Product)
+	assert.Equal(t, db.Version, fp.DBVersion)
+
+	// 重新导入替换旧的指纹库，缓存同时失效
+	require.NoError(t, os.WriteFile(filepath.Join(src, "nmap-service-probes"), []byte("Probe TCP NULL q||\n"), 0644))
+	updated, err := ImportDB(src, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, db.Version, updated.Version)
+	reloaded, err := LoadDB(UserDataDir())
+	require.NoError(t, err)
+	assert.Equal(t, updated.Version, reloaded.Version)
+
+	// 源目录中没有指纹文件时不影响已导入的指纹库
+	_, err = ImportDB(t.TempDir(), "")
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(UserDataDir(), nmap.VersionFile))
+	assert.NoError(t, err)
+}
+
+func TestValidateDB(t *testing.T) {
+	db, err := ValidateDB("")
+	require.NoError(t, err)
+	assert.Empty(t, db.ParseErrors)
+
+	dir := t.TempDir()
+	_, err = ValidateDB(dir)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nmap-service-probes"), []byte(testImportProbes), 0644))
+	db, err = ValidateDB(dir)
+	require.NoError(t, err)
+	require.Len(t, db.ParseErrors, 1)
+	assert.Equal(t, "nmap-service-probes 第3行: "+db.ParseErrors[0].Err.Error(), db.ParseErrors[0].Error())
+}
@@ -0,0 +1,291 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// JARM主动TLS指纹：按固定参数发送10个ClientHello，由服务器选择的密码套件、版本、
+// ALPN和扩展顺序计算62位的指纹，算法与 https://github.com/salesforce/jarm 一致
+
+// jarmEmpty 所有探测都没有ServerHello时的指纹
+var jarmEmpty = strings.Repeat("0", 62)
+
+// jarmCiphers JARM的"ALL"密码套件列表，顺序不能改变
+var jarmCiphers = []uint16{
+	0x0016, 0x0033, 0x0067, 0xc09e, 0xc0a2, 0x009e, 0x0039, 0x006b, 0xc09f, 0xc0a3,
+	0x009f, 0x0045, 0x00be, 0x0088, 0x00c4, 0x009a, 0xc008, 0xc009, 0xc023, 0xc0ac,
+	0xc0ae, 0xc02b, 0xc00a, 0xc024, 0xc0ad, 0xc0af, 0xc02c, 0xc072, 0xc073, 0xcca9,
+	0x1302, 0x1301, 0xcc14, 0xc007, 0xc012, 0xc013, 0xc027, 0xc02f, 0xc014, 0xc028,
+	0xc030, 0xc060, 0xc061, 0xc076, 0xc077, 0xcca8, 0x1305, 0x1304, 0x1303, 0xcc13,
+	0xc011, 0x000a, 0x002f, 0x003c, 0xc09c, 0xc0a0, 0x009c, 0x0035, 0x003d, 0xc09d,
+	0xc0a1, 0x009d, 0x0041, 0x00ba, 0x0084, 0x00c0, 0x0007, 0x0004, 0x0005,
+}
+
+// jarmCipherIndex 计算指纹时密码套件的编号顺序
+var jarmCipherIndex = []uint16{
+	0x0004, 0x0005, 0x0007, 0x000a, 0x0016, 0x002f, 0x0033, 0x0035, 0x0039, 0x003c,
+	0x003d, 0x0041, 0x0045, 0x0067, 0x006b, 0x0084, 0x0088, 0x009a, 0x009c, 0x009d,
+	0x009e, 0x009f, 0x00ba, 0x00be, 0x00c0, 0x00c4, 0xc007, 0xc008, 0xc009, 0xc00a,
+	0xc011, 0xc012, 0xc013, 0xc014, 0xc023, 0xc024, 0xc027, 0xc028, 0xc02b, 0xc02c,
+	0xc02f, 0xc030, 0xc060, 0xc061, 0xc072, 0xc073, 0xc076, 0xc077, 0xc09c, 0xc09d,
+	0xc09e, 0xc09f, 0xc0a0, 0xc0a1, 0xc0a2, 0xc0a3, 0xc0ac, 0xc0ad, 0xc0ae, 0xc0af,
+	0xcc13, 0xcc14, 0xcca8, 0xcca9, 0x1301, 0x1302, 0x1303, 0x1304, 0x1305,
+}
+
+// jarmALPN、jarmRareALPN 两种ALPN列表
+var (
+	jarmALPN     = []string{"http/0.9", "http/1.0", "http/1.1", "spdy/1", "spdy/2", "spdy/3", "h2", "h2c", "hq"}
+	jarmRareALPN = []string{"http/0.9", "http/1.0", "spdy/1", "spdy/2", "spdy/3", "h2c", "hq"}
+)
+
+// jarmOrder 列表的排列方式
+type jarmOrder int
+
+const (
+	jarmForward jarmOrder = iota
+	jarmReverse
+	jarmTopHalf
+	jarmBottomHalf
+	jarmMiddleOut
+)
+
+// jarmProbe 一个JARM探测的参数
+type jarmProbe struct {
+	version     uint16    // TLS版本
+	noTLS13     bool      // 密码套件中去掉TLS 1.3套件
+	cipherOrder jarmOrder // 密码套件顺序
+	grease      bool      // 加入GREASE值
+	rareALPN    bool      // 使用少见的ALPN列表
+	support     uint16    // supported_versions扩展的最高版本，0表示不发送
+	extOrder    jarmOrder // ALPN和supported_versions的顺序
+}
+
+// jarmProbes 按JARM规定顺序排列的10个探测
+var jarmProbes = []jarmProbe{
+	{version: versionTLS12, cipherOrder: jarmForward, support: versionTLS12, extOrder: jarmReverse},
+	{version: versionTLS12, cipherOrder: jarmReverse, support: versionTLS12, extOrder: jarmForward},
+	{version: versionTLS12, cipherOrder: jarmTopHalf, extOrder: jarmForward},
+	{version: versionTLS12, cipherOrder: jarmBottomHalf, rareALPN: true, extOrder: jarmForward},
+	{version: versionTLS12, cipherOrder: jarmMiddleOut, grease: true, rareALPN: true, extOrder: jarmReverse},
+	{version: versionTLS11, cipherOrder: jarmForward, extOrder: jarmForward},
+	{version: versionTLS13, cipherOrder: jarmForward, support: versionTLS13, extOrder: jarmReverse},
+	{version: versionTLS13, cipherOrder: jarmReverse, support: versionTLS13, extOrder: jarmForward},
+	{version: versionTLS13, noTLS13: true, cipherOrder: jarmForward, support: versionTLS13, extOrder: jarmForward},
+	{version: versionTLS13, cipherOrder: jarmMiddleOut, grease: true, support: versionTLS13, extOrder: jarmReverse},
+}
+
+// jarmGrease GREASE保留值
+var jarmGrease = []uint16{0x0a0a, 0x1a1a, 0x2a2a, 0x3a3a, 0x4a4a, 0x5a5a, 0x6a6a, 0x7a7a,
+	0x8a8a, 0x9a9a, 0xaaaa, 0xbaba, 0xcaca, 0xdada, 0xeaea, 0xfafa}
+
+// hello 构造探测对应的ClientHello，host用于server_name扩展
+func (p jarmProbe) hello(host string) *clientHello {
+	hello := &clientHello{RecordVersion: versionTLS10, Version: p.version}
+	switch p.version {
+	case versionTLS11:
+		hello.RecordVersion = versionTLS11
+	case versionTLS13:
+		hello.Version = versionTLS12
+	}
+
+	ciphers := jarmCiphers
+	if p.noTLS13 {
+		ciphers = nil
+		for _, c := range jarmCiphers {
+			if !isTLS13Cipher(c) {
+				ciphers = append(ciphers, c)
+			}
+		}
+	}
+	ciphers = jarmMungUint16(ciphers, p.cipherOrder)
+	var grease uint16
+	if p.grease {
+		grease = jarmGrease[rand.Intn(len(jarmGrease))]
+		ciphers = append([]uint16{grease}, ciphers...)
+	}
+	hello.Ciphers = ciphers
+
+	if grease != 0 {
+		hello.Extensions = append(hello.Extensions, tlsExtension{Type: grease, Data: []byte{}})
+	}
+	alpn := jarmALPN
+	if p.rareALPN {
+		alpn = jarmRareALPN
+	}
+	hello.Extensions = append(hello.Extensions,
+		sniExtension(host),
+		tlsExtension{Type: extExtendedMasterSecret, Data: []byte{}},
+		tlsExtension{Type: extMaxFragmentLength, Data: []byte{0x01}},
+		tlsExtension{Type: extRenegotiationInfo, Data: []byte{0x00}},
+		uint16ListExtension(extSupportedGroups, 0x001d, 0x0017, 0x0018, 0x0019),
+		tlsExtension{Type: extECPointFormats, Data: []byte{0x01, 0x00}},
+		tlsExtension{Type: extSessionTicket, Data: []byte{}},
+		alpnExtension(jarmMungStrings(alpn, p.extOrder)...),
+		uint16ListExtension(extSignatureAlgorithms, signatureAlgorithms...),
+		keyShareExtension(grease),
+		tlsExtension{Type: extPSKKeyExchangeModes, Data: []byte{0x01, 0x01}},
+	)
+	if p.support != 0 {
+		versions := []uint16{versionTLS10, versionTLS11, versionTLS12}
+		if p.support == versionTLS13 {
+			versions = append(versions, versionTLS13)
+		}
+		versions = jarmMungUint16(versions, p.extOrder)
+		if grease != 0 {
+			versions = append([]uint16{grease}, versions...)
+		}
+		hello.Extensions = append(hello.Extensions, supportedVersionsExtension(versions...))
+	}
+	return hello
+}
+
+// jarmMung 按JARM的规则重排长度为n的列表，返回重排后各位置对应的原下标
+func jarmMung(n int, order jarmOrder) []int {
+	index := make([]int, n)
+	for i := range index {
+		index[i] = i
+	}
+	return jarmMungIndex(index, order)
+}
+
+// jarmMungIndex 重排下标列表
+func jarmMungIndex(list []int, order jarmOrder) []int {
+	n := len(list)
+	var out []int
+	switch order {
+	case jarmForward:
+		return append(out, list...)
+	case jarmReverse:
+		for i := n - 1; i >= 0; i-- {
+			out = append(out, list[i])
+		}
+	case jarmBottomHalf:
+		out = append(out, list[n/2+n%2:]...)
+	case jarmTopHalf:
+		if n%2 == 1 {
+			out = append(out, list[n/2])
+		}
+		out = append(out, jarmMungIndex(jarmMungIndex(list, jarmReverse), jarmBottomHalf)...)
+	case jarmMiddleOut:
+		middle := n / 2
+		if n%2 == 1 {
+			out = append(out, list[middle])
+			for i := 1; i <= middle; i++ {
+				out = append(out, list[middle+i], list[middle-i])
+			}
+		} else {
+			for i := 1; i <= middle; i++ {
+				out = append(out, list[middle-1+i], list[middle-i])
+			}
+		}
+	}
+	return out
+}
+
+// jarmMungUint16 按JARM的规则重排密码套件或版本列表
+func jarmMungUint16(list []uint16, order jarmOrder) []uint16 {
+	out := make([]uint16, 0, len(list))
+	for _, i := range jarmMung(len(list), order) {
+		out = append(out, list[i])
+	}
+	return out
+}
+
+// jarmMungStrings 按JARM的规则重排ALPN列表
+func jarmMungStrings(list []string, order jarmOrder) []string {
+	out := make([]string, 0, len(list))
+	for _, i := range jarmMung(len(list), order) {
+		out = append(out, list[i])
+	}
+	return out
+}
+
+// jarmResult 单个探测的结果 "cipher|version|alpn|extensions"，没有ServerHello时为"|||"
+func jarmResult(hello *serverHello) string {
+	if hello == nil {
+		return "|||"
+	}
+	types := make([]string, 0, len(hello.Extensions))
+	for _, ext := range hello.Extensions {
+		types = append(types, fmt.Sprintf("%04x", ext.Type))
+	}
+	return fmt.Sprintf("%04x|%04x|%s|%s", hello.Cipher, hello.Version, hello.ALPN(), strings.Join(types, "-"))
+}
+
+// jarmHash 由10个探测结果计算JARM指纹
+func jarmHash(results []string) string {
+	var fuzzy, alpnAndExt strings.Builder
+	empty := true
+	for _, result := range results {
+		parts := strings.SplitN(result, "|", 4)
+		if len(parts) != 4 {
+			parts = []string{"", "", "", ""}
+		}
+		if parts[0] != "" {
+			empty = false
+		}
+		fuzzy.WriteString(jarmCipherByte(parts[0]))
+		fuzzy.WriteString(jarmVersionByte(parts[1]))
+		alpnAndExt.WriteString(parts[2])
+		alpnAndExt.WriteString(parts[3])
+	}
+	if empty {
+		return jarmEmpty
+	}
+	sum := sha256.Sum256([]byte(alpnAndExt.String()))
+	return fuzzy.String() + hex.EncodeToString(sum[:])[:32]
+}
+
+// jarmCipherByte 密码套件在jarmCipherIndex中的序号(从1开始)，两位十六进制
+func jarmCipherByte(cipher string) string {
+	if cipher == "" {
+		return "00"
+	}
+	count := 1
+	for _, c := range jarmCipherIndex {
+		if fmt.Sprintf("%04x", c) == cipher {
+			break
+		}
+		count++
+	}
+	return fmt.Sprintf("%02x", count)
+}
+
+// jarmVersionByte 版本号的最后一位映射为a-f
+func jarmVersionByte(version string) string {
+	if len(version) < 4 {
+		return "0"
+	}
+	index := int(version[3] - '0')
+	if index < 0 || index > 5 {
+		return "0"
+	}
+	return string("abcdef"[index])
+}
+
+// JARM 计算目标端口的JARM指纹，host为空时server_name使用target
+func JARM(dial func(network, address string, timeout time.Duration) (net.Conn, error),
+	target string, port int, host string, timeout time.Duration) string {
+	if dial == nil {
+		dial = net.DialTimeout
+	}
+	if host == "" {
+		host = target
+	}
+	address := net.JoinHostPort(target, fmt.Sprint(port))
+	results := make([]string, 0, len(jarmProbes))
+	for _, probe := range jarmProbes {
+		hello, err := sendClientHello(dial, address, timeout, probe.hello(host))
+		if err != nil {
+			hello = nil
+		}
+		results = append(results, jarmResult(hello))
+	}
+	return jarmHash(results)
+}
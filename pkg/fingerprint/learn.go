@@ -0,0 +1,107 @@
+package fingerprint
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// maxLearnedLine 生成正则时最多使用的首行字符数
+	maxLearnedLine = 48
+	// maxLearnedBytes 二进制响应生成字节匹配时使用的前缀长度
+	maxLearnedBytes = 8
+)
+
+// learnedVersion 横幅中第一个形如1.2.3的版本号
+var learnedVersion = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// DraftRule 根据未识别服务的记录生成用户规则草稿
+// 使用记录中的第一个响应：可打印的首行生成锚定的正则，其中的版本号替换为分组；
+// 否则用开头的字节生成字节匹配。探测数据取自db中同名的探测，生成的规则保证能匹配该响应
+func DraftRule(record *ServiceRecord, db *nmap.NmapDB) (*ServiceRule, error) {
+	if len(record.Responses) == 0 {
+		return nil, fmt.Errorf("记录中没有探测响应")
+	}
+	resp := record.Responses[0]
+
+	rule := &ServiceRule{
+		Name:    fmt.Sprintf("learned-%s-%d", record.Protocol, record.Port),
+		Service: "unknown",
+		Ports:   PortList(strconv.Itoa(record.Port)),
+		TLS:     record.Tunnel == "ssl",
+	}
+	if strings.EqualFold(record.Protocol, "udp") {
+		rule.Protocol = "udp"
+	}
+
+	if resp.Probe != "NULL" {
+		probe, ok := db.Probes[resp.Probe]
+		if !ok {
+			return nil, fmt.Errorf("指纹库中没有探测%s", resp.Probe)
+		}
+		if isPrintable(probe.Payload) {
+			rule.Payload = nmap.EscapeString(probe.Payload)
+		} else {
+			rule.PayloadHex = hex.EncodeToString(probe.Payload)
+		}
+	}
+
+	line := resp.Response
+	if i := strings.IndexAny(string(line), "\r\n"); i >= 0 {
+		line = line[:i]
+	}
+	if len(line) > maxLearnedLine {
+		line = line[:maxLearnedLine]
+	}
+	if len(line) >= 3 && isPrintable(line) {
+		pattern := "^" + regexp.QuoteMeta(string(line))
+		if loc := learnedVersion.FindIndex(line); loc != nil {
+			pattern = "^" + regexp.QuoteMeta(string(line[:loc[0]])) + `([\d.]+)` + regexp.QuoteMeta(string(line[loc[1]:]))
+			rule.Version = "$1"
+		}
+		rule.Matchers = []RuleMatcher{{Regex: pattern}}
+	} else {
+		prefix := resp.Response
+		if len(prefix) > maxLearnedBytes {
+			prefix = prefix[:maxLearnedBytes]
+		}
+		offset := 0
+		rule.Matchers = []RuleMatcher{{Bytes: hex.EncodeToString(prefix), Offset: &offset}}
+	}
+	return rule, nil
+}
+
+// VerifyRule 检查规则能否编译并匹配response，用于确认生成或修改后的规则草稿
+func VerifyRule(rule *ServiceRule, response []byte) error {
+	probe, err := rule.compile()
+	if err != nil {
+		return err
+	}
+	if probe.Match(response) == nil {
+		return fmt.Errorf("规则%s不能匹配记录中的响应", rule.Name)
+	}
+	return nil
+}
+
+// MarshalRules 将规则编码为规则目录可以直接加载的YAML文件内容
+func MarshalRules(rules []*ServiceRule) ([]byte, error) {
+	return yaml.Marshal(struct {
+		Rules []*ServiceRule `yaml:"rules"`
+	}{rules})
+}
+
+// isPrintable 判断数据是否只包含可打印ASCII字符和常见空白
+func isPrintable(data []byte) bool {
+	for _, c := range data {
+		if (c < 0x20 || c > 0x7e) && c != '\r' && c != '\n' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
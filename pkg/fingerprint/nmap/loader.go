@@ -1,185 +1,158 @@
 package nmap
 
 import (
-	"bufio"
-	"fmt"
-	"os"
-	"path/filepath"
+	"bytes"
+	"strconv"
 	"strings"
 )
 
-// loadProbes 加载Nmap探测规则
-func (db *NmapDB) loadProbes(nmapSharePath string) error {
-	file, err := os.Open(filepath.Join(nmapSharePath, "nmap-service-probes"))
+// 数据目录中的文件名
+const (
+	ServiceProbesFile = "nmap-service-probes"
+	OSDBFile          = "nmap-os-db"
+	ServicesFile      = "nmap-services"
+	VersionFile       = "VERSION"
+)
+
+// loadProbes 加载Nmap服务探测规则，同时为每条match规则生成服务指纹
+func (db *NmapDB) loadProbes(data []byte) error {
+	sp, err := ParseServiceProbes(bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("打开探测规则文件失败: %v", err)
+		return err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	var currentProbe *Probe
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		if strings.HasPrefix(line, "Probe") {
-			// 解析探测规则
-			parts := strings.SplitN(line, " ", 3)
-			if len(parts) != 3 {
-				continue
-			}
+	setErrorFile(sp.Errors, ServiceProbesFile)
+	db.AddServiceProbes(sp)
+	return nil
+}
 
-			probeName := strings.TrimSpace(parts[1])
-			probeStr := strings.TrimSpace(parts[2])
+// AddServiceProbes 将解析后的探测规则加入数据库，同名探测被覆盖
+func (db *NmapDB) AddServiceProbes(sp *ServiceProbes) {
+	db.ParseErrors = append(db.ParseErrors, sp.Errors...)
+	for _, probe := range sp.Probes {
+		db.Probes[probe.Name] = probe
+		db.ServiceProbes = append(db.ServiceProbes, probe)
 
-			currentProbe = &Probe{
-				Name:     probeName,
-				ProbeStr: probeStr,
+		for _, rule := range probe.Matches {
+			fp := &NmapFingerprint{
+				Name:     rule.Service,
+				Class:    "Service",
+				Line:     rule.Text,
+				Features: make(map[string]string),
+				Probes:   []Probe{*probe},
+				Rule:     rule,
 			}
-			db.Probes[probeName] = currentProbe
-		} else if strings.HasPrefix(line, "ports") && currentProbe != nil {
-			// 解析端口
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				currentProbe.Ports = strings.TrimSpace(parts[1])
+			if rule.Soft {
+				fp.SoftMatches = []string{rule.Text}
+			} else {
+				fp.MatchLines = []string{rule.Text}
 			}
-		} else if strings.HasPrefix(line, "protocol") && currentProbe != nil {
-			// 解析协议
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				currentProbe.Protocol = strings.TrimSpace(parts[1])
+			for key, value := range map[string]string{
+				"product":    rule.Template.Product,
+				"version":    rule.Template.Version,
+				"info":       rule.Template.Info,
+				"hostname":   rule.Template.Hostname,
+				"os":         rule.Template.OS,
+				"devicetype": rule.Template.DeviceType,
+			} {
+				if value != "" {
+					fp.Features[key] = value
+				}
 			}
+			db.ServiceFingerprints[strings.TrimSpace(strings.SplitN(rule.Text, " ", 2)[1])] = fp
+			db.serviceOrder = append(db.serviceOrder, fp)
 		}
 	}
-
-	return scanner.Err()
 }
 
-// loadOSFingerprints 加载操作系统指纹
-func (db *NmapDB) loadOSFingerprints(nmapSharePath string) error {
-	file, err := os.Open(filepath.Join(nmapSharePath, "nmap-os-db"))
-	if err != nil {
-		return fmt.Errorf("打开操作系统指纹文件失败: %v", err)
+// WithServiceProbes 返回加入probes后的新数据库，原数据库不变
+// 新探测排在已有探测之前，同名探测覆盖已有探测；version为新数据库的版本
+func (db *NmapDB) WithServiceProbes(probes []*Probe, version string) *NmapDB {
+	derived := *db
+	derived.Version = version
+	derived.Probes = make(map[string]*Probe, len(db.Probes)+len(probes))
+	for name, probe := range db.Probes {
+		derived.Probes[name] = probe
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	var currentFP *NmapFingerprint
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		if strings.HasPrefix(line, "Fingerprint") {
-			// 开始新的指纹
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) != 2 {
-				continue
-			}
-
-			currentFP = &NmapFingerprint{
-				Name:     strings.TrimSpace(parts[1]),
-				Class:    "OS",
-				Line:     line,
-				Features: make(map[string]string),
-			}
-			db.OSFingerprints[currentFP.Name] = currentFP
-		} else if strings.HasPrefix(line, "Class") && currentFP != nil {
-			// 解析类别
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				currentFP.Class = strings.TrimSpace(parts[1])
-			}
-		} else if strings.HasPrefix(line, "Match") && currentFP != nil {
-			// 解析匹配规则
-			currentFP.MatchLines = append(currentFP.MatchLines, line)
-		} else if strings.HasPrefix(line, "SoftMatch") && currentFP != nil {
-			// 解析软匹配规则
-			currentFP.SoftMatches = append(currentFP.SoftMatches, line)
-		}
+	derived.ServiceFingerprints = make(map[string]*NmapFingerprint, len(db.ServiceFingerprints))
+	for key, fp := range db.ServiceFingerprints {
+		derived.ServiceFingerprints[key] = fp
 	}
+	derived.ParseErrors = append([]*ParseError(nil), db.ParseErrors...)
+	derived.ServiceProbes, derived.serviceOrder = nil, nil
 
-	return scanner.Err()
+	derived.AddServiceProbes(&ServiceProbes{Probes: probes})
+	derived.ServiceProbes = append(derived.ServiceProbes, db.ServiceProbes...)
+	derived.serviceOrder = append(derived.serviceOrder, db.serviceOrder...)
+	return &derived
 }
 
-// loadServiceFingerprints 加载服务指纹
-func (db *NmapDB) loadServiceFingerprints(nmapSharePath string) error {
-	file, err := os.Open(filepath.Join(nmapSharePath, "nmap-service-probes"))
+// loadOSFingerprints 加载操作系统指纹
+func (db *NmapDB) loadOSFingerprints(data []byte) error {
+	osdb, err := ParseOSDB(bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("打开服务指纹文件失败: %v", err)
+		return err
 	}
-	defer file.Close()
+	setErrorFile(osdb.Errors, OSDBFile)
+	db.SetOSDB(osdb)
+	return nil
+}
 
-	scanner := bufio.NewScanner(file)
-	var currentFP *NmapFingerprint
-	var currentProbe *Probe
+// loadServices 加载服务名称与端口的对应关系
+func (db *NmapDB) loadServices(data []byte) error {
+	services, err := ParseServices(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	setErrorFile(services.Errors, ServicesFile)
+	db.SetServices(services)
+	return nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+// SetServices 设置服务端口列表，同一端口出现多次时保留第一个名称
+func (db *NmapDB) SetServices(services *Services) {
+	db.ParseErrors = append(db.ParseErrors, services.Errors...)
+	db.Services = services.Entries
+	db.serviceNames = make(map[string]string, len(services.Entries))
+	for _, entry := range services.Entries {
+		key := serviceKey(entry.Protocol, entry.Port)
+		if _, ok := db.serviceNames[key]; !ok {
+			db.serviceNames[key] = entry.Name
 		}
+	}
+}
 
-		if strings.HasPrefix(line, "Probe") {
-			// 开始新的探测规则
-			parts := strings.SplitN(line, " ", 3)
-			if len(parts) != 3 {
-				continue
-			}
-
-			probeName := strings.TrimSpace(parts[1])
-			probeStr := strings.TrimSpace(parts[2])
+// ServiceName 返回nmap-services中端口对应的服务名称，未知端口返回空字符串
+func (db *NmapDB) ServiceName(protocol string, port int) string {
+	return db.serviceNames[serviceKey(protocol, port)]
+}
 
-			currentProbe = &Probe{
-				Name:     probeName,
-				ProbeStr: probeStr,
-			}
-			db.Probes[probeName] = currentProbe
-		} else if strings.HasPrefix(line, "match") && currentProbe != nil {
-			// 解析服务匹配规则
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) != 2 {
-				continue
-			}
+// serviceKey 端口映射的键
+func serviceKey(protocol string, port int) string {
+	return strings.ToLower(protocol) + "/" + strconv.Itoa(port)
+}
 
-			currentFP = &NmapFingerprint{
-				Name:     strings.TrimSpace(parts[1]),
-				Class:    "Service",
-				Line:     line,
-				Features: make(map[string]string),
-				Probes:   []Probe{*currentProbe},
-			}
-			db.ServiceFingerprints[currentFP.Name] = currentFP
-		} else if strings.HasPrefix(line, "softmatch") && currentProbe != nil {
-			// 解析服务软匹配规则
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) != 2 {
-				continue
-			}
+// setErrorFile 为解析错误记录所在文件
+func setErrorFile(errs []*ParseError, file string) {
+	for _, e := range errs {
+		e.File = file
+	}
+}
 
-			currentFP = &NmapFingerprint{
-				Name:     strings.TrimSpace(parts[1]),
-				Class:    "Service",
-				Line:     line,
-				Features: make(map[string]string),
-				Probes:   []Probe{*currentProbe},
-			}
-			db.ServiceFingerprints[currentFP.Name] = currentFP
-		} else if strings.HasPrefix(line, "|") && currentFP != nil {
-			// 解析特征值
-			parts := strings.SplitN(line[1:], ":", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				currentFP.Features[key] = value
-			}
+// SetOSDB 设置操作系统指纹库，并为每个参考指纹生成以名称为键的指纹
+func (db *NmapDB) SetOSDB(osdb *OSDB) {
+	db.OS = osdb
+	db.ParseErrors = append(db.ParseErrors, osdb.Errors...)
+	for _, ref := range osdb.Fingerprints {
+		fp := &NmapFingerprint{
+			Name:     ref.Name,
+			Class:    "OS",
+			Line:     "Fingerprint " + ref.Name,
+			Features: ref.Tests.Strings(),
+		}
+		if len(ref.Classes) > 0 {
+			class := ref.Classes[0]
+			fp.Class = strings.Join([]string{class.Vendor, class.Family, class.Generation, class.DeviceType}, " | ")
 		}
+		db.OSFingerprints[ref.Name] = fp
 	}
-
-	return scanner.Err()
 }
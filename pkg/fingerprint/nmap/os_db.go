@@ -0,0 +1,348 @@
+package nmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OSGuessThreshold 与nmap相同，匹配度低于该值的参考指纹不作为猜测结果
+const OSGuessThreshold = 0.85
+
+// osTestOrder nmap第二代操作系统探测的测试顺序
+var osTestOrder = []string{"SEQ", "OPS", "WIN", "ECN", "T1", "T2", "T3", "T4", "T5", "T6", "T7", "U1", "IE"}
+
+// osAttrOrder 各测试中属性的输出顺序
+var osAttrOrder = map[string][]string{
+	"SEQ": {"SP", "GCD", "ISR", "TI", "CI", "II", "SS", "TS"},
+	"OPS": {"O1", "O2", "O3", "O4", "O5", "O6"},
+	"WIN": {"W1", "W2", "W3", "W4", "W5", "W6"},
+	"ECN": {"R", "DF", "T", "TG", "W", "O", "CC", "Q"},
+	"T1":  {"R", "DF", "T", "TG", "W", "S", "A", "F", "O", "RD", "Q"},
+	"U1":  {"R", "DF", "T", "TG", "IPL", "UN", "RIPL", "RID", "RIPCK", "RUCK", "RUD"},
+	"IE":  {"R", "DFI", "T", "TG", "CD"},
+}
+
+// OSTests 操作系统指纹的测试结果，测试名 -> 属性名 -> 值
+// 参考指纹中的值为表达式，如 W=FAF0|FFFF、T=3B-45、SP=>100
+type OSTests map[string]map[string]string
+
+// String 按nmap的格式输出，如 SEQ(SP=101%GCD=1%ISR=10A)
+func (t OSTests) String() string {
+	var lines []string
+	for _, name := range t.testNames() {
+		lines = append(lines, name+"("+t.attrString(name)+")")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// attrString 返回测试的属性字符串，如 SP=101%GCD=1，测试不存在时返回空
+func (t OSTests) attrString(name string) string {
+	attrs := t[name]
+	var parts []string
+	for _, attr := range attrOrder(name, attrs) {
+		parts = append(parts, attr+"="+attrs[attr])
+	}
+	return strings.Join(parts, "%")
+}
+
+// Strings 返回测试名到属性字符串的映射
+func (t OSTests) Strings() map[string]string {
+	result := make(map[string]string, len(t))
+	for name := range t {
+		result[name] = t.attrString(name)
+	}
+	return result
+}
+
+// testNames 按nmap的顺序返回测试名，未知测试按名称排在最后
+func (t OSTests) testNames() []string {
+	var names, extra []string
+	for _, name := range osTestOrder {
+		if _, ok := t[name]; ok {
+			names = append(names, name)
+		}
+	}
+	for name := range t {
+		if osTestIndex(name) < 0 {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+// osTestIndex 返回测试在osTestOrder中的位置，未知测试返回-1
+func osTestIndex(name string) int {
+	for i, known := range osTestOrder {
+		if known == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// attrOrder 按nmap的顺序返回属性名，T2-T7与T1相同
+func attrOrder(test string, attrs map[string]string) []string {
+	order := osAttrOrder[test]
+	if order == nil && len(test) == 2 && test[0] == 'T' {
+		order = osAttrOrder["T1"]
+	}
+	var names, extra []string
+	seen := make(map[string]bool)
+	for _, attr := range order {
+		if _, ok := attrs[attr]; ok {
+			names = append(names, attr)
+			seen[attr] = true
+		}
+	}
+	for attr := range attrs {
+		if !seen[attr] {
+			extra = append(extra, attr)
+		}
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+// ParseOSTests 解析nmap格式的测试行，每行形如 SEQ(SP=101%GCD=1)
+func ParseOSTests(s string) (OSTests, error) {
+	tests := make(OSTests)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, attrs, err := parseOSTestLine(line)
+		if err != nil {
+			return nil, err
+		}
+		tests[name] = attrs
+	}
+	return tests, nil
+}
+
+// parseOSTestLine 解析单个测试，属性之间以%分隔
+func parseOSTestLine(line string) (string, map[string]string, error) {
+	open := strings.IndexByte(line, '(')
+	if open <= 0 || !strings.HasSuffix(line, ")") {
+		return "", nil, fmt.Errorf("无效的测试行: %s", line)
+	}
+	name := line[:open]
+	attrs := make(map[string]string)
+	body := line[open+1 : len(line)-1]
+	if body == "" {
+		return name, attrs, nil
+	}
+	for _, part := range strings.Split(body, "%") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || key == "" {
+			return "", nil, fmt.Errorf("测试 %s 含无效属性: %s", name, part)
+		}
+		attrs[key] = value
+	}
+	return name, attrs, nil
+}
+
+// OSClass 参考指纹的分类，对应Class行及其后的CPE行
+type OSClass struct {
+	Vendor     string   `json:"vendor"`                // 厂商
+	Family     string   `json:"family"`                // 操作系统家族
+	Generation string   `json:"generation,omitempty"`  // 版本代
+	DeviceType string   `json:"device_type,omitempty"` // 设备类型
+	CPE        []string `json:"cpe,omitempty"`         // CPE标识
+}
+
+// OSReference nmap-os-db中的一个参考指纹
+type OSReference struct {
+	Name    string    // Fingerprint行的名称
+	Classes []OSClass // 分类
+	Tests   OSTests   // 测试表达式
+	Line    int       // Fingerprint行所在行号
+}
+
+// CPE 返回所有分类的CPE标识
+func (r *OSReference) CPE() []string {
+	var cpes []string
+	for _, class := range r.Classes {
+		cpes = append(cpes, class.CPE...)
+	}
+	return cpes
+}
+
+// OSMatch 参考指纹与被测指纹的比较结果
+type OSMatch struct {
+	Reference *OSReference
+	Accuracy  float64 // 匹配度(0-1)，为匹配属性的分值与参与比较属性的总分值之比
+}
+
+// OSDB 解析后的nmap-os-db
+type OSDB struct {
+	MatchPoints  OSTests        // 每个属性的分值
+	Fingerprints []*OSReference // 按文件顺序排列的参考指纹
+	Errors       []*ParseError  // 解析失败而被跳过的行
+}
+
+// ParseOSDB 解析nmap-os-db格式的数据
+// 单行的格式错误记录在Errors中并跳过，只有读取失败时返回错误
+func ParseOSDB(r io.Reader) (*OSDB, error) {
+	db := &OSDB{MatchPoints: make(OSTests)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var current *OSReference
+	inMatchPoints := false
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+
+		var err error
+		switch {
+		case line == "MatchPoints":
+			inMatchPoints, current = true, nil
+		case directive == "Fingerprint":
+			inMatchPoints = false
+			current = &OSReference{Name: args, Tests: make(OSTests), Line: lineNo}
+			db.Fingerprints = append(db.Fingerprints, current)
+		case directive == "Class" && current != nil:
+			var class OSClass
+			if class, err = parseOSClass(args); err == nil {
+				current.Classes = append(current.Classes, class)
+			}
+		case directive == "CPE" && current != nil:
+			if len(current.Classes) == 0 {
+				err = fmt.Errorf("CPE行出现在Class之前")
+				break
+			}
+			// 末尾的auto标志表示CPE由Class自动生成，不影响匹配
+			cpe := strings.TrimSpace(strings.TrimSuffix(args, " auto"))
+			class := &current.Classes[len(current.Classes)-1]
+			class.CPE = append(class.CPE, cpe)
+		case strings.Contains(line, "("):
+			var name string
+			var attrs map[string]string
+			if name, attrs, err = parseOSTestLine(line); err != nil {
+				break
+			}
+			switch {
+			case inMatchPoints:
+				for attr, points := range attrs {
+					if _, convErr := strconv.Atoi(points); convErr != nil {
+						err = fmt.Errorf("MatchPoints中 %s.%s 的分值无效: %s", name, attr, points)
+						break
+					}
+				}
+				if err == nil {
+					db.MatchPoints[name] = attrs
+				}
+			case current != nil:
+				current.Tests[name] = attrs
+			default:
+				err = fmt.Errorf("测试行出现在Fingerprint之前")
+			}
+		default:
+			err = fmt.Errorf("未知的指令: %s", directive)
+		}
+		if err != nil {
+			db.Errors = append(db.Errors, &ParseError{Line: lineNo, Text: line, Err: err})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取操作系统指纹失败: %v", err)
+	}
+	return db, nil
+}
+
+// parseOSClass 解析 "厂商 | 家族 | 版本代 | 设备类型"
+func parseOSClass(args string) (OSClass, error) {
+	fields := strings.Split(args, "|")
+	if len(fields) != 4 {
+		return OSClass{}, fmt.Errorf("Class行必须包含4个以|分隔的字段")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return OSClass{Vendor: fields[0], Family: fields[1], Generation: fields[2], DeviceType: fields[3]}, nil
+}
+
+// Compare 按MatchPoints比较参考指纹与被测指纹，返回匹配度(0-1)
+// 只有双方都包含且有分值的属性参与比较，没有可比较的属性时返回0
+func (db *OSDB) Compare(ref *OSReference, subject OSTests) float64 {
+	total, matched := 0, 0
+	for name, refAttrs := range ref.Tests {
+		subjectAttrs, ok := subject[name]
+		if !ok {
+			continue
+		}
+		for attr, expr := range refAttrs {
+			value, ok := subjectAttrs[attr]
+			if !ok {
+				continue
+			}
+			points, err := strconv.Atoi(db.MatchPoints[name][attr])
+			if err != nil {
+				continue
+			}
+			total += points
+			if MatchOSValue(expr, value) {
+				matched += points
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total)
+}
+
+// Match 返回匹配度不低于threshold的参考指纹，按匹配度降序排列，相同时保持文件顺序
+func (db *OSDB) Match(subject OSTests, threshold float64) []*OSMatch {
+	var matches []*OSMatch
+	for _, ref := range db.Fingerprints {
+		if accuracy := db.Compare(ref, subject); accuracy > 0 && accuracy >= threshold {
+			matches = append(matches, &OSMatch{Reference: ref, Accuracy: accuracy})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Accuracy > matches[j].Accuracy
+	})
+	return matches
+}
+
+// MatchOSValue 判断被测值是否满足参考表达式
+// 表达式由|分隔的候选组成，候选可以是字面值、十六进制范围a-b、>n或<n，空候选匹配空值
+func MatchOSValue(expr, value string) bool {
+	for _, candidate := range strings.Split(expr, "|") {
+		switch {
+		case candidate == value:
+			return true
+		case candidate == "" || value == "":
+		case candidate[0] == '>' || candidate[0] == '<':
+			bound, err1 := strconv.ParseUint(candidate[1:], 16, 64)
+			n, err2 := strconv.ParseUint(value, 16, 64)
+			if err1 == nil && err2 == nil &&
+				((candidate[0] == '>' && n > bound) || (candidate[0] == '<' && n < bound)) {
+				return true
+			}
+		case strings.Contains(candidate, "-"):
+			lowStr, highStr, _ := strings.Cut(candidate, "-")
+			low, err1 := strconv.ParseUint(lowStr, 16, 64)
+			high, err2 := strconv.ParseUint(highStr, 16, 64)
+			n, err3 := strconv.ParseUint(value, 16, 64)
+			if err1 == nil && err2 == nil && err3 == nil && n >= low && n <= high {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package nmap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOSDB = `# 测试用操作系统指纹
+MatchPoints
+SEQ(SP=25%GCD=75%ISR=25%TI=100%TS=100)
+OPS(O1=20%O2=20)
+WIN(W1=15%W2=15)
+T1(R=100%DF=20%TG=15%F=30)
+IE(R=50%CD=100)
+
+Fingerprint Linux 5.0 - 5.4
+Class Linux | Linux | 5.X | general purpose
+CPE cpe:/o:linux:linux_kernel:5 auto
+SEQ(SP=FB-105%GCD=1-6%ISR=102-10C%TI=Z%TS=A)
+OPS(O1=M5B4ST11NW7%O2=M5B4ST11NW7)
+WIN(W1=FAF0|FE88%W2=FAF0)
+T1(R=Y%DF=Y%TG=40%F=AS)
+IE(R=Y%CD=S)
+
+Fingerprint Microsoft Windows 10
+Class Microsoft | Windows | 10 | general purpose
+CPE cpe:/o:microsoft:windows_10
+SEQ(SP=>100%GCD=1-6%ISR=>100%TI=I%TS=A)
+OPS(O1=M5B4NW8ST11%O2=M5B4NW8ST11)
+WIN(W1=FFFF%W2=FFFF)
+T1(R=Y%DF=Y%TG=80%F=AS)
+IE(R=Y%CD=Z)
+
+Fingerprint Broken
+Class only | three | fields
+CPE cpe:/o:broken
+T1(R=Y
+Bogus directive
+`
+
+func TestParseOSDB(t *testing.T) {
+	db, err := ParseOSDB(strings.NewReader(testOSDB))
+	require.NoError(t, err)
+	require.Len(t, db.Fingerprints, 3)
+	assert.Equal(t, "100", db.MatchPoints["SEQ"]["TI"])
+
+	linux := db.Fingerprints[0]
+	assert.Equal(t, "Linux 5.0 - 5.4", linux.Name)
+	assert.Equal(t, 9, linux.Line)
+	require.Len(t, linux.Classes, 1)
+	assert.Equal(t, OSClass{Vendor: "Linux", Family: "Linux", Generation: "5.X", DeviceType: "general purpose",
+		CPE: []string{"cpe:/o:linux:linux_kernel:5"}}, linux.Classes[0])
+	assert.Equal(t, "FAF0|FE88", linux.Tests["WIN"]["W1"])
+	assert.Equal(t, []string{"cpe:/o:microsoft:windows_10"}, db.Fingerprints[1].CPE())
+
+	// 格式错误的行逐行记录，不影响其他指纹
+	var lines []int
+	for _, e := range db.Errors {
+		lines = append(lines, e.Line)
+	}
+	assert.Equal(t, []int{28, 29, 30, 31}, lines)
+	assert.Contains(t, db.Errors[0].Error(), "第28行")
+}
+
+func TestMatchOSValue(t *testing.T) {
+	for _, tc := range []struct {
+		expr, value string
+		want        bool
+	}{
+		{"Y", "Y", true},
+		{"Y", "N", false},
+		{"FAF0|FE88", "FE88", true},
+		{"3B-45", "40", true},
+		{"3B-45", "80", false},
+		{">100", "101", true},
+		{">100", "100", false},
+		{"<10", "F", true},
+		{"|AR", "", true},
+		{"AR", "", false},
+		{"M5B4ST11NW7", "M5B4ST11NW8", false},
+	} {
+		assert.Equal(t, tc.want, MatchOSValue(tc.expr, tc.value), "%s ~ %s", tc.expr, tc.value)
+	}
+}
+
+func TestOSDBMatch(t *testing.T) {
+	db, err := ParseOSDB(strings.NewReader(testOSDB))
+	require.NoError(t, err)
+
+	subject, err := ParseOSTests("SEQ(SP=101%GCD=1%ISR=105%TI=Z%TS=A)\n" +
+		"OPS(O1=M5B4ST11NW7%O2=M5B4ST11NW7)\nWIN(W1=FAF0%W2=FAF0)\nT1(R=Y%DF=Y%TG=40%F=AS)\nIE(R=Y%CD=S)")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, db.Compare(db.Fingerprints[0], subject))
+	matches := db.Match(subject, OSGuessThreshold)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "Linux 5.0 - 5.4", matches[0].Reference.Name)
+
+	// 不同的窗口和选项降低匹配度，低于阈值时不作为猜测结果
+	subject["WIN"]["W1"] = "FFFF"
+	subject["OPS"]["O1"] = "M5B4NW8ST11"
+	accuracy := db.Compare(db.Fingerprints[0], subject)
+	assert.InDelta(t, 1-35.0/710, accuracy, 1e-9)
+	assert.Len(t, db.Match(subject, 0.99), 0)
+
+	// Windows指纹的IP ID和ICMP代码不同，匹配度更低
+	matches = db.Match(subject, 0)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "Linux 5.0 - 5.4", matches[0].Reference.Name)
+	assert.Less(t, matches[1].Accuracy, matches[0].Accuracy)
+}
+
+func TestOSTestsString(t *testing.T) {
+	tests, err := ParseOSTests("T5(R=Y%DF=Y%F=AR)\nSEQ(SP=101%GCD=1)")
+	require.NoError(t, err)
+	assert.Equal(t, "SEQ(SP=101%GCD=1)\nT5(R=Y%DF=Y%F=AR)", tests.String())
+	assert.Equal(t, map[string]string{"SEQ": "SP=101%GCD=1", "T5": "R=Y%DF=Y%F=AR"}, tests.Strings())
+
+	_, err = ParseOSTests("SEQ(SP)")
+	assert.Error(t, err)
+}
+
+func TestLoadNmapDBMatchOS(t *testing.T) {
+	db, err := LoadNmapDB("../data")
+	require.NoError(t, err)
+	assert.Empty(t, db.ParseErrors)
+	require.NotNil(t, db.OS)
+	assert.NotEmpty(t, db.OS.Fingerprints)
+	assert.Len(t, db.OSFingerprints, len(db.OS.Fingerprints))
+
+	// 每个参考指纹与自身的典型取值完全匹配
+	var ref *OSReference
+	for _, fp := range db.OS.Fingerprints {
+		if strings.HasPrefix(fp.Name, "Linux 5.0") {
+			ref = fp
+		}
+	}
+	require.NotNil(t, ref)
+	features := map[string]string{
+		"OPS":     ref.Tests.attrString("OPS"),
+		"WIN":     ref.Tests.attrString("WIN"),
+		"ignored": "not a test",
+	}
+	matches, err := db.MatchOS(features)
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	assert.Equal(t, 1.0, matches[0].Accuracy)
+
+	_, err = NewNmapDB().MatchOS(features)
+	assert.Error(t, err)
+}
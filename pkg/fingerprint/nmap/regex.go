@@ -0,0 +1,196 @@
+package nmap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compilePerlRegex 将nmap-service-probes中的Perl正则及其标志编译为Go正则
+// 编译后的正则按字节匹配，需配合latin1解码后的响应使用
+func compilePerlRegex(pattern, flags string) (*regexp.Regexp, error) {
+	translated, err := translatePerlRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	for _, flag := range flags {
+		switch flag {
+		case 'i', 's':
+			if !strings.ContainsRune(prefix, flag) {
+				prefix += string(flag)
+			}
+		default:
+			return nil, fmt.Errorf("不支持的正则标志: %c", flag)
+		}
+	}
+	if prefix != "" {
+		translated = "(?" + prefix + ")" + translated
+	}
+
+	re, err := regexp.Compile(translated)
+	if err != nil {
+		return nil, fmt.Errorf("编译正则失败: %v", err)
+	}
+	return re, nil
+}
+
+// translatePerlRegex 将Perl正则语法转换为Go正则语法
+// Go正则引擎不支持的反向引用和环视断言返回错误，原子分组和占有量词按普通分组和量词处理
+func translatePerlRegex(pattern string) (string, error) {
+	var b strings.Builder
+	inClass := false
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		// 非ASCII字节按单字节匹配
+		if c >= 0x80 {
+			fmt.Fprintf(&b, `\x{%02x}`, c)
+			continue
+		}
+
+		if c == '\\' {
+			if i+1 >= len(pattern) {
+				return "", fmt.Errorf("正则以反斜杠结尾")
+			}
+			i++
+			n := pattern[i]
+			switch {
+			case n >= 0x80:
+				fmt.Fprintf(&b, `\x{%02x}`, n)
+			case n >= '1' && n <= '9' && !inClass:
+				return "", fmt.Errorf("不支持反向引用: \\%c", n)
+			case n >= '0' && n <= '7':
+				// 八进制转义，最多三位
+				value := 0
+				j := i
+				for ; j < len(pattern) && j < i+3 && pattern[j] >= '0' && pattern[j] <= '7'; j++ {
+					value = value*8 + int(pattern[j]-'0')
+				}
+				if value > 0xff {
+					return "", fmt.Errorf("无效的八进制转义: \\%s", pattern[i:j])
+				}
+				fmt.Fprintf(&b, `\x{%02x}`, value)
+				i = j - 1
+			case n == 'e':
+				b.WriteString(`\x1b`)
+			case n == 'h':
+				if inClass {
+					b.WriteString(`\t `)
+				} else {
+					b.WriteString(`[\t ]`)
+				}
+			case n == 'Z' && !inClass:
+				b.WriteString(`(?:\n?\z)`)
+			case n == 'G' || n == 'K':
+				return "", fmt.Errorf("不支持的正则转义: \\%c", n)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(n)
+			}
+			continue
+		}
+
+		if inClass {
+			switch {
+			case c == '[' && i+1 < len(pattern) && pattern[i+1] == ':':
+				// POSIX字符类 [:alpha:]
+				end := strings.Index(pattern[i:], ":]")
+				if end < 0 {
+					return "", fmt.Errorf("未闭合的POSIX字符类")
+				}
+				b.WriteString(pattern[i : i+end+2])
+				i += end + 1
+			case c == '[':
+				b.WriteString(`\[`)
+			case c == ']':
+				inClass = false
+				b.WriteByte(c)
+			default:
+				b.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case '[':
+			inClass = true
+			b.WriteByte(c)
+			// 紧跟在[或[^之后的]是普通字符
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				b.WriteByte('^')
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				b.WriteString(`\]`)
+				i++
+			}
+		case '(':
+			if i+1 >= len(pattern) || pattern[i+1] != '?' {
+				b.WriteByte(c)
+				continue
+			}
+			rest := pattern[i+2:]
+			switch {
+			case strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, "!"):
+				return "", fmt.Errorf("不支持先行断言: (?%c", rest[0])
+			case strings.HasPrefix(rest, "<=") || strings.HasPrefix(rest, "<!"):
+				return "", fmt.Errorf("不支持后行断言: (?%s", rest[:2])
+			case strings.HasPrefix(rest, ">"):
+				// 原子分组按非捕获分组处理
+				b.WriteString("(?:")
+				i += 2
+			case strings.HasPrefix(rest, "<"):
+				// 命名分组
+				b.WriteString("(?P<")
+				i += 2
+			case strings.HasPrefix(rest, "#"):
+				// 注释
+				end := strings.IndexByte(rest, ')')
+				if end < 0 {
+					return "", fmt.Errorf("未闭合的正则注释")
+				}
+				i += 2 + end
+			default:
+				b.WriteString("(?")
+				i++
+			}
+		case '*', '+', '?', '}':
+			b.WriteByte(c)
+			// 占有量词按普通量词处理
+			if i+1 < len(pattern) && pattern[i+1] == '+' {
+				i++
+			}
+		case '$':
+			// Perl的$同时匹配结尾换行符之前的位置
+			b.WriteString(`(?:\n?\z)`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	if inClass {
+		return "", fmt.Errorf("未闭合的字符类")
+	}
+	return b.String(), nil
+}
+
+// latin1 将字节逐个映射为码点相同的字符，使Go正则按字节匹配任意二进制数据
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, c := range data {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// fromLatin1 将latin1解码后的字符串还原为原始字节
+func fromLatin1(s string) []byte {
+	data := make([]byte, 0, len(s))
+	for _, r := range s {
+		data = append(data, byte(r))
+	}
+	return data
+}
@@ -0,0 +1,429 @@
+package nmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultRarity 未声明rarity的探测的稀有度，与nmap一致
+const defaultRarity = 5
+
+// MatchRule nmap-service-probes中的match或softmatch规则
+type MatchRule struct {
+	Probe    string      // 所属探测名称
+	Service  string      // 服务名称
+	Pattern  string      // 原始Perl正则
+	Flags    string      // 正则标志(i、s)
+	Soft     bool        // 是否为softmatch
+	Template VersionInfo // 版本信息模板
+	Line     int         // 规则所在行号
+	Text     string      // 原始规则行
+	re       *regexp.Regexp
+}
+
+// Match 用规则匹配响应，未命中时返回nil
+func (r *MatchRule) Match(response []byte) *ServiceMatch {
+	return r.match(latin1(response))
+}
+
+// match 匹配latin1解码后的响应
+func (r *MatchRule) match(subject string) *ServiceMatch {
+	captures := r.re.FindStringSubmatch(subject)
+	if captures == nil {
+		return nil
+	}
+	groups := make([][]byte, len(captures))
+	for i, capture := range captures {
+		groups[i] = fromLatin1(capture)
+	}
+	return &ServiceMatch{
+		Probe:       r.Probe,
+		Service:     r.Service,
+		Soft:        r.Soft,
+		Line:        r.Line,
+		VersionInfo: r.Template.expand(groups),
+	}
+}
+
+// HasPort 判断端口是否在探测的ports列表中
+func (p *Probe) HasPort(port int) bool {
+	return portListContains(p.Ports, port)
+}
+
+// HasSSLPort 判断端口是否在探测的sslports列表中
+func (p *Probe) HasSSLPort(port int) bool {
+	return portListContains(p.SSLPorts, port)
+}
+
+// Match 用探测下的规则按顺序匹配响应
+// 返回第一个硬匹配；只有软匹配时返回第一个软匹配，之后只接受同一服务的硬匹配
+func (p *Probe) Match(response []byte) *ServiceMatch {
+	return p.match(latin1(response), nil)
+}
+
+// match 在已有软匹配soft的基础上匹配latin1解码后的响应
+func (p *Probe) match(subject string, soft *ServiceMatch) *ServiceMatch {
+	for _, rule := range p.Matches {
+		if soft != nil && (rule.Soft || rule.Service != soft.Service) {
+			continue
+		}
+		m := rule.match(subject)
+		if m == nil {
+			continue
+		}
+		if !rule.Soft {
+			return m
+		}
+		soft = m
+	}
+	return soft
+}
+
+// ServiceProbes 解析后的nmap-service-probes文件
+type ServiceProbes struct {
+	Exclude string        // Exclude指令排除的端口
+	Probes  []*Probe      // 按文件顺序排列的探测
+	Errors  []*ParseError // 解析失败而被跳过的行
+}
+
+// ParseError 探测文件中某一行的解析错误
+type ParseError struct {
+	File string // 所在文件，由加载器设置，直接解析时为空
+	Line int    // 行号
+	Text string // 原始行
+	Err  error  // 错误原因
+}
+
+// Error 实现error接口
+func (e *ParseError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s 第%d行: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("第%d行: %v", e.Line, e.Err)
+}
+
+// Rules 返回所有探测下的match和softmatch规则数量
+func (sp *ServiceProbes) Rules() int {
+	count := 0
+	for _, probe := range sp.Probes {
+		count += len(probe.Matches)
+	}
+	return count
+}
+
+// ParseServiceProbes 解析nmap-service-probes格式的数据
+// 单行的格式错误或无法转换的正则记录在Errors中并跳过，只有读取失败时返回错误
+func ParseServiceProbes(r io.Reader) (*ServiceProbes, error) {
+	sp := &ServiceProbes{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var current *Probe
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, args := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			directive, args = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		var err error
+		switch {
+		case directive == "Probe":
+			var probe *Probe
+			if probe, err = parseProbeLine(args); err == nil {
+				probe.Line = lineNo
+				sp.Probes = append(sp.Probes, probe)
+				current = probe
+			}
+		case directive == "Exclude":
+			sp.Exclude = args
+		case current == nil:
+			err = fmt.Errorf("%s指令出现在Probe之前", directive)
+		default:
+			err = current.parseDirective(directive, args, line, lineNo)
+		}
+		if err != nil {
+			sp.Errors = append(sp.Errors, &ParseError{Line: lineNo, Text: line, Err: err})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取探测规则失败: %v", err)
+	}
+	return sp, nil
+}
+
+// parseProbeLine 解析 "Probe <TCP|UDP> <名称> q|<探测数据>| [no-payload]"
+func parseProbeLine(args string) (*Probe, error) {
+	fields := strings.Fields(args)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("无效的Probe指令")
+	}
+	protocol := strings.ToUpper(fields[0])
+	if protocol != "TCP" && protocol != "UDP" {
+		return nil, fmt.Errorf("不支持的探测协议: %s", fields[0])
+	}
+
+	// 探测字符串可能包含空格，从名称之后整体截取
+	probeStr := strings.TrimSpace(args[len(fields[0]):])
+	probeStr = strings.TrimSpace(probeStr[len(fields[1]):])
+	if len(probeStr) < 3 || probeStr[0] != 'q' {
+		return nil, fmt.Errorf("探测字符串必须以q<分隔符>开头")
+	}
+	delim := probeStr[1]
+	end := strings.IndexByte(probeStr[2:], delim)
+	if end < 0 {
+		return nil, fmt.Errorf("探测字符串缺少结束分隔符")
+	}
+	payload, err := unescapeProbeString(probeStr[2 : 2+end])
+	if err != nil {
+		return nil, err
+	}
+
+	probe := &Probe{
+		Name:     fields[1],
+		Protocol: protocol,
+		ProbeStr: probeStr[:3+end],
+		Payload:  payload,
+		Rarity:   defaultRarity,
+	}
+	switch option := strings.TrimSpace(probeStr[3+end:]); option {
+	case "":
+	case "no-payload":
+		probe.NoPayload = true
+	default:
+		return nil, fmt.Errorf("未知的探测选项: %s", option)
+	}
+	return probe, nil
+}
+
+// parseDirective 解析Probe之后的指令行
+func (p *Probe) parseDirective(directive, args, line string, lineNo int) error {
+	var err error
+	switch directive {
+	case "match", "softmatch":
+		var rule *MatchRule
+		if rule, err = parseMatchRule(args, directive == "softmatch"); err == nil {
+			rule.Probe, rule.Line, rule.Text = p.Name, lineNo, line
+			p.Matches = append(p.Matches, rule)
+		}
+	case "ports":
+		p.Ports, err = args, validatePortList(args)
+	case "sslports":
+		p.SSLPorts, err = args, validatePortList(args)
+	case "rarity":
+		rarity, convErr := strconv.Atoi(args)
+		if convErr != nil || rarity < 1 || rarity > 9 {
+			return fmt.Errorf("rarity必须是1-9之间的整数: %s", args)
+		}
+		p.Rarity = rarity
+	case "totalwaitms":
+		if p.TotalWaitMS, err = strconv.Atoi(args); err != nil || p.TotalWaitMS < 0 {
+			err = fmt.Errorf("无效的totalwaitms: %s", args)
+		}
+	case "tcpwrappedms":
+		if p.TCPWrappedMS, err = strconv.Atoi(args); err != nil || p.TCPWrappedMS < 0 {
+			err = fmt.Errorf("无效的tcpwrappedms: %s", args)
+		}
+	case "fallback":
+		p.Fallback = nil
+		for _, name := range strings.Split(args, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				p.Fallback = append(p.Fallback, name)
+			}
+		}
+	default:
+		err = fmt.Errorf("未知的指令: %s", directive)
+	}
+	return err
+}
+
+// NewMatchRule 创建不来自nmap-service-probes文件的match规则，pattern使用与match指令相同的Perl正则语法
+func NewMatchRule(probe, service, pattern, flags string, soft bool, template VersionInfo) (*MatchRule, error) {
+	re, err := compilePerlRegex(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	return &MatchRule{
+		Probe:    probe,
+		Service:  service,
+		Pattern:  pattern,
+		Flags:    flags,
+		Soft:     soft,
+		Template: template,
+		re:       re,
+	}, nil
+}
+
+// parseMatchRule 解析 "<服务> m<分隔符><正则><分隔符>[标志] [版本信息]"
+func parseMatchRule(args string, soft bool) (*MatchRule, error) {
+	i := strings.IndexAny(args, " \t")
+	if i < 0 {
+		return nil, fmt.Errorf("match规则缺少正则")
+	}
+	rule := &MatchRule{Service: args[:i], Soft: soft}
+	rest := strings.TrimSpace(args[i+1:])
+	if len(rest) < 3 || rest[0] != 'm' {
+		return nil, fmt.Errorf("match规则的正则必须以m<分隔符>开头")
+	}
+
+	delim := rest[1]
+	end := strings.IndexByte(rest[2:], delim)
+	if end < 0 {
+		return nil, fmt.Errorf("match规则缺少结束分隔符")
+	}
+	rule.Pattern = rest[2 : 2+end]
+	rest = rest[3+end:]
+	for rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		rule.Flags, rest = rule.Flags+rest[:1], rest[1:]
+	}
+
+	re, err := compilePerlRegex(rule.Pattern, rule.Flags)
+	if err != nil {
+		return nil, err
+	}
+	rule.re = re
+
+	if rule.Template, err = parseVersionInfo(rest); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UnescapeString 按探测字符串的规则解码s中的\0、\r、\n、\xHH等转义，可用于命令行输入的横幅
+func UnescapeString(s string) ([]byte, error) {
+	return unescapeProbeString(s)
+}
+
+// EscapeString 按探测字符串的规则转义data，结果可由UnescapeString还原
+// \0、\r、\n、\t使用简写，反斜杠和双引号加反斜杠，其他不可打印字节使用\xHH
+func EscapeString(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		switch {
+		case c == 0:
+			b.WriteString(`\0`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\\' || c == '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unescapeProbeString 解码探测字符串中的\0、\r、\n、\xHH等转义
+func unescapeProbeString(s string) ([]byte, error) {
+	data := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			data = append(data, s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return nil, fmt.Errorf("探测字符串以反斜杠结尾")
+		}
+		i++
+		switch s[i] {
+		case '0':
+			data = append(data, 0)
+		case 'a':
+			data = append(data, '\a')
+		case 'b':
+			data = append(data, '\b')
+		case 'f':
+			data = append(data, '\f')
+		case 'n':
+			data = append(data, '\n')
+		case 'r':
+			data = append(data, '\r')
+		case 't':
+			data = append(data, '\t')
+		case 'v':
+			data = append(data, '\v')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, fmt.Errorf("不完整的十六进制转义: %s", s[i-1:])
+			}
+			value, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("无效的十六进制转义: \\x%s", s[i+1:i+3])
+			}
+			data = append(data, byte(value))
+			i += 2
+		default:
+			// \\和其他转义字符保留字符本身
+			data = append(data, s[i])
+		}
+	}
+	return data, nil
+}
+
+// ValidatePortList 校验 "80,443,8000-8010" 形式的端口列表
+func ValidatePortList(spec string) error {
+	return validatePortList(spec)
+}
+
+// validatePortList 校验 "80,443,8000-8010" 形式的端口列表
+func validatePortList(spec string) error {
+	for _, part := range strings.Split(spec, ",") {
+		low, high, err := parsePortRange(part)
+		if err != nil {
+			return err
+		}
+		if low > high {
+			return fmt.Errorf("无效的端口范围: %s", part)
+		}
+	}
+	return nil
+}
+
+// portListContains 判断端口是否在端口列表中，无效的项被忽略
+func portListContains(spec string, port int) bool {
+	if spec == "" {
+		return false
+	}
+	for _, part := range strings.Split(spec, ",") {
+		low, high, err := parsePortRange(part)
+		if err == nil && port >= low && port <= high {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePortRange 解析单个端口或端口范围
+func parsePortRange(part string) (int, int, error) {
+	part = strings.TrimSpace(part)
+	lowStr, highStr, isRange := strings.Cut(part, "-")
+	low, err := strconv.Atoi(lowStr)
+	if err != nil || low < 0 || low > 65535 {
+		return 0, 0, fmt.Errorf("无效的端口: %s", part)
+	}
+	if !isRange {
+		return low, low, nil
+	}
+	high, err := strconv.Atoi(highStr)
+	if err != nil || high < 0 || high > 65535 {
+		return 0, 0, fmt.Errorf("无效的端口: %s", part)
+	}
+	return low, high, nil
+}
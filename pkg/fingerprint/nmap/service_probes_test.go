@@ -0,0 +1,244 @@
+package nmap
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testServiceProbes = `# 测试用探测文件
+Exclude T:9100-9107
+
+Probe TCP NULL q||
+totalwaitms 6000
+tcpwrappedms 3000
+match ftp m|^220 ProFTPD (\d[\w.]+) Server \(([^)]+)\)| p/ProFTPD/ v/$1/ h/$2/ cpe:/a:proftpd:proftpd:$1/
+softmatch ftp m|^220[- ]|
+match ftp m|^220.*vsFTPd (\d[\d.]+)|s p/vsftpd/ v/$1/ cpe:/a:beasts:vsftpd:$1/a
+match ssh m|^SSH-([\d.]+)-OpenSSH_([\w._-]+)[ -]Ubuntu-([^\r\n]+)\r?\n| p/OpenSSH/ v/$SUBST(2,"p"," p")/ i/Ubuntu $3; protocol $1/ o/Linux/ cpe:/o:linux:linux_kernel/a
+
+Probe TCP GetRequest q|GET / HTTP/1.0\r\n\r\n|
+rarity 1
+ports 80,8000-8010
+sslports 443
+fallback NULL
+match http m|^HTTP/1\.[01] \d\d\d .*?\r\nserver: nginx/([\d.]+)|si p/nginx/ v/$1/
+match http m|^HTTP/1\.[01] \d\d\d (?=OK)| p/lookahead/
+
+Probe UDP Binary q|\x00\x01\xff\0\\|
+rarity 8
+ports 7
+match echo m|^\x00\x01\xff| p/echo/ d/general purpose/
+match count m|^\x02(..)| p/counter/ i/$I(1,">") items/ v/$P(1)/
+match broken m|^(unclosed| p/broken/
+rarity 12
+`
+
+func parseTestProbes(t *testing.T) *ServiceProbes {
+	sp, err := ParseServiceProbes(strings.NewReader(testServiceProbes))
+	require.NoError(t, err)
+	return sp
+}
+
+func TestParseServiceProbes(t *testing.T) {
+	sp := parseTestProbes(t)
+	assert.Equal(t, "T:9100-9107", sp.Exclude)
+	require.Len(t, sp.Probes, 3)
+	assert.Equal(t, 7, sp.Rules())
+
+	null := sp.Probes[0]
+	assert.Equal(t, "TCP", null.Protocol)
+	assert.Empty(t, null.Payload)
+	assert.Equal(t, 5, null.Rarity)
+	assert.Equal(t, 6000, null.TotalWaitMS)
+	assert.Equal(t, 3000, null.TCPWrappedMS)
+	assert.True(t, null.Matches[1].Soft)
+	assert.Equal(t, []string{"cpe:/a:proftpd:proftpd:$1"}, null.Matches[0].Template.CPE)
+
+	get := sp.Probes[1]
+	assert.Equal(t, []byte("GET / HTTP/1.0\r\n\r\n"), get.Payload)
+	assert.Equal(t, 1, get.Rarity)
+	assert.Equal(t, []string{"NULL"}, get.Fallback)
+	assert.True(t, get.HasPort(8005))
+	assert.False(t, get.HasPort(443))
+	assert.True(t, get.HasSSLPort(443))
+	assert.Equal(t, "si", get.Matches[0].Flags)
+
+	binary := sp.Probes[2]
+	assert.Equal(t, "UDP", binary.Protocol)
+	assert.Equal(t, []byte{0x00, 0x01, 0xff, 0x00, '\\'}, binary.Payload)
+	assert.Equal(t, 8, binary.Rarity, "越界的rarity不覆盖已有值")
+
+	// 环视断言、未闭合的正则和越界的rarity记录为行错误
+	require.Len(t, sp.Errors, 3)
+	assert.Equal(t, 18, sp.Errors[0].Line)
+	assert.Contains(t, sp.Errors[0].Error(), "先行断言")
+	assert.Equal(t, 25, sp.Errors[1].Line)
+	assert.Equal(t, 26, sp.Errors[2].Line)
+}
+
+func TestProbeMatch(t *testing.T) {
+	sp := parseTestProbes(t)
+	null, get, binary := sp.Probes[0], sp.Probes[1], sp.Probes[2]
+
+	m := null.Match([]byte("220 ProFTPD 1.3.5e Server (Debian) [::ffff:192.0.2.1]\r\n"))
+	require.NotNil(t, m)
+	assert.Equal(t, "ftp", m.Service)
+	assert.False(t, m.Soft)
+	assert.Equal(t, "ProFTPD", m.Product)
+	assert.Equal(t, "1.3.5e", m.Version)
+	assert.Equal(t, "Debian", m.Hostname)
+	assert.Equal(t, []string{"cpe:/a:proftpd:proftpd:1.3.5e"}, m.CPE)
+	assert.Equal(t, 7, m.Line)
+
+	// 软匹配之后只接受同一服务的硬匹配
+	m = null.Match([]byte("220-Welcome\r\n220 (vsFTPd 3.0.3)\r\n"))
+	require.NotNil(t, m)
+	assert.False(t, m.Soft)
+	assert.Equal(t, "vsftpd", m.Product)
+	assert.Equal(t, "3.0.3", m.Version)
+
+	m = null.Match([]byte("220 some ftp server\r\n"))
+	require.NotNil(t, m)
+	assert.True(t, m.Soft)
+	assert.Equal(t, "ftp", m.Service)
+	assert.Empty(t, m.Product)
+
+	m = null.Match([]byte("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n"))
+	require.NotNil(t, m)
+	assert.Equal(t, "8.9 p1", m.Version)
+	assert.Equal(t, "Ubuntu 3ubuntu0.6; protocol 2.0", m.Info)
+	assert.Equal(t, "Linux", m.OS)
+
+	// i标志忽略大小写，s标志使.匹配换行
+	m = get.Match([]byte("HTTP/1.1 200 OK\r\nDate: now\r\nServer: nginx/1.24.0\r\n\r\n"))
+	require.NotNil(t, m)
+	assert.Equal(t, "1.24.0", m.Version)
+	assert.Nil(t, get.Match([]byte("SSH-2.0-OpenSSH_8.9\r\n")))
+
+	// 二进制响应按字节匹配
+	m = binary.Match([]byte{0x00, 0x01, 0xff, 0x80})
+	require.NotNil(t, m)
+	assert.Equal(t, "echo", m.Service)
+	assert.Equal(t, "general purpose", m.DeviceType)
+	m = binary.Match([]byte{0x02, 0x01, 'A'})
+	require.NotNil(t, m)
+	assert.Equal(t, "321 items", m.Info)
+	assert.Equal(t, "A", m.Version)
+}
+
+func TestTranslatePerlRegex(t *testing.T) {
+	for pattern, want := range map[string]string{
+		`^foo$`:          `^foo(?:\n?\z)`,
+		`a\$b`:           `a\$b`,
+		`[$]\0\e`:        `[$]\x{00}\x1b`,
+		`(?>a++)b*+`:     `(?:a+)b*`,
+		`(?<ver>\d+)\Z`:  `(?P<ver>\d+)(?:\n?\z)`,
+		`x(?#comment)y`:  `xy`,
+		"\xe4\\h":        `\x{e4}[\t ]`,
+		`[]a][^]b]\d{2}`: `[\]a][^\]b]\d{2}`,
+	} {
+		got, err := translatePerlRegex(pattern)
+		require.NoError(t, err, pattern)
+		assert.Equal(t, want, got, pattern)
+	}
+
+	for _, pattern := range []string{`(a)\1`, `a(?!b)`, `(?<=a)b`, `[abc`, `abc\`} {
+		_, err := translatePerlRegex(pattern)
+		assert.Error(t, err, pattern)
+	}
+
+	_, err := compilePerlRegex("abc", "x")
+	assert.Error(t, err)
+}
+
+func TestParseVersionInfo(t *testing.T) {
+	info, err := parseVersionInfo(`p|Apache httpd| v/$1/ i/(Ubuntu)/ d/router/ o/Linux/ h/$2/ cpe:/a:apache:http_server:$1/ cpe:/o:linux:linux_kernel/a`)
+	require.NoError(t, err)
+	assert.Equal(t, "Apache httpd", info.Product)
+	assert.Equal(t, "router", info.DeviceType)
+	assert.Equal(t, []string{"cpe:/a:apache:http_server:$1", "cpe:/o:linux:linux_kernel"}, info.CPE)
+
+	expanded := info.expand([][]byte{[]byte("all"), []byte("2.4.58"), []byte("web01")})
+	assert.Equal(t, "2.4.58", expanded.Version)
+	assert.Equal(t, "web01", expanded.Hostname)
+	assert.Equal(t, "cpe:/a:apache:http_server:2.4.58", expanded.CPE[0])
+
+	for _, bad := range []string{"x/unknown/", "p/unterminated", "v/1.0/b"} {
+		_, err := parseVersionInfo(bad)
+		assert.Error(t, err, bad)
+	}
+
+	assert.Equal(t, "1.2", expandTemplate(`$SUBST(1,"_",".")`, [][]byte{nil, []byte("1_2")}))
+	assert.Equal(t, "258", expandTemplate(`$I(1,"<")`, [][]byte{nil, {0x02, 0x01}}))
+	assert.Equal(t, " $X", expandTemplate(`$9 $X`, nil))
+}
+
+func TestLoadNmapDBMatchResponse(t *testing.T) {
+	db, err := LoadNmapDB("../data")
+	require.NoError(t, err)
+	assert.Empty(t, db.ParseErrors)
+	assert.NotEmpty(t, db.ServiceProbes)
+
+	m := db.MatchResponse("tcp", 22, []byte("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n"))
+	require.NotNil(t, m)
+	assert.Equal(t, "ssh", m.Service)
+	assert.Equal(t, "OpenSSH", m.Product)
+	assert.Equal(t, "8.9p1", m.Version)
+	assert.Equal(t, "SSHVersionString", m.Probe)
+
+	// 与蜜罐默认banner相同的真实服务仍识别为OpenSSH，蜜罐签名不在服务指纹库中
+	m = db.MatchResponse("tcp", 22, []byte("SSH-2.0-OpenSSH_5.1p1 Debian-5\r\n"))
+	require.NotNil(t, m)
+	assert.Equal(t, "OpenSSH", m.Product)
+	assert.Equal(t, "5.1p1", m.Version)
+
+	m, err = db.MatchProbeResponse("GetRequest", []byte("HTTP/1.1 200 OK\r\nDate: now\r\nServer: nginx/1.24.0\r\n\r\n"))
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "nginx", m.Product)
+	assert.Equal(t, "1.24.0", m.Version)
+	assert.Equal(t, []string{"cpe:/a:igor_sysoev:nginx:1.24.0"}, m.CPE)
+
+	m, err = db.MatchProbeResponse("GetRequest", []byte("HTTP/1.0 404 Not Found\r\n\r\n"))
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.True(t, m.Soft)
+
+	_, err = db.MatchProbeResponse("Missing", nil)
+	assert.Error(t, err)
+	assert.Nil(t, db.MatchResponse("tcp", 12345, []byte("hello")))
+
+	matches, err := db.MatchService(map[string]string{"ssh": "SSH-2.0-OpenSSH_9.6\r\n"})
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	assert.Equal(t, "ssh", matches[0].Name)
+	assert.Equal(t, "OpenSSH", matches[0].Features["product"])
+}
+
+func TestLoadNmapDBFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"share/nmap-service-probes": {Data: []byte(testServiceProbes)},
+		"share/nmap-os-db":          {Data: []byte("Fingerprint Test OS\nT1(R=Y)\n")},
+	}
+	db, err := LoadNmapDBFS(fsys, "share")
+	require.NoError(t, err)
+	assert.Len(t, db.ServiceProbes, 3)
+	require.NotNil(t, db.OS)
+	assert.Contains(t, db.OSFingerprints, "Test OS")
+
+	_, err = LoadNmapDBFS(fsys, "missing")
+	assert.Error(t, err)
+}
+
+func TestParseServiceProbesReadError(t *testing.T) {
+	file, err := os.Open(t.TempDir())
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = ParseServiceProbes(file)
+	assert.Error(t, err)
+}
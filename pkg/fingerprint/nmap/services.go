@@ -0,0 +1,78 @@
+package nmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ServiceEntry nmap-services中的一行：服务名称与端口的对应关系
+type ServiceEntry struct {
+	Name      string  // 服务名称
+	Port      int     // 端口
+	Protocol  string  // 协议 (tcp/udp/sctp)
+	Frequency float64 // 端口开放频率，嵌入的精简文件中没有该列时为0
+	Comment   string  // 说明
+}
+
+// Services 解析后的nmap-services
+type Services struct {
+	Entries []ServiceEntry // 按文件顺序排列的条目
+	Errors  []*ParseError  // 解析失败而被跳过的行
+}
+
+// ParseServices 解析nmap-services格式的数据
+// 每行为 "名称 端口/协议 [频率] [# 说明]"，频率列可省略，省略时其后的文字作为说明
+func ParseServices(r io.Reader) (*Services, error) {
+	services := &Services{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseServiceEntry(line)
+		if err != nil {
+			services.Errors = append(services.Errors, &ParseError{Line: lineNo, Text: line, Err: err})
+			continue
+		}
+		services.Entries = append(services.Entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取服务端口列表失败: %v", err)
+	}
+	return services, nil
+}
+
+// parseServiceEntry 解析单行服务条目
+func parseServiceEntry(line string) (ServiceEntry, error) {
+	body, comment, _ := strings.Cut(line, "#")
+	fields := strings.Fields(body)
+	if len(fields) < 2 {
+		return ServiceEntry{}, fmt.Errorf("缺少端口/协议字段")
+	}
+
+	portStr, protocol, ok := strings.Cut(fields[1], "/")
+	if !ok || protocol == "" {
+		return ServiceEntry{}, fmt.Errorf("无效的端口/协议: %s", fields[1])
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return ServiceEntry{}, fmt.Errorf("无效的端口: %s", portStr)
+	}
+
+	entry := ServiceEntry{Name: fields[0], Port: port, Protocol: strings.ToLower(protocol)}
+	rest := fields[2:]
+	if len(rest) > 0 {
+		if freq, err := strconv.ParseFloat(rest[0], 64); err == nil {
+			entry.Frequency = freq
+			rest = rest[1:]
+		}
+	}
+	entry.Comment = strings.TrimSpace(strings.Join(append(rest, strings.TrimSpace(comment)), " "))
+	return entry, nil
+}
@@ -0,0 +1,84 @@
+package nmap
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testServices = `# nmap-services
+ssh	22/tcp	0.182286	# Secure Shell Login
+http 80/tcp World Wide Web HTTP
+domain	53/udp	0.213496	# Domain Name Server
+www	80/tcp	0.001
+broken	notaport/tcp
+missing
+`
+
+func TestParseServices(t *testing.T) {
+	services, err := ParseServices(strings.NewReader(testServices))
+	require.NoError(t, err)
+	require.Len(t, services.Entries, 4)
+	assert.Equal(t, ServiceEntry{Name: "ssh", Port: 22, Protocol: "tcp", Frequency: 0.182286, Comment: "Secure Shell Login"},
+		services.Entries[0])
+	// 嵌入的精简格式没有频率列
+	assert.Equal(t, ServiceEntry{Name: "http", Port: 80, Protocol: "tcp", Comment: "World Wide Web HTTP"},
+		services.Entries[1])
+
+	require.Len(t, services.Errors, 2)
+	assert.Equal(t, 6, services.Errors[0].Line)
+	assert.Equal(t, 7, services.Errors[1].Line)
+
+	db := NewNmapDB()
+	db.SetServices(services)
+	assert.Equal(t, "http", db.ServiceName("TCP", 80))
+	assert.Equal(t, "domain", db.ServiceName("udp", 53))
+	assert.Equal(t, "", db.ServiceName("tcp", 53))
+}
+
+func TestLoadNmapDBFSVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"nmap-service-probes": {Data: []byte("Probe TCP NULL q||\nmatch ssh m|^SSH-|\nmatch broken m|[|\n")},
+		"nmap-os-db":          {Data: []byte("Fingerprint Test OS\nT1(R=Y)\n")},
+		"nmap-services":       {Data: []byte(testServices)},
+	}
+	db, err := LoadNmapDBFS(fsys, ".")
+	require.NoError(t, err)
+	assert.Equal(t, "ssh", db.ServiceName("tcp", 22))
+	assert.Len(t, db.Version, 12)
+
+	// 解析错误记录所在文件
+	var files []string
+	for _, e := range db.ParseErrors {
+		files = append(files, e.File)
+	}
+	assert.Equal(t, []string{ServiceProbesFile, ServicesFile, ServicesFile}, files)
+	assert.True(t, strings.HasPrefix(db.ParseErrors[0].Error(), "nmap-service-probes 第"))
+
+	stats := db.Stats()
+	assert.Equal(t, 1, stats.Probes)
+	assert.Equal(t, 1, stats.OSFingerprints)
+	assert.Equal(t, 4, stats.Services)
+	assert.Equal(t, 3, stats.ParseErrors)
+	assert.Equal(t, 1, stats.Matches)
+
+	// 内容不同时版本不同，VERSION文件优先
+	fsys["nmap-os-db"] = &fstest.MapFile{Data: []byte("Fingerprint Other OS\nT1(R=Y)\n")}
+	other, err := LoadNmapDBFS(fsys, ".")
+	require.NoError(t, err)
+	assert.NotEqual(t, db.Version, other.Version)
+
+	fsys["VERSION"] = &fstest.MapFile{Data: []byte("2024-03 release\n")}
+	versioned, err := LoadNmapDBFS(fsys, ".")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03 release", versioned.Version)
+}
+
+func TestUnescapeString(t *testing.T) {
+	data, err := UnescapeString(`SSH-2.0\r\n\x00`)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("SSH-2.0\r\n\x00"), data)
+}
@@ -1,5 +1,17 @@
 package nmap
 
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
 // NmapFingerprint Nmap指纹结构
 type NmapFingerprint struct {
 	Name        string            // 指纹名称
@@ -9,21 +21,39 @@ type NmapFingerprint struct {
 	Probes      []Probe           // 探测规则
 	MatchLines  []string          // 匹配规则
 	SoftMatches []string          // 软匹配规则
+	Rule        *MatchRule        // 服务指纹对应的match规则
 }
 
 // Probe Nmap探测规则
 type Probe struct {
-	Name     string // 探测名称
-	Protocol string // 协议
-	ProbeStr string // 探测字符串
-	Ports    string // 端口
+	Name         string       // 探测名称
+	Protocol     string       // 协议 (TCP/UDP)
+	ProbeStr     string       // 探测字符串，如 q|GET / HTTP/1.0\r\n\r\n|
+	Payload      []byte       // 解码后的探测数据
+	NoPayload    bool         // 是否声明了no-payload
+	TLSOnly      bool         // 只通过TLS连接发送，用于用户定义的规则
+	Ports        string       // 端口
+	SSLPorts     string       // 需要先建立TLS连接的端口
+	Rarity       int          // 稀有度 (1-9)
+	Fallback     []string     // 回退探测，匹配失败时继续使用其规则
+	TotalWaitMS  int          // 等待响应的总时长(毫秒)
+	TCPWrappedMS int          // 判断tcpwrapped的时长(毫秒)
+	Matches      []*MatchRule // match和softmatch规则，保持文件中的顺序
+	Line         int          // Probe指令所在行号
 }
 
 // NmapDB Nmap指纹数据库
 type NmapDB struct {
 	OSFingerprints      map[string]*NmapFingerprint // 操作系统指纹
+	OS                  *OSDB                       // 解析后的nmap-os-db
 	ServiceFingerprints map[string]*NmapFingerprint // 服务指纹
 	Probes              map[string]*Probe           // 探测规则
+	ServiceProbes       []*Probe                    // 按文件顺序排列的探测规则
+	Services            []ServiceEntry              // nmap-services中的服务端口列表
+	ParseErrors         []*ParseError               // 解析失败被跳过的行，包括探测规则和操作系统指纹
+	Version             string                      // 数据库版本，取自VERSION文件，没有时为文件内容的摘要
+	serviceOrder        []*NmapFingerprint          // 按规则顺序排列的服务指纹
+	serviceNames        map[string]string           // 协议/端口 -> 服务名称
 }
 
 // NewNmapDB 创建新的Nmap数据库
@@ -35,46 +65,123 @@ func NewNmapDB() *NmapDB {
 	}
 }
 
-// LoadNmapDB 加载Nmap指纹数据库
+// LoadNmapDB 从Nmap共享目录加载指纹数据库
 func LoadNmapDB(nmapSharePath string) (*NmapDB, error) {
+	return LoadNmapDBFS(os.DirFS(nmapSharePath), ".")
+}
+
+// LoadNmapDBFS 从fsys的dir目录加载指纹数据库，可直接读取embed.FS中的数据
+// 正则在加载时编译，加载完成后数据库只读，可由多个goroutine共享
+func LoadNmapDBFS(fsys fs.FS, dir string) (*NmapDB, error) {
 	db := NewNmapDB()
 
+	probes, err := fs.ReadFile(fsys, path.Join(dir, ServiceProbesFile))
+	if err != nil {
+		return nil, fmt.Errorf("打开探测规则文件失败: %v", err)
+	}
+	osdb, err := fs.ReadFile(fsys, path.Join(dir, OSDBFile))
+	if err != nil {
+		return nil, fmt.Errorf("打开操作系统指纹文件失败: %v", err)
+	}
+
 	// 加载探测规则
-	if err := db.loadProbes(nmapSharePath); err != nil {
+	if err := db.loadProbes(probes); err != nil {
 		return nil, err
 	}
 
 	// 加载操作系统指纹
-	if err := db.loadOSFingerprints(nmapSharePath); err != nil {
+	if err := db.loadOSFingerprints(osdb); err != nil {
 		return nil, err
 	}
 
-	// 加载服务指纹
-	if err := db.loadServiceFingerprints(nmapSharePath); err != nil {
-		return nil, err
+	// 服务端口列表是可选的
+	if services, err := fs.ReadFile(fsys, path.Join(dir, ServicesFile)); err == nil {
+		if err := db.loadServices(services); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("打开服务端口文件失败: %v", err)
+	}
+
+	if version, err := fs.ReadFile(fsys, path.Join(dir, VersionFile)); err == nil && len(bytes.TrimSpace(version)) > 0 {
+		db.Version = string(bytes.TrimSpace(version))
+	} else {
+		db.Version = ContentVersion(probes, osdb)
 	}
 
 	return db, nil
 }
 
-// MatchOS 匹配操作系统指纹
-func (db *NmapDB) MatchOS(features map[string]string) ([]*NmapFingerprint, error) {
-	var matches []*NmapFingerprint
+// ContentVersion 由探测规则和操作系统指纹文件的内容计算版本摘要
+func ContentVersion(probes, osdb []byte) string {
+	h := sha256.New()
+	h.Write(probes)
+	h.Write([]byte{0})
+	h.Write(osdb)
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
 
-	for _, fp := range db.OSFingerprints {
-		if match := db.matchFingerprint(fp, features); match {
-			matches = append(matches, fp)
+// Stats 指纹库中各类条目的数量
+type Stats struct {
+	Version        string `json:"version"`         // 数据库版本
+	Probes         int    `json:"probes"`          // 探测数量
+	Matches        int    `json:"matches"`         // match规则数量
+	SoftMatches    int    `json:"softmatches"`     // softmatch规则数量
+	OSFingerprints int    `json:"os_fingerprints"` // 操作系统参考指纹数量
+	Services       int    `json:"services"`        // 服务端口条目数量
+	ParseErrors    int    `json:"parse_errors"`    // 解析失败的行数
+}
+
+// Stats 统计指纹库的条目数量
+func (db *NmapDB) Stats() Stats {
+	stats := Stats{
+		Version:     db.Version,
+		Probes:      len(db.ServiceProbes),
+		Services:    len(db.Services),
+		ParseErrors: len(db.ParseErrors),
+	}
+	for _, probe := range db.ServiceProbes {
+		for _, rule := range probe.Matches {
+			if rule.Soft {
+				stats.SoftMatches++
+			} else {
+				stats.Matches++
+			}
 		}
 	}
+	if db.OS != nil {
+		stats.OSFingerprints = len(db.OS.Fingerprints)
+	}
+	return stats
+}
 
-	return matches, nil
+// MatchOS 按MatchPoints为操作系统参考指纹打分，features为测试名到属性字符串的映射
+// 如 "SEQ" -> "SP=101%GCD=1%ISR=10A"，无法解析的特征被忽略；
+// 返回匹配度不低于OSGuessThreshold的结果，按匹配度降序排列
+func (db *NmapDB) MatchOS(features map[string]string) ([]*OSMatch, error) {
+	if db.OS == nil {
+		return nil, fmt.Errorf("未加载操作系统指纹库")
+	}
+	subject := make(OSTests)
+	for name, value := range features {
+		if osTestIndex(name) < 0 {
+			continue
+		}
+		if _, attrs, err := parseOSTestLine(name + "(" + value + ")"); err == nil {
+			subject[name] = attrs
+		}
+	}
+	if len(subject) == 0 {
+		return nil, nil
+	}
+	return db.OS.Match(subject, OSGuessThreshold), nil
 }
 
 // MatchService 匹配服务指纹
 func (db *NmapDB) MatchService(features map[string]string) ([]*NmapFingerprint, error) {
 	var matches []*NmapFingerprint
 
-	for _, fp := range db.ServiceFingerprints {
+	for _, fp := range db.serviceOrder {
 		if match := db.matchFingerprint(fp, features); match {
 			matches = append(matches, fp)
 		}
@@ -83,8 +190,81 @@ func (db *NmapDB) MatchService(features map[string]string) ([]*NmapFingerprint,
 	return matches, nil
 }
 
-// matchFingerprint 匹配指纹
+// MatchResponse 按nmap的顺序匹配端口上收到的响应
+// 先使用NULL探测的规则，再依次使用ports包含该端口的同协议探测的规则，硬匹配优先于软匹配
+func (db *NmapDB) MatchResponse(protocol string, port int, response []byte) *ServiceMatch {
+	if len(response) == 0 {
+		return nil
+	}
+	subject := latin1(response)
+	protocol = strings.ToUpper(protocol)
+
+	var soft *ServiceMatch
+	for _, probe := range db.candidateProbes(protocol, port) {
+		if m := probe.match(subject, soft); m != nil {
+			if !m.Soft {
+				return m
+			}
+			soft = m
+		}
+	}
+	return soft
+}
+
+// MatchProbeResponse 使用指定探测及其fallback探测的规则匹配响应，TCP探测最后回退到NULL探测
+func (db *NmapDB) MatchProbeResponse(probeName string, response []byte) (*ServiceMatch, error) {
+	probe, ok := db.Probes[probeName]
+	if !ok {
+		return nil, fmt.Errorf("探测规则不存在: %s", probeName)
+	}
+	subject := latin1(response)
+
+	names := append([]string{probe.Name}, probe.Fallback...)
+	if probe.Protocol == "TCP" {
+		names = append(names, "NULL")
+	}
+	var soft *ServiceMatch
+	seen := make(map[string]bool)
+	for _, name := range names {
+		candidate, ok := db.Probes[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if m := candidate.match(subject, soft); m != nil {
+			if !m.Soft {
+				return m, nil
+			}
+			soft = m
+		}
+	}
+	return soft, nil
+}
+
+// candidateProbes 返回匹配端口响应时使用的探测，NULL探测在前
+func (db *NmapDB) candidateProbes(protocol string, port int) []*Probe {
+	var probes []*Probe
+	if null, ok := db.Probes["NULL"]; ok && (protocol == "" || null.Protocol == protocol) {
+		probes = append(probes, null)
+	}
+	for _, probe := range db.ServiceProbes {
+		if probe.Name == "NULL" || (protocol != "" && probe.Protocol != protocol) || (!probe.HasPort(port) && !probe.HasSSLPort(port)) {
+			continue
+		}
+		probes = append(probes, probe)
+	}
+	return probes
+}
+
+// matchFingerprint 使用服务指纹对应的match规则匹配任一特征值
 func (db *NmapDB) matchFingerprint(fp *NmapFingerprint, features map[string]string) bool {
-	// TODO: 实现Nmap指纹匹配
+	if fp.Rule == nil {
+		return false
+	}
+	for _, value := range features {
+		if value != "" && fp.Rule.Match([]byte(value)) != nil {
+			return true
+		}
+	}
 	return false
 }
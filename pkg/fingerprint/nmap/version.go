@@ -0,0 +1,202 @@
+package nmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionInfo 版本信息，作为match规则中的模板或匹配后的结果
+type VersionInfo struct {
+	Product    string   `json:"product,omitempty"`     // 产品名称(p/)
+	Version    string   `json:"version,omitempty"`     // 版本号(v/)
+	Info       string   `json:"info,omitempty"`        // 附加信息(i/)
+	Hostname   string   `json:"hostname,omitempty"`    // 主机名(h/)
+	OS         string   `json:"os,omitempty"`          // 操作系统(o/)
+	DeviceType string   `json:"device_type,omitempty"` // 设备类型(d/)
+	CPE        []string `json:"cpe,omitempty"`         // CPE标识(cpe:/)
+}
+
+// ServiceMatch 服务匹配结果
+type ServiceMatch struct {
+	Probe   string `json:"probe"`          // 产生响应的探测名称
+	Service string `json:"service"`        // 服务名称
+	Soft    bool   `json:"soft,omitempty"` // 是否为软匹配
+	Line    int    `json:"line"`           // 命中规则在探测文件中的行号
+	VersionInfo
+}
+
+// parseVersionInfo 解析match规则中正则之后的版本信息模板，如 p/nginx/ v/$1/ cpe:/a:igor_sysoev:nginx:$1/
+func parseVersionInfo(s string) (VersionInfo, error) {
+	var info VersionInfo
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return info, nil
+		}
+
+		var field string
+		if strings.HasPrefix(s, "cpe:") {
+			field, s = "cpe", s[4:]
+		} else {
+			field, s = s[:1], s[1:]
+		}
+		if s == "" {
+			return info, fmt.Errorf("版本字段 %s 缺少分隔符", field)
+		}
+		delim := s[0]
+		end := strings.IndexByte(s[1:], delim)
+		if end < 0 {
+			return info, fmt.Errorf("版本字段 %s 缺少结束分隔符", field)
+		}
+		value := s[1 : 1+end]
+		s = s[2+end:]
+
+		// cpe字段可带a等标志，其他字段的分隔符后必须是空白
+		flags := ""
+		for s != "" && s[0] != ' ' && s[0] != '\t' {
+			flags, s = flags+s[:1], s[1:]
+		}
+		if flags != "" && (field != "cpe" || strings.Trim(flags, "a") != "") {
+			return info, fmt.Errorf("版本字段 %s 含无效标志: %s", field, flags)
+		}
+
+		switch field {
+		case "p":
+			info.Product = value
+		case "v":
+			info.Version = value
+		case "i":
+			info.Info = value
+		case "h":
+			info.Hostname = value
+		case "o":
+			info.OS = value
+		case "d":
+			info.DeviceType = value
+		case "cpe":
+			info.CPE = append(info.CPE, "cpe:/"+value)
+		default:
+			return info, fmt.Errorf("未知的版本字段: %s", field)
+		}
+	}
+}
+
+// expand 使用正则捕获组填充版本信息模板
+func (v VersionInfo) expand(groups [][]byte) VersionInfo {
+	result := VersionInfo{
+		Product:    expandTemplate(v.Product, groups),
+		Version:    expandTemplate(v.Version, groups),
+		Info:       expandTemplate(v.Info, groups),
+		Hostname:   expandTemplate(v.Hostname, groups),
+		OS:         expandTemplate(v.OS, groups),
+		DeviceType: expandTemplate(v.DeviceType, groups),
+	}
+	for _, cpe := range v.CPE {
+		result.CPE = append(result.CPE, expandTemplate(cpe, groups))
+	}
+	return result
+}
+
+// expandTemplate 替换模板中的$1、$P(1)、$SUBST(1,"a","b")和$I(1,">")
+func expandTemplate(tmpl string, groups [][]byte) string {
+	if !strings.Contains(tmpl, "$") {
+		return tmpl
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '$' || i+1 >= len(tmpl) {
+			b.WriteByte(tmpl[i])
+			continue
+		}
+		rest := tmpl[i+1:]
+
+		// $1 ~ $9
+		if rest[0] >= '1' && rest[0] <= '9' {
+			b.Write(group(groups, int(rest[0]-'0')))
+			i++
+			continue
+		}
+
+		name := ""
+		for _, prefix := range []string{"P(", "SUBST(", "I("} {
+			if strings.HasPrefix(rest, prefix) {
+				name = prefix[:len(prefix)-1]
+				break
+			}
+		}
+		end := strings.IndexByte(rest, ')')
+		if name == "" || end < 0 {
+			b.WriteByte(tmpl[i])
+			continue
+		}
+		args := splitTemplateArgs(rest[len(name)+1 : end])
+		index, err := strconv.Atoi(args[0])
+		if err != nil {
+			b.WriteByte(tmpl[i])
+			continue
+		}
+		value := group(groups, index)
+
+		switch name {
+		case "P":
+			// 只保留可打印字符
+			for _, c := range value {
+				if c >= 0x20 && c < 0x7f {
+					b.WriteByte(c)
+				}
+			}
+		case "SUBST":
+			if len(args) == 3 {
+				b.WriteString(strings.ReplaceAll(string(value), args[1], args[2]))
+			} else {
+				b.Write(value)
+			}
+		case "I":
+			// 按字节序解析无符号整数，">"为大端，"<"为小端
+			var n uint64
+			littleEndian := len(args) == 2 && args[1] == "<"
+			for j := range value {
+				c := value[j]
+				if littleEndian {
+					c = value[len(value)-1-j]
+				}
+				n = n<<8 | uint64(c)
+			}
+			if len(value) > 0 {
+				b.WriteString(strconv.FormatUint(n, 10))
+			}
+		}
+		i += end + 1
+	}
+	return b.String()
+}
+
+// splitTemplateArgs 拆分模板函数参数，去掉引号和引号外的空白
+func splitTemplateArgs(s string) []string {
+	var args []string
+	var current strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			args = append(args, current.String())
+			current.Reset()
+		case (c == ' ' || c == '\t') && !quoted:
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return append(args, current.String())
+}
+
+// group 返回指定序号的捕获组，不存在时返回空
+func group(groups [][]byte, index int) []byte {
+	if index <= 0 || index >= len(groups) {
+		return nil
+	}
+	return groups[index]
+}
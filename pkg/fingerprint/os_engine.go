@@ -0,0 +1,329 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// osProbeTTL 操作系统探测报文的TTL，用于根据U1应答计算跳数
+	osProbeTTL = 64
+	// osSeqInterval SEQ探测之间的间隔，与nmap相同
+	osSeqInterval = 100 * time.Millisecond
+	// osU1IPID U1探测的IP ID，应答中引用的IP ID与之比较
+	osU1IPID = 0x1042
+	// osIESeq 第一个IE探测的ICMP序列号
+	osIESeq = 295
+)
+
+// PacketTransport 原始IPv4报文的收发，与scanner.RawTransport的方法一致
+type PacketTransport interface {
+	// LocalIP 返回向目标发送探测时使用的源地址
+	LocalIP(target net.IP) (net.IP, error)
+	// WritePacket 发送完整的IPv4报文
+	WritePacket(data []byte, dst net.IP) error
+	// ReadPacket 读取一个IPv4报文，超过deadline时返回os.ErrDeadlineExceeded
+	ReadPacket(deadline time.Time) ([]byte, error)
+}
+
+// osProbeNames nmap第二代操作系统探测，按发送顺序排列
+var osProbeNames = []string{
+	"SEQ1", "SEQ2", "SEQ3", "SEQ4", "SEQ5", "SEQ6",
+	"IE1", "IE2", "ECN", "T2", "T3", "T4", "T5", "T6", "T7", "U1",
+}
+
+// osEngine 通过原始报文发送nmap的操作系统探测
+//
+// SEQ1-6向开放端口发送6个带不同TCP选项的SYN，间隔100ms；
+// IE1-2为两个ICMP回显请求；ECN为带ECE/CWR的SYN；
+// T2-T4向开放端口、T5-T7向关闭端口发送不同标志组合的TCP报文；U1向关闭的UDP端口发送300字节数据。
+type osEngine struct {
+	transport  PacketTransport
+	target     net.IP
+	source     net.IP
+	openPort   int
+	closedPort int
+	closedUDP  int
+	timeout    time.Duration
+	basePort   uint16
+	icmpID     uint16
+}
+
+// newOSEngine 创建操作系统探测引擎
+func newOSEngine(transport PacketTransport, target string, openPort, closedPort, closedUDP int, timeout time.Duration) (*osEngine, error) {
+	dst := net.ParseIP(target).To4()
+	if dst == nil {
+		return nil, fmt.Errorf("操作系统探测只支持IPv4地址: %s", target)
+	}
+	src, err := transport.LocalIP(dst)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &osEngine{
+		transport:  transport,
+		target:     dst,
+		source:     src.To4(),
+		openPort:   openPort,
+		closedPort: closedPort,
+		closedUDP:  closedUDP,
+		timeout:    timeout,
+		basePort:   uint16(40000 + rand.Intn(20000)),
+		icmpID:     uint16(rand.Intn(65535)),
+	}, nil
+}
+
+// run 发送全部探测并收集应答，每个探测对应一个ProbeResult，未应答时Response为空
+func (e *osEngine) run() ([]ProbeResult, error) {
+	results := make([]ProbeResult, len(osProbeNames))
+	for i, name := range osProbeNames {
+		data, port, protocol, err := e.buildProbe(i, name)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && i < 6 {
+			time.Sleep(osSeqInterval)
+		}
+		results[i] = ProbeResult{
+			Type:      name,
+			Target:    e.target.String(),
+			Port:      port,
+			Protocol:  protocol,
+			Data:      data,
+			Timestamp: time.Now(),
+			Features:  make(map[string]string),
+		}
+		if err := e.transport.WritePacket(data, e.target); err != nil {
+			return nil, fmt.Errorf("发送%s探测失败: %v", name, err)
+		}
+	}
+
+	// 收集应答直到全部探测都有应答或超时
+	pending := len(results)
+	deadline := time.Now().Add(e.timeout)
+	for pending > 0 {
+		data, err := e.transport.ReadPacket(deadline)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			return nil, fmt.Errorf("接收应答失败: %v", err)
+		}
+		if i := e.classify(data); i >= 0 && results[i].Response == nil {
+			results[i].Response = data
+			pending--
+		}
+	}
+	return results, nil
+}
+
+// buildProbe 构造第i个探测报文，返回报文、目标端口和协议
+func (e *osEngine) buildProbe(i int, name string) ([]byte, int, string, error) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      osProbeTTL,
+		Id:       uint16(rand.Intn(65536)),
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    e.source,
+		DstIP:    e.target,
+	}
+
+	switch name {
+	case "IE1", "IE2":
+		ip.Protocol = layers.IPProtocolICMPv4
+		icmp := &layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 9),
+			Id:       e.icmpID,
+			Seq:      osIESeq,
+		}
+		payload := make([]byte, 120)
+		if name == "IE1" {
+			ip.Flags = layers.IPv4DontFragment
+		} else {
+			ip.TOS = 4
+			icmp.TypeCode = layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0)
+			icmp.Id, icmp.Seq = e.icmpID+1, osIESeq+1
+			payload = make([]byte, 150)
+		}
+		data, err := serializeLayers(ip, icmp, gopacket.Payload(payload))
+		return data, 0, "icmp", err
+
+	case "U1":
+		ip.Protocol = layers.IPProtocolUDP
+		ip.Id = osU1IPID
+		udp := &layers.UDP{SrcPort: layers.UDPPort(e.basePort + uint16(i)), DstPort: layers.UDPPort(e.closedUDP)}
+		udp.SetNetworkLayerForChecksum(ip)
+		payload := make([]byte, 300)
+		for j := range payload {
+			payload[j] = 'C'
+		}
+		data, err := serializeLayers(ip, udp, gopacket.Payload(payload))
+		return data, e.closedUDP, "udp", err
+	}
+
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(e.basePort + uint16(i)),
+		DstPort: layers.TCPPort(e.openPort),
+		Seq:     rand.Uint32(),
+	}
+	tsOpt := tcpOption(layers.TCPOptionKindTimestamps, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0)
+	probeOpts := func(ws byte) []layers.TCPOption {
+		return []layers.TCPOption{
+			tcpOption(layers.TCPOptionKindWindowScale, ws), tcpOption(layers.TCPOptionKindNop),
+			tcpOption(layers.TCPOptionKindMSS, 0x01, 0x09), tsOpt, tcpOption(layers.TCPOptionKindSACKPermitted),
+		}
+	}
+
+	switch name {
+	case "SEQ1", "SEQ2", "SEQ3", "SEQ4", "SEQ5", "SEQ6":
+		tcp.SYN = true
+		tcp.Ack = rand.Uint32()
+		tcp.Window, tcp.Options = seqProbeOptions(i, tsOpt)
+	case "ECN":
+		tcp.SYN, tcp.ECE, tcp.CWR, tcp.NS = true, true, true, true
+		tcp.Urgent = 0xf7f5
+		tcp.Window = 3
+		tcp.Options = []layers.TCPOption{
+			tcpOption(layers.TCPOptionKindWindowScale, 10), tcpOption(layers.TCPOptionKindNop),
+			tcpOption(layers.TCPOptionKindMSS, 0x05, 0xb4), tcpOption(layers.TCPOptionKindSACKPermitted),
+			tcpOption(layers.TCPOptionKindNop), tcpOption(layers.TCPOptionKindNop),
+		}
+	case "T2":
+		ip.Flags = layers.IPv4DontFragment
+		tcp.Window, tcp.Options = 128, probeOpts(10)
+	case "T3":
+		tcp.SYN, tcp.FIN, tcp.URG, tcp.PSH = true, true, true, true
+		tcp.Window, tcp.Options = 256, probeOpts(10)
+	case "T4":
+		ip.Flags = layers.IPv4DontFragment
+		tcp.ACK, tcp.Ack = true, rand.Uint32()
+		tcp.Window, tcp.Options = 1024, probeOpts(10)
+	case "T5":
+		tcp.DstPort = layers.TCPPort(e.closedPort)
+		tcp.SYN = true
+		tcp.Window, tcp.Options = 31337, probeOpts(10)
+	case "T6":
+		ip.Flags = layers.IPv4DontFragment
+		tcp.DstPort = layers.TCPPort(e.closedPort)
+		tcp.ACK, tcp.Ack = true, rand.Uint32()
+		tcp.Window, tcp.Options = 32768, probeOpts(10)
+	case "T7":
+		tcp.DstPort = layers.TCPPort(e.closedPort)
+		tcp.FIN, tcp.PSH, tcp.URG = true, true, true
+		tcp.Window, tcp.Options = 65535, probeOpts(15)
+	default:
+		return nil, 0, "", fmt.Errorf("未知的操作系统探测: %s", name)
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+	data, err := serializeLayers(ip, tcp)
+	return data, int(tcp.DstPort), "tcp", err
+}
+
+// seqProbeOptions 返回第i个SEQ探测的窗口和TCP选项
+func seqProbeOptions(i int, ts layers.TCPOption) (uint16, []layers.TCPOption) {
+	nop := tcpOption(layers.TCPOptionKindNop)
+	eol := tcpOption(layers.TCPOptionKindEndList)
+	sack := tcpOption(layers.TCPOptionKindSACKPermitted)
+	ws := func(v byte) layers.TCPOption { return tcpOption(layers.TCPOptionKindWindowScale, v) }
+	mss := func(v uint16) layers.TCPOption { return tcpOption(layers.TCPOptionKindMSS, byte(v>>8), byte(v)) }
+
+	switch i {
+	case 0:
+		return 1, []layers.TCPOption{ws(10), nop, mss(1460), ts, sack}
+	case 1:
+		return 63, []layers.TCPOption{mss(1400), ws(0), sack, ts, eol}
+	case 2:
+		return 4, []layers.TCPOption{ts, nop, nop, ws(5), nop, mss(640)}
+	case 3:
+		return 4, []layers.TCPOption{sack, ts, ws(10), eol}
+	case 4:
+		return 16, []layers.TCPOption{mss(536), sack, ts, ws(10), eol}
+	default:
+		return 512, []layers.TCPOption{mss(265), sack, ts}
+	}
+}
+
+// tcpOption 构造TCP选项，EOL和NOP只有类型字节
+func tcpOption(kind layers.TCPOptionKind, data ...byte) layers.TCPOption {
+	if kind == layers.TCPOptionKindEndList || kind == layers.TCPOptionKindNop {
+		return layers.TCPOption{OptionType: kind, OptionLength: 1}
+	}
+	return layers.TCPOption{OptionType: kind, OptionLength: uint8(2 + len(data)), OptionData: data}
+}
+
+// serializeLayers 序列化报文并计算长度和校验和
+func serializeLayers(layerList ...gopacket.SerializableLayer) ([]byte, error) {
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, layerList...); err != nil {
+		return nil, fmt.Errorf("构造探测报文失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// classify 返回应答对应的探测序号，无关报文返回-1
+func (e *osEngine) classify(data []byte) int {
+	packet := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.NoCopy)
+	ip, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok || !ip.SrcIP.Equal(e.target) {
+		return -1
+	}
+
+	switch ip.Protocol {
+	case layers.IPProtocolTCP:
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok {
+			return -1
+		}
+		i := int(uint16(tcp.DstPort) - e.basePort)
+		if i < 0 || i >= len(osProbeNames) || osProbeNames[i] == "IE1" || osProbeNames[i] == "IE2" || osProbeNames[i] == "U1" {
+			return -1
+		}
+		return i
+	case layers.IPProtocolICMPv4:
+		icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+		if !ok {
+			return -1
+		}
+		switch icmp.TypeCode.Type() {
+		case layers.ICMPv4TypeEchoReply:
+			switch {
+			case icmp.Id == e.icmpID && icmp.Seq == osIESeq:
+				return probeIndex("IE1")
+			case icmp.Id == e.icmpID+1 && icmp.Seq == osIESeq+1:
+				return probeIndex("IE2")
+			}
+		case layers.ICMPv4TypeDestinationUnreachable:
+			// 引用的原始报文为U1探测时视为U1的应答
+			quoted := icmp.Payload
+			if len(quoted) < 20 || layers.IPProtocol(quoted[9]) != layers.IPProtocolUDP {
+				return -1
+			}
+			ihl := int(quoted[0]&0x0f) * 4
+			u1 := probeIndex("U1")
+			if len(quoted) >= ihl+2 && uint16(quoted[ihl])<<8|uint16(quoted[ihl+1]) == e.basePort+uint16(u1) {
+				return u1
+			}
+		}
+	}
+	return -1
+}
+
+// probeIndex 返回探测在osProbeNames中的序号
+func probeIndex(name string) int {
+	for i, probe := range osProbeNames {
+		if probe == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,151 @@
+package fingerprint
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner/scannertest"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOSTarget = "192.0.2.20"
+
+const testOSReferences = `MatchPoints
+OPS(O1=20%O2=20)
+WIN(W1=15%W2=15)
+T1(R=100%DF=20%F=30)
+T5(R=100%F=30%A=20)
+U1(R=50%RID=100)
+IE(R=50%CD=100)
+
+Fingerprint Simulated Linux
+Class Linux | Linux | 5.X | general purpose
+CPE cpe:/o:linux:linux_kernel:5 auto
+OPS(O1=M5B4ST11NW7%O2=M5B4ST11NW7)
+WIN(W1=FAF0|FE88%W2=FAF0|FE88)
+T1(R=Y%DF=Y%F=AS)
+T5(R=Y%F=AR%A=S+)
+U1(R=Y%RID=G)
+IE(R=Y%CD=S)
+
+Fingerprint Simulated Windows
+Class Microsoft | Windows | 10 | general purpose
+CPE cpe:/o:microsoft:windows_10
+OPS(O1=M5B4NW8ST11%O2=M5B4NW8ST11)
+WIN(W1=FFFF%W2=FFFF)
+T1(R=Y%DF=Y%F=AS)
+T5(R=Y%F=AR%A=S+)
+U1(R=N)
+IE(R=Y%CD=Z)
+`
+
+// newSimulatedOSHost 返回Linux风格的模拟主机所在的网络
+func newSimulatedOSHost() *scannertest.Network {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(&scannertest.Host{
+		IP:       net.ParseIP(testOSTarget),
+		DontFrag: true,
+		TCPOptions: []layers.TCPOption{
+			{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}},
+			{OptionType: layers.TCPOptionKindSACKPermitted, OptionLength: 2},
+			{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10, OptionData: []byte{0, 0, 0, 1, 0, 0, 0, 1}},
+			{OptionType: layers.TCPOptionKindNop},
+			{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{7}},
+		},
+		TCP: map[int]scannertest.PortState{22: scannertest.PortOpen},
+	})
+	return network
+}
+
+func TestOSEngineProbes(t *testing.T) {
+	transport := newSimulatedOSHost().RawConn()
+	engine, err := newOSEngine(transport, testOSTarget, 22, 23, 40000, 200*time.Millisecond)
+	require.NoError(t, err)
+	probes, err := engine.run()
+	require.NoError(t, err)
+	require.Len(t, probes, len(osProbeNames))
+
+	// 发往开放端口的无标志T2探测被丢弃，其余探测都有应答
+	for _, probe := range probes {
+		if probe.Type == "T2" {
+			assert.Empty(t, probe.Response)
+		} else {
+			assert.NotEmpty(t, probe.Response, probe.Type)
+		}
+	}
+	assert.Equal(t, "udp", probes[probeIndex("U1")].Protocol)
+	assert.Equal(t, 23, probes[probeIndex("T5")].Port)
+
+	tests := computeOSTests(probes)
+	assert.Equal(t, "M5B4ST11NW7", tests["OPS"]["O1"])
+	assert.Equal(t, "FAF0", tests["WIN"]["W6"])
+	assert.Equal(t, map[string]string{"R": "N"}, tests["T2"])
+	assert.Equal(t, "AR", tests["T5"]["F"])
+	assert.Equal(t, "Z", tests["T5"]["S"])
+	assert.Equal(t, "A", tests["T4"]["S"])
+	assert.Equal(t, "G", tests["U1"]["RUD"])
+	assert.Equal(t, "N", tests["IE"]["DFI"])
+	assert.Equal(t, "40", tests["IE"]["TG"])
+
+	_, err = newOSEngine(transport, "2001:db8::1", 22, 23, 40000, time.Second)
+	assert.Error(t, err)
+}
+
+func TestFingerprintOSGuesses(t *testing.T) {
+	osdb, err := nmap.ParseOSDB(strings.NewReader(testOSReferences))
+	require.NoError(t, err)
+	db := nmap.NewNmapDB()
+	db.SetOSDB(osdb)
+
+	opts := DefaultFingerprintOptions()
+	opts.Timeout = 200 * time.Millisecond
+	opts.Transport = newSimulatedOSHost().RawConn()
+	f := &Fingerprinter{opts: opts, db: db}
+
+	fp, err := f.FingerprintOS(testOSTarget, []int{22})
+	require.NoError(t, err)
+	require.Len(t, fp.Guesses, 1)
+	assert.Equal(t, OSGuess{
+		Name:       "Simulated Linux",
+		Accuracy:   100,
+		Vendor:     "Linux",
+		Family:     "Linux",
+		Generation: "5.X",
+		DeviceType: "general purpose",
+		CPE:        []string{"cpe:/o:linux:linux_kernel:5"},
+	}, fp.Guesses[0])
+	assert.Equal(t, "Simulated Linux", fp.Name)
+	assert.Equal(t, "Linux", fp.Family)
+	assert.Equal(t, 100.0, fp.Confidence)
+
+	// 没有开放端口时无法发送探测
+	_, err = f.FingerprintOS(testOSTarget, nil)
+	assert.Error(t, err)
+}
+
+func TestIPIDSequence(t *testing.T) {
+	assert.Equal(t, "Z", ipidSequence([]uint16{0, 0, 0}, true))
+	assert.Equal(t, "I", ipidSequence([]uint16{100, 101, 103}, true))
+	assert.Equal(t, "BI", ipidSequence([]uint16{256, 512, 1024}, true))
+	assert.Equal(t, "RI", ipidSequence([]uint16{100, 2100, 3100}, true))
+	assert.Equal(t, "RD", ipidSequence([]uint16{100, 30000, 60000}, true))
+	assert.Equal(t, "RI", ipidSequence([]uint16{100, 30000}, false))
+	assert.Equal(t, "2A", ipidSequence([]uint16{42, 42, 42}, true))
+}
+
+func TestTCPOptionsAttr(t *testing.T) {
+	options := []layers.TCPOption{
+		tcpOption(layers.TCPOptionKindMSS, 0x05, 0xb4),
+		tcpOption(layers.TCPOptionKindNop),
+		tcpOption(layers.TCPOptionKindWindowScale, 8),
+		tcpOption(layers.TCPOptionKindSACKPermitted),
+		tcpOption(layers.TCPOptionKindTimestamps, 0, 0, 0, 9, 0, 0, 0, 0),
+		tcpOption(layers.TCPOptionKindEndList),
+	}
+	assert.Equal(t, "M5B4NW8ST10L", tcpOptionsAttr(options))
+}
@@ -0,0 +1,592 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// osExchange 解码后的一次探测及其应答
+type osExchange struct {
+	sent     time.Time
+	probeIP  *layers.IPv4
+	probeTCP *layers.TCP
+	probeUDP *layers.UDP
+	ip       *layers.IPv4
+	tcp      *layers.TCP
+	icmp     *layers.ICMPv4
+}
+
+// replied 判断探测是否收到应答
+func (x *osExchange) replied() bool {
+	return x != nil && x.ip != nil
+}
+
+// decodeOSExchanges 解码nmap操作系统探测的报文和应答，以探测名为键
+// 只处理Data为IPv4报文的探测结果
+func decodeOSExchanges(probes []ProbeResult) map[string]*osExchange {
+	exchanges := make(map[string]*osExchange)
+	for _, probe := range probes {
+		// 连接探测的同名结果没有原始报文，不参与计算
+		if probeIndex(probe.Type) < 0 || len(probe.Data) == 0 {
+			continue
+		}
+		packet := gopacket.NewPacket(probe.Data, layers.LayerTypeIPv4, gopacket.Default)
+		if packet.ErrorLayer() != nil {
+			continue
+		}
+		x := &osExchange{sent: probe.Timestamp}
+		x.probeIP, _ = packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+		x.probeTCP, _ = packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		x.probeUDP, _ = packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if x.probeIP == nil {
+			continue
+		}
+		if len(probe.Response) > 0 {
+			packet := gopacket.NewPacket(probe.Response, layers.LayerTypeIPv4, gopacket.Default)
+			x.ip, _ = packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+			x.tcp, _ = packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+			x.icmp, _ = packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+		}
+		exchanges[probe.Type] = x
+	}
+	return exchanges
+}
+
+// computeOSTests 根据探测结果计算nmap第二代操作系统指纹的各项测试
+func computeOSTests(probes []ProbeResult) nmap.OSTests {
+	x := decodeOSExchanges(probes)
+	if len(x) == 0 {
+		return nil
+	}
+	tests := make(nmap.OSTests)
+	distance := hopDistance(x["U1"])
+
+	// SEQ、OPS、WIN和T1来自6个SEQ探测的应答
+	var seqs []*osExchange
+	ops, win := make(map[string]string), make(map[string]string)
+	for i := 1; i <= 6; i++ {
+		s := x[fmt.Sprintf("SEQ%d", i)]
+		if !s.replied() || s.tcp == nil || !s.tcp.SYN || !s.tcp.ACK {
+			continue
+		}
+		seqs = append(seqs, s)
+		ops[fmt.Sprintf("O%d", i)] = tcpOptionsAttr(s.tcp.Options)
+		win[fmt.Sprintf("W%d", i)] = fmt.Sprintf("%X", s.tcp.Window)
+	}
+	if seq := seqTest(seqs, x); len(seq) > 0 {
+		tests["SEQ"] = seq
+	}
+	if len(ops) > 0 {
+		tests["OPS"] = ops
+		tests["WIN"] = win
+	}
+	if s := x["SEQ1"]; s != nil {
+		tests["T1"] = tcpResponseTest(s, distance, "R", "DF", "T", "TG", "S", "A", "F", "RD", "Q")
+	}
+
+	if ecn := x["ECN"]; ecn != nil {
+		tests["ECN"] = tcpResponseTest(ecn, distance, "R", "DF", "T", "TG", "W", "O", "CC", "Q")
+	}
+	for i := 2; i <= 7; i++ {
+		name := fmt.Sprintf("T%d", i)
+		if t := x[name]; t != nil {
+			tests[name] = tcpResponseTest(t, distance, "R", "DF", "T", "TG", "W", "S", "A", "F", "O", "RD", "Q")
+		}
+	}
+	if u1 := x["U1"]; u1 != nil {
+		tests["U1"] = u1Test(u1, distance)
+	}
+	if ie1, ie2 := x["IE1"], x["IE2"]; ie1 != nil && ie2 != nil {
+		tests["IE"] = ieTest(ie1, ie2, distance)
+	}
+	return tests
+}
+
+// seqTest 计算SEQ测试：ISN的GCD、增长率ISR、可预测性SP，IP ID序列TI/CI/II、SS和时间戳TS
+func seqTest(seqs []*osExchange, x map[string]*osExchange) map[string]string {
+	attrs := make(map[string]string)
+
+	if len(seqs) >= 2 {
+		var diffs []uint32
+		var rates []float64
+		var gcd uint32
+		for i := 1; i < len(seqs); i++ {
+			diff := seqs[i].tcp.Seq - seqs[i-1].tcp.Seq
+			if other := seqs[i-1].tcp.Seq - seqs[i].tcp.Seq; other < diff {
+				diff = other
+			}
+			diffs = append(diffs, diff)
+			gcd = gcdUint32(gcd, diff)
+			rates = append(rates, float64(diff)/probeInterval(seqs[i-1], seqs[i]))
+		}
+		avg := 0.0
+		for _, rate := range rates {
+			avg += rate
+		}
+		avg /= float64(len(rates))
+
+		attrs["GCD"] = fmt.Sprintf("%X", gcd)
+		attrs["ISR"] = fmt.Sprintf("%X", log2Score(avg, 1))
+
+		// SP需要至少4个应答，先按GCD归一化增长率再计算标准差
+		if len(seqs) >= 4 {
+			div := 1.0
+			if gcd > 9 {
+				div = float64(gcd)
+			}
+			variance := 0.0
+			for _, rate := range rates {
+				d := rate/div - avg/div
+				variance += d * d
+			}
+			variance /= float64(len(rates) - 1)
+			attrs["SP"] = fmt.Sprintf("%X", log2Score(math.Sqrt(variance), 1))
+		}
+	}
+
+	var tcpIDs, closedIDs, icmpIDs []uint16
+	for _, s := range seqs {
+		tcpIDs = append(tcpIDs, s.ip.Id)
+	}
+	for _, name := range []string{"T5", "T6", "T7"} {
+		if t := x[name]; t.replied() && t.tcp != nil {
+			closedIDs = append(closedIDs, t.ip.Id)
+		}
+	}
+	for _, name := range []string{"IE1", "IE2"} {
+		if ie := x[name]; ie.replied() {
+			icmpIDs = append(icmpIDs, ie.ip.Id)
+		}
+	}
+	if len(tcpIDs) >= 3 {
+		attrs["TI"] = ipidSequence(tcpIDs, true)
+	}
+	if len(closedIDs) >= 2 {
+		attrs["CI"] = ipidSequence(closedIDs, true)
+	}
+	if len(icmpIDs) == 2 {
+		attrs["II"] = ipidSequence(icmpIDs, false)
+	}
+	// SS：TCP与ICMP的IP ID是否共享同一个递增计数器
+	if incrementalIPID(attrs["TI"]) && incrementalIPID(attrs["II"]) {
+		avg := float64(uint16(tcpIDs[len(tcpIDs)-1]-tcpIDs[0])) / float64(len(tcpIDs)-1)
+		if float64(uint16(icmpIDs[0]-tcpIDs[len(tcpIDs)-1])) < 3*avg {
+			attrs["SS"] = "S"
+		} else {
+			attrs["SS"] = "O"
+		}
+	}
+
+	if ts := timestampAttr(seqs); ts != "" {
+		attrs["TS"] = ts
+	}
+	for key, value := range attrs {
+		if value == "" {
+			delete(attrs, key)
+		}
+	}
+	return attrs
+}
+
+// probeInterval 返回两次探测的发送间隔(秒)，缺少时间戳时按nmap的发送间隔计算
+func probeInterval(a, b *osExchange) float64 {
+	if d := b.sent.Sub(a.sent).Seconds(); d > 0 {
+		return d
+	}
+	return osSeqInterval.Seconds()
+}
+
+// log2Score 返回round(8*log2(v))，v不超过min时为0
+func log2Score(v, min float64) int {
+	if v <= min {
+		return 0
+	}
+	return int(math.Round(8 * math.Log2(v)))
+}
+
+// gcdUint32 计算最大公约数
+func gcdUint32(a, b uint32) uint32 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// ipidSequence 按nmap的规则判断IP ID的生成方式
+// Z全为0，RD随机，RI随机递增，BI按256递增，I逐个递增，相同时为该值
+func ipidSequence(ids []uint16, allowRD bool) string {
+	allZero := true
+	for _, id := range ids {
+		if id != 0 {
+			allZero = false
+		}
+	}
+	if allZero {
+		return "Z"
+	}
+
+	diffs := make([]uint16, len(ids)-1)
+	same := true
+	for i := 1; i < len(ids); i++ {
+		diffs[i-1] = ids[i] - ids[i-1]
+		if diffs[i-1] != 0 {
+			same = false
+		}
+		if allowRD && diffs[i-1] >= 20000 {
+			return "RD"
+		}
+	}
+	if same {
+		return fmt.Sprintf("%X", ids[0])
+	}
+	for _, d := range diffs {
+		if d > 1000 && (d%256 != 0 || d >= 25600) {
+			return "RI"
+		}
+	}
+	broken := true
+	for _, d := range diffs {
+		if d%256 != 0 || d > 5120 {
+			broken = false
+		}
+	}
+	if broken {
+		return "BI"
+	}
+	for _, d := range diffs {
+		if d >= 10 {
+			return ""
+		}
+	}
+	return "I"
+}
+
+// incrementalIPID 判断IP ID序列是否为递增类型
+func incrementalIPID(class string) bool {
+	return class == "I" || class == "BI" || class == "RI"
+}
+
+// timestampAttr 计算TS：U表示不支持时间戳，0表示时间戳为0，否则为时间戳频率的对数
+func timestampAttr(seqs []*osExchange) string {
+	if len(seqs) < 2 {
+		return ""
+	}
+	var values []uint32
+	for _, s := range seqs {
+		value, ok := tcpTimestamp(s.tcp.Options)
+		if !ok {
+			return "U"
+		}
+		if value == 0 {
+			return "0"
+		}
+		values = append(values, value)
+	}
+
+	hz := 0.0
+	for i := 1; i < len(values); i++ {
+		hz += float64(values[i]-values[i-1]) / probeInterval(seqs[i-1], seqs[i])
+	}
+	hz /= float64(len(values) - 1)
+	switch {
+	case hz <= 5.66:
+		return "1"
+	case hz >= 70 && hz <= 150:
+		return "7"
+	case hz > 150 && hz <= 350:
+		return "8"
+	default:
+		return fmt.Sprintf("%X", int(math.Round(math.Log2(hz))))
+	}
+}
+
+// tcpTimestamp 返回TCP时间戳选项中的TSval
+func tcpTimestamp(options []layers.TCPOption) (uint32, bool) {
+	for _, opt := range options {
+		if opt.OptionType == layers.TCPOptionKindTimestamps && len(opt.OptionData) >= 8 {
+			return binary.BigEndian.Uint32(opt.OptionData), true
+		}
+	}
+	return 0, false
+}
+
+// tcpOptionsAttr 按nmap的格式编码TCP选项：L(EOL)、N(NOP)、M(MSS)、W(窗口扩大)、T(时间戳)、S(SACK)
+func tcpOptionsAttr(options []layers.TCPOption) string {
+	var b strings.Builder
+	for _, opt := range options {
+		switch opt.OptionType {
+		case layers.TCPOptionKindEndList:
+			b.WriteString("L")
+		case layers.TCPOptionKindNop:
+			b.WriteString("N")
+		case layers.TCPOptionKindMSS:
+			if len(opt.OptionData) >= 2 {
+				fmt.Fprintf(&b, "M%X", binary.BigEndian.Uint16(opt.OptionData))
+			}
+		case layers.TCPOptionKindWindowScale:
+			if len(opt.OptionData) >= 1 {
+				fmt.Fprintf(&b, "W%X", opt.OptionData[0])
+			}
+		case layers.TCPOptionKindTimestamps:
+			if len(opt.OptionData) >= 8 {
+				b.WriteString("T")
+				for _, part := range [][]byte{opt.OptionData[:4], opt.OptionData[4:8]} {
+					if binary.BigEndian.Uint32(part) != 0 {
+						b.WriteString("1")
+					} else {
+						b.WriteString("0")
+					}
+				}
+			}
+		case layers.TCPOptionKindSACKPermitted:
+			b.WriteString("S")
+		}
+	}
+	return b.String()
+}
+
+// hopDistance 根据U1应答中引用的IP头TTL计算到目标的跳数，无法计算时返回0
+func hopDistance(u1 *osExchange) int {
+	if !u1.replied() || u1.icmp == nil || u1.probeIP == nil || len(u1.icmp.Payload) < 20 {
+		return 0
+	}
+	distance := int(u1.probeIP.TTL) - int(u1.icmp.Payload[8]) + 1
+	if distance < 1 {
+		return 0
+	}
+	return distance
+}
+
+// ttlAttrs 计算T(由跳数推算的初始TTL)和TG(初始TTL的猜测值)
+func ttlAttrs(attrs map[string]string, ip *layers.IPv4, distance int) {
+	if distance > 0 {
+		attrs["T"] = fmt.Sprintf("%X", int(ip.TTL)+distance-1)
+	}
+	switch {
+	case ip.TTL <= 32:
+		attrs["TG"] = "20"
+	case ip.TTL <= 64:
+		attrs["TG"] = "40"
+	case ip.TTL <= 128:
+		attrs["TG"] = "80"
+	default:
+		attrs["TG"] = "FF"
+	}
+}
+
+// yesNo 将布尔值转换为Y/N
+func yesNo(v bool) string {
+	if v {
+		return "Y"
+	}
+	return "N"
+}
+
+// tcpResponseTest 计算ECN、T1-T7测试的指定属性，未应答时只有R=N
+func tcpResponseTest(x *osExchange, distance int, names ...string) map[string]string {
+	if !x.replied() || x.tcp == nil {
+		return map[string]string{"R": "N"}
+	}
+	all := map[string]string{
+		"R":  "Y",
+		"DF": yesNo(x.ip.Flags&layers.IPv4DontFragment != 0),
+		"W":  fmt.Sprintf("%X", x.tcp.Window),
+		"S":  seqAttr(x.tcp, x.probeTCP),
+		"A":  ackAttr(x.tcp, x.probeTCP),
+		"F":  tcpFlagsAttr(x.tcp),
+		"O":  tcpOptionsAttr(x.tcp.Options),
+		"RD": "0",
+		"Q":  tcpQuirks(x.tcp),
+		"CC": congestionAttr(x.tcp),
+	}
+	ttlAttrs(all, x.ip, distance)
+	if x.tcp.RST && len(x.tcp.Payload) > 0 {
+		all["RD"] = fmt.Sprintf("%X", crc32.ChecksumIEEE(x.tcp.Payload))
+	}
+
+	attrs := make(map[string]string)
+	for _, name := range names {
+		if value, ok := all[name]; ok {
+			attrs[name] = value
+		}
+	}
+	return attrs
+}
+
+// seqAttr 比较应答序列号与探测确认号：Z为0，A相等，A+为确认号加1，O其他
+func seqAttr(reply, probe *layers.TCP) string {
+	switch {
+	case reply.Seq == 0:
+		return "Z"
+	case probe != nil && reply.Seq == probe.Ack:
+		return "A"
+	case probe != nil && reply.Seq == probe.Ack+1:
+		return "A+"
+	default:
+		return "O"
+	}
+}
+
+// ackAttr 比较应答确认号与探测序列号：Z为0，S相等，S+为序列号加1，O其他
+func ackAttr(reply, probe *layers.TCP) string {
+	switch {
+	case reply.Ack == 0:
+		return "Z"
+	case probe != nil && reply.Ack == probe.Seq:
+		return "S"
+	case probe != nil && reply.Ack == probe.Seq+1:
+		return "S+"
+	default:
+		return "O"
+	}
+}
+
+// tcpFlagsAttr 按EUAPRSF的顺序列出应答中的TCP标志
+func tcpFlagsAttr(tcp *layers.TCP) string {
+	var b strings.Builder
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{{tcp.ECE, "E"}, {tcp.URG, "U"}, {tcp.ACK, "A"}, {tcp.PSH, "P"}, {tcp.RST, "R"}, {tcp.SYN, "S"}, {tcp.FIN, "F"}} {
+		if flag.set {
+			b.WriteString(flag.name)
+		}
+	}
+	return b.String()
+}
+
+// tcpQuirks 计算Q：R表示保留位非0，U表示未设置URG时紧急指针非0
+func tcpQuirks(tcp *layers.TCP) string {
+	quirks := ""
+	if tcp.NS {
+		quirks += "R"
+	}
+	if tcp.Urgent != 0 && !tcp.URG {
+		quirks += "U"
+	}
+	return quirks
+}
+
+// congestionAttr 计算ECN测试的CC：Y只有ECE，N都没有，S两者都有，O只有CWR
+func congestionAttr(tcp *layers.TCP) string {
+	switch {
+	case tcp.ECE && tcp.CWR:
+		return "S"
+	case tcp.ECE:
+		return "Y"
+	case tcp.CWR:
+		return "O"
+	default:
+		return "N"
+	}
+}
+
+// u1Test 计算U1测试：ICMP端口不可达应答及其引用的原始报文
+func u1Test(x *osExchange, distance int) map[string]string {
+	if !x.replied() || x.icmp == nil || x.icmp.TypeCode.Type() != layers.ICMPv4TypeDestinationUnreachable {
+		return map[string]string{"R": "N"}
+	}
+	attrs := map[string]string{
+		"R":   "Y",
+		"DF":  yesNo(x.ip.Flags&layers.IPv4DontFragment != 0),
+		"IPL": fmt.Sprintf("%X", x.ip.Length),
+		"UN":  fmt.Sprintf("%X", uint32(x.icmp.Id)<<16|uint32(x.icmp.Seq)),
+	}
+	ttlAttrs(attrs, x.ip, distance)
+
+	quoted := x.icmp.Payload
+	if len(quoted) < 20 || x.probeIP == nil {
+		return attrs
+	}
+	ihl := int(quoted[0]&0x0f) * 4
+	if ihl < 20 || len(quoted) < ihl {
+		return attrs
+	}
+
+	attrs["RIPL"] = matchedHex(binary.BigEndian.Uint16(quoted[2:4]), x.probeIP.Length)
+	attrs["RID"] = matchedHex(binary.BigEndian.Uint16(quoted[4:6]), x.probeIP.Id)
+	switch {
+	case binary.BigEndian.Uint16(quoted[10:12]) == 0:
+		attrs["RIPCK"] = "Z"
+	case ipChecksum(quoted[:ihl]) == 0:
+		attrs["RIPCK"] = "G"
+	default:
+		attrs["RIPCK"] = "I"
+	}
+	if len(quoted) >= ihl+8 && x.probeUDP != nil {
+		attrs["RUCK"] = matchedHex(binary.BigEndian.Uint16(quoted[ihl+6:ihl+8]), x.probeUDP.Checksum)
+		attrs["RUD"] = "G"
+		for _, c := range quoted[ihl+8:] {
+			if c != 'C' {
+				attrs["RUD"] = "I"
+				break
+			}
+		}
+	}
+	return attrs
+}
+
+// matchedHex 值与探测中的值相同时为G，否则为十六进制值
+func matchedHex(value, sent uint16) string {
+	if value == sent {
+		return "G"
+	}
+	return fmt.Sprintf("%X", value)
+}
+
+// ipChecksum 计算IP头的反码和，校验和正确时结果为0
+func ipChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(header[i])<<8 | uint32(header[i+1])
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// ieTest 计算IE测试：两个ICMP回显应答的DF位(DFI)和代码(CD)
+func ieTest(ie1, ie2 *osExchange, distance int) map[string]string {
+	if !ie1.replied() || !ie2.replied() || ie1.icmp == nil || ie2.icmp == nil {
+		return map[string]string{"R": "N"}
+	}
+	attrs := map[string]string{"R": "Y"}
+	ttlAttrs(attrs, ie1.ip, distance)
+
+	df1 := ie1.ip.Flags&layers.IPv4DontFragment != 0
+	df2 := ie2.ip.Flags&layers.IPv4DontFragment != 0
+	switch {
+	case !df1 && !df2:
+		attrs["DFI"] = "N"
+	case df1 && !df2:
+		// 与探测的DF位相同
+		attrs["DFI"] = "S"
+	case df1 && df2:
+		attrs["DFI"] = "Y"
+	default:
+		attrs["DFI"] = "O"
+	}
+
+	code1, code2 := ie1.icmp.TypeCode.Code(), ie2.icmp.TypeCode.Code()
+	switch {
+	case code1 == 0 && code2 == 0:
+		attrs["CD"] = "Z"
+	case code1 == 9 && code2 == 0:
+		// 与探测的代码相同
+		attrs["CD"] = "S"
+	case code1 == code2:
+		attrs["CD"] = fmt.Sprintf("%X", code1)
+	default:
+		attrs["CD"] = "O"
+	}
+	return attrs
+}
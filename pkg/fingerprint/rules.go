@@ -0,0 +1,395 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"gopkg.in/yaml.v3"
+)
+
+// UserRuleProbePrefix 用户规则生成的探测名称前缀，匹配结果的Probe以此开头时来自用户规则
+const UserRuleProbePrefix = "user:"
+
+// ServiceRule 用户定义的服务识别规则，从规则目录中的YAML或JSON文件加载
+//
+//	rules:
+//	  - name: acme-rpc
+//	    service: acme-rpc
+//	    ports: 9000,9100-9110
+//	    payload: "HELLO\r\n"
+//	    match:
+//	      - regex: '^ACME RPC ([\d.]+)'
+//	    product: Acme RPC
+//	    version: $1
+//	    cpe: [cpe:/a:acme:rpc:$1]
+type ServiceRule struct {
+	Name       string        `yaml:"name" json:"name"`                                   // 规则名称，在所有规则文件中唯一
+	Service    string        `yaml:"service" json:"service"`                             // 服务名称
+	Protocol   string        `yaml:"protocol,omitempty" json:"protocol,omitempty"`       // tcp或udp，默认为tcp
+	Ports      PortList      `yaml:"ports,omitempty" json:"ports,omitempty"`             // 发送探测的端口，为空时按稀有度决定
+	TLS        bool          `yaml:"tls,omitempty" json:"tls,omitempty"`                 // 是否先建立TLS连接
+	Payload    string        `yaml:"payload,omitempty" json:"payload,omitempty"`         // 探测数据，支持\r、\n、\xHH等转义
+	PayloadHex string        `yaml:"payload_hex,omitempty" json:"payload_hex,omitempty"` // 十六进制的探测数据
+	Rarity     int           `yaml:"rarity,omitempty" json:"rarity,omitempty"`           // 稀有度(1-9)，默认为5
+	Soft       bool          `yaml:"soft,omitempty" json:"soft,omitempty"`               // 是否为软匹配，只确定服务类型
+	Matchers   []RuleMatcher `yaml:"match" json:"match"`                                 // 匹配条件，任一命中即识别成功
+	Product    string        `yaml:"product,omitempty" json:"product,omitempty"`         // 产品名称，可引用$1等正则分组
+	Version    string        `yaml:"version,omitempty" json:"version,omitempty"`         // 版本号
+	Info       string        `yaml:"info,omitempty" json:"info,omitempty"`               // 附加信息
+	Hostname   string        `yaml:"hostname,omitempty" json:"hostname,omitempty"`       // 主机名
+	OS         string        `yaml:"os,omitempty" json:"os,omitempty"`                   // 操作系统
+	DeviceType string        `yaml:"device_type,omitempty" json:"device_type,omitempty"` // 设备类型
+	CPE        []string      `yaml:"cpe,omitempty" json:"cpe,omitempty"`                 // CPE标识
+	File       string        `yaml:"-" json:"file"`                                      // 所在文件
+	Line       int           `yaml:"-" json:"line"`                                      // 所在行号
+}
+
+// RuleMatcher 规则的一个匹配条件，regex和bytes二选一
+type RuleMatcher struct {
+	Regex  string `yaml:"regex,omitempty" json:"regex,omitempty"`   // Perl正则，语法与nmap的match指令相同
+	Flags  string `yaml:"flags,omitempty" json:"flags,omitempty"`   // 正则标志(i、s)
+	Bytes  string `yaml:"bytes,omitempty" json:"bytes,omitempty"`   // 十六进制字节，可含空格，??匹配任意字节
+	Offset *int   `yaml:"offset,omitempty" json:"offset,omitempty"` // 字节出现的位置，未设置时可出现在任意位置
+}
+
+// PortList nmap格式的端口列表，规则文件中可写为 "80,8000-8010" 或 [80, "8000-8010"]
+type PortList string
+
+// UnmarshalYAML 实现yaml.Unmarshaler接口，接受字符串或列表
+func (p *PortList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*p = PortList(node.Value)
+	case yaml.SequenceNode:
+		parts := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("第%d行: 端口必须是数字或范围", item.Line)
+			}
+			parts = append(parts, item.Value)
+		}
+		*p = PortList(strings.Join(parts, ","))
+	default:
+		return fmt.Errorf("第%d行: ports必须是字符串或列表", node.Line)
+	}
+	return nil
+}
+
+// RuleSet 从规则目录加载的用户规则
+type RuleSet struct {
+	Dir     string             `json:"dir"`     // 规则目录
+	Version string             `json:"version"` // 规则文件内容的摘要，没有规则文件时为空
+	Rules   []*ServiceRule     `json:"rules"`   // 成功加载的规则
+	Errors  []*nmap.ParseError `json:"-"`       // 无法加载的规则文件或规则
+	probes  []*nmap.Probe      // 规则生成的探测
+}
+
+// ruleExtensions 规则文件的扩展名
+var ruleExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// LoadRules 加载dir目录中的所有.yaml、.yml和.json规则文件
+// 目录不存在时返回空的规则集；单个文件或规则的错误记录在Errors中，其余规则照常加载
+func LoadRules(dir string) (*RuleSet, error) {
+	set := &RuleSet{Dir: dir}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取规则目录失败: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && ruleExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	seen := make(map[string]bool)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("读取规则文件%s失败: %v", name, err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+
+		rules, errs := parseRuleFile(name, data)
+		set.Errors = append(set.Errors, errs...)
+		for _, rule := range rules {
+			probe, err := rule.compile()
+			if err == nil && seen[rule.Name] {
+				err = fmt.Errorf("规则名称重复: %s", rule.Name)
+			}
+			if err != nil {
+				set.Errors = append(set.Errors, &nmap.ParseError{File: name, Line: rule.Line, Text: rule.Name, Err: err})
+				continue
+			}
+			seen[rule.Name] = true
+			set.Rules = append(set.Rules, rule)
+			set.probes = append(set.probes, probe)
+		}
+	}
+	if len(names) > 0 {
+		set.Version = hex.EncodeToString(h.Sum(nil))[:8]
+	}
+	return set, nil
+}
+
+// parseRuleFile 解析规则文件，文件顶层为rules列表，也可以直接是规则列表
+func parseRuleFile(name string, data []byte) ([]*ServiceRule, []*nmap.ParseError) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, []*nmap.ParseError{{File: name, Err: fmt.Errorf("解析规则文件失败: %v", err)}}
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		list = nil
+		for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+			if doc.Content[0].Content[i].Value == "rules" {
+				list = doc.Content[0].Content[i+1]
+			}
+		}
+	}
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return nil, []*nmap.ParseError{{File: name, Line: doc.Content[0].Line, Err: fmt.Errorf("规则文件必须包含rules列表")}}
+	}
+
+	var rules []*ServiceRule
+	var errs []*nmap.ParseError
+	for _, item := range list.Content {
+		rule := &ServiceRule{}
+		if err := item.Decode(rule); err != nil {
+			errs = append(errs, &nmap.ParseError{File: name, Line: item.Line, Err: err})
+			continue
+		}
+		rule.File, rule.Line = name, item.Line
+		rules = append(rules, rule)
+	}
+	return rules, errs
+}
+
+// compile 将规则转换为nmap探测，探测下每个匹配条件对应一条match规则
+func (r *ServiceRule) compile() (*nmap.Probe, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("规则缺少name")
+	}
+	if r.Service == "" {
+		return nil, fmt.Errorf("规则缺少service")
+	}
+	if len(r.Matchers) == 0 {
+		return nil, fmt.Errorf("规则至少需要一个match条件")
+	}
+
+	protocol := strings.ToUpper(r.Protocol)
+	if protocol == "" {
+		protocol = "TCP"
+	}
+	if protocol != "TCP" && protocol != "UDP" {
+		return nil, fmt.Errorf("不支持的协议: %s", r.Protocol)
+	}
+	if r.TLS && protocol != "TCP" {
+		return nil, fmt.Errorf("只有TCP规则可以使用tls")
+	}
+	if r.Ports != "" {
+		if err := nmap.ValidatePortList(string(r.Ports)); err != nil {
+			return nil, err
+		}
+	}
+	rarity := r.Rarity
+	if rarity == 0 {
+		// 与nmap探测的默认稀有度一致
+		rarity = 5
+	}
+	if rarity < 1 || rarity > 9 {
+		return nil, fmt.Errorf("rarity必须是1-9之间的整数: %d", r.Rarity)
+	}
+
+	var payload []byte
+	var err error
+	switch {
+	case r.Payload != "" && r.PayloadHex != "":
+		return nil, fmt.Errorf("payload和payload_hex不能同时设置")
+	case r.Payload != "":
+		payload, err = nmap.UnescapeString(r.Payload)
+	case r.PayloadHex != "":
+		payload, err = hex.DecodeString(strings.Join(strings.Fields(r.PayloadHex), ""))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无效的探测数据: %v", err)
+	}
+
+	probe := &nmap.Probe{
+		Name:     UserRuleProbePrefix + r.Name,
+		Protocol: protocol,
+		Payload:  payload,
+		Rarity:   rarity,
+		TLSOnly:  r.TLS,
+	}
+	if r.TLS {
+		probe.SSLPorts = string(r.Ports)
+	} else {
+		probe.Ports = string(r.Ports)
+	}
+
+	template := nmap.VersionInfo{
+		Product:    r.Product,
+		Version:    r.Version,
+		Info:       r.Info,
+		Hostname:   r.Hostname,
+		OS:         r.OS,
+		DeviceType: r.DeviceType,
+		CPE:        r.CPE,
+	}
+	for i, matcher := range r.Matchers {
+		pattern, flags, err := matcher.pattern()
+		if err != nil {
+			return nil, fmt.Errorf("第%d个match条件: %v", i+1, err)
+		}
+		rule, err := nmap.NewMatchRule(probe.Name, r.Service, pattern, flags, r.Soft, template)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个match条件: %v", i+1, err)
+		}
+		rule.Line = r.Line
+		rule.Text = fmt.Sprintf("match %s m|%s|%s", r.Service, pattern, flags)
+		probe.Matches = append(probe.Matches, rule)
+	}
+	return probe, nil
+}
+
+// pattern 返回匹配条件对应的正则和标志，字节模式转换为\xHH序列
+func (m RuleMatcher) pattern() (string, string, error) {
+	switch {
+	case m.Regex != "" && m.Bytes != "":
+		return "", "", fmt.Errorf("regex和bytes不能同时设置")
+	case m.Regex != "":
+		if m.Offset != nil {
+			return "", "", fmt.Errorf("offset只能用于bytes")
+		}
+		return m.Regex, m.Flags, nil
+	case m.Bytes == "":
+		return "", "", fmt.Errorf("需要regex或bytes")
+	}
+
+	digits := strings.Join(strings.Fields(m.Bytes), "")
+	if len(digits)%2 != 0 {
+		return "", "", fmt.Errorf("bytes的十六进制位数必须为偶数")
+	}
+	var pattern strings.Builder
+	if m.Offset != nil {
+		if *m.Offset < 0 {
+			return "", "", fmt.Errorf("offset不能为负数")
+		}
+		pattern.WriteString("^")
+		if *m.Offset > 0 {
+			fmt.Fprintf(&pattern, ".{%d}", *m.Offset)
+		}
+	}
+	for i := 0; i < len(digits); i += 2 {
+		pair := digits[i : i+2]
+		if pair == "??" {
+			pattern.WriteString(".")
+			continue
+		}
+		if _, err := hex.DecodeString(pair); err != nil {
+			return "", "", fmt.Errorf("无效的字节: %s", pair)
+		}
+		pattern.WriteString(`\x` + pair)
+	}
+	return pattern.String(), "s", nil
+}
+
+var (
+	rulesMu     sync.Mutex
+	rulesDir    string                          // 配置的规则目录，为空时使用数据目录下的rules
+	activeRules *RuleSet                        // 当前生效的规则，nil表示尚未加载
+	mergedDBs   = make(map[string]*nmap.NmapDB) // 指纹库版本 -> 加入用户规则后的指纹库
+)
+
+// SetRulesDir 设置用户规则目录，为空时使用RulesDir的默认目录，下次使用时重新加载
+func SetRulesDir(dir string) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rulesDir = dir
+	activeRules = nil
+	mergedDBs = make(map[string]*nmap.NmapDB)
+}
+
+// RulesDir 返回用户规则目录：SetRulesDir设置的目录，否则为当前数据目录下的rules，使用内置数据时为UserDataDir下的rules
+func RulesDir() string {
+	rulesMu.Lock()
+	dir := rulesDir
+	rulesMu.Unlock()
+	if dir != "" {
+		return dir
+	}
+	if data := ActiveDataDir(); data != "" {
+		return filepath.Join(data, "rules")
+	}
+	return filepath.Join(UserDataDir(), "rules")
+}
+
+// Rules 返回当前生效的用户规则，首次调用时从RulesDir加载
+func Rules() (*RuleSet, error) {
+	rulesMu.Lock()
+	set := activeRules
+	rulesMu.Unlock()
+	if set != nil {
+		return set, nil
+	}
+	set, _, err := ReloadRules()
+	return set, err
+}
+
+// ReloadRules 重新加载规则目录，规则文件有变化时替换当前规则并返回changed为true
+// 加载失败时保留原有规则；之后创建的识别器使用新的规则，已创建的识别器不受影响
+func ReloadRules() (set *RuleSet, changed bool, err error) {
+	set, err = LoadRules(RulesDir())
+	if err != nil {
+		return nil, false, err
+	}
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if activeRules != nil && activeRules.Dir == set.Dir && activeRules.Version == set.Version {
+		return activeRules, false, nil
+	}
+	activeRules = set
+	mergedDBs = make(map[string]*nmap.NmapDB)
+	return set, true, nil
+}
+
+// withUserRules 返回加入当前用户规则后的指纹库，没有规则时返回db本身
+func withUserRules(db *nmap.NmapDB) *nmap.NmapDB {
+	set, err := Rules()
+	if err != nil || len(set.probes) == 0 {
+		return db
+	}
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if set != activeRules {
+		// 规则在加载期间被替换，本次仍使用加载时的规则
+		return db.WithServiceProbes(set.probes, db.Version+"+rules-"+set.Version)
+	}
+	merged, ok := mergedDBs[db.Version]
+	if !ok {
+		merged = db.WithServiceProbes(set.probes, db.Version+"+rules-"+set.Version)
+		mergedDBs[db.Version] = merged
+	}
+	return merged
+}
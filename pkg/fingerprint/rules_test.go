@@ -0,0 +1,212 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRulesYAML = `rules:
+  - name: acme-rpc
+    service: acme-rpc
+    ports: [9000, "9100-9110"]
+    payload: "HELLO\r\n"
+    match:
+      - regex: '^ACME RPC ([\d.]+)'
+    product: Acme RPC
+    version: $1
+    cpe: [cpe:/a:acme:rpc:$1]
+  - name: acme-bin
+    service: acme-bin
+    ports: 9200
+    payload_hex: "de ad 00 01"
+    match:
+      - bytes: "ca fe ?? 02"
+        offset: 2
+    product: Acme Binary
+  - name: broken
+    service: broken
+    match:
+      - regex: '['
+  - name: acme-rpc
+    service: duplicate
+    match:
+      - regex: '^DUP'
+`
+
+const testRulesJSON = `{"rules": [
+  {"name": "acme-tls", "service": "acme-admin", "ports": "9443", "tls": true, "payload": "STATUS\r\n",
+   "match": [{"regex": "^ADMIN OK"}], "product": "Acme Admin"}
+]}`
+
+// writeRules 在dir下写入测试规则文件
+func writeRules(t *testing.T, dir string) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "acme.yaml"), []byte(testRulesYAML), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "admin.json"), []byte(testRulesJSON), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.txt"), []byte("不是规则文件"), 0644))
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir)
+
+	set, err := LoadRules(dir)
+	require.NoError(t, err)
+	require.Len(t, set.Rules, 3)
+	assert.Len(t, set.Version, 8)
+	assert.Equal(t, PortList("9000,9100-9110"), set.Rules[0].Ports)
+	assert.Equal(t, "acme.yaml", set.Rules[0].File)
+	assert.Equal(t, 2, set.Rules[0].Line)
+	assert.Equal(t, "acme-tls", set.Rules[2].Name)
+
+	// 正则错误和重复名称逐条报告
+	require.Len(t, set.Errors, 2)
+	assert.Equal(t, 19, set.Errors[0].Line)
+	assert.Equal(t, "broken", set.Errors[0].Text)
+	assert.Contains(t, set.Errors[1].Error(), "规则名称重复")
+
+	rpc, bin, admin := set.probes[0], set.probes[1], set.probes[2]
+	assert.Equal(t, UserRuleProbePrefix+"acme-rpc", rpc.Name)
+	assert.Equal(t, []byte("HELLO\r\n"), rpc.Payload)
+	assert.True(t, rpc.HasPort(9105))
+	assert.Equal(t, []byte{0xde, 0xad, 0x00, 0x01}, bin.Payload)
+	assert.True(t, admin.TLSOnly)
+	assert.True(t, admin.HasSSLPort(9443))
+	assert.False(t, admin.HasPort(9443))
+
+	m := rpc.Match([]byte("ACME RPC 2.1\n"))
+	require.NotNil(t, m)
+	assert.Equal(t, "Acme RPC", m.Product)
+	assert.Equal(t, "2.1", m.Version)
+	assert.Equal(t, []string{"cpe:/a:acme:rpc:2.1"}, m.CPE)
+
+	// 字节模式从offset开始匹配，??匹配任意字节
+	assert.NotNil(t, bin.Match([]byte{0x00, 0x0a, 0xca, 0xfe, 0x7f, 0x02}))
+	assert.Nil(t, bin.Match([]byte{0xca, 0xfe, 0x7f, 0x02}))
+
+	// 目录不存在时没有规则
+	empty, err := LoadRules(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, empty.Rules)
+	assert.Empty(t, empty.Version)
+}
+
+func TestRuleCompileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		rule ServiceRule
+		want string
+	}{
+		{ServiceRule{Service: "x", Matchers: []RuleMatcher{{Regex: "x"}}}, "name"},
+		{ServiceRule{Name: "x", Matchers: []RuleMatcher{{Regex: "x"}}}, "service"},
+		{ServiceRule{Name: "x", Service: "x"}, "match"},
+		{ServiceRule{Name: "x", Service: "x", Protocol: "sctp", Matchers: []RuleMatcher{{Regex: "x"}}}, "协议"},
+		{ServiceRule{Name: "x", Service: "x", Protocol: "udp", TLS: true, Matchers: []RuleMatcher{{Regex: "x"}}}, "tls"},
+		{ServiceRule{Name: "x", Service: "x", Ports: "99999", Matchers: []RuleMatcher{{Regex: "x"}}}, ""},
+		{ServiceRule{Name: "x", Service: "x", Payload: "a", PayloadHex: "61", Matchers: []RuleMatcher{{Regex: "x"}}}, "payload"},
+		{ServiceRule{Name: "x", Service: "x", PayloadHex: "zz", Matchers: []RuleMatcher{{Regex: "x"}}}, "探测数据"},
+		{ServiceRule{Name: "x", Service: "x", Matchers: []RuleMatcher{{Regex: "x", Bytes: "00"}}}, "regex和bytes"},
+		{ServiceRule{Name: "x", Service: "x", Matchers: []RuleMatcher{{Bytes: "0"}}}, "偶数"},
+		{ServiceRule{Name: "x", Service: "x", Matchers: []RuleMatcher{{Bytes: "zz"}}}, "无效的字节"},
+		{ServiceRule{Name: "x", Service: "x", Rarity: 10, Matchers: []RuleMatcher{{Regex: "x"}}}, "rarity"},
+	} {
+		_, err := tc.rule.compile()
+		if assert.Error(t, err, "%+v", tc.rule) {
+			assert.Contains(t, err.Error(), tc.want)
+		}
+	}
+}
+
+func TestUserRulesFingerprint(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	writeRules(t, dir)
+	SetRulesDir(dir)
+	defer SetRulesDir("")
+
+	f, err := NewFingerprinter("")
+	require.NoError(t, err)
+	assert.Contains(t, f.db.Version, "+rules-")
+	assert.Contains(t, f.db.Probes, UserRuleProbePrefix+"acme-rpc")
+
+	service := &fakeService{respond: func(payload string) string {
+		if payload == "HELLO\r\n" {
+			return "ACME RPC 3.4\r\n"
+		}
+		return ""
+	}}
+	opts := DefaultFingerprintOptions()
+	opts.Timeout = 300 * time.Millisecond
+	opts.VersionIntensity = 0
+	opts.Dial = service.dial
+	f.SetOptions(opts)
+
+	fp, err := f.FingerprintService("192.0.2.1", 9100)
+	require.NoError(t, err)
+	assert.Equal(t, "acme-rpc", fp.Name)
+	assert.Equal(t, "Acme RPC", fp.Product)
+	assert.Equal(t, "3.4", fp.Version)
+	assert.Equal(t, UserRuleProbePrefix+"acme-rpc", fp.Features["probe"])
+	assert.Equal(t, f.db.Version, fp.DBVersion)
+	// 要求TLS的规则不在明文连接上发送
+	assert.NotContains(t, service.sent(), "STATUS\r\n")
+
+	// 共享的指纹库不包含用户规则
+	base, err := LoadDB("")
+	require.NoError(t, err)
+	assert.NotContains(t, base.Probes, UserRuleProbePrefix+"acme-rpc")
+	assert.Same(t, f.db, withUserRules(base))
+}
+
+func TestReloadRules(t *testing.T) {
+	dir := t.TempDir()
+	SetRulesDir(dir)
+	defer SetRulesDir("")
+
+	set, err := Rules()
+	require.NoError(t, err)
+	assert.Empty(t, set.Rules)
+	base, err := LoadDB("")
+	require.NoError(t, err)
+	assert.Same(t, base, withUserRules(base))
+
+	// 规则文件变化后重新加载，之后的识别器使用新规则
+	writeRules(t, dir)
+	set, changed, err := ReloadRules()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Len(t, set.Rules, 3)
+	_, changed, err = ReloadRules()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	merged := withUserRules(base)
+	m := merged.MatchResponse("tcp", 9000, []byte("ACME RPC 1.0\r\n"))
+	require.NotNil(t, m)
+	assert.Equal(t, UserRuleProbePrefix+"acme-rpc", m.Probe)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "acme.yaml")))
+	set, changed, err = ReloadRules()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Len(t, set.Rules, 1)
+	assert.Nil(t, withUserRules(base).MatchResponse("tcp", 9000, []byte("ACME RPC 1.0\r\n")))
+}
+
+func TestWithServiceProbes(t *testing.T) {
+	base, err := LoadDB("")
+	require.NoError(t, err)
+	probe := &nmap.Probe{Name: "user:test", Protocol: "TCP", Rarity: 1}
+	derived := base.WithServiceProbes([]*nmap.Probe{probe}, "v2")
+	assert.Equal(t, "v2", derived.Version)
+	assert.Same(t, probe, derived.ServiceProbes[0])
+	assert.Len(t, derived.ServiceProbes, len(base.ServiceProbes)+1)
+	assert.NotContains(t, base.Probes, "user:test")
+	assert.True(t, strings.HasPrefix(base.Version, "embedded-"))
+}
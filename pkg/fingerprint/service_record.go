@@ -0,0 +1,244 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+)
+
+const (
+	// maxRecordResponse 记录中每个探测响应保留的最大字节数
+	maxRecordResponse = 1024
+	// recordLineWidth 记录文本每行的最大宽度，续行以SF:开头
+	recordLineWidth = 80
+)
+
+// recordEscaper 记录中额外转义空格和百分号，使记录不含空白且字段分隔符只出现在字段之间
+var recordEscaper = strings.NewReplacer(" ", `\x20`, "%", `\x25`)
+
+// ServiceRecord 未识别服务的指纹记录，格式与nmap的SF-Port块相同
+// 记录保存每个探测收到的响应，可提交给规则维护者或用fingerprint learn生成规则草稿
+type ServiceRecord struct {
+	Port      int              // 端口
+	Protocol  string           // 协议(tcp/udp)
+	Tunnel    string           // 探测使用的隧道，如ssl
+	DBVersion string           // 生成记录时的指纹库版本
+	Intensity int              // 版本探测强度
+	Time      time.Time        // 生成时间
+	Responses []RecordResponse // 按发送顺序排列的非空响应
+}
+
+// RecordResponse 记录中单个探测的响应
+type RecordResponse struct {
+	Probe    string // 探测名称
+	Response []byte // 响应数据，超过maxRecordResponse的部分被截断
+}
+
+// newServiceRecord 根据探测结果生成记录，没有任何响应时返回nil
+// TLS阶段有响应时只记录TLS阶段的响应，明文阶段通常只收到TLS告警
+func newServiceRecord(port int, protocol string, probes []ProbeResult, dbVersion string, intensity int) *ServiceRecord {
+	record := &ServiceRecord{
+		Port:      port,
+		Protocol:  strings.ToLower(protocol),
+		DBVersion: dbVersion,
+		Intensity: intensity,
+		Time:      time.Now(),
+	}
+	var plain, tunneled []RecordResponse
+	for _, probe := range probes {
+		if len(probe.Response) == 0 {
+			continue
+		}
+		response := probe.Response
+		if len(response) > maxRecordResponse {
+			response = response[:maxRecordResponse]
+		}
+		if name, ok := strings.CutPrefix(probe.Type, "ssl/"); ok {
+			tunneled = append(tunneled, RecordResponse{Probe: name, Response: response})
+		} else {
+			plain = append(plain, RecordResponse{Probe: probe.Type, Response: response})
+		}
+	}
+	switch {
+	case len(tunneled) > 0:
+		record.Tunnel, record.Responses = "ssl", tunneled
+	case len(plain) > 0:
+		record.Responses = plain
+	default:
+		return nil
+	}
+	return record
+}
+
+// String 返回nmap SF-Port格式的记录文本，超过80列时换行，续行以SF:开头
+func (r *ServiceRecord) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SF-Port%d-%s:V=%s%%I=%d%%D=%d/%d%%Time=%X",
+		r.Port, strings.ToUpper(r.Protocol), recordEscaper.Replace(r.DBVersion), r.Intensity,
+		int(r.Time.Month()), r.Time.Day(), r.Time.Unix())
+	if r.Tunnel != "" {
+		fmt.Fprintf(&b, "%%T=%s", strings.ToUpper(r.Tunnel))
+	}
+	for _, resp := range r.Responses {
+		fmt.Fprintf(&b, "%%r(%s,%X,\"%s\")", resp.Probe, len(resp.Response),
+			recordEscaper.Replace(nmap.EscapeString(resp.Response)))
+	}
+	b.WriteString(";")
+
+	text := b.String()
+	var lines []string
+	for len(text) > recordLineWidth {
+		lines = append(lines, text[:recordLineWidth])
+		text = "SF:" + text[recordLineWidth:]
+	}
+	return strings.Join(append(lines, text), "\n")
+}
+
+// ParseServiceRecord 解析String生成的或nmap输出的SF-Port记录
+func ParseServiceRecord(text string) (*ServiceRecord, error) {
+	// 去掉续行前缀后拼接
+	var joined strings.Builder
+	for i, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimSpace(line)
+		if i > 0 {
+			line = strings.TrimPrefix(line, "SF:")
+		}
+		joined.WriteString(line)
+	}
+	body := strings.TrimSuffix(joined.String(), ";")
+
+	header, rest, ok := strings.Cut(body, ":")
+	if !ok || !strings.HasPrefix(header, "SF-Port") {
+		return nil, fmt.Errorf("不是SF-Port记录")
+	}
+	portStr, protocol, ok := strings.Cut(strings.TrimPrefix(header, "SF-Port"), "-")
+	port, err := strconv.Atoi(portStr)
+	if !ok || err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("无效的记录头: %s", header)
+	}
+	record := &ServiceRecord{Port: port, Protocol: strings.ToLower(protocol)}
+
+	for _, field := range strings.Split(rest, "%") {
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "r(") {
+			resp, err := parseRecordResponse(field)
+			if err != nil {
+				return nil, err
+			}
+			record.Responses = append(record.Responses, resp)
+			continue
+		}
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "V":
+			version, err := nmap.UnescapeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("无效的版本: %v", err)
+			}
+			record.DBVersion = string(version)
+		case "I":
+			record.Intensity, _ = strconv.Atoi(value)
+		case "T":
+			record.Tunnel = strings.ToLower(value)
+		case "Time":
+			if sec, err := strconv.ParseInt(value, 16, 64); err == nil {
+				record.Time = time.Unix(sec, 0)
+			}
+		}
+	}
+	if len(record.Responses) == 0 {
+		return nil, fmt.Errorf("记录中没有探测响应")
+	}
+	return record, nil
+}
+
+// parseRecordResponse 解析 r(<探测>,<十六进制长度>,"<转义的响应>")
+func parseRecordResponse(field string) (RecordResponse, error) {
+	inner := strings.TrimPrefix(field, "r(")
+	name, rest, ok := strings.Cut(inner, ",")
+	if !ok {
+		return RecordResponse{}, fmt.Errorf("无效的响应记录: %s", field)
+	}
+	_, quoted, ok := strings.Cut(rest, ",")
+	if !ok || len(quoted) < 3 || !strings.HasPrefix(quoted, `"`) || !strings.HasSuffix(quoted, `")`) {
+		return RecordResponse{}, fmt.Errorf("无效的响应记录: %s", field)
+	}
+	response, err := nmap.UnescapeString(quoted[1 : len(quoted)-2])
+	if err != nil {
+		return RecordResponse{}, fmt.Errorf("探测%s的响应: %v", name, err)
+	}
+	return RecordResponse{Probe: name, Response: response}, nil
+}
+
+// ExtractServiceRecords 从文本中找出所有SF-Port记录
+// data可以是直接保存的记录、包含记录的文本输出，或JSON格式的扫描结果
+func ExtractServiceRecords(data []byte) ([]*ServiceRecord, error) {
+	var texts []string
+	var doc interface{}
+	if json.Unmarshal(data, &doc) == nil {
+		collectRecordStrings(doc, &texts)
+	} else {
+		texts = splitRecordText(string(data))
+	}
+
+	var records []*ServiceRecord
+	for _, text := range texts {
+		record, err := ParseServiceRecord(text)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// collectRecordStrings 收集JSON中以SF-Port开头的字符串
+func collectRecordStrings(v interface{}, texts *[]string) {
+	switch value := v.(type) {
+	case string:
+		if strings.HasPrefix(value, "SF-Port") {
+			*texts = append(*texts, value)
+		}
+	case []interface{}:
+		for _, item := range value {
+			collectRecordStrings(item, texts)
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			collectRecordStrings(item, texts)
+		}
+	}
+}
+
+// splitRecordText 从文本中截取SF-Port记录，记录的各行可以带有相同的缩进
+func splitRecordText(text string) []string {
+	var texts []string
+	var current []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "SF-Port"):
+			if current != nil {
+				texts = append(texts, strings.Join(current, "\n"))
+			}
+			current = []string{line}
+		case current != nil && strings.HasPrefix(line, "SF:"):
+			current = append(current, line)
+		default:
+			if current != nil {
+				texts = append(texts, strings.Join(current, "\n"))
+				current = nil
+			}
+		}
+	}
+	if current != nil {
+		texts = append(texts, strings.Join(current, "\n"))
+	}
+	return texts
+}
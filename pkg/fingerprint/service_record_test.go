@@ -0,0 +1,209 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceRecordRoundTrip(t *testing.T) {
+	record := &ServiceRecord{
+		Port:      9999,
+		Protocol:  "tcp",
+		Tunnel:    "ssl",
+		DBVersion: "embedded-0123456789ab",
+		Intensity: 7,
+		Time:      time.Unix(1760000000, 0),
+		Responses: []RecordResponse{
+			{Probe: "NULL", Response: []byte("ZQX server 100% ready\r\n\x00\x01\xff\"quoted\" a\\b")},
+			{Probe: "GetRequest", Response: []byte(strings.Repeat("HTTP/1.0 400 Bad, request ", 8))},
+		},
+	}
+
+	text := record.String()
+	lines := strings.Split(text, "\n")
+	require.Greater(t, len(lines), 1)
+	assert.True(t, strings.HasPrefix(lines[0], "SF-Port9999-TCP:V=embedded-0123456789ab%I=7%D="))
+	assert.Contains(t, lines[0], "%T=SSL%r(NULL,")
+	for i, line := range lines {
+		assert.LessOrEqual(t, len(line), recordLineWidth+3)
+		assert.NotContains(t, line, " ")
+		if i > 0 {
+			assert.True(t, strings.HasPrefix(line, "SF:"), line)
+		}
+	}
+	assert.True(t, strings.HasSuffix(text, ";"))
+
+	parsed, err := ParseServiceRecord(text)
+	require.NoError(t, err)
+	assert.Equal(t, record, parsed)
+
+	_, err = ParseServiceRecord("SF-Port80-TCP:V=1%I=7;")
+	assert.Error(t, err)
+	_, err = ParseServiceRecord("PORT STATE SERVICE")
+	assert.Error(t, err)
+	_, err = ParseServiceRecord(`SF-Port80-TCP:V=1%r(NULL,2,"\x4");`)
+	assert.Error(t, err)
+}
+
+func TestNewServiceRecord(t *testing.T) {
+	probes := []ProbeResult{
+		{Type: "NULL"},
+		{Type: "GenericLines", Response: []byte(strings.Repeat("x", maxRecordResponse+10))},
+	}
+	record := newServiceRecord(2000, "TCP", probes, "v1", 5)
+	require.NotNil(t, record)
+	assert.Equal(t, "tcp", record.Protocol)
+	assert.Empty(t, record.Tunnel)
+	require.Len(t, record.Responses, 1)
+	assert.Equal(t, "GenericLines", record.Responses[0].Probe)
+	assert.Len(t, record.Responses[0].Response, maxRecordResponse)
+
+	// TLS阶段有响应时只记录TLS阶段的响应
+	probes = append(probes, ProbeResult{Type: "ssl/NULL", Response: []byte("hello")})
+	record = newServiceRecord(2000, "tcp", probes, "v1", 5)
+	require.NotNil(t, record)
+	assert.Equal(t, "ssl", record.Tunnel)
+	assert.Equal(t, []RecordResponse{{Probe: "NULL", Response: []byte("hello")}}, record.Responses)
+
+	assert.Nil(t, newServiceRecord(2000, "tcp", []ProbeResult{{Type: "NULL"}}, "v1", 5))
+}
+
+func TestExtractServiceRecords(t *testing.T) {
+	first := &ServiceRecord{Port: 2000, Protocol: "tcp", Time: time.Unix(1760000000, 0),
+		Responses: []RecordResponse{{Probe: "NULL", Response: []byte(strings.Repeat("banner ", 20))}}}
+	second := &ServiceRecord{Port: 53, Protocol: "udp", Time: time.Unix(1760000000, 0),
+		Responses: []RecordResponse{{Probe: "DNSStatusRequest", Response: []byte{0, 1, 2}}}}
+
+	// 文本输出中带缩进的记录
+	var text strings.Builder
+	text.WriteString("2000/tcp  开放  \n  ● 未识别服务指纹:\n")
+	for _, line := range strings.Split(first.String(), "\n") {
+		text.WriteString("    " + line + "\n")
+	}
+	text.WriteString("\n" + second.String() + "\n")
+	records, err := ExtractServiceRecords([]byte(text.String()))
+	require.NoError(t, err)
+	assert.Equal(t, []*ServiceRecord{first, second}, records)
+
+	// JSON扫描结果中的fingerprint字段
+	data, err := json.Marshal(map[string]interface{}{"hosts": []interface{}{map[string]interface{}{
+		"ports": []interface{}{map[string]interface{}{"port": 2000, "service": map[string]string{"fingerprint": first.String()}}},
+	}}})
+	require.NoError(t, err)
+	records, err = ExtractServiceRecords(data)
+	require.NoError(t, err)
+	assert.Equal(t, []*ServiceRecord{first}, records)
+
+	records, err = ExtractServiceRecords([]byte("nothing here"))
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestDraftRule(t *testing.T) {
+	db, err := LoadDB("")
+	require.NoError(t, err)
+
+	// 文本横幅生成锚定正则，版本号替换为分组
+	record := &ServiceRecord{Port: 7001, Protocol: "tcp", Responses: []RecordResponse{
+		{Probe: "NULL", Response: []byte("ZQX (server) 4.12.1 ready\r\nmore\r\n")},
+	}}
+	rule, err := DraftRule(record, db)
+	require.NoError(t, err)
+	assert.Equal(t, "learned-tcp-7001", rule.Name)
+	assert.Equal(t, "unknown", rule.Service)
+	assert.Equal(t, PortList("7001"), rule.Ports)
+	assert.Empty(t, rule.Payload)
+	require.Len(t, rule.Matchers, 1)
+	assert.Equal(t, `^ZQX \(server\) ([\d.]+) ready`, rule.Matchers[0].Regex)
+	assert.Equal(t, "$1", rule.Version)
+	require.NoError(t, VerifyRule(rule, record.Responses[0].Response))
+
+	probe, err := rule.compile()
+	require.NoError(t, err)
+	m := probe.Match(record.Responses[0].Response)
+	require.NotNil(t, m)
+	assert.Equal(t, "4.12.1", m.Version)
+
+	// 二进制响应生成字节匹配，探测数据取自指纹库
+	record = &ServiceRecord{Port: 7002, Protocol: "tcp", Tunnel: "ssl", Responses: []RecordResponse{
+		{Probe: "GetRequest", Response: []byte{0x00, 0x00, 0x12, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03}},
+	}}
+	rule, err = DraftRule(record, db)
+	require.NoError(t, err)
+	assert.True(t, rule.TLS)
+	assert.Equal(t, nmap.EscapeString(db.Probes["GetRequest"].Payload), rule.Payload)
+	require.Len(t, rule.Matchers, 1)
+	assert.Equal(t, "0000120400000000", rule.Matchers[0].Bytes)
+	require.NotNil(t, rule.Matchers[0].Offset)
+	require.NoError(t, VerifyRule(rule, record.Responses[0].Response))
+	assert.Error(t, VerifyRule(rule, []byte("HTTP/1.0 200 OK\r\n")))
+
+	record.Responses[0].Probe = "NoSuchProbe"
+	_, err = DraftRule(record, db)
+	assert.Error(t, err)
+
+	// 生成的YAML可以直接放入规则目录
+	record.Responses[0].Probe = "GetRequest"
+	rule, err = DraftRule(record, db)
+	require.NoError(t, err)
+	data, err := MarshalRules([]*ServiceRule{rule})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "learned.yaml"), data, 0644))
+	set, err := LoadRules(dir)
+	require.NoError(t, err)
+	require.Empty(t, set.Errors)
+	require.Len(t, set.Rules, 1)
+	assert.Equal(t, rule.Matchers, set.Rules[0].Matchers)
+	assert.Equal(t, rule.Payload, set.Rules[0].Payload)
+}
+
+func TestFingerprintServiceRecord(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	SetRulesDir(t.TempDir())
+	defer SetRulesDir("")
+
+	f, err := NewFingerprinter("")
+	require.NoError(t, err)
+	service := &fakeService{respond: func(payload string) string {
+		if payload == "" {
+			return "ZQX-PROTO 9 hello\r\n"
+		}
+		return ""
+	}}
+	opts := DefaultFingerprintOptions()
+	opts.Timeout = 300 * time.Millisecond
+	opts.VersionIntensity = 0
+	opts.Dial = service.dial
+	f.SetOptions(opts)
+
+	fp, err := f.FingerprintService("192.0.2.1", 45123)
+	require.NoError(t, err)
+	assert.Empty(t, fp.Name)
+	require.NotNil(t, fp.Record)
+	assert.Equal(t, 45123, fp.Record.Port)
+	assert.Equal(t, "tcp", fp.Record.Protocol)
+	assert.Equal(t, f.db.Version, fp.Record.DBVersion)
+	assert.Equal(t, 0, fp.Record.Intensity)
+	require.NotEmpty(t, fp.Record.Responses)
+	assert.Equal(t, RecordResponse{Probe: "NULL", Response: []byte("ZQX-PROTO 9 hello\r\n")}, fp.Record.Responses[0])
+
+	// 识别成功的服务不生成记录
+	service = &fakeService{respond: func(payload string) string {
+		return "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n"
+	}}
+	opts.Dial = service.dial
+	f.SetOptions(opts)
+	fp, err = f.FingerprintService("192.0.2.1", 22)
+	require.NoError(t, err)
+	assert.Equal(t, "ssh", fp.Name)
+	assert.Nil(t, fp.Record)
+}
@@ -0,0 +1,369 @@
+package fingerprint
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// TLS协议版本
+const (
+	versionSSL30 uint16 = 0x0300
+	versionTLS10 uint16 = 0x0301
+	versionTLS11 uint16 = 0x0302
+	versionTLS12 uint16 = 0x0303
+	versionTLS13 uint16 = 0x0304
+)
+
+// TLS记录和握手类型
+const (
+	recordTypeAlert      = 21
+	recordTypeHandshake  = 22
+	handshakeClientHello = 1
+	handshakeServerHello = 2
+)
+
+// TLS扩展类型
+const (
+	extServerName           uint16 = 0x0000
+	extMaxFragmentLength    uint16 = 0x0001
+	extSupportedGroups      uint16 = 0x000a
+	extECPointFormats       uint16 = 0x000b
+	extSignatureAlgorithms  uint16 = 0x000d
+	extALPN                 uint16 = 0x0010
+	extExtendedMasterSecret uint16 = 0x0017
+	extSessionTicket        uint16 = 0x0023
+	extSupportedVersions    uint16 = 0x002b
+	extPSKKeyExchangeModes  uint16 = 0x002d
+	extKeyShare             uint16 = 0x0033
+	extRenegotiationInfo    uint16 = 0xff01
+)
+
+// errNoServerHello 对端没有用ServerHello应答
+var errNoServerHello = errors.New("没有收到ServerHello")
+
+// tlsVersionNames 协议版本的显示名称
+var tlsVersionNames = map[uint16]string{
+	versionSSL30: "SSLv3",
+	versionTLS10: "TLSv1.0",
+	versionTLS11: "TLSv1.1",
+	versionTLS12: "TLSv1.2",
+	versionTLS13: "TLSv1.3",
+}
+
+// tlsCipherSuiteNames IANA密码套件名称，包含JARM使用的全部套件
+var tlsCipherSuiteNames = map[uint16]string{
+	0x0004: "TLS_RSA_WITH_RC4_128_MD5",
+	0x0005: "TLS_RSA_WITH_RC4_128_SHA",
+	0x0007: "TLS_RSA_WITH_IDEA_CBC_SHA",
+	0x000a: "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	0x0016: "TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	0x002f: "TLS_RSA_WITH_AES_128_CBC_SHA",
+	0x0033: "TLS_DHE_RSA_WITH_AES_128_CBC_SHA",
+	0x0035: "TLS_RSA_WITH_AES_256_CBC_SHA",
+	0x0039: "TLS_DHE_RSA_WITH_AES_256_CBC_SHA",
+	0x003c: "TLS_RSA_WITH_AES_128_CBC_SHA256",
+	0x003d: "TLS_RSA_WITH_AES_256_CBC_SHA256",
+	0x0041: "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA",
+	0x0045: "TLS_DHE_RSA_WITH_CAMELLIA_128_CBC_SHA",
+	0x0067: "TLS_DHE_RSA_WITH_AES_128_CBC_SHA256",
+	0x006b: "TLS_DHE_RSA_WITH_AES_256_CBC_SHA256",
+	0x0084: "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA",
+	0x0088: "TLS_DHE_RSA_WITH_CAMELLIA_256_CBC_SHA",
+	0x009a: "TLS_DHE_RSA_WITH_SEED_CBC_SHA",
+	0x009c: "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	0x009d: "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	0x009e: "TLS_DHE_RSA_WITH_AES_128_GCM_SHA256",
+	0x009f: "TLS_DHE_RSA_WITH_AES_256_GCM_SHA384",
+	0x00ba: "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA256",
+	0x00be: "TLS_DHE_RSA_WITH_CAMELLIA_128_CBC_SHA256",
+	0x00c0: "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA256",
+	0x00c4: "TLS_DHE_RSA_WITH_CAMELLIA_256_CBC_SHA256",
+	0x1301: "TLS_AES_128_GCM_SHA256",
+	0x1302: "TLS_AES_256_GCM_SHA384",
+	0x1303: "TLS_CHACHA20_POLY1305_SHA256",
+	0x1304: "TLS_AES_128_CCM_SHA256",
+	0x1305: "TLS_AES_128_CCM_8_SHA256",
+	0xc007: "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA",
+	0xc008: "TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA",
+	0xc009: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	0xc00a: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	0xc011: "TLS_ECDHE_RSA_WITH_RC4_128_SHA",
+	0xc012: "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	0xc013: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	0xc014: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	0xc023: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+	0xc024: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384",
+	0xc027: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+	0xc028: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384",
+	0xc02b: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	0xc02c: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	0xc02f: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	0xc030: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	0xc060: "TLS_ECDHE_RSA_WITH_ARIA_128_GCM_SHA256",
+	0xc061: "TLS_ECDHE_RSA_WITH_ARIA_256_GCM_SHA384",
+	0xc072: "TLS_ECDHE_ECDSA_WITH_CAMELLIA_128_CBC_SHA256",
+	0xc073: "TLS_ECDHE_ECDSA_WITH_CAMELLIA_256_CBC_SHA384",
+	0xc076: "TLS_ECDHE_RSA_WITH_CAMELLIA_128_CBC_SHA256",
+	0xc077: "TLS_ECDHE_RSA_WITH_CAMELLIA_256_CBC_SHA384",
+	0xc09c: "TLS_RSA_WITH_AES_128_CCM",
+	0xc09d: "TLS_RSA_WITH_AES_256_CCM",
+	0xc09e: "TLS_DHE_RSA_WITH_AES_128_CCM",
+	0xc09f: "TLS_DHE_RSA_WITH_AES_256_CCM",
+	0xc0a0: "TLS_RSA_WITH_AES_128_CCM_8",
+	0xc0a1: "TLS_RSA_WITH_AES_256_CCM_8",
+	0xc0a2: "TLS_DHE_RSA_WITH_AES_128_CCM_8",
+	0xc0a3: "TLS_DHE_RSA_WITH_AES_256_CCM_8",
+	0xc0ac: "TLS_ECDHE_ECDSA_WITH_AES_128_CCM",
+	0xc0ad: "TLS_ECDHE_ECDSA_WITH_AES_256_CCM",
+	0xc0ae: "TLS_ECDHE_ECDSA_WITH_AES_128_CCM_8",
+	0xc0af: "TLS_ECDHE_ECDSA_WITH_AES_256_CCM_8",
+	0xcc13: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256_OLD",
+	0xcc14: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256_OLD",
+	0xcca8: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	0xcca9: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+// cipherSuiteName 返回密码套件的名称，未知套件返回十六进制编号
+func cipherSuiteName(id uint16) string {
+	if name, ok := tlsCipherSuiteNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", id)
+}
+
+// isTLS13Cipher 判断是否为TLS 1.3的密码套件
+func isTLS13Cipher(id uint16) bool {
+	return id>>8 == 0x13
+}
+
+// tlsExtension ClientHello或ServerHello中的一个扩展
+type tlsExtension struct {
+	Type uint16
+	Data []byte
+}
+
+// clientHello 构造ClientHello所需的参数
+type clientHello struct {
+	RecordVersion uint16         // 记录层版本
+	Version       uint16         // ClientHello中的client_version
+	Ciphers       []uint16       // 按顺序提供的密码套件
+	Extensions    []tlsExtension // 按顺序发送的扩展，为空时不发送扩展字段
+}
+
+// marshal 编码为完整的TLS记录
+func (h *clientHello) marshal() []byte {
+	body := make([]byte, 0, 512)
+	body = binary.BigEndian.AppendUint16(body, h.Version)
+	body = append(body, randomBytes(32)...)
+	body = append(body, 32)
+	body = append(body, randomBytes(32)...)
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(h.Ciphers)))
+	for _, c := range h.Ciphers {
+		body = binary.BigEndian.AppendUint16(body, c)
+	}
+	// 只支持null压缩
+	body = append(body, 1, 0)
+	if len(h.Extensions) > 0 {
+		var exts []byte
+		for _, ext := range h.Extensions {
+			exts = binary.BigEndian.AppendUint16(exts, ext.Type)
+			exts = binary.BigEndian.AppendUint16(exts, uint16(len(ext.Data)))
+			exts = append(exts, ext.Data...)
+		}
+		body = binary.BigEndian.AppendUint16(body, uint16(len(exts)))
+		body = append(body, exts...)
+	}
+
+	handshake := []byte{handshakeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+	record := []byte{recordTypeHandshake}
+	record = binary.BigEndian.AppendUint16(record, h.RecordVersion)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+// serverHello 解析后的ServerHello
+type serverHello struct {
+	Version    uint16         // ServerHello中的server_version
+	Selected   uint16         // 协商的版本，TLS 1.3取自supported_versions扩展
+	Cipher     uint16         // 选择的密码套件
+	Extensions []tlsExtension // 按顺序排列的扩展，没有扩展字段时为nil
+}
+
+// ALPN 返回服务器选择的应用层协议
+func (h *serverHello) ALPN() string {
+	for _, ext := range h.Extensions {
+		// ALPN扩展: 列表长度(2) 协议长度(1) 协议
+		if ext.Type == extALPN && len(ext.Data) > 3 {
+			return string(ext.Data[3:])
+		}
+	}
+	return ""
+}
+
+// alertError 服务器以TLS告警拒绝了ClientHello
+type alertError struct {
+	Level, Description byte
+}
+
+func (e *alertError) Error() string {
+	return fmt.Sprintf("TLS告警: %d", e.Description)
+}
+
+// sendClientHello 建立连接发送ClientHello并读取ServerHello
+func sendClientHello(dial func(network, address string, timeout time.Duration) (net.Conn, error),
+	address string, timeout time.Duration, hello *clientHello) (*serverHello, error) {
+	conn, err := dial("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(hello.marshal()); err != nil {
+		return nil, err
+	}
+	return readServerHello(conn)
+}
+
+// readServerHello 读取对端的第一个握手消息，要求是ServerHello
+func readServerHello(r io.Reader) (*serverHello, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errNoServerHello
+	}
+	length := int(binary.BigEndian.Uint16(header[3:5]))
+	if header[1] != 3 || length == 0 || length > 1<<14+2048 {
+		return nil, errNoServerHello
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errNoServerHello
+	}
+
+	switch header[0] {
+	case recordTypeAlert:
+		if len(payload) < 2 {
+			return nil, errNoServerHello
+		}
+		return nil, &alertError{Level: payload[0], Description: payload[1]}
+	case recordTypeHandshake:
+	default:
+		return nil, errNoServerHello
+	}
+	if len(payload) < 4 || payload[0] != handshakeServerHello {
+		return nil, errNoServerHello
+	}
+	msgLen := int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+	// ServerHello很短，总在第一个记录内
+	if msgLen > len(payload)-4 {
+		return nil, errNoServerHello
+	}
+	return parseServerHello(payload[4 : 4+msgLen])
+}
+
+// parseServerHello 解析ServerHello消息体
+func parseServerHello(body []byte) (*serverHello, error) {
+	// version(2) random(32) session_id长度(1)
+	if len(body) < 35 {
+		return nil, errNoServerHello
+	}
+	hello := &serverHello{Version: binary.BigEndian.Uint16(body)}
+	hello.Selected = hello.Version
+	offset := 35 + int(body[34])
+	// cipher(2) compression(1)
+	if len(body) < offset+3 {
+		return nil, errNoServerHello
+	}
+	hello.Cipher = binary.BigEndian.Uint16(body[offset:])
+	offset += 3
+	if len(body) < offset+2 {
+		return hello, nil
+	}
+	end := offset + 2 + int(binary.BigEndian.Uint16(body[offset:]))
+	if end > len(body) {
+		return nil, errNoServerHello
+	}
+	hello.Extensions = []tlsExtension{}
+	for offset += 2; offset+4 <= end; {
+		ext := tlsExtension{Type: binary.BigEndian.Uint16(body[offset:])}
+		size := int(binary.BigEndian.Uint16(body[offset+2:]))
+		offset += 4
+		if offset+size > end {
+			return nil, errNoServerHello
+		}
+		ext.Data = body[offset : offset+size]
+		offset += size
+		if ext.Type == extSupportedVersions && len(ext.Data) == 2 {
+			hello.Selected = binary.BigEndian.Uint16(ext.Data)
+		}
+		hello.Extensions = append(hello.Extensions, ext)
+	}
+	return hello, nil
+}
+
+// randomBytes 返回n个随机字节
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// 常用扩展的编码
+
+// sniExtension server_name扩展
+func sniExtension(host string) tlsExtension {
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(host)+3))
+	data = append(data, 0)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(host)))
+	return tlsExtension{Type: extServerName, Data: append(data, host...)}
+}
+
+// uint16ListExtension 以2字节长度开头的uint16列表扩展，如supported_groups
+func uint16ListExtension(typ uint16, values ...uint16) tlsExtension {
+	data := binary.BigEndian.AppendUint16(nil, uint16(2*len(values)))
+	for _, v := range values {
+		data = binary.BigEndian.AppendUint16(data, v)
+	}
+	return tlsExtension{Type: typ, Data: data}
+}
+
+// supportedVersionsExtension ClientHello中的supported_versions扩展
+func supportedVersionsExtension(versions ...uint16) tlsExtension {
+	data := []byte{byte(2 * len(versions))}
+	for _, v := range versions {
+		data = binary.BigEndian.AppendUint16(data, v)
+	}
+	return tlsExtension{Type: extSupportedVersions, Data: data}
+}
+
+// alpnExtension ALPN扩展
+func alpnExtension(protocols ...string) tlsExtension {
+	var list []byte
+	for _, p := range protocols {
+		list = append(list, byte(len(p)))
+		list = append(list, p...)
+	}
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(list)))
+	return tlsExtension{Type: extALPN, Data: append(data, list...)}
+}
+
+// keyShareExtension 包含一个随机x25519公钥的key_share扩展，grease不为0时先加入GREASE条目
+func keyShareExtension(grease uint16) tlsExtension {
+	var shares []byte
+	if grease != 0 {
+		shares = binary.BigEndian.AppendUint16(shares, grease)
+		shares = append(shares, 0, 1, 0)
+	}
+	shares = append(shares, 0x00, 0x1d, 0x00, 0x20)
+	shares = append(shares, randomBytes(32)...)
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(shares)))
+	return tlsExtension{Type: extKeyShare, Data: append(data, shares...)}
+}
+
+// signatureAlgorithms ClientHello中提供的签名算法
+var signatureAlgorithms = []uint16{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601, 0x0201}
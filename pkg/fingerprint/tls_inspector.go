@@ -0,0 +1,299 @@
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// ErrNotTLS 端口没有应答ClientHello
+var ErrNotTLS = errors.New("端口没有应答TLS握手")
+
+// TLSInfo TLS服务的检查结果
+type TLSInfo struct {
+	ServerName   string           `json:"server_name,omitempty" xml:"servername,attr,omitempty"` // 握手使用的SNI
+	Versions     []TLSVersion     `json:"versions" xml:"version"`                                // 支持的协议版本，从低到高排列
+	Certificates []TLSCertificate `json:"certificates,omitempty" xml:"certificate,omitempty"`    // 服务器发送的证书链，第一个为叶子证书
+	Trusted      bool             `json:"trusted" xml:"trusted,attr"`                            // 证书链能否由系统根证书验证
+	VerifyError  string           `json:"verify_error,omitempty" xml:"verifyerror,omitempty"`    // 证书链验证失败的原因
+	JARM         string           `json:"jarm" xml:"jarm,attr"`                                  // JARM指纹
+}
+
+// TLSVersion 一个协议版本及服务器接受的密码套件
+type TLSVersion struct {
+	Name    string   `json:"name" xml:"name,attr"` // 版本名称，如TLSv1.2
+	Ciphers []string `json:"ciphers" xml:"cipher"` // 按服务器偏好顺序排列的密码套件
+}
+
+// TLSCertificate 证书链中的一个证书
+type TLSCertificate struct {
+	Subject            string    `json:"subject" xml:"subject"`                          // 主题
+	Issuer             string    `json:"issuer" xml:"issuer"`                            // 颁发者
+	SANs               []string  `json:"sans,omitempty" xml:"san,omitempty"`             // 主题备用名称
+	SerialNumber       string    `json:"serial_number" xml:"serial,attr"`                // 序列号(十六进制)
+	NotBefore          time.Time `json:"not_before" xml:"notbefore,attr"`                // 生效时间
+	NotAfter           time.Time `json:"not_after" xml:"notafter,attr"`                  // 过期时间
+	Expired            bool      `json:"expired,omitempty" xml:"expired,attr,omitempty"` // 检查时是否已过期
+	KeyType            string    `json:"key_type" xml:"keytype,attr"`                    // 公钥类型(RSA、ECDSA、Ed25519、DSA)
+	KeyBits            int       `json:"key_bits,omitempty" xml:"bits,attr,omitempty"`   // 公钥长度
+	SignatureAlgorithm string    `json:"signature_algorithm" xml:"sigalg,attr"`          // 签名算法
+	SHA256             string    `json:"sha256" xml:"sha256,attr"`                       // 证书的SHA-256指纹
+	SelfSigned         bool      `json:"self_signed,omitempty" xml:"selfsigned,attr,omitempty"`
+}
+
+// VersionNames 返回支持的协议版本名称
+func (t *TLSInfo) VersionNames() []string {
+	names := make([]string, 0, len(t.Versions))
+	for _, v := range t.Versions {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// NeedsTLSInspection 判断服务识别结果是否需要TLS检查：识别为ssl、通过ssl隧道识别或没有硬匹配
+// 已硬匹配到明文协议的端口不是TLS服务，不再发送ClientHello
+func (fp *ServiceFingerprint) NeedsTLSInspection() bool {
+	return fp.Name == "ssl" || fp.Tunnel == "ssl" || fp.Record != nil
+}
+
+// TLSInspector 检查应答ClientHello的端口：证书链、支持的协议版本、各版本接受的密码套件和JARM指纹
+// 版本和密码套件的枚举与nmap的ssl-enum-ciphers相同：每次提供剩余的套件，记录服务器选择的套件后将其移除，
+// 得到的列表即服务器的偏好顺序
+type TLSInspector struct {
+	opts       *FingerprintOptions
+	serverName string
+	roots      *x509.CertPool
+}
+
+// NewTLSInspector 创建TLS检查器，使用opts中的超时和拨号函数
+func NewTLSInspector(opts *FingerprintOptions) *TLSInspector {
+	if opts == nil {
+		opts = DefaultFingerprintOptions()
+	}
+	return &TLSInspector{opts: opts}
+}
+
+// SetServerName 设置握手使用的SNI，为空时目标是域名则使用目标
+func (i *TLSInspector) SetServerName(name string) {
+	i.serverName = name
+}
+
+// SetRoots 设置验证证书链的根证书，为nil时使用系统根证书
+func (i *TLSInspector) SetRoots(roots *x509.CertPool) {
+	i.roots = roots
+}
+
+// inspectVersions 按检查顺序排列的协议版本，先检查TLS 1.3和1.2以尽快判断端口是否为TLS服务
+var inspectVersions = []uint16{versionTLS13, versionTLS12, versionTLS11, versionTLS10, versionSSL30}
+
+// Inspect 检查目标端口，端口没有以ServerHello或TLS告警应答时返回ErrNotTLS
+func (i *TLSInspector) Inspect(target string, port int) (*TLSInfo, error) {
+	sni := i.serverName
+	if sni == "" && net.ParseIP(target) == nil {
+		sni = target
+	}
+	info := &TLSInfo{ServerName: sni}
+
+	answered := false
+	for n, version := range inspectVersions {
+		ciphers, ok := i.enumerate(target, port, sni, version)
+		answered = answered || ok
+		if n == 1 && !answered {
+			return nil, ErrNotTLS
+		}
+		if len(ciphers) > 0 {
+			info.Versions = append(info.Versions, TLSVersion{Name: tlsVersionNames[version], Ciphers: ciphers})
+		}
+	}
+	if len(info.Versions) == 0 {
+		return nil, ErrNotTLS
+	}
+	// 从低到高排列
+	for l, r := 0, len(info.Versions)-1; l < r; l, r = l+1, r-1 {
+		info.Versions[l], info.Versions[r] = info.Versions[r], info.Versions[l]
+	}
+
+	if err := i.inspectCertificates(target, port, sni, info); err != nil {
+		info.VerifyError = err.Error()
+	}
+	info.JARM = JARM(i.opts.Dial, target, port, sni, i.opts.Timeout)
+	return info, nil
+}
+
+// enumerate 枚举服务器在version下接受的密码套件，answered表示服务器以ServerHello或告警应答
+func (i *TLSInspector) enumerate(target string, port int, sni string, version uint16) (ciphers []string, answered bool) {
+	address := net.JoinHostPort(target, fmt.Sprint(port))
+	candidates := enumCipherSuites(version)
+	for len(candidates) > 0 {
+		hello, err := sendClientHello(i.dial(), address, i.opts.Timeout, versionHello(version, candidates, sni))
+		if err != nil {
+			var alert *alertError
+			answered = answered || errors.As(err, &alert)
+			break
+		}
+		answered = true
+		index := -1
+		for n, c := range candidates {
+			if c == hello.Cipher {
+				index = n
+			}
+		}
+		// 服务器协商了其他版本，或选择了没有提供的套件
+		if hello.Selected != version || index < 0 {
+			break
+		}
+		ciphers = append(ciphers, cipherSuiteName(hello.Cipher))
+		candidates = append(candidates[:index:index], candidates[index+1:]...)
+	}
+	return ciphers, answered
+}
+
+// dial 返回探测使用的拨号函数
+func (i *TLSInspector) dial() func(network, address string, timeout time.Duration) (net.Conn, error) {
+	if i.opts.Dial != nil {
+		return i.opts.Dial
+	}
+	return net.DialTimeout
+}
+
+// enumCipherSuites 枚举version时提供的密码套件
+func enumCipherSuites(version uint16) []uint16 {
+	var suites []uint16
+	for id := range tlsCipherSuiteNames {
+		// cc13、cc14是ChaCha20的草案编号
+		if isTLS13Cipher(id) != (version == versionTLS13) || id == 0xcc13 || id == 0xcc14 {
+			continue
+		}
+		suites = append(suites, id)
+	}
+	sort.Slice(suites, func(a, b int) bool { return suites[a] < suites[b] })
+	return suites
+}
+
+// versionHello 构造只接受version的ClientHello
+func versionHello(version uint16, ciphers []uint16, sni string) *clientHello {
+	hello := &clientHello{RecordVersion: versionTLS10, Version: version, Ciphers: ciphers}
+	if version == versionSSL30 {
+		hello.RecordVersion = versionSSL30
+		return hello
+	}
+	if sni != "" {
+		hello.Extensions = append(hello.Extensions, sniExtension(sni))
+	}
+	hello.Extensions = append(hello.Extensions,
+		uint16ListExtension(extSupportedGroups, 0x001d, 0x0017, 0x0018, 0x0019),
+		tlsExtension{Type: extECPointFormats, Data: []byte{0x01, 0x00}},
+		uint16ListExtension(extSignatureAlgorithms, signatureAlgorithms...),
+	)
+	if version == versionTLS13 {
+		hello.Version = versionTLS12
+		hello.Extensions = append(hello.Extensions,
+			supportedVersionsExtension(versionTLS13),
+			keyShareExtension(0),
+			tlsExtension{Type: extPSKKeyExchangeModes, Data: []byte{0x01, 0x01}},
+		)
+	} else {
+		hello.Extensions = append(hello.Extensions,
+			tlsExtension{Type: extExtendedMasterSecret},
+			tlsExtension{Type: extRenegotiationInfo, Data: []byte{0x00}},
+		)
+	}
+	return hello
+}
+
+// inspectCertificates 完成一次握手读取证书链并验证，返回证书链验证失败的原因
+func (i *TLSInspector) inspectCertificates(target string, port int, sni string, info *TLSInfo) error {
+	conn, err := i.dial()("tcp", net.JoinHostPort(target, fmt.Sprint(port)), i.opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(i.opts.Timeout))
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         sni,
+		InsecureSkipVerify: true, // 证书在握手后单独验证，自签名证书也要读取
+		MinVersion:         tls.VersionTLS10,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS握手失败: %v", err)
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("服务器没有发送证书")
+	}
+
+	now := time.Now()
+	for _, cert := range certs {
+		info.Certificates = append(info.Certificates, certificateInfo(cert, now))
+	}
+
+	host := sni
+	if host == "" {
+		host = target
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         i.roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	}); err != nil {
+		return err
+	}
+	info.Trusted = true
+	return nil
+}
+
+// certificateInfo 提取证书的检查信息
+func certificateInfo(cert *x509.Certificate, now time.Time) TLSCertificate {
+	sum := sha256.Sum256(cert.Raw)
+	c := TLSCertificate{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.Text(16),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		Expired:            now.After(cert.NotAfter),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		SHA256:             hex.EncodeToString(sum[:]),
+		SelfSigned: bytes.Equal(cert.RawSubject, cert.RawIssuer) &&
+			cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil,
+	}
+	c.SANs = append(c.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		c.SANs = append(c.SANs, ip.String())
+	}
+	c.SANs = append(c.SANs, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		c.SANs = append(c.SANs, uri.String())
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		c.KeyType, c.KeyBits = "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		c.KeyType, c.KeyBits = "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		c.KeyType, c.KeyBits = "Ed25519", 256
+	case *dsa.PublicKey:
+		c.KeyType, c.KeyBits = "DSA", key.P.BitLen()
+	default:
+		c.KeyType = cert.PublicKeyAlgorithm.String()
+	}
+	return c
+}
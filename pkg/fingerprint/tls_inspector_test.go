@@ -0,0 +1,201 @@
+package fingerprint
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTLSServer 启动使用自签名证书的本地TLS服务，返回端口和证书
+func startTLSServer(t *testing.T, config *tls.Config) (int, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1234),
+		Subject:      pkix.Name{CommonName: "rocket.test", Organization: []string{"Rocket"}},
+		DNSNames:     []string{"rocket.test", "www.rocket.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	config.Certificates = []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(2 * time.Second))
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, cert
+}
+
+func TestJarmMung(t *testing.T) {
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, jarmMung(5, jarmForward))
+	assert.Equal(t, []int{4, 3, 2, 1, 0}, jarmMung(5, jarmReverse))
+	assert.Equal(t, []int{3, 4}, jarmMung(5, jarmBottomHalf))
+	assert.Equal(t, []int{2, 3}, jarmMung(4, jarmBottomHalf))
+	assert.Equal(t, []int{2, 1, 0}, jarmMung(5, jarmTopHalf))
+	assert.Equal(t, []int{1, 0}, jarmMung(4, jarmTopHalf))
+	assert.Equal(t, []int{2, 3, 1, 4, 0}, jarmMung(5, jarmMiddleOut))
+	assert.Equal(t, []int{2, 1, 3, 0}, jarmMung(4, jarmMiddleOut))
+	assert.Equal(t, []string{"h2", "h2c", "spdy/3"}, jarmMungStrings([]string{"spdy/3", "h2", "h2c"}, jarmMiddleOut))
+}
+
+func TestJarmHash(t *testing.T) {
+	results := make([]string, len(jarmProbes))
+	for i := range results {
+		results[i] = jarmResult(nil)
+	}
+	assert.Equal(t, jarmEmpty, jarmHash(results))
+
+	assert.Equal(t, "00", jarmCipherByte(""))
+	assert.Equal(t, "01", jarmCipherByte("0004"))
+	assert.Equal(t, "29", jarmCipherByte("c02f"))
+	assert.Equal(t, "45", jarmCipherByte("1305"))
+	assert.Equal(t, "d", jarmVersionByte("0303"))
+	assert.Equal(t, "0", jarmVersionByte(""))
+
+	results[0] = "c02f|0303|h2|ff01-0000-0010"
+	hash := jarmHash(results)
+	assert.Len(t, hash, 62)
+	assert.True(t, strings.HasPrefix(hash, "29d"+strings.Repeat("000", 9)), hash)
+}
+
+func TestParseServerHello(t *testing.T) {
+	// version random session_id(0) cipher compression extensions
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0x00, 0x13, 0x01, 0x00)
+	exts := []byte{0x00, 0x2b, 0x00, 0x02, 0x03, 0x04, 0x00, 0x10, 0x00, 0x05, 0x00, 0x03, 0x02, 'h', '2'}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(exts)))
+	body = append(body, exts...)
+
+	hello, err := parseServerHello(body)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(versionTLS12), hello.Version)
+	assert.Equal(t, uint16(versionTLS13), hello.Selected)
+	assert.Equal(t, uint16(0x1301), hello.Cipher)
+	assert.Equal(t, "h2", hello.ALPN())
+	assert.Equal(t, "1301|0303|h2|002b-0010", jarmResult(hello))
+
+	_, err = parseServerHello(body[:20])
+	assert.Error(t, err)
+}
+
+func TestTLSInspector(t *testing.T) {
+	port, cert := startTLSServer(t, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+	})
+
+	opts := DefaultFingerprintOptions()
+	opts.Timeout = 2 * time.Second
+	inspector := NewTLSInspector(opts)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	inspector.SetRoots(roots)
+
+	info, err := inspector.Inspect("127.0.0.1", port)
+	require.NoError(t, err)
+	assert.Empty(t, info.ServerName)
+	require.Len(t, info.Versions, 1)
+	assert.Equal(t, "TLSv1.2", info.Versions[0].Name)
+	assert.ElementsMatch(t, []string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	}, info.Versions[0].Ciphers)
+
+	require.Len(t, info.Certificates, 1)
+	leaf := info.Certificates[0]
+	assert.Equal(t, "CN=rocket.test,O=Rocket", leaf.Subject)
+	assert.Equal(t, leaf.Subject, leaf.Issuer)
+	assert.Equal(t, []string{"rocket.test", "www.rocket.test", "127.0.0.1"}, leaf.SANs)
+	assert.Equal(t, "1234", leaf.SerialNumber)
+	assert.Equal(t, "ECDSA", leaf.KeyType)
+	assert.Equal(t, 256, leaf.KeyBits)
+	assert.Equal(t, "ECDSA-SHA256", leaf.SignatureAlgorithm)
+	assert.Len(t, leaf.SHA256, 64)
+	assert.True(t, leaf.SelfSigned)
+	assert.False(t, leaf.Expired)
+	assert.True(t, info.Trusted, info.VerifyError)
+
+	assert.Len(t, info.JARM, 62)
+	assert.NotEqual(t, jarmEmpty, info.JARM)
+
+	// 不受信任的证书报告验证失败原因
+	inspector.SetRoots(x509.NewCertPool())
+	inspector.SetServerName("other.test")
+	info, err = inspector.Inspect("127.0.0.1", port)
+	require.NoError(t, err)
+	assert.Equal(t, "other.test", info.ServerName)
+	assert.False(t, info.Trusted)
+	assert.NotEmpty(t, info.VerifyError)
+}
+
+func TestTLSInspectorTLS13(t *testing.T) {
+	port, _ := startTLSServer(t, &tls.Config{MinVersion: tls.VersionTLS12})
+
+	opts := DefaultFingerprintOptions()
+	opts.Timeout = 2 * time.Second
+	info, err := NewTLSInspector(opts).Inspect("127.0.0.1", port)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TLSv1.2", "TLSv1.3"}, info.VersionNames())
+	assert.ElementsMatch(t, []string{
+		"TLS_AES_128_GCM_SHA256",
+		"TLS_AES_256_GCM_SHA384",
+		"TLS_CHACHA20_POLY1305_SHA256",
+	}, info.Versions[1].Ciphers)
+	assert.NotContains(t, info.Versions[0].Ciphers, "TLS_AES_128_GCM_SHA256")
+}
+
+func TestTLSInspectorNotTLS(t *testing.T) {
+	service := &fakeService{respond: func(payload string) string {
+		return "HTTP/1.0 400 Bad Request\r\n\r\n"
+	}}
+	opts := DefaultFingerprintOptions()
+	opts.Timeout = 300 * time.Millisecond
+	opts.Dial = service.dial
+	_, err := NewTLSInspector(opts).Inspect("192.0.2.1", 80)
+	assert.ErrorIs(t, err, ErrNotTLS)
+	// 判断为非TLS服务后不再继续枚举
+	assert.Len(t, service.sent(), 2)
+}
+
+func TestNeedsTLSInspection(t *testing.T) {
+	// 硬匹配到明文协议
+	assert.False(t, (&ServiceFingerprint{Name: "ssh"}).NeedsTLSInspection())
+	assert.True(t, (&ServiceFingerprint{Name: "ssl"}).NeedsTLSInspection())
+	assert.True(t, (&ServiceFingerprint{Name: "http", Tunnel: "ssl"}).NeedsTLSInspection())
+	// 软匹配和未识别都带有指纹记录
+	assert.True(t, (&ServiceFingerprint{Name: "http", Record: &ServiceRecord{}}).NeedsTLSInspection())
+	assert.True(t, (&ServiceFingerprint{Record: &ServiceRecord{}}).NeedsTLSInspection())
+}
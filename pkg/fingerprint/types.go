@@ -1,6 +1,7 @@
 package fingerprint
 
 import (
+	"net"
 	"time"
 )
 
@@ -9,17 +10,40 @@ type OSFingerprint struct {
 	Name        string            // 操作系统名称
 	Version     string            // 版本号
 	Confidence  float64           // 置信度 (0-100)
+	Family      string            // 操作系统家族
+	CPE         []string          // 最佳匹配的CPE标识
+	Guesses     []OSGuess         // 按匹配度降序排列的候选结果
+	DBVersion   string            // 匹配使用的指纹库版本
 	Features    map[string]string // 特征值
 	Probes      []ProbeResult     // 探测结果
 	LastUpdated time.Time         // 最后更新时间
 }
 
+// OSGuess 与nmap-os-db参考指纹比较得到的一个操作系统候选
+type OSGuess struct {
+	Name       string   `json:"name"`                  // 参考指纹名称
+	Accuracy   float64  `json:"accuracy"`              // 匹配度 (0-100)
+	Vendor     string   `json:"vendor,omitempty"`      // 厂商
+	Family     string   `json:"family,omitempty"`      // 操作系统家族
+	Generation string   `json:"generation,omitempty"`  // 版本代
+	DeviceType string   `json:"device_type,omitempty"` // 设备类型
+	CPE        []string `json:"cpe,omitempty"`         // CPE标识
+}
+
 // ServiceFingerprint 服务指纹
 type ServiceFingerprint struct {
 	Name        string            // 服务名称
 	Version     string            // 版本号
 	Product     string            // 产品名称
+	Info        string            // 附加信息
+	Hostname    string            // 服务报告的主机名
+	OS          string            // 服务报告的操作系统
+	DeviceType  string            // 设备类型
+	CPE         []string          // CPE标识
+	Tunnel      string            // 识别时使用的隧道，如ssl
 	Confidence  float64           // 置信度 (0-100)
+	DBVersion   string            // 匹配使用的指纹库版本
+	Record      *ServiceRecord    // 没有硬匹配时的未识别服务指纹记录
 	Features    map[string]string // 特征值
 	Probes      []ProbeResult     // 探测结果
 	LastUpdated time.Time         // 最后更新时间
@@ -41,11 +65,26 @@ type ProbeResult struct {
 type FingerprintOptions struct {
 	EnableOSDetection      bool          // 启用操作系统检测
 	EnableServiceDetection bool          // 启用服务检测
-	VersionIntensity       int           // 版本检测强度 (0-9)
+	VersionIntensity       int           // 版本检测强度 (0-9)，与nmap相同：只发送rarity不超过该值的探测，端口相关的探测总会发送
 	MaxProbes              int           // 最大探测次数
 	Timeout                time.Duration // 超时时间
 	GuessOS                bool          // 是否推测操作系统
 	LimitOSScan            bool          // 是否限制操作系统扫描
+	// Dial 建立探测连接，为nil时使用net.DialTimeout，测试可替换为模拟网络
+	Dial func(network, address string, timeout time.Duration) (net.Conn, error)
+	// Transport 收发原始报文，设置后操作系统检测发送nmap第二代探测，为nil时使用连接探测
+	Transport PacketTransport
+	// ClosedTCPPort、ClosedUDPPort 操作系统探测使用的关闭端口，为0时随机选择高端口
+	ClosedTCPPort int
+	ClosedUDPPort int
+}
+
+// dial 按选项建立探测连接
+func (o *FingerprintOptions) dial(network, address string) (net.Conn, error) {
+	if o.Dial != nil {
+		return o.Dial(network, address, o.Timeout)
+	}
+	return net.DialTimeout(network, address, o.Timeout)
 }
 
 // DefaultFingerprintOptions 默认指纹识别选项
@@ -78,28 +117,31 @@ type FingerprintDB interface {
 
 // Service 服务信息
 type Service struct {
-	Name       string            `json:"name"`        // 服务名称
-	Version    string            `json:"version"`     // 服务版本
-	Product    string            `json:"product"`     // 产品名称
-	Protocol   string            `json:"protocol"`    // 协议
-	DeviceType string            `json:"device_type"` // 设备类型
-	CPE        []string          `json:"cpe"`         // CPE标识
-	Banner     string            `json:"banner"`      // 服务横幅
-	Confidence float64           `json:"confidence"`  // 置信度
-	Metadata   map[string]string `json:"metadata"`    // 元数据
+	Name        string            `json:"name"`                  // 服务名称
+	Version     string            `json:"version"`               // 服务版本
+	Product     string            `json:"product"`               // 产品名称
+	Protocol    string            `json:"protocol"`              // 协议
+	DeviceType  string            `json:"device_type"`           // 设备类型
+	CPE         []string          `json:"cpe"`                   // CPE标识
+	Banner      string            `json:"banner"`                // 服务横幅
+	Confidence  float64           `json:"confidence"`            // 置信度
+	Metadata    map[string]string `json:"metadata"`              // 元数据
+	Fingerprint string            `json:"fingerprint,omitempty"` // 未识别服务的SF-Port指纹记录
+	TLS         *TLSInfo          `json:"tls,omitempty"`         // TLS检查结果
 }
 
 // OSInfo 操作系统信息
 type OSInfo struct {
-	Name         string            `json:"name"`         // 操作系统名称
-	Family       string            `json:"family"`       // 操作系统家族
-	Generation   string            `json:"generation"`   // 操作系统代
-	Version      string            `json:"version"`      // 操作系统版本
-	Kernel       string            `json:"kernel"`       // 内核版本
-	Architecture string            `json:"architecture"` // 系统架构
-	CPE          []string          `json:"cpe"`          // CPE标识
-	Confidence   float64           `json:"confidence"`   // 置信度
-	Metadata     map[string]string `json:"metadata"`     // 元数据
+	Name         string            `json:"name"`              // 操作系统名称
+	Family       string            `json:"family"`            // 操作系统家族
+	Generation   string            `json:"generation"`        // 操作系统代
+	Version      string            `json:"version"`           // 操作系统版本
+	Kernel       string            `json:"kernel"`            // 内核版本
+	Architecture string            `json:"architecture"`      // 系统架构
+	CPE          []string          `json:"cpe"`               // CPE标识
+	Confidence   float64           `json:"confidence"`        // 置信度
+	Guesses      []OSGuess         `json:"guesses,omitempty"` // 按匹配度降序排列的候选结果
+	Metadata     map[string]string `json:"metadata"`          // 元数据
 }
 
 // MatchResult 匹配结果
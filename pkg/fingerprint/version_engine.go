@@ -0,0 +1,263 @@
+package fingerprint
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+)
+
+const (
+	// maxProbeResponse 单个探测读取的最大响应长度
+	maxProbeResponse = 16 * 1024
+	// probeIdleTimeout 收到数据后等待后续数据的时间
+	probeIdleTimeout = 200 * time.Millisecond
+)
+
+// versionResult 一次版本探测的结果
+type versionResult struct {
+	probes []ProbeResult      // 每个探测的请求和响应
+	match  *nmap.ServiceMatch // 最佳匹配，可能为软匹配或nil
+	tunnel string             // 匹配时使用的隧道，如ssl
+}
+
+// versionEngine 按nmap的版本探测流程向端口发送探测并匹配响应
+//
+// 探测顺序：TCP先发送NULL探测读取banner，再发送ports或sslports包含该端口的探测，
+// 最后按文件顺序发送rarity不超过VersionIntensity的其他探测。
+// 响应依次用探测自身、fallback探测和NULL探测的规则匹配，硬匹配后立即结束；
+// 软匹配后只继续发送含有该服务规则的探测。
+// 匹配到ssl服务，或端口属于sslports且没有硬匹配时，建立TLS连接后重新探测。
+type versionEngine struct {
+	db   *nmap.NmapDB
+	opts *FingerprintOptions
+}
+
+// run 执行版本探测，第一个探测连接失败时返回错误
+func (e *versionEngine) run(target string, port int, protocol string) (*versionResult, error) {
+	protocol = strings.ToUpper(protocol)
+	if protocol == "" {
+		protocol = "TCP"
+	}
+
+	result := &versionResult{}
+	match, err := e.runPhase(result, target, port, protocol, false)
+	if err != nil {
+		return nil, err
+	}
+	result.match = match
+
+	if protocol == "TCP" && e.needTLS(port, match) {
+		tlsResult := &versionResult{}
+		tlsMatch, err := e.runPhase(tlsResult, target, port, protocol, true)
+		result.probes = append(result.probes, tlsResult.probes...)
+		if err == nil && tlsMatch != nil && tlsMatch.Service != "ssl" &&
+			(match == nil || match.Soft || match.Service == "ssl" || !tlsMatch.Soft) {
+			result.match = tlsMatch
+			result.tunnel = "ssl"
+		}
+	}
+	return result, nil
+}
+
+// needTLS 判断是否需要通过TLS重新探测
+func (e *versionEngine) needTLS(port int, match *nmap.ServiceMatch) bool {
+	if match != nil && match.Service == "ssl" {
+		return true
+	}
+	if match != nil && !match.Soft {
+		return false
+	}
+	for _, probe := range e.db.ServiceProbes {
+		if probe.HasSSLPort(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPhase 在明文或TLS连接上按顺序发送探测，返回最佳匹配
+func (e *versionEngine) runPhase(result *versionResult, target string, port int, protocol string, useTLS bool) (*nmap.ServiceMatch, error) {
+	var soft *nmap.ServiceMatch
+	for i, probe := range e.probeOrder(port, protocol, useTLS) {
+		if soft != nil && !e.canMatch(probe, soft.Service) {
+			continue
+		}
+
+		response, err := e.send(probe, target, port, useTLS)
+		if err != nil {
+			// 第一个探测无法建立连接说明端口不可用
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		result.probes = append(result.probes, e.probeResult(probe, target, port, useTLS, response))
+		if len(response) == 0 {
+			continue
+		}
+
+		m, err := e.db.MatchProbeResponse(probe.Name, response)
+		if err != nil || m == nil || (soft != nil && m.Service != soft.Service) {
+			continue
+		}
+		if !m.Soft {
+			return m, nil
+		}
+		if soft == nil {
+			soft = m
+		}
+	}
+	return soft, nil
+}
+
+// probeOrder 返回按发送顺序排列的探测
+func (e *versionEngine) probeOrder(port int, protocol string, useTLS bool) []*nmap.Probe {
+	intensity := e.opts.VersionIntensity
+	if intensity < 0 {
+		intensity = 0
+	} else if intensity > 9 {
+		intensity = 9
+	}
+
+	var first, registered, others []*nmap.Probe
+	for _, probe := range e.db.ServiceProbes {
+		if probe.Protocol != protocol {
+			continue
+		}
+		switch {
+		case probe.Name == "NULL":
+			first = append(first, probe)
+		case probe.NoPayload:
+			// no-payload探测只用于匹配，不单独发送
+		case probe.TLSOnly && !useTLS:
+			// 用户规则要求的TLS探测不在明文连接上发送
+		case probe.HasPort(port) || (useTLS && probe.HasSSLPort(port)):
+			registered = append(registered, probe)
+		case probe.Rarity <= intensity:
+			others = append(others, probe)
+		}
+	}
+	return append(append(first, registered...), others...)
+}
+
+// canMatch 判断探测(含fallback和NULL)是否有指定服务的硬匹配规则
+func (e *versionEngine) canMatch(probe *nmap.Probe, service string) bool {
+	names := append([]string{probe.Name, "NULL"}, probe.Fallback...)
+	for _, name := range names {
+		candidate, ok := e.db.Probes[name]
+		if !ok {
+			continue
+		}
+		for _, rule := range candidate.Matches {
+			if !rule.Soft && rule.Service == service {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// send 建立新连接发送探测数据并读取响应
+func (e *versionEngine) send(probe *nmap.Probe, target string, port int, useTLS bool) ([]byte, error) {
+	network := strings.ToLower(probe.Protocol)
+	conn, err := e.opts.dial(network, net.JoinHostPort(target, fmt.Sprint(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	wait := e.opts.Timeout
+	if probe.TotalWaitMS > 0 {
+		if total := time.Duration(probe.TotalWaitMS) * time.Millisecond; wait <= 0 || total < wait {
+			wait = total
+		}
+	}
+	if wait <= 0 {
+		wait = 5 * time.Second
+	}
+	deadline := time.Now().Add(wait)
+
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+		tlsConn.SetDeadline(deadline)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, fmt.Errorf("TLS握手失败: %v", err)
+		}
+		conn = tlsConn
+	}
+
+	if len(probe.Payload) > 0 {
+		conn.SetWriteDeadline(deadline)
+		if _, err := conn.Write(probe.Payload); err != nil {
+			return nil, err
+		}
+	}
+	return readProbeResponse(conn, deadline), nil
+}
+
+// readProbeResponse 读取响应直到连接关闭、超过总等待时间或短暂空闲
+func readProbeResponse(conn net.Conn, deadline time.Time) []byte {
+	var response []byte
+	buf := make([]byte, 4096)
+	for len(response) < maxProbeResponse {
+		readDeadline := deadline
+		if len(response) > 0 {
+			if idle := time.Now().Add(probeIdleTimeout); idle.Before(deadline) {
+				readDeadline = idle
+			}
+		}
+		conn.SetReadDeadline(readDeadline)
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		// 连接关闭、超时或出错时返回已读取的数据
+		if err != nil {
+			break
+		}
+	}
+	if len(response) > maxProbeResponse {
+		response = response[:maxProbeResponse]
+	}
+	return response
+}
+
+// probeResult 记录探测的请求和响应
+func (e *versionEngine) probeResult(probe *nmap.Probe, target string, port int, useTLS bool, response []byte) ProbeResult {
+	name := probe.Name
+	if useTLS {
+		name = "ssl/" + name
+	}
+	return ProbeResult{
+		Type:      name,
+		Target:    target,
+		Port:      port,
+		Protocol:  strings.ToLower(probe.Protocol),
+		Data:      probe.Payload,
+		Response:  response,
+		Timestamp: time.Now(),
+		Features: map[string]string{
+			strings.ToLower(name): string(response),
+		},
+	}
+}
+
+// applyServiceMatch 将匹配结果填充到服务指纹
+func applyServiceMatch(fp *ServiceFingerprint, m *nmap.ServiceMatch) {
+	fp.Name = m.Service
+	fp.Product = m.Product
+	fp.Version = m.Version
+	fp.Info = m.Info
+	fp.Hostname = m.Hostname
+	fp.OS = m.OS
+	fp.DeviceType = m.DeviceType
+	fp.CPE = m.CPE
+	fp.Features["probe"] = m.Probe
+	fp.Confidence = 0.9
+	if m.Soft {
+		// 软匹配只确定了服务类型
+		fp.Confidence = 0.6
+	}
+}
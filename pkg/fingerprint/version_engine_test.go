@@ -0,0 +1,180 @@
+package fingerprint
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint/nmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVersionProbes = `Probe TCP NULL q||
+match ftp m|^220 \(vsFTPd ([\d.]+)\)| p/vsftpd/ v/$1/
+
+Probe TCP GetRequest q|GET / HTTP/1.0\r\n\r\n|
+rarity 1
+ports 80
+match http m|^HTTP/1\.[01] \d\d\d .*?\r\nServer: nginx/([\d.]+)|s p/nginx/ v/$1/ cpe:/a:igor_sysoev:nginx:$1/
+softmatch http m|^HTTP/1\.[01] \d\d\d |
+
+Probe TCP Help q|HELP\r\n|
+rarity 3
+fallback GetRequest
+
+Probe TCP Rare q|RARE\r\n|
+rarity 8
+match rare m|^RARE-OK| p/Rare/
+`
+
+// fakeService 按收到的探测数据返回响应的模拟服务，记录收到的探测
+type fakeService struct {
+	mu       sync.Mutex
+	received []string
+	respond  func(payload string) string
+}
+
+// dial 返回与模拟服务相连的内存连接
+func (s *fakeService) dial(network, address string, timeout time.Duration) (net.Conn, error) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		buf := make([]byte, 1024)
+		server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, _ := server.Read(buf)
+		payload := string(buf[:n])
+
+		s.mu.Lock()
+		s.received = append(s.received, payload)
+		s.mu.Unlock()
+
+		if response := s.respond(payload); response != "" {
+			server.SetWriteDeadline(time.Now().Add(time.Second))
+			server.Write([]byte(response))
+		}
+	}()
+	return client, nil
+}
+
+func (s *fakeService) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+func newTestEngine(t *testing.T, service *fakeService, intensity int) *versionEngine {
+	sp, err := nmap.ParseServiceProbes(strings.NewReader(testVersionProbes))
+	require.NoError(t, err)
+	require.Empty(t, sp.Errors)
+	db := nmap.NewNmapDB()
+	db.AddServiceProbes(sp)
+
+	opts := DefaultFingerprintOptions()
+	opts.Timeout = time.Second
+	opts.VersionIntensity = intensity
+	opts.Dial = service.dial
+	return &versionEngine{db: db, opts: opts}
+}
+
+func TestVersionEngineNullProbe(t *testing.T) {
+	service := &fakeService{respond: func(payload string) string {
+		if payload == "" {
+			return "220 (vsFTPd 3.0.3)\r\n"
+		}
+		return ""
+	}}
+
+	// 非标准端口上的banner由NULL探测识别，硬匹配后不再发送其他探测
+	result, err := newTestEngine(t, service, 7).run("192.0.2.1", 2121, "tcp")
+	require.NoError(t, err)
+	require.NotNil(t, result.match)
+	assert.Equal(t, "ftp", result.match.Service)
+	assert.Equal(t, "3.0.3", result.match.Version)
+	assert.Equal(t, []string{""}, service.sent())
+	require.Len(t, result.probes, 1)
+	assert.Equal(t, "NULL", result.probes[0].Type)
+}
+
+func TestVersionEngineIntensity(t *testing.T) {
+	newService := func() *fakeService {
+		return &fakeService{respond: func(payload string) string {
+			if strings.HasPrefix(payload, "GET ") {
+				return "HTTP/1.1 200 OK\r\nServer: nginx/1.24.0\r\n\r\n"
+			}
+			return ""
+		}}
+	}
+
+	// 非标准端口上按rarity发送GetRequest
+	service := newService()
+	result, err := newTestEngine(t, service, 7).run("192.0.2.1", 12345, "tcp")
+	require.NoError(t, err)
+	require.NotNil(t, result.match)
+	assert.Equal(t, "nginx", result.match.Product)
+	assert.Equal(t, []string{"cpe:/a:igor_sysoev:nginx:1.24.0"}, result.match.CPE)
+	assert.Equal(t, []string{"", "GET / HTTP/1.0\r\n\r\n"}, service.sent())
+
+	// 强度为0时只发送NULL探测和端口登记的探测
+	service = newService()
+	result, err = newTestEngine(t, service, 0).run("192.0.2.1", 12345, "tcp")
+	require.NoError(t, err)
+	assert.Nil(t, result.match)
+	assert.Equal(t, []string{""}, service.sent())
+
+	service = newService()
+	result, err = newTestEngine(t, service, 0).run("192.0.2.1", 80, "tcp")
+	require.NoError(t, err)
+	require.NotNil(t, result.match)
+	assert.Equal(t, "1.24.0", result.match.Version)
+}
+
+func TestVersionEngineFallback(t *testing.T) {
+	service := &fakeService{respond: func(payload string) string {
+		if payload == "HELP\r\n" {
+			return "HTTP/1.0 400 Bad Request\r\nServer: nginx/1.18.0\r\n\r\n"
+		}
+		return ""
+	}}
+
+	// Help探测自身没有规则，响应由fallback的GetRequest规则匹配
+	result, err := newTestEngine(t, service, 7).run("192.0.2.1", 12345, "tcp")
+	require.NoError(t, err)
+	require.NotNil(t, result.match)
+	assert.Equal(t, "GetRequest", result.match.Probe)
+	assert.Equal(t, "1.18.0", result.match.Version)
+}
+
+func TestVersionEngineSoftMatch(t *testing.T) {
+	service := &fakeService{respond: func(payload string) string {
+		if strings.HasPrefix(payload, "GET ") {
+			return "HTTP/1.0 404 Not Found\r\n\r\n"
+		}
+		return ""
+	}}
+
+	// 软匹配http后跳过无法给出http硬匹配的Rare探测
+	result, err := newTestEngine(t, service, 9).run("192.0.2.1", 12345, "tcp")
+	require.NoError(t, err)
+	require.NotNil(t, result.match)
+	assert.True(t, result.match.Soft)
+	assert.Equal(t, "http", result.match.Service)
+	assert.NotContains(t, service.sent(), "RARE\r\n")
+	assert.Contains(t, service.sent(), "HELP\r\n")
+
+	fp := &ServiceFingerprint{Features: make(map[string]string)}
+	applyServiceMatch(fp, result.match)
+	assert.Equal(t, 0.6, fp.Confidence)
+}
+
+func TestVersionEngineDialError(t *testing.T) {
+	engine := newTestEngine(t, &fakeService{}, 7)
+	engine.opts.Dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	_, err := engine.run("192.0.2.1", 21, "tcp")
+	assert.Error(t, err)
+}
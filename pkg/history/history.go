@@ -0,0 +1,487 @@
+// Package history 本地扫描历史库
+//
+// 每次扫描的主机、端口、服务和操作系统结果按时间追加到单个JSON Lines文件中，每个主机一行，
+// CLI和API共用同一个文件，可作为资产清单查询。
+//
+// 没有使用SQLite或bbolt：SQLite需要cgo或体积很大的纯Go实现，会破坏单一静态二进制的发布方式；
+// bbolt同一时间只允许一个进程打开，而CLI、monitor和API服务需要同时写入。追加写入的文本文件
+// 不需要文件锁，查询按顺序读取全部记录，对资产清单规模的历史足够快，并可用Prune控制文件大小。
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/google/uuid"
+)
+
+// 扫描来源
+const (
+	SourceCLI      = "cli"      // scan命令
+	SourceMonitor  = "monitor"  // monitor命令
+	SourceAPI      = "api"      // API扫描任务
+	SourceSchedule = "schedule" // 定时计划创建的API任务
+	SourceJob      = "job"      // 分布式扫描任务
+)
+
+// maxLineSize 单行记录的最大长度，超过时写入前去掉关闭和过滤的端口，读取时跳过该行
+var maxLineSize = 64 * 1024 * 1024
+
+// Scan 一次扫描的记录
+type Scan struct {
+	ID       string                `json:"id"`                  // 扫描ID
+	Source   string                `json:"source"`              // 扫描来源
+	SourceID string                `json:"source_id,omitempty"` // 来源中的任务或计划ID
+	Target   string                `json:"target"`              // 扫描目标
+	Ports    string                `json:"ports,omitempty"`     // 端口范围
+	ScanType string                `json:"scan_type"`           // 扫描类型
+	Start    time.Time             `json:"start"`               // 开始时间
+	End      time.Time             `json:"end"`                 // 结束时间
+	Hosts    []*scanner.HostResult `json:"hosts"`               // 主机结果
+}
+
+// Observation 某次扫描中观察到的一个端口
+type Observation struct {
+	ScanID    string            `json:"scan_id"`             // 扫描ID
+	Time      time.Time         `json:"time"`                // 观察时间
+	Host      string            `json:"host"`                // 主机地址
+	Hostnames []string          `json:"hostnames,omitempty"` // 主机名
+	Port      int               `json:"port"`                // 端口
+	Protocol  string            `json:"protocol"`            // 协议
+	State     scanner.PortState `json:"state"`               // 端口状态
+	Service   string            `json:"service,omitempty"`   // 服务名称
+	Product   string            `json:"product,omitempty"`   // 产品名称
+	Version   string            `json:"version,omitempty"`   // 版本
+	OS        string            `json:"os,omitempty"`        // 主机的操作系统
+}
+
+// Query 历史查询条件，零值字段不参与过滤
+type Query struct {
+	Host    string            // IP地址、主机名或CIDR
+	Port    int               // 端口
+	Service string            // 服务名称或产品名称，不区分大小写的子串匹配
+	State   scanner.PortState // 端口状态
+	Since   time.Time         // 起始时间(含)
+	Until   time.Time         // 结束时间(不含)
+	Latest  bool              // 每个主机端口只保留最近一次观察，即当前资产清单
+	Limit   int               // 最多返回的条数，取最新的记录
+}
+
+// Store 扫描历史库
+// 同一进程内的读写由互斥锁保护；多个进程追加写入时每条记录一次写入，不会交错。
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// DefaultPath 返回默认的历史库文件路径
+func DefaultPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".go-port-rocket", "history.jsonl")
+}
+
+// Open 打开历史库，文件不存在时在首次写入时创建
+func Open(path string) (*Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("历史库路径不能为空")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建历史库目录失败: %v", err)
+	}
+	return &Store{path: path}, nil
+}
+
+// Path 返回历史库文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Record 追加一次扫描记录，未设置ID和结束时间时自动填充
+// 每个主机写成一行，同一次扫描的各行共享ID，读取扫描记录时按ID合并
+func (s *Store) Record(scan *Scan) error {
+	if scan.ID == "" {
+		scan.ID = uuid.New().String()
+	}
+	if scan.End.IsZero() {
+		scan.End = time.Now()
+	}
+	if scan.Start.IsZero() {
+		scan.Start = scan.End
+	}
+
+	data, err := encodeScan(scan)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("打开历史库失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("写入历史库失败: %v", err)
+	}
+	return nil
+}
+
+// encodeScan 将扫描记录按主机拆分为多行
+// 单个主机的记录超过maxLineSize时去掉关闭和过滤的端口，仍然超过则返回错误
+func encodeScan(scan *Scan) ([]byte, error) {
+	hosts := scan.Hosts
+	if len(hosts) == 0 {
+		hosts = []*scanner.HostResult{nil}
+	}
+
+	var buf bytes.Buffer
+	for _, host := range hosts {
+		line := *scan
+		line.Hosts = []*scanner.HostResult{}
+		if host != nil {
+			line.Hosts = append(line.Hosts, host)
+		}
+		data, err := json.Marshal(&line)
+		if err != nil {
+			return nil, fmt.Errorf("序列化扫描记录失败: %v", err)
+		}
+		if len(data) >= maxLineSize && host != nil {
+			line.Hosts[0] = withoutClosedPorts(host)
+			if data, err = json.Marshal(&line); err != nil {
+				return nil, fmt.Errorf("序列化扫描记录失败: %v", err)
+			}
+			if len(data) >= maxLineSize {
+				return nil, fmt.Errorf("主机 %s 的扫描记录超过 %d 字节", host.Address(), maxLineSize)
+			}
+			logger.Warnf("主机 %s 的扫描记录过大，只保存开放的端口", host.Address())
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// withoutClosedPorts 返回去掉关闭和过滤端口的主机结果副本
+func withoutClosedPorts(host *scanner.HostResult) *scanner.HostResult {
+	copied := host.Clone()
+	for _, list := range []*[]scanner.PortResult{&copied.TCP, &copied.UDP, &copied.SCTP} {
+		kept := (*list)[:0]
+		for _, p := range *list {
+			if p.State != scanner.PortStateClosed && p.State != scanner.PortStateFiltered {
+				kept = append(kept, p)
+			}
+		}
+		*list = kept
+	}
+	return copied
+}
+
+// Scans 返回时间范围内包含匹配主机的扫描记录，按时间升序，Limit取最新的记录
+func (s *Store) Scans(q Query) ([]*Scan, error) {
+	var all []*Scan
+	byID := make(map[string]*Scan)
+	err := s.each(func(scan *Scan) {
+		if existing, ok := byID[scan.ID]; ok {
+			existing.Hosts = append(existing.Hosts, scan.Hosts...)
+			return
+		}
+		byID[scan.ID] = scan
+		all = append(all, scan)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scans := make([]*Scan, 0)
+	for _, scan := range all {
+		if !inRange(scan.End, q.Since, q.Until) {
+			continue
+		}
+		if q.Host != "" {
+			matched := false
+			for _, host := range scan.Hosts {
+				if matchHost(host, q.Host) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		scans = append(scans, scan)
+	}
+	sort.SliceStable(scans, func(i, j int) bool { return scans[i].End.Before(scans[j].End) })
+	if q.Limit > 0 && len(scans) > q.Limit {
+		scans = scans[len(scans)-q.Limit:]
+	}
+	return scans, nil
+}
+
+// Query 按条件查询端口观察记录，按时间、主机和端口升序，Limit取最新的记录
+func (s *Store) Query(q Query) ([]Observation, error) {
+	observations := make([]Observation, 0)
+	err := s.each(func(scan *Scan) {
+		if !inRange(scan.End, q.Since, q.Until) {
+			return
+		}
+		for _, host := range scan.Hosts {
+			if q.Host != "" && !matchHost(host, q.Host) {
+				continue
+			}
+			osName := ""
+			if len(host.OS) > 0 {
+				osName = strings.TrimSpace(host.OS[0].Name + " " + host.OS[0].Version)
+			}
+			for _, p := range host.Ports() {
+				obs := Observation{
+					ScanID:    scan.ID,
+					Time:      scan.End,
+					Host:      host.Address(),
+					Hostnames: host.Hostnames,
+					Port:      p.Port,
+					Protocol:  p.Protocol,
+					State:     p.State,
+					OS:        osName,
+				}
+				if p.Service != nil {
+					obs.Service, obs.Product, obs.Version = p.Service.Name, p.Service.Product, p.Service.Version
+				}
+				// 资产清单需要端口的最新状态，状态过滤在去重之后进行
+				if q.Port > 0 && obs.Port != q.Port || q.Service != "" && !matchService(obs, q.Service) ||
+					!q.Latest && q.State != "" && obs.State != q.State {
+					continue
+				}
+				observations = append(observations, obs)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(observations, func(i, j int) bool {
+		a, b := observations[i], observations[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Port < b.Port
+	})
+	if q.Latest {
+		observations = latest(observations, q.State)
+	}
+	if q.Limit > 0 && len(observations) > q.Limit {
+		observations = observations[len(observations)-q.Limit:]
+	}
+	return observations, nil
+}
+
+// Prune 删除早于before的扫描记录，返回删除的记录数
+// 通过重写文件实现，执行期间其他进程追加的记录可能丢失。
+func (s *Store) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取历史库失败: %v", err)
+	}
+
+	var kept bytes.Buffer
+	removed := make(map[string]bool)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var scan struct {
+			ID  string    `json:"id"`
+			End time.Time `json:"end"`
+		}
+		if err := json.Unmarshal(line, &scan); err == nil && scan.End.Before(before) {
+			removed[scan.ID] = true
+			continue
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("写入历史库失败: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return 0, fmt.Errorf("替换历史库失败: %v", err)
+	}
+	return len(removed), nil
+}
+
+// each 依次读取每一行记录，跳过无法解析和超过maxLineSize的行
+func (s *Store) each(fn func(scan *Scan)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开历史库失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, tooLong, err := readLine(reader)
+		if err == io.EOF && len(data) == 0 && !tooLong {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("读取历史库失败: %v", err)
+		}
+		switch {
+		case tooLong:
+			logger.Warnf("跳过历史库第 %d 行: 超过 %d 字节", line, maxLineSize)
+		case len(bytes.TrimSpace(data)) > 0:
+			var scan Scan
+			if err := json.Unmarshal(data, &scan); err != nil {
+				logger.Warnf("跳过历史库第 %d 行: %v", line, err)
+				break
+			}
+			fn(&scan)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// readLine 读取一行，超过maxLineSize的行丢弃剩余内容并返回tooLong
+func readLine(reader *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(line) > maxLineSize {
+				line, tooLong = nil, true
+			}
+		}
+		if err != bufio.ErrBufferFull {
+			return line, tooLong, err
+		}
+	}
+}
+
+// latest 每个主机端口只保留最近一次观察，再按状态过滤
+func latest(observations []Observation, state scanner.PortState) []Observation {
+	index := make(map[string]int)
+	var result []Observation
+	for _, obs := range observations {
+		key := fmt.Sprintf("%s/%s/%d", obs.Host, obs.Protocol, obs.Port)
+		if i, ok := index[key]; ok {
+			result[i] = obs
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, obs)
+	}
+
+	filtered := result[:0]
+	for _, obs := range result {
+		if state == "" || obs.State == state {
+			filtered = append(filtered, obs)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Port < b.Port
+	})
+	return filtered
+}
+
+// matchHost 主机的地址或主机名与条件相同，或地址在CIDR范围内
+func matchHost(host *scanner.HostResult, pattern string) bool {
+	_, network, _ := net.ParseCIDR(pattern)
+	for _, addr := range host.Addresses {
+		if addr == pattern {
+			return true
+		}
+		if ip := net.ParseIP(addr); network != nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	for _, name := range host.Hostnames {
+		if strings.EqualFold(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchService 服务名称或产品名称包含关键字
+func matchService(obs Observation, keyword string) bool {
+	keyword = strings.ToLower(keyword)
+	return strings.Contains(strings.ToLower(obs.Service), keyword) ||
+		strings.Contains(strings.ToLower(obs.Product), keyword)
+}
+
+// inRange 时间在[since, until)范围内，零值表示不限制
+func inRange(t, since, until time.Time) bool {
+	if !since.IsZero() && t.Before(since) {
+		return false
+	}
+	if !until.IsZero() && !t.Before(until) {
+		return false
+	}
+	return true
+}
+
+// ParseTime 解析查询时间，支持RFC3339、日期(2006-01-02)、日期时间(2006-01-02 15:04)，
+// 以及相对于now的时长，如"24h"、"7d"表示now之前的时间点
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %s，应为RFC3339、2006-01-02或24h、7d等时长", s)
+}
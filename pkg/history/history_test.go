@@ -0,0 +1,178 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHost(address string, results ...scanner.ScanResult) *scanner.HostResult {
+	host := scanner.NewHostResult(address)
+	host.AddResults(scanner.ScanTypeTCP, results)
+	return host
+}
+
+func TestStoreQuery(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history", "history.jsonl"))
+	require.NoError(t, err)
+
+	day1 := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	require.NoError(t, store.Record(&Scan{Source: SourceCLI, Target: "192.0.2.0/30", ScanType: "tcp", End: day1, Hosts: []*scanner.HostResult{
+		testHost("192.0.2.1",
+			scanner.ScanResult{Port: 22, State: scanner.PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Product: "OpenSSH", Version: "8.9"}},
+			scanner.ScanResult{Port: 80, State: scanner.PortStateOpen, Service: &fingerprint.Service{Name: "http", Product: "nginx"}},
+		),
+		testHost("192.0.2.2", scanner.ScanResult{Port: 80, State: scanner.PortStateOpen}),
+	}}))
+	require.NoError(t, store.Record(&Scan{Source: SourceAPI, Target: "192.0.2.1", ScanType: "tcp", End: day2, Hosts: []*scanner.HostResult{
+		testHost("192.0.2.1",
+			scanner.ScanResult{Port: 22, State: scanner.PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Product: "OpenSSH", Version: "9.6"}},
+			scanner.ScanResult{Port: 80, State: scanner.PortStateClosed},
+		),
+	}}))
+
+	all, err := store.Query(Query{})
+	require.NoError(t, err)
+	assert.Len(t, all, 5)
+
+	ssh, err := store.Query(Query{Service: "openssh"})
+	require.NoError(t, err)
+	require.Len(t, ssh, 2)
+	assert.Equal(t, "8.9", ssh[0].Version)
+	assert.Equal(t, "9.6", ssh[1].Version)
+
+	port80, err := store.Query(Query{Host: "192.0.2.0/24", Port: 80, State: scanner.PortStateOpen})
+	require.NoError(t, err)
+	assert.Len(t, port80, 2)
+
+	ranged, err := store.Query(Query{Host: "192.0.2.1", Since: day2})
+	require.NoError(t, err)
+	assert.Len(t, ranged, 2)
+	ranged, err = store.Query(Query{Until: day2})
+	require.NoError(t, err)
+	assert.Len(t, ranged, 3)
+
+	// 资产清单取每个端口的最新状态，192.0.2.1:80已关闭
+	inventory, err := store.Query(Query{Latest: true, State: scanner.PortStateOpen})
+	require.NoError(t, err)
+	require.Len(t, inventory, 2)
+	assert.Equal(t, "192.0.2.1", inventory[0].Host)
+	assert.Equal(t, 22, inventory[0].Port)
+	assert.Equal(t, "9.6", inventory[0].Version)
+	assert.Equal(t, "192.0.2.2", inventory[1].Host)
+
+	latest, err := store.Query(Query{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	assert.Equal(t, day2, latest[0].Time.UTC())
+
+	scans, err := store.Scans(Query{Host: "192.0.2.2"})
+	require.NoError(t, err)
+	require.Len(t, scans, 1)
+	assert.Equal(t, SourceCLI, scans[0].Source)
+	assert.NotEmpty(t, scans[0].ID)
+
+	// 损坏的行被跳过，不影响其他记录
+	file, err := os.OpenFile(store.Path(), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString("{broken\n")
+	require.NoError(t, err)
+	file.Close()
+	scans, err = store.Scans(Query{})
+	require.NoError(t, err)
+	assert.Len(t, scans, 2)
+
+	removed, err := store.Prune(day2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	scans, err = store.Scans(Query{})
+	require.NoError(t, err)
+	require.Len(t, scans, 1)
+	assert.Equal(t, SourceAPI, scans[0].Source)
+}
+
+func TestStoreLargeScans(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	require.NoError(t, err)
+
+	saved := maxLineSize
+	maxLineSize = 2048
+	defer func() { maxLineSize = saved }()
+
+	closed := make([]scanner.ScanResult, 0, 100)
+	for port := 1000; port < 1100; port++ {
+		closed = append(closed, scanner.ScanResult{Port: port, State: scanner.PortStateClosed})
+	}
+	large := testHost("192.0.2.1", append(closed, scanner.ScanResult{Port: 443, State: scanner.PortStateOpen})...)
+	require.NoError(t, store.Record(&Scan{Target: "192.0.2.0/30", Hosts: []*scanner.HostResult{
+		large,
+		testHost("192.0.2.2", scanner.ScanResult{Port: 80, State: scanner.PortStateOpen}),
+	}}))
+
+	// 每个主机一行，读取时按ID合并为一次扫描
+	data, err := os.ReadFile(store.Path())
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+	scans, err := store.Scans(Query{})
+	require.NoError(t, err)
+	require.Len(t, scans, 1)
+	assert.Len(t, scans[0].Hosts, 2)
+
+	// 超过限制的主机只保存开放的端口，原结果不受影响
+	ports, err := store.Query(Query{Host: "192.0.2.1"})
+	require.NoError(t, err)
+	require.Len(t, ports, 1)
+	assert.Equal(t, 443, ports[0].Port)
+	assert.Len(t, large.TCP, 101)
+
+	// 去掉关闭端口后仍然过大时返回错误
+	open := make([]scanner.ScanResult, 0, 100)
+	for port := 1000; port < 1100; port++ {
+		open = append(open, scanner.ScanResult{Port: port, State: scanner.PortStateOpen})
+	}
+	assert.Error(t, store.Record(&Scan{Hosts: []*scanner.HostResult{testHost("192.0.2.3", open...)}}))
+
+	// 超长的行被跳过，不影响前后的记录
+	file, err := os.OpenFile(store.Path(), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(strings.Repeat("x", 10000) + "\n")
+	require.NoError(t, err)
+	file.Close()
+	require.NoError(t, store.Record(&Scan{Hosts: []*scanner.HostResult{testHost("192.0.2.4")}}))
+	scans, err = store.Scans(Query{})
+	require.NoError(t, err)
+	assert.Len(t, scans, 2)
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	parsed, err := ParseTime("7d", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, -7), parsed)
+
+	parsed, err = ParseTime("36h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-36*time.Hour), parsed)
+
+	parsed, err = ParseTime("2024-03-01T08:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), parsed.UTC())
+
+	parsed, err = ParseTime("2024-03-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), parsed)
+
+	for _, bad := range []string{"yesterday", "-3d", "3x"} {
+		_, err := ParseTime(bad, now)
+		assert.Error(t, err, bad)
+	}
+}
@@ -2,6 +2,8 @@ package mcp
 
 import (
 	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 )
 
 // 这个文件包含MCP模块中使用的模型结构定义
@@ -11,6 +13,9 @@ import (
 // ScanResult 扫描结果
 type ScanResult struct {
 	Target    string          `json:"target"`          // 目标
+	Addresses []string        `json:"addresses"`       // IP地址
+	MAC       string          `json:"mac,omitempty"`   // MAC地址
+	Status    string          `json:"status"`          // 主机存活状态
 	StartTime time.Time       `json:"start_time"`      // 开始时间
 	EndTime   time.Time       `json:"end_time"`        // 结束时间
 	Ports     []PortInfo      `json:"ports"`           // 端口信息
@@ -19,6 +24,55 @@ type ScanResult struct {
 	Vulns     []Vulnerability `json:"vulnerabilities"` // 漏洞信息
 }
 
+// NewScanResult 从以主机为中心的扫描结果创建MCP扫描结果
+func NewScanResult(host *scanner.HostResult) *ScanResult {
+	result := &ScanResult{
+		Target:    host.Name(),
+		Addresses: host.Addresses,
+		MAC:       host.MAC,
+		Status:    string(host.Status),
+		StartTime: host.Timing.Start,
+		EndTime:   host.Timing.End,
+		Ports:     make([]PortInfo, 0),
+		Services:  make([]Service, 0),
+	}
+
+	for _, port := range host.Ports() {
+		info := PortInfo{
+			Port:     port.Port,
+			Protocol: port.Protocol,
+			State:    string(port.State),
+			Banner:   port.Banner,
+		}
+		if port.Service != nil {
+			info.Service = port.Service.Name
+			info.Version = port.Service.Version
+			result.Services = append(result.Services, Service{
+				Name:     port.Service.Name,
+				Port:     port.Port,
+				Protocol: port.Protocol,
+				Version:  port.Service.Version,
+				Product:  port.Service.Product,
+				CPE:      port.Service.CPE,
+			})
+		}
+		result.Ports = append(result.Ports, info)
+	}
+
+	// 只保留置信度最高的OS匹配
+	if len(host.OS) > 0 {
+		best := host.OS[0]
+		result.OS = OSInfo{
+			Name:     best.Name,
+			Version:  best.Version,
+			Family:   best.Family,
+			Accuracy: int(best.Accuracy),
+			CPE:      best.CPE,
+		}
+	}
+	return result
+}
+
 // PortInfo 端口信息
 type PortInfo struct {
 	Port     int    `json:"port"`     // 端口号
@@ -374,6 +374,7 @@ const (
 	IntentService  IntentType = "service"   // 服务检测
 	IntentVulnScan IntentType = "vuln_scan" // 漏洞扫描
 	IntentOSScan   IntentType = "os_scan"   // 操作系统检测
+	IntentScanPlan IntentType = "scan_plan" // 扫描计划(不执行)
 
 	// 分析意图
 	IntentRiskAnalysis IntentType = "risk"      // 风险分析
@@ -3,6 +3,8 @@ package mcp
 import (
 	"fmt"
 	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 )
 
 // Session 表示MCP会话
@@ -72,6 +74,7 @@ func (s *Session) processQueryInstruction(instruction Instruction) (*Response, e
 				string(IntentPortScan),
 				string(IntentService),
 				string(IntentOSScan),
+				string(IntentScanPlan),
 				string(IntentVulnScan),
 				string(IntentRiskAnalysis),
 				string(IntentCompare),
@@ -83,6 +86,7 @@ func (s *Session) processQueryInstruction(instruction Instruction) (*Response, e
 				"分析上次扫描结果的安全风险",
 				"给出加固建议",
 			},
+			"tools": ToolSchemas(),
 		}
 
 		// 返回帮助信息
@@ -121,40 +125,66 @@ func (s *Session) processQueryInstruction(instruction Instruction) (*Response, e
 func (s *Session) processScanInstruction(instruction Instruction) (*Response, error) {
 	switch instruction.Intent {
 	case IntentPortScan:
-		// 确认有目标参数
-		targetExists := false
-		var target string
-		if t, ok := instruction.Parameters["target"]; ok {
-			target = t.(string)
-			targetExists = true
-		}
-
-		// 如果没有目标参数，返回错误
-		if !targetExists {
+		// 与port_scan工具描述一致，目标和端口范围都必须指定
+		opts := scanOptionsFromParameters(instruction.Parameters)
+		if opts.Target == "" {
 			return &Response{
 				Status:  StatusError,
 				Message: "未指定扫描目标",
 			}, nil
 		}
+		if opts.Ports == "" {
+			return &Response{
+				Status:  StatusError,
+				Message: "未指定端口范围",
+			}, nil
+		}
+		if opts.ScanType == "" {
+			opts.ScanType = scanner.ScanTypeTCP
+		}
+		if err := scanner.ValidateScanType(opts.ScanType); err != nil {
+			return &Response{
+				Status:  StatusError,
+				Message: err.Error(),
+			}, nil
+		}
+		// 默认值与API扫描任务一致
+		if opts.Timeout == 0 {
+			opts.Timeout = 5 * time.Second
+		}
+		if opts.Workers <= 0 {
+			opts.Workers = 100
+		}
 
-		// 获取端口参数
-		ports := "1-1000" // 默认端口范围
-		if p, ok := instruction.Parameters["ports"]; ok {
-			ports = p.(string)
+		result, err := s.runScan(opts)
+		if err != nil {
+			return &Response{
+				Status:  StatusError,
+				Message: fmt.Sprintf("扫描目标 %s 失败: %v", opts.Target, err),
+			}, nil
 		}
 
-		// 构造响应
-		response := &Response{
+		// 记录最近一次扫描，供后续分析和建议使用
+		s.context.SetState("last_scan_target", opts.Target)
+		s.context.SetState("last_scan_ports", opts.Ports)
+		s.context.SetState("last_scan", result)
+
+		openPorts := 0
+		for _, port := range result.Ports {
+			if port.State == string(scanner.PortStateOpen) {
+				openPorts++
+			}
+		}
+		return &Response{
 			Status:  StatusSuccess,
-			Message: fmt.Sprintf("计划扫描目标: %s，端口范围: %s", target, ports),
+			Message: fmt.Sprintf("扫描目标: %s 完成，发现 %d 个开放端口", opts.Target, openPorts),
 			Data: map[string]interface{}{
-				"target": target,
-				"ports":  ports,
-				"status": "running",
+				"target": opts.Target,
+				"ports":  opts.Ports,
+				"status": "completed",
+				"result": result,
 			},
-		}
-
-		return response, nil
+		}, nil
 
 	case IntentService:
 		// 确认有目标参数
@@ -252,6 +282,28 @@ func (s *Session) processScanInstruction(instruction Instruction) (*Response, er
 			},
 		}, nil
 
+	case IntentScanPlan:
+		// 只生成扫描计划，不访问网络，供操作员在大规模扫描前确认
+		plan, err := scanner.PlanScan(scanOptionsFromParameters(instruction.Parameters))
+		if err != nil {
+			return &Response{
+				Status:  StatusError,
+				Message: fmt.Sprintf("生成扫描计划失败: %v", err),
+			}, nil
+		}
+
+		return &Response{
+			Status: StatusSuccess,
+			Message: fmt.Sprintf("扫描计划: %d 个目标，%d 次探测，预估耗时 %s",
+				plan.TargetCount, plan.TotalProbes, plan.EstimatedDuration),
+			Data: map[string]interface{}{
+				"plan": plan,
+			},
+			NextSteps: []string{
+				"确认计划后执行 port_scan",
+			},
+		}, nil
+
 	default:
 		return nil, fmt.Errorf("未知的扫描意图: %s", instruction.Intent)
 	}
@@ -489,3 +541,45 @@ func (s *Session) processConfigInstruction(instruction Instruction) (*Response,
 		return nil, fmt.Errorf("未知的配置意图: %s", instruction.Intent)
 	}
 }
+
+// runScan 执行端口扫描，将以主机为中心的结果转换为MCP扫描结果
+// 扫描经注册表分发，各扫描类型使用各自的扫描器
+func (s *Session) runScan(opts *scanner.ScanOptions) (*ScanResult, error) {
+	opts.Quiet = true
+	host, err := scanner.ExecuteHostScan(opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewScanResult(host), nil
+}
+
+// scanOptionsFromParameters 将指令参数转换为扫描选项
+// 数值参数可能来自JSON解码(float64)或直接传入(int)
+func scanOptionsFromParameters(params map[string]interface{}) *scanner.ScanOptions {
+	opts := &scanner.ScanOptions{}
+	opts.Target, _ = params["target"].(string)
+	opts.Ports, _ = params["ports"].(string)
+	if scanType, ok := params["scan_type"].(string); ok {
+		opts.ScanType = scanner.ScanType(scanType)
+	}
+	opts.Workers = intParameter(params["workers"])
+	if timeout := intParameter(params["timeout"]); timeout > 0 {
+		opts.Timeout = time.Duration(timeout) * time.Second
+	}
+	opts.MaxRetries = intParameter(params["max_retries"])
+	opts.EnableService, _ = params["enable_service"].(bool)
+	opts.EnableOS, _ = params["enable_os"].(bool)
+	return opts
+}
+
+// intParameter 读取整数参数，类型不符时返回0
+func intParameter(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
@@ -1,10 +1,13 @@
 package mcp
 
 import (
+	"context"
+	"net"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -82,14 +85,22 @@ func TestExecuteInstruction_Query(t *testing.T) {
 func TestExecuteInstruction_Scan(t *testing.T) {
 	session := NewSession("test-session")
 
-	// 测试扫描指令
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听本地端口失败: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// 测试扫描指令，参数经JSON解码后数值为float64
 	instruction := Instruction{
 		Type:   TypeScan,
 		Intent: IntentPortScan,
-		Query:  "扫描目标example.com的开放端口",
+		Query:  "扫描目标127.0.0.1的开放端口",
 		Parameters: map[string]interface{}{
-			"target": "example.com",
-			"ports":  "1-1000",
+			"target":  "127.0.0.1",
+			"ports":   strconv.Itoa(port),
+			"timeout": float64(1),
 		},
 	}
 
@@ -100,10 +111,107 @@ func TestExecuteInstruction_Scan(t *testing.T) {
 	assert.NoError(t, err, "执行扫描指令不应返回错误")
 	assert.Equal(t, StatusSuccess, response.Status, "响应状态应为成功")
 	assert.Contains(t, response.Message, "扫描目标", "响应消息应包含扫描信息")
-	assert.Equal(t, "example.com", response.Data["target"], "响应数据中的目标应匹配")
+	assert.Equal(t, "127.0.0.1", response.Data["target"], "响应数据中的目标应匹配")
+	assert.Equal(t, "completed", response.Data["status"], "扫描应已完成")
+
+	// 结果由以主机为中心的扫描结果转换而来
+	result, ok := response.Data["result"].(*ScanResult)
+	if assert.True(t, ok, "响应数据应包含扫描结果") {
+		assert.Equal(t, []string{"127.0.0.1"}, result.Addresses)
+		assert.Equal(t, string(scanner.HostStateUp), result.Status)
+		if assert.Len(t, result.Ports, 1) {
+			assert.Equal(t, port, result.Ports[0].Port)
+			assert.Equal(t, string(scanner.PortStateOpen), result.Ports[0].State)
+		}
+	}
+	lastScan, _ := session.context.GetState("last_scan")
+	assert.Equal(t, result, lastScan, "最近一次扫描结果应保存到会话状态")
+
+	// 未指定端口范围时不执行扫描
+	response, err = session.ExecuteInstruction(Instruction{
+		Type:       TypeScan,
+		Intent:     IntentPortScan,
+		Parameters: map[string]interface{}{"target": "127.0.0.1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusError, response.Status)
+	assert.Contains(t, response.Message, "未指定端口范围")
 
 	// 验证指令已被添加到历史记录
-	assert.Len(t, session.context.History, 1, "历史记录应包含1条指令")
+	assert.Len(t, session.context.History, 2, "历史记录应包含2条指令")
+}
+
+// registryScanner 注册到扫描器注册表的测试扫描器
+type registryScanner struct{}
+
+func (r *registryScanner) Scan(ctx context.Context, opts *scanner.ScanOptions) ([]scanner.ScanResult, error) {
+	return []scanner.ScanResult{{Port: 4242, State: scanner.PortStateOpen, Type: opts.ScanType}}, nil
+}
+
+func (r *registryScanner) ValidateOptions(opts *scanner.ScanOptions) error { return nil }
+
+func (r *registryScanner) RequiresRoot() bool { return false }
+
+func TestExecuteInstruction_ScanUsesRegistry(t *testing.T) {
+	scanType := scanner.ScanType("mcp-registry-test")
+	assert.NoError(t, scanner.RegisterScanner(scanType, func() scanner.BaseScanner { return &registryScanner{} }, scanner.ScannerCapabilities{
+		Protocol:    "tcp",
+		Description: "测试扫描器",
+	}))
+
+	// 扫描类型经注册表分派到对应的扫描器
+	response, err := NewSession("registry-session").ExecuteInstruction(Instruction{
+		Type:   TypeScan,
+		Intent: IntentPortScan,
+		Parameters: map[string]interface{}{
+			"target":    "127.0.0.1",
+			"ports":     "4242",
+			"scan_type": string(scanType),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSuccess, response.Status)
+	result, ok := response.Data["result"].(*ScanResult)
+	if assert.True(t, ok) && assert.Len(t, result.Ports, 1) {
+		assert.Equal(t, 4242, result.Ports[0].Port)
+		assert.Equal(t, string(scanner.PortStateOpen), result.Ports[0].State)
+	}
+}
+
+func TestExecuteInstruction_ScanPlan(t *testing.T) {
+	session := NewSession("test-session")
+
+	// 参数经JSON解码后数值为float64
+	response, err := session.ExecuteInstruction(Instruction{
+		Type:   TypeScan,
+		Intent: IntentScanPlan,
+		Parameters: map[string]interface{}{
+			"target":    "10.0.0.1-4",
+			"ports":     "22,80",
+			"scan_type": "tcp",
+			"workers":   float64(2),
+			"timeout":   float64(1),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSuccess, response.Status)
+
+	plan, ok := response.Data["plan"].(*scanner.ScanPlan)
+	if assert.True(t, ok, "响应数据应包含扫描计划") {
+		assert.Equal(t, int64(4), plan.TargetCount)
+		assert.Equal(t, int64(8), plan.TotalProbes)
+		assert.Equal(t, 2, plan.Workers)
+		assert.Equal(t, 4*time.Second, plan.EstimatedDuration)
+	}
+
+	// 缺少端口时返回错误响应
+	response, err = session.ExecuteInstruction(Instruction{
+		Type:       TypeScan,
+		Intent:     IntentScanPlan,
+		Parameters: map[string]interface{}{"target": "10.0.0.1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusError, response.Status)
 }
 
 func TestExecuteInstruction_Analyze(t *testing.T) {
@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+)
+
+// ToolSchema MCP工具描述，InputSchema为JSON Schema格式
+type ToolSchema struct {
+	Name        string                 `json:"name"`        // 工具名称
+	Description string                 `json:"description"` // 工具说明
+	InputSchema map[string]interface{} `json:"inputSchema"` // 输入参数的JSON Schema
+}
+
+// ToolSchemas 返回MCP可用的全部工具描述
+func ToolSchemas() []ToolSchema {
+	return []ToolSchema{
+		portScanToolSchema(),
+		scanPlanToolSchema(),
+	}
+}
+
+// portScanToolSchema 根据扫描器注册表生成端口扫描工具描述，仅列出已实现的扫描类型
+func portScanToolSchema() ToolSchema {
+	regs := scanner.ImplementedScanners()
+
+	scanTypes := make([]string, 0, len(regs))
+	details := make([]string, 0, len(regs))
+	capabilities := make(map[string]interface{}, len(regs))
+	for _, reg := range regs {
+		name := string(reg.Type)
+		scanTypes = append(scanTypes, name)
+		details = append(details, describeScanType(reg))
+		capabilities[name] = reg.Capabilities
+	}
+
+	return ToolSchema{
+		Name:        "port_scan",
+		Description: "对目标执行端口扫描。扫描类型: " + strings.Join(details, "; "),
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"target": map[string]interface{}{
+					"type":        "string",
+					"description": "扫描目标，IP地址或域名",
+				},
+				"ports": map[string]interface{}{
+					"type":        "string",
+					"description": "端口范围，如 1-1000 或 22,80,443",
+				},
+				"scan_type": map[string]interface{}{
+					"type":           "string",
+					"enum":           scanTypes,
+					"default":        string(scanner.ScanTypeTCP),
+					"description":    "扫描类型",
+					"x-capabilities": capabilities,
+				},
+			},
+			"required": []string{"target", "ports"},
+		},
+	}
+}
+
+// scanPlanToolSchema 生成扫描计划工具描述，参数与端口扫描一致
+func scanPlanToolSchema() ToolSchema {
+	schema := portScanToolSchema()
+	props := schema.InputSchema["properties"].(map[string]interface{})
+	props["target"] = map[string]interface{}{
+		"type":        "string",
+		"description": "扫描目标，支持IP、域名、CIDR(10.0.0.0/24)、范围(10.0.0.1-20)及逗号分隔",
+	}
+	props["workers"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "并发数，缺省时按端口数计算",
+	}
+	props["timeout"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "单次探测超时(秒)",
+	}
+	props["max_retries"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "超时端口的最大重试次数",
+	}
+	props["enable_service"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "是否包含服务检测阶段",
+	}
+	props["enable_os"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "是否包含操作系统检测阶段",
+	}
+
+	return ToolSchema{
+		Name:        "scan_plan",
+		Description: "生成扫描计划而不访问网络: 展开目标与端口，给出各阶段探测次数、预估耗时与速率，以及需要root权限的功能，用于在大规模扫描前确认",
+		InputSchema: schema.InputSchema,
+	}
+}
+
+// describeScanType 生成单个扫描类型的简要说明
+func describeScanType(reg scanner.ScannerRegistration) string {
+	flags := []string{reg.Capabilities.Protocol}
+	if reg.Capabilities.RequiresRoot {
+		flags = append(flags, "需root")
+	}
+	if reg.Capabilities.SupportsIPv6 {
+		flags = append(flags, "支持IPv6")
+	}
+	return fmt.Sprintf("%s(%s): %s", reg.Type, strings.Join(flags, ","), reg.Capabilities.Description)
+}
@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolSchemas(t *testing.T) {
+	tools := ToolSchemas()
+	require.NotEmpty(t, tools)
+
+	scan := tools[0]
+	assert.Equal(t, "port_scan", scan.Name)
+	assert.Contains(t, scan.Description, "syn(tcp,需root)")
+
+	props, ok := scan.InputSchema["properties"].(map[string]interface{})
+	require.True(t, ok)
+	scanType, ok := props["scan_type"].(map[string]interface{})
+	require.True(t, ok)
+
+	enum, ok := scanType["enum"].([]string)
+	require.True(t, ok)
+	assert.Contains(t, enum, "tcp")
+	assert.Contains(t, enum, "udp")
+	// 尚未实现的扫描类型不提供给客户端
+	assert.NotContains(t, enum, "maimon")
+	assert.Equal(t, []string{"target", "ports"}, scan.InputSchema["required"])
+}
+
+func TestScanPlanToolSchema(t *testing.T) {
+	var plan *ToolSchema
+	for _, tool := range ToolSchemas() {
+		if tool.Name == "scan_plan" {
+			tool := tool
+			plan = &tool
+		}
+	}
+	require.NotNil(t, plan)
+
+	props, ok := plan.InputSchema["properties"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Contains(t, props, "scan_type")
+	assert.Contains(t, props, "enable_os")
+	assert.Equal(t, []string{"target", "ports"}, plan.InputSchema["required"])
+
+	// 不影响端口扫描工具的参数
+	scanProps := ToolSchemas()[0].InputSchema["properties"].(map[string]interface{})
+	assert.NotContains(t, scanProps, "enable_os")
+}
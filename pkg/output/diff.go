@@ -0,0 +1,490 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+)
+
+// DiffKind 两次扫描结果之间的差异类型
+type DiffKind string
+
+const (
+	DiffHostAdded      DiffKind = "host-added"      // 新出现的主机
+	DiffHostRemoved    DiffKind = "host-removed"    // 消失的主机
+	DiffPortOpened     DiffKind = "port-opened"     // 端口变为开放
+	DiffPortClosed     DiffKind = "port-closed"     // 开放端口变为关闭、过滤或未扫描
+	DiffPortState      DiffKind = "port-state"      // 其他端口状态变化，如关闭与过滤之间的切换
+	DiffServiceChanged DiffKind = "service-changed" // 服务、产品或版本变化
+	DiffOSChanged      DiffKind = "os-changed"      // 操作系统识别结果变化
+)
+
+// DiffKinds 所有差异类型，按报告中的展示顺序排列
+var DiffKinds = []DiffKind{DiffHostAdded, DiffHostRemoved, DiffPortOpened, DiffPortClosed, DiffPortState, DiffServiceChanged, DiffOSChanged}
+
+// notScanned 端口只出现在一侧结果中时另一侧的描述
+const notScanned = "未扫描"
+
+// DiffEntry 一项差异
+type DiffEntry struct {
+	Kind     DiffKind `json:"kind"`               // 差异类型
+	Host     string   `json:"host"`               // 主机地址
+	Port     int      `json:"port,omitempty"`     // 端口
+	Protocol string   `json:"protocol,omitempty"` // 协议
+	Old      string   `json:"old,omitempty"`      // 旧结果中的状态、服务或OS
+	New      string   `json:"new,omitempty"`      // 新结果中的状态、服务或OS
+}
+
+// String 返回差异的文字描述
+func (e DiffEntry) String() string {
+	where := e.Host
+	if e.Port > 0 {
+		where = fmt.Sprintf("%s %d/%s", e.Host, e.Port, e.Protocol)
+	}
+	switch e.Kind {
+	case DiffHostAdded:
+		return fmt.Sprintf("[%s] %s (开放端口: %s)", e.Kind, where, e.New)
+	case DiffHostRemoved:
+		return fmt.Sprintf("[%s] %s (开放端口: %s)", e.Kind, where, e.Old)
+	default:
+		return fmt.Sprintf("[%s] %s: %s -> %s", e.Kind, where, e.Old, e.New)
+	}
+}
+
+// ScanDiff 两个扫描结果文件的比较结果
+type ScanDiff struct {
+	Old      string      `json:"old"`       // 旧结果文件
+	New      string      `json:"new"`       // 新结果文件
+	OldHosts int         `json:"old_hosts"` // 旧结果中的主机数
+	NewHosts int         `json:"new_hosts"` // 新结果中的主机数
+	Entries  []DiffEntry `json:"entries"`   // 差异列表
+}
+
+// Count 返回指定类型的差异数量，未指定类型时返回全部差异数量
+func (d *ScanDiff) Count(kinds ...DiffKind) int {
+	if len(kinds) == 0 {
+		return len(d.Entries)
+	}
+	count := 0
+	for _, entry := range d.Entries {
+		for _, kind := range kinds {
+			if entry.Kind == kind {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// ParseDiffKinds 解析逗号分隔的差异类型列表，"any"表示所有类型
+func ParseDiffKinds(s string) ([]DiffKind, error) {
+	var kinds []DiffKind
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" || name == "any" {
+			continue
+		}
+		valid := false
+		for _, kind := range DiffKinds {
+			if DiffKind(name) == kind {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("未知的差异类型: %s", name)
+		}
+		kinds = append(kinds, DiffKind(name))
+	}
+	return kinds, nil
+}
+
+// DiffFiles 读取并比较两个扫描结果文件
+func DiffFiles(oldPath, newPath string) (*ScanDiff, error) {
+	oldHosts, err := LoadScanFile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newHosts, err := LoadScanFile(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ScanDiff{
+		Old:      oldPath,
+		New:      newPath,
+		OldHosts: len(oldHosts),
+		NewHosts: len(newHosts),
+		Entries:  DiffHosts(oldHosts, newHosts),
+	}, nil
+}
+
+// DiffHosts 比较两组主机结果，同一主机按共同的地址或主机名匹配
+func DiffHosts(oldHosts, newHosts []*scanner.HostResult) []DiffEntry {
+	var entries []DiffEntry
+	matched := make(map[*scanner.HostResult]bool)
+	for _, cur := range newHosts {
+		prev := findHost(oldHosts, cur, matched)
+		if prev == nil {
+			entries = append(entries, DiffEntry{Kind: DiffHostAdded, Host: cur.Address(), New: openPortList(cur)})
+			continue
+		}
+		matched[prev] = true
+		entries = append(entries, diffHost(prev, cur)...)
+	}
+	for _, prev := range oldHosts {
+		if !matched[prev] {
+			entries = append(entries, DiffEntry{Kind: DiffHostRemoved, Host: prev.Address(), Old: openPortList(prev)})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Port < b.Port
+	})
+	return entries
+}
+
+// findHost 在未匹配的主机中查找与host共享地址或主机名的主机
+func findHost(hosts []*scanner.HostResult, host *scanner.HostResult, matched map[*scanner.HostResult]bool) *scanner.HostResult {
+	for _, candidate := range hosts {
+		if !matched[candidate] && sharesAny(candidate.Addresses, host.Addresses) {
+			return candidate
+		}
+	}
+	for _, candidate := range hosts {
+		if !matched[candidate] && sharesAny(candidate.Hostnames, host.Hostnames) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// sharesAny 两个列表是否有相同的元素
+func sharesAny(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// changeKinds 持续监控的变化类型对应的差异类型
+var changeKinds = map[scanner.ChangeType]DiffKind{
+	scanner.ChangePortOpened:     DiffPortOpened,
+	scanner.ChangePortClosed:     DiffPortClosed,
+	scanner.ChangeServiceChanged: DiffServiceChanged,
+	scanner.ChangeOSChanged:      DiffOSChanged,
+}
+
+// diffHost 比较同一主机的两次结果
+// 开放端口、服务和OS的变化与持续监控使用同一套判定(scanner.DiffHosts)；
+// 文件比较额外报告非开放状态之间的切换，以及旧结果中开放、新结果未扫描的端口。
+func diffHost(prev, cur *scanner.HostResult) []DiffEntry {
+	host := cur.Address()
+	var entries []DiffEntry
+	for _, change := range scanner.DiffHosts(prev, cur) {
+		entries = append(entries, DiffEntry{
+			Kind:     changeKinds[change.Type],
+			Host:     host,
+			Port:     change.Port,
+			Protocol: change.Protocol,
+			Old:      change.Previous,
+			New:      change.Current,
+		})
+	}
+
+	before := make(map[string]scanner.PortResult)
+	for _, p := range prev.Ports() {
+		before[fmt.Sprintf("%s/%d", p.Protocol, p.Port)] = p
+	}
+	for _, p := range cur.Ports() {
+		key := fmt.Sprintf("%s/%d", p.Protocol, p.Port)
+		old, scanned := before[key]
+		delete(before, key)
+		if scanned && old.State != p.State && old.State != scanner.PortStateOpen && p.State != scanner.PortStateOpen {
+			entries = append(entries, DiffEntry{Kind: DiffPortState, Host: host, Port: p.Port, Protocol: p.Protocol, Old: string(old.State), New: string(p.State)})
+		}
+	}
+	for _, old := range before {
+		if old.State == scanner.PortStateOpen {
+			entries = append(entries, DiffEntry{Kind: DiffPortClosed, Host: host, Port: old.Port, Protocol: old.Protocol, Old: string(old.State), New: notScanned})
+		}
+	}
+	return entries
+}
+
+// openPortList 返回主机开放端口的简要列表
+func openPortList(host *scanner.HostResult) string {
+	var ports []string
+	for _, p := range host.OpenPorts() {
+		ports = append(ports, fmt.Sprintf("%d/%s", p.Port, p.Protocol))
+	}
+	if len(ports) == 0 {
+		return "无"
+	}
+	return strings.Join(ports, ",")
+}
+
+// LoadScanFile 读取扫描结果文件，返回其中的主机结果
+// 支持scan命令输出的PortScanOutput JSON/XML和ScanReport JSON/XML，格式根据内容自动识别。
+func LoadScanFile(path string) ([]*scanner.HostResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取结果文件失败: %v", err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("结果文件 %s 为空", path)
+	}
+
+	var hosts []*scanner.HostResult
+	switch data[0] {
+	case '{':
+		hosts, err = parseJSONScan(data)
+	case '<':
+		hosts, err = parseXMLScan(data)
+	default:
+		err = fmt.Errorf("无法识别的文件格式，只支持JSON和XML")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析结果文件 %s 失败: %v", path, err)
+	}
+	return hosts, nil
+}
+
+// parseJSONScan 解析JSON格式的扫描结果
+func parseJSONScan(data []byte) ([]*scanner.HostResult, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	if _, ok := fields["summary"]; ok {
+		var result scanner.PortScanOutput
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		return []*scanner.HostResult{hostFromPortScanOutput(&result)}, nil
+	}
+
+	_, hasHosts := fields["hosts"]
+	_, hasResults := fields["results"]
+	if !hasHosts && !hasResults {
+		return nil, fmt.Errorf("不是go-port-rocket的扫描结果")
+	}
+	var report ScanReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return hostsFromReport(&report), nil
+}
+
+// parseXMLScan 解析XML格式的扫描结果
+func parseXMLScan(data []byte) ([]*scanner.HostResult, error) {
+	var root struct {
+		XMLName    xml.Name
+		Output     *scanner.PortScanOutput `xml:"PortScanOutput"`
+		Target     string                  `xml:"target"`
+		ScanType   string                  `xml:"scan_type"`
+		StartTime  time.Time               `xml:"start_time"`
+		EndTime    time.Time               `xml:"end_time"`
+		Hosts      []*scanner.HostResult   `xml:"hosts>host"`
+		Statistics *Statistics             `xml:"statistics"`
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	switch root.XMLName.Local {
+	case "scan_result":
+		if root.Output == nil {
+			return nil, fmt.Errorf("缺少PortScanOutput元素")
+		}
+		return []*scanner.HostResult{hostFromPortScanOutput(root.Output)}, nil
+	case "ScanResult":
+		return hostsFromReport(&ScanReport{Target: root.Target, ScanType: root.ScanType, StartTime: root.StartTime, EndTime: root.EndTime, Hosts: root.Hosts}), nil
+	default:
+		return nil, fmt.Errorf("不是go-port-rocket的扫描结果")
+	}
+}
+
+// hostFromPortScanOutput 从PortScanOutput取出主机结果，没有主机结构的旧版结果从端口列表重建
+func hostFromPortScanOutput(result *scanner.PortScanOutput) *scanner.HostResult {
+	if result.Host != nil && (len(result.Host.Addresses) > 0 || len(result.Host.Hostnames) > 0) {
+		return result.Host
+	}
+
+	host := scanner.NewHostResult(result.Summary.Target)
+	services := make(map[int]scanner.ServiceInfo)
+	for _, service := range result.ServiceVersions {
+		services[service.Port] = service
+	}
+	var values []scanner.ScanResult
+	for _, list := range [][]scanner.PortInfo{result.OpenPorts, result.ClosedPorts, result.FilteredPorts} {
+		for _, p := range list {
+			r := scanner.ScanResult{
+				Port:        p.Port,
+				State:       scanner.PortState(p.State),
+				ServiceName: p.ServiceName,
+				Type:        scanTypeForProtocol(p.Protocol),
+			}
+			if service, ok := services[p.Port]; ok && r.State == scanner.PortStateOpen {
+				r.ServiceName = service.Name
+				r.Version = service.Version
+			}
+			values = append(values, r)
+		}
+	}
+	host.AddResults(scanner.ScanTypeTCP, values)
+	for _, match := range result.OSDetection {
+		host.OS = append(host.OS, scanner.OSMatch{Name: match.Name, Family: match.Family, Version: match.Version, Accuracy: match.Confidence, CPE: match.CPE})
+	}
+	host.Complete(result.Summary.StartTime, result.Summary.EndTime)
+	return host
+}
+
+// hostsFromReport 从ScanReport取出主机结果，没有主机结构的旧版报告按目标合并逐端口结果
+func hostsFromReport(report *ScanReport) []*scanner.HostResult {
+	if len(report.Hosts) > 0 {
+		return report.Hosts
+	}
+	if len(report.Results) == 0 {
+		return nil
+	}
+	return []*scanner.HostResult{hostFromResults(&Options{
+		Target:    report.Target,
+		ScanType:  report.ScanType,
+		StartTime: report.StartTime,
+		EndTime:   report.EndTime,
+	}, report.Results)}
+}
+
+// scanTypeForProtocol 返回协议对应的默认扫描类型
+func scanTypeForProtocol(protocol string) scanner.ScanType {
+	if strings.EqualFold(protocol, scanner.ProtocolUDP) {
+		return scanner.ScanTypeUDP
+	}
+	return scanner.ScanTypeTCP
+}
+
+// WriteDiff 以text、json或html格式输出比较结果
+func WriteDiff(w io.Writer, diff *ScanDiff, format string) error {
+	switch strings.ToLower(format) {
+	case "text":
+		return writeDiffText(w, diff)
+	case "json":
+		entries := diff.Entries
+		if entries == nil {
+			entries = []DiffEntry{}
+		}
+		report := *diff
+		report.Entries = entries
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "html":
+		return writeDiffHTML(w, diff)
+	default:
+		return fmt.Errorf("不支持的输出格式: %s", format)
+	}
+}
+
+// writeDiffText 输出文本格式的比较结果
+func writeDiffText(w io.Writer, diff *ScanDiff) error {
+	fmt.Fprintf(w, "旧结果: %s (%d 台主机)\n", diff.Old, diff.OldHosts)
+	fmt.Fprintf(w, "新结果: %s (%d 台主机)\n", diff.New, diff.NewHosts)
+	if len(diff.Entries) == 0 {
+		_, err := fmt.Fprintln(w, "\n两次扫描结果没有差异")
+		return err
+	}
+
+	fmt.Fprintf(w, "\n发现 %d 项差异:\n", len(diff.Entries))
+	for _, entry := range diff.Entries {
+		fmt.Fprintf(w, "  %s\n", entry)
+	}
+
+	fmt.Fprintln(w, "\n统计:")
+	for _, kind := range DiffKinds {
+		if n := diff.Count(kind); n > 0 {
+			fmt.Fprintf(w, "  %-16s %d\n", kind, n)
+		}
+	}
+	return nil
+}
+
+// writeDiffHTML 输出HTML格式的比较结果
+func writeDiffHTML(w io.Writer, diff *ScanDiff) error {
+	const diffTemplate = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>扫描结果对比</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Arial, sans-serif; color: #212529; background: #f5f5f5; margin: 0; }
+        .container { max-width: 1200px; margin: 2rem auto; background: white; box-shadow: 0 0.5rem 1rem rgba(0, 0, 0, 0.15); border-radius: 0.25rem; overflow: hidden; }
+        header { background: linear-gradient(135deg, #4a6cf7, #304ffe); color: white; padding: 1.5rem 2rem; }
+        main { padding: 2rem; }
+        table { width: 100%; border-collapse: collapse; margin-top: 1rem; }
+        th, td { border: 1px solid #dee2e6; padding: 0.5rem 0.75rem; text-align: left; }
+        th { background: #f8f9fa; }
+        .kind { font-family: monospace; font-weight: bold; }
+        .host-added, .port-opened { color: #dc3545; }
+        .host-removed, .port-closed { color: #28a745; }
+        .port-state, .service-changed, .os-changed { color: #b8860b; }
+        .empty { color: #6c757d; }
+    </style>
+</head>
+<body>
+<div class="container">
+    <header>
+        <h1>扫描结果对比</h1>
+        <p>旧结果: {{.Old}} ({{.OldHosts}} 台主机) &rarr; 新结果: {{.New}} ({{.NewHosts}} 台主机)</p>
+    </header>
+    <main>
+        {{if .Entries}}
+        <p>发现 {{len .Entries}} 项差异</p>
+        <table>
+            <tr><th>类型</th><th>主机</th><th>端口</th><th>旧</th><th>新</th></tr>
+            {{range .Entries}}
+            <tr>
+                <td class="kind {{.Kind}}">{{.Kind}}</td>
+                <td>{{.Host}}</td>
+                <td>{{if .Port}}{{.Port}}/{{.Protocol}}{{end}}</td>
+                <td>{{.Old}}</td>
+                <td>{{.New}}</td>
+            </tr>
+            {{end}}
+        </table>
+        {{else}}
+        <p class="empty">两次扫描结果没有差异</p>
+        {{end}}
+    </main>
+</div>
+</body>
+</html>
+`
+	tmpl, err := template.New("diff").Parse(diffTemplate)
+	if err != nil {
+		return fmt.Errorf("解析HTML模板失败: %v", err)
+	}
+	return tmpl.Execute(w, diff)
+}
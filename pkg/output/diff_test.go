@@ -0,0 +1,165 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffTestHost(address string, results ...scanner.ScanResult) *scanner.HostResult {
+	host := scanner.NewHostResult(address)
+	host.AddResults(scanner.ScanTypeTCP, results)
+	host.Complete(time.Now(), time.Now())
+	return host
+}
+
+func TestDiffHosts(t *testing.T) {
+	oldHosts := []*scanner.HostResult{
+		diffTestHost("192.0.2.1",
+			scanner.ScanResult{Port: 22, State: scanner.PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Product: "OpenSSH", Version: "8.9"}},
+			scanner.ScanResult{Port: 25, State: scanner.PortStateClosed},
+			scanner.ScanResult{Port: 80, State: scanner.PortStateOpen},
+			scanner.ScanResult{Port: 8080, State: scanner.PortStateOpen},
+		),
+		diffTestHost("192.0.2.2", scanner.ScanResult{Port: 443, State: scanner.PortStateOpen}),
+	}
+	newHosts := []*scanner.HostResult{
+		diffTestHost("192.0.2.1",
+			scanner.ScanResult{Port: 22, State: scanner.PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Product: "OpenSSH", Version: "9.6"}},
+			scanner.ScanResult{Port: 25, State: scanner.PortStateFiltered},
+			scanner.ScanResult{Port: 80, State: scanner.PortStateClosed},
+			scanner.ScanResult{Port: 3306, State: scanner.PortStateOpen},
+			scanner.ScanResult{Port: 9000, State: scanner.PortStateClosed}, // 只在新结果中出现的非开放端口不报告
+		),
+		diffTestHost("192.0.2.3", scanner.ScanResult{Port: 53, State: scanner.PortStateOpen}),
+	}
+
+	entries := DiffHosts(oldHosts, newHosts)
+	require.Len(t, entries, 7)
+	expected := []DiffEntry{
+		{Kind: DiffServiceChanged, Host: "192.0.2.1", Port: 22, Protocol: "tcp", Old: "ssh OpenSSH 8.9", New: "ssh OpenSSH 9.6"},
+		{Kind: DiffPortState, Host: "192.0.2.1", Port: 25, Protocol: "tcp", Old: "closed", New: "filtered"},
+		{Kind: DiffPortClosed, Host: "192.0.2.1", Port: 80, Protocol: "tcp", Old: "open", New: "closed"},
+		{Kind: DiffPortOpened, Host: "192.0.2.1", Port: 3306, Protocol: "tcp", Old: notScanned, New: "open"},
+		{Kind: DiffPortClosed, Host: "192.0.2.1", Port: 8080, Protocol: "tcp", Old: "open", New: notScanned},
+		{Kind: DiffHostRemoved, Host: "192.0.2.2", Old: "443/tcp"},
+		{Kind: DiffHostAdded, Host: "192.0.2.3", New: "53/tcp"},
+	}
+	assert.Equal(t, expected, entries)
+
+	diff := &ScanDiff{Entries: entries}
+	assert.Equal(t, 7, diff.Count())
+	assert.Equal(t, 3, diff.Count(DiffPortClosed, DiffHostAdded))
+
+	kinds, err := ParseDiffKinds("any")
+	require.NoError(t, err)
+	assert.Empty(t, kinds)
+	kinds, err = ParseDiffKinds("port-opened, service-changed")
+	require.NoError(t, err)
+	assert.Equal(t, []DiffKind{DiffPortOpened, DiffServiceChanged}, kinds)
+	_, err = ParseDiffKinds("port-moved")
+	assert.Error(t, err)
+}
+
+func TestDiffHostsMatchesMonitor(t *testing.T) {
+	https := func(sha256 string) *fingerprint.Service {
+		return &fingerprint.Service{Name: "https", TLS: &fingerprint.TLSInfo{
+			Certificates: []fingerprint.TLSCertificate{{SHA256: sha256}},
+		}}
+	}
+	prev := diffTestHost("192.0.2.1", scanner.ScanResult{Port: 443, State: scanner.PortStateOpen, Service: https("aaaaaaaaaaaaaaaaaaaa")})
+	cur := diffTestHost("192.0.2.1", scanner.ScanResult{Port: 443, State: scanner.PortStateOpen, Service: https("bbbbbbbbbbbbbbbbbbbb")})
+	// 低置信度的OS结果来回跳动不视为变化
+	prev.OS = []scanner.OSMatch{{Name: "Linux", Accuracy: 60}}
+	cur.OS = []scanner.OSMatch{{Name: "FreeBSD", Accuracy: 60}}
+
+	// 与持续监控报告相同的变化
+	entries := DiffHosts([]*scanner.HostResult{prev}, []*scanner.HostResult{cur})
+	changes := scanner.DiffHosts(prev, cur)
+	require.Len(t, changes, 1)
+	assert.Equal(t, []DiffEntry{
+		{Kind: DiffServiceChanged, Host: "192.0.2.1", Port: 443, Protocol: "tcp", Old: changes[0].Previous, New: changes[0].Current},
+	}, entries)
+	assert.Equal(t, "证书 aaaaaaaaaaaaaaaa", entries[0].Old)
+}
+
+func TestLoadScanFile(t *testing.T) {
+	dir := t.TempDir()
+	host := diffTestHost("192.0.2.1",
+		scanner.ScanResult{Port: 22, State: scanner.PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Version: "8.9"}},
+		scanner.ScanResult{Port: 25, State: scanner.PortStateClosed},
+	)
+
+	// scan命令输出的JSON和XML
+	for _, format := range []string{scanner.OutputFormatJSON, scanner.OutputFormatXML} {
+		path := filepath.Join(dir, "scan."+format)
+		require.NoError(t, scanner.SaveScanResult(scanner.NewPortScanOutput(host), &scanner.OutputOptions{Format: format, OutputFile: path}))
+		hosts, err := LoadScanFile(path)
+		require.NoError(t, err, format)
+		require.Len(t, hosts, 1)
+		assert.Equal(t, "192.0.2.1", hosts[0].Address())
+		assert.Empty(t, DiffHosts([]*scanner.HostResult{host}, hosts), format)
+	}
+
+	// 没有主机结构的旧版PortScanOutput从端口列表重建
+	legacy := scanner.NewPortScanOutput(host)
+	legacy.Host = nil
+	data, err := json.Marshal(legacy)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "legacy.json")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	hosts, err := LoadScanFile(path)
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	assert.Len(t, hosts[0].TCP, 2)
+	assert.Equal(t, "8.9", hosts[0].TCP[0].Service.Version)
+
+	// API使用的扫描报告JSON和XML
+	for _, format := range []string{"json", "xml"} {
+		var buf bytes.Buffer
+		out, err := NewOutput(&Options{Format: format, Writer: &buf, Target: "192.0.2.0/30"})
+		require.NoError(t, err)
+		require.NoError(t, out.WriteHosts([]*scanner.HostResult{host, diffTestHost("192.0.2.2")}))
+		path := filepath.Join(dir, "report."+format)
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+		hosts, err := LoadScanFile(path)
+		require.NoError(t, err, format)
+		require.Len(t, hosts, 2, format)
+		assert.Equal(t, "192.0.2.2", hosts[1].Address())
+		assert.Equal(t, scanner.PortStateOpen, hosts[0].TCP[0].State)
+	}
+
+	path = filepath.Join(dir, "other.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"name":"x"}`), 0644))
+	_, err = LoadScanFile(path)
+	assert.Error(t, err)
+}
+
+func TestWriteDiff(t *testing.T) {
+	diff := &ScanDiff{Old: "old.json", New: "new.json", OldHosts: 1, NewHosts: 1, Entries: []DiffEntry{
+		{Kind: DiffPortOpened, Host: "192.0.2.1", Port: 3306, Protocol: "tcp", Old: "closed", New: "open"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDiff(&buf, diff, "text"))
+	assert.Contains(t, buf.String(), "[port-opened] 192.0.2.1 3306/tcp: closed -> open")
+
+	buf.Reset()
+	require.NoError(t, WriteDiff(&buf, diff, "html"))
+	assert.Contains(t, buf.String(), "<td>3306/tcp</td>")
+
+	buf.Reset()
+	require.NoError(t, WriteDiff(&buf, &ScanDiff{}, "json"))
+	assert.Contains(t, buf.String(), `"entries": []`)
+
+	assert.Error(t, WriteDiff(&buf, diff, "csv"))
+}
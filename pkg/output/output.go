@@ -11,12 +11,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
 	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
 )
 
 // Output 输出接口
 type Output interface {
+	// Write 写入单个目标的逐端口结果，结果按Options中的目标合并为一个主机
 	Write(results []*scanner.ScanResult) error
+	// WriteHosts 写入以主机为中心的扫描结果
+	WriteHosts(hosts []*scanner.HostResult) error
 }
 
 // TextOutput 文本输出
@@ -70,7 +74,12 @@ func NewOutput(opts *Options) (Output, error) {
 
 // Write 写入文本输出
 func (o *TextOutput) Write(results []*scanner.ScanResult) error {
-	stats := calculateStatistics(results, o.opts.Duration)
+	return o.WriteHosts([]*scanner.HostResult{hostFromResults(o.opts, results)})
+}
+
+// WriteHosts 写入主机结果的文本输出
+func (o *TextOutput) WriteHosts(hosts []*scanner.HostResult) error {
+	stats := calculateStatistics(flattenHosts(hosts), o.opts.Duration)
 
 	// 写入扫描标题和信息
 	fmt.Fprintf(o.opts.Writer, "\n%s\n", ColorizeHeader("╭─────────────────────────────────────────────────────╮"))
@@ -89,6 +98,50 @@ func (o *TextOutput) Write(results []*scanner.ScanResult) error {
 	fmt.Fprintf(o.opts.Writer, "%s\n", ColorizeHeader("│                    端口扫描结果                     │"))
 	fmt.Fprintf(o.opts.Writer, "%s\n", ColorizeHeader("╰─────────────────────────────────────────────────────╯"))
 
+	for _, host := range hosts {
+		o.writeHost(host)
+	}
+
+	// 写入统计信息
+	fmt.Fprintf(o.opts.Writer, "%s\n", ColorizeHeader("╭─────────────────────────────────────────────────────╮"))
+	fmt.Fprintf(o.opts.Writer, "%s\n", ColorizeHeader("│                    扫描统计信息                     │"))
+	fmt.Fprintf(o.opts.Writer, "%s\n\n", ColorizeHeader("╰─────────────────────────────────────────────────────╯"))
+
+	fmt.Fprintf(o.opts.Writer, "%s %s\n", ColorizeTitle("●  总端口数:"), ColorizeNumber(fmt.Sprintf("%d", stats.TotalPorts)))
+	fmt.Fprintf(o.opts.Writer, "%s %s\n", ColorizeTitle("●  开放端口:"), ColorizeNumber(fmt.Sprintf("%d", stats.OpenPorts)))
+	fmt.Fprintf(o.opts.Writer, "%s %s\n", ColorizeTitle("●  关闭端口:"), ColorizeNumber(fmt.Sprintf("%d", stats.ClosedPorts)))
+	fmt.Fprintf(o.opts.Writer, "%s %s\n\n", ColorizeTitle("●  过滤端口:"), ColorizeNumber(fmt.Sprintf("%d", stats.FilteredPorts)))
+
+	return nil
+}
+
+// writeHost 写入单个主机的信息和端口结果
+func (o *TextOutput) writeHost(host *scanner.HostResult) {
+	results := host.ScanResults()
+	ports := host.Ports()
+
+	fmt.Fprintf(o.opts.Writer, "\n%s %s", ColorizeTitle("●  主机:"), ColorizeHighlight(host.Name()))
+	if addr := host.Address(); addr != host.Name() {
+		fmt.Fprintf(o.opts.Writer, " (%s)", addr)
+	}
+	fmt.Fprintf(o.opts.Writer, "  %s %s", ColorizeTitle("状态:"), ColorizeInfo(string(host.Status)))
+	if host.Reason != "" {
+		fmt.Fprintf(o.opts.Writer, " (%s)", host.Reason)
+	}
+	if host.MAC != "" {
+		fmt.Fprintf(o.opts.Writer, "  %s %s", ColorizeTitle("MAC:"), host.MAC)
+	}
+	fmt.Fprintln(o.opts.Writer)
+
+	for _, match := range host.OS {
+		fmt.Fprintf(o.opts.Writer, "%s %s %s\n", ColorizeTitle("●  操作系统:"),
+			ColorizeInfo(strings.TrimSpace(match.Name+" "+match.Version)),
+			ColorizeNumber(fmt.Sprintf("(%.0f%%)", match.Accuracy)))
+		if len(match.CPE) > 0 {
+			fmt.Fprintf(o.opts.Writer, "   %s %s\n", ColorizeTitle("CPE:"), strings.Join(match.CPE, " "))
+		}
+	}
+
 	if len(results) == 0 {
 		fmt.Fprintf(o.opts.Writer, "\n%s\n\n", ColorizeWarning("未发现开放端口"))
 	} else {
@@ -100,7 +153,7 @@ func (o *TextOutput) Write(results []*scanner.ScanResult) error {
 			ColorizeTitle("操作系统"))
 		fmt.Fprintf(o.opts.Writer, "%s\n", strings.Repeat("─", 70))
 
-		for _, result := range results {
+		for i, result := range results {
 			// 根据端口状态设置不同颜色
 			var portStatus string
 			switch result.State {
@@ -115,7 +168,7 @@ func (o *TextOutput) Write(results []*scanner.ScanResult) error {
 			}
 
 			// 端口和协议
-			portInfo := fmt.Sprintf("%d/%s", result.Port, o.opts.ScanType)
+			portInfo := fmt.Sprintf("%d/%s", result.Port, ports[i].Protocol)
 
 			// 服务信息
 			serviceInfo := ""
@@ -197,26 +250,69 @@ func (o *TextOutput) Write(results []*scanner.ScanResult) error {
 					fmt.Fprintf(o.opts.Writer, "    %s\n", strings.Repeat("─", 70))
 				}
 			}
+
+			// 未识别服务的指纹记录，可保存后用 fingerprint learn 生成规则
+			if result.Service != nil && result.Service.Fingerprint != "" {
+				fmt.Fprintf(o.opts.Writer, "  %s\n", ColorizeTitle("● 未识别服务指纹:"))
+				for _, line := range strings.Split(result.Service.Fingerprint, "\n") {
+					fmt.Fprintf(o.opts.Writer, "    %s\n", line)
+				}
+			}
+
+			if result.Service != nil && result.Service.TLS != nil {
+				o.writeTLS(result.Service.TLS)
+			}
 		}
 		fmt.Fprintln(o.opts.Writer, "")
 	}
+}
 
-	// 写入统计信息
-	fmt.Fprintf(o.opts.Writer, "%s\n", ColorizeHeader("╭─────────────────────────────────────────────────────╮"))
-	fmt.Fprintf(o.opts.Writer, "%s\n", ColorizeHeader("│                    扫描统计信息                     │"))
-	fmt.Fprintf(o.opts.Writer, "%s\n\n", ColorizeHeader("╰─────────────────────────────────────────────────────╯"))
-
-	fmt.Fprintf(o.opts.Writer, "%s %s\n", ColorizeTitle("●  总端口数:"), ColorizeNumber(fmt.Sprintf("%d", stats.TotalPorts)))
-	fmt.Fprintf(o.opts.Writer, "%s %s\n", ColorizeTitle("●  开放端口:"), ColorizeNumber(fmt.Sprintf("%d", stats.OpenPorts)))
-	fmt.Fprintf(o.opts.Writer, "%s %s\n", ColorizeTitle("●  关闭端口:"), ColorizeNumber(fmt.Sprintf("%d", stats.ClosedPorts)))
-	fmt.Fprintf(o.opts.Writer, "%s %s\n\n", ColorizeTitle("●  过滤端口:"), ColorizeNumber(fmt.Sprintf("%d", stats.FilteredPorts)))
-
-	return nil
+// writeTLS 输出TLS检查结果：协议版本和密码套件、证书链、JARM指纹
+func (o *TextOutput) writeTLS(info *fingerprint.TLSInfo) {
+	w := o.opts.Writer
+	fmt.Fprintf(w, "  %s\n", ColorizeTitle("● TLS 信息:"))
+	fmt.Fprintf(w, "    %s %s\n", ColorizeTitle("协议版本:"), strings.Join(info.VersionNames(), " "))
+	for _, version := range info.Versions {
+		fmt.Fprintf(w, "    %s\n", ColorizeInfo(version.Name))
+		for _, cipher := range version.Ciphers {
+			fmt.Fprintf(w, "      %s\n", cipher)
+		}
+	}
+	for i, cert := range info.Certificates {
+		label := "证书:"
+		if i > 0 {
+			label = "证书链:"
+		}
+		fmt.Fprintf(w, "    %s %s\n", ColorizeTitle(label), cert.Subject)
+		if len(cert.SANs) > 0 {
+			fmt.Fprintf(w, "      SAN: %s\n", strings.Join(cert.SANs, ", "))
+		}
+		fmt.Fprintf(w, "      颁发者: %s\n", cert.Issuer)
+		fmt.Fprintf(w, "      密钥: %s %d  签名: %s\n", cert.KeyType, cert.KeyBits, cert.SignatureAlgorithm)
+		expiry := cert.NotAfter.Format("2006-01-02")
+		if cert.Expired {
+			expiry = ColorizeError(expiry + " (已过期)")
+		}
+		fmt.Fprintf(w, "      有效期: %s 至 %s\n", cert.NotBefore.Format("2006-01-02"), expiry)
+	}
+	if len(info.Certificates) > 0 {
+		if info.Trusted {
+			fmt.Fprintf(w, "    %s %s\n", ColorizeTitle("证书验证:"), ColorizeSuccess("受信任"))
+		} else {
+			fmt.Fprintf(w, "    %s %s\n", ColorizeTitle("证书验证:"), ColorizeWarning(info.VerifyError))
+		}
+	}
+	fmt.Fprintf(w, "    %s %s\n", ColorizeTitle("JARM:"), info.JARM)
 }
 
 // Write 写入JSON输出
 func (o *JSONOutput) Write(results []*scanner.ScanResult) error {
-	report := NewScanReport(o.opts, results)
+	return o.WriteHosts([]*scanner.HostResult{hostFromResults(o.opts, results)})
+}
+
+// WriteHosts 写入主机结果的JSON输出
+func (o *JSONOutput) WriteHosts(hosts []*scanner.HostResult) error {
+	report := NewHostReport(o.opts, hosts)
 
 	encoder := json.NewEncoder(o.opts.Writer)
 	if o.opts.Pretty {
@@ -227,6 +323,11 @@ func (o *JSONOutput) Write(results []*scanner.ScanResult) error {
 
 // Write 写入XML输出
 func (o *XMLOutput) Write(results []*scanner.ScanResult) error {
+	return o.WriteHosts([]*scanner.HostResult{hostFromResults(o.opts, results)})
+}
+
+// WriteHosts 写入主机结果的XML输出
+func (o *XMLOutput) WriteHosts(hosts []*scanner.HostResult) error {
 	// 创建带XML标签的报告结构
 	type XMLScanReport struct {
 		XMLName    xml.Name              `xml:"ScanResult"`
@@ -235,18 +336,18 @@ func (o *XMLOutput) Write(results []*scanner.ScanResult) error {
 		StartTime  time.Time             `xml:"start_time"`
 		EndTime    time.Time             `xml:"end_time"`
 		Duration   float64               `xml:"duration"`
-		Results    []*scanner.ScanResult `xml:"ports>port"`
+		Hosts      []*scanner.HostResult `xml:"hosts>host"`
 		Statistics *Statistics           `xml:"statistics"`
 	}
 
-	report := NewScanReport(o.opts, results)
+	report := NewHostReport(o.opts, hosts)
 	xmlReport := XMLScanReport{
 		Target:     report.Target,
 		ScanType:   report.ScanType,
 		StartTime:  report.StartTime,
 		EndTime:    report.EndTime,
 		Duration:   report.Duration,
-		Results:    report.Results,
+		Hosts:      report.Hosts,
 		Statistics: report.Statistics,
 	}
 
@@ -260,6 +361,11 @@ func (o *XMLOutput) Write(results []*scanner.ScanResult) error {
 
 // Write 写入HTML输出
 func (o *HTMLOutput) Write(results []*scanner.ScanResult) error {
+	return o.WriteHosts([]*scanner.HostResult{hostFromResults(o.opts, results)})
+}
+
+// WriteHosts 写入主机结果的HTML输出
+func (o *HTMLOutput) WriteHosts(hosts []*scanner.HostResult) error {
 	const htmlTemplate = `
 <!DOCTYPE html>
 <html>
@@ -665,7 +771,41 @@ func (o *HTMLOutput) Write(results []*scanner.ScanResult) error {
                     </div>
                 </div>
             </div>
-            
+
+            <!-- 主机信息 -->
+            <div class="section">
+                <div class="section-header collapsible">
+                    <span>主机信息</span>
+                    <span class="collapse-icon">▼</span>
+                </div>
+                <div class="section-body collapsible-content">
+                    {{range .Hosts}}
+                    <div class="info-grid">
+                        <div class="info-item">
+                            <div class="info-label">主机:</div>
+                            <div class="info-value">{{.Name}}{{if ne .Address .Name}} ({{.Address}}){{end}}</div>
+                        </div>
+                        <div class="info-item">
+                            <div class="info-label">状态:</div>
+                            <div class="info-value">{{.Status}}{{if .Reason}} ({{.Reason}}){{end}}</div>
+                        </div>
+                        {{if .MAC}}
+                        <div class="info-item">
+                            <div class="info-label">MAC地址:</div>
+                            <div class="info-value">{{.MAC}}</div>
+                        </div>
+                        {{end}}
+                        {{range .OS}}
+                        <div class="info-item">
+                            <div class="info-label">操作系统:</div>
+                            <div class="info-value">{{.Name}}{{if .Version}} {{.Version}}{{end}} - 置信度: {{printf "%.1f" .Accuracy}}%{{range .CPE}}<br>{{.}}{{end}}</div>
+                        </div>
+                        {{end}}
+                    </div>
+                    {{end}}
+                </div>
+            </div>
+
             <!-- 统计信息 -->
             <div class="section">
                 <div class="section-header collapsible">
@@ -747,7 +887,7 @@ func (o *HTMLOutput) Write(results []*scanner.ScanResult) error {
                             {{range $index, $result := .Results}}
                             <tr class="port-row" data-state="{{$result.State}}">
                                 <td>{{$result.Port}}</td>
-                                <td>{{$.ScanType}}</td>
+                                <td>{{protocol $result.Type}}</td>
                                 <td>
                                     {{if eq $result.State "open"}}
                                     <span class="badge badge-open">开放</span>
@@ -792,6 +932,36 @@ func (o *HTMLOutput) Write(results []*scanner.ScanResult) error {
                                             <div class="info-value">{{$result.Service.Product}}</div>
                                         </div>
                                         {{end}}
+                                        {{with $result.Service.TLS}}
+                                        <div class="info-item">
+                                            <div class="info-label">TLS 协议:</div>
+                                            <div class="info-value">
+                                                {{range .Versions}}<div><strong>{{.Name}}</strong>: {{join .Ciphers ", "}}</div>{{end}}
+                                            </div>
+                                        </div>
+                                        {{range $i, $cert := .Certificates}}
+                                        <div class="info-item">
+                                            <div class="info-label">{{if eq $i 0}}证书:{{else}}证书链:{{end}}</div>
+                                            <div class="info-value">
+                                                {{$cert.Subject}}
+                                                {{if $cert.SANs}}<div>SAN: {{join $cert.SANs ", "}}</div>{{end}}
+                                                <div>颁发者: {{$cert.Issuer}}</div>
+                                                <div>密钥: {{$cert.KeyType}} {{$cert.KeyBits}} - 签名: {{$cert.SignatureAlgorithm}}</div>
+                                                <div>有效期: {{$cert.NotBefore.Format "2006-01-02"}} 至 {{$cert.NotAfter.Format "2006-01-02"}}{{if $cert.Expired}} (已过期){{end}}</div>
+                                            </div>
+                                        </div>
+                                        {{end}}
+                                        {{if .Certificates}}
+                                        <div class="info-item">
+                                            <div class="info-label">证书验证:</div>
+                                            <div class="info-value">{{if .Trusted}}受信任{{else}}{{.VerifyError}}{{end}}</div>
+                                        </div>
+                                        {{end}}
+                                        <div class="info-item">
+                                            <div class="info-label">JARM:</div>
+                                            <div class="info-value">{{.JARM}}</div>
+                                        </div>
+                                        {{end}}
                                         {{end}}
                                         
                                         {{if $result.OS}}
@@ -964,6 +1134,9 @@ func (o *HTMLOutput) Write(results []*scanner.ScanResult) error {
 		"inc": func(i int) int {
 			return i + 1
 		},
+		"protocol": func(scanType scanner.ScanType) string {
+			return scanner.ScanTypeProtocol(scanType)
+		},
 		"splitBanner": func(s string) []string {
 			// 处理空字符串
 			if s == "" {
@@ -987,6 +1160,7 @@ func (o *HTMLOutput) Write(results []*scanner.ScanResult) error {
 			}
 			return result
 		},
+		"join": strings.Join,
 		"highlightHTML": func(line string) template.HTML {
 			// 定义高亮规则
 			patterns := []struct {
@@ -1016,7 +1190,7 @@ func (o *HTMLOutput) Write(results []*scanner.ScanResult) error {
 		},
 	}
 
-	report := NewScanReport(o.opts, results)
+	report := NewHostReport(o.opts, hosts)
 
 	tmpl, err := template.New("report").Funcs(funcMap).Parse(htmlTemplate)
 	if err != nil {
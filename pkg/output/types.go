@@ -54,23 +54,57 @@ type ScanReport struct {
 	StartTime  time.Time             `json:"start_time" xml:"start_time"`
 	EndTime    time.Time             `json:"end_time" xml:"end_time"`
 	Duration   float64               `json:"duration" xml:"duration"`
+	Hosts      []*scanner.HostResult `json:"hosts" xml:"hosts"`
 	Results    []*scanner.ScanResult `json:"results" xml:"results"`
 	Statistics *Statistics           `json:"statistics" xml:"statistics"`
 }
 
-// NewScanReport 创建新的扫描报告
+// NewScanReport 创建新的扫描报告，逐端口结果按Options中的目标合并为一个主机
 func NewScanReport(opts *Options, results []*scanner.ScanResult) *ScanReport {
+	return NewHostReport(opts, []*scanner.HostResult{hostFromResults(opts, results)})
+}
+
+// NewHostReport 从主机结果创建扫描报告，Results为所有主机端口结果的展开
+func NewHostReport(opts *Options, hosts []*scanner.HostResult) *ScanReport {
+	results := flattenHosts(hosts)
 	return &ScanReport{
 		Target:     opts.Target,
 		ScanType:   opts.ScanType,
 		StartTime:  opts.StartTime,
 		EndTime:    opts.EndTime,
 		Duration:   opts.Duration.Seconds(),
+		Hosts:      hosts,
 		Results:    results,
 		Statistics: calculateStatistics(results, opts.Duration),
 	}
 }
 
+// hostFromResults 将单个目标的逐端口结果合并为主机结果
+func hostFromResults(opts *Options, results []*scanner.ScanResult) *scanner.HostResult {
+	values := make([]scanner.ScanResult, 0, len(results))
+	for _, r := range results {
+		if r != nil {
+			values = append(values, *r)
+		}
+	}
+	host := scanner.NewHostResult(opts.Target)
+	host.AddResults(scanner.ScanType(opts.ScanType), values)
+	host.Complete(opts.StartTime, opts.EndTime)
+	return host
+}
+
+// flattenHosts 展开所有主机的端口结果
+func flattenHosts(hosts []*scanner.HostResult) []*scanner.ScanResult {
+	var results []*scanner.ScanResult
+	for _, host := range hosts {
+		values := host.ScanResults()
+		for i := range values {
+			results = append(results, &values[i])
+		}
+	}
+	return results
+}
+
 // calculateStatistics 计算扫描统计信息
 func calculateStatistics(results []*scanner.ScanResult, duration time.Duration) *Statistics {
 	stats := &Statistics{
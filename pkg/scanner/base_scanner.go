@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
-	"github.com/cyberspacesec/go-port-rocket/pkg/metrics"
 )
 
 // BaseScanner 基础扫描器接口
@@ -28,6 +27,9 @@ type baseScanner struct {
 	stats    *ScanStats
 	mu       sync.Mutex
 	opts     *ScanOptions
+	events   *eventBus
+	// scanFunc 具体扫描器提供的单端口扫描实现，嵌入结构体无法动态分派scanPort
+	scanFunc func(ctx context.Context, port int) (ScanResult, error)
 }
 
 // newBaseScanner 创建新的基础扫描器
@@ -56,6 +58,13 @@ func (s *baseScanner) Scan(ctx context.Context, opts *ScanOptions) ([]ScanResult
 	s.stats = NewScanStats()
 	s.stats.TotalPorts = len(ports)
 
+	s.events = newEventBus(opts.Target, s.scanType, opts.Observers)
+	s.events.started(len(ports))
+	defer s.events.finished()
+
+	// 主机级放弃策略：单主机超时与tarpit检测
+	hostCtx, guard := newHostGuard(ctx, opts)
+
 	// 创建工作池
 	jobs := make(chan int, len(ports))
 	results := make(chan ScanResult, len(ports))
@@ -69,14 +78,21 @@ func (s *baseScanner) Scan(ctx context.Context, opts *ScanOptions) ([]ScanResult
 			defer wg.Done()
 			for port := range jobs {
 				select {
-				case <-ctx.Done():
+				case <-hostCtx.Done():
 					return
 				default:
-					result, err := s.scanPort(ctx, port)
+					result, err := retryPort(hostCtx, opts.MaxRetries, func() (ScanResult, error) {
+						return s.scanOne(hostCtx, port)
+					})
 					if err != nil {
 						errors <- ScanError{Port: port, Error: err}
 						continue
 					}
+					// 主机被放弃时中断的探测无法判定端口状态，不计入结果
+					if hostCtx.Err() != nil && result.State == PortStateFiltered {
+						return
+					}
+					guard.observe(result)
 					results <- result
 				}
 			}
@@ -85,14 +101,14 @@ func (s *baseScanner) Scan(ctx context.Context, opts *ScanOptions) ([]ScanResult
 
 	// 发送任务
 	go func() {
+		defer close(jobs)
 		for _, port := range ports {
 			select {
-			case <-ctx.Done():
+			case <-hostCtx.Done():
 				return
 			case jobs <- port:
 			}
 		}
-		close(jobs)
 	}()
 
 	// 等待所有工作完成
@@ -104,26 +120,53 @@ func (s *baseScanner) Scan(ctx context.Context, opts *ScanOptions) ([]ScanResult
 
 	// 收集结果
 	var scanResults []ScanResult
+	completed := 0
 	for {
 		select {
 		case <-ctx.Done():
 			return scanResults, ctx.Err()
 		case result, ok := <-results:
 			if !ok {
+				s.finishHost(hostCtx, guard, scanResults)
 				return scanResults, nil
 			}
 			scanResults = append(scanResults, result)
 			s.updateStats(result)
+			s.events.portResult(result)
+			completed++
+			s.events.progress(completed, len(ports))
 		case err, ok := <-errors:
 			if !ok {
 				continue
 			}
 			s.stats.Errors++
-			fmt.Printf("⚠️  扫描端口 %d 时发生错误: %v\n", err.Port, err.Error)
+			s.events.failed(err.Port, err.Error)
+			completed++
+			s.events.progress(completed, len(ports))
 		}
 	}
 }
 
+// finishHost 结束主机扫描，被放弃的主机在结果中标记原因
+func (s *baseScanner) finishHost(hostCtx context.Context, guard *hostGuard, results []ScanResult) {
+	giveUp := guard.finish(hostCtx)
+	if giveUp == nil {
+		return
+	}
+	for i := range results {
+		markAbandoned(&results[i], giveUp)
+	}
+	s.events.abandoned(giveUp)
+}
+
+// scanOne 调用具体扫描器的单端口扫描实现
+func (s *baseScanner) scanOne(ctx context.Context, port int) (ScanResult, error) {
+	if s.scanFunc != nil {
+		return s.scanFunc(ctx, port)
+	}
+	return s.scanPort(ctx, port)
+}
+
 // scanPort 扫描单个端口
 func (s *baseScanner) scanPort(ctx context.Context, port int) (ScanResult, error) {
 	// 实现具体的端口扫描逻辑
@@ -144,17 +187,6 @@ func (s *baseScanner) updateStats(result ScanResult) {
 	case PortStateFiltered:
 		s.stats.FilteredPorts++
 	}
-
-	// 更新指标
-	metrics.IncrementPortsScanned(s.opts.Target, string(s.scanType))
-	switch result.State {
-	case PortStateOpen:
-		metrics.SetOpenPorts(s.opts.Target, string(s.scanType), float64(s.stats.OpenPorts))
-	case PortStateClosed:
-		metrics.SetClosedPorts(s.opts.Target, string(s.scanType), float64(s.stats.ClosedPorts))
-	case PortStateFiltered:
-		metrics.SetFilteredPorts(s.opts.Target, string(s.scanType), float64(s.stats.FilteredPorts))
-	}
 }
 
 // ValidateOptions 验证扫描选项
@@ -178,13 +210,7 @@ func (s *baseScanner) ValidateOptions(opts *ScanOptions) error {
 
 	// 设置默认超时时间（仅当用户未设置时）
 	if opts.Timeout <= 0 {
-		if portCount > 10000 {
-			opts.Timeout = time.Second * 2 // 大规模扫描使用较短超时
-		} else if portCount > 1000 {
-			opts.Timeout = time.Second * 3
-		} else {
-			opts.Timeout = time.Second * 5
-		}
+		opts.Timeout = defaultTimeout(portCount)
 	}
 
 	// 智能调整工作线程数
@@ -228,6 +254,16 @@ func (s *baseScanner) GetStats() *ScanStats {
 	return s.stats
 }
 
+// defaultTimeout 根据端口数量确定默认超时时间
+func defaultTimeout(portCount int) time.Duration {
+	if portCount > 10000 {
+		return time.Second * 2 // 大规模扫描使用较短超时
+	} else if portCount > 1000 {
+		return time.Second * 3
+	}
+	return time.Second * 5
+}
+
 // calculateOptimalWorkers 根据端口数量计算最优工作线程数
 func calculateOptimalWorkers(portCount int) int {
 	cpuCount := runtime.NumCPU()
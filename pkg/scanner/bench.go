@@ -0,0 +1,459 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// benchMinAccuracy 生成机器画像时要求的最低准确率
+const benchMinAccuracy = 0.99
+
+// benchSampleInterval 资源占用的采样间隔
+const benchSampleInterval = 20 * time.Millisecond
+
+// benchBanner 本地开放端口发送的banner
+const benchBanner = "SSH-2.0-go-port-rocket-bench\r\n"
+
+// BenchOptions 扫描器基准测试选项
+type BenchOptions struct {
+	Engines      []ScanType    // 参与测试的扫描引擎
+	Workers      []int         // 测试的并发数
+	RateLimits   []int         // 测试的速率限制(每秒探测数)，0表示不限速
+	OpenPorts    int           // 每种协议的开放端口数
+	ClosedPorts  int           // 每种协议的关闭端口数
+	DelayedPorts int           // 每种协议延迟应答的端口数
+	Delay        time.Duration // 延迟端口的应答延迟
+	Timeout      time.Duration // 单端口超时
+}
+
+// DefaultBenchOptions 返回默认的基准测试选项
+func DefaultBenchOptions() *BenchOptions {
+	return &BenchOptions{
+		Engines:      []ScanType{ScanTypeTCP, ScanTypeUDP, ScanTypeSYN},
+		Workers:      []int{10, 50, 100, 200},
+		RateLimits:   []int{0, 1000},
+		OpenPorts:    50,
+		ClosedPorts:  200,
+		DelayedPorts: 10,
+		Delay:        200 * time.Millisecond,
+		Timeout:      time.Second,
+	}
+}
+
+// BenchResult 一组引擎、并发数与速率限制的测试结果
+type BenchResult struct {
+	Engine       ScanType      `json:"engine"`               // 扫描引擎
+	Workers      int           `json:"workers"`              // 并发数
+	RateLimit    int           `json:"rate_limit"`           // 速率限制，0表示不限速
+	Ports        int           `json:"ports"`                // 扫描端口数
+	Duration     time.Duration `json:"duration"`             // 耗时
+	PortsPerSec  float64       `json:"ports_per_sec"`        // 吞吐量
+	CPUPercent   float64       `json:"cpu_percent"`          // 进程CPU占用(单核百分比，包含本地监听器)
+	PeakMemoryMB float64       `json:"peak_memory_mb"`       // 堆内存峰值
+	PeakFDs      int           `json:"peak_fds"`             // 打开文件描述符峰值，-1表示无法获取
+	Accuracy     float64       `json:"accuracy"`             // 端口状态与预期一致的比例
+	Error        string        `json:"error,omitempty"`      // 测试失败原因
+	Mismatched   []int         `json:"mismatched,omitempty"` // 状态与预期不一致的端口
+}
+
+// BenchReport 基准测试报告
+type BenchReport struct {
+	StartTime time.Time           `json:"start_time"`        // 开始时间
+	CPUs      int                 `json:"cpus"`              // CPU核心数
+	FDLimit   int                 `json:"fd_limit"`          // 文件描述符限制
+	Results   []BenchResult       `json:"results"`           // 各组测试结果
+	Skipped   map[ScanType]string `json:"skipped,omitempty"` // 未能测试的引擎
+}
+
+// RunBenchmark 在本机启动开放、关闭和延迟应答的TCP/UDP监听端口，
+// 以不同并发数与速率限制运行各扫描引擎，测量吞吐、资源占用和准确率
+// 需要root权限的引擎在无权限时跳过并记录在Skipped中。
+func RunBenchmark(opts *BenchOptions, progress io.Writer) (*BenchReport, error) {
+	if opts == nil {
+		opts = DefaultBenchOptions()
+	}
+	if len(opts.Engines) == 0 || len(opts.Workers) == 0 {
+		return nil, fmt.Errorf("基准测试需要至少一个扫描引擎和并发数")
+	}
+	for _, engine := range opts.Engines {
+		if err := ValidateScanType(engine); err != nil {
+			return nil, err
+		}
+		if engine == ScanTypeACK {
+			return nil, fmt.Errorf("ACK扫描无法区分开放与关闭端口，不支持基准测试")
+		}
+	}
+	rateLimits := opts.RateLimits
+	if len(rateLimits) == 0 {
+		rateLimits = []int{0}
+	}
+
+	target, err := startBenchTarget(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer target.close()
+
+	report := &BenchReport{
+		StartTime: time.Now(),
+		CPUs:      runtime.NumCPU(),
+		FDLimit:   fdLimit(),
+		Skipped:   make(map[ScanType]string),
+	}
+	for _, engine := range opts.Engines {
+		reg, _ := LookupScanner(engine)
+		if reg.Capabilities.RequiresRoot && os.Geteuid() != 0 {
+			report.Skipped[engine] = "需要root权限"
+			continue
+		}
+		for _, workers := range opts.Workers {
+			for _, rate := range rateLimits {
+				if progress != nil {
+					fmt.Fprintf(progress, "测试 %s 扫描: 并发 %d, 速率 %s\n", engine, workers, rateLimitString(rate))
+				}
+				report.Results = append(report.Results, runBenchCase(target, engine, workers, rate, opts.Timeout))
+			}
+		}
+	}
+	if len(report.Skipped) == 0 {
+		report.Skipped = nil
+	}
+	return report, nil
+}
+
+// runBenchCase 运行一组测试并统计资源占用与准确率
+func runBenchCase(target *benchTarget, engine ScanType, workers, rate int, timeout time.Duration) BenchResult {
+	expected := target.tcp
+	if engine == ScanTypeUDP {
+		expected = target.udp
+	}
+	ports := make([]int, 0, len(expected))
+	for port := range expected {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	result := BenchResult{Engine: engine, Workers: workers, RateLimit: rate, Ports: len(ports)}
+	opts := &ScanOptions{
+		Target:    benchHost,
+		Ports:     joinPortsToString(ports),
+		ScanType:  engine,
+		Timeout:   timeout,
+		Workers:   workers,
+		RateLimit: rate,
+	}
+	if rate > 0 {
+		limiter := NewRateLimiter(rate)
+		defer limiter.Stop()
+		opts.Dialer = &rateLimitedDialer{dialer: scanDialer(opts), limiter: limiter}
+		if reg, _ := LookupScanner(engine); reg.Capabilities.RequiresRoot {
+			transport, err := newSocketTransport()
+			if err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			defer transport.Close()
+			opts.RawTransport = &rateLimitedTransport{RawTransport: transport, limiter: limiter}
+		}
+	}
+
+	sampler := startResourceSampler()
+	cpuBefore := processCPUTime()
+	start := time.Now()
+	results, err := runQuietScan(opts)
+	result.Duration = time.Since(start)
+	cpu := processCPUTime() - cpuBefore
+	result.PeakMemoryMB, result.PeakFDs = sampler.stop()
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if result.Duration > 0 {
+		result.PortsPerSec = float64(len(ports)) / result.Duration.Seconds()
+		result.CPUPercent = float64(cpu) / float64(result.Duration) * 100
+	}
+
+	correct := 0
+	for _, r := range results {
+		if r.State == expected[r.Port] {
+			correct++
+		} else {
+			result.Mismatched = append(result.Mismatched, r.Port)
+		}
+	}
+	if len(ports) > 0 {
+		result.Accuracy = float64(correct) / float64(len(ports))
+	}
+	sort.Ints(result.Mismatched)
+	return result
+}
+
+// rateLimitString 格式化速率限制
+func rateLimitString(rate int) string {
+	if rate <= 0 {
+		return "不限"
+	}
+	return fmt.Sprintf("%d/秒", rate)
+}
+
+// benchHost 基准测试监听的地址
+const benchHost = "127.0.0.1"
+
+// benchTarget 本地基准测试目标，记录各端口的预期状态
+type benchTarget struct {
+	tcp map[int]PortState
+	udp map[int]PortState
+
+	closers []io.Closer
+	wg      sync.WaitGroup
+}
+
+// startBenchTarget 启动开放、关闭和延迟应答的本地监听端口
+func startBenchTarget(opts *BenchOptions) (*benchTarget, error) {
+	t := &benchTarget{tcp: make(map[int]PortState), udp: make(map[int]PortState)}
+	fail := func(err error) (*benchTarget, error) {
+		t.close()
+		return nil, fmt.Errorf("启动本地监听失败: %v", err)
+	}
+
+	// 延迟超过超时时间的UDP端口在扫描器看来没有应答
+	delayedUDP := PortStateOpen
+	if opts.Delay >= opts.Timeout {
+		delayedUDP = PortStateFiltered
+	}
+
+	for i := 0; i < opts.OpenPorts+opts.DelayedPorts; i++ {
+		delay := time.Duration(0)
+		udpState := PortStateOpen
+		if i >= opts.OpenPorts {
+			delay, udpState = opts.Delay, delayedUDP
+		}
+
+		ln, err := net.Listen("tcp", net.JoinHostPort(benchHost, "0"))
+		if err != nil {
+			return fail(err)
+		}
+		t.closers = append(t.closers, ln)
+		t.tcp[ln.Addr().(*net.TCPAddr).Port] = PortStateOpen
+		t.wg.Add(1)
+		go t.serveTCP(ln, delay)
+
+		pc, err := net.ListenPacket("udp", net.JoinHostPort(benchHost, "0"))
+		if err != nil {
+			return fail(err)
+		}
+		t.closers = append(t.closers, pc)
+		t.udp[pc.LocalAddr().(*net.UDPAddr).Port] = udpState
+		t.wg.Add(1)
+		go t.serveUDP(pc, delay)
+	}
+
+	// 关闭端口：绑定后立即释放，连接时由内核以RST或ICMP端口不可达应答
+	// 释放的端口可能被再次分配，重复时重新获取
+	for closed := 0; closed < opts.ClosedPorts; {
+		ln, err := net.Listen("tcp", net.JoinHostPort(benchHost, "0"))
+		if err != nil {
+			return fail(err)
+		}
+		port := ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+		if _, dup := t.tcp[port]; !dup {
+			t.tcp[port] = PortStateClosed
+			closed++
+		}
+	}
+	for closed := 0; closed < opts.ClosedPorts; {
+		pc, err := net.ListenPacket("udp", net.JoinHostPort(benchHost, "0"))
+		if err != nil {
+			return fail(err)
+		}
+		port := pc.LocalAddr().(*net.UDPAddr).Port
+		pc.Close()
+		if _, dup := t.udp[port]; !dup {
+			t.udp[port] = PortStateClosed
+			closed++
+		}
+	}
+	return t, nil
+}
+
+// serveTCP 接受连接，延迟delay后发送banner并关闭
+func (t *benchTarget) serveTCP(ln net.Listener, delay time.Duration) {
+	defer t.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			time.Sleep(delay)
+			conn.Write([]byte(benchBanner))
+		}()
+	}
+}
+
+// serveUDP 延迟delay后原样返回收到的数据
+func (t *benchTarget) serveUDP(pc net.PacketConn, delay time.Duration) {
+	defer t.wg.Done()
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		data := append([]byte(nil), buf[:n]...)
+		if len(data) == 0 {
+			data = []byte(benchBanner)
+		}
+		time.AfterFunc(delay, func() { pc.WriteTo(data, addr) })
+	}
+}
+
+// close 关闭全部监听并等待服务协程退出
+func (t *benchTarget) close() {
+	for _, c := range t.closers {
+		c.Close()
+	}
+	t.wg.Wait()
+}
+
+// rateLimitedDialer 按令牌桶限制建立连接的速率
+type rateLimitedDialer struct {
+	dialer  Dialer
+	limiter *RateLimiter
+}
+
+// DialContext 获取令牌后建立连接
+func (d *rateLimitedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := d.limiter.Wait(); err != nil {
+		return nil, err
+	}
+	return d.dialer.DialContext(ctx, network, address)
+}
+
+// rateLimitedTransport 按令牌桶限制原始报文的发送速率
+type rateLimitedTransport struct {
+	RawTransport
+	limiter *RateLimiter
+}
+
+// WritePacket 获取令牌后发送报文
+func (t *rateLimitedTransport) WritePacket(data []byte, dst net.IP) error {
+	if err := t.limiter.Wait(); err != nil {
+		return err
+	}
+	return t.RawTransport.WritePacket(data, dst)
+}
+
+// resourceSampler 周期性采样堆内存与文件描述符峰值
+type resourceSampler struct {
+	done     chan struct{}
+	finished chan struct{}
+	peakHeap uint64
+	peakFDs  int
+}
+
+// startResourceSampler 开始采样
+func startResourceSampler() *resourceSampler {
+	s := &resourceSampler{done: make(chan struct{}), finished: make(chan struct{}), peakFDs: -1}
+	go func() {
+		defer close(s.finished)
+		ticker := time.NewTicker(benchSampleInterval)
+		defer ticker.Stop()
+		for {
+			s.sample()
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// sample 记录一次采样
+func (s *resourceSampler) sample() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	if m.HeapInuse > s.peakHeap {
+		s.peakHeap = m.HeapInuse
+	}
+	if fds := countOpenFDs(); fds > s.peakFDs {
+		s.peakFDs = fds
+	}
+}
+
+// stop 停止采样并返回堆内存峰值(MB)与文件描述符峰值
+func (s *resourceSampler) stop() (float64, int) {
+	close(s.done)
+	<-s.finished
+	s.sample()
+	return float64(s.peakHeap) / 1024 / 1024, s.peakFDs
+}
+
+// countOpenFDs 统计进程打开的文件描述符数，不支持的系统返回-1
+func countOpenFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// processCPUTime 返回进程累计使用的用户态与内核态CPU时间
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// fdLimit 返回文件描述符软限制
+func fdLimit() int {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0
+	}
+	return int(rlimit.Cur)
+}
+
+// PrintBenchReport 打印基准测试报告
+func PrintBenchReport(report *BenchReport) {
+	fmt.Printf("扫描器基准测试 (CPU: %d 核, 文件描述符限制: %d)\n", report.CPUs, report.FDLimit)
+	fmt.Println("========================================")
+	fmt.Printf("%-6s %-6s %-8s %-10s %-10s %-8s %-8s %-6s %s\n",
+		"引擎", "并发", "速率", "耗时", "端口/秒", "CPU%", "内存MB", "FD", "准确率")
+	for _, r := range report.Results {
+		if r.Error != "" {
+			fmt.Printf("%-6s %-6d %-8s 失败: %s\n", r.Engine, r.Workers, rateLimitString(r.RateLimit), r.Error)
+			continue
+		}
+		fmt.Printf("%-6s %-6d %-8s %-10s %-10.0f %-8.1f %-8.1f %-6s %.1f%%\n",
+			r.Engine, r.Workers, rateLimitString(r.RateLimit), r.Duration.Round(time.Millisecond),
+			r.PortsPerSec, r.CPUPercent, r.PeakMemoryMB, fdString(r.PeakFDs), r.Accuracy*100)
+	}
+	for engine, reason := range report.Skipped {
+		fmt.Printf("跳过 %s 扫描: %s\n", engine, reason)
+	}
+}
+
+// fdString 格式化文件描述符数量
+func fdString(fds int) string {
+	if fds < 0 {
+		return "-"
+	}
+	return strconv.Itoa(fds)
+}
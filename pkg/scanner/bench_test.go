@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBenchmarkLoopback(t *testing.T) {
+	report, err := RunBenchmark(&BenchOptions{
+		Engines:      []ScanType{ScanTypeTCP, ScanTypeUDP},
+		Workers:      []int{4, 16},
+		OpenPorts:    5,
+		ClosedPorts:  10,
+		DelayedPorts: 2,
+		Delay:        20 * time.Millisecond,
+		Timeout:      500 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 4)
+
+	for _, r := range report.Results {
+		assert.Empty(t, r.Error)
+		assert.Equal(t, 17, r.Ports)
+		assert.Equal(t, 1.0, r.Accuracy, "%s/%d 状态不一致的端口: %v", r.Engine, r.Workers, r.Mismatched)
+		assert.Greater(t, r.PortsPerSec, 0.0)
+	}
+}
+
+func TestRunBenchmarkRejectsACK(t *testing.T) {
+	_, err := RunBenchmark(&BenchOptions{Engines: []ScanType{ScanTypeACK}, Workers: []int{1}}, nil)
+	assert.Error(t, err)
+}
+
+func TestBenchReportProfile(t *testing.T) {
+	report := &BenchReport{CPUs: 4, FDLimit: 1024, Results: []BenchResult{
+		{Engine: ScanTypeTCP, Workers: 50, PortsPerSec: 900, Accuracy: 1},
+		{Engine: ScanTypeTCP, Workers: 200, PortsPerSec: 1500, Accuracy: 0.9}, // 吞吐高但丢失结果
+		{Engine: ScanTypeTCP, Workers: 100, PortsPerSec: 900, Accuracy: 1},
+		{Engine: ScanTypeTCP, Workers: 400, Error: "too many open files"},
+		{Engine: ScanTypeUDP, Workers: 10, PortsPerSec: 100, Accuracy: 0.8},
+		{Engine: ScanTypeUDP, Workers: 50, PortsPerSec: 300, Accuracy: 0.7},
+	}}
+
+	profile := report.Profile()
+	tcp, ok := profile.Engine(ScanTypeTCP)
+	require.True(t, ok)
+	assert.Equal(t, 50, tcp.Workers, "吞吐相同时取较小的并发数")
+
+	udp, ok := profile.Engine(ScanTypeUDP)
+	require.True(t, ok)
+	assert.Equal(t, 10, udp.Workers, "没有达标配置时取准确率最高者")
+
+	_, ok = profile.Engine(ScanTypeSYN)
+	assert.False(t, ok)
+
+	path := filepath.Join(t.TempDir(), "profile.json")
+	require.NoError(t, SaveMachineProfile(path, profile))
+	loaded, err := LoadMachineProfile(path)
+	require.NoError(t, err)
+	assert.Equal(t, profile.Engines, loaded.Engines)
+}
+
+func TestScanAdvisorUsesMachineProfile(t *testing.T) {
+	advisor, err := NewScanAdvisor(&ScanOptions{Target: "127.0.0.1", Ports: "1-2000", ScanType: ScanTypeTCP, Workers: 20})
+	require.NoError(t, err)
+	advisor.SetMachineProfile(&MachineProfile{Engines: map[ScanType]EngineProfile{
+		ScanTypeTCP: {Workers: 200, RateLimit: 5000, PortsPerSec: 8000, Accuracy: 1},
+	}})
+
+	var concurrency string
+	for _, s := range advisor.AnalyzeAndSuggest() {
+		if strings.Contains(s, "并发优化建议") {
+			concurrency = s
+		}
+	}
+	assert.Contains(t, concurrency, "基于本机基准测试")
+	assert.Contains(t, concurrency, "调整为 200")
+
+	optimized := advisor.GetOptimizedConfig()
+	assert.Equal(t, 200, optimized.Workers)
+	assert.Equal(t, 5000, optimized.RateLimit)
+
+	// 接近实测最佳的并发数不再提示
+	advisor.opts.Workers = 150
+	for _, s := range advisor.AnalyzeAndSuggest() {
+		assert.NotContains(t, s, "并发优化建议")
+	}
+}
@@ -0,0 +1,238 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+)
+
+// EventType 扫描事件类型
+type EventType string
+
+const (
+	EventScanStarted     EventType = "scan_started"     // 扫描开始
+	EventHostUp          EventType = "host_up"          // 主机存活
+	EventPortResult      EventType = "port_result"      // 端口扫描结果
+	EventServiceDetected EventType = "service_detected" // 识别到服务
+	EventOSDetected      EventType = "os_detected"      // 识别到操作系统
+	EventProgress        EventType = "progress"         // 扫描进度
+	EventHostAbandoned   EventType = "host_abandoned"   // 主机被放弃
+	EventScanFinished    EventType = "scan_finished"    // 扫描结束
+	EventError           EventType = "error"            // 扫描错误
+)
+
+// ScanEvent 扫描生命周期事件，按事件类型填充对应字段
+type ScanEvent struct {
+	Type      EventType            // 事件类型
+	Time      time.Time            // 事件时间
+	Target    string               // 扫描目标
+	ScanType  ScanType             // 扫描类型
+	Port      int                  // 相关端口
+	Result    *ScanResult          // 端口结果 (EventPortResult)
+	Host      *HostStatus          // 主机状态 (EventHostUp)
+	Service   *fingerprint.Service // 服务信息 (EventServiceDetected)
+	OS        *fingerprint.OSInfo  // 操作系统信息 (EventOSDetected)
+	Completed int                  // 已完成数量 (EventProgress)
+	Total     int                  // 总数量 (EventScanStarted/EventProgress)
+	Progress  float64              // 完成百分比 (EventProgress)
+	Stats     *ScanStats           // 统计信息 (EventScanFinished)
+	GiveUp    *HostGiveUp          // 放弃记录 (EventHostAbandoned)
+	Err       error                // 错误信息 (EventError)
+}
+
+// Observer 扫描事件观察者
+// 事件在扫描协程中同步分发，实现需并发安全且尽快返回
+type Observer interface {
+	OnEvent(event ScanEvent)
+}
+
+// ObserverFunc 函数形式的观察者
+type ObserverFunc func(event ScanEvent)
+
+// OnEvent 实现Observer接口
+func (f ObserverFunc) OnEvent(event ScanEvent) {
+	f(event)
+}
+
+// eventBus 扫描事件分发器，同时维护本次扫描的统计信息
+type eventBus struct {
+	target    string
+	scanType  ScanType
+	observers []Observer
+	stats     *ScanStats
+	mu        sync.Mutex
+}
+
+// newEventBus 创建事件分发器，内置的指标与日志观察者排在用户观察者之前
+func newEventBus(target string, scanType ScanType, observers []Observer) *eventBus {
+	all := []Observer{NewMetricsObserver(), NewLoggingObserver()}
+	all = append(all, observers...)
+	return &eventBus{
+		target:    target,
+		scanType:  scanType,
+		observers: all,
+		stats:     NewScanStats(),
+	}
+}
+
+// emit 分发事件
+func (b *eventBus) emit(event ScanEvent) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Target == "" {
+		event.Target = b.target
+	}
+	if event.ScanType == "" {
+		event.ScanType = b.scanType
+	}
+	for _, o := range b.observers {
+		o.OnEvent(event)
+	}
+}
+
+// started 分发扫描开始事件
+func (b *eventBus) started(total int) {
+	b.mu.Lock()
+	b.stats = NewScanStats()
+	b.stats.TotalPorts = total
+	b.mu.Unlock()
+
+	b.emit(ScanEvent{Type: EventScanStarted, Total: total})
+}
+
+// portResult 分发端口结果事件并更新统计
+func (b *eventBus) portResult(result ScanResult) {
+	b.mu.Lock()
+	switch result.State {
+	case PortStateOpen:
+		b.stats.OpenPorts++
+	case PortStateClosed:
+		b.stats.ClosedPorts++
+	case PortStateFiltered:
+		b.stats.FilteredPorts++
+	}
+	b.mu.Unlock()
+
+	b.emit(ScanEvent{Type: EventPortResult, Port: result.Port, Result: &result})
+}
+
+// progress 分发进度事件
+func (b *eventBus) progress(completed, total int) {
+	percentage := 100.0
+	if total > 0 {
+		percentage = float64(completed) / float64(total) * 100
+	}
+	b.emit(ScanEvent{Type: EventProgress, Completed: completed, Total: total, Progress: percentage})
+}
+
+// failed 分发错误事件
+func (b *eventBus) failed(port int, err error) {
+	b.mu.Lock()
+	b.stats.Errors++
+	b.mu.Unlock()
+
+	b.emit(ScanEvent{Type: EventError, Port: port, Err: err})
+}
+
+// abandoned 分发主机被放弃事件
+func (b *eventBus) abandoned(giveUp *HostGiveUp) {
+	b.emit(ScanEvent{Type: EventHostAbandoned, Target: giveUp.Target, GiveUp: giveUp})
+}
+
+// finished 分发扫描结束事件
+func (b *eventBus) finished() {
+	b.mu.Lock()
+	b.stats.EndTime = time.Now()
+	if elapsed := b.stats.EndTime.Sub(b.stats.StartTime).Seconds(); elapsed > 0 {
+		done := b.stats.OpenPorts + b.stats.ClosedPorts + b.stats.FilteredPorts
+		b.stats.ScanRate = float64(done) / elapsed
+	}
+	stats := *b.stats
+	b.mu.Unlock()
+
+	b.emit(ScanEvent{Type: EventScanFinished, Total: stats.TotalPorts, Stats: &stats})
+}
+
+// fingerprintObserver 将指纹识别器的回调转换为扫描事件
+func (b *eventBus) fingerprintObserver() fingerprint.FingerprintObserver {
+	return &fingerprintEventAdapter{bus: b}
+}
+
+// fingerprintEventAdapter 指纹识别事件适配器
+type fingerprintEventAdapter struct {
+	bus *eventBus
+}
+
+// OnServiceFingerprint 服务识别完成
+func (a *fingerprintEventAdapter) OnServiceFingerprint(target string, port int, fp *fingerprint.ServiceFingerprint) {
+	a.bus.emit(ScanEvent{
+		Type:    EventServiceDetected,
+		Target:  target,
+		Port:    port,
+		Service: serviceFromFingerprint(fp),
+	})
+}
+
+// OnOSFingerprint 操作系统识别完成
+func (a *fingerprintEventAdapter) OnOSFingerprint(target string, fp *fingerprint.OSFingerprint) {
+	a.bus.emit(ScanEvent{
+		Type:   EventOSDetected,
+		Target: target,
+		OS:     osInfoFromFingerprint(fp),
+	})
+}
+
+// serviceFromFingerprint 将服务指纹转换为Service结构
+func serviceFromFingerprint(fp *fingerprint.ServiceFingerprint) *fingerprint.Service {
+	service := &fingerprint.Service{
+		Name:       fp.Name,
+		Product:    fp.Product,
+		Version:    fp.Version,
+		Protocol:   "tcp", // 默认为TCP
+		DeviceType: fp.DeviceType,
+		CPE:        fp.CPE,
+		Confidence: fp.Confidence,
+		Metadata:   make(map[string]string),
+	}
+	for key, value := range map[string]string{"info": fp.Info, "hostname": fp.Hostname, "os": fp.OS, "tunnel": fp.Tunnel, "fingerprint_db": fp.DBVersion} {
+		if value != "" {
+			service.Metadata[key] = value
+		}
+	}
+	if fp.Record != nil {
+		service.Fingerprint = fp.Record.String()
+	}
+	return service
+}
+
+// osInfoFromFingerprint 将操作系统指纹转换为OSInfo结构
+func osInfoFromFingerprint(fp *fingerprint.OSFingerprint) *fingerprint.OSInfo {
+	info := &fingerprint.OSInfo{
+		Name:       fp.Name,
+		Family:     fp.Family,
+		Version:    fp.Version,
+		CPE:        fp.CPE,
+		Confidence: fp.Confidence,
+		Guesses:    fp.Guesses,
+		Metadata:   make(map[string]string),
+	}
+	if fp.DBVersion != "" {
+		info.Metadata["fingerprint_db"] = fp.DBVersion
+	}
+	if len(fp.Guesses) > 0 {
+		best := fp.Guesses[0]
+		info.Generation = best.Generation
+		if best.Vendor != "" {
+			info.Metadata["vendor"] = best.Vendor
+		}
+		if best.DeviceType != "" {
+			info.Metadata["device_type"] = best.DeviceType
+		}
+	}
+	return info
+}
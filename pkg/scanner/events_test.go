@@ -0,0 +1,158 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventRecorder 记录收到的扫描事件
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []ScanEvent
+}
+
+func (r *eventRecorder) OnEvent(event ScanEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) ofType(t EventType) []ScanEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []ScanEvent
+	for _, e := range r.events {
+		if e.Type == t {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// listenLocal 启动一个接受连接的本地TCP服务
+func listenLocal(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestScannerEmitsLifecycleEvents(t *testing.T) {
+	port := listenLocal(t)
+	rec := &eventRecorder{}
+
+	s, err := NewScanner(&ScanOptions{
+		Target:    "127.0.0.1",
+		Ports:     strconv.Itoa(port),
+		ScanType:  ScanTypeTCP,
+		Timeout:   time.Second,
+		Workers:   1,
+		Observers: []Observer{rec},
+	})
+	require.NoError(t, err)
+
+	_, err = s.Scan(context.Background())
+	require.NoError(t, err)
+
+	started := rec.ofType(EventScanStarted)
+	require.Len(t, started, 1)
+	assert.Equal(t, 1, started[0].Total)
+	assert.Equal(t, rec.events[0].Type, EventScanStarted)
+
+	results := rec.ofType(EventPortResult)
+	require.Len(t, results, 1)
+	assert.Equal(t, port, results[0].Port)
+	assert.Equal(t, PortStateOpen, results[0].Result.State)
+
+	require.Len(t, rec.ofType(EventHostUp), 1)
+
+	progress := rec.ofType(EventProgress)
+	require.NotEmpty(t, progress)
+	assert.Equal(t, 100.0, progress[len(progress)-1].Progress)
+
+	finished := rec.ofType(EventScanFinished)
+	require.Len(t, finished, 1)
+	assert.Equal(t, 1, finished[0].Stats.OpenPorts)
+	assert.Equal(t, EventScanFinished, rec.events[len(rec.events)-1].Type)
+}
+
+func TestBaseScannerEmitsPortResults(t *testing.T) {
+	port := listenLocal(t)
+	var mu sync.Mutex
+	var open []int
+
+	observer := ObserverFunc(func(e ScanEvent) {
+		if e.Type == EventPortResult && e.Result.State == PortStateOpen {
+			mu.Lock()
+			open = append(open, e.Port)
+			mu.Unlock()
+		}
+	})
+
+	results, err := NewTCPScanner().Scan(context.Background(), &ScanOptions{
+		Target:    "127.0.0.1",
+		Ports:     strconv.Itoa(port),
+		Timeout:   time.Second,
+		Workers:   1,
+		Observers: []Observer{observer},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, PortStateOpen, results[0].State)
+	assert.Equal(t, []int{port}, open)
+}
+
+func TestDiscoverHostsEmitsHostUp(t *testing.T) {
+	port := listenLocal(t)
+	rec := &eventRecorder{}
+
+	opts := DefaultDiscoveryOptions()
+	opts.SkipPing = true
+	opts.TCPPorts = []int{port}
+	opts.Observers = []Observer{rec}
+
+	hosts, err := DiscoverHosts([]string{"127.0.0.1/32"}, opts)
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+
+	up := rec.ofType(EventHostUp)
+	require.Len(t, up, 1)
+	assert.Equal(t, "127.0.0.1", up[0].Host.IP)
+	assert.Len(t, rec.ofType(EventScanFinished), 1)
+}
+
+func TestFingerprintEventAdapter(t *testing.T) {
+	rec := &eventRecorder{}
+	bus := newEventBus("10.0.0.1", ScanTypeTCP, []Observer{rec})
+	adapter := bus.fingerprintObserver()
+
+	adapter.OnServiceFingerprint("10.0.0.1", 22, &fingerprint.ServiceFingerprint{Name: "ssh", Version: "8.9"})
+	adapter.OnOSFingerprint("10.0.0.1", &fingerprint.OSFingerprint{Name: "Linux"})
+
+	services := rec.ofType(EventServiceDetected)
+	require.Len(t, services, 1)
+	assert.Equal(t, 22, services[0].Port)
+	assert.Equal(t, "ssh", services[0].Service.Name)
+
+	oses := rec.ofType(EventOSDetected)
+	require.Len(t, oses, 1)
+	assert.Equal(t, "Linux", oses[0].OS.Name)
+}
@@ -0,0 +1,537 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+)
+
+// FirewallVerdict 端口过滤行为的结论
+type FirewallVerdict string
+
+const (
+	FirewallUnfiltered FirewallVerdict = "unfiltered"         // 探测直达主机，未发现过滤
+	FirewallFiltered   FirewallVerdict = "filtered"           // 存在过滤，证据不足以区分类型
+	FirewallStateful   FirewallVerdict = "stateful-filtered"  // 有状态防火墙，丢弃不属于已有连接的报文
+	FirewallStateless  FirewallVerdict = "stateless-filtered" // 无状态过滤，只按标志位拦截SYN
+	FirewallIPS        FirewallVerdict = "ips"                // 入侵防御系统，按行为阻断或限速
+	FirewallUnknown    FirewallVerdict = "unknown"            // 应答不足以判断
+)
+
+// firewallTTLTolerance RST与主机应答的TTL相差超过该值时认为RST由中间设备发出
+const firewallTTLTolerance = 1
+
+// firewallMinSilentTail 判断限速时连续无应答端口的最少数量
+const firewallMinSilentTail = 3
+
+// DefaultFirewallProbes 防火墙分析默认使用的探测类型
+var DefaultFirewallProbes = []ScanType{ScanTypeTCP, ScanTypeSYN, ScanTypeACK, ScanTypeFIN, ScanTypeUDP}
+
+// FirewallEvidence 支持结论的一条观察
+type FirewallEvidence struct {
+	Probe  ScanType `json:"probe"`  // 产生该观察的探测类型
+	Detail string   `json:"detail"` // 观察说明
+}
+
+// PortFirewallAnalysis 单个端口的过滤行为分析
+type PortFirewallAnalysis struct {
+	Port     int                `json:"port"`     // 端口号
+	Protocol string             `json:"protocol"` // 协议(tcp/udp)
+	Verdict  FirewallVerdict    `json:"verdict"`  // 结论
+	Evidence []FirewallEvidence `json:"evidence"` // 证据
+}
+
+// FirewallReport 防火墙/IDS行为分析报告
+type FirewallReport struct {
+	Target    string                  `json:"target"`               // 目标
+	HostTTL   int                     `json:"host_ttl,omitempty"`   // 主机SYN/ACK应答的常见TTL
+	Ports     []PortFirewallAnalysis  `json:"ports"`                // 各端口分析
+	Summary   map[FirewallVerdict]int `json:"summary"`              // 各结论的端口数
+	RateLimit []FirewallEvidence      `json:"rate_limit,omitempty"` // 限速迹象
+	Errors    map[ScanType]string     `json:"errors,omitempty"`     // 未能执行的探测
+}
+
+// DetectFirewall 使用多种探测扫描目标并分析防火墙与IPS行为
+// probes为空时使用DefaultFirewallProbes；udpPorts为空时UDP探测使用opts.Ports。
+// 需要root权限的探测在无权限时跳过并记录在报告的Errors中。
+func DetectFirewall(opts *ScanOptions, probes []ScanType, udpPorts string) (*FirewallReport, error) {
+	if opts == nil {
+		return nil, ErrInvalidOptions
+	}
+	if opts.Target == "" {
+		return nil, ErrInvalidTarget
+	}
+	if len(probes) == 0 {
+		probes = DefaultFirewallProbes
+	}
+	if udpPorts == "" {
+		udpPorts = opts.Ports
+	}
+
+	// 过滤判断依赖ICMP不可达，原始报文探测共用一个同时接收ICMP的传输
+	if opts.RawTransport == nil {
+		if transport := firewallTransport(); transport != nil {
+			defer transport.Close()
+			withTransport := *opts
+			withTransport.RawTransport = transport
+			opts = &withTransport
+		}
+	}
+
+	results := make(map[ScanType][]ScanResult)
+	errs := make(map[ScanType]string)
+	for _, probe := range probes {
+		probeOpts := *opts
+		probeOpts.ScanType = probe
+		if probe == ScanTypeUDP {
+			probeOpts.Ports = udpPorts
+		}
+
+		probeResults, err := runQuietScan(&probeOpts)
+		if err != nil {
+			errs[probe] = err.Error()
+			continue
+		}
+		results[probe] = probeResults
+	}
+
+	if len(results) == 0 {
+		var msgs []string
+		for _, probe := range probes {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", probe, errs[probe]))
+		}
+		return nil, fmt.Errorf("所有探测均失败: %s", strings.Join(msgs, "; "))
+	}
+
+	report := AnalyzeFirewall(opts.Target, results)
+	if len(errs) > 0 {
+		report.Errors = errs
+	}
+	return report, nil
+}
+
+// firewallTransport 创建防火墙探测使用的原始报文传输，无root权限或创建失败时返回nil，由探测自行报错
+// 原始TCP套接字收不到ICMP报文，被ICMP拒绝的端口会误判为无应答，因此同时接收ICMP
+func firewallTransport() RawTransport {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	transport, err := newICMPSocketTransport()
+	if err != nil {
+		logger.Debugf("创建ICMP原始报文传输失败: %v", err)
+		return nil
+	}
+	return transport
+}
+
+// firewallView 按探测类型和端口索引的扫描结果
+type firewallView struct {
+	results map[ScanType]map[int]ScanResult
+	hostTTL int
+}
+
+// get 返回指定探测对端口的结果
+func (v *firewallView) get(scanType ScanType, port int) (ScanResult, bool) {
+	r, ok := v.results[scanType][port]
+	return r, ok
+}
+
+// stealth 返回第一个存在的FIN/NULL/XMAS探测类型与结果
+func (v *firewallView) stealth(port int) (ScanType, ScanResult, bool) {
+	for _, scanType := range []ScanType{ScanTypeFIN, ScanTypeNULL, ScanTypeXMAS} {
+		if r, ok := v.get(scanType, port); ok {
+			return scanType, r, true
+		}
+	}
+	return "", ScanResult{}, false
+}
+
+// AnalyzeFirewall 比较同一目标在不同探测下的应答，推断各端口的过滤行为
+// results以探测类型为键，TCP端口取TCP connect、SYN、ACK、FIN/NULL/XMAS探测，UDP端口取UDP探测。
+func AnalyzeFirewall(target string, results map[ScanType][]ScanResult) *FirewallReport {
+	view := &firewallView{results: make(map[ScanType]map[int]ScanResult)}
+	tcpPorts := make(map[int]bool)
+	for scanType, list := range results {
+		byPort := make(map[int]ScanResult, len(list))
+		for _, r := range list {
+			byPort[r.Port] = r
+			if scanType != ScanTypeUDP {
+				tcpPorts[r.Port] = true
+			}
+		}
+		view.results[scanType] = byPort
+	}
+	view.hostTTL = commonReplyTTL(results[ScanTypeSYN], "syn-ack")
+
+	report := &FirewallReport{
+		Target:  target,
+		HostTTL: view.hostTTL,
+		Summary: make(map[FirewallVerdict]int),
+	}
+
+	// SYN探测在若干端口后不再有应答，而这些端口对完整连接有应答，说明扫描被限速或封禁
+	limited := make(map[int]bool)
+	if tail := silentTail(results[ScanTypeSYN]); len(tail) > 0 {
+		for _, port := range tail {
+			if r, ok := view.get(ScanTypeTCP, port); ok && r.State != PortStateFiltered {
+				limited[port] = true
+			}
+		}
+		if len(limited) > 0 {
+			report.RateLimit = append(report.RateLimit, FirewallEvidence{
+				Probe: ScanTypeSYN,
+				Detail: fmt.Sprintf("从端口 %d 起连续 %d 个端口SYN探测无应答，其中 %d 个端口完整连接有应答，疑似SYN探测被限速或封禁",
+					tail[0], len(tail), len(limited)),
+			})
+		}
+	}
+
+	for _, port := range sortedPorts(tcpPorts) {
+		report.Ports = append(report.Ports, view.analyzeTCPPort(port, limited[port]))
+	}
+
+	udpLimited := icmpLimitedPorts(results[ScanTypeUDP])
+	if len(udpLimited) > 0 {
+		report.RateLimit = append(report.RateLimit, FirewallEvidence{
+			Probe: ScanTypeUDP,
+			Detail: fmt.Sprintf("ICMP端口不可达只出现在前面的端口，其后 %d 个端口均无应答，疑似ICMP差错报文限速",
+				len(udpLimited)),
+		})
+	}
+	udpPorts := make(map[int]bool)
+	for port := range view.results[ScanTypeUDP] {
+		udpPorts[port] = true
+	}
+	for _, port := range sortedPorts(udpPorts) {
+		report.Ports = append(report.Ports, view.analyzeUDPPort(port, udpLimited[port]))
+	}
+
+	for _, port := range report.Ports {
+		report.Summary[port.Verdict]++
+	}
+	return report
+}
+
+// analyzeTCPPort 分析单个TCP端口
+func (v *firewallView) analyzeTCPPort(port int, rateLimited bool) PortFirewallAnalysis {
+	analysis := PortFirewallAnalysis{Port: port, Protocol: "tcp"}
+	add := func(probe ScanType, format string, args ...interface{}) {
+		analysis.Evidence = append(analysis.Evidence, FirewallEvidence{Probe: probe, Detail: fmt.Sprintf(format, args...)})
+	}
+	verdict := func(verdict FirewallVerdict) PortFirewallAnalysis {
+		analysis.Verdict = verdict
+		return analysis
+	}
+
+	syn, hasSYN := v.get(ScanTypeSYN, port)
+	ack, hasACK := v.get(ScanTypeACK, port)
+	connect, hasConnect := v.get(ScanTypeTCP, port)
+	stealthType, stealth, hasStealth := v.stealth(port)
+	stealthName := strings.ToUpper(string(stealthType))
+	synReason, ackReason, stealthReason := resultReason(syn), resultReason(ack), resultReason(stealth)
+
+	// RST的TTL与主机应答不一致时，RST由主机前的设备代发
+	synForged := hasSYN && synReason == "reset" && v.forgedTTL(syn.TTL)
+	if synForged {
+		add(ScanTypeSYN, "RST的TTL为%d，主机SYN/ACK应答的TTL为%d，RST由中间设备代发", syn.TTL, v.hostTTL)
+	}
+	ackForged := hasACK && ackReason == "reset" && v.forgedTTL(ack.TTL)
+	if ackForged {
+		add(ScanTypeACK, "RST的TTL为%d，主机SYN/ACK应答的TTL为%d，RST由中间设备代发", ack.TTL, v.hostTTL)
+	}
+	ackReached := hasACK && ackReason == "reset" && !ackForged
+	stealthReached := hasStealth && stealthReason == "reset" && !v.forgedTTL(stealth.TTL)
+
+	// 半开放探测与完整连接结果矛盾，说明有设备按连接行为阻断
+	ips := false
+	if hasSYN && hasConnect {
+		if synReason == "syn-ack" && connect.State != PortStateOpen {
+			add(ScanTypeTCP, "SYN探测收到SYN/ACK，但完整连接结果为%s，疑似IPS在握手后阻断", connect.State)
+			ips = true
+		}
+		if connect.State == PortStateOpen && syn.State == PortStateFiltered {
+			add(ScanTypeSYN, "完整连接成功，但SYN半开放探测%s，疑似IPS识别并丢弃扫描报文", describeFirewallReason(synReason))
+			ips = true
+		}
+	}
+	if rateLimited {
+		add(ScanTypeSYN, "此前端口的SYN探测均有应答，从此处起连续无应答，但完整连接结果为%s，疑似触发限速或封禁", connect.State)
+		ips = true
+	}
+	if ips {
+		return verdict(FirewallIPS)
+	}
+
+	// SYN被丢弃、拒绝或由中间设备应答
+	if hasSYN && (syn.State == PortStateFiltered || synForged) {
+		if code, ok := icmpUnreachCode(synReason); ok && isAdminProhibited(code) {
+			add(ScanTypeSYN, "SYN探测收到ICMP管理禁止(代码%d)，防火墙规则拒绝", code)
+		} else if !synForged {
+			add(ScanTypeSYN, "SYN探测%s", describeFirewallReason(synReason))
+		}
+
+		switch {
+		case ackReached:
+			add(ScanTypeACK, "ACK探测收到主机RST(TTL %d)，不带SYN的报文可以穿透，过滤器只按标志位拦截SYN", ack.TTL)
+			return verdict(FirewallStateless)
+		case stealthReached:
+			add(stealthType, "%s探测收到RST，不带SYN的报文可以穿透，过滤器只按标志位拦截SYN", stealthName)
+			return verdict(FirewallStateless)
+		case hasACK:
+			add(ScanTypeACK, "ACK探测%s，不属于已有连接的报文同样被拦截", describeFirewallReason(ackReason))
+			return verdict(FirewallStateful)
+		default:
+			return verdict(FirewallFiltered)
+		}
+	}
+
+	// SYN到达主机
+	if hasSYN && syn.State != PortStateUnknown {
+		add(ScanTypeSYN, "SYN探测%s(TTL %d)，探测到达主机", describeFirewallReason(synReason), syn.TTL)
+		if hasACK && !ackReached {
+			add(ScanTypeACK, "ACK探测%s，无连接状态的ACK被拦截，防火墙跟踪连接状态", describeFirewallReason(ackReason))
+			return verdict(FirewallStateful)
+		}
+		if synReason == "reset" && hasStealth && stealthReason == "no-response" {
+			add(stealthType, "端口关闭但%s探测无应答，不属于连接的报文被丢弃", stealthName)
+			return verdict(FirewallStateful)
+		}
+		if ackReached {
+			add(ScanTypeACK, "ACK探测收到主机RST，端口未被过滤")
+		}
+		return verdict(FirewallUnfiltered)
+	}
+
+	// 没有SYN探测结果时根据ACK与完整连接判断
+	switch {
+	case ackReached:
+		add(ScanTypeACK, "ACK探测收到主机RST，端口未被过滤")
+		return verdict(FirewallUnfiltered)
+	case hasACK:
+		add(ScanTypeACK, "ACK探测%s", describeFirewallReason(ackReason))
+		return verdict(FirewallFiltered)
+	case hasConnect && connect.State != PortStateFiltered && connect.State != PortStateUnknown:
+		add(ScanTypeTCP, "完整连接结果为%s，探测到达主机", connect.State)
+		return verdict(FirewallUnfiltered)
+	case hasConnect && connect.State == PortStateFiltered:
+		add(ScanTypeTCP, "完整连接无应答")
+		return verdict(FirewallFiltered)
+	default:
+		return verdict(FirewallUnknown)
+	}
+}
+
+// analyzeUDPPort 分析单个UDP端口
+func (v *firewallView) analyzeUDPPort(port int, rateLimited bool) PortFirewallAnalysis {
+	analysis := PortFirewallAnalysis{Port: port, Protocol: "udp", Verdict: FirewallUnknown}
+	r, _ := v.get(ScanTypeUDP, port)
+	evidence := func(detail string) {
+		analysis.Evidence = append(analysis.Evidence, FirewallEvidence{Probe: ScanTypeUDP, Detail: detail})
+	}
+
+	switch reason := resultReason(r); {
+	case reason == "got-response":
+		evidence("UDP探测收到应答，探测到达主机")
+		analysis.Verdict = FirewallUnfiltered
+	case reason == "port-unreach":
+		evidence("收到ICMP端口不可达，探测到达主机")
+		analysis.Verdict = FirewallUnfiltered
+	case rateLimited:
+		evidence("无应答，前面的端口返回过ICMP端口不可达，可能是ICMP差错报文限速所致")
+	case r.State == PortStateFiltered:
+		evidence("无应答，端口开放但不应答或被过滤")
+	default:
+		evidence(fmt.Sprintf("探测结果为%s", r.State))
+	}
+	return analysis
+}
+
+// forgedTTL 判断RST的TTL是否与主机应答不一致
+func (v *firewallView) forgedTTL(ttl int) bool {
+	if v.hostTTL == 0 || ttl == 0 {
+		return false
+	}
+	diff := ttl - v.hostTTL
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff > firewallTTLTolerance
+}
+
+// resultReason 返回扫描结果中记录的应答原因
+func resultReason(r ScanResult) string {
+	reason, _ := r.Metadata["reason"].(string)
+	return reason
+}
+
+// describeFirewallReason 将应答原因转换为说明
+func describeFirewallReason(reason string) string {
+	if code, ok := icmpUnreachCode(reason); ok {
+		if isAdminProhibited(code) {
+			return fmt.Sprintf("收到ICMP管理禁止(代码%d)", code)
+		}
+		return fmt.Sprintf("收到ICMP不可达(代码%d)", code)
+	}
+	switch reason {
+	case "no-response", "timeout", "":
+		return "无应答"
+	case "reset":
+		return "收到RST"
+	case "syn-ack":
+		return "收到SYN/ACK"
+	default:
+		return "收到" + reason
+	}
+}
+
+// icmpUnreachCode 解析"icmp-unreach-<代码>"形式的应答原因
+func icmpUnreachCode(reason string) (int, bool) {
+	if !strings.HasPrefix(reason, "icmp-unreach-") {
+		return 0, false
+	}
+	code, err := strconv.Atoi(strings.TrimPrefix(reason, "icmp-unreach-"))
+	return code, err == nil
+}
+
+// isAdminProhibited 判断ICMP不可达代码是否为管理禁止(9、10、13)
+func isAdminProhibited(code int) bool {
+	return code == 9 || code == 10 || code == 13
+}
+
+// commonReplyTTL 返回指定应答原因中出现次数最多的TTL
+func commonReplyTTL(results []ScanResult, reason string) int {
+	counts := make(map[int]int)
+	best, bestCount := 0, 0
+	for _, r := range results {
+		if r.TTL == 0 || resultReason(r) != reason {
+			continue
+		}
+		counts[r.TTL]++
+		if counts[r.TTL] > bestCount || (counts[r.TTL] == bestCount && r.TTL < best) {
+			best, bestCount = r.TTL, counts[r.TTL]
+		}
+	}
+	return best
+}
+
+// silentTail 返回按端口排序后末尾连续无应答的端口
+// 要求此前至少有两个端口应答，且末尾连续无应答的端口不少于firewallMinSilentTail个
+func silentTail(results []ScanResult) []int {
+	sorted := append([]ScanResult(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Port < sorted[j].Port })
+
+	last := -1
+	answered := 0
+	for i, r := range sorted {
+		if reason := resultReason(r); reason != "" && reason != "no-response" {
+			last = i
+			answered++
+		}
+	}
+	if answered < 2 || len(sorted)-last-1 < firewallMinSilentTail {
+		return nil
+	}
+
+	var tail []int
+	for _, r := range sorted[last+1:] {
+		tail = append(tail, r.Port)
+	}
+	return tail
+}
+
+// icmpLimitedPorts 检测UDP扫描中的ICMP不可达限速
+// 端口不可达只出现在前面的端口、其后连续无应答时，返回这些无应答的端口
+func icmpLimitedPorts(results []ScanResult) map[int]bool {
+	sorted := append([]ScanResult(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Port < sorted[j].Port })
+
+	unreach := 0
+	limited := make(map[int]bool)
+	for _, r := range sorted {
+		switch resultReason(r) {
+		case "port-unreach":
+			if len(limited) > 0 {
+				// 不可达出现在无应答端口之后，不符合限速特征
+				return nil
+			}
+			unreach++
+		case "timeout", "no-response":
+			if unreach > 0 {
+				limited[r.Port] = true
+			}
+		}
+	}
+	if unreach == 0 || len(limited) < firewallMinSilentTail {
+		return nil
+	}
+	return limited
+}
+
+// sortedPorts 返回排序后的端口列表
+func sortedPorts(ports map[int]bool) []int {
+	list := make([]int, 0, len(ports))
+	for port := range ports {
+		list = append(list, port)
+	}
+	sort.Ints(list)
+	return list
+}
+
+// PrintFirewallReport 打印防火墙分析报告
+func PrintFirewallReport(report *FirewallReport) {
+	fmt.Printf("\n🛡️  防火墙/IDS行为分析: %s\n", report.Target)
+	if report.HostTTL > 0 {
+		fmt.Printf("主机应答TTL: %d\n", report.HostTTL)
+	}
+	fmt.Println(strings.Repeat("─", 60))
+	fmt.Printf("%-8s %-6s %s\n", "端口", "协议", "结论")
+	for _, port := range report.Ports {
+		fmt.Printf("%-8d %-6s %s\n", port.Port, port.Protocol, port.Verdict)
+		for _, evidence := range port.Evidence {
+			fmt.Printf("         └─ [%s] %s\n", evidence.Probe, evidence.Detail)
+		}
+	}
+
+	if len(report.RateLimit) > 0 {
+		fmt.Println("\n⏱️  限速迹象:")
+		for _, evidence := range report.RateLimit {
+			fmt.Printf("  - [%s] %s\n", evidence.Probe, evidence.Detail)
+		}
+	}
+
+	fmt.Println("\n汇总:")
+	for _, verdict := range []FirewallVerdict{FirewallUnfiltered, FirewallStateful, FirewallStateless, FirewallIPS, FirewallFiltered, FirewallUnknown} {
+		if count := report.Summary[verdict]; count > 0 {
+			fmt.Printf("  %-20s %d\n", verdict, count)
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Println("\n未执行的探测:")
+		for _, probe := range DefaultFirewallProbes {
+			if msg, ok := report.Errors[probe]; ok {
+				fmt.Printf("  - %s: %s\n", probe, msg)
+			}
+		}
+		for probe, msg := range report.Errors {
+			if !containsScanType(DefaultFirewallProbes, probe) {
+				fmt.Printf("  - %s: %s\n", probe, msg)
+			}
+		}
+	}
+	fmt.Println("\n注意: 结论基于应答特征推断，仅供参考。")
+}
+
+// containsScanType 判断列表中是否包含指定扫描类型
+func containsScanType(list []ScanType, scanType ScanType) bool {
+	for _, t := range list {
+		if t == scanType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probeResult 构造带应答原因的扫描结果
+func probeResult(port int, state PortState, reason string, ttl int) ScanResult {
+	return ScanResult{Port: port, State: state, TTL: ttl, Metadata: map[string]interface{}{"reason": reason}}
+}
+
+// verdicts 将报告整理为端口到结论的映射
+func verdicts(report *FirewallReport, protocol string) map[int]FirewallVerdict {
+	out := make(map[int]FirewallVerdict)
+	for _, port := range report.Ports {
+		if port.Protocol == protocol {
+			out[port.Port] = port.Verdict
+		}
+	}
+	return out
+}
+
+func TestAnalyzeFirewallVerdicts(t *testing.T) {
+	report := AnalyzeFirewall("192.0.2.10", map[ScanType][]ScanResult{
+		ScanTypeSYN: {
+			probeResult(22, PortStateOpen, "syn-ack", 52),
+			probeResult(23, PortStateClosed, "reset", 52),
+			probeResult(25, PortStateFiltered, "no-response", 0),
+			probeResult(80, PortStateOpen, "syn-ack", 52),
+			probeResult(113, PortStateFiltered, "icmp-unreach-13", 60),
+			probeResult(139, PortStateClosed, "reset", 255),
+			probeResult(443, PortStateOpen, "syn-ack", 52),
+		},
+		ScanTypeACK: {
+			probeResult(22, PortStateClosed, "reset", 52),
+			probeResult(23, PortStateClosed, "reset", 52),
+			probeResult(25, PortStateClosed, "reset", 52),
+			probeResult(80, PortStateFiltered, "no-response", 0),
+			probeResult(113, PortStateFiltered, "no-response", 0),
+			probeResult(139, PortStateClosed, "reset", 255),
+			probeResult(443, PortStateOpen, "reset", 52),
+		},
+		ScanTypeTCP: {
+			{Port: 22, State: PortStateOpen},
+			{Port: 443, State: PortStateFiltered},
+		},
+	})
+
+	assert.Equal(t, 52, report.HostTTL)
+	assert.Equal(t, map[int]FirewallVerdict{
+		22:  FirewallUnfiltered,
+		23:  FirewallUnfiltered,
+		25:  FirewallStateless,
+		80:  FirewallStateful,
+		113: FirewallStateful,
+		139: FirewallStateful,
+		443: FirewallIPS,
+	}, verdicts(report, "tcp"))
+	assert.Equal(t, 2, report.Summary[FirewallUnfiltered])
+	assert.Equal(t, 3, report.Summary[FirewallStateful])
+
+	// 每个结论都附带证据
+	for _, port := range report.Ports {
+		assert.NotEmpty(t, port.Evidence, "端口 %d 缺少证据", port.Port)
+	}
+	evidence := func(port int) string {
+		var out string
+		for _, p := range report.Ports {
+			if p.Port == port {
+				for _, e := range p.Evidence {
+					out += e.Detail + "\n"
+				}
+			}
+		}
+		return out
+	}
+	assert.Contains(t, evidence(113), "管理禁止(代码13)")
+	assert.Contains(t, evidence(139), "RST的TTL为255")
+	assert.Contains(t, evidence(443), "IPS")
+}
+
+func TestAnalyzeFirewallStealthProbes(t *testing.T) {
+	report := AnalyzeFirewall("192.0.2.10", map[ScanType][]ScanResult{
+		ScanTypeSYN: {
+			probeResult(23, PortStateClosed, "reset", 64),
+			probeResult(25, PortStateFiltered, "no-response", 0),
+			probeResult(26, PortStateFiltered, "no-response", 0),
+		},
+		ScanTypeFIN: {
+			probeResult(23, PortStateOpen, "no-response", 0),
+			probeResult(25, PortStateClosed, "reset", 64),
+			probeResult(26, PortStateOpen, "no-response", 0),
+		},
+	})
+
+	assert.Equal(t, map[int]FirewallVerdict{
+		23: FirewallStateful,  // 关闭端口对FIN无应答
+		25: FirewallStateless, // SYN被拦截但FIN到达主机
+		26: FirewallFiltered,  // 没有ACK探测，无法区分
+	}, verdicts(report, "tcp"))
+}
+
+func TestAnalyzeFirewallRateLimit(t *testing.T) {
+	var syn, connect []ScanResult
+	for port := 1; port <= 8; port++ {
+		connect = append(connect, ScanResult{Port: port, State: PortStateClosed})
+		if port <= 3 {
+			syn = append(syn, probeResult(port, PortStateClosed, "reset", 64))
+		} else {
+			syn = append(syn, probeResult(port, PortStateFiltered, "no-response", 0))
+		}
+	}
+
+	report := AnalyzeFirewall("192.0.2.10", map[ScanType][]ScanResult{
+		ScanTypeSYN: syn,
+		ScanTypeTCP: connect,
+	})
+	require.Len(t, report.RateLimit, 1)
+	assert.Contains(t, report.RateLimit[0].Detail, "从端口 4 起连续 5 个端口")
+	assert.Equal(t, 5, report.Summary[FirewallIPS])
+	assert.Equal(t, 3, report.Summary[FirewallUnfiltered])
+
+	// 被过滤的高端口对完整连接同样无应答时不视为限速
+	for i := range connect[3:] {
+		connect[3+i].State = PortStateFiltered
+	}
+	report = AnalyzeFirewall("192.0.2.10", map[ScanType][]ScanResult{
+		ScanTypeSYN: syn,
+		ScanTypeTCP: connect,
+	})
+	assert.Empty(t, report.RateLimit)
+	assert.Zero(t, report.Summary[FirewallIPS])
+}
+
+func TestAnalyzeFirewallUDP(t *testing.T) {
+	udp := []ScanResult{
+		probeResult(53, PortStateOpen, "got-response", 0),
+		probeResult(1000, PortStateClosed, "port-unreach", 0),
+	}
+	for port := 1001; port <= 1004; port++ {
+		udp = append(udp, probeResult(port, PortStateFiltered, "timeout", 0))
+	}
+
+	report := AnalyzeFirewall("192.0.2.10", map[ScanType][]ScanResult{ScanTypeUDP: udp})
+	assert.Equal(t, FirewallUnfiltered, verdicts(report, "udp")[53])
+	assert.Equal(t, FirewallUnfiltered, verdicts(report, "udp")[1000])
+	assert.Equal(t, FirewallUnknown, verdicts(report, "udp")[1003])
+	require.Len(t, report.RateLimit, 1)
+	assert.Equal(t, ScanTypeUDP, report.RateLimit[0].Probe)
+
+	// 不可达出现在无应答端口之后，不符合限速特征
+	udp = append(udp, probeResult(2000, PortStateClosed, "port-unreach", 0))
+	report = AnalyzeFirewall("192.0.2.10", map[ScanType][]ScanResult{ScanTypeUDP: udp})
+	assert.Empty(t, report.RateLimit)
+}
+
+func TestDetectFirewallRequiresTarget(t *testing.T) {
+	_, err := DetectFirewall(&ScanOptions{Ports: "80"}, nil, "")
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+}
@@ -0,0 +1,161 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+)
+
+// 蜜罐评分各信号的权重，总分上限为100
+const (
+	honeypotWeightManyOpen   = 30 // 开放端口数量异常
+	honeypotWeightOpenRatio  = 30 // 几乎所有探测端口都开放
+	honeypotWeightSameBanner = 25 // 多个端口返回相同banner
+	honeypotWeightSignature  = 30 // banner匹配已知蜜罐签名，真实服务也可能使用相同banner，单独不足以达到默认阈值
+	honeypotWeightZeroWindow = 40 // SYN/ACK窗口为0的tarpit
+)
+
+// 蜜罐评分阈值
+const (
+	honeypotManyOpenPorts    = 50 // 正常主机很少开放这么多端口
+	honeypotSameBannerMin    = 3  // 相同banner的最少端口数
+	DefaultHoneypotThreshold = 50 // 判定为疑似蜜罐的默认分数
+)
+
+// HoneypotScore 主机的蜜罐/tarpit可能性评分
+type HoneypotScore struct {
+	Score   int      `json:"score" xml:"score"`                      // 0-100，越高越可能是蜜罐
+	Reasons []string `json:"reasons,omitempty" xml:"reasons>reason"` // 评分依据
+}
+
+// Exceeds 判断评分是否达到阈值，threshold<=0表示不过滤
+func (s *HoneypotScore) Exceeds(threshold int) bool {
+	return s != nil && threshold > 0 && s.Score >= threshold
+}
+
+// String 返回评分摘要
+func (s *HoneypotScore) String() string {
+	if s == nil {
+		return "0/100"
+	}
+	return fmt.Sprintf("%d/100", s.Score)
+}
+
+// ScoreHoneypot 根据单个主机的端口结果计算蜜罐可能性
+// 信号包括开放端口数量异常、多个端口banner相同、banner匹配已知蜜罐签名以及零窗口tarpit
+func ScoreHoneypot(results []ScanResult) *HoneypotScore {
+	score := &HoneypotScore{}
+	add := func(weight int, format string, args ...interface{}) {
+		score.Score += weight
+		score.Reasons = append(score.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	var open []ScanResult
+	for _, r := range results {
+		if r.State == PortStateOpen {
+			open = append(open, r)
+		}
+	}
+
+	// 开放端口数量
+	if len(open) >= honeypotManyOpenPorts {
+		add(honeypotWeightManyOpen, "开放 %d 个端口，远超正常主机", len(open))
+	}
+	if giveUp := GiveUpFromResults("", results); giveUp != nil && giveUp.Reason == GiveUpReasonTarpit {
+		add(honeypotWeightOpenRatio, "扫描因tarpit特征被放弃: %s", giveUp.Detail)
+	} else if len(results) >= tarpitMinSamples && float64(len(open))/float64(len(results)) >= tarpitOpenRatio {
+		add(honeypotWeightOpenRatio, "探测的 %d 个端口中 %d 个开放", len(results), len(open))
+	}
+
+	// 多个端口返回相同banner
+	banners := make(map[string][]int)
+	for _, r := range open {
+		if banner := strings.TrimSpace(resultBanner(r)); banner != "" {
+			banners[banner] = append(banners[banner], r.Port)
+		}
+	}
+	var sameBanner []int
+	for _, ports := range banners {
+		if len(ports) >= honeypotSameBannerMin && len(ports) > len(sameBanner) {
+			sameBanner = ports
+		}
+	}
+	if len(sameBanner) > 0 {
+		sort.Ints(sameBanner)
+		add(honeypotWeightSameBanner, "%d 个端口返回相同banner(端口 %s)", len(sameBanner), joinPortsToString(limitPorts(sameBanner, 10)))
+	}
+
+	// 已知蜜罐签名，同一产品只计一次
+	matched := make(map[string]bool)
+	for _, r := range open {
+		sig, ok := fingerprint.MatchHoneypotBanner(resultBanner(r))
+		if !ok && r.Service != nil && strings.Contains(strings.ToLower(r.Service.Product), "honeypot") {
+			sig, ok = &fingerprint.BannerSignature{Product: r.Service.Product}, true
+		}
+		if ok && !matched[sig.Product] {
+			matched[sig.Product] = true
+			add(honeypotWeightSignature, "端口 %d 匹配蜜罐签名: %s", r.Port, sig.Product)
+		}
+	}
+
+	// SYN/ACK窗口为0：LaBrea类tarpit接受连接后不接收数据
+	var zeroWindow []int
+	for _, r := range open {
+		if window, ok := metadataInt(r.Metadata, "window"); ok && window == 0 {
+			zeroWindow = append(zeroWindow, r.Port)
+		}
+	}
+	if len(zeroWindow) > 0 {
+		add(honeypotWeightZeroWindow, "%d 个开放端口的SYN/ACK窗口为0(端口 %s)，符合tarpit特征",
+			len(zeroWindow), joinPortsToString(limitPorts(zeroWindow, 10)))
+	}
+
+	if score.Score > 100 {
+		score.Score = 100
+	}
+	return score
+}
+
+// resultBanner 返回端口结果中的banner
+func resultBanner(r ScanResult) string {
+	if r.Banner != "" {
+		return r.Banner
+	}
+	if r.Service != nil {
+		return r.Service.Banner
+	}
+	return ""
+}
+
+// metadataInt 读取元数据中的整数，兼容JSON解码后的float64
+func metadataInt(metadata map[string]interface{}, key string) (int, bool) {
+	switch v := metadata[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// limitPorts 截取前n个端口用于展示
+func limitPorts(ports []int, n int) []int {
+	if len(ports) > n {
+		return ports[:n]
+	}
+	return ports
+}
+
+// PrintHoneypotScore 打印蜜罐评分
+func PrintHoneypotScore(score *HoneypotScore) {
+	if score == nil || score.Score == 0 {
+		return
+	}
+	fmt.Printf("🍯 蜜罐/tarpit可能性: %s\n", score)
+	for _, reason := range score.Reasons {
+		fmt.Printf("   - %s\n", reason)
+	}
+}
@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreHoneypotCleanHost(t *testing.T) {
+	score := ScoreHoneypot([]ScanResult{
+		{Port: 22, State: PortStateOpen, Banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13"},
+		{Port: 80, State: PortStateOpen, Metadata: map[string]interface{}{"window": 64240}},
+		{Port: 443, State: PortStateClosed},
+	})
+	assert.Zero(t, score.Score)
+	assert.Empty(t, score.Reasons)
+	assert.False(t, score.Exceeds(DefaultHoneypotThreshold))
+}
+
+func TestScoreHoneypotManyOpenSameBanner(t *testing.T) {
+	var results []ScanResult
+	for port := 1; port <= 60; port++ {
+		results = append(results, ScanResult{Port: port, State: PortStateOpen, Banner: "220 Service ready\r\n"})
+	}
+
+	score := ScoreHoneypot(results)
+	assert.Equal(t, honeypotWeightManyOpen+honeypotWeightOpenRatio+honeypotWeightSameBanner, score.Score)
+	require.Len(t, score.Reasons, 3)
+	assert.Contains(t, score.Reasons[0], "开放 60 个端口")
+	assert.Contains(t, score.Reasons[2], "60 个端口返回相同banner")
+	assert.True(t, score.Exceeds(DefaultHoneypotThreshold))
+	assert.False(t, score.Exceeds(0), "阈值为0时不过滤")
+}
+
+func TestScoreHoneypotSignature(t *testing.T) {
+	score := ScoreHoneypot([]ScanResult{
+		{Port: 22, State: PortStateOpen, Banner: "SSH-2.0-OpenSSH_6.0p1 Debian-4+deb7u2\r\n"},
+		{Port: 2222, State: PortStateOpen, Banner: "SSH-2.0-OpenSSH_6.0p1 Debian-4+deb7u2\r\n"},
+	})
+	assert.Equal(t, honeypotWeightSignature, score.Score, "同一产品只计一次")
+	require.Len(t, score.Reasons, 1)
+	assert.Contains(t, score.Reasons[0], "Cowrie")
+	assert.False(t, score.Exceeds(DefaultHoneypotThreshold), "单个签名不足以判定为蜜罐")
+
+	// 服务识别结果中的产品名同样作为签名
+	score = ScoreHoneypot([]ScanResult{
+		{Port: 502, State: PortStateOpen, Service: &fingerprint.Service{Name: "modbus", Product: "Conpot ICS honeypot"}},
+	})
+	assert.Equal(t, honeypotWeightSignature, score.Score)
+}
+
+func TestScoreHoneypotZeroWindowTarpit(t *testing.T) {
+	score := ScoreHoneypot([]ScanResult{
+		{Port: 80, State: PortStateOpen, Metadata: map[string]interface{}{"reason": "syn-ack", "window": 0}},
+		{Port: 443, State: PortStateOpen, Metadata: map[string]interface{}{"reason": "syn-ack", "window": float64(0)}},
+		{Port: 8080, State: PortStateClosed, Metadata: map[string]interface{}{"reason": "reset", "window": 0}},
+	})
+	assert.Equal(t, honeypotWeightZeroWindow, score.Score)
+	require.Len(t, score.Reasons, 1)
+	assert.Contains(t, score.Reasons[0], "端口 80,443")
+}
+
+func TestScoreHoneypotCapped(t *testing.T) {
+	var results []ScanResult
+	for port := 1; port <= 60; port++ {
+		results = append(results, ScanResult{
+			Port:     port,
+			State:    PortStateOpen,
+			Banner:   "SSH-2.0-OpenSSH_5.1p1 Debian-5\r\n",
+			Metadata: map[string]interface{}{"window": 0},
+		})
+	}
+	score := ScoreHoneypot(results)
+	assert.Equal(t, 100, score.Score)
+	assert.Len(t, score.Reasons, 5)
+}
@@ -15,6 +15,7 @@ type HostStatus struct {
 	Up      bool          // 是否存活
 	Method  string        // 发现方法
 	Latency time.Duration // 延迟时间
+	MAC     string        // MAC地址，仅ARP发现时可获得
 }
 
 // DiscoveryOptions 主机发现选项
@@ -27,6 +28,7 @@ type DiscoveryOptions struct {
 	Concurrency int           // 并发数
 	SkipPing    bool          // 是否跳过Ping扫描（类似nmap -Pn）
 	ExcludeIPs  []string      // 要排除的IP地址
+	Observers   []Observer    // 主机发现事件观察者
 }
 
 // DefaultDiscoveryOptions 默认主机发现选项
@@ -63,6 +65,10 @@ func DiscoverHosts(networks []string, opts *DiscoveryOptions) ([]HostStatus, err
 	// 过滤掉要排除的IP
 	allIPs = filterExcludedIPs(allIPs, opts.ExcludeIPs)
 
+	events := newEventBus(strings.Join(networks, ","), "", opts.Observers)
+	events.started(len(allIPs))
+	defer events.finished()
+
 	// 创建工作任务
 	for _, ip := range allIPs {
 		wg.Add(1)
@@ -123,13 +129,14 @@ func DiscoverHosts(networks []string, opts *DiscoveryOptions) ([]HostStatus, err
 			}
 
 			if opts.ARPScan {
-				isUp, _ := scanARP(ip)
-				if isUp {
+				mac, _ := scanARP(ip)
+				if mac != "" {
 					resultsChan <- HostStatus{
 						IP:      ip,
 						Up:      true,
 						Method:  "ARP",
 						Latency: 0,
+						MAC:     mac,
 					}
 					return
 				}
@@ -151,10 +158,15 @@ func DiscoverHosts(networks []string, opts *DiscoveryOptions) ([]HostStatus, err
 	}()
 
 	// 收集结果
+	completed := 0
 	for result := range resultsChan {
 		if result.Up {
 			results = append(results, result)
+			host := result
+			events.emit(ScanEvent{Type: EventHostUp, Target: host.IP, Host: &host})
 		}
+		completed++
+		events.progress(completed, len(allIPs))
 	}
 
 	return results, nil
@@ -191,10 +203,14 @@ func PrintHosts(hosts []HostStatus) {
 	if len(hosts) > 0 {
 		fmt.Println("\n活跃主机：")
 		for _, host := range hosts {
-			fmt.Printf("IP: %-15s 方法: %-10s 延迟: %v\n",
+			fmt.Printf("IP: %-15s 方法: %-10s 延迟: %v",
 				host.IP,
 				host.Method,
 				host.Latency)
+			if host.MAC != "" {
+				fmt.Printf(" MAC: %s", host.MAC)
+			}
+			fmt.Println()
 		}
 	} else {
 		fmt.Println("\n未发现活跃主机")
@@ -286,24 +302,40 @@ func pingTCP(target string, port int, timeout time.Duration) (bool, time.Duratio
 	return true, latency, nil
 }
 
-// scanARP 使用ARP扫描检测本地网络主机
+// scanARP 使用ARP扫描检测本地网络主机，返回主机的MAC地址，主机不在ARP表中时返回空字符串
 // 这只能在具有root权限和对目标网络有直接访问权的情况下工作
-func scanARP(target string) (bool, error) {
+func scanARP(target string) (string, error) {
 	// 这是一个简化版实现，实际上应该使用类似gopacket等库来构建和发送ARP请求
 	// 由于需要系统权限，这里使用arp系统命令作为示例
 	cmd := exec.Command("arp", "-n", target)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return false, err
+		return "", err
 	}
+	return parseARPOutput(string(output)), nil
+}
 
-	// 分析输出查找MAC地址
-	outputStr := string(output)
-	if !strings.Contains(outputStr, "no entry") && strings.Contains(outputStr, ":") {
-		return true, nil
+// parseARPOutput 从arp命令的输出中提取MAC地址
+func parseARPOutput(output string) string {
+	if strings.Contains(output, "no entry") {
+		return ""
 	}
-
-	return false, nil
+	for _, field := range strings.Fields(output) {
+		// macOS的arp输出省略前导零，如 0:11:22:3:44:55
+		parts := strings.Split(field, ":")
+		if len(parts) != 6 {
+			continue
+		}
+		for i, part := range parts {
+			if len(part) == 1 {
+				parts[i] = "0" + part
+			}
+		}
+		if mac, err := net.ParseMAC(strings.Join(parts, ":")); err == nil {
+			return mac.String()
+		}
+	}
+	return ""
 }
 
 // GenerateIPRange 生成IP地址范围
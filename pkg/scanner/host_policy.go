@@ -0,0 +1,176 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 放弃主机的原因
+const (
+	GiveUpReasonHostTimeout = "host-timeout" // 超过单主机超时时间
+	GiveUpReasonTarpit      = "tarpit"       // 疑似tarpit主机
+)
+
+// tarpit检测参数：采样足够多端口后，几乎全部开放即视为tarpit
+const (
+	tarpitMinSamples = 20
+	tarpitOpenRatio  = 0.9
+)
+
+// HostGiveUp 主机被放弃扫描的记录
+type HostGiveUp struct {
+	Target string    `json:"target"` // 目标地址
+	Reason string    `json:"reason"` // 放弃原因
+	Detail string    `json:"detail"` // 详细说明
+	Time   time.Time `json:"time"`   // 放弃时间
+}
+
+// tarpitDetector 检测"所有端口均开放"的tarpit特征
+type tarpitDetector struct {
+	total int
+	open  int
+}
+
+// observe 记录一个端口结果，返回是否判定为tarpit
+func (d *tarpitDetector) observe(state PortState) bool {
+	if state == PortStateUnknown {
+		return false
+	}
+	d.total++
+	if state == PortStateOpen {
+		d.open++
+	}
+	return d.total >= tarpitMinSamples && float64(d.open)/float64(d.total) >= tarpitOpenRatio
+}
+
+// hostGuard 单主机放弃策略：主机超时与tarpit检测
+type hostGuard struct {
+	target string
+	opts   *ScanOptions
+	parent context.Context
+	cancel context.CancelFunc
+	tarpit tarpitDetector
+	giveUp *HostGiveUp
+	mu     sync.Mutex
+}
+
+// newHostGuard 创建主机放弃策略，返回受策略控制的上下文
+func newHostGuard(ctx context.Context, opts *ScanOptions) (context.Context, *hostGuard) {
+	g := &hostGuard{target: opts.Target, opts: opts, parent: ctx}
+
+	var hostCtx context.Context
+	if opts.HostTimeout > 0 {
+		hostCtx, g.cancel = context.WithTimeout(ctx, opts.HostTimeout)
+	} else {
+		hostCtx, g.cancel = context.WithCancel(ctx)
+	}
+	return hostCtx, g
+}
+
+// observe 记录端口结果，触发tarpit判定时放弃主机
+func (g *hostGuard) observe(result ScanResult) {
+	if !g.opts.DetectTarpit {
+		return
+	}
+
+	g.mu.Lock()
+	isTarpit := g.tarpit.observe(result.State)
+	total, open := g.tarpit.total, g.tarpit.open
+	g.mu.Unlock()
+
+	if isTarpit {
+		g.abandon(GiveUpReasonTarpit, fmt.Sprintf("已探测的 %d 个端口中 %d 个开放，疑似tarpit", total, open))
+	}
+}
+
+// abandon 放弃主机并停止后续探测，只记录第一次放弃的原因
+func (g *hostGuard) abandon(reason, detail string) {
+	g.mu.Lock()
+	if g.giveUp == nil {
+		g.giveUp = &HostGiveUp{Target: g.target, Reason: reason, Detail: detail, Time: time.Now()}
+	}
+	g.mu.Unlock()
+	g.cancel()
+}
+
+// finish 结束主机扫描，返回放弃记录(未放弃时为nil)
+func (g *hostGuard) finish(hostCtx context.Context) *HostGiveUp {
+	// 主机上下文超时而外部上下文仍有效，说明触发了单主机超时
+	if errors.Is(hostCtx.Err(), context.DeadlineExceeded) && g.parent.Err() == nil {
+		g.abandon(GiveUpReasonHostTimeout, fmt.Sprintf("超过单主机超时时间 %v", g.opts.HostTimeout))
+	}
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.giveUp
+}
+
+// retryPort 按MaxRetries重试被过滤(超时)的端口
+func retryPort(ctx context.Context, maxRetries int, probe func() (ScanResult, error)) (ScanResult, error) {
+	result, err := probe()
+	retries := 0
+	for err == nil && result.State == PortStateFiltered && retries < maxRetries && ctx.Err() == nil {
+		retries++
+		result, err = probe()
+	}
+	if err == nil && retries > 0 {
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+		result.Metadata["retries"] = retries
+	}
+	return result, err
+}
+
+// markAbandoned 在结果中标记主机被放弃的原因
+func markAbandoned(result *ScanResult, giveUp *HostGiveUp) {
+	if giveUp == nil {
+		return
+	}
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["host_abandoned"] = true
+	result.Metadata["abandon_reason"] = giveUp.Reason
+	result.Metadata["abandon_detail"] = giveUp.Detail
+}
+
+// GiveUpFromResults 从扫描结果中提取主机放弃记录
+func GiveUpFromResults(target string, results []ScanResult) *HostGiveUp {
+	for _, r := range results {
+		if abandoned, _ := r.Metadata["host_abandoned"].(bool); !abandoned {
+			continue
+		}
+		reason, _ := r.Metadata["abandon_reason"].(string)
+		detail, _ := r.Metadata["abandon_detail"].(string)
+		return &HostGiveUp{Target: target, Reason: reason, Detail: detail}
+	}
+	return nil
+}
+
+// giveUpRecorder 收集扫描过程中的主机放弃事件，供扫描建议器使用
+type giveUpRecorder struct {
+	mu      sync.Mutex
+	giveUps []*HostGiveUp
+}
+
+// OnEvent 实现Observer接口
+func (r *giveUpRecorder) OnEvent(event ScanEvent) {
+	if event.Type != EventHostAbandoned || event.GiveUp == nil {
+		return
+	}
+	r.mu.Lock()
+	r.giveUps = append(r.giveUps, event.GiveUp)
+	r.mu.Unlock()
+}
+
+// list 返回已收集的放弃记录
+func (r *giveUpRecorder) list() []*HostGiveUp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*HostGiveUp(nil), r.giveUps...)
+}
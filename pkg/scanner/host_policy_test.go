@@ -0,0 +1,171 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarpitDetector(t *testing.T) {
+	d := &tarpitDetector{}
+	for i := 0; i < tarpitMinSamples-1; i++ {
+		assert.False(t, d.observe(PortStateOpen))
+	}
+	assert.True(t, d.observe(PortStateOpen))
+
+	// 关闭端口占比足够时不应判定为tarpit
+	d = &tarpitDetector{}
+	for i := 0; i < 40; i++ {
+		state := PortStateOpen
+		if i%2 == 0 {
+			state = PortStateClosed
+		}
+		assert.False(t, d.observe(state))
+	}
+}
+
+func TestRetryPort(t *testing.T) {
+	probe := func(states ...PortState) func() (ScanResult, error) {
+		i := 0
+		return func() (ScanResult, error) {
+			state := states[min(i, len(states)-1)]
+			i++
+			return ScanResult{Port: 80, State: state}, nil
+		}
+	}
+
+	result, err := retryPort(context.Background(), 3, probe(PortStateFiltered, PortStateFiltered, PortStateOpen))
+	require.NoError(t, err)
+	assert.Equal(t, PortStateOpen, result.State)
+	assert.Equal(t, 2, result.Metadata["retries"])
+
+	result, err = retryPort(context.Background(), 1, probe(PortStateFiltered))
+	require.NoError(t, err)
+	assert.Equal(t, PortStateFiltered, result.State)
+	assert.Equal(t, 1, result.Metadata["retries"])
+
+	result, err = retryPort(context.Background(), 0, probe(PortStateFiltered, PortStateOpen))
+	require.NoError(t, err)
+	assert.Equal(t, PortStateFiltered, result.State)
+	assert.Nil(t, result.Metadata)
+}
+
+func TestScannerAbandonsTarpit(t *testing.T) {
+	// 模拟所有端口都开放的tarpit主机
+	var ports []string
+	for i := 0; i < tarpitMinSamples+10; i++ {
+		ports = append(ports, strconv.Itoa(listenLocal(t)))
+	}
+
+	s, err := NewScanner(&ScanOptions{
+		Target:       "127.0.0.1",
+		Ports:        strings.Join(ports, ","),
+		ScanType:     ScanTypeTCP,
+		Timeout:      time.Second,
+		Workers:      1,
+		DetectTarpit: true,
+	})
+	require.NoError(t, err)
+
+	results, err := s.Scan(context.Background())
+	require.NoError(t, err)
+	assert.Less(t, len(results), len(ports))
+
+	giveUp := s.GiveUp()
+	require.NotNil(t, giveUp)
+	assert.Equal(t, GiveUpReasonTarpit, giveUp.Reason)
+	for _, r := range results {
+		assert.Equal(t, true, r.Metadata["host_abandoned"])
+		assert.Equal(t, GiveUpReasonTarpit, r.Metadata["abandon_reason"])
+	}
+}
+
+func TestScannerHostTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	s, err := NewScanner(&ScanOptions{
+		Target:      "127.0.0.1",
+		Ports:       strconv.Itoa(port),
+		ScanType:    ScanTypeTCP,
+		Timeout:     time.Second,
+		Workers:     1,
+		HostTimeout: time.Nanosecond,
+	})
+	require.NoError(t, err)
+
+	_, err = s.Scan(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, s.GiveUp())
+	assert.Equal(t, GiveUpReasonHostTimeout, s.GiveUp().Reason)
+}
+
+func TestScannerNoGiveUp(t *testing.T) {
+	port := listenLocal(t)
+	s, err := NewScanner(&ScanOptions{
+		Target:       "127.0.0.1",
+		Ports:        strconv.Itoa(port),
+		ScanType:     ScanTypeTCP,
+		Timeout:      time.Second,
+		Workers:      1,
+		HostTimeout:  time.Minute,
+		DetectTarpit: true,
+	})
+	require.NoError(t, err)
+
+	results, err := s.Scan(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Nil(t, s.GiveUp())
+	assert.Nil(t, results[0].Metadata["host_abandoned"])
+}
+
+func TestScanAdvisorPolicyWarnings(t *testing.T) {
+	advisor, err := NewScanAdvisor(&ScanOptions{
+		Target:      "127.0.0.1",
+		Ports:       "1-1000",
+		Timeout:     time.Second,
+		Workers:     1,
+		HostTimeout: time.Second,
+	})
+	require.NoError(t, err)
+
+	// 预估时间超过单主机超时时给出预警
+	assert.Contains(t, strings.Join(advisor.AnalyzeAndSuggest(), "\n"), "单主机超时")
+
+	results := []ScanResult{
+		{Port: 80, State: PortStateOpen, Metadata: map[string]interface{}{"retries": 2}},
+		{Port: 81, State: PortStateClosed},
+	}
+	giveUps := []*HostGiveUp{
+		{Target: "10.0.0.1", Reason: GiveUpReasonTarpit, Detail: "30个端口全部开放"},
+		{Target: "10.0.0.2", Reason: GiveUpReasonHostTimeout, Detail: "超时"},
+	}
+
+	warnings := advisor.PolicyWarnings(results, giveUps)
+	require.Len(t, warnings, 3)
+	assert.Contains(t, warnings[0], "tarpit")
+	assert.Contains(t, warnings[1], "10.0.0.2")
+	assert.Contains(t, warnings[2], "1 个端口经过重试")
+
+	assert.Empty(t, advisor.PolicyWarnings(nil, nil))
+}
+
+func TestGiveUpFromResults(t *testing.T) {
+	results := []ScanResult{{Port: 80}}
+	assert.Nil(t, GiveUpFromResults("10.0.0.1", results))
+
+	markAbandoned(&results[0], &HostGiveUp{Reason: GiveUpReasonTarpit, Detail: "d"})
+	giveUp := GiveUpFromResults("10.0.0.1", results)
+	require.NotNil(t, giveUp)
+	assert.Equal(t, "10.0.0.1", giveUp.Target)
+	assert.Equal(t, GiveUpReasonTarpit, giveUp.Reason)
+}
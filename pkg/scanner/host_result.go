@@ -0,0 +1,536 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+)
+
+// HostState 主机存活状态
+type HostState string
+
+const (
+	HostStateUp      HostState = "up"      // 主机存活
+	HostStateDown    HostState = "down"    // 主机不可达
+	HostStateUnknown HostState = "unknown" // 没有足够的信息判断
+)
+
+// 端口协议
+const (
+	ProtocolTCP  = "tcp"
+	ProtocolUDP  = "udp"
+	ProtocolSCTP = "sctp"
+)
+
+// PortService 端口上识别出的服务
+type PortService struct {
+	Name        string               `json:"name" xml:"name,attr"`                                  // 服务名称
+	Product     string               `json:"product,omitempty" xml:"product,attr,omitempty"`        // 产品名称
+	Version     string               `json:"version,omitempty" xml:"version,attr,omitempty"`        // 版本
+	DeviceType  string               `json:"device_type,omitempty" xml:"devicetype,attr,omitempty"` // 设备类型
+	Confidence  float64              `json:"confidence,omitempty" xml:"conf,attr,omitempty"`        // 置信度
+	CPE         []string             `json:"cpe,omitempty" xml:"cpe,omitempty"`                     // CPE标识
+	Fingerprint string               `json:"fingerprint,omitempty" xml:"servicefp,attr,omitempty"`  // 未识别服务的SF-Port指纹记录
+	TLS         *fingerprint.TLSInfo `json:"tls,omitempty" xml:"tls,omitempty"`                     // TLS检查结果
+}
+
+// PortResult 主机上单个端口的扫描结果
+type PortResult struct {
+	Port     int                    `json:"port" xml:"portid,attr"`                    // 端口号
+	Protocol string                 `json:"protocol" xml:"protocol,attr"`              // 协议(tcp/udp/sctp)
+	State    PortState              `json:"state" xml:"state"`                         // 端口状态
+	Reason   string                 `json:"reason,omitempty" xml:"reason,omitempty"`   // 状态判定依据
+	TTL      int                    `json:"ttl,omitempty" xml:"ttl,omitempty"`         // 应答报文TTL
+	ScanType ScanType               `json:"scan_type,omitempty" xml:"scantype,attr"`   // 得出该结果的扫描类型
+	Service  *PortService           `json:"service,omitempty" xml:"service,omitempty"` // 服务信息
+	Banner   string                 `json:"banner,omitempty" xml:"banner,omitempty"`   // 服务banner
+	Metadata map[string]interface{} `json:"metadata,omitempty" xml:"-"`                // 扫描引擎记录的原始信息
+}
+
+// OSMatch 操作系统匹配结果
+type OSMatch struct {
+	Name     string            `json:"name" xml:"name,attr"`                      // 操作系统名称
+	Family   string            `json:"family,omitempty" xml:"family,omitempty"`   // 操作系统家族
+	Version  string            `json:"version,omitempty" xml:"version,omitempty"` // 版本
+	Accuracy float64           `json:"accuracy" xml:"accuracy,attr"`              // 置信度
+	CPE      []string          `json:"cpe,omitempty" xml:"cpe,omitempty"`         // CPE标识
+	Metadata map[string]string `json:"metadata,omitempty" xml:"-"`                // 识别依据等附加信息
+}
+
+// HostTiming 主机扫描计时
+type HostTiming struct {
+	Start    time.Time     `json:"start" xml:"start,attr"`                 // 开始时间
+	End      time.Time     `json:"end" xml:"end,attr"`                     // 结束时间
+	Duration time.Duration `json:"duration" xml:"duration,attr"`           // 扫描耗时
+	RTT      time.Duration `json:"rtt,omitempty" xml:"rtt,attr,omitempty"` // 主机发现测得的往返时延
+}
+
+// HostResult 以主机为中心的扫描结果
+// 汇总主机发现、各协议端口扫描、服务与OS识别等阶段的数据，是各输出格式、API和MCP共用的结果模型。
+type HostResult struct {
+	Addresses []string       `json:"addresses" xml:"address"`                                // IP地址
+	Hostnames []string       `json:"hostnames,omitempty" xml:"hostnames>hostname,omitempty"` // 主机名
+	MAC       string         `json:"mac,omitempty" xml:"mac,omitempty"`                      // MAC地址
+	Status    HostState      `json:"status" xml:"status"`                                    // 存活状态
+	Reason    string         `json:"reason,omitempty" xml:"reason,omitempty"`                // 状态判定依据
+	TCP       []PortResult   `json:"tcp,omitempty" xml:"ports>tcp,omitempty"`                // TCP端口
+	UDP       []PortResult   `json:"udp,omitempty" xml:"ports>udp,omitempty"`                // UDP端口
+	SCTP      []PortResult   `json:"sctp,omitempty" xml:"ports>sctp,omitempty"`              // SCTP端口
+	OS        []OSMatch      `json:"os,omitempty" xml:"os>osmatch,omitempty"`                // 操作系统匹配
+	Timing    HostTiming     `json:"timing" xml:"times"`                                     // 计时
+	Honeypot  *HoneypotScore `json:"honeypot,omitempty" xml:"honeypot,omitempty"`            // 蜜罐/tarpit可能性评分
+	GiveUp    *HostGiveUp    `json:"give_up,omitempty" xml:"-"`                              // 主机被放弃的记录
+}
+
+// NewHostResult 为扫描目标创建主机结果，目标为IP时记为地址，否则记为主机名
+func NewHostResult(target string) *HostResult {
+	host := &HostResult{Status: HostStateUnknown}
+	if target == "" {
+		return host
+	}
+	if net.ParseIP(target) != nil {
+		host.Addresses = []string{target}
+	} else {
+		host.Hostnames = []string{target}
+	}
+	return host
+}
+
+// NewHostResultFromStatus 从主机发现结果创建主机结果
+func NewHostResultFromStatus(status HostStatus) *HostResult {
+	host := NewHostResult(status.IP)
+	host.SetStatus(status)
+	return host
+}
+
+// Address 返回主机的首个IP地址，未解析时返回主机名
+func (h *HostResult) Address() string {
+	if len(h.Addresses) > 0 {
+		return h.Addresses[0]
+	}
+	if len(h.Hostnames) > 0 {
+		return h.Hostnames[0]
+	}
+	return ""
+}
+
+// Name 返回用于展示的主机名，没有主机名时返回地址
+func (h *HostResult) Name() string {
+	if len(h.Hostnames) > 0 {
+		return h.Hostnames[0]
+	}
+	return h.Address()
+}
+
+// Resolve 将主机名解析为IP地址，已有地址时不做处理
+func (h *HostResult) Resolve() error {
+	if len(h.Addresses) > 0 || len(h.Hostnames) == 0 {
+		return nil
+	}
+	addrs, err := net.LookupHost(h.Hostnames[0])
+	if err != nil {
+		return fmt.Errorf("解析主机名 %s 失败: %v", h.Hostnames[0], err)
+	}
+	h.Addresses = addrs
+	return nil
+}
+
+// SetStatus 记录主机发现阶段的结果
+func (h *HostResult) SetStatus(status HostStatus) {
+	if status.IP != "" && net.ParseIP(status.IP) != nil && !containsString(h.Addresses, status.IP) {
+		h.Addresses = append(h.Addresses, status.IP)
+	}
+	if status.Up {
+		h.Status = HostStateUp
+	} else {
+		h.Status = HostStateDown
+	}
+	h.Reason = status.Method
+	h.Timing.RTT = status.Latency
+	if status.MAC != "" {
+		h.MAC = status.MAC
+	}
+}
+
+// AddResults 合并一个扫描阶段的端口结果
+// scanType为结果未标注扫描类型时使用的默认值，同一端口的后续结果覆盖先前的状态，后续结果缺少的服务、banner、原因和TTL沿用先前的记录。
+func (h *HostResult) AddResults(scanType ScanType, results []ScanResult) {
+	for _, r := range results {
+		if r.Type == "" {
+			r.Type = scanType
+		}
+		h.addPort(portResultFromScanResult(r))
+
+		if r.OS != nil && r.OS.Name != "" {
+			h.addOS(r.OS)
+		}
+	}
+}
+
+// AddUDPResults 合并UDP扫描器的结果
+func (h *HostResult) AddUDPResults(results []UDPScanResult) {
+	converted := make([]ScanResult, len(results))
+	for i, r := range results {
+		converted[i] = r.toScanResult()
+	}
+	h.AddResults(ScanTypeUDP, converted)
+}
+
+// Clone 复制主机结果，切片使用新的底层数组，修改副本不影响原结果
+func (h *HostResult) Clone() *HostResult {
+	copied := *h
+	copied.Addresses = append([]string(nil), h.Addresses...)
+	copied.Hostnames = append([]string(nil), h.Hostnames...)
+	copied.TCP = append([]PortResult(nil), h.TCP...)
+	copied.UDP = append([]PortResult(nil), h.UDP...)
+	copied.SCTP = append([]PortResult(nil), h.SCTP...)
+	copied.OS = append([]OSMatch(nil), h.OS...)
+	return &copied
+}
+
+// Merge 合并同一主机在其他扫描中得到的结果，如分布式扫描中各端口分片的结果
+func (h *HostResult) Merge(other *HostResult) {
+	if other == nil {
+		return
+	}
+	for _, addr := range other.Addresses {
+		if !containsString(h.Addresses, addr) {
+			h.Addresses = append(h.Addresses, addr)
+		}
+	}
+	for _, name := range other.Hostnames {
+		if !containsString(h.Hostnames, name) {
+			h.Hostnames = append(h.Hostnames, name)
+		}
+	}
+	if h.MAC == "" {
+		h.MAC = other.MAC
+	}
+	// 存活优先，其次是明确的离线结果
+	if other.Status == HostStateUp && h.Status != HostStateUp ||
+		other.Status == HostStateDown && h.Status == HostStateUnknown {
+		h.Status = other.Status
+		h.Reason = other.Reason
+	}
+
+	for _, port := range other.Ports() {
+		h.addPort(port)
+	}
+	for _, match := range other.OS {
+		h.addOSMatch(match)
+	}
+
+	if !other.Timing.Start.IsZero() && (h.Timing.Start.IsZero() || other.Timing.Start.Before(h.Timing.Start)) {
+		h.Timing.Start = other.Timing.Start
+	}
+	if other.Timing.End.After(h.Timing.End) {
+		h.Timing.End = other.Timing.End
+	}
+	if !h.Timing.Start.IsZero() {
+		h.Timing.Duration = h.Timing.End.Sub(h.Timing.Start)
+	}
+	if h.Timing.RTT == 0 {
+		h.Timing.RTT = other.Timing.RTT
+	}
+
+	h.Honeypot = ScoreHoneypot(h.ScanResults())
+	if h.GiveUp == nil {
+		h.GiveUp = other.GiveUp
+	}
+}
+
+// Complete 记录扫描起止时间，并根据端口结果计算蜜罐评分和主机放弃记录
+func (h *HostResult) Complete(start, end time.Time) {
+	h.Timing.Start = start
+	h.Timing.End = end
+	h.Timing.Duration = end.Sub(start)
+
+	results := h.ScanResults()
+	h.Honeypot = ScoreHoneypot(results)
+	h.GiveUp = GiveUpFromResults(h.Address(), results)
+}
+
+// Ports 按TCP、UDP、SCTP顺序返回所有端口结果
+func (h *HostResult) Ports() []PortResult {
+	ports := make([]PortResult, 0, len(h.TCP)+len(h.UDP)+len(h.SCTP))
+	ports = append(ports, h.TCP...)
+	ports = append(ports, h.UDP...)
+	return append(ports, h.SCTP...)
+}
+
+// OpenPorts 返回所有开放端口
+func (h *HostResult) OpenPorts() []PortResult {
+	var open []PortResult
+	for _, p := range h.Ports() {
+		if p.State == PortStateOpen {
+			open = append(open, p)
+		}
+	}
+	return open
+}
+
+// CountPorts 统计指定状态的端口数
+func (h *HostResult) CountPorts(state PortState) int {
+	count := 0
+	for _, p := range h.Ports() {
+		if p.State == state {
+			count++
+		}
+	}
+	return count
+}
+
+// ScanResults 将主机结果展开为逐端口的ScanResult，供仍使用旧模型的调用方使用
+func (h *HostResult) ScanResults() []ScanResult {
+	ports := h.Ports()
+	results := make([]ScanResult, 0, len(ports))
+	for _, p := range ports {
+		r := ScanResult{
+			Port:     p.Port,
+			State:    p.State,
+			Banner:   p.Banner,
+			Open:     p.State == PortStateOpen,
+			Type:     p.ScanType,
+			TTL:      p.TTL,
+			Metadata: p.Metadata,
+		}
+		if p.Service != nil {
+			r.ServiceName = p.Service.Name
+			r.Version = p.Service.Version
+			r.Service = &fingerprint.Service{
+				Name:        p.Service.Name,
+				Product:     p.Service.Product,
+				Version:     p.Service.Version,
+				Protocol:    p.Protocol,
+				DeviceType:  p.Service.DeviceType,
+				CPE:         p.Service.CPE,
+				Banner:      p.Banner,
+				Confidence:  p.Service.Confidence,
+				Fingerprint: p.Service.Fingerprint,
+				TLS:         p.Service.TLS,
+			}
+		}
+		if len(h.OS) > 0 {
+			best := h.OS[0]
+			r.OS = &fingerprint.OSInfo{
+				Name:       best.Name,
+				Family:     best.Family,
+				Version:    best.Version,
+				CPE:        best.CPE,
+				Confidence: best.Accuracy,
+				Metadata:   best.Metadata,
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// addPort 将端口结果合并到对应协议的列表中，保持端口号有序
+func (h *HostResult) addPort(port PortResult) {
+	list := h.portList(port.Protocol)
+	i := sort.Search(len(*list), func(i int) bool { return (*list)[i].Port >= port.Port })
+	if i < len(*list) && (*list)[i].Port == port.Port {
+		previous := (*list)[i]
+		if port.Service == nil {
+			port.Service = previous.Service
+		} else if port.Service.TLS == nil && previous.Service != nil && previous.Service.TLS != nil {
+			// 本次没有TLS检查结果时沿用之前的证书信息
+			service := *port.Service
+			service.TLS = previous.Service.TLS
+			port.Service = &service
+		}
+		if port.Banner == "" {
+			port.Banner = previous.Banner
+		}
+		if port.Reason == "" {
+			port.Reason = previous.Reason
+		}
+		if port.TTL == 0 {
+			port.TTL = previous.TTL
+		}
+		if port.Metadata == nil {
+			port.Metadata = previous.Metadata
+		}
+		(*list)[i] = port
+	} else {
+		*list = append(*list, PortResult{})
+		copy((*list)[i+1:], (*list)[i:])
+		(*list)[i] = port
+	}
+	h.markUp(port)
+}
+
+// portList 返回协议对应的端口列表
+func (h *HostResult) portList(protocol string) *[]PortResult {
+	switch protocol {
+	case ProtocolUDP:
+		return &h.UDP
+	case ProtocolSCTP:
+		return &h.SCTP
+	default:
+		return &h.TCP
+	}
+}
+
+// markUp 收到端口应答时将主机标记为存活
+// TCP的RST和UDP的端口不可达同样说明主机在线，超时或被过滤的端口不能作为依据。
+func (h *HostResult) markUp(port PortResult) {
+	if h.Status == HostStateUp {
+		return
+	}
+	responded := port.State == PortStateOpen
+	if port.State == PortStateClosed && (port.Protocol != ProtocolUDP || port.Reason == "port-unreach") {
+		responded = true
+	}
+	if responded {
+		h.Status = HostStateUp
+		h.Reason = fmt.Sprintf("%s/%d", strings.ToUpper(port.Protocol), port.Port)
+	}
+}
+
+// addOS 记录OS匹配结果，指纹库给出的其余候选按各自的匹配度一并记录
+func (h *HostResult) addOS(info *fingerprint.OSInfo) {
+	h.addOSMatch(OSMatch{
+		Name:     info.Name,
+		Family:   info.Family,
+		Version:  info.Version,
+		Accuracy: info.Confidence,
+		CPE:      info.CPE,
+		Metadata: info.Metadata,
+	})
+	for _, guess := range info.Guesses {
+		metadata := map[string]string{}
+		if guess.Vendor != "" {
+			metadata["vendor"] = guess.Vendor
+		}
+		if guess.DeviceType != "" {
+			metadata["device_type"] = guess.DeviceType
+		}
+		h.addOSMatch(OSMatch{
+			Name:     guess.Name,
+			Family:   guess.Family,
+			Version:  guess.Generation,
+			Accuracy: guess.Accuracy,
+			CPE:      guess.CPE,
+			Metadata: metadata,
+		})
+	}
+}
+
+// addOSMatch 同名结果保留置信度较高者，并按置信度降序排列
+func (h *HostResult) addOSMatch(match OSMatch) {
+	for i := range h.OS {
+		if h.OS[i].Name == match.Name {
+			if match.Accuracy > h.OS[i].Accuracy {
+				h.OS[i] = match
+			}
+			match.Name = ""
+			break
+		}
+	}
+	if match.Name != "" {
+		h.OS = append(h.OS, match)
+	}
+	sort.SliceStable(h.OS, func(i, j int) bool { return h.OS[i].Accuracy > h.OS[j].Accuracy })
+}
+
+// portResultFromScanResult 将逐端口结果转换为主机结果中的端口记录
+func portResultFromScanResult(r ScanResult) PortResult {
+	port := PortResult{
+		Port:     r.Port,
+		Protocol: ScanTypeProtocol(r.Type),
+		State:    r.State,
+		TTL:      r.TTL,
+		ScanType: r.Type,
+		Banner:   r.Banner,
+		Metadata: r.Metadata,
+	}
+	if reason, ok := r.Metadata["reason"].(string); ok {
+		port.Reason = reason
+	}
+	if port.TTL == 0 {
+		port.TTL, _ = metadataInt(r.Metadata, "ttl")
+	}
+
+	switch {
+	case r.Service != nil:
+		port.Service = &PortService{
+			Name:        r.Service.Name,
+			Product:     r.Service.Product,
+			Version:     r.Service.Version,
+			DeviceType:  r.Service.DeviceType,
+			Confidence:  r.Service.Confidence,
+			CPE:         r.Service.CPE,
+			Fingerprint: r.Service.Fingerprint,
+			TLS:         r.Service.TLS,
+		}
+		if port.Banner == "" {
+			port.Banner = r.Service.Banner
+		}
+	case r.ServiceName != "":
+		port.Service = &PortService{Name: r.ServiceName, Version: r.Version}
+	}
+	return port
+}
+
+// ScanTypeProtocol 返回扫描类型对应的传输层协议，未注册的类型视为TCP
+func ScanTypeProtocol(scanType ScanType) string {
+	if reg, ok := LookupScanner(scanType); ok && reg.Capabilities.Protocol != "" {
+		return reg.Capabilities.Protocol
+	}
+	return ProtocolTCP
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecuteHostScan 执行扫描并返回以主机为中心的结果
+func ExecuteHostScan(opts *ScanOptions) (*HostResult, error) {
+	start := time.Now()
+	results, err := ExecuteScan(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	host := NewHostResult(opts.Target)
+	// 离线重放不访问网络，不做域名解析
+	if opts.Replay == nil {
+		if err := host.Resolve(); err != nil {
+			logger.Debugf("%v", err)
+		}
+	}
+	host.AddResults(opts.ScanType, results)
+	host.Complete(start, time.Now())
+	return host, nil
+}
+
+// PrintHostResult 打印主机信息和端口结果
+func PrintHostResult(host *HostResult) {
+	fmt.Printf("\n主机: %s", host.Name())
+	if addr := host.Address(); addr != host.Name() {
+		fmt.Printf(" (%s)", addr)
+	}
+	fmt.Printf("  状态: %s", host.Status)
+	if host.Reason != "" {
+		fmt.Printf(" (%s)", host.Reason)
+	}
+	if host.MAC != "" {
+		fmt.Printf("  MAC: %s", host.MAC)
+	}
+	fmt.Println()
+
+	PrintResults(host.ScanResults())
+}
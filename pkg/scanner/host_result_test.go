@@ -0,0 +1,204 @@
+package scanner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostResultMergesStages(t *testing.T) {
+	host := NewHostResult("192.0.2.10")
+	assert.Equal(t, HostStateUnknown, host.Status)
+
+	// 端口扫描阶段
+	host.AddResults(ScanTypeSYN, []ScanResult{
+		{Port: 443, State: PortStateOpen, Metadata: map[string]interface{}{"reason": "syn-ack", "ttl": 57}},
+		{Port: 22, State: PortStateOpen, Metadata: map[string]interface{}{"reason": "syn-ack"}},
+		{Port: 25, State: PortStateClosed, Metadata: map[string]interface{}{"reason": "reset"}},
+	})
+	host.AddUDPResults([]UDPScanResult{
+		{Port: 53, State: PortStateOpen, ServiceName: "dns", Reason: "udp-response"},
+		{Port: 161, State: PortStateFiltered, Reason: "timeout"},
+	})
+
+	// 服务与OS识别阶段，已识别的banner不应被覆盖
+	host.AddResults(ScanTypeTCP, []ScanResult{
+		{Port: 22, State: PortStateOpen, Banner: "SSH-2.0-OpenSSH_8.9"},
+	})
+	host.AddResults(ScanTypeTCP, []ScanResult{
+		{
+			Port:    22,
+			State:   PortStateOpen,
+			Service: &fingerprint.Service{Name: "ssh", Product: "OpenSSH", Version: "8.9"},
+			OS:      &fingerprint.OSInfo{Name: "Linux", Confidence: 60},
+		},
+		{Port: 443, State: PortStateOpen, OS: &fingerprint.OSInfo{Name: "Ubuntu", Version: "22.04", Confidence: 85}},
+		{Port: 25, State: PortStateClosed, OS: &fingerprint.OSInfo{Name: "Linux", Confidence: 40}},
+	})
+
+	require.Len(t, host.TCP, 3)
+	assert.Equal(t, []int{22, 25, 443}, []int{host.TCP[0].Port, host.TCP[1].Port, host.TCP[2].Port})
+	assert.Equal(t, "SSH-2.0-OpenSSH_8.9", host.TCP[0].Banner)
+	assert.Equal(t, "OpenSSH", host.TCP[0].Service.Product)
+	assert.Equal(t, 57, host.TCP[2].TTL)
+
+	require.Len(t, host.UDP, 2)
+	assert.Equal(t, ProtocolUDP, host.UDP[0].Protocol)
+	assert.Equal(t, "dns", host.UDP[0].Service.Name)
+	assert.Equal(t, "timeout", host.UDP[1].Reason)
+	assert.Empty(t, host.SCTP)
+
+	assert.Equal(t, HostStateUp, host.Status)
+	assert.Equal(t, "TCP/443", host.Reason)
+	assert.Equal(t, 3, host.CountPorts(PortStateOpen))
+
+	// 同名OS保留置信度高者，按置信度降序
+	require.Len(t, host.OS, 2)
+	assert.Equal(t, "Ubuntu", host.OS[0].Name)
+	assert.Equal(t, 60.0, host.OS[1].Accuracy)
+}
+
+func TestHostResultStatus(t *testing.T) {
+	// 只有超时的端口无法判断主机状态
+	host := NewHostResult("192.0.2.10")
+	host.AddResults(ScanTypeTCP, []ScanResult{{Port: 80, State: PortStateFiltered}})
+	assert.Equal(t, HostStateUnknown, host.Status)
+
+	// UDP端口不可达说明主机在线
+	host.AddUDPResults([]UDPScanResult{{Port: 123, State: PortStateClosed, Reason: "port-unreach"}})
+	assert.Equal(t, HostStateUp, host.Status)
+	assert.Equal(t, "UDP/123", host.Reason)
+
+	// 主机发现阶段的结果
+	host = NewHostResultFromStatus(HostStatus{IP: "192.0.2.20", Up: false, Method: "icmp-echo", Latency: time.Millisecond})
+	assert.Equal(t, HostStateDown, host.Status)
+	assert.Equal(t, []string{"192.0.2.20"}, host.Addresses)
+	assert.Equal(t, time.Millisecond, host.Timing.RTT)
+
+	// ARP发现的MAC地址，兼容Linux和macOS的arp输出
+	mac := parseARPOutput("Address  HWtype  HWaddress  Flags Mask  Iface\n192.0.2.30  ether  00:11:22:aa:bb:cc  C  eth0\n")
+	assert.Equal(t, "00:11:22:aa:bb:cc", mac)
+	assert.Equal(t, "00:11:22:03:04:05", parseARPOutput("? (192.0.2.30) at 0:11:22:3:4:5 on en0 ifscope [ethernet]"))
+	assert.Empty(t, parseARPOutput("192.0.2.31 (192.0.2.31) -- no entry"))
+	host = NewHostResultFromStatus(HostStatus{IP: "192.0.2.30", Up: true, Method: "ARP", MAC: mac})
+	assert.Equal(t, mac, host.MAC)
+
+	// 主机名目标
+	host = NewHostResult("scanme.example")
+	assert.Equal(t, []string{"scanme.example"}, host.Hostnames)
+	assert.Empty(t, host.Addresses)
+	assert.Equal(t, "scanme.example", host.Name())
+}
+
+func TestHostResultMerge(t *testing.T) {
+	start := time.Now()
+	host := NewHostResult("192.0.2.10")
+	host.AddResults(ScanTypeTCP, []ScanResult{{Port: 80, State: PortStateFiltered}})
+	host.Complete(start.Add(time.Second), start.Add(2*time.Second))
+
+	other := NewHostResult("192.0.2.10")
+	other.Hostnames = []string{"www.example"}
+	other.AddResults(ScanTypeTCP, []ScanResult{
+		{Port: 22, State: PortStateOpen, OS: &fingerprint.OSInfo{Name: "Linux", Confidence: 70}},
+		{Port: 443, State: PortStateClosed},
+	})
+	other.Complete(start, start.Add(3*time.Second))
+
+	host.Merge(other)
+	assert.Equal(t, []string{"192.0.2.10"}, host.Addresses)
+	assert.Equal(t, []string{"www.example"}, host.Hostnames)
+	assert.Equal(t, HostStateUp, host.Status)
+	require.Len(t, host.TCP, 3)
+	assert.Equal(t, []int{22, 80, 443}, []int{host.TCP[0].Port, host.TCP[1].Port, host.TCP[2].Port})
+	require.Len(t, host.OS, 1)
+	assert.Equal(t, start, host.Timing.Start)
+	assert.Equal(t, 3*time.Second, host.Timing.Duration)
+
+	host.Merge(nil)
+	assert.Len(t, host.TCP, 3)
+}
+
+func TestHostResultCompleteAndEncode(t *testing.T) {
+	host := NewHostResult("192.0.2.10")
+	host.MAC = "00:11:22:33:44:55"
+	host.AddResults(ScanTypeTCP, []ScanResult{
+		{Port: 80, State: PortStateOpen, ServiceName: "http"},
+		{Port: 81, State: PortStateOpen, Metadata: map[string]interface{}{"window": 0}},
+	})
+
+	start := time.Now()
+	host.Complete(start, start.Add(2*time.Second))
+	assert.Equal(t, 2*time.Second, host.Timing.Duration)
+	require.NotNil(t, host.Honeypot)
+	assert.Contains(t, strings.Join(host.Honeypot.Reasons, ";"), "窗口")
+
+	results := host.ScanResults()
+	require.Len(t, results, 2)
+	assert.Equal(t, "http", results[0].ServiceName)
+	assert.True(t, results[0].Open)
+
+	data, err := json.Marshal(host)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"mac":"00:11:22:33:44:55"`)
+
+	_, err = xml.Marshal(host)
+	assert.NoError(t, err)
+}
+
+func TestExecuteHostScanUsesRegistry(t *testing.T) {
+	custom := ScanType("host-result-test")
+	require.NoError(t, RegisterScanner(custom, func() BaseScanner { return &fakeScanner{} }, ScannerCapabilities{Protocol: "tcp"}))
+
+	// 以主机为中心的结果与ExecuteScan一样经注册表分派
+	host, err := ExecuteHostScan(&ScanOptions{Target: "127.0.0.1", Ports: "80", ScanType: custom, Quiet: true})
+	require.NoError(t, err)
+	assert.Equal(t, HostStateUp, host.Status)
+	ports := host.Ports()
+	if assert.Len(t, ports, 1) {
+		assert.Equal(t, 80, ports[0].Port)
+		assert.Equal(t, PortStateOpen, ports[0].State)
+	}
+
+	_, err = ExecuteHostScan(&ScanOptions{Target: "127.0.0.1", Ports: "80", ScanType: ScanType("nope"), Quiet: true})
+	assert.Error(t, err)
+}
+
+func TestNewPortScanOutput(t *testing.T) {
+	start := time.Now()
+	output := CreateScanOutputFromResults("192.0.2.10",
+		[]ScanResult{
+			{Port: 22, State: PortStateOpen, ServiceName: "ssh"},
+			{Port: 23, State: PortStateClosed},
+			{Port: 24, State: PortStateFiltered},
+		},
+		[]UDPScanResult{{Port: 53, State: PortStateOpen, Reason: "udp-response"}},
+		map[int]*ServiceInfo{22: {Name: "ssh", Product: "OpenSSH", Version: "8.9"}},
+		[]HostStatus{{IP: "192.0.2.10", Up: true, Method: "icmp-echo"}},
+		start, start.Add(time.Second))
+
+	assert.Equal(t, 4, output.Summary.TotalPorts)
+	assert.Equal(t, 2, output.Summary.OpenPorts)
+	assert.Equal(t, 1, output.Summary.ClosedPorts)
+	assert.Equal(t, 1, output.Summary.FilteredPorts)
+	assert.Equal(t, time.Second, output.Summary.Duration)
+
+	require.Len(t, output.OpenPorts, 2)
+	assert.Equal(t, PortInfo{Port: 22, Protocol: "tcp", State: "open", ServiceName: "ssh", Reason: "syn-ack"}, output.OpenPorts[0])
+	assert.Equal(t, "udp", output.OpenPorts[1].Protocol)
+	assert.Equal(t, "reset", output.ClosedPorts[0].Reason)
+
+	// 单独检测的服务信息补充到端口上
+	require.Len(t, output.ServiceVersions, 1)
+	assert.Equal(t, "OpenSSH", output.ServiceVersions[0].Product)
+
+	require.NotNil(t, output.Host)
+	assert.Equal(t, HostStateUp, output.Host.Status)
+	assert.Equal(t, "icmp-echo", output.Host.Reason)
+	require.Len(t, output.HostDiscovery, 1)
+}
@@ -0,0 +1,369 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLoadBalancerSamples 负载均衡检测默认的重复探测次数
+const DefaultLoadBalancerSamples = 10
+
+// 负载均衡判断使用的阈值
+const (
+	lbClockTolerance = time.Second // Date头精度为1秒，时钟偏差在此范围内视为同一后端
+	lbIPIDMaxGap     = 2000        // 同一后端相邻两次应答的IP ID最大增量
+	lbMinIPIDSamples = 4           // 分析IP ID序列所需的最少样本数
+)
+
+// LoadBalancerSample 一次重复探测的观察
+type LoadBalancerSample struct {
+	Server      string    `json:"server,omitempty"`      // HTTP Server头
+	Date        time.Time `json:"date"`                  // HTTP Date头
+	Received    time.Time `json:"received"`              // 收到HTTP应答的本地时间
+	Certificate string    `json:"certificate,omitempty"` // TLS叶子证书的SHA-256指纹
+	TTL         int       `json:"ttl,omitempty"`         // SYN/ACK的TTL，0表示未采样
+	IPID        int       `json:"ipid"`                  // SYN/ACK的IP ID，-1表示未采样
+	Error       string    `json:"error,omitempty"`       // 探测失败原因
+}
+
+// LoadBalancerSignal 一类特征在各次探测中的差异
+type LoadBalancerSignal struct {
+	Name     string   `json:"name"`             // 特征名称(server、date、certificate、ttl、ipid)
+	Distinct int      `json:"distinct"`         // 观察到的不同取值或序列数
+	Values   []string `json:"values,omitempty"` // 各取值
+	Detail   string   `json:"detail"`           // 说明
+}
+
+// LoadBalancerReport 负载均衡检测报告
+type LoadBalancerReport struct {
+	Target             string               `json:"target"`               // 目标
+	Port               int                  `json:"port"`                 // 探测端口
+	TLS                bool                 `json:"tls"`                  // 是否使用TLS
+	BehindLoadBalancer bool                 `json:"behind_load_balancer"` // 是否位于负载均衡之后
+	Backends           int                  `json:"backends"`             // 估计的后端数量
+	Signals            []LoadBalancerSignal `json:"signals"`              // 各特征的分析
+	Samples            []LoadBalancerSample `json:"samples"`              // 原始观察
+	Errors             []string             `json:"errors,omitempty"`     // 未能执行的探测
+}
+
+// DetectLoadBalancer 向目标端口重复发送HTTP请求和SYN探测，比较Server/Date头、TLS证书、
+// TTL与IP ID序列，判断目标是否位于负载均衡之后并估计后端数量
+// samples<=0时使用DefaultLoadBalancerSamples；SYN探测需要root权限，无权限时只比较HTTP特征。
+func DetectLoadBalancer(opts *ScanOptions, port, samples int, useTLS bool) (*LoadBalancerReport, error) {
+	if opts == nil {
+		return nil, ErrInvalidOptions
+	}
+	if opts.Target == "" {
+		return nil, ErrInvalidTarget
+	}
+	if port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("无效的端口: %d", port)
+	}
+	if samples <= 0 {
+		samples = DefaultLoadBalancerSamples
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout(1)
+	}
+
+	rawAllowed := opts.RawTransport != nil || os.Geteuid() == 0
+	var errs []string
+	if !rawAllowed {
+		errs = append(errs, "SYN探测需要root权限，跳过TTL与IP ID采样")
+	}
+
+	list := make([]LoadBalancerSample, 0, samples)
+	succeeded := false
+	for i := 0; i < samples; i++ {
+		sample := probeHTTPSample(scanDialer(opts), opts.Target, port, timeout, useTLS)
+		sample.IPID = -1
+		if rawAllowed {
+			// 每次新建引擎使用不同的源端口，避免按五元组哈希的负载均衡固定到同一后端
+			results, err := rawScan(context.Background(), ScanTypeSYN, opts.RawTransport, opts.Capture,
+				opts.Target, []int{port}, timeout, 1)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("SYN探测失败: %v", err))
+				rawAllowed = false
+			} else if len(results) == 1 && results[0].State == PortStateOpen {
+				sample.TTL = results[0].TTL
+				if ipid, ok := metadataInt(results[0].Metadata, "ipid"); ok {
+					sample.IPID = ipid
+				}
+			}
+		}
+		if sample.Error == "" || sample.TTL > 0 {
+			succeeded = true
+		}
+		list = append(list, sample)
+	}
+	if !succeeded {
+		return nil, fmt.Errorf("所有探测均失败: %s", list[0].Error)
+	}
+
+	report := AnalyzeLoadBalancer(opts.Target, port, list)
+	report.TLS = useTLS
+	report.Errors = errs
+	return report, nil
+}
+
+// probeHTTPSample 建立新连接发送一次HTTP请求，记录Server/Date头和TLS证书
+func probeHTTPSample(dialer Dialer, target string, port int, timeout time.Duration, useTLS bool) LoadBalancerSample {
+	sample := LoadBalancerSample{}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(target, strconv.Itoa(port)))
+	if err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: target, InsecureSkipVerify: true})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			sample.Error = fmt.Sprintf("TLS握手失败: %v", err)
+			return sample
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			sum := sha256.Sum256(certs[0].Raw)
+			sample.Certificate = hex.EncodeToString(sum[:])
+		}
+		conn = tlsConn
+	}
+
+	// 使用HEAD请求，只需要应答头
+	req, err := http.NewRequest(http.MethodHead, "http://"+net.JoinHostPort(target, strconv.Itoa(port))+"/", nil)
+	if err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+	req.Header.Set("User-Agent", "go-port-rocket")
+	req.Close = true
+	if err := req.Write(conn); err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	sample.Received = time.Now()
+	if err != nil {
+		sample.Error = fmt.Sprintf("读取HTTP应答失败: %v", err)
+		return sample
+	}
+	resp.Body.Close()
+
+	sample.Server = resp.Header.Get("Server")
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		sample.Date = date
+	}
+	return sample
+}
+
+// AnalyzeLoadBalancer 比较同一目标多次探测的观察，估计后端数量
+// 每类特征的不同取值数都是后端数量的下限，取其中最大者作为估计值。
+func AnalyzeLoadBalancer(target string, port int, samples []LoadBalancerSample) *LoadBalancerReport {
+	report := &LoadBalancerReport{Target: target, Port: port, Samples: samples, Backends: 1}
+
+	var servers, certs, ttls []string
+	var offsets []time.Duration
+	var ipids []int
+	for _, s := range samples {
+		if s.Server != "" {
+			servers = append(servers, s.Server)
+		}
+		if s.Certificate != "" {
+			certs = append(certs, s.Certificate)
+		}
+		if s.TTL > 0 {
+			ttls = append(ttls, strconv.Itoa(s.TTL))
+		}
+		if !s.Date.IsZero() && !s.Received.IsZero() {
+			offsets = append(offsets, s.Date.Sub(s.Received))
+		}
+		if s.IPID >= 0 {
+			ipids = append(ipids, s.IPID)
+		}
+	}
+
+	addSignal := func(signal LoadBalancerSignal) {
+		report.Signals = append(report.Signals, signal)
+		if signal.Distinct > report.Backends {
+			report.Backends = signal.Distinct
+		}
+	}
+
+	if values := distinctValues(servers); len(values) > 0 {
+		addSignal(LoadBalancerSignal{Name: "server", Distinct: len(values), Values: values,
+			Detail: fmt.Sprintf("%d 次应答中出现 %d 种Server头", len(servers), len(values))})
+	}
+	if values := distinctValues(certs); len(values) > 0 {
+		addSignal(LoadBalancerSignal{Name: "certificate", Distinct: len(values), Values: values,
+			Detail: fmt.Sprintf("%d 次握手中出现 %d 张不同的证书", len(certs), len(values))})
+	}
+	if len(offsets) > 0 {
+		clusters := clockClusters(offsets)
+		values := make([]string, len(clusters))
+		for i, offset := range clusters {
+			values[i] = fmt.Sprintf("%+ds", int(offset.Round(time.Second)/time.Second))
+		}
+		addSignal(LoadBalancerSignal{Name: "date", Distinct: len(clusters), Values: values,
+			Detail: fmt.Sprintf("Date头与本地时间的偏差分为 %d 组", len(clusters))})
+	}
+	if values := distinctValues(ttls); len(values) > 0 {
+		addSignal(LoadBalancerSignal{Name: "ttl", Distinct: len(values), Values: values,
+			Detail: fmt.Sprintf("%d 次SYN/ACK中出现 %d 种TTL", len(ttls), len(values))})
+	}
+	if len(ipids) > 0 {
+		addSignal(ipidSignal(ipids))
+	}
+
+	// TTL是唯一出现差异的特征时更可能是路由变化或anycast节点切换
+	if ttl := report.signal("ttl"); ttl != nil && ttl.Distinct > 1 {
+		others := 1
+		for _, s := range report.Signals {
+			if s.Name != "ttl" && s.Distinct > others {
+				others = s.Distinct
+			}
+		}
+		if others == 1 {
+			ttl.Detail += "，其他特征一致，可能是路由变化或anycast节点切换"
+		}
+	}
+
+	report.BehindLoadBalancer = report.Backends > 1
+	return report
+}
+
+// signal 按名称查找特征
+func (r *LoadBalancerReport) signal(name string) *LoadBalancerSignal {
+	for i := range r.Signals {
+		if r.Signals[i].Name == name {
+			return &r.Signals[i]
+		}
+	}
+	return nil
+}
+
+// distinctValues 返回按首次出现顺序排列的不同取值
+func distinctValues(values []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// clockClusters 将时钟偏差排序后按容差分组，返回各组的首个偏差
+func clockClusters(offsets []time.Duration) []time.Duration {
+	sorted := append([]time.Duration(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	clusters := []time.Duration{sorted[0]}
+	last := sorted[0]
+	for _, offset := range sorted[1:] {
+		if offset-last > lbClockTolerance {
+			clusters = append(clusters, offset)
+		}
+		last = offset
+	}
+	return clusters
+}
+
+// ipidSignal 将IP ID按递增序列分组，每个独立递增的计数器对应一台后端
+// 全为0或每个样本自成一组时说明目标使用固定或随机IP ID，无法区分后端
+func ipidSignal(ipids []int) LoadBalancerSignal {
+	signal := LoadBalancerSignal{Name: "ipid", Distinct: 1}
+
+	allZero := true
+	for _, id := range ipids {
+		if id != 0 {
+			allZero = false
+			break
+		}
+	}
+	switch {
+	case allZero:
+		signal.Detail = "IP ID恒为0，无法区分后端"
+		return signal
+	case len(ipids) < lbMinIPIDSamples:
+		signal.Detail = fmt.Sprintf("IP ID样本不足 %d 个，无法区分后端", lbMinIPIDSamples)
+		return signal
+	}
+
+	// 为每个IP ID选择增量最小的已有序列，找不到时开始新序列
+	var lasts []int
+	for _, id := range ipids {
+		best, bestGap := -1, lbIPIDMaxGap+1
+		for i, last := range lasts {
+			gap := (id - last + 65536) % 65536
+			if gap > 0 && gap < bestGap {
+				best, bestGap = i, gap
+			}
+		}
+		if best < 0 {
+			lasts = append(lasts, id)
+		} else {
+			lasts[best] = id
+		}
+	}
+
+	if len(lasts) > len(ipids)/2 {
+		signal.Detail = fmt.Sprintf("%d 个IP ID中有 %d 个无法归入递增序列，目标可能使用随机IP ID", len(ipids), len(lasts))
+		return signal
+	}
+	signal.Distinct = len(lasts)
+	for _, last := range lasts {
+		signal.Values = append(signal.Values, strconv.Itoa(last))
+	}
+	signal.Detail = fmt.Sprintf("%d 个IP ID组成 %d 个独立递增序列", len(ipids), len(lasts))
+	return signal
+}
+
+// PrintLoadBalancerReport 打印负载均衡检测报告
+func PrintLoadBalancerReport(report *LoadBalancerReport) {
+	fmt.Printf("负载均衡检测: %s:%d\n", report.Target, report.Port)
+	fmt.Println("========================================")
+	if report.BehindLoadBalancer {
+		fmt.Printf("结论: 位于负载均衡之后，至少观察到 %d 个后端\n", report.Backends)
+	} else {
+		fmt.Println("结论: 未发现负载均衡迹象")
+	}
+
+	fmt.Printf("\n%-12s %-6s %s\n", "特征", "取值数", "说明")
+	for _, signal := range report.Signals {
+		fmt.Printf("%-12s %-6d %s\n", signal.Name, signal.Distinct, signal.Detail)
+		if signal.Distinct > 1 && len(signal.Values) > 0 {
+			fmt.Printf("%-12s %-6s %s\n", "", "", strings.Join(signal.Values, ", "))
+		}
+	}
+
+	failed := 0
+	for _, s := range report.Samples {
+		if s.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d/%d 次HTTP探测失败\n", failed, len(report.Samples))
+	}
+	for _, msg := range report.Errors {
+		fmt.Printf("注意: %s\n", msg)
+	}
+}
@@ -0,0 +1,77 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lbSample 构造只有SYN/ACK观察的样本
+func lbSample(ttl, ipid int) LoadBalancerSample {
+	return LoadBalancerSample{TTL: ttl, IPID: ipid}
+}
+
+func TestAnalyzeLoadBalancerCertificates(t *testing.T) {
+	now := time.Now()
+	var samples []LoadBalancerSample
+	for i := 0; i < 6; i++ {
+		samples = append(samples, LoadBalancerSample{
+			Server:      "nginx",
+			Date:        now.Truncate(time.Second),
+			Received:    now,
+			Certificate: []string{"aa", "bb"}[i%2],
+			IPID:        -1,
+		})
+	}
+
+	report := AnalyzeLoadBalancer("192.0.2.10", 443, samples)
+	assert.True(t, report.BehindLoadBalancer)
+	assert.Equal(t, 2, report.Backends)
+	require.NotNil(t, report.signal("certificate"))
+	assert.Equal(t, []string{"aa", "bb"}, report.signal("certificate").Values)
+	assert.Equal(t, 1, report.signal("date").Distinct)
+	assert.Nil(t, report.signal("ipid"), "未采样的IP ID不参与分析")
+}
+
+func TestAnalyzeLoadBalancerIPIDSequences(t *testing.T) {
+	// 两台后端的IP ID计数器交替出现，其中一个发生回绕
+	report := AnalyzeLoadBalancer("192.0.2.10", 80, []LoadBalancerSample{
+		lbSample(64, 100), lbSample(64, 65530), lbSample(64, 103),
+		lbSample(64, 2), lbSample(64, 110), lbSample(64, 9),
+	})
+	assert.Equal(t, 2, report.Backends)
+	assert.Equal(t, "6 个IP ID组成 2 个独立递增序列", report.signal("ipid").Detail)
+
+	// 随机IP ID无法归入递增序列
+	report = AnalyzeLoadBalancer("192.0.2.10", 80, []LoadBalancerSample{
+		lbSample(64, 51234), lbSample(64, 812), lbSample(64, 30077),
+		lbSample(64, 19000), lbSample(64, 44100), lbSample(64, 6000),
+	})
+	assert.False(t, report.BehindLoadBalancer)
+	assert.Contains(t, report.signal("ipid").Detail, "随机IP ID")
+
+	// 恒为0的IP ID同样不可用
+	report = AnalyzeLoadBalancer("192.0.2.10", 80, []LoadBalancerSample{
+		lbSample(64, 0), lbSample(64, 0), lbSample(64, 0), lbSample(64, 0),
+	})
+	assert.Equal(t, 1, report.Backends)
+	assert.Contains(t, report.signal("ipid").Detail, "恒为0")
+}
+
+func TestAnalyzeLoadBalancerTTLOnly(t *testing.T) {
+	report := AnalyzeLoadBalancer("192.0.2.10", 80, []LoadBalancerSample{
+		lbSample(57, 10), lbSample(57, 11), lbSample(55, 12), lbSample(55, 13),
+	})
+	assert.Equal(t, 2, report.Backends)
+	assert.Contains(t, report.signal("ttl").Detail, "anycast")
+}
+
+func TestDetectLoadBalancerValidatesInput(t *testing.T) {
+	_, err := DetectLoadBalancer(&ScanOptions{}, 80, 1, false)
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+
+	_, err = DetectLoadBalancer(&ScanOptions{Target: "192.0.2.10"}, 0, 1, false)
+	assert.Error(t, err)
+}
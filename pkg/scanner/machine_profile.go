@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// EngineProfile 扫描引擎在本机的最佳配置
+type EngineProfile struct {
+	Workers     int     `json:"workers"`       // 吞吐最高的并发数
+	RateLimit   int     `json:"rate_limit"`    // 对应的速率限制，0表示不限速
+	PortsPerSec float64 `json:"ports_per_sec"` // 测得的吞吐量
+	Accuracy    float64 `json:"accuracy"`      // 测得的准确率
+}
+
+// MachineProfile 由基准测试生成的机器画像，供ScanAdvisor给出并发建议
+type MachineProfile struct {
+	CreatedAt time.Time                  `json:"created_at"` // 生成时间
+	CPUs      int                        `json:"cpus"`       // CPU核心数
+	FDLimit   int                        `json:"fd_limit"`   // 文件描述符限制
+	Engines   map[ScanType]EngineProfile `json:"engines"`    // 各引擎的最佳配置
+}
+
+// Profile 从基准测试结果生成机器画像
+// 每个引擎选取准确率达标的配置中吞吐最高者，吞吐相同时取并发数较小者；没有达标配置时取准确率最高者。
+func (r *BenchReport) Profile() *MachineProfile {
+	profile := &MachineProfile{
+		CreatedAt: time.Now(),
+		CPUs:      r.CPUs,
+		FDLimit:   r.FDLimit,
+		Engines:   make(map[ScanType]EngineProfile),
+	}
+
+	best := make(map[ScanType]BenchResult)
+	for _, result := range r.Results {
+		if result.Error != "" {
+			continue
+		}
+		current, ok := best[result.Engine]
+		if !ok || benchBetter(result, current) {
+			best[result.Engine] = result
+		}
+	}
+	for engine, result := range best {
+		profile.Engines[engine] = EngineProfile{
+			Workers:     result.Workers,
+			RateLimit:   result.RateLimit,
+			PortsPerSec: result.PortsPerSec,
+			Accuracy:    result.Accuracy,
+		}
+	}
+	return profile
+}
+
+// benchBetter 判断测试结果a是否优于b
+func benchBetter(a, b BenchResult) bool {
+	aOK, bOK := a.Accuracy >= benchMinAccuracy, b.Accuracy >= benchMinAccuracy
+	switch {
+	case aOK != bOK:
+		return aOK
+	case !aOK:
+		return a.Accuracy > b.Accuracy
+	case a.PortsPerSec != b.PortsPerSec:
+		return a.PortsPerSec > b.PortsPerSec
+	default:
+		return a.Workers < b.Workers
+	}
+}
+
+// Engine 返回扫描引擎的画像，画像为空或不包含该引擎时返回false
+func (p *MachineProfile) Engine(scanType ScanType) (EngineProfile, bool) {
+	if p == nil {
+		return EngineProfile{}, false
+	}
+	engine, ok := p.Engines[scanType]
+	return engine, ok
+}
+
+// DefaultMachineProfilePath 返回机器画像的默认保存路径
+func DefaultMachineProfilePath() string {
+	return filepath.Join(os.Getenv("HOME"), ".go-port-rocket", "machine-profile.json")
+}
+
+// SaveMachineProfile 保存机器画像
+func SaveMachineProfile(path string, profile *MachineProfile) error {
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化机器画像失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建画像目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入机器画像失败: %v", err)
+	}
+	return nil
+}
+
+// LoadMachineProfile 读取机器画像
+func LoadMachineProfile(path string) (*MachineProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profile MachineProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("解析机器画像失败: %v", err)
+	}
+	return &profile, nil
+}
+
+// loadLocalMachineProfile 读取默认路径下的机器画像，CPU核心数与本机不符时视为过期
+func loadLocalMachineProfile() *MachineProfile {
+	profile, err := LoadMachineProfile(DefaultMachineProfilePath())
+	if err != nil || profile.CPUs != runtime.NumCPU() {
+		return nil
+	}
+	return profile
+}
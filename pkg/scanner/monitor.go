@@ -0,0 +1,319 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChangeType 两次扫描之间的变化类型
+type ChangeType string
+
+const (
+	ChangePortOpened     ChangeType = "port-opened"     // 端口新开放
+	ChangePortClosed     ChangeType = "port-closed"     // 开放端口关闭或被过滤
+	ChangeServiceChanged ChangeType = "service-changed" // 服务、版本或TLS证书变化
+	ChangeOSChanged      ChangeType = "os-changed"      // 操作系统识别结果变化
+)
+
+// minOSChangeAccuracy 两次OS识别的置信度都达到该值时才报告OS变化，避免低置信度结果来回跳动
+const minOSChangeAccuracy = 80.0
+
+// ChangeEvent 与基线相比的一项变化
+type ChangeEvent struct {
+	Type     ChangeType `json:"type"`               // 变化类型
+	Host     string     `json:"host"`               // 主机地址
+	Port     int        `json:"port,omitempty"`     // 端口
+	Protocol string     `json:"protocol,omitempty"` // 协议
+	Previous string     `json:"previous,omitempty"` // 变化前的状态、服务或OS
+	Current  string     `json:"current,omitempty"`  // 变化后的状态、服务或OS
+	Time     time.Time  `json:"time"`               // 发现变化的时间
+}
+
+// String 返回变化的文字描述
+func (e ChangeEvent) String() string {
+	where := e.Host
+	if e.Port > 0 {
+		where = fmt.Sprintf("%s %d/%s", e.Host, e.Port, e.Protocol)
+	}
+	switch e.Type {
+	case ChangePortOpened:
+		return fmt.Sprintf("[%s] %s 开放 (之前: %s)", e.Type, where, e.Previous)
+	case ChangePortClosed:
+		return fmt.Sprintf("[%s] %s 变为 %s", e.Type, where, e.Current)
+	default:
+		return fmt.Sprintf("[%s] %s: %s -> %s", e.Type, where, e.Previous, e.Current)
+	}
+}
+
+// DiffHosts 比较同一主机的两次扫描结果，只报告有意义的变化
+// 关闭与过滤之间的切换、本次未扫描的端口、本次未识别出服务或OS的情况都不视为变化。
+// previous为nil表示还没有基线，不产生变化。
+func DiffHosts(previous, current *HostResult) []ChangeEvent {
+	if previous == nil || current == nil {
+		return nil
+	}
+
+	now := current.Timing.End
+	if now.IsZero() {
+		now = time.Now()
+	}
+	host := current.Address()
+	var changes []ChangeEvent
+	add := func(change ChangeEvent) {
+		change.Host = host
+		change.Time = now
+		changes = append(changes, change)
+	}
+
+	before := make(map[string]PortResult)
+	for _, p := range previous.Ports() {
+		before[portKey(p)] = p
+	}
+	for _, p := range current.Ports() {
+		old, scanned := before[portKey(p)]
+		wasOpen := scanned && old.State == PortStateOpen
+		switch {
+		case p.State == PortStateOpen && !wasOpen:
+			previousState := "未扫描"
+			if scanned {
+				previousState = string(old.State)
+			}
+			add(ChangeEvent{Type: ChangePortOpened, Port: p.Port, Protocol: p.Protocol, Previous: previousState, Current: describeService(p)})
+		case p.State != PortStateOpen && wasOpen:
+			add(ChangeEvent{Type: ChangePortClosed, Port: p.Port, Protocol: p.Protocol, Previous: describeService(old), Current: string(p.State)})
+		case p.State == PortStateOpen && wasOpen:
+			if prev, cur, changed := serviceChanged(old, p); changed {
+				add(ChangeEvent{Type: ChangeServiceChanged, Port: p.Port, Protocol: p.Protocol, Previous: prev, Current: cur})
+			}
+		}
+	}
+
+	if len(previous.OS) > 0 && len(current.OS) > 0 {
+		prev, cur := previous.OS[0], current.OS[0]
+		if prev.Accuracy >= minOSChangeAccuracy && cur.Accuracy >= minOSChangeAccuracy && describeOS(prev) != describeOS(cur) {
+			add(ChangeEvent{Type: ChangeOSChanged, Previous: describeOS(prev), Current: describeOS(cur)})
+		}
+	}
+	return changes
+}
+
+// serviceChanged 比较开放端口的服务，任一方缺少信息的字段不参与比较
+func serviceChanged(old, cur PortResult) (string, string, bool) {
+	if old.Service != nil && cur.Service != nil {
+		a, b := old.Service, cur.Service
+		if differs(a.Name, b.Name) || differs(a.Product, b.Product) || differs(a.Version, b.Version) {
+			return describeService(old), describeService(cur), true
+		}
+	}
+	oldCert, curCert := leafCertificate(old), leafCertificate(cur)
+	if differs(oldCert, curCert) {
+		return "证书 " + shortHash(oldCert), "证书 " + shortHash(curCert), true
+	}
+	return "", "", false
+}
+
+// differs 两个值都非空且不同
+func differs(a, b string) bool {
+	return a != "" && b != "" && !strings.EqualFold(a, b)
+}
+
+// describeService 返回端口服务的文字描述
+func describeService(p PortResult) string {
+	if p.Service == nil {
+		return string(p.State)
+	}
+	parts := []string{p.Service.Name}
+	if p.Service.Product != "" {
+		parts = append(parts, p.Service.Product)
+	}
+	if p.Service.Version != "" {
+		parts = append(parts, p.Service.Version)
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// describeOS 返回OS的文字描述
+func describeOS(match OSMatch) string {
+	return strings.TrimSpace(match.Name + " " + match.Version)
+}
+
+// portKey 返回端口在主机内的唯一键
+func portKey(p PortResult) string {
+	return fmt.Sprintf("%s/%d", p.Protocol, p.Port)
+}
+
+// leafCertificate 返回TLS检查得到的叶子证书SHA-256指纹，未检查或不是TLS服务时为空
+func leafCertificate(p PortResult) string {
+	if p.Service == nil || p.Service.TLS == nil || len(p.Service.TLS.Certificates) == 0 {
+		return ""
+	}
+	return p.Service.TLS.Certificates[0].SHA256
+}
+
+// shortHash 截短指纹便于展示
+func shortHash(s string) string {
+	if len(s) > 16 {
+		return s[:16]
+	}
+	return s
+}
+
+// Monitor 持续监控，保存每个目标的基线并与每次扫描结果比较
+type Monitor struct {
+	mu        sync.Mutex
+	baselines map[string]*HostResult
+}
+
+// NewMonitor 创建监控器
+func NewMonitor() *Monitor {
+	return &Monitor{baselines: make(map[string]*HostResult)}
+}
+
+// Observe 将扫描结果与key对应的基线比较并更新基线，首次观察只建立基线
+// 新基线以本次结果为准，本次未扫描的端口和未识别出的服务信息沿用旧基线，避免下次比较时误报。
+func (m *Monitor) Observe(key string, host *HostResult) []ChangeEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.baselines[key]
+	changes := DiffHosts(previous, host)
+	if previous == nil {
+		m.baselines[key] = host.Clone()
+		return nil
+	}
+
+	baseline := previous.Clone()
+	baseline.Merge(host)
+	if len(host.OS) > 0 {
+		baseline.OS = append([]OSMatch(nil), host.OS...)
+	}
+	if host.Status != HostStateUnknown {
+		baseline.Status = host.Status
+		baseline.Reason = host.Reason
+	}
+	baseline.Timing = host.Timing
+	m.baselines[key] = baseline
+	return changes
+}
+
+// Baseline 返回key对应的基线
+func (m *Monitor) Baseline(key string) (*HostResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	baseline, ok := m.baselines[key]
+	if !ok {
+		return nil, false
+	}
+	return baseline.Clone(), true
+}
+
+// SetBaseline 设置key对应的基线，用于从存储中恢复
+func (m *Monitor) SetBaseline(key string, host *HostResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.baselines[key] = host.Clone()
+}
+
+// Keys 返回所有基线的key
+func (m *Monitor) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.baselines))
+	for key := range m.baselines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DefaultMonitorStatePath 返回默认的监控基线文件路径
+func DefaultMonitorStatePath() string {
+	return filepath.Join(os.Getenv("HOME"), ".go-port-rocket", "monitor.json")
+}
+
+// SaveMonitor 将所有基线保存到文件
+func SaveMonitor(path string, m *Monitor) error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m.baselines, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化监控基线失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建基线目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入监控基线失败: %v", err)
+	}
+	return nil
+}
+
+// LoadMonitor 从文件加载基线，文件不存在时返回空的监控器
+func LoadMonitor(path string) (*Monitor, error) {
+	m := NewMonitor()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取监控基线失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &m.baselines); err != nil {
+		return nil, fmt.Errorf("解析监控基线失败: %v", err)
+	}
+	return m, nil
+}
+
+// ChangeNotification 变化通知内容
+type ChangeNotification struct {
+	Source  string        `json:"source"`  // 通知来源，如监控计划名称
+	Target  string        `json:"target"`  // 扫描目标
+	Time    time.Time     `json:"time"`    // 通知时间
+	Changes []ChangeEvent `json:"changes"` // 变化列表
+}
+
+// WebhookNotifier 以HTTP POST JSON的方式发送变化通知
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier 创建Webhook通知器
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify 发送变化通知，没有变化时不发送
+func (w *WebhookNotifier) Notify(ctx context.Context, notification *ChangeNotification) error {
+	if len(notification.Changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("创建通知请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送通知失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("通知地址返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package scanner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func monitorHost(results ...ScanResult) *HostResult {
+	host := NewHostResult("192.0.2.10")
+	host.AddResults(ScanTypeTCP, results)
+	return host
+}
+
+// tlsService 构造叶子证书指纹为sha256的TLS服务
+func tlsService(sha256 string) *fingerprint.Service {
+	return &fingerprint.Service{Name: "https", TLS: &fingerprint.TLSInfo{
+		Certificates: []fingerprint.TLSCertificate{{SHA256: sha256}},
+	}}
+}
+
+// selfSignedCert 生成以name为CN的自签名证书
+func selfSignedCert(t *testing.T, name string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMonitorCertificateChange(t *testing.T) {
+	var cert atomic.Value
+	cert.Store(selfSignedCert(t, "old.rocket.test"))
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.Load().(*tls.Certificate), nil
+		},
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(2 * time.Second))
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	scan := func() *HostResult {
+		info, err := DetectService("127.0.0.1", port, &ServiceDetectionOptions{
+			BannerGrab:    true,
+			TLSInspection: true,
+			Timeout:       500 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, info.TLS)
+		return monitorHost(ScanResult{Port: port, State: PortStateOpen, Service: ConvertServiceInfoToFingerprint(info)})
+	}
+
+	monitor := NewMonitor()
+	first := scan()
+	leaf := first.TCP[0].Service.TLS.Certificates[0].SHA256
+	assert.Nil(t, monitor.Observe("tls", first))
+	assert.Empty(t, monitor.Observe("tls", scan()), "证书未变化")
+
+	cert.Store(selfSignedCert(t, "new.rocket.test"))
+	changes := monitor.Observe("tls", scan())
+	require.Len(t, changes, 1)
+	assert.Equal(t, ChangeServiceChanged, changes[0].Type)
+	assert.Equal(t, "证书 "+leaf[:16], changes[0].Previous)
+	assert.NotEqual(t, changes[0].Previous, changes[0].Current)
+}
+
+func TestDiffHosts(t *testing.T) {
+	previous := monitorHost(
+		ScanResult{Port: 22, State: PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Product: "OpenSSH", Version: "8.9"}},
+		ScanResult{Port: 80, State: PortStateOpen, Service: &fingerprint.Service{Name: "http", Product: "nginx"}},
+		ScanResult{Port: 443, State: PortStateOpen, Service: tlsService("aaaaaaaaaaaaaaaaaaaaaaaa")},
+		ScanResult{Port: 25, State: PortStateClosed},
+		ScanResult{Port: 8080, State: PortStateOpen, OS: &fingerprint.OSInfo{Name: "Linux", Version: "5.x", Confidence: 90}},
+	)
+	current := monitorHost(
+		ScanResult{Port: 22, State: PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Product: "OpenSSH", Version: "9.6"}},
+		ScanResult{Port: 80, State: PortStateOpen}, // 本次未识别服务，不视为变化
+		ScanResult{Port: 443, State: PortStateOpen, Service: tlsService("bbbbbbbbbbbbbbbbbbbbbbbb")},
+		ScanResult{Port: 25, State: PortStateFiltered}, // 关闭与过滤之间的切换不视为变化
+		ScanResult{Port: 8080, State: PortStateFiltered, OS: &fingerprint.OSInfo{Name: "FreeBSD", Confidence: 85}},
+		ScanResult{Port: 3306, State: PortStateOpen},
+	)
+
+	changes := DiffHosts(previous, current)
+	byPort := make(map[int]ChangeEvent)
+	var osChange *ChangeEvent
+	for i, change := range changes {
+		assert.Equal(t, "192.0.2.10", change.Host)
+		if change.Type == ChangeOSChanged {
+			osChange = &changes[i]
+			continue
+		}
+		byPort[change.Port] = change
+	}
+	require.Len(t, changes, 5)
+
+	assert.Equal(t, ChangeServiceChanged, byPort[22].Type)
+	assert.Equal(t, "ssh OpenSSH 8.9", byPort[22].Previous)
+	assert.Equal(t, "ssh OpenSSH 9.6", byPort[22].Current)
+	assert.Equal(t, ChangeServiceChanged, byPort[443].Type)
+	assert.Equal(t, "证书 aaaaaaaaaaaaaaaa", byPort[443].Previous)
+	assert.Equal(t, ChangePortClosed, byPort[8080].Type)
+	assert.Equal(t, "filtered", byPort[8080].Current)
+	assert.Equal(t, ChangePortOpened, byPort[3306].Type)
+	assert.Equal(t, "未扫描", byPort[3306].Previous)
+
+	require.NotNil(t, osChange)
+	assert.Equal(t, "Linux 5.x", osChange.Previous)
+	assert.Equal(t, "FreeBSD", osChange.Current)
+
+	assert.Nil(t, DiffHosts(nil, current))
+	assert.Empty(t, DiffHosts(current, current))
+}
+
+func TestMonitorObserve(t *testing.T) {
+	monitor := NewMonitor()
+	first := monitorHost(
+		ScanResult{Port: 22, State: PortStateOpen, Service: &fingerprint.Service{Name: "ssh", Version: "8.9"}},
+		ScanResult{Port: 80, State: PortStateOpen},
+	)
+	assert.Nil(t, monitor.Observe("web", first), "首次观察只建立基线")
+
+	// 本次只扫描了22端口且没有识别出服务，基线沿用之前的信息
+	second := monitorHost(ScanResult{Port: 22, State: PortStateOpen})
+	assert.Empty(t, monitor.Observe("web", second))
+	baseline, ok := monitor.Baseline("web")
+	require.True(t, ok)
+	require.Len(t, baseline.TCP, 2)
+	assert.Equal(t, "8.9", baseline.TCP[0].Service.Version)
+
+	// 变化只报告一次
+	third := monitorHost(ScanResult{Port: 80, State: PortStateClosed})
+	changes := monitor.Observe("web", third)
+	require.Len(t, changes, 1)
+	assert.Equal(t, ChangePortClosed, changes[0].Type)
+	assert.Empty(t, monitor.Observe("web", third))
+
+	// 基线保存后可以恢复
+	path := filepath.Join(t.TempDir(), "monitor.json")
+	require.NoError(t, SaveMonitor(path, monitor))
+	loaded, err := LoadMonitor(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, loaded.Keys())
+	assert.Empty(t, loaded.Observe("web", third))
+
+	empty, err := LoadMonitor(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	assert.Empty(t, empty.Keys())
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received []ChangeNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n ChangeNotification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		received = append(received, n)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL)
+	require.NoError(t, notifier.Notify(context.Background(), &ChangeNotification{Source: "test"}))
+	assert.Empty(t, received, "没有变化时不发送")
+
+	require.NoError(t, notifier.Notify(context.Background(), &ChangeNotification{
+		Source:  "test",
+		Changes: []ChangeEvent{{Type: ChangePortOpened, Host: "192.0.2.10", Port: 3306, Protocol: "tcp"}},
+	}))
+	require.Len(t, received, 1)
+	assert.Equal(t, 3306, received[0].Changes[0].Port)
+}
@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"sync"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
+	"github.com/cyberspacesec/go-port-rocket/pkg/metrics"
+)
+
+// MetricsObserver 将扫描事件写入Prometheus指标
+type MetricsObserver struct{}
+
+// NewMetricsObserver 创建指标观察者
+func NewMetricsObserver() *MetricsObserver {
+	return &MetricsObserver{}
+}
+
+// OnEvent 实现Observer接口
+func (m *MetricsObserver) OnEvent(event ScanEvent) {
+	target, scanType := event.Target, string(event.ScanType)
+
+	switch event.Type {
+	case EventPortResult:
+		metrics.IncrementPortsScanned(target, scanType)
+	case EventError:
+		metrics.IncrementScanErrors(target, scanType, "scan")
+	case EventScanFinished:
+		if event.Stats == nil {
+			return
+		}
+		metrics.SetOpenPorts(target, scanType, float64(event.Stats.OpenPorts))
+		metrics.SetClosedPorts(target, scanType, float64(event.Stats.ClosedPorts))
+		metrics.SetFilteredPorts(target, scanType, float64(event.Stats.FilteredPorts))
+		metrics.SetScanRate(target, scanType, event.Stats.ScanRate)
+		metrics.RecordScanDuration(target, scanType, event.Stats.EndTime.Sub(event.Stats.StartTime))
+	}
+}
+
+// LoggingObserver 将扫描事件写入日志
+type LoggingObserver struct{}
+
+// NewLoggingObserver 创建日志观察者
+func NewLoggingObserver() *LoggingObserver {
+	return &LoggingObserver{}
+}
+
+// OnEvent 实现Observer接口
+func (l *LoggingObserver) OnEvent(event ScanEvent) {
+	switch event.Type {
+	case EventScanStarted:
+		logger.Debugf("开始扫描 %s (%s)，共 %d 项", event.Target, event.ScanType, event.Total)
+	case EventHostUp:
+		if event.Host != nil {
+			logger.Debugf("主机存活: %s (%s)", event.Host.IP, event.Host.Method)
+		}
+	case EventPortResult:
+		if event.Result != nil && event.Result.State == PortStateOpen {
+			logger.Debugf("发现开放端口: %s:%d", event.Target, event.Port)
+		}
+	case EventServiceDetected:
+		if event.Service != nil {
+			logger.Debugf("识别到服务: %s:%d %s %s", event.Target, event.Port, event.Service.Name, event.Service.Version)
+		}
+	case EventOSDetected:
+		if event.OS != nil {
+			logger.Debugf("识别到操作系统: %s %s", event.Target, event.OS.Name)
+		}
+	case EventHostAbandoned:
+		if event.GiveUp != nil {
+			logger.Warnf("放弃主机 %s (%s): %s", event.Target, event.GiveUp.Reason, event.GiveUp.Detail)
+		}
+	case EventError:
+		logger.Warnf("扫描 %s:%d 出错: %v", event.Target, event.Port, event.Err)
+	case EventScanFinished:
+		if event.Stats != nil {
+			logger.Debugf("扫描完成 %s: 开放 %d, 关闭 %d, 过滤 %d", event.Target,
+				event.Stats.OpenPorts, event.Stats.ClosedPorts, event.Stats.FilteredPorts)
+		}
+	}
+}
+
+// ProgressObserver 在控制台打印扫描进度
+type ProgressObserver struct {
+	tracker *ProgressTracker
+	mu      sync.Mutex
+}
+
+// NewProgressObserver 创建进度观察者
+func NewProgressObserver() *ProgressObserver {
+	return &ProgressObserver{}
+}
+
+// OnEvent 实现Observer接口
+func (p *ProgressObserver) OnEvent(event ScanEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch event.Type {
+	case EventScanStarted:
+		p.tracker = NewProgressTracker(event.Total)
+	case EventProgress:
+		if p.tracker != nil {
+			p.tracker.Update(event.Completed)
+		}
+	}
+}
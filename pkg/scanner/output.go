@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
 )
 
 // 输出格式常量
@@ -21,14 +25,15 @@ const (
 
 // ScanSummary 扫描摘要信息
 type ScanSummary struct {
-	Target        string        // 目标
-	StartTime     time.Time     // 开始时间
-	EndTime       time.Time     // 结束时间
-	Duration      time.Duration // 持续时间
-	TotalPorts    int           // 总端口数
-	OpenPorts     int           // 开放端口数
-	ClosedPorts   int           // 关闭端口数
-	FilteredPorts int           // 被过滤端口数
+	Target        string         // 目标
+	StartTime     time.Time      // 开始时间
+	EndTime       time.Time      // 结束时间
+	Duration      time.Duration  // 持续时间
+	TotalPorts    int            // 总端口数
+	OpenPorts     int            // 开放端口数
+	ClosedPorts   int            // 关闭端口数
+	FilteredPorts int            // 被过滤端口数
+	Honeypot      *HoneypotScore `json:",omitempty" xml:",omitempty"` // 蜜罐/tarpit可能性评分
 }
 
 // OutputOptions 输出选项
@@ -47,6 +52,7 @@ type PortScanOutput struct {
 	HostDiscovery   []HostStatus  `json:"host_discovery,omitempty" xml:"host_discovery>host,omitempty"`
 	ServiceVersions []ServiceInfo `json:"service_versions,omitempty" xml:"service_versions>service,omitempty"`
 	OSDetection     []OSInfo      `json:"os_detection,omitempty" xml:"os_detection>os,omitempty"`
+	Host            *HostResult   `json:"host,omitempty" xml:"host,omitempty"`
 }
 
 // PortInfo 端口信息
@@ -64,6 +70,7 @@ type OSInfo struct {
 	Family     string      `json:"family,omitempty" xml:"family,omitempty"`
 	Version    string      `json:"version,omitempty" xml:"version,omitempty"`
 	Confidence float64     `json:"confidence" xml:"confidence"`
+	CPE        []string    `json:"cpe,omitempty" xml:"cpe,omitempty"`
 	Metadata   MetadataMap `json:"metadata,omitempty" xml:"metadata,omitempty"`
 }
 
@@ -142,11 +149,19 @@ func outputCSV(result *PortScanOutput, output *os.File) error {
 	defer writer.Flush()
 
 	// 写入标题
-	header := []string{"端口", "协议", "状态", "服务", "原因"}
+	header := []string{"端口", "协议", "状态", "服务", "原因", "TLS版本", "JARM"}
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
+	// 开放端口的TLS检查结果
+	tlsInfo := make(map[int]*fingerprint.TLSInfo)
+	for _, svc := range result.ServiceVersions {
+		if svc.TLS != nil {
+			tlsInfo[svc.Port] = svc.TLS
+		}
+	}
+
 	// 写入开放端口
 	for _, port := range result.OpenPorts {
 		record := []string{
@@ -155,6 +170,12 @@ func outputCSV(result *PortScanOutput, output *os.File) error {
 			port.State,
 			port.ServiceName,
 			port.Reason,
+			"",
+			"",
+		}
+		if info := tlsInfo[port.Port]; info != nil && port.Protocol == ProtocolTCP {
+			record[5] = strings.Join(info.VersionNames(), " ")
+			record[6] = info.JARM
 		}
 		if err := writer.Write(record); err != nil {
 			return err
@@ -170,6 +191,8 @@ func outputCSV(result *PortScanOutput, output *os.File) error {
 				port.State,
 				port.ServiceName,
 				port.Reason,
+				"",
+				"",
 			}
 			if err := writer.Write(record); err != nil {
 				return err
@@ -185,6 +208,8 @@ func outputCSV(result *PortScanOutput, output *os.File) error {
 				port.State,
 				port.ServiceName,
 				port.Reason,
+				"",
+				"",
 			}
 			if err := writer.Write(record); err != nil {
 				return err
@@ -227,6 +252,13 @@ func outputText(result *PortScanOutput, output *os.File, verbose bool) error {
 	fmt.Fprintf(output, "%s %s\n", title("●  开始时间:"), result.Summary.StartTime.Format("2006-01-02 15:04:05"))
 	fmt.Fprintf(output, "%s %s\n", title("●  结束时间:"), result.Summary.EndTime.Format("2006-01-02 15:04:05"))
 	fmt.Fprintf(output, "%s %.2f %s\n\n", title("●  扫描耗时:"), result.Summary.Duration.Seconds(), "秒")
+	if hp := result.Summary.Honeypot; hp != nil && hp.Score > 0 {
+		fmt.Fprintf(output, "%s %s\n", title("●  蜜罐可能性:"), warning(hp.String()))
+		for _, reason := range hp.Reasons {
+			fmt.Fprintf(output, "   - %s\n", reason)
+		}
+		fmt.Fprintln(output)
+	}
 
 	// 开放端口结果
 	fmt.Fprintf(output, "%s\n", header("╭─────────────────────────────────────────────────────╮"))
@@ -320,6 +352,10 @@ func outputText(result *PortScanOutput, output *os.File, verbose bool) error {
 
 				fmt.Fprintln(output)
 			}
+
+			if svc.TLS != nil {
+				writeTLSText(output, svc.TLS, title, warning)
+			}
 		}
 		fmt.Fprintln(output)
 	}
@@ -357,137 +393,178 @@ func outputText(result *PortScanOutput, output *os.File, verbose bool) error {
 	return nil
 }
 
+// writeTLSText 以文本格式输出TLS检查结果
+func writeTLSText(output io.Writer, info *fingerprint.TLSInfo, title, warning func(string) string) {
+	fmt.Fprintf(output, "  %s\n", title("● TLS 信息:"))
+	for _, version := range info.Versions {
+		fmt.Fprintf(output, "    %s %s\n", version.Name, strings.Join(version.Ciphers, ", "))
+	}
+	for i, cert := range info.Certificates {
+		label := "证书:"
+		if i > 0 {
+			label = "证书链:"
+		}
+		fmt.Fprintf(output, "    %s %s\n", title(label), cert.Subject)
+		if len(cert.SANs) > 0 {
+			fmt.Fprintf(output, "      SAN: %s\n", strings.Join(cert.SANs, ", "))
+		}
+		fmt.Fprintf(output, "      颁发者: %s\n", cert.Issuer)
+		fmt.Fprintf(output, "      密钥: %s %d  签名: %s\n", cert.KeyType, cert.KeyBits, cert.SignatureAlgorithm)
+		expiry := cert.NotAfter.Format("2006-01-02")
+		if cert.Expired {
+			expiry = warning(expiry + " (已过期)")
+		}
+		fmt.Fprintf(output, "      有效期: %s 至 %s\n", cert.NotBefore.Format("2006-01-02"), expiry)
+	}
+	if len(info.Certificates) > 0 {
+		if info.Trusted {
+			fmt.Fprintf(output, "    %s 受信任\n", title("证书验证:"))
+		} else {
+			fmt.Fprintf(output, "    %s %s\n", title("证书验证:"), warning(info.VerifyError))
+		}
+	}
+	fmt.Fprintf(output, "    %s %s\n\n", title("JARM:"), info.JARM)
+}
+
 // CreateScanOutputFromResults 从扫描结果创建输出数据
+// 各阶段的结果先合并为HostResult，再由NewPortScanOutput生成输出数据。
 func CreateScanOutputFromResults(target string, tcpResults []ScanResult, udpResults []UDPScanResult,
 	serviceInfo map[int]*ServiceInfo, hostStatus []HostStatus,
 	startTime time.Time, endTime time.Time) *PortScanOutput {
 
+	host := NewHostResult(target)
+	host.AddResults(ScanTypeTCP, tcpResults)
+	host.AddUDPResults(udpResults)
+	for _, status := range hostStatus {
+		if status.IP == target {
+			host.SetStatus(status)
+		}
+	}
+
+	// 单独检测的服务信息补充到对应的TCP端口
+	for port, info := range serviceInfo {
+		if info == nil {
+			continue
+		}
+		for i := range host.TCP {
+			if host.TCP[i].Port == port && (host.TCP[i].Service == nil || host.TCP[i].Service.Product == "") {
+				host.TCP[i].Service = &PortService{
+					Name:    info.Name,
+					Product: info.Product,
+					Version: info.Version,
+					CPE:     info.CPE,
+				}
+				if host.TCP[i].Banner == "" {
+					host.TCP[i].Banner = info.FullBanner
+				}
+			}
+		}
+	}
+	host.Complete(startTime, endTime)
+
+	output := NewPortScanOutput(host)
+	if len(hostStatus) > 0 {
+		output.HostDiscovery = hostStatus
+	}
+	return output
+}
+
+// defaultTCPReasons TCP端口状态的默认判定原因
+var defaultTCPReasons = map[PortState]string{
+	PortStateOpen:     "syn-ack",
+	PortStateClosed:   "reset",
+	PortStateFiltered: "no-response",
+}
+
+// NewPortScanOutput 从主机结果创建输出数据
+func NewPortScanOutput(host *HostResult) *PortScanOutput {
 	output := &PortScanOutput{
 		Summary: ScanSummary{
-			Target:    target,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Duration:  endTime.Sub(startTime),
+			Target:    host.Name(),
+			StartTime: host.Timing.Start,
+			EndTime:   host.Timing.End,
+			Duration:  host.Timing.Duration,
+			Honeypot:  host.Honeypot,
 		},
 		OpenPorts:     make([]PortInfo, 0),
 		ClosedPorts:   make([]PortInfo, 0),
 		FilteredPorts: make([]PortInfo, 0),
 		OSDetection:   make([]OSInfo, 0),
+		Host:          host,
 	}
 
-	// 处理TCP扫描结果
-	totalTCP := len(tcpResults)
-	openTCP := 0
-	closedTCP := 0
-	filteredTCP := 0
-	for _, result := range tcpResults {
+	for _, port := range host.Ports() {
 		portInfo := PortInfo{
-			Port:        result.Port,
-			Protocol:    "tcp",
-			ServiceName: result.ServiceName,
+			Port:     port.Port,
+			Protocol: port.Protocol,
+			State:    string(port.State),
+			Reason:   port.Reason,
 		}
-
-		switch result.State {
-		case PortStateOpen:
-			portInfo.State = "open"
-			portInfo.Reason = "syn-ack"
-			output.OpenPorts = append(output.OpenPorts, portInfo)
-			openTCP++
-		case PortStateClosed:
-			portInfo.State = "closed"
-			portInfo.Reason = "reset"
-			output.ClosedPorts = append(output.ClosedPorts, portInfo)
-			closedTCP++
-		case PortStateFiltered:
-			portInfo.State = "filtered"
-			portInfo.Reason = "no-response"
-			output.FilteredPorts = append(output.FilteredPorts, portInfo)
-			filteredTCP++
+		if port.Service != nil {
+			portInfo.ServiceName = port.Service.Name
 		}
-	}
-
-	// 处理UDP扫描结果
-	totalUDP := len(udpResults)
-	openUDP := 0
-	filteredUDP := 0
-	for _, result := range udpResults {
-		portInfo := PortInfo{
-			Port:     result.Port,
-			Protocol: "udp",
-			Reason:   result.Reason,
+		// 没有记录原因的TCP结果按状态给出默认原因
+		if port.Protocol == ProtocolTCP && portInfo.Reason == "" {
+			portInfo.Reason = defaultTCPReasons[port.State]
 		}
 
-		switch result.State {
-		case "open":
-			portInfo.State = "open"
+		switch port.State {
+		case PortStateOpen:
 			output.OpenPorts = append(output.OpenPorts, portInfo)
-			openUDP++
-		case "filtered":
-			portInfo.State = "filtered"
+		case PortStateFiltered, "open|filtered":
 			output.FilteredPorts = append(output.FilteredPorts, portInfo)
-			filteredUDP++
-		case "open|filtered":
-			portInfo.State = "open|filtered"
-			output.FilteredPorts = append(output.FilteredPorts, portInfo)
-			filteredUDP++
-		default:
-			portInfo.State = "closed"
+		case PortStateClosed:
 			output.ClosedPorts = append(output.ClosedPorts, portInfo)
 		}
-	}
 
-	// 添加服务版本信息
-	if serviceInfo != nil && len(serviceInfo) > 0 {
-		for port, info := range serviceInfo {
-			if info == nil {
-				continue
-			}
-			// 设置端口号
-			info.Port = port
-			output.ServiceVersions = append(output.ServiceVersions, *info)
+		// 服务版本信息
+		if port.Service != nil && port.State == PortStateOpen {
+			output.ServiceVersions = append(output.ServiceVersions, ServiceInfo{
+				Name:        port.Service.Name,
+				Port:        port.Port,
+				Version:     port.Service.Version,
+				Product:     port.Service.Product,
+				FullBanner:  port.Banner,
+				Fingerprint: port.Service.Fingerprint,
+				CPE:         port.Service.CPE,
+				TTL:         port.TTL,
+				TLS:         port.Service.TLS,
+			})
 		}
 	}
 
-	// 添加主机状态信息
-	if hostStatus != nil && len(hostStatus) > 0 {
-		output.HostDiscovery = hostStatus
+	// 主机发现结果
+	if host.Status != HostStateUnknown && host.Reason != "" {
+		output.HostDiscovery = []HostStatus{{
+			IP:      host.Address(),
+			Up:      host.Status == HostStateUp,
+			Method:  host.Reason,
+			Latency: host.Timing.RTT,
+		}}
 	}
 
-	// 添加OS检测结果
-	seenOSInfo := make(map[string]bool)
-	for _, result := range tcpResults {
-		if result.OS != nil && !seenOSInfo[result.OS.Name] {
-			seenOSInfo[result.OS.Name] = true
-
-			// 提取元数据
-			var items []MetadataItem
-			if result.OS.Metadata != nil {
-				for k, v := range result.OS.Metadata {
-					items = append(items, MetadataItem{
-						Key:   k,
-						Value: fmt.Sprintf("%v", v),
-					})
-				}
-			}
-
-			osInfo := OSInfo{
-				Name:       result.OS.Name,
-				Family:     result.OS.Family,
-				Version:    result.OS.Version,
-				Confidence: result.OS.Confidence,
-				Metadata: MetadataMap{
-					Items: items,
-				},
-			}
-			output.OSDetection = append(output.OSDetection, osInfo)
+	// OS检测结果
+	for _, match := range host.OS {
+		var items []MetadataItem
+		for k, v := range match.Metadata {
+			items = append(items, MetadataItem{Key: k, Value: v})
 		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+		output.OSDetection = append(output.OSDetection, OSInfo{
+			Name:       match.Name,
+			Family:     match.Family,
+			Version:    match.Version,
+			Confidence: match.Accuracy,
+			CPE:        match.CPE,
+			Metadata:   MetadataMap{Items: items},
+		})
 	}
 
-	// 更新统计信息
-	output.Summary.TotalPorts = totalTCP + totalUDP
-	output.Summary.OpenPorts = openTCP + openUDP
-	output.Summary.ClosedPorts = closedTCP + (totalUDP - openUDP - filteredUDP)
-	output.Summary.FilteredPorts = filteredTCP + filteredUDP
+	// 统计信息
+	output.Summary.TotalPorts = len(host.Ports())
+	output.Summary.OpenPorts = len(output.OpenPorts)
+	output.Summary.ClosedPorts = len(output.ClosedPorts)
+	output.Summary.FilteredPorts = len(output.FilteredPorts)
 
 	return output
 }
@@ -0,0 +1,355 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// PacketDirection 数据包方向
+type PacketDirection string
+
+const (
+	PacketSent     PacketDirection = "SENT" // 扫描器发出
+	PacketReceived PacketDirection = "RCVD" // 扫描器收到
+)
+
+// pcapng增强分组块(EPB)及选项编码
+const (
+	ngBlockTypeEnhancedPacket = 0x00000006
+	ngOptionComment           = 1
+	ngOptionEndOfOptions      = 0
+)
+
+// PacketRecorder 记录扫描收发的数据包，可写入pcapng文件并实时打印
+// 数据链路类型为LINKTYPE_RAW，每个数据包都是完整的IP报文
+type PacketRecorder struct {
+	file   io.Closer
+	raw    io.Writer
+	writer *pcapgo.NgWriter
+	trace  io.Writer
+	start  time.Time
+	count  int
+	err    error
+	mu     sync.Mutex
+}
+
+// NewPacketRecorder 创建数据包记录器，w为pcapng输出(可为nil)，trace为实时打印输出(可为nil)
+func NewPacketRecorder(w io.Writer, trace io.Writer) (*PacketRecorder, error) {
+	r := &PacketRecorder{raw: w, trace: trace, start: time.Now()}
+	if w == nil {
+		return r, nil
+	}
+
+	intf := pcapgo.DefaultNgInterface
+	intf.Name = "scan"
+	intf.LinkType = layers.LinkTypeRaw
+	intf.Comment = "go-port-rocket扫描收发的原始报文"
+
+	writer, err := pcapgo.NewNgWriterInterface(w, intf, pcapgo.NgWriterOptions{
+		SectionInfo: pcapgo.NgSectionInfo{
+			Hardware:    runtime.GOARCH,
+			OS:          runtime.GOOS,
+			Application: "go-port-rocket",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建pcapng写入器失败: %v", err)
+	}
+	r.writer = writer
+	return r, nil
+}
+
+// CreatePacketRecorder 创建写入pcapng文件的数据包记录器，path为空时只做实时打印
+func CreatePacketRecorder(path string, trace io.Writer) (*PacketRecorder, error) {
+	if path == "" {
+		return NewPacketRecorder(nil, trace)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建pcap文件失败: %v", err)
+	}
+	r, err := NewPacketRecorder(file, trace)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.file = file
+	return r, nil
+}
+
+// Record 记录一个原始IP报文，comment非空时作为pcapng分组注释写入
+func (r *PacketRecorder) Record(dir PacketDirection, data []byte, comment string) {
+	if r == nil || len(data) == 0 {
+		return
+	}
+	now := time.Now()
+	packet := append([]byte(nil), data...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.count++
+	if r.trace != nil {
+		fmt.Fprintln(r.trace, formatTraceLine(dir, now.Sub(r.start), packet, comment))
+	}
+	if r.writer == nil || r.err != nil {
+		return
+	}
+
+	comment = strings.TrimSpace(string(dir) + " " + comment)
+	r.err = r.writePacket(now, packet, comment)
+}
+
+// RecordStream 记录connect扫描的用户态数据交换
+// 内核协议栈不暴露真实报文，因此按连接地址合成TCP报文承载数据并加注释说明
+func (r *PacketRecorder) RecordStream(dir PacketDirection, local, remote net.Addr, payload []byte, comment string) {
+	if r == nil {
+		return
+	}
+	src, dst := local, remote
+	if dir == PacketReceived {
+		src, dst = remote, local
+	}
+	tcp := &layers.TCP{ACK: true, PSH: len(payload) > 0}
+	data, err := synthesizeTCPPacket(src, dst, tcp, payload)
+	if err != nil {
+		return
+	}
+	r.Record(dir, data, "[connect合成] "+comment)
+}
+
+// RecordHandshake 以合成的SYN与SYN/ACK报文记录connect扫描建立的连接
+// 重放分析据此把端口判定为开放，与SYN扫描的报文语义一致
+func (r *PacketRecorder) RecordHandshake(local, remote net.Addr) {
+	if r == nil {
+		return
+	}
+	if syn, err := synthesizeTCPPacket(local, remote, &layers.TCP{SYN: true}, nil); err == nil {
+		r.Record(PacketSent, syn, "[connect合成] connect()发起连接")
+	}
+	if synAck, err := synthesizeTCPPacket(remote, local, &layers.TCP{SYN: true, ACK: true}, nil); err == nil {
+		r.Record(PacketReceived, synAck, "[connect合成] connect()建立连接")
+	}
+}
+
+// Count 返回已记录的数据包数量
+func (r *PacketRecorder) Count() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Close 刷新并关闭pcapng文件，返回记录过程中的第一个错误
+func (r *PacketRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.err
+	if r.writer != nil {
+		if flushErr := r.writer.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+	if r.file != nil {
+		if closeErr := r.file.Close(); err == nil {
+			err = closeErr
+		}
+		r.file = nil
+	}
+	return err
+}
+
+// writePacket 写入一个数据包，带注释的数据包由记录器自行编码EPB块
+func (r *PacketRecorder) writePacket(ts time.Time, data []byte, comment string) error {
+	if comment == "" {
+		return r.writer.WritePacket(gopacket.CaptureInfo{
+			Timestamp:     ts,
+			CaptureLength: len(data),
+			Length:        len(data),
+		}, data)
+	}
+
+	// pcapgo的NgWriter不支持分组注释，先刷新其缓冲区再直接写入EPB块
+	if err := r.writer.Flush(); err != nil {
+		return err
+	}
+	_, err := r.raw.Write(encodeCommentedEPB(ts, data, comment))
+	return err
+}
+
+// encodeCommentedEPB 编码带opt_comment选项的pcapng增强分组块
+func encodeCommentedEPB(ts time.Time, data []byte, comment string) []byte {
+	pad := func(n int) int { return (4 - n%4) % 4 }
+	le := binary.LittleEndian
+
+	optLen := 4 + len(comment) + pad(len(comment)) + 4
+	blockLen := 28 + len(data) + pad(len(data)) + optLen + 4
+	nanos := uint64(ts.UnixNano())
+
+	buf := make([]byte, 0, blockLen)
+	buf = le.AppendUint32(buf, ngBlockTypeEnhancedPacket)
+	buf = le.AppendUint32(buf, uint32(blockLen))
+	buf = le.AppendUint32(buf, 0) // 接口ID
+	buf = le.AppendUint32(buf, uint32(nanos>>32))
+	buf = le.AppendUint32(buf, uint32(nanos))
+	buf = le.AppendUint32(buf, uint32(len(data)))
+	buf = le.AppendUint32(buf, uint32(len(data)))
+	buf = append(buf, data...)
+	buf = append(buf, make([]byte, pad(len(data)))...)
+
+	buf = le.AppendUint16(buf, ngOptionComment)
+	buf = le.AppendUint16(buf, uint16(len(comment)))
+	buf = append(buf, comment...)
+	buf = append(buf, make([]byte, pad(len(comment)))...)
+	buf = le.AppendUint16(buf, ngOptionEndOfOptions)
+	buf = le.AppendUint16(buf, 0)
+
+	return le.AppendUint32(buf, uint32(blockLen))
+}
+
+// synthesizeTCPPacket 按连接地址合成TCP报文，tcp中只需设置标志位
+func synthesizeTCPPacket(src, dst net.Addr, tcp *layers.TCP, payload []byte) ([]byte, error) {
+	srcAddr, ok1 := src.(*net.TCPAddr)
+	dstAddr, ok2 := dst.(*net.TCPAddr)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("不是TCP地址")
+	}
+
+	tcp.SrcPort = layers.TCPPort(srcAddr.Port)
+	tcp.DstPort = layers.TCPPort(dstAddr.Port)
+	tcp.Window = 65535
+
+	var network gopacket.SerializableLayer
+	if src4, dst4 := srcAddr.IP.To4(), dstAddr.IP.To4(); src4 != nil && dst4 != nil {
+		ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: src4, DstIP: dst4}
+		tcp.SetNetworkLayerForChecksum(ip)
+		network = ip
+	} else {
+		ip := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolTCP, SrcIP: srcAddr.IP, DstIP: dstAddr.IP}
+		tcp.SetNetworkLayerForChecksum(ip)
+		network = ip
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, network, tcp, gopacket.Payload(payload)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatTraceLine 生成类似nmap --packet-trace的单行描述
+func formatTraceLine(dir PacketDirection, offset time.Duration, data []byte, comment string) string {
+	line := fmt.Sprintf("%s (%.4fs) %s", dir, offset.Seconds(), describePacket(data))
+	if comment != "" {
+		line += " # " + comment
+	}
+	return line
+}
+
+// describePacket 解析IP报文并生成简要描述
+func describePacket(data []byte) string {
+	first := layers.LayerTypeIPv4
+	if len(data) > 0 && data[0]>>4 == 6 {
+		first = layers.LayerTypeIPv6
+	}
+	packet := gopacket.NewPacket(data, first, gopacket.NoCopy)
+
+	var src, dst, ipInfo string
+	if ip4, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		src, dst = ip4.SrcIP.String(), ip4.DstIP.String()
+		ipInfo = fmt.Sprintf("ttl=%d id=%d iplen=%d", ip4.TTL, ip4.Id, len(data))
+	} else if ip6, ok := packet.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		src, dst = ip6.SrcIP.String(), ip6.DstIP.String()
+		ipInfo = fmt.Sprintf("hlim=%d iplen=%d", ip6.HopLimit, len(data))
+	} else {
+		return fmt.Sprintf("未知报文 len=%d", len(data))
+	}
+
+	if tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		return fmt.Sprintf("TCP %s > %s %s %s seq=%d win=%d len=%d",
+			net.JoinHostPort(src, strconv.Itoa(int(tcp.SrcPort))), net.JoinHostPort(dst, strconv.Itoa(int(tcp.DstPort))),
+			tcpFlagString(tcp), ipInfo, tcp.Seq, tcp.Window, len(tcp.Payload))
+	}
+	if icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+		return fmt.Sprintf("ICMP %s > %s %s %s", src, dst, icmp.TypeCode.String(), ipInfo)
+	}
+	if udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		return fmt.Sprintf("UDP %s > %s %s len=%d",
+			net.JoinHostPort(src, strconv.Itoa(int(udp.SrcPort))), net.JoinHostPort(dst, strconv.Itoa(int(udp.DstPort))),
+			ipInfo, len(udp.Payload))
+	}
+	return fmt.Sprintf("IP %s > %s %s", src, dst, ipInfo)
+}
+
+// tcpFlagString 按nmap习惯输出TCP标志位
+func tcpFlagString(tcp *layers.TCP) string {
+	flags := ""
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{tcp.FIN, "F"}, {tcp.SYN, "S"}, {tcp.RST, "R"}, {tcp.PSH, "P"},
+		{tcp.ACK, "A"}, {tcp.URG, "U"}, {tcp.ECE, "E"}, {tcp.CWR, "C"},
+	} {
+		if f.set {
+			flags += f.name
+		}
+	}
+	if flags == "" {
+		return "NONE"
+	}
+	return flags
+}
+
+// captureConn 记录connect扫描连接上的用户态数据交换
+type captureConn struct {
+	net.Conn
+	tap *PacketRecorder
+}
+
+// wrapConn 为连接记录握手并在记录器非空时包装连接
+func wrapConn(conn net.Conn, tap *PacketRecorder) net.Conn {
+	if tap == nil {
+		return conn
+	}
+	tap.RecordHandshake(conn.LocalAddr(), conn.RemoteAddr())
+	return &captureConn{Conn: conn, tap: tap}
+}
+
+// Write 记录发送的探测数据
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.tap.RecordStream(PacketSent, c.LocalAddr(), c.RemoteAddr(), b[:n], fmt.Sprintf("发送探测数据 %d 字节", n))
+	}
+	return n, err
+}
+
+// Read 记录收到的banner/响应数据
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.tap.RecordStream(PacketReceived, c.LocalAddr(), c.RemoteAddr(), b[:n], fmt.Sprintf("收到响应数据 %d 字节", n))
+	}
+	return n, err
+}
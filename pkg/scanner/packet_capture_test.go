@@ -0,0 +1,122 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildTestSYN 构造测试用的SYN报文
+func buildTestSYN(t *testing.T) []byte {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP,
+		SrcIP: net.ParseIP("10.0.0.1").To4(), DstIP: net.ParseIP("10.0.0.2").To4()}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 80, SYN: true, Seq: 1, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}, ip, tcp)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestPacketRecorderWritesPcapng(t *testing.T) {
+	var file, trace bytes.Buffer
+	recorder, err := NewPacketRecorder(&file, &trace)
+	require.NoError(t, err)
+
+	syn := buildTestSYN(t)
+	recorder.Record(PacketSent, syn, "")
+	local := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 50000}
+	remote := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+	recorder.RecordStream(PacketReceived, local, remote, []byte("SSH-2.0-OpenSSH_8.9\r\n"), "banner")
+	require.NoError(t, recorder.Close())
+	assert.Equal(t, 2, recorder.Count())
+
+	reader, err := pcapgo.NewNgReader(bytes.NewReader(file.Bytes()), pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+	assert.Equal(t, layers.LinkTypeRaw, reader.LinkType())
+
+	var packets [][]byte
+	for {
+		data, _, err := reader.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		packets = append(packets, data)
+	}
+	require.Len(t, packets, 2)
+	assert.Equal(t, syn, packets[0])
+
+	// 合成的banner报文方向为远端到本地，载荷为banner
+	packet := gopacket.NewPacket(packets[1], layers.LayerTypeIPv4, gopacket.Default)
+	tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	require.True(t, ok)
+	assert.Equal(t, layers.TCPPort(22), tcp.SrcPort)
+	assert.Equal(t, layers.TCPPort(50000), tcp.DstPort)
+	assert.Equal(t, "SSH-2.0-OpenSSH_8.9\r\n", string(tcp.Payload))
+
+	// 注释以pcapng选项写入
+	assert.Contains(t, file.String(), "[connect合成] banner")
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^SENT \(\d+\.\d{4}s\) TCP 10\.0\.0\.1:40000 > 10\.0\.0\.2:80 S ttl=64`, lines[0])
+	assert.Regexp(t, `^RCVD .* TCP 127\.0\.0\.1:22 > 127\.0\.0\.1:50000 PA .* len=21 # \[connect合成\] banner$`, lines[1])
+}
+
+func TestPacketRecorderNilSafe(t *testing.T) {
+	var recorder *PacketRecorder
+	recorder.Record(PacketSent, []byte{0x45}, "")
+	assert.Equal(t, 0, recorder.Count())
+	assert.NoError(t, recorder.Close())
+}
+
+func TestConnectScanCaptureRecordsBanner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 test ftp\r\n"))
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	var trace bytes.Buffer
+	recorder, err := NewPacketRecorder(nil, &trace)
+	require.NoError(t, err)
+
+	results, err := NewTCPScanner().Scan(context.Background(), &ScanOptions{
+		Target:       "127.0.0.1",
+		Ports:        strconv.Itoa(port),
+		Timeout:      time.Second,
+		Workers:      1,
+		ServiceProbe: true,
+		Capture:      recorder,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "220 test ftp\r\n", results[0].Banner)
+
+	output := trace.String()
+	assert.Contains(t, output, "connect()建立连接")
+	assert.Contains(t, output, "发送探测数据 2 字节")
+	assert.Contains(t, output, "收到响应数据 14 字节")
+}
@@ -0,0 +1,326 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// planTargetSample 扫描计划中最多列出的目标数
+const planTargetSample = 16
+
+// 各阶段对每个端口增加的耗时，与扫描建议器的估算一致
+const (
+	planServiceCost = time.Second
+	planBannerCost  = 500 * time.Millisecond
+	planOSCost      = 2 * time.Second
+	planOSProbes    = 5 // SEQ、ICMP、ECN、TCP选项、UDP
+)
+
+// PlanStage 扫描计划中的一个阶段
+type PlanStage struct {
+	Name         string        `json:"name"`          // 阶段名称
+	Description  string        `json:"description"`   // 阶段说明
+	Probes       int64         `json:"probes"`        // 探测次数
+	MaxProbes    int64         `json:"max_probes"`    // 计入重试后的探测次数上限
+	Duration     time.Duration `json:"duration"`      // 预估耗时
+	RequiresRoot bool          `json:"requires_root"` // 是否需要root权限
+}
+
+// ScanPlan 扫描计划，由PlanScan生成，不访问网络
+type ScanPlan struct {
+	Targets           []string      `json:"targets"`            // 展开后的目标(最多列出planTargetSample个)
+	TargetCount       int64         `json:"target_count"`       // 目标总数
+	UnresolvedTargets []string      `json:"unresolved_targets"` // 未解析的域名目标
+	PortCount         int           `json:"port_count"`         // 每个目标的端口数
+	ScanType          ScanType      `json:"scan_type"`          // 扫描类型
+	Timeout           time.Duration `json:"timeout"`            // 单次探测超时
+	Workers           int           `json:"workers"`            // 并发数
+	MaxRetries        int           `json:"max_retries"`        // 最大重试次数
+	Stages            []PlanStage   `json:"stages"`             // 各阶段
+	TotalProbes       int64         `json:"total_probes"`       // 探测总数
+	EstimatedDuration time.Duration `json:"estimated_duration"` // 预估总耗时(按探测均等待超时估算)
+	PacketsPerSecond  float64       `json:"packets_per_second"` // 预估探测速率
+	RootFeatures      []string      `json:"root_features"`      // 需要root权限的功能
+	HasRoot           bool          `json:"has_root"`           // 当前进程是否为root
+	Warnings          []string      `json:"warnings"`           // 风险提示
+}
+
+// PlanScan 展开目标与端口并估算探测次数与耗时，不发送任何报文
+func PlanScan(opts *ScanOptions) (*ScanPlan, error) {
+	if opts == nil {
+		return nil, ErrInvalidOptions
+	}
+	if opts.Target == "" {
+		return nil, ErrInvalidTarget
+	}
+	if opts.Ports == "" {
+		return nil, ErrInvalidPorts
+	}
+
+	scanType := opts.ScanType
+	if scanType == "" {
+		scanType = ScanTypeTCP
+	}
+	if err := ValidateScanType(scanType); err != nil {
+		return nil, err
+	}
+
+	ports, err := parsePorts(opts.Ports)
+	if err != nil {
+		return nil, err
+	}
+	targets, count, unresolved, err := expandPlanTargets(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	// 与扫描器相同的默认值
+	planOpts := *opts
+	planOpts.ScanType = scanType
+	if planOpts.Timeout <= 0 {
+		planOpts.Timeout = defaultTimeout(len(ports))
+	}
+	if planOpts.Workers <= 0 {
+		planOpts.Workers = calculateOptimalWorkers(len(ports))
+	}
+
+	plan := &ScanPlan{
+		Targets:           targets,
+		TargetCount:       count,
+		UnresolvedTargets: unresolved,
+		PortCount:         len(ports),
+		ScanType:          scanType,
+		Timeout:           planOpts.Timeout,
+		Workers:           planOpts.Workers,
+		MaxRetries:        planOpts.MaxRetries,
+		Stages:            planStages(&planOpts, count, len(ports)),
+		HasRoot:           os.Geteuid() == 0,
+	}
+
+	for _, stage := range plan.Stages {
+		plan.TotalProbes += stage.Probes
+		plan.EstimatedDuration += stage.Duration
+		if stage.RequiresRoot {
+			plan.RootFeatures = append(plan.RootFeatures, stage.Description)
+		}
+	}
+	if plan.EstimatedDuration > 0 {
+		plan.PacketsPerSecond = float64(plan.TotalProbes) / plan.EstimatedDuration.Seconds()
+	}
+
+	plan.Warnings = planWarnings(plan, &planOpts)
+	return plan, nil
+}
+
+// planStages 计算各阶段的探测次数与耗时
+// 服务与操作系统检测按全部端口开放估算，为耗时上限
+func planStages(opts *ScanOptions, targets int64, portCount int) []PlanStage {
+	probes := targets * int64(portCount)
+	workers := int64(max(opts.Workers, 1))
+	perPort := func(cost time.Duration) time.Duration {
+		return time.Duration(probes) * cost / time.Duration(workers)
+	}
+
+	reg, _ := LookupScanner(opts.ScanType)
+	stages := []PlanStage{{
+		Name:         "port-scan",
+		Description:  fmt.Sprintf("%s扫描", opts.ScanType),
+		Probes:       probes,
+		MaxProbes:    probes * int64(1+max(opts.MaxRetries, 0)),
+		Duration:     perPort(opts.Timeout),
+		RequiresRoot: reg.Capabilities.RequiresRoot,
+	}}
+
+	if opts.EnableService {
+		stages = append(stages, PlanStage{
+			Name:        "service-detection",
+			Description: "服务版本检测",
+			Probes:      probes,
+			MaxProbes:   probes,
+			Duration:    perPort(planServiceCost),
+		})
+	}
+	if opts.BannerProbe || opts.ServiceProbe {
+		stages = append(stages, PlanStage{
+			Name:        "banner-grab",
+			Description: "服务探测与banner获取",
+			Probes:      probes,
+			MaxProbes:   probes,
+			Duration:    perPort(planBannerCost),
+		})
+	}
+	if opts.EnableOS {
+		stages = append(stages, PlanStage{
+			Name:         "os-detection",
+			Description:  "操作系统检测(ICMP原始套接字)",
+			Probes:       probes * planOSProbes,
+			MaxProbes:    probes * planOSProbes,
+			Duration:     perPort(planOSCost),
+			RequiresRoot: true,
+		})
+	}
+	return stages
+}
+
+// planWarnings 生成扫描计划的风险提示
+func planWarnings(plan *ScanPlan, opts *ScanOptions) []string {
+	var warnings []string
+	if len(plan.RootFeatures) > 0 && !plan.HasRoot {
+		warnings = append(warnings, "当前不是root用户，以下功能将无法执行: "+strings.Join(plan.RootFeatures, ", "))
+	}
+	if plan.TargetCount > 1 {
+		warnings = append(warnings, fmt.Sprintf("共 %d 个目标，scan命令一次只扫描一个目标，需逐个执行", plan.TargetCount))
+	}
+	if len(plan.UnresolvedTargets) > 0 {
+		warnings = append(warnings, "域名目标未解析，按单个主机估算: "+strings.Join(plan.UnresolvedTargets, ", "))
+	}
+	if opts.HostTimeout > 0 && plan.TargetCount > 0 {
+		perHost := plan.EstimatedDuration / time.Duration(plan.TargetCount)
+		if perHost > opts.HostTimeout {
+			warnings = append(warnings, fmt.Sprintf("单主机预估耗时 %s 超过单主机超时 %s，主机可能在完成前被放弃",
+				formatDuration(perHost), formatDuration(opts.HostTimeout)))
+		}
+	}
+	if plan.MaxRetries > 0 {
+		warnings = append(warnings, fmt.Sprintf("端口超时后最多重试 %d 次，探测次数最多可达 %d", plan.MaxRetries, plan.maxProbes()))
+	}
+	return warnings
+}
+
+// maxProbes 计入重试后的探测总数上限
+func (p *ScanPlan) maxProbes() int64 {
+	var total int64
+	for _, stage := range p.Stages {
+		total += stage.MaxProbes
+	}
+	return total
+}
+
+// Print 以文本形式输出扫描计划
+func (p *ScanPlan) Print(w io.Writer) {
+	fmt.Fprintln(w, "📋 扫描计划 (dry-run，不会发送任何报文)")
+	fmt.Fprintf(w, "目标: %d 个", p.TargetCount)
+	if len(p.Targets) > 0 {
+		fmt.Fprintf(w, " (%s", strings.Join(p.Targets, ", "))
+		if int64(len(p.Targets)) < p.TargetCount {
+			fmt.Fprint(w, ", ...")
+		}
+		fmt.Fprint(w, ")")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "端口: 每个目标 %d 个\n", p.PortCount)
+	fmt.Fprintf(w, "扫描类型: %s  超时: %s  并发: %d  最大重试: %d\n", p.ScanType, p.Timeout, p.Workers, p.MaxRetries)
+
+	fmt.Fprintln(w, "\n阶段:")
+	for i, stage := range p.Stages {
+		root := ""
+		if stage.RequiresRoot {
+			root = " [需root]"
+		}
+		fmt.Fprintf(w, "  %d. %-18s 探测 %-8d 预估 %s%s\n", i+1, stage.Name, stage.Probes, formatDuration(stage.Duration), root)
+	}
+
+	fmt.Fprintf(w, "\n探测总数: %d\n", p.TotalProbes)
+	fmt.Fprintf(w, "预估耗时: %s (按所有探测均等待超时估算)\n", formatDuration(p.EstimatedDuration))
+	fmt.Fprintf(w, "预估速率: %.1f 包/秒\n", p.PacketsPerSecond)
+
+	if len(p.RootFeatures) > 0 {
+		fmt.Fprintf(w, "需要root权限: %s (当前root: %v)\n", strings.Join(p.RootFeatures, ", "), p.HasRoot)
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(w, "⚠️  %s\n", warning)
+	}
+}
+
+// maxExpandedTargets ExpandTargets允许展开的最大主机数
+const maxExpandedTargets = 65536
+
+// ExpandTargets 将目标描述展开为主机列表，支持逗号分隔、CIDR和末段范围，域名原样保留
+func ExpandTargets(spec string) ([]string, error) {
+	targets, count, _, err := expandTargets(spec, maxExpandedTargets)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxExpandedTargets {
+		return nil, fmt.Errorf("目标数量过多: %d (上限 %d)", count, maxExpandedTargets)
+	}
+	return targets, nil
+}
+
+// expandPlanTargets 展开目标描述，支持逗号分隔、CIDR和末段范围(如192.168.1.1-20)
+// 域名不做解析，按单个主机计数
+func expandPlanTargets(spec string) ([]string, int64, []string, error) {
+	return expandTargets(spec, planTargetSample)
+}
+
+// isAddressRange 判断是否为末段范围(如192.168.1.1-20)，连字符前不是IPv4地址的按域名处理
+func isAddressRange(part string) bool {
+	idx := strings.LastIndex(part, "-")
+	return idx > 0 && net.ParseIP(part[:idx]).To4() != nil
+}
+
+// expandTargets 展开目标描述，最多列出limit个目标，返回的计数为全部目标数
+func expandTargets(spec string, limit int) ([]string, int64, []string, error) {
+	var sample, unresolved []string
+	var count int64
+	add := func(ip string) {
+		count++
+		if len(sample) < limit {
+			sample = append(sample, ip)
+		}
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		switch {
+		case strings.Contains(part, "/"):
+			ip, ipnet, err := net.ParseCIDR(part)
+			if err != nil {
+				return nil, 0, nil, fmt.Errorf("无效的网段: %s", part)
+			}
+			ones, bits := ipnet.Mask.Size()
+			size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+			if !size.IsInt64() {
+				return nil, 0, nil, fmt.Errorf("网段过大: %s", part)
+			}
+			// 只列出样本，计数按网段大小计算
+			cur := ip.Mask(ipnet.Mask)
+			for i := int64(0); i < size.Int64() && len(sample) < limit; i++ {
+				sample = append(sample, cur.String())
+				cur = append(net.IP(nil), cur...)
+				inc(cur)
+			}
+			count += size.Int64()
+		case net.ParseIP(part) != nil:
+			add(part)
+		case isAddressRange(part):
+			idx := strings.LastIndex(part, "-")
+			start := net.ParseIP(part[:idx]).To4()
+			end, err := strconv.Atoi(part[idx+1:])
+			if err != nil || end < int(start[3]) || end > 255 {
+				return nil, 0, nil, fmt.Errorf("无效的地址范围: %s", part)
+			}
+			for last := int(start[3]); last <= end; last++ {
+				add(net.IPv4(start[0], start[1], start[2], byte(last)).String())
+			}
+		default:
+			add(part)
+			unresolved = append(unresolved, part)
+		}
+	}
+
+	if count == 0 {
+		return nil, 0, nil, ErrInvalidTarget
+	}
+	return sample, count, unresolved, nil
+}
@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPlanTargets(t *testing.T) {
+	tests := []struct {
+		spec       string
+		count      int64
+		sample     []string
+		unresolved []string
+	}{
+		{"192.168.1.1", 1, []string{"192.168.1.1"}, nil},
+		{"10.0.0.0/30", 4, []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}, nil},
+		{"10.0.0.1-3, example.com", 4, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "example.com"}, []string{"example.com"}},
+		{"10.0.0.0/8", 1 << 24, nil, nil},
+		{"scanme-test.example.org", 1, []string{"scanme-test.example.org"}, []string{"scanme-test.example.org"}},
+		{"10.0.0.9,my-host", 2, []string{"10.0.0.9", "my-host"}, []string{"my-host"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			sample, count, unresolved, err := expandPlanTargets(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, count)
+			assert.Equal(t, tt.unresolved, unresolved)
+			if tt.sample != nil {
+				assert.Equal(t, tt.sample, sample)
+			} else {
+				// 大网段只列出样本
+				assert.Len(t, sample, planTargetSample)
+			}
+		})
+	}
+
+	for _, spec := range []string{"10.0.0.0/33", "10.0.0.5-2", ",", "10.0.0.1-300"} {
+		_, _, _, err := expandPlanTargets(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestExpandTargetsAndShardPorts(t *testing.T) {
+	targets, err := ExpandTargets("10.0.0.0/30,10.0.1.7-8,example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.1.7", "10.0.1.8", "example.com"}, targets)
+
+	_, err = ExpandTargets("10.0.0.0/8")
+	assert.Error(t, err)
+
+	// 带连字符的域名不是地址范围
+	targets, err = ExpandTargets("scanme-test.example.org")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"scanme-test.example.org"}, targets)
+	assert.Equal(t, "scanme-test.example.org", tlsServerName("scanme-test.example.org"))
+
+	shards, err := ShardPorts("80,1-5,22,3", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1-3", "4-5,22", "80"}, shards)
+
+	_, err = ShardPorts("1-10", 0)
+	assert.Error(t, err)
+}
+
+func TestPlanScan(t *testing.T) {
+	plan, err := PlanScan(&ScanOptions{
+		Target:        "10.0.0.0/30",
+		Ports:         "1-100",
+		ScanType:      ScanTypeSYN,
+		Timeout:       time.Second,
+		Workers:       10,
+		MaxRetries:    2,
+		EnableService: true,
+		EnableOS:      true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), plan.TargetCount)
+	assert.Equal(t, 100, plan.PortCount)
+	require.Len(t, plan.Stages, 3)
+
+	scan := plan.Stages[0]
+	assert.Equal(t, "port-scan", scan.Name)
+	assert.Equal(t, int64(400), scan.Probes)
+	assert.Equal(t, int64(1200), scan.MaxProbes)
+	assert.Equal(t, 40*time.Second, scan.Duration)
+	assert.True(t, scan.RequiresRoot)
+
+	assert.Equal(t, "service-detection", plan.Stages[1].Name)
+	assert.False(t, plan.Stages[1].RequiresRoot)
+	assert.Equal(t, int64(2000), plan.Stages[2].Probes)
+
+	assert.Equal(t, int64(400+400+2000), plan.TotalProbes)
+	assert.Equal(t, 40*time.Second+40*time.Second+80*time.Second, plan.EstimatedDuration)
+	assert.InDelta(t, 2800.0/160.0, plan.PacketsPerSecond, 0.001)
+	assert.Len(t, plan.RootFeatures, 2)
+	assert.NotEmpty(t, plan.Warnings)
+
+	var out bytes.Buffer
+	plan.Print(&out)
+	assert.Contains(t, out.String(), "dry-run")
+	assert.Contains(t, out.String(), "port-scan")
+	assert.Contains(t, out.String(), "10.0.0.3")
+}
+
+func TestPlanScanDefaults(t *testing.T) {
+	plan, err := PlanScan(&ScanOptions{Target: "example.com", Ports: "80,443"})
+	require.NoError(t, err)
+	assert.Equal(t, ScanTypeTCP, plan.ScanType)
+	assert.Equal(t, defaultTimeout(2), plan.Timeout)
+	assert.Equal(t, calculateOptimalWorkers(2), plan.Workers)
+	assert.Empty(t, plan.RootFeatures)
+	assert.Equal(t, []string{"example.com"}, plan.UnresolvedTargets)
+
+	_, err = PlanScan(&ScanOptions{Target: "example.com", Ports: "80", ScanType: "bogus"})
+	assert.Error(t, err)
+	_, err = PlanScan(&ScanOptions{Ports: "80"})
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+}
+
+func TestPlanMatchesAdvisorEstimate(t *testing.T) {
+	opts := &ScanOptions{
+		Target:        "192.168.1.1",
+		Ports:         "1-1000",
+		ScanType:      ScanTypeTCP,
+		Timeout:       2 * time.Second,
+		Workers:       50,
+		EnableService: true,
+	}
+	plan, err := PlanScan(opts)
+	require.NoError(t, err)
+
+	advisor, err := NewScanAdvisor(opts)
+	require.NoError(t, err)
+	assert.Equal(t, advisor.estimateScanTime(), plan.EstimatedDuration)
+}
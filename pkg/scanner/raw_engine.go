@@ -0,0 +1,396 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// rawPollInterval 接收协程每次读取的等待时间，用于及时响应关闭
+const rawPollInterval = 50 * time.Millisecond
+
+// rawReply 与某个探测端口匹配的应答
+type rawReply struct {
+	tcp  *layers.TCP
+	icmp *layers.ICMPv4
+	ttl  int
+	ipid int
+}
+
+// rawEngine 原始报文扫描引擎
+// 构造探测报文经RawTransport发出，由单个接收协程按目标端口分发应答
+type rawEngine struct {
+	transport RawTransport
+	owned     bool // 传输由引擎创建，关闭引擎时一并关闭
+	scanType  ScanType
+	tap       *PacketRecorder
+	target    net.IP
+	source    net.IP
+	srcPort   layers.TCPPort
+	retries   int              // 无应答端口的重试次数
+	observe   func(ScanResult) // 每个端口得到结果后调用，供主机放弃策略判定tarpit
+
+	mu      sync.Mutex
+	waiters map[layers.TCPPort]chan rawReply
+	readErr error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newRawEngine 创建原始报文扫描引擎，transport为空时使用同时接收TCP和ICMP报文的原始套接字
+func newRawEngine(target string, scanType ScanType, transport RawTransport, tap *PacketRecorder) (*rawEngine, error) {
+	dst := net.ParseIP(target)
+	if dst == nil {
+		addr, err := net.ResolveIPAddr("ip4", target)
+		if err != nil {
+			return nil, fmt.Errorf("无效的目标IP地址: %s", target)
+		}
+		dst = addr.IP
+	}
+	if dst.To4() == nil {
+		return nil, fmt.Errorf("原始报文引擎只支持IPv4: %s", target)
+	}
+
+	owned := false
+	if transport == nil {
+		// 原始TCP套接字收不到ICMP不可达，被ICMP拒绝的端口会误判为无应答
+		t, err := newICMPSocketTransport()
+		if err != nil {
+			return nil, err
+		}
+		transport, owned = t, true
+	}
+
+	src, err := transport.LocalIP(dst)
+	if err != nil {
+		if owned {
+			transport.Close()
+		}
+		return nil, err
+	}
+
+	e := &rawEngine{
+		transport: transport,
+		owned:     owned,
+		scanType:  scanType,
+		tap:       tap,
+		target:    dst.To4(),
+		source:    src.To4(),
+		srcPort:   layers.TCPPort(40000 + rand.Intn(20000)),
+		waiters:   make(map[layers.TCPPort]chan rawReply),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go e.receive()
+	return e, nil
+}
+
+// close 停止接收协程并释放传输
+func (e *rawEngine) close() error {
+	close(e.stop)
+	<-e.done
+	if e.owned {
+		return e.transport.Close()
+	}
+	return nil
+}
+
+// receive 持续读取应答报文并分发给等待中的探测
+func (e *rawEngine) receive() {
+	defer close(e.done)
+	for {
+		select {
+		case <-e.stop:
+			return
+		default:
+		}
+
+		data, err := e.transport.ReadPacket(time.Now().Add(rawPollInterval))
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			e.mu.Lock()
+			e.readErr = err
+			e.mu.Unlock()
+			return
+		}
+		e.dispatch(data)
+	}
+}
+
+// dispatch 解析应答报文，匹配到探测端口时投递
+func (e *rawEngine) dispatch(data []byte) {
+	packet := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.NoCopy)
+	ip, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		return
+	}
+
+	var port layers.TCPPort
+	reply := rawReply{ttl: int(ip.TTL), ipid: int(ip.Id)}
+	switch {
+	case ip.Protocol == layers.IPProtocolTCP:
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok || !ip.SrcIP.Equal(e.target) || tcp.DstPort != e.srcPort {
+			return
+		}
+		port, reply.tcp = tcp.SrcPort, tcp
+	case ip.Protocol == layers.IPProtocolICMPv4:
+		// ICMP不可达可能来自中间路由，按内层报文匹配
+		icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+		if !ok || icmp.TypeCode.Type() != layers.ICMPv4TypeDestinationUnreachable {
+			return
+		}
+		inner := gopacket.NewPacket(icmp.Payload, layers.LayerTypeIPv4, gopacket.NoCopy)
+		innerIP, ok := inner.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+		if !ok || !innerIP.DstIP.Equal(e.target) || innerIP.Protocol != layers.IPProtocolTCP {
+			return
+		}
+		// 内层报文可能只带TCP头前8字节，直接读取端口
+		payload := innerIP.Payload
+		if len(payload) < 4 || layers.TCPPort(uint16(payload[0])<<8|uint16(payload[1])) != e.srcPort {
+			return
+		}
+		port, reply.icmp = layers.TCPPort(uint16(payload[2])<<8|uint16(payload[3])), icmp
+	default:
+		return
+	}
+
+	e.mu.Lock()
+	ch, ok := e.waiters[port]
+	e.mu.Unlock()
+	if !ok {
+		return
+	}
+	e.tap.Record(PacketReceived, data, "")
+	select {
+	case ch <- reply:
+	default:
+	}
+}
+
+// buildProbe 按扫描类型构造探测报文
+func (e *rawEngine) buildProbe(port layers.TCPPort) ([]byte, error) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Id:       uint16(rand.Intn(65536)),
+		Flags:    layers.IPv4DontFragment,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    e.source,
+		DstIP:    e.target,
+	}
+	tcp := &layers.TCP{
+		SrcPort: e.srcPort,
+		DstPort: port,
+		Seq:     rand.Uint32(),
+		Window:  1024,
+	}
+
+	switch e.scanType {
+	case ScanTypeSYN:
+		tcp.SYN = true
+		tcp.Options = []layers.TCPOption{
+			{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}},
+		}
+	case ScanTypeFIN:
+		tcp.FIN = true
+	case ScanTypeNULL:
+	case ScanTypeXMAS:
+		tcp.FIN, tcp.PSH, tcp.URG = true, true, true
+	case ScanTypeACK:
+		tcp.ACK = true
+		tcp.Ack = rand.Uint32()
+	default:
+		return nil, fmt.Errorf("原始报文引擎不支持的扫描类型: %s", e.scanType)
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp); err != nil {
+		return nil, fmt.Errorf("构造探测报文失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// probe 向单个端口发送探测并等待应答
+func (e *rawEngine) probe(ctx context.Context, port int, timeout time.Duration) (ScanResult, error) {
+	tcpPort := layers.TCPPort(port)
+	ch := make(chan rawReply, 1)
+	e.mu.Lock()
+	e.waiters[tcpPort] = ch
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.waiters, tcpPort)
+		e.mu.Unlock()
+	}()
+
+	packet, err := e.buildProbe(tcpPort)
+	if err != nil {
+		return ScanResult{Port: port, State: PortStateUnknown, Type: e.scanType}, err
+	}
+	if err := e.transport.WritePacket(packet, e.target); err != nil {
+		return ScanResult{Port: port, State: PortStateUnknown, Type: e.scanType}, fmt.Errorf("发送探测报文失败: %v", err)
+	}
+	e.tap.Record(PacketSent, packet, "")
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-ch:
+		return e.classify(port, &reply), nil
+	case <-timer.C:
+		return e.classify(port, nil), nil
+	case <-ctx.Done():
+		return ScanResult{Port: port, State: PortStateUnknown, Type: e.scanType}, ctx.Err()
+	}
+}
+
+// classify 根据应答判断端口状态
+func (e *rawEngine) classify(port int, reply *rawReply) ScanResult {
+	result := ScanResult{Port: port, Type: e.scanType, Metadata: make(map[string]interface{})}
+
+	switch {
+	case reply == nil:
+		// FIN/NULL/XMAS扫描中开放端口不应答，其余类型无应答视为被过滤
+		result.Metadata["reason"] = "no-response"
+		switch e.scanType {
+		case ScanTypeFIN, ScanTypeNULL, ScanTypeXMAS:
+			result.State = PortStateOpen
+		default:
+			result.State = PortStateFiltered
+		}
+	case reply.icmp != nil:
+		result.State = PortStateFiltered
+		result.TTL = reply.ttl
+		result.Metadata["reason"] = "icmp-unreach-" + strconv.Itoa(int(reply.icmp.TypeCode.Code()))
+	case reply.tcp.RST:
+		result.State = PortStateClosed
+		result.TTL = reply.ttl
+		result.Metadata["reason"] = "reset"
+		result.Metadata["ipid"] = reply.ipid
+		if e.scanType == ScanTypeACK {
+			// ACK扫描收到RST说明端口未被过滤
+			result.Metadata["unfiltered"] = true
+		}
+	case reply.tcp.SYN && reply.tcp.ACK:
+		result.State = PortStateOpen
+		result.TTL = reply.ttl
+		result.Metadata["reason"] = "syn-ack"
+		result.Metadata["ipid"] = reply.ipid
+		result.Metadata["window"] = int(reply.tcp.Window)
+		if opts := tcpOptionString(reply.tcp.Options); opts != "" {
+			result.Metadata["tcp_options"] = opts
+		}
+	default:
+		result.State = PortStateUnknown
+		result.TTL = reply.ttl
+		result.Metadata["reason"] = "unexpected-" + tcpFlagString(reply.tcp)
+	}
+	return result
+}
+
+// scan 使用工作池探测全部端口，结果顺序与端口顺序一致
+// 上下文取消时停止探测，返回已完成的端口结果和上下文错误
+func (e *rawEngine) scan(ctx context.Context, ports []int, timeout time.Duration, workers int) ([]ScanResult, error) {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	results := make([]ScanResult, len(ports))
+	done := make([]bool, len(ports))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				result, err := retryPort(ctx, e.retries, func() (ScanResult, error) {
+					return e.probe(ctx, ports[idx], timeout)
+				})
+				if err != nil {
+					// 上下文取消中断的探测无法判定端口状态，不计入结果
+					if ctx.Err() == nil {
+						errOnce.Do(func() { firstErr = err })
+					}
+					continue
+				}
+				if e.observe != nil {
+					e.observe(result)
+				}
+				results[idx], done[idx] = result, true
+			}
+		}()
+	}
+dispatch:
+	for i := range ports {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	e.mu.Lock()
+	readErr := e.readErr
+	e.mu.Unlock()
+	if readErr != nil {
+		return nil, readErr
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		completed := make([]ScanResult, 0, len(results))
+		for i, result := range results {
+			if done[i] {
+				completed = append(completed, result)
+			}
+		}
+		return completed, err
+	}
+	return results, nil
+}
+
+// rawScan 创建引擎扫描一组端口并在结束后释放
+func rawScan(ctx context.Context, scanType ScanType, transport RawTransport, tap *PacketRecorder,
+	target string, ports []int, timeout time.Duration, workers int) ([]ScanResult, error) {
+	engine, err := newRawEngine(target, scanType, transport, tap)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.close()
+	return engine.scan(ctx, ports, timeout, workers)
+}
+
+// rawEngineScanFunc 将扫描选项中的传输、报文记录器和重试次数绑定到扫描函数
+func rawEngineScanFunc(scanType ScanType, opts *ScanOptions) hostScanFunc {
+	return func(ctx context.Context, ports []int, observe func(ScanResult)) ([]ScanResult, error) {
+		engine, err := newRawEngine(opts.Target, scanType, opts.RawTransport, opts.Capture)
+		if err != nil {
+			return nil, err
+		}
+		defer engine.close()
+		engine.retries, engine.observe = opts.MaxRetries, observe
+		return engine.scan(ctx, ports, opts.Timeout, opts.Workers)
+	}
+}
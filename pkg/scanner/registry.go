@@ -0,0 +1,310 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ScannerConstructor 扫描器构造函数
+type ScannerConstructor func() BaseScanner
+
+// ScannerCapabilities 扫描器能力描述
+type ScannerCapabilities struct {
+	RequiresRoot bool   `json:"requires_root"` // 是否需要root权限
+	Protocol     string `json:"protocol"`      // 传输层协议(tcp/udp)
+	SupportsIPv6 bool   `json:"supports_ipv6"` // 是否支持IPv6目标
+	Description  string `json:"description"`   // 扫描类型说明
+}
+
+// ScannerRegistration 扫描器注册信息
+type ScannerRegistration struct {
+	Type         ScanType            `json:"type"`         // 扫描类型
+	Capabilities ScannerCapabilities `json:"capabilities"` // 能力描述
+	constructor  ScannerConstructor  // 构造函数，为nil表示仅声明尚未实现
+}
+
+// Implemented 扫描类型是否已有可用的扫描器实现
+func (r ScannerRegistration) Implemented() bool {
+	return r.constructor != nil
+}
+
+// scannerRegistry 扫描器注册表
+type scannerRegistry struct {
+	mu      sync.RWMutex
+	entries map[ScanType]*ScannerRegistration
+	order   []ScanType // 注册顺序，用于生成稳定的帮助信息
+}
+
+var defaultRegistry = &scannerRegistry{
+	entries: make(map[ScanType]*ScannerRegistration),
+}
+
+// RegisterScanner 注册扫描器，使新的扫描类型无需修改工厂即可使用
+func RegisterScanner(scanType ScanType, constructor ScannerConstructor, caps ScannerCapabilities) error {
+	if constructor == nil {
+		return fmt.Errorf("扫描类型 %s 的构造函数不能为空", scanType)
+	}
+	return defaultRegistry.register(scanType, constructor, caps)
+}
+
+// declareScanType 声明尚未实现的扫描类型，仅登记能力信息
+func declareScanType(scanType ScanType, caps ScannerCapabilities) {
+	if err := defaultRegistry.register(scanType, nil, caps); err != nil {
+		panic(err)
+	}
+}
+
+// register 向注册表中添加扫描类型
+func (r *scannerRegistry) register(scanType ScanType, constructor ScannerConstructor, caps ScannerCapabilities) error {
+	name := strings.TrimSpace(string(scanType))
+	if name == "" {
+		return fmt.Errorf("扫描类型不能为空")
+	}
+	if name != string(scanType) || strings.ContainsAny(name, " ,") {
+		return fmt.Errorf("无效的扫描类型名称: %q", scanType)
+	}
+	caps.Protocol = strings.ToLower(caps.Protocol)
+	if caps.Protocol == "" {
+		caps.Protocol = "tcp"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.entries[scanType]; ok {
+		// 允许为仅声明的扫描类型补充实现
+		if existing.constructor != nil || constructor == nil {
+			return fmt.Errorf("扫描类型 %s 已注册", scanType)
+		}
+		existing.constructor = constructor
+		existing.Capabilities = caps
+		return nil
+	}
+
+	r.entries[scanType] = &ScannerRegistration{
+		Type:         scanType,
+		Capabilities: caps,
+		constructor:  constructor,
+	}
+	r.order = append(r.order, scanType)
+	return nil
+}
+
+// lookup 查找扫描类型的注册信息
+func (r *scannerRegistry) lookup(scanType ScanType) (ScannerRegistration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.entries[scanType]
+	if !ok {
+		return ScannerRegistration{}, false
+	}
+	return *entry, true
+}
+
+// list 按注册顺序列出所有注册信息
+func (r *scannerRegistry) list() []ScannerRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	regs := make([]ScannerRegistration, 0, len(r.order))
+	for _, t := range r.order {
+		regs = append(regs, *r.entries[t])
+	}
+	return regs
+}
+
+// LookupScanner 查找扫描类型的注册信息
+func LookupScanner(scanType ScanType) (ScannerRegistration, bool) {
+	return defaultRegistry.lookup(scanType)
+}
+
+// RegisteredScanners 按注册顺序返回所有扫描类型的注册信息
+func RegisteredScanners() []ScannerRegistration {
+	return defaultRegistry.list()
+}
+
+// ImplementedScanners 按注册顺序返回已有实现、可以执行的扫描类型注册信息
+func ImplementedScanners() []ScannerRegistration {
+	regs := make([]ScannerRegistration, 0)
+	for _, reg := range RegisteredScanners() {
+		if reg.Implemented() {
+			regs = append(regs, reg)
+		}
+	}
+	return regs
+}
+
+// RegisteredScanTypes 返回所有已注册的扫描类型
+func RegisteredScanTypes() []ScanType {
+	regs := RegisteredScanners()
+	types := make([]ScanType, 0, len(regs))
+	for _, reg := range regs {
+		types = append(types, reg.Type)
+	}
+	return types
+}
+
+// ValidateScanType 检查扫描类型是否已在注册表中登记并有可用的实现
+func ValidateScanType(scanType ScanType) error {
+	if reg, ok := LookupScanner(scanType); ok {
+		if !reg.Implemented() {
+			return fmt.Errorf("%s扫描暂未实现", strings.ToUpper(string(scanType)))
+		}
+		return nil
+	}
+
+	names := make([]string, 0)
+	for _, reg := range ImplementedScanners() {
+		names = append(names, string(reg.Type))
+	}
+	return fmt.Errorf("不支持的扫描类型: %s (可用类型: %s)", scanType, strings.Join(names, ", "))
+}
+
+// ScanTypeUsage 根据注册表生成扫描类型的帮助说明，仅列出已实现的类型
+func ScanTypeUsage() string {
+	parts := make([]string, 0)
+	for _, reg := range ImplementedScanners() {
+		item := string(reg.Type)
+		if reg.Capabilities.RequiresRoot {
+			item += "(需root)"
+		}
+		parts = append(parts, item)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// newRegisteredScanner 通过注册表创建扫描器
+func newRegisteredScanner(scanType ScanType) (BaseScanner, error) {
+	if err := ValidateScanType(scanType); err != nil {
+		return nil, err
+	}
+	reg, _ := LookupScanner(scanType)
+	return reg.constructor(), nil
+}
+
+// executeRegisteredScan 使用注册表中的扫描器执行扫描
+func executeRegisteredScan(opts *ScanOptions) ([]ScanResult, error) {
+	s, err := newRegisteredScanner(opts.ScanType)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ValidateOptions(opts); err != nil {
+		return nil, err
+	}
+	return s.Scan(context.Background(), opts)
+}
+
+// builtinScanner 内置扫描类型的扫描器，ExecuteScan等入口与第三方扫描器一样通过注册表调用
+type builtinScanner struct {
+	scanType     ScanType
+	requiresRoot bool
+	scan         func(ctx context.Context, opts *ScanOptions, ports []int) ([]ScanResult, error)
+}
+
+// newBuiltinConstructor 创建内置扫描类型的构造函数
+func newBuiltinConstructor(scanType ScanType, requiresRoot bool,
+	scan func(ctx context.Context, opts *ScanOptions, ports []int) ([]ScanResult, error)) ScannerConstructor {
+	return func() BaseScanner {
+		return &builtinScanner{scanType: scanType, requiresRoot: requiresRoot, scan: scan}
+	}
+}
+
+// Scan 执行扫描
+func (s *builtinScanner) Scan(ctx context.Context, opts *ScanOptions) ([]ScanResult, error) {
+	ports, err := parsePorts(opts.Ports)
+	if err != nil {
+		return nil, err
+	}
+	return s.scan(ctx, opts, ports)
+}
+
+// ValidateOptions 验证扫描选项，需要root权限的扫描在未注入报文传输时检查权限
+func (s *builtinScanner) ValidateOptions(opts *ScanOptions) error {
+	if opts == nil {
+		return ErrInvalidOptions
+	}
+	if s.requiresRoot && opts.RawTransport == nil && os.Geteuid() != 0 {
+		return fmt.Errorf("%s扫描需要root权限", s.scanType)
+	}
+	return nil
+}
+
+// RequiresRoot 是否需要root权限
+func (s *builtinScanner) RequiresRoot() bool {
+	return s.requiresRoot
+}
+
+// connectScan 使用Scanner执行TCP全连接扫描
+func connectScan(ctx context.Context, opts *ScanOptions, ports []int) ([]ScanResult, error) {
+	s, err := NewScanner(opts)
+	if err != nil {
+		return nil, err
+	}
+	scanResults, err := s.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ScanResult, len(scanResults))
+	for i, result := range scanResults {
+		results[i] = *result
+	}
+	return results, nil
+}
+
+// udpScanPorts 在主机放弃策略下执行UDP扫描
+func udpScanPorts(ctx context.Context, opts *ScanOptions, ports []int) ([]ScanResult, error) {
+	return runScanFunc(ctx, opts, ports, udpScanFunc(opts))
+}
+
+// rawScanPorts 返回在主机放弃策略下使用原始报文引擎扫描的函数
+func rawScanPorts(scanType ScanType) func(ctx context.Context, opts *ScanOptions, ports []int) ([]ScanResult, error) {
+	return func(ctx context.Context, opts *ScanOptions, ports []int) ([]ScanResult, error) {
+		return runScanFunc(ctx, opts, ports, rawEngineScanFunc(scanType, opts))
+	}
+}
+
+// 注册内置扫描类型
+func init() {
+	builtins := []struct {
+		scanType    ScanType
+		constructor ScannerConstructor
+		caps        ScannerCapabilities
+	}{
+		{ScanTypeTCP, newBuiltinConstructor(ScanTypeTCP, false, connectScan), ScannerCapabilities{
+			Protocol: "tcp", SupportsIPv6: true, Description: "TCP全连接扫描",
+		}},
+		{ScanTypeSYN, newBuiltinConstructor(ScanTypeSYN, true, rawScanPorts(ScanTypeSYN)), ScannerCapabilities{
+			RequiresRoot: true, Protocol: "tcp", Description: "TCP SYN半开放扫描",
+		}},
+		{ScanTypeFIN, newBuiltinConstructor(ScanTypeFIN, true, rawScanPorts(ScanTypeFIN)), ScannerCapabilities{
+			RequiresRoot: true, Protocol: "tcp", Description: "TCP FIN扫描",
+		}},
+		{ScanTypeNULL, newBuiltinConstructor(ScanTypeNULL, true, rawScanPorts(ScanTypeNULL)), ScannerCapabilities{
+			RequiresRoot: true, Protocol: "tcp", Description: "TCP NULL扫描",
+		}},
+		{ScanTypeXMAS, newBuiltinConstructor(ScanTypeXMAS, true, rawScanPorts(ScanTypeXMAS)), ScannerCapabilities{
+			RequiresRoot: true, Protocol: "tcp", Description: "TCP XMAS扫描",
+		}},
+		{ScanTypeACK, newBuiltinConstructor(ScanTypeACK, true, rawScanPorts(ScanTypeACK)), ScannerCapabilities{
+			RequiresRoot: true, Protocol: "tcp", Description: "TCP ACK扫描",
+		}},
+		{ScanTypeUDP, newBuiltinConstructor(ScanTypeUDP, false, udpScanPorts), ScannerCapabilities{
+			Protocol: "udp", Description: "UDP扫描",
+		}},
+		{ScanTypeMAIMON, nil, ScannerCapabilities{RequiresRoot: true, Protocol: "tcp", Description: "TCP Maimon扫描"}},
+	}
+
+	for _, b := range builtins {
+		if b.constructor == nil {
+			declareScanType(b.scanType, b.caps)
+			continue
+		}
+		if err := RegisterScanner(b.scanType, b.constructor, b.caps); err != nil {
+			panic(err)
+		}
+	}
+}
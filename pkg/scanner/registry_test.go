@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScanner 用于测试注册表的扫描器
+type fakeScanner struct{}
+
+func (f *fakeScanner) Scan(ctx context.Context, opts *ScanOptions) ([]ScanResult, error) {
+	return []ScanResult{{Port: 80, State: PortStateOpen, Type: opts.ScanType}}, nil
+}
+
+func (f *fakeScanner) ValidateOptions(opts *ScanOptions) error { return nil }
+
+func (f *fakeScanner) RequiresRoot() bool { return false }
+
+func TestRegistryBuiltins(t *testing.T) {
+	factory := NewScannerFactory()
+
+	assert.Equal(t, []ScanType{
+		ScanTypeTCP, ScanTypeSYN, ScanTypeFIN, ScanTypeNULL,
+		ScanTypeXMAS, ScanTypeACK, ScanTypeUDP, ScanTypeMAIMON,
+	}, factory.GetSupportedScanTypes()[:8])
+	// ExecuteScan只通过注册表分派，内置类型都应注册了实现
+	assert.Equal(t, []ScanType{
+		ScanTypeTCP, ScanTypeSYN, ScanTypeFIN, ScanTypeNULL,
+		ScanTypeXMAS, ScanTypeACK, ScanTypeUDP,
+	}, factory.GetImplementedScanTypes()[:7])
+	assert.False(t, factory.IsScanTypeImplemented(ScanTypeMAIMON))
+
+	reg, ok := LookupScanner(ScanTypeSYN)
+	require.True(t, ok)
+	assert.True(t, reg.Capabilities.RequiresRoot)
+	assert.Equal(t, "tcp", reg.Capabilities.Protocol)
+
+	for _, scanType := range []ScanType{ScanTypeTCP, ScanTypeFIN, ScanTypeUDP} {
+		s, err := factory.CreateScanner(scanType)
+		require.NoError(t, err)
+		reg, _ := LookupScanner(scanType)
+		assert.Equal(t, reg.Capabilities.RequiresRoot, s.RequiresRoot(), scanType)
+	}
+
+	_, err := factory.CreateScanner(ScanTypeMAIMON)
+	assert.EqualError(t, err, "MAIMON扫描暂未实现")
+	_, err = ExecuteScan(&ScanOptions{Target: "127.0.0.1", Ports: "80", ScanType: ScanTypeMAIMON, Quiet: true})
+	assert.Contains(t, err.Error(), "MAIMON扫描暂未实现")
+	_, err = runQuietScan(&ScanOptions{Target: "127.0.0.1", Ports: "80", ScanType: ScanTypeMAIMON})
+	assert.EqualError(t, err, "MAIMON扫描暂未实现")
+	_, err = factory.CreateScanner(ScanType("nope"))
+	assert.Error(t, err)
+}
+
+func TestRegisterScanner(t *testing.T) {
+	// 注册表是全局的，使用唯一名称保证测试可重复执行
+	custom := ScanType(fmt.Sprintf("test-probe-%d", time.Now().UnixNano()))
+	err := RegisterScanner(custom, func() BaseScanner { return &fakeScanner{} }, ScannerCapabilities{
+		Protocol:    "UDP",
+		Description: "测试探测",
+	})
+	require.NoError(t, err)
+
+	// 重复注册应当失败
+	assert.Error(t, RegisterScanner(custom, func() BaseScanner { return &fakeScanner{} }, ScannerCapabilities{}))
+	// 内置类型不可覆盖
+	assert.Error(t, RegisterScanner(ScanTypeTCP, func() BaseScanner { return &fakeScanner{} }, ScannerCapabilities{}))
+	assert.Error(t, RegisterScanner(custom, nil, ScannerCapabilities{}))
+	assert.Error(t, RegisterScanner(ScanType(""), func() BaseScanner { return &fakeScanner{} }, ScannerCapabilities{}))
+
+	reg, ok := LookupScanner(custom)
+	require.True(t, ok)
+	assert.True(t, reg.Implemented())
+	assert.Equal(t, "udp", reg.Capabilities.Protocol)
+
+	factory := NewScannerFactory()
+	assert.True(t, factory.IsScanTypeSupported(custom))
+	assert.True(t, factory.IsScanTypeImplemented(custom))
+	s, err := factory.CreateScanner(custom)
+	require.NoError(t, err)
+	assert.IsType(t, &fakeScanner{}, s)
+
+	assert.NoError(t, ValidateScanType(custom))
+	assert.Contains(t, ScanTypeUsage(), "test-probe")
+	assert.Contains(t, ScanTypeUsage(), "syn(需root)")
+
+	results, err := executeRegisteredScan(&ScanOptions{ScanType: custom})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestValidateScanType(t *testing.T) {
+	assert.NoError(t, ValidateScanType(ScanTypeTCP))
+	err := ValidateScanType(ScanType("bogus"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tcp")
+	// 可用类型中不列出尚未实现的类型
+	assert.NotContains(t, err.Error(), "maimon")
+
+	// 仅声明尚未实现的类型不能通过校验，也不出现在帮助说明中
+	assert.EqualError(t, ValidateScanType(ScanTypeMAIMON), "MAIMON扫描暂未实现")
+	assert.NotContains(t, ScanTypeUsage(), "maimon")
+	for _, reg := range ImplementedScanners() {
+		assert.True(t, reg.Implemented(), reg.Type)
+	}
+}
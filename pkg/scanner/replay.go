@@ -0,0 +1,569 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// replaySource 离线重放结果的来源标记
+const replaySource = "pcap-replay"
+
+// replayResponseLimit 单个会话保留的应答数据上限
+const replayResponseLimit = 4096
+
+// pcapng文件以节头块(SHB)开头
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// packetSource 抓包文件读取器，pcap与pcapng读取器均满足该接口
+type packetSource interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// replayFlowKey 会话标识，from为探测发起方(扫描器)，to为应答方(目标端口)
+type replayFlowKey struct {
+	proto string
+	from  string
+	to    string
+}
+
+// replayFlow 抓包中的一次探测会话
+type replayFlow struct {
+	proto    string    // 协议 tcp/udp
+	target   string    // 目标地址
+	port     int       // 目标端口
+	probe    string    // 首个探测报文的TCP标志，UDP为空
+	synAck   bool      // 收到SYN/ACK
+	rst      bool      // 收到RST
+	icmp     int       // ICMP不可达代码，-1表示未收到
+	request  []byte    // 扫描器发送的数据
+	response []byte    // 目标返回的数据
+	ttl      int       // 应答报文的TTL
+	window   int       // SYN/ACK的窗口大小
+	options  string    // SYN/ACK的TCP选项
+	first    time.Time // 首个报文时间
+}
+
+// PacketReplay 从抓包文件重建的扫描数据，作为原始报文引擎的离线后端
+// 不访问网络，可用于回归测试和分析隔离网络中采集的抓包
+type PacketReplay struct {
+	flows   map[replayFlowKey]*replayFlow
+	order   []*replayFlow
+	Packets int // 读取的报文数
+	Skipped int // 非TCP/UDP/ICMP等被忽略的报文数
+}
+
+// ReplayHost 单主机的离线分析结果
+type ReplayHost struct {
+	Target   string              `json:"target"`             // 目标地址
+	Results  []ScanResult        `json:"results"`            // 端口结果
+	OS       *fingerprint.OSInfo `json:"os,omitempty"`       // 操作系统信息
+	Honeypot *HoneypotScore      `json:"honeypot,omitempty"` // 蜜罐/tarpit可能性评分
+}
+
+// LoadPacketReplay 读取pcap或pcapng文件
+func LoadPacketReplay(path string) (*PacketReplay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开抓包文件失败: %v", err)
+	}
+	defer file.Close()
+
+	return NewPacketReplay(file)
+}
+
+// NewPacketReplay 从pcap或pcapng数据流重建扫描会话
+func NewPacketReplay(r io.Reader) (*PacketReplay, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("读取抓包文件头失败: %v", err)
+	}
+
+	var source packetSource
+	if string(magic) == string(pcapngMagic) {
+		source, err = pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+	} else {
+		source, err = pcapgo.NewReader(br)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析抓包文件失败: %v", err)
+	}
+
+	p := &PacketReplay{flows: make(map[replayFlowKey]*replayFlow)}
+	for {
+		data, ci, err := source.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取报文失败: %v", err)
+		}
+		p.Packets++
+		p.addPacket(gopacket.NewPacket(data, source.LinkType(), gopacket.NoCopy), ci.Timestamp)
+	}
+	return p, nil
+}
+
+// addPacket 将报文归入对应的探测会话
+func (p *PacketReplay) addPacket(packet gopacket.Packet, ts time.Time) {
+	var src, dst string
+	var ttl int
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, dst, ttl = ip.SrcIP.String(), ip.DstIP.String(), int(ip.TTL)
+	case *layers.IPv6:
+		src, dst, ttl = ip.SrcIP.String(), ip.DstIP.String(), int(ip.HopLimit)
+	default:
+		p.Skipped++
+		return
+	}
+
+	if tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		p.addTCP(src, dst, ttl, tcp, ts)
+		return
+	}
+	if udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		p.addUDP(src, dst, ttl, udp, ts)
+		return
+	}
+	if icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+		p.addICMP(icmp)
+		return
+	}
+	p.Skipped++
+}
+
+// addTCP 处理TCP报文：首个报文的发送方视为扫描器
+func (p *PacketReplay) addTCP(src, dst string, ttl int, tcp *layers.TCP, ts time.Time) {
+	from := net.JoinHostPort(src, strconv.Itoa(int(tcp.SrcPort)))
+	to := net.JoinHostPort(dst, strconv.Itoa(int(tcp.DstPort)))
+
+	// 抓包从会话中途开始时，SYN/ACK与RST只可能来自目标
+	forward := p.flows[replayFlowKey{"tcp", from, to}]
+	reverse := p.flows[replayFlowKey{"tcp", to, from}]
+	isReply := reverse != nil || (forward == nil && (tcp.RST || (tcp.SYN && tcp.ACK)))
+
+	if !isReply {
+		flow := forward
+		if flow == nil {
+			flow = p.newFlow(replayFlowKey{"tcp", from, to}, dst, int(tcp.DstPort), ts)
+			flow.probe = tcpFlagString(tcp)
+		}
+		flow.request = appendLimited(flow.request, tcp.Payload)
+		return
+	}
+
+	flow := reverse
+	if flow == nil {
+		flow = p.newFlow(replayFlowKey{"tcp", to, from}, src, int(tcp.SrcPort), ts)
+	}
+	if flow.ttl == 0 {
+		flow.ttl = ttl
+	}
+	if tcp.SYN && tcp.ACK {
+		flow.synAck = true
+		flow.window = int(tcp.Window)
+		flow.options = tcpOptionString(tcp.Options)
+	}
+	if tcp.RST {
+		flow.rst = true
+	}
+	flow.response = appendLimited(flow.response, tcp.Payload)
+}
+
+// addUDP 处理UDP报文
+func (p *PacketReplay) addUDP(src, dst string, ttl int, udp *layers.UDP, ts time.Time) {
+	from := net.JoinHostPort(src, strconv.Itoa(int(udp.SrcPort)))
+	to := net.JoinHostPort(dst, strconv.Itoa(int(udp.DstPort)))
+
+	if flow := p.flows[replayFlowKey{"udp", to, from}]; flow != nil {
+		if flow.ttl == 0 {
+			flow.ttl = ttl
+		}
+		flow.response = appendLimited(flow.response, udp.Payload)
+		return
+	}
+
+	flow := p.flows[replayFlowKey{"udp", from, to}]
+	if flow == nil {
+		flow = p.newFlow(replayFlowKey{"udp", from, to}, dst, int(udp.DstPort), ts)
+	}
+	flow.request = appendLimited(flow.request, udp.Payload)
+}
+
+// addICMP 处理ICMP目标不可达报文，根据内层报文找到对应的探测
+func (p *PacketReplay) addICMP(icmp *layers.ICMPv4) {
+	if icmp.TypeCode.Type() != layers.ICMPv4TypeDestinationUnreachable {
+		p.Skipped++
+		return
+	}
+
+	inner := icmp.Payload
+	if len(inner) < 20 {
+		return
+	}
+	ihl := int(inner[0]&0x0f) * 4
+	if len(inner) < ihl+4 {
+		return
+	}
+
+	proto := ""
+	switch layers.IPProtocol(inner[9]) {
+	case layers.IPProtocolTCP:
+		proto = "tcp"
+	case layers.IPProtocolUDP:
+		proto = "udp"
+	default:
+		return
+	}
+	sport := binary.BigEndian.Uint16(inner[ihl:])
+	dport := binary.BigEndian.Uint16(inner[ihl+2:])
+	from := net.JoinHostPort(net.IP(inner[12:16]).String(), strconv.Itoa(int(sport)))
+	to := net.JoinHostPort(net.IP(inner[16:20]).String(), strconv.Itoa(int(dport)))
+
+	if flow := p.flows[replayFlowKey{proto, from, to}]; flow != nil {
+		flow.icmp = int(icmp.TypeCode.Code())
+	}
+}
+
+// newFlow 创建探测会话
+func (p *PacketReplay) newFlow(key replayFlowKey, target string, port int, ts time.Time) *replayFlow {
+	flow := &replayFlow{proto: key.proto, target: target, port: port, icmp: -1, first: ts}
+	p.flows[key] = flow
+	p.order = append(p.order, flow)
+	return flow
+}
+
+// state 按扫描类型的语义推断端口状态，与实时扫描引擎的判定保持一致
+func (f *replayFlow) state() PortState {
+	if f.proto == "udp" {
+		switch {
+		case len(f.response) > 0:
+			return PortStateOpen
+		case f.icmp == int(layers.ICMPv4CodePort):
+			return PortStateClosed
+		default:
+			return PortStateFiltered
+		}
+	}
+
+	switch {
+	case f.synAck || len(f.response) > 0:
+		return PortStateOpen
+	case f.rst:
+		return PortStateClosed
+	case f.icmp >= 0:
+		return PortStateFiltered
+	}
+
+	// 没有任何应答：FIN/NULL/XMAS扫描视为开放，其余视为被过滤
+	switch f.scanType() {
+	case ScanTypeFIN, ScanTypeNULL, ScanTypeXMAS:
+		return PortStateOpen
+	default:
+		return PortStateFiltered
+	}
+}
+
+// scanType 根据首个探测报文推断扫描类型
+func (f *replayFlow) scanType() ScanType {
+	if f.proto == "udp" {
+		return ScanTypeUDP
+	}
+	switch f.probe {
+	case "S":
+		return ScanTypeSYN
+	case "F":
+		return ScanTypeFIN
+	case "NONE":
+		return ScanTypeNULL
+	case "FPU":
+		return ScanTypeXMAS
+	case "A":
+		return ScanTypeACK
+	default:
+		return ScanTypeTCP
+	}
+}
+
+// result 将会话转换为扫描结果
+func (f *replayFlow) result() ScanResult {
+	state := f.state()
+	result := ScanResult{
+		Port:  f.port,
+		State: state,
+		Open:  state == PortStateOpen,
+		Type:  f.scanType(),
+		TTL:   f.ttl,
+		Metadata: map[string]interface{}{
+			"source": replaySource,
+		},
+	}
+	if f.probe != "" {
+		result.Metadata["probe"] = f.probe
+	}
+	if f.window > 0 {
+		result.Metadata["window"] = f.window
+	}
+	if f.options != "" {
+		result.Metadata["tcp_options"] = f.options
+	}
+	if len(f.response) > 0 {
+		result.Banner = string(f.response)
+	}
+	return result
+}
+
+// stateRank 合并同一端口的多个会话时的状态优先级
+func stateRank(state PortState) int {
+	switch state {
+	case PortStateOpen:
+		return 3
+	case PortStateClosed:
+		return 2
+	case PortStateFiltered:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// hostFlows 返回目标每个端口最有信息量的会话，重试产生的多个会话只保留一个
+func (p *PacketReplay) hostFlows(target string) map[int]*replayFlow {
+	best := make(map[int]*replayFlow)
+	for _, flow := range p.order {
+		if flow.target != target {
+			continue
+		}
+		cur, ok := best[flow.port]
+		if !ok || stateRank(flow.state()) > stateRank(cur.state()) ||
+			(flow.state() == cur.state() && len(flow.response) > len(cur.response)) {
+			best[flow.port] = flow
+		}
+	}
+	return best
+}
+
+// Targets 返回抓包中被探测的目标，按首次出现的顺序排列
+func (p *PacketReplay) Targets() []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, flow := range p.order {
+		if !seen[flow.target] {
+			seen[flow.target] = true
+			targets = append(targets, flow.target)
+		}
+	}
+	return targets
+}
+
+// Results 返回目标在抓包中出现过的全部端口结果
+func (p *PacketReplay) Results(target string) []ScanResult {
+	flows := p.hostFlows(target)
+	results := make([]ScanResult, 0, len(flows))
+	for _, flow := range flows {
+		results = append(results, flow.result())
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Port < results[j].Port })
+	return results
+}
+
+// ScanFunc 返回基于抓包应答的扫描函数，可替代原始套接字扫描
+// 抓包中没有出现的端口状态为unknown
+func (p *PacketReplay) ScanFunc() ScanFunc {
+	return func(target string, ports []int, timeout time.Duration, workers int) ([]ScanResult, error) {
+		flows := p.hostFlows(target)
+		if len(flows) == 0 {
+			return nil, fmt.Errorf("抓包中没有目标 %s 的报文", target)
+		}
+
+		results := make([]ScanResult, 0, len(ports))
+		for _, port := range ports {
+			if flow, ok := flows[port]; ok {
+				results = append(results, flow.result())
+				continue
+			}
+			results = append(results, ScanResult{
+				Port:     port,
+				State:    PortStateUnknown,
+				Metadata: map[string]interface{}{"source": replaySource, "error": "抓包中没有该端口的探测"},
+			})
+		}
+		return results, nil
+	}
+}
+
+// Analyze 重建抓包中全部主机的扫描结果，并按需执行服务与操作系统识别
+func (p *PacketReplay) Analyze(enableService, enableOS bool) ([]ReplayHost, error) {
+	var hosts []ReplayHost
+	for _, target := range p.Targets() {
+		results := p.Results(target)
+		osInfo, err := p.analyzeHost(target, results, enableService, enableOS)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, ReplayHost{Target: target, Results: results, OS: osInfo, Honeypot: ScoreHoneypot(results)})
+	}
+	return hosts, nil
+}
+
+// analyzeHost 用抓包中的banner与应答报文执行指纹识别，结果写回results
+func (p *PacketReplay) analyzeHost(target string, results []ScanResult, enableService, enableOS bool) (*fingerprint.OSInfo, error) {
+	if !enableService && !enableOS {
+		return nil, nil
+	}
+
+	fp, err := GetFingerprinter("")
+	if err != nil {
+		return nil, fmt.Errorf("创建指纹识别器失败: %v", err)
+	}
+	fpOpts := fingerprint.DefaultFingerprintOptions()
+	fpOpts.EnableServiceDetection = enableService
+	fpOpts.EnableOSDetection = enableOS
+	fp.SetOptions(fpOpts)
+
+	flows := p.hostFlows(target)
+	var osProbes []fingerprint.ProbeResult
+	var osFlow *replayFlow
+	for i := range results {
+		flow, ok := flows[results[i].Port]
+		if !ok || results[i].State != PortStateOpen {
+			continue
+		}
+		if osFlow == nil || (flow.synAck && !osFlow.synAck) {
+			osFlow = flow
+		}
+		if len(flow.response) == 0 || flow.proto != "tcp" {
+			continue
+		}
+
+		if enableService {
+			results[i].Service = identifyReplayService(fp, flow)
+			results[i].ServiceName = results[i].Service.Name
+			results[i].Version = results[i].Service.Version
+		}
+		osProbes = append(osProbes, fingerprint.NewOSProbeResults(target, flow.port, flow.response)...)
+	}
+
+	if !enableOS || osFlow == nil {
+		return nil, nil
+	}
+	osInfo := identifyReplayOS(fp, target, osFlow, osProbes)
+	for i := range results {
+		if results[i].State == PortStateOpen {
+			results[i].OS = osInfo
+		}
+	}
+	return osInfo, nil
+}
+
+// identifyReplayService 用指纹库匹配banner，未命中时退回banner版本解析
+func identifyReplayService(fp *fingerprint.Fingerprinter, flow *replayFlow) *fingerprint.Service {
+	probe := fingerprint.NewServiceProbeResult(flow.target, flow.port, flow.request, flow.response)
+	if serviceFp, err := fp.MatchServiceProbes(flow.target, flow.port, []fingerprint.ProbeResult{probe}); err == nil && serviceFp.Name != "" {
+		service := serviceFromFingerprint(serviceFp)
+		service.Banner = strings.TrimSpace(string(flow.response))
+		service.Metadata["source"] = replaySource
+		return service
+	}
+
+	info := &ServiceInfo{Name: CommonServices[flow.port], Port: flow.port, FullBanner: strings.TrimSpace(string(flow.response))}
+	if info.Name == "" {
+		info.Name = "unknown"
+	}
+	parseVersionFromBanner(info)
+	return &fingerprint.Service{
+		Name:     strings.ToLower(info.Name),
+		Product:  info.Product,
+		Version:  info.Version,
+		Protocol: "tcp",
+		Banner:   info.FullBanner,
+		Metadata: map[string]string{"source": replaySource + "-banner"},
+	}
+}
+
+// identifyReplayOS 用指纹库匹配操作系统，未命中时根据应答报文的TTL推测
+func identifyReplayOS(fp *fingerprint.Fingerprinter, target string, flow *replayFlow, probes []fingerprint.ProbeResult) *fingerprint.OSInfo {
+	osFp := &fingerprint.OSFingerprint{}
+	if len(probes) > 0 {
+		if matched, err := fp.MatchOSProbes(target, probes); err == nil {
+			osFp = matched
+		}
+	}
+	if osFp.Name == "" && flow.ttl > 0 {
+		osFp.Name = guessOSFromTTL(flow.ttl)
+		osFp.Confidence = 60.0 // TTL猜测的置信度较低
+	}
+
+	osInfo := osInfoFromFingerprint(osFp)
+	osInfo.Family = parseOSFamily(osFp.Name)
+	osInfo.Metadata["source"] = replaySource
+	if flow.ttl > 0 {
+		osInfo.Metadata["ttl"] = strconv.Itoa(flow.ttl)
+	}
+	if flow.window > 0 {
+		osInfo.Metadata["window"] = strconv.Itoa(flow.window)
+	}
+	if flow.options != "" {
+		osInfo.Metadata["tcp_options"] = flow.options
+	}
+	return osInfo
+}
+
+// executeReplayScan 以抓包文件代替网络执行扫描
+func executeReplayScan(opts *ScanOptions, ports []int) ([]ScanResult, error) {
+	results, err := runScanFunc(context.Background(), opts, ports, batchScanFunc(opts, opts.Replay.ScanFunc()))
+	if err != nil {
+		return nil, err
+	}
+
+	enableService := opts.EnableService || opts.ServiceProbe || opts.BannerProbe
+	if _, err := opts.Replay.analyzeHost(opts.Target, results, enableService, opts.EnableOS); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// appendLimited 追加数据，总长度不超过replayResponseLimit
+func appendLimited(buf, data []byte) []byte {
+	if room := replayResponseLimit - len(buf); room < len(data) {
+		data = data[:max(room, 0)]
+	}
+	return append(buf, data...)
+}
+
+// tcpOptionString 生成TCP选项的简要描述
+func tcpOptionString(options []layers.TCPOption) string {
+	names := make([]string, 0, len(options))
+	for _, opt := range options {
+		switch opt.OptionType {
+		case layers.TCPOptionKindMSS:
+			if len(opt.OptionData) == 2 {
+				names = append(names, fmt.Sprintf("MSS=%d", binary.BigEndian.Uint16(opt.OptionData)))
+				continue
+			}
+		case layers.TCPOptionKindWindowScale:
+			if len(opt.OptionData) == 1 {
+				names = append(names, fmt.Sprintf("WS=%d", opt.OptionData[0]))
+				continue
+			}
+		}
+		names = append(names, opt.OptionType.String())
+	}
+	return strings.Join(names, ",")
+}
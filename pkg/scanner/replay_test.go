@@ -0,0 +1,229 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	replayScanner = net.ParseIP("10.0.0.1")
+	replayTarget  = net.ParseIP("10.0.0.2")
+)
+
+// replayPacket 合成扫描器(发起方)与目标之间的TCP报文
+func replayPacket(t *testing.T, fromScanner bool, port int, tcp *layers.TCP, payload string) []byte {
+	local := &net.TCPAddr{IP: replayScanner, Port: 40000}
+	remote := &net.TCPAddr{IP: replayTarget, Port: port}
+	src, dst := net.Addr(local), net.Addr(remote)
+	if !fromScanner {
+		src, dst = remote, local
+	}
+	data, err := synthesizeTCPPacket(src, dst, tcp, []byte(payload))
+	require.NoError(t, err)
+	return data
+}
+
+// buildReplayCapture 构造包含各类端口应答的pcapng抓包
+func buildReplayCapture(t *testing.T) []byte {
+	var buf bytes.Buffer
+	rec, err := NewPacketRecorder(&buf, nil)
+	require.NoError(t, err)
+
+	// 22端口: SYN -> SYN/ACK，随后返回SSH banner
+	rec.Record(PacketSent, replayPacket(t, true, 22, &layers.TCP{SYN: true}, ""), "")
+	synAck := &layers.TCP{SYN: true, ACK: true, Options: []layers.TCPOption{
+		{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}},
+	}}
+	rec.Record(PacketReceived, replayPacket(t, false, 22, synAck, ""), "")
+	rec.Record(PacketReceived, replayPacket(t, false, 22, &layers.TCP{ACK: true, PSH: true}, "SSH-2.0-OpenSSH_8.9\r\n"), "")
+
+	// 23端口: SYN -> RST
+	rec.Record(PacketSent, replayPacket(t, true, 23, &layers.TCP{SYN: true}, ""), "")
+	rec.Record(PacketReceived, replayPacket(t, false, 23, &layers.TCP{RST: true, ACK: true}, ""), "")
+
+	// 25端口: SYN无应答，重试一次后仍无应答
+	rec.Record(PacketSent, replayPacket(t, true, 25, &layers.TCP{SYN: true}, ""), "")
+
+	// 80端口: FIN无应答
+	rec.Record(PacketSent, replayPacket(t, true, 80, &layers.TCP{FIN: true}, ""), "")
+
+	require.NoError(t, rec.Close())
+	return buf.Bytes()
+}
+
+func TestPacketReplayReconstructsResults(t *testing.T) {
+	replay, err := NewPacketReplay(bytes.NewReader(buildReplayCapture(t)))
+	require.NoError(t, err)
+	assert.Equal(t, 7, replay.Packets)
+	assert.Equal(t, []string{"10.0.0.2"}, replay.Targets())
+
+	results := replay.Results("10.0.0.2")
+	require.Len(t, results, 4)
+
+	byPort := make(map[int]ScanResult)
+	for _, r := range results {
+		byPort[r.Port] = r
+	}
+	assert.Equal(t, PortStateOpen, byPort[22].State)
+	assert.Equal(t, ScanTypeSYN, byPort[22].Type)
+	assert.Equal(t, "SSH-2.0-OpenSSH_8.9\r\n", byPort[22].Banner)
+	assert.Equal(t, 64, byPort[22].TTL)
+	assert.Equal(t, "MSS=1460", byPort[22].Metadata["tcp_options"])
+	assert.Equal(t, PortStateClosed, byPort[23].State)
+	assert.Equal(t, PortStateFiltered, byPort[25].State)
+	assert.Equal(t, PortStateOpen, byPort[80].State)
+	assert.Equal(t, ScanTypeFIN, byPort[80].Type)
+}
+
+func TestPacketReplayAnalyze(t *testing.T) {
+	replay, err := NewPacketReplay(bytes.NewReader(buildReplayCapture(t)))
+	require.NoError(t, err)
+
+	hosts, err := replay.Analyze(true, true)
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+
+	host := hosts[0]
+	require.NotNil(t, host.OS)
+	assert.Equal(t, "Linux/Unix", host.OS.Name)
+	assert.Equal(t, "64", host.OS.Metadata["ttl"])
+
+	for _, r := range host.Results {
+		if r.Port == 22 {
+			require.NotNil(t, r.Service)
+			assert.Equal(t, "ssh", r.Service.Name)
+			assert.Equal(t, "SSH-2.0-OpenSSH_8.9", r.Service.Banner)
+		}
+	}
+}
+
+func TestPacketReplayScanFunc(t *testing.T) {
+	replay, err := NewPacketReplay(bytes.NewReader(buildReplayCapture(t)))
+	require.NoError(t, err)
+
+	results, err := replay.ScanFunc()("10.0.0.2", []int{22, 443}, time.Second, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, PortStateOpen, results[0].State)
+	assert.Equal(t, PortStateUnknown, results[1].State)
+
+	_, err = replay.ScanFunc()("10.0.0.9", []int{22}, time.Second, 1)
+	assert.Error(t, err)
+}
+
+func TestExecuteScanWithReplay(t *testing.T) {
+	replay, err := NewPacketReplay(bytes.NewReader(buildReplayCapture(t)))
+	require.NoError(t, err)
+
+	results, err := ExecuteScan(&ScanOptions{
+		Target:   "10.0.0.2",
+		Ports:    "22,23",
+		ScanType: ScanTypeSYN,
+		Timeout:  time.Second,
+		Workers:  1,
+		Replay:   replay,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, PortStateOpen, results[0].State)
+	assert.Equal(t, PortStateClosed, results[1].State)
+}
+
+func TestPacketReplayClassicPcapUDP(t *testing.T) {
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	require.NoError(t, w.WriteFileHeader(65535, layers.LinkTypeEthernet))
+
+	write := func(layersToSend ...gopacket.SerializableLayer) {
+		sb := gopacket.NewSerializeBuffer()
+		err := gopacket.SerializeLayers(sb, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, layersToSend...)
+		require.NoError(t, err)
+		data := sb.Bytes()
+		require.NoError(t, w.WritePacket(gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(data), Length: len(data)}, data))
+	}
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: layers.EthernetTypeIPv4}
+
+	// 53端口: UDP探测收到应答
+	probeIP := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: replayScanner, DstIP: replayTarget}
+	probe := &layers.UDP{SrcPort: 40000, DstPort: 53}
+	probe.SetNetworkLayerForChecksum(probeIP)
+	write(eth, probeIP, probe, gopacket.Payload("q"))
+
+	replyIP := &layers.IPv4{Version: 4, TTL: 128, Protocol: layers.IPProtocolUDP, SrcIP: replayTarget, DstIP: replayScanner}
+	reply := &layers.UDP{SrcPort: 53, DstPort: 40000}
+	reply.SetNetworkLayerForChecksum(replyIP)
+	write(eth, replyIP, reply, gopacket.Payload("answer"))
+
+	// 161端口: UDP探测收到ICMP端口不可达
+	probe161 := &layers.UDP{SrcPort: 40000, DstPort: 161}
+	probe161.SetNetworkLayerForChecksum(probeIP)
+	write(eth, probeIP, probe161)
+
+	inner := gopacket.NewSerializeBuffer()
+	require.NoError(t, gopacket.SerializeLayers(inner, gopacket.SerializeOptions{FixLengths: true}, probeIP, probe161))
+	icmpIP := &layers.IPv4{Version: 4, TTL: 128, Protocol: layers.IPProtocolICMPv4, SrcIP: replayTarget, DstIP: replayScanner}
+	icmp := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort)}
+	write(eth, icmpIP, icmp, gopacket.Payload(inner.Bytes()))
+
+	replay, err := NewPacketReplay(&buf)
+	require.NoError(t, err)
+
+	results := replay.Results("10.0.0.2")
+	require.Len(t, results, 2)
+	assert.Equal(t, 53, results[0].Port)
+	assert.Equal(t, PortStateOpen, results[0].State)
+	assert.Equal(t, ScanTypeUDP, results[0].Type)
+	assert.Equal(t, "answer", results[0].Banner)
+	assert.Equal(t, 161, results[1].Port)
+	assert.Equal(t, PortStateClosed, results[1].State)
+}
+
+func TestPacketReplayRoundTripConnectCapture(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 test ftp\r\n"))
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	var file bytes.Buffer
+	recorder, err := NewPacketRecorder(&file, nil)
+	require.NoError(t, err)
+
+	_, err = NewTCPScanner().Scan(context.Background(), &ScanOptions{
+		Target:       "127.0.0.1",
+		Ports:        strconv.Itoa(port),
+		Timeout:      time.Second,
+		Workers:      1,
+		ServiceProbe: true,
+		Capture:      recorder,
+	})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	replay, err := NewPacketReplay(&file)
+	require.NoError(t, err)
+	results := replay.Results("127.0.0.1")
+	require.Len(t, results, 1)
+	assert.Equal(t, port, results[0].Port)
+	assert.Equal(t, PortStateOpen, results[0].State)
+	assert.Equal(t, "220 test ftp\r\n", results[0].Banner)
+}
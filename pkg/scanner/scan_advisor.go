@@ -10,6 +10,7 @@ import (
 type ScanAdvisor struct {
 	opts      *ScanOptions
 	portCount int
+	profile   *MachineProfile // 基准测试生成的机器画像，为空时使用静态规则
 }
 
 // NewScanAdvisor 创建扫描建议器
@@ -22,9 +23,15 @@ func NewScanAdvisor(opts *ScanOptions) (*ScanAdvisor, error) {
 	return &ScanAdvisor{
 		opts:      opts,
 		portCount: len(ports),
+		profile:   loadLocalMachineProfile(),
 	}, nil
 }
 
+// SetMachineProfile 设置机器画像，nil表示使用静态规则
+func (sa *ScanAdvisor) SetMachineProfile(profile *MachineProfile) {
+	sa.profile = profile
+}
+
 // AnalyzeAndSuggest 分析扫描配置并提供建议
 func (sa *ScanAdvisor) AnalyzeAndSuggest() []string {
 	var suggestions []string
@@ -34,8 +41,8 @@ func (sa *ScanAdvisor) AnalyzeAndSuggest() []string {
 		suggestions = append(suggestions, sa.suggestPortOptimization())
 	}
 
-	// 分析并发设置 - 降低阈值
-	if sa.opts.Workers > 30 {
+	// 分析并发设置 - 有机器画像时与实测最佳并发比较
+	if sa.needsConcurrencyOptimization() {
 		suggestions = append(suggestions, sa.suggestConcurrencyOptimization())
 	}
 
@@ -60,6 +67,11 @@ func (sa *ScanAdvisor) AnalyzeAndSuggest() []string {
 		suggestions = append(suggestions, sa.suggestTimeOptimization(estimatedTime))
 	}
 
+	// 分析单主机超时设置
+	if sa.opts.HostTimeout > 0 && estimatedTime > sa.opts.HostTimeout {
+		suggestions = append(suggestions, sa.suggestHostTimeoutOptimization(estimatedTime))
+	}
+
 	// 添加通用建议
 	if sa.portCount > 1000 && sa.opts.Workers > 20 {
 		suggestions = append(suggestions, "⚠️ 大规模扫描建议: 考虑分批扫描以获得更好的性能和稳定性")
@@ -78,8 +90,34 @@ func (sa *ScanAdvisor) suggestPortOptimization() string {
 		sa.portCount)
 }
 
+// needsConcurrencyOptimization 判断并发设置是否需要调整
+func (sa *ScanAdvisor) needsConcurrencyOptimization() bool {
+	engine, ok := sa.profile.Engine(sa.opts.ScanType)
+	if !ok {
+		return sa.opts.Workers > 30
+	}
+	// 端口数少于实测并发时提高并发没有意义
+	if sa.portCount <= engine.Workers && sa.opts.Workers >= sa.portCount {
+		return false
+	}
+	return sa.opts.Workers > engine.Workers*2 || sa.opts.Workers*2 < engine.Workers
+}
+
 // suggestConcurrencyOptimization 并发优化建议
 func (sa *ScanAdvisor) suggestConcurrencyOptimization() string {
+	if engine, ok := sa.profile.Engine(sa.opts.ScanType); ok {
+		rate := ""
+		if engine.RateLimit > 0 {
+			rate = fmt.Sprintf("\n   • 速率限制 %d 个/秒", engine.RateLimit)
+		}
+		return fmt.Sprintf("⚡ 并发优化建议 (基于本机基准测试):\n"+
+			"   %s 扫描在并发 %d 时吞吐最高 (%.0f 端口/秒，准确率 %.1f%%)，当前并发数为 %d，建议:\n"+
+			"   • 将并发数调整为 %d%s\n"+
+			"   • 硬件或系统限制变化后重新运行 go-port-rocket bench",
+			sa.opts.ScanType, engine.Workers, engine.PortsPerSec, engine.Accuracy*100, sa.opts.Workers,
+			engine.Workers, rate)
+	}
+
 	optimal := calculateOptimalWorkers(sa.portCount)
 	return fmt.Sprintf("⚡ 并发优化建议:\n"+
 		"   当前并发数 %d 可能过高，建议:\n"+
@@ -129,6 +167,65 @@ func (sa *ScanAdvisor) suggestTimeOptimization(estimatedTime time.Duration) stri
 		formatDuration(estimatedTime))
 }
 
+// suggestHostTimeoutOptimization 单主机超时建议
+func (sa *ScanAdvisor) suggestHostTimeoutOptimization(estimatedTime time.Duration) string {
+	return fmt.Sprintf("⏳ 单主机超时建议:\n"+
+		"   预估扫描时间 %s 超过单主机超时 %s，主机很可能被提前放弃，建议:\n"+
+		"   • 适当增大 --host-timeout\n"+
+		"   • 或缩小端口范围、提高并发数",
+		formatDuration(estimatedTime), formatDuration(sa.opts.HostTimeout))
+}
+
+// PolicyWarnings 根据扫描结果生成主机放弃与重试策略的提示
+func (sa *ScanAdvisor) PolicyWarnings(results []ScanResult, giveUps []*HostGiveUp) []string {
+	var warnings []string
+
+	for _, g := range giveUps {
+		switch g.Reason {
+		case GiveUpReasonTarpit:
+			warnings = append(warnings, fmt.Sprintf("🕳️ 主机 %s 疑似tarpit，已放弃扫描:\n"+
+				"   %s\n"+
+				"   • 该主机的开放端口结果不可信，请勿直接使用\n"+
+				"   • 如确认不是tarpit，可去掉 --detect-tarpit 后重新扫描", g.Target, g.Detail))
+		case GiveUpReasonHostTimeout:
+			warnings = append(warnings, fmt.Sprintf("⏳ 主机 %s 超时，已放弃扫描:\n"+
+				"   %s\n"+
+				"   • 结果只包含超时前完成的端口\n"+
+				"   • 可增大 --host-timeout 或缩小端口范围后重新扫描", g.Target, g.Detail))
+		default:
+			warnings = append(warnings, fmt.Sprintf("⚠️ 主机 %s 已被放弃扫描: %s", g.Target, g.Detail))
+		}
+	}
+
+	retried := 0
+	for _, r := range results {
+		if n, ok := r.Metadata["retries"].(int); ok && n > 0 {
+			retried++
+		}
+	}
+	if retried > 0 {
+		warnings = append(warnings, fmt.Sprintf("🔁 重试提示:\n"+
+			"   %d 个端口经过重试才得到结果，网络可能存在丢包或限速，建议:\n"+
+			"   • 适当增大超时时间\n"+
+			"   • 降低并发数或扫描速率", retried))
+	}
+
+	return warnings
+}
+
+// PrintPolicyWarnings 打印主机放弃与重试策略的提示
+func (sa *ScanAdvisor) PrintPolicyWarnings(results []ScanResult, giveUps []*HostGiveUp) {
+	warnings := sa.PolicyWarnings(results, giveUps)
+	if len(warnings) == 0 {
+		return
+	}
+
+	fmt.Println("\n🚧 扫描策略提示:")
+	for i, warning := range warnings {
+		fmt.Printf("\n%d. %s\n", i+1, warning)
+	}
+}
+
 // isAllFeaturesEnabled 检查是否启用了所有功能
 func (sa *ScanAdvisor) isAllFeaturesEnabled() bool {
 	return sa.opts.EnableService && sa.opts.EnableOS &&
@@ -146,25 +243,13 @@ func (sa *ScanAdvisor) isPublicTarget() bool {
 		!strings.HasPrefix(target, "172.")
 }
 
-// estimateScanTime 估算扫描时间
+// estimateScanTime 估算单个目标的扫描时间，与扫描计划使用相同的估算方式
 func (sa *ScanAdvisor) estimateScanTime() time.Duration {
-	baseTimePerPort := sa.opts.Timeout
-
-	// 考虑功能开销
-	if sa.opts.EnableService {
-		baseTimePerPort += time.Second
-	}
-	if sa.opts.EnableOS {
-		baseTimePerPort += time.Second * 2
+	var total time.Duration
+	for _, stage := range planStages(sa.opts, 1, sa.portCount) {
+		total += stage.Duration
 	}
-	if sa.opts.BannerProbe {
-		baseTimePerPort += time.Millisecond * 500
-	}
-
-	// 考虑并发
-	totalTime := time.Duration(sa.portCount) * baseTimePerPort / time.Duration(sa.opts.Workers)
-
-	return totalTime
+	return total
 }
 
 // formatDuration 格式化时间显示
@@ -209,6 +294,14 @@ func (sa *ScanAdvisor) GetOptimizedConfig() *ScanOptions {
 		optimized.Timeout = time.Second * 3
 	}
 
+	// 有机器画像时使用实测的最佳并发与速率
+	if engine, ok := sa.profile.Engine(sa.opts.ScanType); ok {
+		optimized.Workers = engine.Workers
+		if engine.RateLimit > 0 {
+			optimized.RateLimit = engine.RateLimit
+		}
+	}
+
 	// 大规模扫描时建议禁用一些功能以提高速度，但不强制覆盖用户设置
 	// 只有当用户没有明确设置时才应用优化
 	if sa.portCount > 5000 {
@@ -2,8 +2,10 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -41,11 +43,15 @@ var CommonServices = map[int]string{
 
 // Scanner 端口扫描器
 type Scanner struct {
-	opts     *ScanOptions
-	ports    []int
-	results  []*ScanResult
-	progress float64
-	mu       sync.Mutex
+	opts      *ScanOptions
+	ports     []int
+	results   []*ScanResult
+	progress  float64
+	completed int
+	events    *eventBus
+	hostUp    sync.Once
+	giveUp    *HostGiveUp
+	mu        sync.Mutex
 }
 
 // NewScanner 创建新的扫描器
@@ -68,6 +74,18 @@ func NewScanner(opts *ScanOptions) (*Scanner, error) {
 
 // Scan 执行扫描
 func (s *Scanner) Scan(ctx context.Context) ([]*ScanResult, error) {
+	s.mu.Lock()
+	s.completed = 0
+	s.hostUp = sync.Once{}
+	s.mu.Unlock()
+
+	s.events = newEventBus(s.opts.Target, s.opts.ScanType, s.opts.Observers)
+	s.events.started(len(s.ports))
+	defer s.events.finished()
+
+	// 主机级放弃策略：单主机超时与tarpit检测
+	hostCtx, guard := newHostGuard(ctx, s.opts)
+
 	// 创建工作线程池
 	jobs := make(chan int, len(s.ports))
 	results := make(chan *ScanResult, len(s.ports))
@@ -79,13 +97,19 @@ func (s *Scanner) Scan(ctx context.Context) ([]*ScanResult, error) {
 		go func() {
 			defer wg.Done()
 			for port := range jobs {
-				if ctx.Err() != nil {
+				if hostCtx.Err() != nil {
 					return
 				}
-				result := s.scanPort(ctx, port)
-				if result != nil {
-					results <- result
+				result, _ := retryPort(hostCtx, s.opts.MaxRetries, func() (ScanResult, error) {
+					return *s.scanPort(hostCtx, port), nil
+				})
+				// 主机被放弃时中断的连接无法判定端口状态，不计入结果
+				if hostCtx.Err() != nil && result.State == PortStateFiltered {
+					return
 				}
+				guard.observe(result)
+				s.notifyResult(&result)
+				results <- &result
 				s.updateProgress()
 			}
 		}()
@@ -94,7 +118,7 @@ func (s *Scanner) Scan(ctx context.Context) ([]*ScanResult, error) {
 	// 分发扫描任务
 	go func() {
 		for _, port := range s.ports {
-			if ctx.Err() != nil {
+			if hostCtx.Err() != nil {
 				break
 			}
 			jobs <- port
@@ -119,9 +143,45 @@ func (s *Scanner) Scan(ctx context.Context) ([]*ScanResult, error) {
 		s.mu.Unlock()
 	}
 
+	// 被放弃的主机在结果中标记原因
+	s.mu.Lock()
+	s.giveUp = guard.finish(hostCtx)
+	if s.giveUp != nil {
+		for _, result := range s.results {
+			markAbandoned(result, s.giveUp)
+		}
+	}
+	giveUp := s.giveUp
+	s.mu.Unlock()
+
+	if giveUp != nil {
+		s.events.abandoned(giveUp)
+	}
+
 	return s.results, nil
 }
 
+// GiveUp 返回主机被放弃的记录，未放弃时返回nil
+func (s *Scanner) GiveUp() *HostGiveUp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.giveUp
+}
+
+// notifyResult 分发端口结果事件，首个开放端口同时标记主机存活
+func (s *Scanner) notifyResult(result *ScanResult) {
+	s.events.portResult(*result)
+	if result.State != PortStateOpen {
+		return
+	}
+	s.hostUp.Do(func() {
+		s.events.emit(ScanEvent{
+			Type: EventHostUp,
+			Host: &HostStatus{IP: s.opts.Target, Up: true, Method: fmt.Sprintf("TCP/%d", result.Port)},
+		})
+	})
+}
+
 // scanPort 扫描单个端口
 func (s *Scanner) scanPort(ctx context.Context, port int) *ScanResult {
 	result := &ScanResult{
@@ -140,7 +200,7 @@ func (s *Scanner) scanPort(ctx context.Context, port int) *ScanResult {
 
 	// 创建连接
 	addr := fmt.Sprintf("%s:%d", s.opts.Target, port)
-	conn, err := net.DialTimeout(string(s.opts.ScanType), addr, s.opts.Timeout)
+	conn, err := scanDialer(s.opts).DialContext(ctx, string(s.opts.ScanType), addr)
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok {
 			if netErr.Timeout() {
@@ -170,6 +230,7 @@ func (s *Scanner) scanPort(ctx context.Context, port int) *ScanResult {
 		result.State = PortStateClosed
 		return result
 	}
+	conn = wrapConn(conn, s.opts.Capture)
 
 	result.State = PortStateOpen
 
@@ -212,12 +273,15 @@ func (s *Scanner) detectService(conn net.Conn, port int) (*fingerprint.Service,
 	if err != nil {
 		return nil, fmt.Errorf("创建指纹识别器失败: %v", err)
 	}
+	fp.SetObserver(s.events.fingerprintObserver())
 
 	// 设置指纹识别选项
 	opts := fingerprint.DefaultFingerprintOptions()
 	opts.EnableServiceDetection = true
-	// 从Scanner选项中设置超时
+	// 从Scanner选项中设置超时、探测强度和拨号器
 	opts.Timeout = s.opts.Service.Timeout
+	opts.VersionIntensity = s.opts.Service.VersionIntensity
+	opts.Dial = fingerprintDial(scanDialer(s.opts))
 	fp.SetOptions(opts)
 
 	// 执行服务指纹识别
@@ -228,22 +292,24 @@ func (s *Scanner) detectService(conn net.Conn, port int) (*fingerprint.Service,
 	}
 
 	// 转换为Service结构
-	service := &fingerprint.Service{
-		Name:       serviceFp.Name,
-		Product:    serviceFp.Product,
-		Version:    serviceFp.Version,
-		Protocol:   "tcp", // 默认为TCP
-		DeviceType: "",    // 如果服务有设备类型可以设置
-		Banner:     "",    // 从服务探测中可能获取到的banner
-		Confidence: serviceFp.Confidence,
-		Metadata:   make(map[string]string),
-	}
+	service := serviceFromFingerprint(serviceFp)
 
 	// 添加指纹识别来源
-	service.Metadata["source"] = "embedded-fingerprint-db"
+	service.Metadata["source"] = fingerprintSource(serviceFp.DBVersion)
+	if probe := serviceFp.Features["probe"]; strings.HasPrefix(probe, fingerprint.UserRuleProbePrefix) {
+		service.Metadata["source"] = "user-fingerprint-rule"
+		service.Metadata["rule"] = strings.TrimPrefix(probe, fingerprint.UserRuleProbePrefix)
+	}
 	// 添加置信度
 	service.Metadata["confidence"] = fmt.Sprintf("%.2f", serviceFp.Confidence)
 
+	if s.opts.Service.TLSInspection && serviceFp.NeedsTLSInspection() {
+		service.TLS = inspectTLS(target, port, tlsServerName(s.opts.Target), s.opts.Service.Timeout, scanDialer(s.opts))
+		if service.TLS != nil && service.Name == "" {
+			service.Name = "ssl"
+		}
+	}
+
 	return service, nil
 }
 
@@ -273,6 +339,7 @@ func (s *Scanner) detectOS(conn net.Conn) (*fingerprint.OSInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("创建指纹识别器失败: %v", err)
 	}
+	fp.SetObserver(s.events.fingerprintObserver())
 
 	// 设置指纹识别选项
 	opts := fingerprint.DefaultFingerprintOptions()
@@ -291,6 +358,20 @@ func (s *Scanner) detectOS(conn net.Conn) (*fingerprint.OSInfo, error) {
 	for _, r := range s.results {
 		if r.State == PortStateOpen {
 			openPorts = append(openPorts, r.Port)
+		} else if r.State == PortStateClosed && opts.ClosedTCPPort == 0 {
+			opts.ClosedTCPPort = r.Port
+		}
+	}
+
+	// 能收发原始报文时发送nmap第二代探测，否则退回连接探测
+	if s.opts.RawTransport != nil {
+		opts.Transport = s.opts.RawTransport
+	} else if os.Geteuid() == 0 {
+		if transport, err := newICMPSocketTransport(); err == nil {
+			defer transport.Close()
+			opts.Transport = transport
+		} else {
+			logger.Debugf("创建原始报文传输失败，使用连接探测: %v", err)
 		}
 	}
 
@@ -307,6 +388,7 @@ func (s *Scanner) detectOS(conn net.Conn) (*fingerprint.OSInfo, error) {
 	}
 
 	// 尝试从TTL猜测操作系统
+	ttlGuessed := false
 	ttl, err := getTTLValue(ipAddress)
 	if err == nil {
 		// 根据TTL猜测操作系统
@@ -314,27 +396,29 @@ func (s *Scanner) detectOS(conn net.Conn) (*fingerprint.OSInfo, error) {
 		if osFp.Name == "" {
 			osFp.Name = guessedOS
 			osFp.Confidence = 60.0 // TTL猜测的置信度较低
+			ttlGuessed = true
 		}
 	}
 
 	// 转换为OSInfo结构
-	osInfo := &fingerprint.OSInfo{
-		Name:       osFp.Name,
-		Family:     "", // 从指纹中可能无法获取OS家族，需要进一步解析
-		Version:    osFp.Version,
-		Confidence: osFp.Confidence,
-		Metadata:   make(map[string]string),
-	}
+	osInfo := osInfoFromFingerprint(osFp)
 
 	// 添加指纹识别来源
-	osInfo.Metadata["source"] = "embedded-fingerprint-db"
+	osInfo.Metadata["source"] = fingerprintSource(osFp.DBVersion)
 	// 添加TTL信息
 	if ttl > 0 {
 		osInfo.Metadata["ttl"] = fmt.Sprintf("%d", ttl)
 	}
 
-	// 尝试解析OS家族
-	osInfo.Family = parseOSFamily(osFp.Name)
+	// 指纹库未给出家族时从名称解析
+	if osInfo.Family == "" {
+		osInfo.Family = parseOSFamily(osFp.Name)
+	}
+
+	// 指纹库未命中时由扫描器补发TTL推测结果
+	if ttlGuessed {
+		s.events.emit(ScanEvent{Type: EventOSDetected, Target: ipAddress, OS: osInfo})
+	}
 
 	return osInfo, nil
 }
@@ -364,6 +448,14 @@ func parseOSFamily(osName string) string {
 	return "Unknown"
 }
 
+// fingerprintSource 根据指纹库版本返回识别来源，区分内置指纹库和导入的指纹库
+func fingerprintSource(dbVersion string) string {
+	if dbVersion == "" || strings.HasPrefix(dbVersion, "embedded-") {
+		return "embedded-fingerprint-db"
+	}
+	return "user-fingerprint-db"
+}
+
 // guessOSFromTTL 根据TTL猜测操作系统
 func guessOSFromTTL(ttl int) string {
 	if ttl <= 64 {
@@ -483,10 +575,12 @@ func getHostname(ipAddress string) (string, error) {
 // updateProgress 更新扫描进度
 func (s *Scanner) updateProgress() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	total := float64(len(s.ports))
-	current := float64(len(s.results))
-	s.progress = (current / total) * 100
+	s.completed++
+	completed, total := s.completed, len(s.ports)
+	s.progress = (float64(completed) / float64(total)) * 100
+	s.mu.Unlock()
+
+	s.events.progress(completed, total)
 }
 
 // GetProgress 获取扫描进度
@@ -565,53 +659,60 @@ func ExecuteScan(opts *ScanOptions) ([]ScanResult, error) {
 	}
 
 	// 创建扫描建议器并提供建议
-	advisor, err := NewScanAdvisor(opts)
-	if err != nil {
-		logger.Warnf("无法创建扫描建议器: %v", err)
-	} else {
-		advisor.PrintSuggestions()
-	}
-
-	// 根据扫描类型执行不同的扫描
-	switch opts.ScanType {
-	case ScanTypeTCP:
-		// 使用QuickScanWithOptions来保持用户配置
-		results, err = QuickScanWithOptions(opts)
-	case ScanTypeSYN:
-		// 执行基础SYN扫描，然后应用用户配置进行后处理
-		results, err = executeScanWithOptions(opts, portInts, SYNScan)
-	case ScanTypeFIN:
-		// 执行基础FIN扫描，然后应用用户配置进行后处理
-		results, err = executeScanWithOptions(opts, portInts, FINScan)
-	case ScanTypeNULL:
-		// 执行基础NULL扫描，然后应用用户配置进行后处理
-		results, err = executeScanWithOptions(opts, portInts, NULLScan)
-	case ScanTypeXMAS:
-		// 执行基础XMAS扫描，然后应用用户配置进行后处理
-		results, err = executeScanWithOptions(opts, portInts, XMASScan)
-	case ScanTypeACK:
-		// 执行基础ACK扫描，然后应用用户配置进行后处理
-		results, err = executeScanWithOptions(opts, portInts, ACKScan)
-	case ScanTypeUDP:
-		// 执行基础UDP扫描，然后应用用户配置进行后处理
-		results, err = executeScanWithOptions(opts, portInts, UDPScan)
-	default:
-		return nil, fmt.Errorf("不支持的扫描类型: %s", opts.ScanType)
+	var advisor *ScanAdvisor
+	if !opts.Quiet {
+		advisor, err = NewScanAdvisor(opts)
+		if err != nil {
+			logger.Warnf("无法创建扫描建议器: %v", err)
+		} else {
+			advisor.PrintSuggestions()
+		}
 	}
 
+	// 收集主机放弃事件，扫描结束后由建议器提示
+	recorder := &giveUpRecorder{}
+	scanOpts := *opts
+	scanOpts.Observers = append(append([]Observer{}, opts.Observers...), recorder)
+	opts = &scanOpts
+
+	// 离线重放模式下所有扫描类型都由抓包数据应答
+	if opts.Replay != nil {
+		return executeReplayScan(opts, portInts)
+	}
+
+	// 内置和第三方注册的扫描类型都通过注册表执行，然后应用用户配置进行后处理
+	if err := ValidateScanType(opts.ScanType); err != nil {
+		return nil, err
+	}
+	results, err = executeRegisteredScan(opts)
+	if err == nil {
+		results, err = applyUserConfigToResults(results, opts)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("扫描失败: %v", err)
 	}
 
+	// 提示触发的主机放弃与重试策略
+	if advisor != nil {
+		advisor.PrintPolicyWarnings(results, recorder.list())
+	}
+
 	// 如果启用了服务检测
 	if opts.Service != nil && opts.Service.EnableVersionDetection {
+		events := newEventBus(opts.Target, opts.ScanType, opts.Observers)
+		// 服务探测与端口扫描使用相同的拨号器
+		serviceOpts := *opts.Service
+		if serviceOpts.Dialer == nil {
+			serviceOpts.Dialer = scanDialer(opts)
+		}
 		for i := range results {
 			if results[i].State == PortStateOpen {
 				// 执行服务检测
-				serviceInfo, err := DetectService(opts.Target, results[i].Port, opts.Service)
+				serviceInfo, err := DetectService(opts.Target, results[i].Port, &serviceOpts)
 				if err == nil {
 					results[i].Service = ConvertServiceInfoToFingerprint(serviceInfo)
 					results[i].ServiceName = serviceInfo.Name
+					events.emit(ScanEvent{Type: EventServiceDetected, Port: results[i].Port, Service: results[i].Service})
 				}
 			}
 		}
@@ -679,6 +780,7 @@ func PrintResults(results []ScanResult) {
 	fmt.Printf("总共扫描端口: %d   开放: %d   关闭: %d   被过滤: %d\n",
 		len(results), openPorts, closedPorts, filteredPorts)
 	fmt.Printf("扫描时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	PrintHoneypotScore(ScoreHoneypot(results))
 	fmt.Println()
 
 	// 打印开放端口详细信息
@@ -775,6 +877,24 @@ func PrintResults(results []ScanResult) {
 				}
 			}
 
+			// 打印TLS检查结果
+			if result.Service != nil && result.Service.TLS != nil {
+				tlsInfo := result.Service.TLS
+				fmt.Printf("\n      └─ TLS: %s  JARM: %s", strings.Join(tlsInfo.VersionNames(), " "), tlsInfo.JARM)
+				if len(tlsInfo.Certificates) > 0 {
+					leaf := tlsInfo.Certificates[0]
+					fmt.Printf("\n      └─ 证书: %s (%s %d, 有效期至 %s", leaf.Subject, leaf.KeyType, leaf.KeyBits,
+						leaf.NotAfter.Format("2006-01-02"))
+					if leaf.Expired {
+						fmt.Printf(", 已过期")
+					}
+					if !tlsInfo.Trusted {
+						fmt.Printf(", 不受信任")
+					}
+					fmt.Printf(")")
+				}
+			}
+
 			fmt.Println()
 		}
 		fmt.Println()
@@ -843,16 +963,61 @@ func TCPScanWithOptions(opts *ScanOptions) ([]ScanResult, error) {
 // ScanFunc 扫描函数类型定义
 type ScanFunc func(target string, ports []int, timeout time.Duration, workers int) ([]ScanResult, error)
 
-// executeScanWithOptions 执行扫描并应用用户配置进行后处理
-func executeScanWithOptions(opts *ScanOptions, ports []int, scanFunc ScanFunc) ([]ScanResult, error) {
-	// 执行基础扫描
-	results, err := scanFunc(opts.Target, ports, opts.Timeout, opts.Workers)
-	if err != nil {
+// hostScanFunc 在主机上下文中扫描一组端口，每个端口得到结果后调用observe
+// 主机被放弃时停止探测，返回已完成的端口结果和上下文错误
+type hostScanFunc func(ctx context.Context, ports []int, observe func(ScanResult)) ([]ScanResult, error)
+
+// batchScanFunc 将一次性返回结果的扫描函数转换为主机扫描函数，结果返回后再交给主机放弃策略
+func batchScanFunc(opts *ScanOptions, scanFunc ScanFunc) hostScanFunc {
+	return func(ctx context.Context, ports []int, observe func(ScanResult)) ([]ScanResult, error) {
+		results, err := scanFunc(opts.Target, ports, opts.Timeout, opts.Workers)
+		for _, result := range results {
+			observe(result)
+		}
+		return results, err
+	}
+}
+
+// runScanFunc 在主机放弃策略下执行扫描函数，并补发扫描事件
+func runScanFunc(ctx context.Context, opts *ScanOptions, ports []int, scan hostScanFunc) ([]ScanResult, error) {
+	events := newEventBus(opts.Target, opts.ScanType, opts.Observers)
+	events.started(len(ports))
+	defer events.finished()
+
+	// 主机级放弃策略：单主机超时与tarpit检测，放弃后扫描函数停止发送探测
+	hostCtx, guard := newHostGuard(ctx, opts)
+	results, err := scan(hostCtx, ports, guard.observe)
+	giveUp := guard.finish(hostCtx)
+	if err != nil && (giveUp == nil || !errors.Is(err, hostCtx.Err())) {
+		events.failed(0, err)
 		return nil, err
 	}
 
-	// 应用用户配置进行后处理
-	return applyUserConfigToResults(results, opts)
+	// 扫描函数一次性返回结果，在此补发端口事件
+	for i := range results {
+		markAbandoned(&results[i], giveUp)
+		events.portResult(results[i])
+		events.progress(i+1, len(results))
+	}
+	if giveUp != nil {
+		events.abandoned(giveUp)
+	}
+	return results, nil
+}
+
+// runQuietScan 执行单一类型的扫描，不打印扫描建议，供需要组合多次扫描的分析功能使用
+func runQuietScan(opts *ScanOptions) ([]ScanResult, error) {
+	if err := ValidateScanType(opts.ScanType); err != nil {
+		return nil, err
+	}
+	ports, err := parsePorts(opts.Ports)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Replay != nil {
+		return executeReplayScan(opts, ports)
+	}
+	return executeRegisteredScan(opts)
 }
 
 // applyUserConfigToResults 将用户配置应用到扫描结果
@@ -1019,27 +1184,41 @@ func scanPort(target string, port int, timeout time.Duration) ScanResult {
 
 // UDPScan 执行UDP扫描
 func UDPScan(target string, ports []int, timeout time.Duration, workers int) ([]ScanResult, error) {
-	// 使用新的UDP扫描器
-	udpResults, err := ExecuteUDPScan(target, ports, timeout, workers)
-	if err != nil {
+	return udpScan(nil, target, ports, timeout, workers)
+}
+
+// udpScanFunc 将扫描选项中的拨号器和重试次数绑定到UDP扫描函数
+func udpScanFunc(opts *ScanOptions) hostScanFunc {
+	return func(ctx context.Context, ports []int, observe func(ScanResult)) ([]ScanResult, error) {
+		udpScanner := NewUDPScanner(opts.Target, ports, opts.Timeout, opts.Workers)
+		udpScanner.dialer = opts.Dialer
+		udpScanner.retries = opts.MaxRetries
+		udpScanner.observe = observe
+		return runUDPScanner(ctx, udpScanner)
+	}
+}
+
+// udpScan 使用指定拨号器执行UDP扫描，dialer为空时直接访问网络
+func udpScan(dialer Dialer, target string, ports []int, timeout time.Duration, workers int) ([]ScanResult, error) {
+	udpScanner := NewUDPScanner(target, ports, timeout, workers)
+	udpScanner.dialer = dialer
+	return runUDPScanner(context.Background(), udpScanner)
+}
+
+// runUDPScanner 执行UDP扫描并转换为通用结果，上下文取消时返回已完成的端口结果
+func runUDPScanner(ctx context.Context, udpScanner *UDPScanner) ([]ScanResult, error) {
+	udpResults, err := udpScanner.Scan(ctx)
+	if err != nil && ctx.Err() == nil {
 		return nil, fmt.Errorf("UDP扫描失败: %v", err)
 	}
 
 	// 将UDP扫描结果转换为通用ScanResult格式
 	results := make([]ScanResult, len(udpResults))
 	for i, udpResult := range udpResults {
-		results[i] = ScanResult{
-			Port:        udpResult.Port,
-			State:       udpResult.State,
-			Type:        ScanTypeUDP,
-			ServiceName: udpResult.ServiceName,
-			Version:     udpResult.Version,
-			Banner:      udpResult.Banner,
-			Open:        udpResult.State == PortStateOpen,
-		}
+		results[i] = udpResult.toScanResult()
 	}
 
-	return results, nil
+	return results, err
 }
 
 // validateTarget 验证目标地址是否有效
@@ -0,0 +1,123 @@
+package scannertest
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// conn 模拟网络中的连接
+// 服务的应答在写入时同步产生，读取时没有数据即返回超时，不会阻塞
+type conn struct {
+	network string
+	local   net.Addr
+	remote  net.Addr
+	service *Service
+
+	mu      sync.Mutex
+	inbox   []byte
+	err     error
+	closed  bool
+	onWrite func(b []byte)
+}
+
+// newConn 创建连接，服务的banner在连接建立后即可读取
+func newConn(network string, local, remote net.Addr, service *Service) *conn {
+	c := &conn{network: network, local: local, remote: remote, service: service}
+	if service != nil {
+		c.inbox = append(c.inbox, service.Banner...)
+		c.onWrite = func(b []byte) {
+			if service.Reply != nil {
+				c.push(service.Reply(b))
+			}
+		}
+	}
+	return c
+}
+
+// push 追加待读取的数据
+func (c *conn) push(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inbox = append(c.inbox, b...)
+}
+
+// fail 设置下一次读取返回的错误
+func (c *conn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+// opError 包装为与真实连接一致的错误
+func (c *conn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: c.network, Source: c.local, Addr: c.remote, Err: err}
+}
+
+// Read 读取服务应答
+func (c *conn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, c.opError("read", net.ErrClosed)
+	}
+	if len(c.inbox) > 0 {
+		n := copy(b, c.inbox)
+		c.inbox = c.inbox[n:]
+		return n, nil
+	}
+	if c.err != nil {
+		err := c.err
+		c.err = nil
+		return 0, c.opError("read", err)
+	}
+	if c.network == "tcp" && c.service == nil {
+		// 没有服务的开放端口在读取时视为对端关闭
+		return 0, io.EOF
+	}
+	return 0, c.opError("read", os.ErrDeadlineExceeded)
+}
+
+// Write 发送数据并同步产生应答
+func (c *conn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	closed, onWrite := c.closed, c.onWrite
+	c.mu.Unlock()
+
+	if closed {
+		return 0, c.opError("write", net.ErrClosed)
+	}
+	if onWrite != nil && len(b) > 0 {
+		onWrite(append([]byte(nil), b...))
+	}
+	return len(b), nil
+}
+
+// Close 关闭连接
+func (c *conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// LocalAddr 返回本地地址
+func (c *conn) LocalAddr() net.Addr { return c.local }
+
+// RemoteAddr 返回远端地址
+func (c *conn) RemoteAddr() net.Addr { return c.remote }
+
+// SetDeadline 模拟连接不会阻塞，忽略超时设置
+func (c *conn) SetDeadline(t time.Time) error { return nil }
+
+// SetReadDeadline 模拟连接不会阻塞，忽略超时设置
+func (c *conn) SetReadDeadline(t time.Time) error { return nil }
+
+// SetWriteDeadline 模拟连接不会阻塞，忽略超时设置
+func (c *conn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,137 @@
+package scannertest_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner/scannertest"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOSFingerprinter 创建通过模拟网络探测的指纹识别器
+func newOSFingerprinter(t *testing.T, network *scannertest.Network) *fingerprint.Fingerprinter {
+	fp, err := fingerprint.NewFingerprinter("")
+	require.NoError(t, err)
+
+	opts := fingerprint.DefaultFingerprintOptions()
+	opts.EnableOSDetection = true
+	opts.Timeout = time.Second
+	opts.Dial = network.Dial
+	fp.SetOptions(opts)
+	return fp
+}
+
+func TestOSFingerprinterProbesSimulatedHost(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(&scannertest.Host{
+		IP:  net.ParseIP(target),
+		TCP: map[int]scannertest.PortState{80: scannertest.PortOpen},
+		UDP: map[int]scannertest.PortState{80: scannertest.PortOpen},
+		Services: map[int]*scannertest.Service{
+			80: {Reply: func(req []byte) []byte { return []byte("HTTP/1.1 400 Bad Request\r\n\r\n") }},
+		},
+		UDPServices: map[int]*scannertest.Service{
+			80: {Reply: func(req []byte) []byte { return []byte{0x01} }},
+		},
+	})
+
+	osFp, err := newOSFingerprinter(t, network).FingerprintOS(target, []int{80})
+	require.NoError(t, err)
+
+	types := make(map[string]int)
+	for _, probe := range osFp.Probes {
+		types[probe.Type]++
+	}
+	assert.Equal(t, map[string]int{"SEQ": 1, "ICMP": 1, "ECN": 1, "TCP_OPTIONS": 1, "UDP": 1}, types)
+
+	// ICMP回显应答类型为0
+	assert.Equal(t, "0000", osFp.Features["icmp"][:4])
+	assert.Equal(t, "01", osFp.Features["udp"])
+	assert.NotEmpty(t, osFp.Features["seq"])
+}
+
+func TestOSFingerprinterRequiresEcho(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(&scannertest.Host{
+		IP:     net.ParseIP(target),
+		NoEcho: true,
+	})
+
+	_, err := newOSFingerprinter(t, network).FingerprintOS(target, []int{80})
+	assert.Error(t, err)
+}
+
+// newRawOSFingerprinter 创建通过模拟网络原始报文发送nmap第二代探测的指纹识别器
+func newRawOSFingerprinter(t *testing.T, network *scannertest.Network) *fingerprint.Fingerprinter {
+	fp := newOSFingerprinter(t, network)
+	opts := fp.GetOptions()
+	opts.Timeout = 200 * time.Millisecond
+	opts.Transport = network.RawConn()
+	opts.ClosedTCPPort = 81
+	return fp
+}
+
+func TestOSFingerprinterRawProbes(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(&scannertest.Host{
+		IP:       net.ParseIP(target),
+		DontFrag: true,
+		Window:   0xFE88,
+		TCPOptions: []layers.TCPOption{
+			{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}},
+			{OptionType: layers.TCPOptionKindSACKPermitted, OptionLength: 2},
+			{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10, OptionData: []byte{0, 0, 0, 1, 0, 0, 0, 1}},
+			{OptionType: layers.TCPOptionKindNop},
+			{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{7}},
+		},
+		TCP: map[int]scannertest.PortState{80: scannertest.PortOpen},
+	})
+
+	osFp, err := newRawOSFingerprinter(t, network).FingerprintOS(target, []int{80})
+	require.NoError(t, err)
+	require.Len(t, osFp.Probes, 16)
+
+	// 应答按nmap第二代测试计算为属性
+	assert.Equal(t, "M5B4ST11NW7", attr(osFp.Features["OPS"], "O1"))
+	assert.Equal(t, "FE88", attr(osFp.Features["WIN"], "W1"))
+	assert.Equal(t, "Y", attr(osFp.Features["T1"], "DF"))
+	assert.Equal(t, "AS", attr(osFp.Features["T1"], "F"))
+	assert.Equal(t, "R=N", osFp.Features["T2"])
+	assert.Equal(t, "AR", attr(osFp.Features["T5"], "F"))
+	assert.Equal(t, "S+", attr(osFp.Features["T5"], "A"))
+	assert.Equal(t, "164", attr(osFp.Features["U1"], "IPL"))
+	assert.Equal(t, "G", attr(osFp.Features["U1"], "RID"))
+	assert.Equal(t, "40", attr(osFp.Features["U1"], "T"))
+	assert.Equal(t, "S", attr(osFp.Features["IE"], "CD"))
+	assert.Equal(t, "1", attr(osFp.Features["SEQ"], "TS"))
+}
+
+func TestOSFingerprinterRawProbesNoEcho(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(&scannertest.Host{
+		IP:     net.ParseIP(target),
+		NoEcho: true,
+		TCP:    map[int]scannertest.PortState{80: scannertest.PortOpen},
+	})
+
+	// 原始报文探测不要求主机应答ICMP回显
+	osFp, err := newRawOSFingerprinter(t, network).FingerprintOS(target, []int{80})
+	require.NoError(t, err)
+	assert.Equal(t, "R=N", osFp.Features["IE"])
+	assert.Equal(t, "M5B4ST00NW7", attr(osFp.Features["OPS"], "O1"))
+}
+
+// attr 从nmap格式的属性字符串中取出属性值
+func attr(attrs, name string) string {
+	for _, part := range strings.Split(attrs, "%") {
+		if key, value, ok := strings.Cut(part, "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
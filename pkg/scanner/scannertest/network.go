@@ -0,0 +1,404 @@
+// Package scannertest 提供用于扫描器测试的模拟网络
+//
+// Network中的主机按配置对connect、UDP、ICMP以及原始TCP探测作出应答，
+// 支持开放/关闭/过滤端口、无状态过滤与防火墙RST拒绝、TTL、TCP选项、ICMP限速和丢包，
+// 通过ScanOptions.Dialer、ScanOptions.RawTransport和FingerprintOptions.Dial接入，测试不访问真实网络。
+// 模拟网络不会等待超时：没有应答时立即返回超时错误。
+package scannertest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// PortState 模拟端口对探测的反应
+type PortState int
+
+const (
+	// PortClosed TCP以RST应答，UDP以ICMP端口不可达应答
+	PortClosed PortState = iota
+	// PortOpen TCP完成握手，UDP由服务应答
+	PortOpen
+	// PortFiltered 静默丢弃探测
+	PortFiltered
+	// PortProhibited 以ICMP管理禁止应答
+	PortProhibited
+	// PortSYNFiltered 无状态过滤器丢弃SYN报文，其余TCP报文到达主机并按关闭端口应答
+	PortSYNFiltered
+	// PortRejected 防火墙代主机以RST拒绝全部TCP报文，RST的TTL为Host.FirewallTTL
+	PortRejected
+)
+
+// String 返回端口状态名称
+func (s PortState) String() string {
+	switch s {
+	case PortClosed:
+		return "closed"
+	case PortOpen:
+		return "open"
+	case PortFiltered:
+		return "filtered"
+	case PortProhibited:
+		return "prohibited"
+	case PortSYNFiltered:
+		return "syn-filtered"
+	case PortRejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// Service 开放端口上的模拟服务
+type Service struct {
+	Banner string                  // 连接建立后主动发送的数据
+	Reply  func(req []byte) []byte // 收到数据后的应答，返回nil表示不应答
+}
+
+// Host 模拟主机
+type Host struct {
+	IP          net.IP
+	TTL         uint8              // 应答报文的TTL，默认64
+	FirewallTTL uint8              // 主机前防火墙发出报文的TTL，默认255
+	Window      uint16             // SYN/ACK的窗口大小，默认64240
+	TCPOptions  []layers.TCPOption // SYN/ACK携带的TCP选项，默认为Linux风格的选项
+	IPID        uint16             // 应答IP ID的起始值，非0时逐个递增，默认随机
+	DontFrag    bool               // TCP应答设置DF位(Linux、Windows行为)
+
+	DefaultTCP PortState         // 未列出的TCP端口状态，默认关闭
+	DefaultUDP PortState         // 未列出的UDP端口状态，默认关闭
+	TCP        map[int]PortState // TCP端口状态
+	UDP        map[int]PortState // UDP端口状态
+
+	Services    map[int]*Service // TCP端口上的服务
+	UDPServices map[int]*Service // UDP端口上的服务
+
+	NoEcho        bool    // 不应答ICMP回显请求
+	ResetOnFIN    bool    // 开放端口同样以RST应答FIN/NULL/XMAS探测(Windows行为)
+	ICMPRateLimit int     // 每秒最多发送的ICMP差错报文数，0表示不限制
+	Loss          float64 // 探测或应答丢失的概率
+
+	// Backends 负载均衡后端，设置后连接与原始报文探测按轮询分发到各后端
+	// 后端不需要加入网络，其端口状态与服务决定应答
+	Backends []*Host
+
+	icmpWindow  time.Time
+	icmpSent    int
+	ipid        uint16
+	nextBackend int
+}
+
+// tcpState 返回TCP端口状态
+func (h *Host) tcpState(port int) PortState {
+	if state, ok := h.TCP[port]; ok {
+		return state
+	}
+	return h.DefaultTCP
+}
+
+// udpState 返回UDP端口状态
+func (h *Host) udpState(port int) PortState {
+	if state, ok := h.UDP[port]; ok {
+		return state
+	}
+	return h.DefaultUDP
+}
+
+// Network 模拟网络
+type Network struct {
+	// Local 扫描器所在的本地地址，默认10.0.0.1
+	Local net.IP
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	hosts    map[string]*Host
+	nextPort int
+	now      func() time.Time
+}
+
+// NewNetwork 创建模拟网络，相同的seed产生相同的丢包序列
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		Local:    net.IPv4(10, 0, 0, 1).To4(),
+		rand:     rand.New(rand.NewSource(seed)),
+		hosts:    make(map[string]*Host),
+		nextPort: 40000,
+		now:      time.Now,
+	}
+}
+
+// AddHost 向网络中加入主机并补全默认值
+func (n *Network) AddHost(h *Host) *Host {
+	h.setDefaults()
+	for _, backend := range h.Backends {
+		backend.setDefaults()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hosts[h.IP.String()] = h
+	return h
+}
+
+// setDefaults 补全主机配置的默认值
+func (h *Host) setDefaults() {
+	h.ipid = h.IPID
+	if h.TTL == 0 {
+		h.TTL = 64
+	}
+	if h.FirewallTTL == 0 {
+		h.FirewallTTL = 255
+	}
+	if h.Window == 0 {
+		h.Window = 64240
+	}
+	if h.TCPOptions == nil {
+		h.TCPOptions = []layers.TCPOption{
+			{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}},
+			{OptionType: layers.TCPOptionKindSACKPermitted, OptionLength: 2},
+			{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10, OptionData: make([]byte, 8)},
+			{OptionType: layers.TCPOptionKindNop},
+			{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{7}},
+		}
+	}
+	if h.TCP == nil {
+		h.TCP = make(map[int]PortState)
+	}
+	if h.UDP == nil {
+		h.UDP = make(map[int]PortState)
+	}
+}
+
+// host 按地址查找主机
+func (n *Network) host(ip net.IP) *Host {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.hosts[ip.String()]
+}
+
+// route 返回实际处理连接或探测的主机，负载均衡主机按轮询选择后端
+func (n *Network) route(h *Host) *Host {
+	if h == nil || len(h.Backends) == 0 {
+		return h
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	backend := h.Backends[h.nextBackend%len(h.Backends)]
+	h.nextBackend++
+	return backend
+}
+
+// ipID 返回主机下一个应答报文的IP ID
+func (n *Network) ipID(h *Host) uint16 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if h.IPID == 0 {
+		return uint16(n.rand.Uint32())
+	}
+	h.ipid++
+	return h.ipid
+}
+
+// lost 按主机丢包率判断本次报文是否丢失
+func (n *Network) lost(h *Host) bool {
+	if h.Loss <= 0 {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.rand.Float64() < h.Loss
+}
+
+// allowICMP 按主机ICMP限速判断能否发送差错报文
+func (n *Network) allowICMP(h *Host) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if h.ICMPRateLimit <= 0 {
+		return true
+	}
+	now := n.now()
+	if now.Sub(h.icmpWindow) >= time.Second {
+		h.icmpWindow = now
+		h.icmpSent = 0
+	}
+	if h.icmpSent >= h.ICMPRateLimit {
+		return false
+	}
+	h.icmpSent++
+	return true
+}
+
+// localPort 分配本地临时端口
+func (n *Network) localPort() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nextPort++
+	if n.nextPort > 65535 {
+		n.nextPort = 40001
+	}
+	return n.nextPort
+}
+
+// randUint32 生成随机序列号
+func (n *Network) randUint32() uint32 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.rand.Uint32()
+}
+
+// Dial 带超时的拨号，签名与FingerprintOptions.Dial一致
+func (n *Network) Dial(network, address string, timeout time.Duration) (net.Conn, error) {
+	return n.DialContext(context.Background(), network, address)
+}
+
+// DialContext 在模拟网络中建立连接，支持tcp、udp和ip4:icmp
+// 返回的错误与真实网络一致：超时、connection refused、no route to host
+func (n *Network) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	switch network {
+	case "tcp", "tcp4":
+		return n.dialTCP(address)
+	case "udp", "udp4":
+		return n.dialUDP(address)
+	case "ip4:icmp", "ip4:1":
+		return n.dialICMP(address)
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+}
+
+// resolve 解析host:port形式的地址，模拟网络中只接受IP
+func resolve(network, address string) (net.IP, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, 0, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, &net.OpError{Op: "dial", Net: network,
+			Err: &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}}
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, 0, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("无效的端口: %s", portStr)}
+	}
+	return ip.To4(), port, nil
+}
+
+// dialTCP 模拟TCP三次握手
+func (n *Network) dialTCP(address string) (net.Conn, error) {
+	ip, port, err := resolve("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	remote := &net.TCPAddr{IP: ip, Port: port}
+	fail := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Addr: remote, Err: err}
+	}
+
+	h := n.host(ip)
+	if h == nil || n.lost(h) {
+		return nil, fail(os.ErrDeadlineExceeded)
+	}
+	h = n.route(h)
+
+	switch h.tcpState(port) {
+	case PortOpen:
+		conn := newConn("tcp", &net.TCPAddr{IP: n.Local, Port: n.localPort()}, remote, h.Services[port])
+		return conn, nil
+	case PortClosed, PortRejected:
+		return nil, fail(os.NewSyscallError("connect", syscall.ECONNREFUSED))
+	case PortProhibited:
+		if n.allowICMP(h) {
+			return nil, fail(os.NewSyscallError("connect", syscall.EHOSTUNREACH))
+		}
+		return nil, fail(os.ErrDeadlineExceeded)
+	default:
+		return nil, fail(os.ErrDeadlineExceeded)
+	}
+}
+
+// dialUDP 创建UDP连接，UDP拨号总是成功，端口状态在读写时体现
+func (n *Network) dialUDP(address string) (net.Conn, error) {
+	ip, port, err := resolve("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	remote := &net.UDPAddr{IP: ip, Port: port}
+	conn := newConn("udp", &net.UDPAddr{IP: n.Local, Port: n.localPort()}, remote, nil)
+
+	h := n.host(ip)
+	conn.onWrite = func(b []byte) {
+		if h == nil || n.lost(h) {
+			return
+		}
+		switch h.udpState(port) {
+		case PortOpen:
+			if svc := h.UDPServices[port]; svc != nil && svc.Reply != nil {
+				conn.push(svc.Reply(b))
+			}
+		case PortClosed:
+			if n.allowICMP(h) {
+				conn.fail(os.NewSyscallError("read", syscall.ECONNREFUSED))
+			}
+		case PortProhibited:
+			if n.allowICMP(h) {
+				conn.fail(os.NewSyscallError("read", syscall.EHOSTUNREACH))
+			}
+		}
+	}
+	return conn, nil
+}
+
+// dialICMP 创建ICMP连接，应答回显请求
+func (n *Network) dialICMP(address string) (net.Conn, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, &net.OpError{Op: "dial", Net: "ip4:icmp",
+			Err: &net.DNSError{Err: "no such host", Name: address, IsNotFound: true}}
+	}
+	ip = ip.To4()
+	conn := newConn("ip4:icmp", &net.IPAddr{IP: n.Local}, &net.IPAddr{IP: ip}, nil)
+
+	h := n.host(ip)
+	conn.onWrite = func(b []byte) {
+		if h == nil || h.NoEcho || n.lost(h) || len(b) < 8 || b[0] != 8 {
+			return
+		}
+		// 回显应答：类型改为0并重新计算校验和
+		reply := append([]byte(nil), b...)
+		reply[0] = 0
+		reply[2], reply[3] = 0, 0
+		sum := checksum(reply)
+		reply[2], reply[3] = byte(sum>>8), byte(sum)
+		conn.push(reply)
+	}
+	return conn, nil
+}
+
+// checksum 计算ICMP校验和
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
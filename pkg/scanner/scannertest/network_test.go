@@ -0,0 +1,239 @@
+package scannertest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var simTarget = net.ParseIP("192.0.2.10").To4()
+
+// newSimNetwork 构造包含一台测试主机的模拟网络
+func newSimNetwork() *Network {
+	n := NewNetwork(1)
+	n.AddHost(&Host{
+		IP:  simTarget,
+		TCP: map[int]PortState{22: PortOpen, 25: PortFiltered, 113: PortProhibited},
+		UDP: map[int]PortState{53: PortOpen, 161: PortFiltered},
+		Services: map[int]*Service{
+			22: {Banner: "SSH-2.0-OpenSSH_8.9\r\n"},
+		},
+		UDPServices: map[int]*Service{
+			53: {Reply: func(req []byte) []byte { return append([]byte("dns:"), req...) }},
+		},
+	})
+	return n
+}
+
+func TestDialTCPStates(t *testing.T) {
+	n := newSimNetwork()
+	ctx := context.Background()
+
+	conn, err := n.DialContext(ctx, "tcp", "192.0.2.10:22")
+	require.NoError(t, err)
+	buf := make([]byte, 64)
+	count, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "SSH-2.0-OpenSSH_8.9\r\n", string(buf[:count]))
+	assert.Equal(t, "192.0.2.10:22", conn.RemoteAddr().String())
+
+	// 读完banner后没有更多数据，立即返回超时
+	_, err = conn.Read(buf)
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+
+	_, err = n.DialContext(ctx, "tcp", "192.0.2.10:23")
+	assert.True(t, errors.Is(err, syscall.ECONNREFUSED))
+	assert.Contains(t, err.Error(), "connection refused")
+
+	_, err = n.DialContext(ctx, "tcp", "192.0.2.10:25")
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+
+	_, err = n.DialContext(ctx, "tcp", "192.0.2.10:113")
+	assert.True(t, errors.Is(err, syscall.EHOSTUNREACH))
+
+	// 不存在的主机不应答
+	_, err = n.DialContext(ctx, "tcp", "192.0.2.99:22")
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+
+	_, err = n.DialContext(ctx, "tcp", "example.invalid:22")
+	var dnsErr *net.DNSError
+	assert.True(t, errors.As(err, &dnsErr))
+}
+
+func TestDialUDPStates(t *testing.T) {
+	n := newSimNetwork()
+	buf := make([]byte, 64)
+
+	conn, err := n.Dial("udp", "192.0.2.10:53", time.Second)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("q"))
+	require.NoError(t, err)
+	count, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "dns:q", string(buf[:count]))
+
+	conn, err = n.Dial("udp", "192.0.2.10:9", time.Second)
+	require.NoError(t, err)
+	conn.Write([]byte("q"))
+	_, err = conn.Read(buf)
+	assert.True(t, errors.Is(err, syscall.ECONNREFUSED))
+
+	conn, err = n.Dial("udp", "192.0.2.10:161", time.Second)
+	require.NoError(t, err)
+	conn.Write([]byte("q"))
+	_, err = conn.Read(buf)
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+}
+
+func TestICMPEcho(t *testing.T) {
+	n := newSimNetwork()
+	conn, err := n.Dial("ip4:icmp", "192.0.2.10", time.Second)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte{0x08, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x01})
+	require.NoError(t, err)
+
+	buf := make([]byte, 64)
+	count, err := conn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, 8, count)
+	assert.Equal(t, byte(0), buf[0])
+	assert.Equal(t, []byte{0x12, 0x34, 0x00, 0x01}, buf[4:8])
+	assert.Equal(t, uint16(0), checksum(buf[:count]))
+
+	n.host(simTarget).NoEcho = true
+	conn, err = n.Dial("ip4:icmp", "192.0.2.10", time.Second)
+	require.NoError(t, err)
+	conn.Write([]byte{0x08, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x02})
+	_, err = conn.Read(buf)
+	assert.Error(t, err)
+}
+
+func TestICMPRateLimit(t *testing.T) {
+	n := newSimNetwork()
+	now := time.Unix(1700000000, 0)
+	n.now = func() time.Time { return now }
+	n.host(simTarget).ICMPRateLimit = 2
+
+	refused := func() int {
+		count := 0
+		for port := 1000; port < 1005; port++ {
+			conn, err := n.Dial("udp", net.JoinHostPort("192.0.2.10", strconv.Itoa(port)), time.Second)
+			require.NoError(t, err)
+			conn.Write([]byte("q"))
+			if _, err := conn.Read(make([]byte, 8)); errors.Is(err, syscall.ECONNREFUSED) {
+				count++
+			}
+		}
+		return count
+	}
+
+	assert.Equal(t, 2, refused())
+	// 同一秒内额度已用完
+	assert.Equal(t, 0, refused())
+	now = now.Add(time.Second)
+	assert.Equal(t, 2, refused())
+}
+
+func TestLossIsDeterministic(t *testing.T) {
+	run := func() []bool {
+		n := newSimNetwork()
+		n.host(simTarget).Loss = 0.5
+		var out []bool
+		for i := 0; i < 32; i++ {
+			_, err := n.DialContext(context.Background(), "tcp", "192.0.2.10:22")
+			out = append(out, err == nil)
+		}
+		return out
+	}
+
+	first := run()
+	assert.Equal(t, first, run())
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
+
+// sendProbe 通过RawConn发送TCP探测并返回应答
+func sendProbe(t *testing.T, raw *RawConn, port int, probe *layers.TCP) gopacket.Packet {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: raw.network.Local, DstIP: simTarget}
+	probe.SrcPort, probe.DstPort = 40000, layers.TCPPort(port)
+	probe.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	require.NoError(t, gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, probe))
+	require.NoError(t, raw.WritePacket(buf.Bytes(), simTarget))
+
+	data, err := raw.ReadPacket(time.Now().Add(10 * time.Millisecond))
+	if err != nil {
+		return nil
+	}
+	return gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+}
+
+func TestRawConnReplies(t *testing.T) {
+	n := newSimNetwork()
+	n.host(simTarget).TTL = 128
+	raw := n.RawConn()
+	defer raw.Close()
+
+	ip, err := raw.LocalIP(simTarget)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", ip.String())
+
+	// 开放端口：SYN/ACK，携带主机TTL、窗口与TCP选项
+	reply := sendProbe(t, raw, 22, &layers.TCP{SYN: true, Seq: 100})
+	require.NotNil(t, reply)
+	tcp := reply.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	assert.True(t, tcp.SYN && tcp.ACK)
+	assert.Equal(t, uint32(101), tcp.Ack)
+	assert.Equal(t, uint16(64240), tcp.Window)
+	assert.Len(t, tcp.Options, 5)
+	assert.Equal(t, uint8(128), reply.Layer(layers.LayerTypeIPv4).(*layers.IPv4).TTL)
+
+	// 开放端口丢弃FIN探测
+	assert.Nil(t, sendProbe(t, raw, 22, &layers.TCP{FIN: true}))
+
+	// 关闭端口：RST
+	reply = sendProbe(t, raw, 23, &layers.TCP{FIN: true, PSH: true, URG: true})
+	require.NotNil(t, reply)
+	assert.True(t, reply.Layer(layers.LayerTypeTCP).(*layers.TCP).RST)
+
+	// 过滤端口不应答
+	assert.Nil(t, sendProbe(t, raw, 25, &layers.TCP{SYN: true}))
+
+	// 管理禁止：ICMP不可达，携带原始报文
+	reply = sendProbe(t, raw, 113, &layers.TCP{SYN: true})
+	require.NotNil(t, reply)
+	icmp := reply.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+	assert.Equal(t, uint8(layers.ICMPv4CodeCommAdminProhibited), icmp.TypeCode.Code())
+	assert.Len(t, icmp.Payload, 28)
+
+	assert.Len(t, raw.Sent(), 5)
+	require.NoError(t, raw.Close())
+	_, err = raw.ReadPacket(time.Now().Add(time.Second))
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestRawConnResetOnFIN(t *testing.T) {
+	n := newSimNetwork()
+	n.host(simTarget).ResetOnFIN = true
+	raw := n.RawConn()
+	defer raw.Close()
+
+	reply := sendProbe(t, raw, 22, &layers.TCP{})
+	require.NotNil(t, reply)
+	assert.True(t, reply.Layer(layers.LayerTypeTCP).(*layers.TCP).RST)
+}
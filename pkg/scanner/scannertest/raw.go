@@ -0,0 +1,256 @@
+package scannertest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// RawConn 模拟网络中的原始报文传输，满足scanner.RawTransport
+// 写入的TCP探测按目标主机配置产生SYN/ACK、RST或ICMP不可达应答，
+// 发往关闭端口的UDP报文产生端口不可达，ICMP回显请求产生回显应答
+type RawConn struct {
+	network *Network
+	replies chan []byte
+	done    chan struct{}
+	once    sync.Once
+
+	mu   sync.Mutex
+	sent [][]byte
+}
+
+// RawConn 创建原始报文传输
+func (n *Network) RawConn() *RawConn {
+	return &RawConn{
+		network: n,
+		replies: make(chan []byte, 4096),
+		done:    make(chan struct{}),
+	}
+}
+
+// Sent 返回已写入的探测报文
+func (c *RawConn) Sent() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.sent...)
+}
+
+// LocalIP 返回模拟网络的本地地址
+func (c *RawConn) LocalIP(target net.IP) (net.IP, error) {
+	return c.network.Local, nil
+}
+
+// WritePacket 发送IPv4报文，目标主机的应答进入接收队列
+func (c *RawConn) WritePacket(data []byte, dst net.IP) error {
+	select {
+	case <-c.done:
+		return net.ErrClosed
+	default:
+	}
+
+	c.mu.Lock()
+	c.sent = append(c.sent, append([]byte(nil), data...))
+	c.mu.Unlock()
+
+	packet := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+	ip, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		return fmt.Errorf("无效的IPv4报文")
+	}
+	h := c.network.host(ip.DstIP)
+	if h == nil || c.network.lost(h) {
+		return nil
+	}
+
+	var reply []byte
+	var err error
+	switch l := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		reply, err = c.network.respond(c.network.route(h), ip, l, data)
+	case *layers.UDP:
+		reply, err = c.network.respondUDP(c.network.route(h), ip, l, data)
+	default:
+		if icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+			reply, err = c.network.respondEcho(h, ip, icmp)
+		}
+	}
+	if err != nil || reply == nil {
+		return err
+	}
+	select {
+	case c.replies <- reply:
+	default:
+		// 接收队列已满，按丢包处理
+	}
+	return nil
+}
+
+// ReadPacket 读取一个应答报文
+func (c *RawConn) ReadPacket(deadline time.Time) ([]byte, error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case data := <-c.replies:
+		return data, nil
+	case <-c.done:
+		return nil, net.ErrClosed
+	case <-timer.C:
+		return nil, os.ErrDeadlineExceeded
+	}
+}
+
+// Close 关闭传输
+func (c *RawConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+// respond 按端口状态构造主机对TCP探测的应答，nil表示不应答
+func (n *Network) respond(h *Host, ip *layers.IPv4, probe *layers.TCP, data []byte) ([]byte, error) {
+	if probe.RST {
+		return nil, nil
+	}
+
+	switch h.tcpState(int(probe.DstPort)) {
+	case PortOpen:
+		switch {
+		case probe.SYN && !probe.ACK:
+			return n.tcpReply(h, h.TTL, ip, probe, &layers.TCP{SYN: true, ACK: true, Window: h.Window, Options: h.TCPOptions})
+		case probe.ACK:
+			// 没有连接的ACK探测以RST应答，序列号取探测的确认号
+			return n.tcpReply(h, h.TTL, ip, probe, &layers.TCP{RST: true, Seq: probe.Ack})
+		case h.ResetOnFIN:
+			return n.tcpReply(h, h.TTL, ip, probe, &layers.TCP{RST: true, ACK: true})
+		default:
+			// 符合RFC 793的主机丢弃发往开放端口的FIN/NULL/XMAS探测
+			return nil, nil
+		}
+	case PortClosed:
+		return n.resetReply(h, h.TTL, ip, probe)
+	case PortSYNFiltered:
+		if probe.SYN && !probe.ACK {
+			return nil, nil
+		}
+		return n.resetReply(h, h.TTL, ip, probe)
+	case PortRejected:
+		return n.resetReply(h, h.FirewallTTL, ip, probe)
+	case PortProhibited:
+		if !n.allowICMP(h) {
+			return nil, nil
+		}
+		return n.icmpUnreachable(h, ip, data, layers.ICMPv4CodeCommAdminProhibited)
+	default:
+		return nil, nil
+	}
+}
+
+// resetReply 构造关闭端口的RST应答，ACK探测的RST序列号取探测的确认号
+func (n *Network) resetReply(h *Host, ttl uint8, probeIP *layers.IPv4, probe *layers.TCP) ([]byte, error) {
+	if probe.ACK {
+		return n.tcpReply(h, ttl, probeIP, probe, &layers.TCP{RST: true, Seq: probe.Ack})
+	}
+	return n.tcpReply(h, ttl, probeIP, probe, &layers.TCP{RST: true, ACK: true})
+}
+
+// tcpReply 构造主机h发往扫描器的TCP应答，ttl为发出应答的设备的TTL
+func (n *Network) tcpReply(h *Host, ttl uint8, probeIP *layers.IPv4, probe *layers.TCP, tcp *layers.TCP) ([]byte, error) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      ttl,
+		Id:       n.ipID(h),
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    probeIP.DstIP,
+		DstIP:    probeIP.SrcIP,
+	}
+	if h.DontFrag {
+		ip.Flags = layers.IPv4DontFragment
+	}
+	tcp.SrcPort, tcp.DstPort = probe.DstPort, probe.SrcPort
+	if tcp.ACK {
+		// 确认号覆盖探测中的SYN/FIN标志与载荷
+		tcp.Ack = probe.Seq + uint32(len(probe.Payload))
+		if probe.SYN || probe.FIN {
+			tcp.Ack++
+		}
+		if tcp.SYN {
+			tcp.Seq = n.randUint32()
+		}
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp); err != nil {
+		return nil, fmt.Errorf("构造应答报文失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// respondUDP 关闭的UDP端口以端口不可达应答，与Linux相同引用完整的原始报文
+func (n *Network) respondUDP(h *Host, ip *layers.IPv4, probe *layers.UDP, data []byte) ([]byte, error) {
+	if h.udpState(int(probe.DstPort)) != PortClosed || !n.allowICMP(h) {
+		return nil, nil
+	}
+	return n.icmpError(h, ip, data, layers.ICMPv4CodePort, len(data))
+}
+
+// respondEcho 以相同的标识、序列号、代码和数据应答ICMP回显请求
+func (n *Network) respondEcho(h *Host, probeIP *layers.IPv4, probe *layers.ICMPv4) ([]byte, error) {
+	if h.NoEcho || probe.TypeCode.Type() != layers.ICMPv4TypeEchoRequest {
+		return nil, nil
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      h.TTL,
+		Id:       n.ipID(h),
+		Protocol: layers.IPProtocolICMPv4,
+		SrcIP:    probeIP.DstIP,
+		DstIP:    probeIP.SrcIP,
+	}
+	icmp := &layers.ICMPv4{
+		TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoReply, probe.TypeCode.Code()),
+		Id:       probe.Id,
+		Seq:      probe.Seq,
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, icmp, gopacket.Payload(probe.Payload)); err != nil {
+		return nil, fmt.Errorf("构造ICMP应答失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// icmpUnreachable 构造ICMP不可达应答，携带原始报文的IP头和前8字节
+func (n *Network) icmpUnreachable(h *Host, probeIP *layers.IPv4, data []byte, code uint8) ([]byte, error) {
+	return n.icmpError(h, probeIP, data, code, int(probeIP.IHL)*4+8)
+}
+
+// icmpError 构造ICMP不可达应答，携带原始报文的前quote字节
+func (n *Network) icmpError(h *Host, probeIP *layers.IPv4, data []byte, code uint8, quote int) ([]byte, error) {
+	if quote > len(data) {
+		quote = len(data)
+	}
+
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      h.TTL,
+		Id:       n.ipID(h),
+		Protocol: layers.IPProtocolICMPv4,
+		SrcIP:    probeIP.DstIP,
+		DstIP:    probeIP.SrcIP,
+	}
+	icmp := &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, code)}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, icmp, gopacket.Payload(data[:quote])); err != nil {
+		return nil, fmt.Errorf("构造ICMP应答失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
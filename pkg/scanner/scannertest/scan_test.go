@@ -0,0 +1,495 @@
+package scannertest_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner"
+	"github.com/cyberspacesec/go-port-rocket/pkg/scanner/scannertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const target = "192.0.2.10"
+
+// newLinuxHost 构造符合RFC 793的Linux风格主机
+// TCP: 22开放(SSH)、80开放、23关闭、25过滤、113管理禁止
+// UDP: 53开放(应答)、123开放(不应答)、161过滤，其余关闭
+func newLinuxHost() *scannertest.Host {
+	return &scannertest.Host{
+		IP: net.ParseIP(target),
+		TCP: map[int]scannertest.PortState{
+			22:  scannertest.PortOpen,
+			80:  scannertest.PortOpen,
+			25:  scannertest.PortFiltered,
+			113: scannertest.PortProhibited,
+		},
+		UDP: map[int]scannertest.PortState{
+			53:  scannertest.PortOpen,
+			123: scannertest.PortOpen,
+			161: scannertest.PortFiltered,
+		},
+		Services: map[int]*scannertest.Service{
+			22: {Banner: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n"},
+			80: {Reply: func(req []byte) []byte { return []byte("HTTP/1.1 400 Bad Request\r\nServer: nginx\r\n\r\n") }},
+		},
+		UDPServices: map[int]*scannertest.Service{
+			53: {Reply: func(req []byte) []byte { return req }},
+		},
+	}
+}
+
+// statesByPort 将结果整理为端口到状态的映射
+func statesByPort(results []scanner.ScanResult) map[int]scanner.PortState {
+	states := make(map[int]scanner.PortState)
+	for _, r := range results {
+		states[r.Port] = r.State
+	}
+	return states
+}
+
+func TestConnectScanners(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(newLinuxHost())
+
+	expected := map[int]scanner.PortState{
+		22:  scanner.PortStateOpen,
+		80:  scanner.PortStateOpen,
+		23:  scanner.PortStateClosed,
+		25:  scanner.PortStateFiltered,
+		113: scanner.PortStateFiltered,
+	}
+
+	t.Run("TCPScanner", func(t *testing.T) {
+		results, err := scanner.NewTCPScanner().Scan(context.Background(), &scanner.ScanOptions{
+			Target:       target,
+			Ports:        "22,23,25,80,113",
+			Timeout:      time.Second,
+			Workers:      4,
+			ServiceProbe: true,
+			Dialer:       network,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, expected, statesByPort(results))
+		for _, r := range results {
+			switch r.Port {
+			case 22:
+				assert.Equal(t, "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n", r.Banner)
+			case 80:
+				assert.Contains(t, r.Banner, "Server: nginx")
+			}
+		}
+	})
+
+	t.Run("ExecuteScan", func(t *testing.T) {
+		results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+			Target:   target,
+			Ports:    "22,23,25,80,113",
+			ScanType: scanner.ScanTypeTCP,
+			Timeout:  time.Second,
+			Workers:  4,
+			Dialer:   network,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, expected, statesByPort(results))
+	})
+}
+
+func TestRawScanTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scanType scanner.ScanType
+		windows  bool
+		expected map[int]scanner.PortState
+	}{
+		{"SYN", scanner.ScanTypeSYN, false, map[int]scanner.PortState{
+			22: scanner.PortStateOpen, 23: scanner.PortStateClosed, 25: scanner.PortStateFiltered, 113: scanner.PortStateFiltered}},
+		{"FIN", scanner.ScanTypeFIN, false, map[int]scanner.PortState{
+			22: scanner.PortStateOpen, 23: scanner.PortStateClosed, 25: scanner.PortStateOpen, 113: scanner.PortStateFiltered}},
+		{"NULL", scanner.ScanTypeNULL, false, map[int]scanner.PortState{
+			22: scanner.PortStateOpen, 23: scanner.PortStateClosed, 25: scanner.PortStateOpen, 113: scanner.PortStateFiltered}},
+		{"XMAS", scanner.ScanTypeXMAS, false, map[int]scanner.PortState{
+			22: scanner.PortStateOpen, 23: scanner.PortStateClosed, 25: scanner.PortStateOpen, 113: scanner.PortStateFiltered}},
+		{"ACK", scanner.ScanTypeACK, false, map[int]scanner.PortState{
+			22: scanner.PortStateClosed, 23: scanner.PortStateClosed, 25: scanner.PortStateFiltered, 113: scanner.PortStateFiltered}},
+		// Windows对开放端口的FIN探测同样回RST，FIN扫描无法区分开放与关闭
+		{"FIN-Windows", scanner.ScanTypeFIN, true, map[int]scanner.PortState{
+			22: scanner.PortStateClosed, 23: scanner.PortStateClosed, 25: scanner.PortStateOpen, 113: scanner.PortStateFiltered}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network := scannertest.NewNetwork(1)
+			host := newLinuxHost()
+			host.ResetOnFIN = tt.windows
+			network.AddHost(host)
+
+			results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+				Target:       target,
+				Ports:        "22,23,25,113",
+				ScanType:     tt.scanType,
+				Timeout:      100 * time.Millisecond,
+				Workers:      4,
+				RawTransport: network.RawConn(),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, statesByPort(results))
+		})
+	}
+}
+
+func TestSYNScanReportsTTLAndOptions(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	host := newLinuxHost()
+	host.TTL = 128
+	host.Window = 8192
+	network.AddHost(host)
+
+	var trace bytes.Buffer
+	recorder, err := scanner.NewPacketRecorder(nil, &trace)
+	require.NoError(t, err)
+
+	// SYNScanner在提供传输时不要求root权限
+	results, err := scanner.NewSYNScanner().Scan(context.Background(), &scanner.ScanOptions{
+		Target:       target,
+		Ports:        "22,23",
+		Timeout:      100 * time.Millisecond,
+		Workers:      2,
+		RawTransport: network.RawConn(),
+		Capture:      recorder,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	for _, r := range results {
+		if r.Port != 22 {
+			assert.Equal(t, scanner.PortStateClosed, r.State)
+			continue
+		}
+		assert.Equal(t, scanner.PortStateOpen, r.State)
+		assert.Equal(t, 128, r.TTL)
+		assert.Equal(t, "syn-ack", r.Metadata["reason"])
+		assert.Equal(t, 8192, r.Metadata["window"])
+		assert.Equal(t, "MSS=1460,SACKPermitted,Timestamps,NOP,WS=7", r.Metadata["tcp_options"])
+	}
+
+	// 两个探测与两个应答都被记录
+	assert.Equal(t, 4, recorder.Count())
+	assert.Contains(t, trace.String(), "RCVD")
+	assert.Contains(t, trace.String(), " SA ")
+}
+
+func TestUDPScan(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(newLinuxHost())
+
+	results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+		Target:   target,
+		Ports:    "53,123,161,9999",
+		ScanType: scanner.ScanTypeUDP,
+		Timeout:  time.Second,
+		Workers:  2,
+		Dialer:   network,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]scanner.PortState{
+		53:   scanner.PortStateOpen,
+		123:  scanner.PortStateFiltered, // 开放但不应答，与过滤无法区分
+		161:  scanner.PortStateFiltered,
+		9999: scanner.PortStateClosed,
+	}, statesByPort(results))
+}
+
+func TestICMPRateLimitHidesClosedUDPPorts(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	host := newLinuxHost()
+	host.ICMPRateLimit = 1
+	network.AddHost(host)
+
+	results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+		Target:   target,
+		Ports:    "1000-1004",
+		ScanType: scanner.ScanTypeUDP,
+		Timeout:  time.Second,
+		Workers:  1,
+		Dialer:   network,
+	})
+	require.NoError(t, err)
+
+	counts := make(map[scanner.PortState]int)
+	for _, r := range results {
+		counts[r.State]++
+	}
+	// 只有第一个端口不可达被发出，其余关闭端口看起来像被过滤
+	assert.Equal(t, 1, counts[scanner.PortStateClosed])
+	assert.Equal(t, 4, counts[scanner.PortStateFiltered])
+}
+
+func TestPacketLoss(t *testing.T) {
+	t.Run("TotalLoss", func(t *testing.T) {
+		network := scannertest.NewNetwork(1)
+		host := newLinuxHost()
+		host.Loss = 1
+		network.AddHost(host)
+
+		results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+			Target:       target,
+			Ports:        "22,23",
+			ScanType:     scanner.ScanTypeSYN,
+			Timeout:      50 * time.Millisecond,
+			Workers:      2,
+			RawTransport: network.RawConn(),
+		})
+		require.NoError(t, err)
+		for _, r := range results {
+			assert.Equal(t, scanner.PortStateFiltered, r.State)
+		}
+	})
+
+	t.Run("RetriesRecover", func(t *testing.T) {
+		network := scannertest.NewNetwork(7)
+		host := newLinuxHost()
+		host.Loss = 0.5
+		network.AddHost(host)
+
+		// 丢包导致的超时按MaxRetries重试，最终识别出开放端口
+		results, err := scanner.NewTCPScanner().Scan(context.Background(), &scanner.ScanOptions{
+			Target:     target,
+			Ports:      "22,80",
+			Timeout:    time.Second,
+			Workers:    1,
+			MaxRetries: 10,
+			Dialer:     network,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[int]scanner.PortState{
+			22: scanner.PortStateOpen,
+			80: scanner.PortStateOpen,
+		}, statesByPort(results))
+	})
+
+	t.Run("RawRetriesRecover", func(t *testing.T) {
+		network := scannertest.NewNetwork(7)
+		host := newLinuxHost()
+		host.Loss = 0.5
+		network.AddHost(host)
+
+		// 原始报文扫描同样按MaxRetries重试无应答的端口
+		results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+			Target:       target,
+			Ports:        "22,80",
+			ScanType:     scanner.ScanTypeSYN,
+			Timeout:      50 * time.Millisecond,
+			Workers:      1,
+			MaxRetries:   10,
+			Quiet:        true,
+			RawTransport: network.RawConn(),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[int]scanner.PortState{
+			22: scanner.PortStateOpen,
+			80: scanner.PortStateOpen,
+		}, statesByPort(results))
+	})
+}
+
+func TestRawScanHostPolicy(t *testing.T) {
+	t.Run("HostTimeout", func(t *testing.T) {
+		network := scannertest.NewNetwork(1)
+		host := newLinuxHost()
+		host.Loss = 1
+		network.AddHost(host)
+
+		// 单主机超时中断正在进行的原始报文扫描
+		start := time.Now()
+		results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+			Target:       target,
+			Ports:        "1-100",
+			ScanType:     scanner.ScanTypeSYN,
+			Timeout:      100 * time.Millisecond,
+			Workers:      1,
+			HostTimeout:  250 * time.Millisecond,
+			Quiet:        true,
+			RawTransport: network.RawConn(),
+		})
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+		assert.Less(t, len(results), 100)
+		for _, r := range results {
+			assert.Equal(t, scanner.GiveUpReasonHostTimeout, r.Metadata["abandon_reason"])
+		}
+	})
+
+	t.Run("Tarpit", func(t *testing.T) {
+		network := scannertest.NewNetwork(1)
+		host := newLinuxHost()
+		host.DefaultTCP = scannertest.PortOpen
+		network.AddHost(host)
+
+		results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+			Target:       target,
+			Ports:        "1000-1999",
+			ScanType:     scanner.ScanTypeSYN,
+			Timeout:      time.Second,
+			Workers:      1,
+			DetectTarpit: true,
+			Quiet:        true,
+			RawTransport: network.RawConn(),
+		})
+		require.NoError(t, err)
+		assert.Less(t, len(results), 1000)
+		require.NotEmpty(t, results)
+		assert.Equal(t, scanner.GiveUpReasonTarpit, results[0].Metadata["abandon_reason"])
+	})
+
+	t.Run("UDPRetries", func(t *testing.T) {
+		network := scannertest.NewNetwork(1)
+		network.AddHost(newLinuxHost())
+
+		results, err := scanner.ExecuteScan(&scanner.ScanOptions{
+			Target:     target,
+			Ports:      "123",
+			ScanType:   scanner.ScanTypeUDP,
+			Timeout:    20 * time.Millisecond,
+			Workers:    1,
+			MaxRetries: 2,
+			Quiet:      true,
+			Dialer:     network,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, scanner.PortStateFiltered, results[0].State)
+		assert.Equal(t, 2, results[0].Metadata["retries"])
+	})
+}
+
+func TestDetectFirewall(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	host := newLinuxHost()
+	host.TCP[26] = scannertest.PortSYNFiltered
+	host.TCP[27] = scannertest.PortRejected
+	network.AddHost(host)
+
+	report, err := scanner.DetectFirewall(&scanner.ScanOptions{
+		Target:       target,
+		Ports:        "22,23,25,26,27,113",
+		Timeout:      50 * time.Millisecond,
+		Workers:      4,
+		Dialer:       network,
+		RawTransport: network.RawConn(),
+	}, nil, "53,9999")
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 64, report.HostTTL)
+
+	verdicts := make(map[string]scanner.FirewallVerdict)
+	for _, port := range report.Ports {
+		verdicts[port.Protocol+"/"+strconv.Itoa(port.Port)] = port.Verdict
+		assert.NotEmpty(t, port.Evidence)
+	}
+	assert.Equal(t, map[string]scanner.FirewallVerdict{
+		"tcp/22":   scanner.FirewallUnfiltered,
+		"tcp/23":   scanner.FirewallUnfiltered,
+		"tcp/25":   scanner.FirewallStateful,  // SYN与ACK均被丢弃
+		"tcp/26":   scanner.FirewallStateless, // 只丢弃SYN
+		"tcp/27":   scanner.FirewallStateful,  // 防火墙以TTL 255的RST拒绝
+		"tcp/113":  scanner.FirewallStateful,  // ICMP管理禁止
+		"udp/53":   scanner.FirewallUnfiltered,
+		"udp/9999": scanner.FirewallUnfiltered,
+	}, verdicts)
+}
+
+// newWebBackend 构造负载均衡后的Web后端，Date头带有固定的时钟偏差
+func newWebBackend(skew time.Duration, ipid uint16) *scannertest.Host {
+	return &scannertest.Host{
+		IPID: ipid,
+		TCP:  map[int]scannertest.PortState{80: scannertest.PortOpen},
+		Services: map[int]*scannertest.Service{80: {Reply: func(req []byte) []byte {
+			date := time.Now().Add(skew).UTC().Format(http.TimeFormat)
+			return []byte("HTTP/1.1 200 OK\r\nServer: nginx\r\nDate: " + date + "\r\nContent-Length: 0\r\n\r\n")
+		}}},
+	}
+}
+
+func TestDetectLoadBalancer(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(&scannertest.Host{
+		IP: net.ParseIP(target),
+		Backends: []*scannertest.Host{
+			newWebBackend(0, 1000),
+			newWebBackend(30*time.Second, 20000),
+			newWebBackend(-45*time.Second, 40000),
+		},
+	})
+	opts := &scanner.ScanOptions{
+		Target:       target,
+		Timeout:      50 * time.Millisecond,
+		Dialer:       network,
+		RawTransport: network.RawConn(),
+	}
+
+	report, err := scanner.DetectLoadBalancer(opts, 80, 9, false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	assert.True(t, report.BehindLoadBalancer)
+	assert.Equal(t, 3, report.Backends)
+
+	distinct := make(map[string]int)
+	for _, signal := range report.Signals {
+		distinct[signal.Name] = signal.Distinct
+	}
+	assert.Equal(t, map[string]int{"server": 1, "date": 3, "ttl": 1, "ipid": 3}, distinct)
+
+	// 单台主机的各项特征一致
+	single := scannertest.NewNetwork(1)
+	host := newWebBackend(0, 500)
+	host.IP = net.ParseIP(target)
+	single.AddHost(host)
+	opts.Dialer, opts.RawTransport = single, single.RawConn()
+
+	report, err = scanner.DetectLoadBalancer(opts, 80, 6, false)
+	require.NoError(t, err)
+	assert.False(t, report.BehindLoadBalancer)
+	assert.Equal(t, 1, report.Backends)
+}
+
+func TestExecuteHostScan(t *testing.T) {
+	network := scannertest.NewNetwork(1)
+	network.AddHost(newLinuxHost())
+
+	host, err := scanner.ExecuteHostScan(&scanner.ScanOptions{
+		Target:   target,
+		Ports:    "22,23,25",
+		ScanType: scanner.ScanTypeTCP,
+		Timeout:  time.Second,
+		Workers:  2,
+		Dialer:   network,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{target}, host.Addresses)
+	assert.Equal(t, scanner.HostStateUp, host.Status)
+	assert.Contains(t, host.Reason, "TCP/")
+	require.Len(t, host.TCP, 3)
+	assert.False(t, host.Timing.End.Before(host.Timing.Start))
+
+	// 同一主机的UDP阶段合并到同一个结果中
+	udpResults, err := scanner.ExecuteScan(&scanner.ScanOptions{
+		Target:   target,
+		Ports:    "53,9999",
+		ScanType: scanner.ScanTypeUDP,
+		Timeout:  time.Second,
+		Workers:  2,
+		Dialer:   network,
+	})
+	require.NoError(t, err)
+	host.AddResults(scanner.ScanTypeUDP, udpResults)
+	require.Len(t, host.UDP, 2)
+	assert.Equal(t, scanner.PortStateOpen, host.UDP[0].State)
+	assert.Equal(t, scanner.PortStateClosed, host.UDP[1].State)
+	assert.Len(t, host.Ports(), 5)
+}
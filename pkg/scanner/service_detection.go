@@ -2,11 +2,16 @@ package scanner
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/cyberspacesec/go-port-rocket/pkg/fingerprint"
+	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
 )
 
 // ServiceDetectionOptions 服务检测选项
@@ -15,7 +20,9 @@ type ServiceDetectionOptions struct {
 	VersionIntensity       int  // 版本检测强度(0-9)
 	EnableOSDetection      bool // 启用操作系统检测
 	BannerGrab             bool // 获取服务banner
+	TLSInspection          bool // 检查识别为ssl或未识别的端口的证书、协议版本、密码套件和JARM
 	Timeout                time.Duration
+	Dialer                 Dialer // 建立探测连接的拨号器，为nil时直接拨号
 }
 
 // DefaultServiceDetectionOptions 默认服务检测选项
@@ -25,6 +32,7 @@ func DefaultServiceDetectionOptions() *ServiceDetectionOptions {
 		VersionIntensity:       5,
 		EnableOSDetection:      false,
 		BannerGrab:             true,
+		TLSInspection:          true,
 		Timeout:                time.Second * 5,
 	}
 }
@@ -63,28 +71,100 @@ func GetServiceDescription(serviceName string) string {
 }
 
 // DetectService 检测服务版本信息
+// 启用版本检测时按nmap-service-probes的探测顺序发送探测，否则只读取连接后的banner
 func DetectService(target string, port int, opts *ServiceDetectionOptions) (*ServiceInfo, error) {
-	serviceName := ""
-
-	// 根据常见端口映射查找服务名称
-	if name, ok := CommonServices[port]; ok {
-		serviceName = name
+	info := &ServiceInfo{
+		Name: CommonServices[port],
+		Port: port,
+	}
+	if !opts.EnableVersionDetection {
+		err := grabServiceBanner(target, port, opts, info)
+		// TLS服务不会主动发送数据，读到明文banner的端口不检查
+		if err == nil && opts.TLSInspection && info.FullBanner == "" {
+			info.TLS = inspectTLS(target, port, "", opts.Timeout, opts.Dialer)
+		}
+		return info, err
 	}
 
-	info := &ServiceInfo{
-		Name:    serviceName,
-		Port:    port,
-		Version: "",
+	fp, err := GetFingerprinter("")
+	if err != nil {
+		return info, err
 	}
+	fpOpts := fingerprint.DefaultFingerprintOptions()
+	fpOpts.Timeout = opts.Timeout
+	fpOpts.VersionIntensity = opts.VersionIntensity
+	fpOpts.Dial = fingerprintDial(opts.Dialer)
+	fp.SetOptions(fpOpts)
 
-	// 1. 尝试TCP连接
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", target, port), opts.Timeout)
+	serviceFp, err := fp.DetectVersion(target, port, "tcp")
 	if err != nil {
 		return info, err
 	}
+
+	if serviceFp.Name != "" {
+		info.Name = serviceFp.Name
+		info.Product = serviceFp.Product
+		info.Version = serviceFp.Version
+		info.ExtraInfo = serviceFp.Info
+		info.CPE = serviceFp.CPE
+	}
+	if serviceFp.Record != nil {
+		info.Fingerprint = serviceFp.Record.String()
+	}
+	if opts.BannerGrab {
+		for _, probe := range serviceFp.Probes {
+			if probe.Type == "NULL" && len(probe.Response) > 0 {
+				info.FullBanner = strings.TrimSpace(string(probe.Response))
+				break
+			}
+		}
+		// 探测规则未给出版本时从banner中解析
+		if info.Version == "" {
+			parseVersionFromBanner(info)
+		}
+	}
+	if opts.TLSInspection && serviceFp.NeedsTLSInspection() {
+		info.TLS = inspectTLS(target, port, "", opts.Timeout, opts.Dialer)
+	}
+	return info, nil
+}
+
+// inspectTLS 检查应答ClientHello的端口，非TLS端口或检查失败时返回nil
+// serverName为空时目标是域名则用作SNI
+func inspectTLS(target string, port int, serverName string, timeout time.Duration, dialer Dialer) *fingerprint.TLSInfo {
+	opts := fingerprint.DefaultFingerprintOptions()
+	opts.Timeout = timeout
+	opts.Dial = fingerprintDial(dialer)
+	inspector := fingerprint.NewTLSInspector(opts)
+	inspector.SetServerName(serverName)
+
+	info, err := inspector.Inspect(target, port)
+	if err != nil {
+		if !errors.Is(err, fingerprint.ErrNotTLS) {
+			logger.Debugf("TLS检查失败 %s:%d: %v", target, port, err)
+		}
+		return nil
+	}
+	return info
+}
+
+// tlsServerName 扫描目标是单个域名时返回该域名，用作TLS检查的SNI
+func tlsServerName(target string) string {
+	_, count, unresolved, err := expandTargets(target, 1)
+	if err != nil || count != 1 || len(unresolved) != 1 {
+		return ""
+	}
+	return unresolved[0]
+}
+
+// grabServiceBanner 建立连接并读取服务主动发送的首行banner
+func grabServiceBanner(target string, port int, opts *ServiceDetectionOptions, info *ServiceInfo) error {
+	conn, err := fingerprintDial(opts.Dialer)("tcp", net.JoinHostPort(target, fmt.Sprint(port)), opts.Timeout)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
-	// 2. 获取初始banner
 	if opts.BannerGrab {
 		conn.SetDeadline(time.Now().Add(opts.Timeout))
 		reader := bufio.NewReader(conn)
@@ -95,115 +175,23 @@ func DetectService(target string, port int, opts *ServiceDetectionOptions) (*Ser
 			parseVersionFromBanner(info)
 		}
 	}
+	return nil
+}
 
-	// 3. 发送特定服务的探测包
-	if opts.EnableVersionDetection {
-		probes := getServiceProbes(info.Name, opts.VersionIntensity)
-		for _, probe := range probes {
-			conn.SetDeadline(time.Now().Add(opts.Timeout))
-			_, err := conn.Write([]byte(probe.Request))
-			if err != nil {
-				continue
-			}
-
-			// 读取响应
-			buf := make([]byte, 1024)
-			n, err := conn.Read(buf)
-			if err != nil {
-				continue
-			}
-
-			response := string(buf[:n])
-			if matches := probe.MatchPattern.FindStringSubmatch(response); len(matches) > 1 {
-				info.Product = matches[1]
-				if len(matches) > 2 {
-					info.Version = matches[2]
-				}
-				break
-			}
+// fingerprintDial 将扫描使用的拨号器转换为指纹识别的拨号函数
+func fingerprintDial(dialer Dialer) func(network, address string, timeout time.Duration) (net.Conn, error) {
+	if dialer == nil {
+		return net.DialTimeout
+	}
+	return func(network, address string, timeout time.Duration) (net.Conn, error) {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
 		}
+		return dialer.DialContext(ctx, network, address)
 	}
-
-	return info, nil
-}
-
-// ServiceProbe 服务探测规则
-type ServiceProbe struct {
-	ServiceName  string
-	Request      string
-	MatchPattern *regexp.Regexp
-}
-
-// getServiceProbes 获取服务探测规则
-func getServiceProbes(serviceName string, intensity int) []ServiceProbe {
-	probes := []ServiceProbe{
-		// HTTP
-		{
-			ServiceName:  "http",
-			Request:      "HEAD / HTTP/1.0\r\n\r\n",
-			MatchPattern: regexp.MustCompile(`Server: ([^\r\n]+)`),
-		},
-		// SSH
-		{
-			ServiceName:  "ssh",
-			Request:      "",
-			MatchPattern: regexp.MustCompile(`SSH-([0-9.]+)-([^\r\n]+)`),
-		},
-		// FTP
-		{
-			ServiceName:  "ftp",
-			Request:      "",
-			MatchPattern: regexp.MustCompile(`([^\r\n]+) FTP`),
-		},
-		// SMTP
-		{
-			ServiceName:  "smtp",
-			Request:      "EHLO localhost\r\n",
-			MatchPattern: regexp.MustCompile(`([^\r\n]+) ESMTP`),
-		},
-		// MySQL
-		{
-			ServiceName:  "mysql",
-			Request:      "",
-			MatchPattern: regexp.MustCompile(`([0-9.]+)-MariaDB`),
-		},
-		// Redis
-		{
-			ServiceName:  "redis",
-			Request:      "INFO\r\n",
-			MatchPattern: regexp.MustCompile(`redis_version:([0-9.]+)`),
-		},
-		// MongoDB
-		{
-			ServiceName:  "mongodb",
-			Request:      "\x3F\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xD4\x07\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00",
-			MatchPattern: regexp.MustCompile(`mongodb([0-9.]+)`),
-		},
-		// PostgreSQL
-		{
-			ServiceName:  "postgresql",
-			Request:      "\x00\x00\x00\x08\x04\xD2\x16\x2F",
-			MatchPattern: regexp.MustCompile(`PostgreSQL ([0-9.]+)`),
-		},
-		// Telnet
-		{
-			ServiceName:  "telnet",
-			Request:      "",
-			MatchPattern: regexp.MustCompile(`([^\r\n]+) telnet`),
-		},
-		// HTTPS/TLS
-		{
-			ServiceName:  "https",
-			Request:      "",
-			MatchPattern: regexp.MustCompile(`TLS ([0-9.]+)`),
-		},
-	}
-
-	// 根据强度过滤探测规则
-	if intensity < len(probes) {
-		return probes[:intensity]
-	}
-	return probes
 }
 
 // parseVersionFromBanner 从banner中解析版本信息
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"syscall"
 	"time"
 
 	"golang.org/x/net/icmp"
@@ -29,115 +28,37 @@ type SynScanState struct {
 // SYNScanner SYN扫描器
 type SYNScanner struct {
 	*baseScanner
+	engine *rawEngine
 }
 
 // NewSYNScanner 创建新的SYN扫描器
 func NewSYNScanner() *SYNScanner {
-	return &SYNScanner{
+	s := &SYNScanner{
 		baseScanner: newBaseScanner(ScanTypeSYN),
 	}
+	s.scanFunc = s.scanPort
+	return s
 }
 
-// scanPort 实现SYN端口扫描
-func (s *SYNScanner) scanPort(ctx context.Context, port int) (ScanResult, error) {
-	// 创建原始套接字
-	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
-	if err != nil {
-		return ScanResult{Port: port, State: PortStateUnknown}, fmt.Errorf("创建原始套接字失败: %v", err)
-	}
-	defer syscall.Close(fd)
-
-	// 设置套接字选项
-	err = syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, int(s.opts.Timeout.Milliseconds()))
-	if err != nil {
-		return ScanResult{Port: port, State: PortStateUnknown}, fmt.Errorf("设置套接字选项失败: %v", err)
-	}
-
-	// 构造TCP SYN包
-	addr := net.ParseIP(s.opts.Target)
-	if addr == nil {
-		return ScanResult{Port: port, State: PortStateUnknown}, fmt.Errorf("无效的目标IP地址: %s", s.opts.Target)
+// Scan 执行SYN扫描，同一次扫描的所有端口共用一个原始报文引擎
+func (s *SYNScanner) Scan(ctx context.Context, opts *ScanOptions) ([]ScanResult, error) {
+	if err := s.ValidateOptions(opts); err != nil {
+		return nil, err
 	}
 
-	// 构造TCP头
-	tcpHeader := make([]byte, 20)
-	tcpHeader[0] = 0x50 // 数据偏移
-	tcpHeader[1] = 0x00 // 保留
-	tcpHeader[2] = 0x00 // 窗口大小
-	tcpHeader[3] = 0x00
-	tcpHeader[4] = 0x00 // 校验和
-	tcpHeader[5] = 0x00
-	tcpHeader[6] = 0x02 // SYN标志
-	tcpHeader[7] = 0x00
-	tcpHeader[8] = 0x00 // 序列号
-	tcpHeader[9] = 0x00
-	tcpHeader[10] = 0x00
-	tcpHeader[11] = 0x00
-	tcpHeader[12] = 0x00 // 确认号
-	tcpHeader[13] = 0x00
-	tcpHeader[14] = 0x00
-	tcpHeader[15] = 0x00
-	tcpHeader[16] = 0x00 // 紧急指针
-	tcpHeader[17] = 0x00
-	tcpHeader[18] = 0x00
-	tcpHeader[19] = 0x00
-
-	// 构造IP头
-	ipHeader := make([]byte, 20)
-	ipHeader[0] = 0x45 // 版本和头部长度
-	ipHeader[1] = 0x00 // 服务类型
-	ipHeader[2] = 0x00 // 总长度
-	ipHeader[3] = 0x28
-	ipHeader[4] = 0x00 // 标识
-	ipHeader[5] = 0x00
-	ipHeader[6] = 0x40 // 标志和片偏移
-	ipHeader[7] = 0x00
-	ipHeader[8] = 0x40  // 生存时间
-	ipHeader[9] = 0x06  // 协议(TCP)
-	ipHeader[10] = 0x00 // 校验和
-	ipHeader[11] = 0x00
-	ipHeader[12] = 0x00 // 源IP
-	ipHeader[13] = 0x00
-	ipHeader[14] = 0x00
-	ipHeader[15] = 0x00
-	ipHeader[16] = byte(addr[0]) // 目标IP
-	ipHeader[17] = byte(addr[1])
-	ipHeader[18] = byte(addr[2])
-	ipHeader[19] = byte(addr[3])
-
-	// 发送SYN包
-	packet := append(ipHeader, tcpHeader...)
-	sa := &syscall.SockaddrInet4{
-		Addr: [4]byte{addr[0], addr[1], addr[2], addr[3]},
-	}
-	err = syscall.Sendto(fd, packet, 0, sa)
-	if err != nil {
-		return ScanResult{Port: port, State: PortStateUnknown}, fmt.Errorf("发送SYN包失败: %v", err)
-	}
-
-	// 接收响应
-	buf := make([]byte, 1024)
-	n, _, err := syscall.Recvfrom(fd, buf, 0)
+	engine, err := newRawEngine(opts.Target, ScanTypeSYN, opts.RawTransport, opts.Capture)
 	if err != nil {
-		if err == syscall.EAGAIN {
-			return ScanResult{Port: port, State: PortStateFiltered}, nil
-		}
-		return ScanResult{Port: port, State: PortStateUnknown}, fmt.Errorf("接收响应失败: %v", err)
+		return nil, err
 	}
+	defer engine.close()
 
-	// 解析响应
-	if n > 0 {
-		// 检查是否是RST包
-		if buf[33]&0x04 != 0 {
-			return ScanResult{Port: port, State: PortStateClosed}, nil
-		}
-		// 检查是否是SYN-ACK包
-		if buf[33]&0x12 != 0 {
-			return ScanResult{Port: port, State: PortStateOpen}, nil
-		}
-	}
+	s.engine = engine
+	return s.baseScanner.Scan(ctx, opts)
+}
 
-	return ScanResult{Port: port, State: PortStateUnknown}, nil
+// scanPort 实现SYN端口扫描
+func (s *SYNScanner) scanPort(ctx context.Context, port int) (ScanResult, error) {
+	return s.engine.probe(ctx, port, s.opts.Timeout)
 }
 
 // ValidateOptions 验证SYN扫描选项
@@ -146,8 +67,8 @@ func (s *SYNScanner) ValidateOptions(opts *ScanOptions) error {
 		return err
 	}
 
-	// 检查是否有root权限
-	if os.Geteuid() != 0 {
+	// 使用原始套接字时需要root权限
+	if opts.RawTransport == nil && os.Geteuid() != 0 {
 		return ErrRootRequired
 	}
 
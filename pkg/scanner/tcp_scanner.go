@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,9 +19,11 @@ type TCPScanner struct {
 
 // NewTCPScanner 创建新的TCP扫描器
 func NewTCPScanner() *TCPScanner {
-	return &TCPScanner{
+	s := &TCPScanner{
 		baseScanner: newBaseScanner(ScanTypeTCP),
 	}
+	s.scanFunc = s.scanPort
+	return s
 }
 
 // scanPort 实现TCP端口扫描
@@ -30,8 +33,10 @@ func (s *TCPScanner) scanPort(ctx context.Context, port int) (ScanResult, error)
 		return ScanResult{Port: port, State: PortStateUnknown}, err
 	}
 
-	conn, netErr := utils.ConnectWithTimeout(s.opts.Target, port, s.opts.Timeout)
-	if netErr != nil {
+	address := net.JoinHostPort(s.opts.Target, strconv.Itoa(port))
+	conn, err := scanDialer(s.opts).DialContext(ctx, "tcp", address)
+	if err != nil {
+		netErr := utils.AnalyzeNetworkError(err)
 		switch netErr.Type {
 		case "timeout":
 			return ScanResult{Port: port, State: PortStateFiltered}, nil
@@ -51,6 +56,7 @@ func (s *TCPScanner) scanPort(ctx context.Context, port int) (ScanResult, error)
 	if !s.verifyConnection(conn) {
 		return ScanResult{Port: port, State: PortStateClosed}, nil
 	}
+	conn = wrapConn(conn, s.opts.Capture)
 
 	// 如果连接成功，端口是开放的
 	result := ScanResult{
@@ -0,0 +1,171 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Dialer 建立连接的抽象，connect扫描与UDP扫描通过它访问网络
+// *net.Dialer满足该接口，测试中可替换为模拟网络
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// RawTransport 原始报文收发的抽象，原始报文引擎通过它发送探测并接收应答
+// 默认实现为原始套接字，测试中可替换为模拟网络
+type RawTransport interface {
+	// LocalIP 返回向目标发送探测时使用的源地址
+	LocalIP(target net.IP) (net.IP, error)
+	// WritePacket 发送完整的IPv4报文
+	WritePacket(data []byte, dst net.IP) error
+	// ReadPacket 读取一个IPv4报文，超过deadline时返回os.ErrDeadlineExceeded
+	ReadPacket(deadline time.Time) ([]byte, error)
+	// Close 关闭传输
+	Close() error
+}
+
+// scanDialer 返回扫描使用的拨号器
+func scanDialer(opts *ScanOptions) Dialer {
+	if opts != nil && opts.Dialer != nil {
+		return opts.Dialer
+	}
+	var timeout time.Duration
+	if opts != nil {
+		timeout = opts.Timeout
+	}
+	return &net.Dialer{Timeout: timeout}
+}
+
+// socketTransport 基于原始TCP套接字(IP_HDRINCL)的报文传输，需要root权限
+type socketTransport struct {
+	fd  int
+	buf []byte
+}
+
+// newSocketTransport 创建原始套接字传输
+func newSocketTransport() (*socketTransport, error) {
+	if os.Geteuid() != 0 {
+		return nil, ErrRootRequired
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, fmt.Errorf("创建原始套接字失败: %v", err)
+	}
+	// 由引擎构造完整IP头
+	if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_HDRINCL, 1); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("设置套接字选项失败: %v", err)
+	}
+	return &socketTransport{fd: fd, buf: make([]byte, 65535)}, nil
+}
+
+// LocalIP 通过UDP路由查询获取源地址，不会发送报文
+func (t *socketTransport) LocalIP(target net.IP) (net.IP, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(target.String(), "9"))
+	if err != nil {
+		return nil, fmt.Errorf("获取源地址失败: %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.To4(), nil
+}
+
+// WritePacket 发送IPv4报文
+func (t *socketTransport) WritePacket(data []byte, dst net.IP) error {
+	ip4 := dst.To4()
+	if ip4 == nil {
+		return fmt.Errorf("原始报文引擎只支持IPv4: %s", dst)
+	}
+	sa := &syscall.SockaddrInet4{}
+	copy(sa.Addr[:], ip4)
+	return syscall.Sendto(t.fd, data, 0, sa)
+}
+
+// ReadPacket 读取IPv4报文
+func (t *socketTransport) ReadPacket(deadline time.Time) ([]byte, error) {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return nil, os.ErrDeadlineExceeded
+	}
+	return recvPacket(t.fd, t.buf, wait)
+}
+
+// recvPacket 在wait时间内从原始套接字读取一个报文
+func recvPacket(fd int, buf []byte, wait time.Duration) ([]byte, error) {
+	tv := syscall.NsecToTimeval(wait.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return nil, fmt.Errorf("设置接收超时失败: %v", err)
+	}
+
+	n, _, err := syscall.Recvfrom(fd, buf, 0)
+	if err != nil {
+		if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EINTR) {
+			return nil, os.ErrDeadlineExceeded
+		}
+		return nil, fmt.Errorf("接收响应失败: %v", err)
+	}
+	return append([]byte(nil), buf[:n]...), nil
+}
+
+// Close 关闭原始套接字
+func (t *socketTransport) Close() error {
+	return syscall.Close(t.fd)
+}
+
+// icmpPollInterval 同时接收TCP和ICMP报文时每个套接字的单次等待时间
+const icmpPollInterval = 10 * time.Millisecond
+
+// icmpSocketTransport 在原始TCP套接字之外再接收ICMP报文，用于端口扫描和操作系统探测
+// 两个套接字以较短的超时轮流读取，不依赖平台相关的select
+type icmpSocketTransport struct {
+	*socketTransport
+	icmp    int
+	icmpBuf []byte
+}
+
+// newICMPSocketTransport 创建同时接收TCP和ICMP报文的原始套接字传输
+func newICMPSocketTransport() (*icmpSocketTransport, error) {
+	tcp, err := newSocketTransport()
+	if err != nil {
+		return nil, err
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+	if err != nil {
+		tcp.Close()
+		return nil, fmt.Errorf("创建ICMP原始套接字失败: %v", err)
+	}
+	return &icmpSocketTransport{socketTransport: tcp, icmp: fd, icmpBuf: make([]byte, 65535)}, nil
+}
+
+// ReadPacket 轮流从TCP和ICMP套接字读取IPv4报文
+func (t *icmpSocketTransport) ReadPacket(deadline time.Time) ([]byte, error) {
+	for {
+		for _, sock := range []struct {
+			fd  int
+			buf []byte
+		}{{t.fd, t.buf}, {t.icmp, t.icmpBuf}} {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return nil, os.ErrDeadlineExceeded
+			}
+			if wait > icmpPollInterval {
+				wait = icmpPollInterval
+			}
+			data, err := recvPacket(sock.fd, sock.buf, wait)
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				return data, err
+			}
+		}
+	}
+}
+
+// Close 关闭两个原始套接字
+func (t *icmpSocketTransport) Close() error {
+	syscall.Close(t.icmp)
+	return t.socketTransport.Close()
+}
@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestICMPTransport 创建真实的ICMP原始报文传输，无root权限时跳过测试
+func newTestICMPTransport(t *testing.T) *icmpSocketTransport {
+	if os.Geteuid() != 0 {
+		t.Skip("需要root权限创建原始套接字")
+	}
+	transport, err := newICMPSocketTransport()
+	if err != nil {
+		t.Skipf("无法创建原始套接字: %v", err)
+	}
+	t.Cleanup(func() { transport.Close() })
+	return transport
+}
+
+func TestICMPSocketTransportReceivesUnreachable(t *testing.T) {
+	transport := newTestICMPTransport(t)
+
+	// 向关闭的UDP端口发送数据，内核以ICMP端口不可达应答
+	listener, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.LocalAddr().(*net.UDPAddr).Port
+	listener.Close()
+	conn, err := net.Dial("udp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("probe"))
+	require.NoError(t, err)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := transport.ReadPacket(deadline)
+		require.NoError(t, err, "没有收到ICMP不可达")
+		packet := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+		icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+		if !ok || icmp.TypeCode.Type() != layers.ICMPv4TypeDestinationUnreachable {
+			continue
+		}
+		assert.Equal(t, uint8(layers.ICMPv4CodePort), icmp.TypeCode.Code())
+		return
+	}
+}
+
+func TestFirewallTransport(t *testing.T) {
+	newTestICMPTransport(t)
+
+	transport := firewallTransport()
+	require.NotNil(t, transport)
+	defer transport.Close()
+	_, ok := transport.(*icmpSocketTransport)
+	assert.True(t, ok, "防火墙探测应使用同时接收ICMP的传输")
+}
+
+func TestDetectFirewallRawSockets(t *testing.T) {
+	newTestICMPTransport(t)
+
+	open := listenLocal(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	report, err := DetectFirewall(&ScanOptions{
+		Target:  "127.0.0.1",
+		Ports:   strconv.Itoa(open) + "," + strconv.Itoa(closed),
+		Timeout: 500 * time.Millisecond,
+		Workers: 1,
+	}, []ScanType{ScanTypeSYN, ScanTypeACK}, "")
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	require.Len(t, report.Ports, 2)
+	for _, p := range report.Ports {
+		assert.Equal(t, FirewallUnfiltered, p.Verdict, "端口 %d: %v", p.Port, p.Evidence)
+	}
+}
+
+func TestRawEngineRealSockets(t *testing.T) {
+	newTestICMPTransport(t)
+
+	open := listenLocal(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	results, err := rawScan(context.Background(), ScanTypeSYN, nil, nil, "127.0.0.1", []int{open, closed}, time.Second, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, PortStateOpen, results[0].State)
+	assert.Equal(t, PortStateClosed, results[1].State)
+}
+
+// injectUnreachable 通过原始套接字向本机发送ICMP不可达，内层报文为srcPort到dstPort的TCP探测
+func injectUnreachable(t *testing.T, srcPort, dstPort int, code uint8) {
+	loopback := net.IPv4(127, 0, 0, 1).To4()
+	inner := gopacket.NewSerializeBuffer()
+	innerTCP := &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), FIN: true}
+	innerIP := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: loopback, DstIP: loopback}
+	innerTCP.SetNetworkLayerForChecksum(innerIP)
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(inner, opts, innerIP, innerTCP))
+
+	// 与路由器一样只引用内层TCP头的前8字节
+	quoted := inner.Bytes()[:20+8]
+	buf := gopacket.NewSerializeBuffer()
+	require.NoError(t, gopacket.SerializeLayers(buf, opts,
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolICMPv4, SrcIP: loopback, DstIP: loopback},
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, code)},
+		gopacket.Payload(quoted)))
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_RAW)
+	require.NoError(t, err)
+	defer syscall.Close(fd)
+	sa := &syscall.SockaddrInet4{}
+	copy(sa.Addr[:], loopback)
+	require.NoError(t, syscall.Sendto(fd, buf.Bytes(), 0, sa))
+}
+
+func TestRawEngineRealSocketsICMP(t *testing.T) {
+	newTestICMPTransport(t)
+
+	// Linux对开放端口上的FIN探测不应答，期间由ICMP管理禁止应答决定端口状态
+	port := listenLocal(t)
+	engine, err := newRawEngine("127.0.0.1", ScanTypeFIN, nil, nil)
+	require.NoError(t, err)
+	defer engine.close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		injectUnreachable(t, int(engine.srcPort), port, layers.ICMPv4CodeCommAdminProhibited)
+	}()
+	result, err := engine.probe(context.Background(), port, 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, PortStateFiltered, result.State)
+	assert.Equal(t, "icmp-unreach-13", result.Metadata["reason"])
+}
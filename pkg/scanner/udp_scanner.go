@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cyberspacesec/go-port-rocket/pkg/logger"
@@ -22,6 +25,26 @@ type UDPScanResult struct {
 	Banner      string    // Banner
 	Reason      string    // 原因
 	TTL         int       // TTL值
+	Retries     int       // 超时后的重试次数
+}
+
+// toScanResult 转换为通用ScanResult格式
+func (r UDPScanResult) toScanResult() ScanResult {
+	metadata := map[string]interface{}{"reason": r.Reason}
+	if r.Retries > 0 {
+		metadata["retries"] = r.Retries
+	}
+	return ScanResult{
+		Port:        r.Port,
+		State:       r.State,
+		Type:        ScanTypeUDP,
+		ServiceName: r.ServiceName,
+		Version:     r.Version,
+		Banner:      r.Banner,
+		Open:        r.State == PortStateOpen,
+		TTL:         r.TTL,
+		Metadata:    metadata,
+	}
 }
 
 // UDPScanner UDP扫描器
@@ -30,6 +53,10 @@ type UDPScanner struct {
 	ports   []int
 	timeout time.Duration
 	workers int
+	dialer  Dialer // 为空时直接访问网络
+	retries int    // 超时未应答的端口的重试次数
+	// observe 每个端口得到结果后调用，供主机放弃策略判定tarpit
+	observe func(ScanResult)
 	mu      sync.Mutex
 	results []UDPScanResult
 }
@@ -50,7 +77,6 @@ func (s *UDPScanner) Scan(ctx context.Context) ([]UDPScanResult, error) {
 	// 创建工作通道
 	portChan := make(chan int, len(s.ports))
 	resultChan := make(chan UDPScanResult, len(s.ports))
-	doneChan := make(chan struct{})
 
 	// 启动工作协程
 	var wg sync.WaitGroup
@@ -63,7 +89,10 @@ func (s *UDPScanner) Scan(ctx context.Context) ([]UDPScanResult, error) {
 				case <-ctx.Done():
 					return
 				default:
-					result := s.scanPort(port)
+					result := s.probePort(ctx, port)
+					if s.observe != nil {
+						s.observe(result.toScanResult())
+					}
 					resultChan <- result
 				}
 			}
@@ -74,7 +103,6 @@ func (s *UDPScanner) Scan(ctx context.Context) ([]UDPScanResult, error) {
 	go func() {
 		wg.Wait()
 		close(resultChan)
-		close(doneChan)
 	}()
 
 	// 分发端口
@@ -91,24 +119,33 @@ func (s *UDPScanner) Scan(ctx context.Context) ([]UDPScanResult, error) {
 		close(portChan)
 	}()
 
-	// 处理结果
+	// 处理结果，结果通道关闭时所有工作协程均已退出
 	for {
 		select {
 		case <-ctx.Done():
 			return s.results, ctx.Err()
 		case result, ok := <-resultChan:
 			if !ok {
-				continue
+				return s.results, nil
 			}
 			s.mu.Lock()
 			s.results = append(s.results, result)
 			s.mu.Unlock()
-		case <-doneChan:
-			return s.results, nil
 		}
 	}
 }
 
+// probePort 扫描单个UDP端口，超时未应答的端口按retries重试
+func (s *UDPScanner) probePort(ctx context.Context, port int) UDPScanResult {
+	var result UDPScanResult
+	retried, _ := retryPort(ctx, s.retries, func() (ScanResult, error) {
+		result = s.scanPort(port)
+		return result.toScanResult(), nil
+	})
+	result.Retries, _ = retried.Metadata["retries"].(int)
+	return result
+}
+
 // scanPort 扫描单个UDP端口
 func (s *UDPScanner) scanPort(port int) UDPScanResult {
 	result := UDPScanResult{
@@ -122,19 +159,19 @@ func (s *UDPScanner) scanPort(port int) UDPScanResult {
 	payload := getUDPProbeForPort(port)
 
 	// 发送UDP数据包
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", s.target, port))
-	if err != nil {
-		logger.Debugf("UDP地址解析失败 %s:%d: %v", s.target, port, err)
-		result.State = PortStateFiltered
-		result.Reason = "resolve-failed"
-		return result
+	dialer := s.dialer
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: s.timeout}
 	}
-
-	conn, err := net.DialUDP("udp", nil, addr)
+	conn, err := dialer.DialContext(context.Background(), "udp", net.JoinHostPort(s.target, strconv.Itoa(port)))
 	if err != nil {
 		logger.Debugf("UDP连接失败 %s:%d: %v", s.target, port, err)
 		result.State = PortStateFiltered
 		result.Reason = "connect-failed"
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			result.Reason = "resolve-failed"
+		}
 		return result
 	}
 	defer conn.Close()
@@ -158,6 +195,12 @@ func (s *UDPScanner) scanPort(port int) UDPScanResult {
 	n, err := conn.Read(buf)
 
 	if err != nil {
+		// 已连接的UDP套接字收到ICMP端口不可达时读取返回ECONNREFUSED
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			result.State = PortStateClosed
+			result.Reason = "port-unreach"
+			return result
+		}
 		// 超时通常意味着端口被过滤或者没有服务
 		// 在UDP中，没有响应可能意味着过滤或开放但不响应
 		result.State = PortStateFiltered
@@ -44,11 +44,20 @@ type ScanOptions struct {
 	RateLimit        int                      // 速率限制
 	Retries          int                      // 重试次数
 	Verbose          bool                     // 详细输出
+	Quiet            bool                     // 不打印扫描建议，用于重复执行或输出机器可读结果的扫描
 	VersionIntensity int                      // 版本检测强度
 	GuessOS          bool                     // 推测操作系统
 	LimitOSScan      bool                     // 限制操作系统扫描
 	Service          *ServiceDetectionOptions // 服务检测选项
 	OutputFile       string                   // 输出文件
+	Observers        []Observer               // 扫描事件观察者
+	HostTimeout      time.Duration            // 单主机扫描总超时，0表示不限制
+	MaxRetries       int                      // 端口被过滤(超时)时的最大重试次数
+	DetectTarpit     bool                     // 启用tarpit检测，几乎所有端口开放时放弃该主机
+	Capture          *PacketRecorder          // 报文记录器，写入pcapng或实时打印
+	Replay           *PacketReplay            // 离线重放数据，设置后不访问网络
+	Dialer           Dialer                   // connect与UDP扫描使用的拨号器，为空时直接访问网络
+	RawTransport     RawTransport             // 原始报文扫描使用的传输，为空时使用原始套接字
 }
 
 // NewScanOptions 创建新的扫描选项，使用合理的默认值
@@ -72,6 +81,7 @@ func NewScanOptions(target string, ports []int, scanType ScanType) *ScanOptions
 		VersionIntensity: 0,               // 默认禁用版本检测
 		GuessOS:          false,           // 默认禁用OS猜测
 		LimitOSScan:      false,           // 默认不限制OS扫描
+		DetectTarpit:     false,           // 默认禁用tarpit检测（避免误判开放端口多的主机）
 	}
 }
 
@@ -100,15 +110,16 @@ type ScanConfig struct {
 
 // ServiceInfo 服务信息
 type ServiceInfo struct {
-	Name        string   // 服务名称
-	Port        int      // 端口号
-	Version     string   // 版本号
-	Product     string   // 产品名称
-	ExtraInfo   string   // 额外信息
-	FullBanner  string   // 完整的Banner信息
-	Fingerprint string   // 指纹
-	CPE         []string // Common Platform Enumeration
-	TTL         int      // Time To Live (用于OS检测)
+	Name        string               // 服务名称
+	Port        int                  // 端口号
+	Version     string               // 版本号
+	Product     string               // 产品名称
+	ExtraInfo   string               // 额外信息
+	FullBanner  string               // 完整的Banner信息
+	Fingerprint string               // 指纹
+	CPE         []string             // Common Platform Enumeration
+	TTL         int                  // Time To Live (用于OS检测)
+	TLS         *fingerprint.TLSInfo `json:",omitempty" xml:",omitempty"` // TLS检查结果
 }
 
 // ScanStats 扫描统计信息
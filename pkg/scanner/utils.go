@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -60,6 +61,53 @@ func ParsePorts(portsStr string) ([]int, error) {
 	return ports, nil
 }
 
+// ShardPorts 将端口范围去重排序后按每片size个端口切分，每片以紧凑的范围字符串表示
+func ShardPorts(portsStr string, size int) ([]string, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("无效的分片大小: %d", size)
+	}
+	ports, err := ParsePorts(portsStr)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Ints(ports)
+	unique := ports[:0]
+	for i, port := range ports {
+		if i == 0 || port != ports[i-1] {
+			unique = append(unique, port)
+		}
+	}
+
+	var shards []string
+	for start := 0; start < len(unique); start += size {
+		end := start + size
+		if end > len(unique) {
+			end = len(unique)
+		}
+		shards = append(shards, formatPortRanges(unique[start:end]))
+	}
+	return shards, nil
+}
+
+// formatPortRanges 将有序端口列表格式化为范围字符串，如 22,80-82
+func formatPortRanges(ports []int) string {
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
 // ConvertServiceInfoToFingerprint 将ServiceInfo转换为fingerprint.Service
 func ConvertServiceInfoToFingerprint(info *ServiceInfo) *fingerprint.Service {
 	if info == nil {
@@ -67,12 +115,14 @@ func ConvertServiceInfoToFingerprint(info *ServiceInfo) *fingerprint.Service {
 	}
 
 	service := &fingerprint.Service{
-		Name:       info.Name,
-		Version:    info.Version,
-		Product:    info.Product,
-		Protocol:   "tcp", // 默认为TCP协议
-		Banner:     info.FullBanner,
-		Confidence: 100.0, // 默认置信度为100%
+		Name:        info.Name,
+		Version:     info.Version,
+		Product:     info.Product,
+		Protocol:    "tcp", // 默认为TCP协议
+		Banner:      info.FullBanner,
+		Confidence:  100.0, // 默认置信度为100%
+		Fingerprint: info.Fingerprint,
+		TLS:         info.TLS,
 	}
 
 	// 如果有CPE信息，则添加
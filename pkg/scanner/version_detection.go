@@ -309,7 +309,8 @@ func detectMySQL(target string, port int, timeout time.Duration) (*ServiceInfo,
 	return info, nil
 }
 
-// GetFingerprinter 获取指纹识别器实例，优先使用嵌入的指纹数据库
+// GetFingerprinter 获取指纹识别器实例，nmapSharePath为空时使用配置的数据目录或嵌入的指纹数据库
+// 指纹库在进程内共享，每次调用只创建轻量的识别器
 func GetFingerprinter(nmapSharePath string) (*fingerprint.Fingerprinter, error) {
 	// 创建指纹识别器实例
 	fp, err := fingerprint.NewFingerprinter(nmapSharePath)
@@ -201,7 +201,8 @@ func AnalyzeNetworkError(err error) *NetworkError {
 
 	// 检查网络不可达
 	if strings.Contains(errStr, "network is unreachable") ||
-		strings.Contains(errStr, "host is unreachable") {
+		strings.Contains(errStr, "host is unreachable") ||
+		strings.Contains(errStr, "no route to host") {
 		return &NetworkError{
 			Type:    "unreachable",
 			Message: "网络不可达",
@@ -219,7 +220,7 @@ func AnalyzeNetworkError(err error) *NetworkError {
 
 // ConnectWithTimeout 带超时的TCP连接
 func ConnectWithTimeout(target string, port int, timeout time.Duration) (net.Conn, *NetworkError) {
-	addr := fmt.Sprintf("%s:%d", target, port)
+	addr := net.JoinHostPort(target, strconv.Itoa(port))
 	conn, err := net.DialTimeout("tcp", addr, timeout)
 	if err != nil {
 		return nil, AnalyzeNetworkError(err)